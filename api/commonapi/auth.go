@@ -0,0 +1,95 @@
+package commonapi
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// Authorizes request with Bearer API token, or with session cookie when there is no Authorization header.
+// Sessions have full access, tokens must have the scope
+func AuthorizeRequest(r *http.Request, userDB *dbs.UserTestDB, sessionDB *dbs.SessionDB, apiTokenDB *dbs.ApiTokenDB, scope dbs.ApiTokenScope) (*dbs.UserTestDBEntry, error) {
+	authzHeader := r.Header.Get("Authorization")
+	if authzHeader != "" {
+		return authorizeApiToken(authzHeader, userDB, apiTokenDB, scope)
+	}
+
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("Failed to read cookie. " + err.Error())
+	}
+
+	sessionInst, err := sessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("Session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("Unauthorized!")
+	}
+
+	userInst, err := userDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("User does not exists. " + err.Error())
+	}
+
+	if userInst.Status == dbs.AS_Blocked {
+		return nil, errors.New("User " + userInst.Email + " is blocked")
+	}
+
+	return userInst, nil
+}
+
+func authorizeApiToken(authzHeader string, userDB *dbs.UserTestDB, apiTokenDB *dbs.ApiTokenDB, scope dbs.ApiTokenScope) (*dbs.UserTestDBEntry, error) {
+	if !strings.HasPrefix(authzHeader, "Bearer ") {
+		return nil, errors.New("Authorization header must be Bearer token")
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(authzHeader, "Bearer "))
+	tokenEntry, err := apiTokenDB.GetByToken(token)
+	if err != nil {
+		return nil, errors.New("Invalid api token. " + err.Error())
+	}
+
+	if !tokenEntry.HasScope(scope) {
+		return nil, errors.New("Api token " + tokenEntry.Id + " does not have " + string(scope) + " scope")
+	}
+
+	userInst, err := userDB.Get(tokenEntry.Email)
+	if err != nil {
+		return nil, errors.New("User does not exists. " + err.Error())
+	}
+
+	if userInst.Status == dbs.AS_Blocked {
+		return nil, errors.New("User " + userInst.Email + " is blocked")
+	}
+
+	err = apiTokenDB.UpdateLastUsed(token)
+	if err != nil {
+		log.Println("Failed to update api token last used. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+// Authorizes request, and returns the test instances of the user's organisation, or of the user. Viewers can only read
+func AuthorizeWorkspace(r *http.Request, userDB *dbs.UserTestDB, sessionDB *dbs.SessionDB, apiTokenDB *dbs.ApiTokenDB, orgDB *dbs.OrgDB, scope dbs.ApiTokenScope) (*dbs.TestWorkspace, error) {
+	userInst, err := AuthorizeRequest(r, userDB, sessionDB, apiTokenDB, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := dbs.NewTestWorkspace(userInst, userDB, orgDB)
+	if err != nil {
+		return nil, errors.New("Failed to load workspace. " + err.Error())
+	}
+
+	if scope == dbs.ATS_Execute && !workspace.CanExecute() {
+		return nil, errors.New("User " + userInst.Email + " has " + string(workspace.Role) + " role")
+	}
+
+	return workspace, nil
+}
@@ -0,0 +1,167 @@
+package commonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestAuthorizeRequest_ApiTokenScopes(t *testing.T) {
+	db := kv.NewMemoryStore()
+
+	userDb := dbs.NewUserTestDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+	apiTokenDb := dbs.NewApiTokenDB(db)
+
+	for _, userInst := range []dbs.UserTestDBEntry{
+		{Email: "vendor@example.com", Status: dbs.AS_Validated},
+		{Email: "blocked@example.com", Status: dbs.AS_Blocked},
+	} {
+		err := userDb.Save(userInst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readToken, _, err := apiTokenDb.Create("vendor@example.com", "dashboard", dbs.ATS_Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executeToken, _, err := apiTokenDb.Create("vendor@example.com", "pipeline", dbs.ATS_Execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockedToken, _, err := apiTokenDb.Create("blocked@example.com", "pipeline", dbs.ATS_Execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionId, err := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "vendor@example.com", LoggedIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockedSessionId, err := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "blocked@example.com", LoggedIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		authz     string
+		cookie    string
+		scope     dbs.ApiTokenScope
+		expectErr bool
+	}{
+		{"read token reads", "Bearer " + readToken, "", dbs.ATS_Read, false},
+		{"read token can not execute", "Bearer " + readToken, "", dbs.ATS_Execute, true},
+		{"execute token reads", "Bearer " + executeToken, "", dbs.ATS_Read, false},
+		{"execute token executes", "Bearer " + executeToken, "", dbs.ATS_Execute, false},
+		{"unknown token", "Bearer " + dbs.API_TOKEN_PREFIX + "unknown", "", dbs.ATS_Read, true},
+		{"not bearer", "Basic " + readToken, "", dbs.ATS_Read, true},
+		{"blocked user", "Bearer " + blockedToken, "", dbs.ATS_Read, true},
+		{"session executes", "", string(sessionId), dbs.ATS_Execute, false},
+		{"blocked user session", "", string(blockedSessionId), dbs.ATS_Read, true},
+		{"bad token does not fall back to session", "Bearer bad", string(sessionId), dbs.ATS_Read, true},
+		{"no credentials", "", "", dbs.ATS_Read, true},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+		if testCase.authz != "" {
+			req.Header.Set("Authorization", testCase.authz)
+		}
+		if testCase.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: testCase.cookie})
+		}
+
+		userInst, err := AuthorizeRequest(req, userDb, sessionDb, apiTokenDb, testCase.scope)
+		if testCase.expectErr && err == nil {
+			t.Errorf("%s: expected error", testCase.name)
+		} else if !testCase.expectErr && err != nil {
+			t.Errorf("%s: unexpected error. %s", testCase.name, err.Error())
+		} else if !testCase.expectErr && userInst.Email != "vendor@example.com" {
+			t.Errorf("%s: unexpected user %s", testCase.name, userInst.Email)
+		}
+	}
+
+	tokenEntry, err := apiTokenDb.GetByToken(executeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokenEntry.LastUsed.IsZero() {
+		t.Error("Expected last used to be updated")
+	}
+}
+
+func TestAuthorizeRequest_DeletedApiTokenIsNotRestored(t *testing.T) {
+	db := kv.NewMemoryStore()
+
+	userDb := dbs.NewUserTestDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+	apiTokenDb := dbs.NewApiTokenDB(db)
+
+	err := userDb.Save(dbs.UserTestDBEntry{Email: "vendor@example.com", Status: dbs.AS_Validated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, tokenEntry, err := apiTokenDb.Create("vendor@example.com", "pipeline", dbs.ATS_Execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	_, err = AuthorizeRequest(req, userDb, sessionDb, apiTokenDb, dbs.ATS_Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usedEntry, err := apiTokenDb.GetByToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Last used is throttled, so the next request does not write it again
+	_, err = AuthorizeRequest(req, userDb, sessionDb, apiTokenDb, dbs.ATS_Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reusedEntry, err := apiTokenDb.GetByToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reusedEntry.LastUsed.Equal(usedEntry.LastUsed) {
+		t.Error("Expected last used not to be updated within the interval")
+	}
+
+	err = apiTokenDb.Delete("vendor@example.com", tokenEntry.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = apiTokenDb.UpdateLastUsed(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = apiTokenDb.GetByToken(token)
+	if err == nil {
+		t.Error("Expected deleted token to stay deleted")
+	}
+
+	_, err = AuthorizeRequest(req, userDb, sessionDb, apiTokenDb, dbs.ATS_Read)
+	if err == nil {
+		t.Error("Expected deleted token to be rejected")
+	}
+}
@@ -16,3 +16,9 @@ type User_ResetPassword struct {
 type User_ResetPasswordReq struct {
 	Email string `json:"email"`
 }
+
+// Response to a confirmed mail link. Redirect is the frontend page to open next
+type User_ConfirmResp struct {
+	Status   FdoConfApiStatus `json:"status"`
+	Redirect string           `json:"redirect"`
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+type DevicePoolApi struct {
+	DevBasePool *dbs.DeviceBasePool
+}
+
+// Seeded device bases per device sgType, and whether the pool is refilling
+func (h *DevicePoolApi) Status(w http.ResponseWriter, r *http.Request) {
+	commonapi.RespondSuccessStruct(w, h.DevBasePool.Status())
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,7 +26,7 @@ type IopApiResponse struct {
 
 type IopApi struct {
 	DOVouchersDB *dodbs.VoucherDB
-	Ctx          context.Context
+	Config       *fdoshared.Config
 }
 
 func (h *IopApi) submitVoucherToRvs(voucherdbe *fdoshared.VoucherDBEntry) ([]string, error) {
@@ -47,7 +46,7 @@ func (h *IopApi) submitVoucherToRvs(voucherdbe *fdoshared.VoucherDBEntry) ([]str
 
 	for rvEntryIndex, mappedRvInfo := range ownerMappedRvInfo {
 		for _, urlOption := range mappedRvInfo.GetOwnerUrls() {
-			to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{SrvURL: urlOption}, *voucherdbe, nil)
+			to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{SrvURL: urlOption}, *voucherdbe, h.Config)
 
 			helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
 			if err != nil {
@@ -138,6 +137,6 @@ type IopIsOipOnlyResponse struct {
 
 func (h *IopApi) IsOipOnly(w http.ResponseWriter, r *http.Request) {
 	commonapi.RespondSuccessStruct(w, IopIsOipOnlyResponse{
-		OipOnly: h.Ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool),
+		OipOnly: h.Config.Interop.Enabled(),
 	})
 }
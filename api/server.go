@@ -1,68 +1,90 @@
 package api
 
 import (
-	"context"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
 	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
 	"github.com/gorilla/mux"
 )
 
-func AddContext(next http.Handler, ctx context.Context) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-func SetupServer(db *badger.DB, ctx context.Context) {
+// mailSender is only used in online mode
+func SetupServer(db kv.Store, config *fdoshared.Config, mailSender mailer.Sender, devBasePool *dbs.DeviceBasePool) {
 	userDb := dbs.NewUserTestDB(db)
 	rvtDb := testdbs.NewRequestTestDB(db)
 	sessionDb := dbs.NewSessionDB(db)
+	verifyDb := dbs.NewVerifyDB(db)
+	apiTokenDb := dbs.NewApiTokenDB(db)
+	orgDb := dbs.NewOrgDB(db)
 	configDb := dbs.NewConfigDB(db)
 	devBaseDb := dbs.NewDeviceBaseDB(db)
 	listenerDb := testdbs.NewListenerTestDB(db)
 	doVoucherDb := dodbs.NewVoucherDB(db)
 
 	rvtApiHandler := testapi.RVTestMgmtAPI{
-		UserDB:    userDb,
-		ReqTDB:    rvtDb,
-		SessionDB: sessionDb,
-		ConfigDB:  configDb,
-		DevBaseDB: devBaseDb,
-		Ctx:       ctx,
+		UserDB:      userDb,
+		ReqTDB:      rvtDb,
+		SessionDB:   sessionDb,
+		ApiTokenDB:  apiTokenDb,
+		OrgDB:       orgDb,
+		DevBaseDB:   devBaseDb,
+		DevBasePool: devBasePool,
+		Config:      config,
 	}
 
 	dotApiHandler := testapi.DOTestMgmtAPI{
-		UserDB:    userDb,
-		ReqTDB:    rvtDb,
-		SessionDB: sessionDb,
-		ConfigDB:  configDb,
-		DevBaseDB: devBaseDb,
+		UserDB:      userDb,
+		ReqTDB:      rvtDb,
+		SessionDB:   sessionDb,
+		ApiTokenDB:  apiTokenDb,
+		OrgDB:       orgDb,
+		DevBaseDB:   devBaseDb,
+		DevBasePool: devBasePool,
+		Config:      config,
 	}
 
 	deviceApiHandler := testapi.DeviceTestMgmtAPI{
 		UserDB:       userDb,
 		ListenerDB:   listenerDb,
 		SessionDB:    sessionDb,
+		ApiTokenDB:   apiTokenDb,
+		OrgDB:        orgDb,
 		ConfigDB:     configDb,
 		DevBaseDB:    devBaseDb,
 		DOVouchersDB: doVoucherDb,
-		Ctx:          ctx,
+		Config:       config,
 	}
 
 	userApiHandler := UserAPI{
+		UserDB:     userDb,
+		SessionDB:  sessionDb,
+		VerifyDB:   verifyDb,
+		ApiTokenDB: apiTokenDb,
+		OrgDB:      orgDb,
+		Mailer:     mailSender,
+		Config:     config,
+	}
+
+	userVerifyHandler := UserVerify{
 		UserDB:    userDb,
+		VerifyDB:  verifyDb,
 		SessionDB: sessionDb,
+		Mailer:    mailSender,
+		Config:    config,
+	}
+
+	devicePoolApi := DevicePoolApi{
+		DevBasePool: devBasePool,
 	}
 
 	iopApi := IopApi{
 		DOVouchersDB: doVoucherDb,
-		Ctx:          ctx,
+		Config:       config,
 	}
 
 	r := mux.NewRouter()
@@ -71,12 +93,14 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 	r.HandleFunc("/api/rvt/testruns", rvtApiHandler.List)
 	r.HandleFunc("/api/rvt/testruns/{testinsthex}/{testrunid}", rvtApiHandler.DeleteTestRun).Methods("DELETE")
 	r.HandleFunc("/api/rvt/execute", rvtApiHandler.Execute)
+	r.HandleFunc("/api/rvt/testruns/{testinsthex}/cancel", rvtApiHandler.CancelTestRun).Methods("POST")
 
 	r.HandleFunc("/api/dot/create", dotApiHandler.Generate)
 	r.HandleFunc("/api/dot/testruns", dotApiHandler.List)
 	r.HandleFunc("/api/dot/testruns/{testinsthex}/{testrunid}", dotApiHandler.DeleteTestRun).Methods("DELETE")
 	r.HandleFunc("/api/dot/vouchers/{uuid}", dotApiHandler.GetVouchers)
 	r.HandleFunc("/api/dot/execute", dotApiHandler.Execute)
+	r.HandleFunc("/api/dot/testruns/{testinsthex}/cancel", dotApiHandler.CancelTestRun).Methods("POST")
 
 	r.HandleFunc("/api/device/create", deviceApiHandler.Generate)
 	r.HandleFunc("/api/device/testruns", deviceApiHandler.List)
@@ -86,16 +110,44 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 	r.HandleFunc("/api/iop/do/add", iopApi.IopAddVoucherToDO)
 	r.HandleFunc("/api/iop/is_iop_only", iopApi.IsOipOnly)
 
-	r.HandleFunc("/api/user/login/onprem", userApiHandler.OnPremNoLogin)
+	r.HandleFunc("/api/devicepool", devicePoolApi.Status).Methods("GET")
+
+	// Onprem login is single user without password, so it is not available in online mode
+	if config.Mode == fdoshared.CFG_MODE_ONLINE {
+		r.HandleFunc("/api/user/register", userApiHandler.Register)
+		r.HandleFunc("/api/user/login", userApiHandler.Login)
+		r.HandleFunc("/api/user/email/check/{id}", userVerifyHandler.EmailCheck).Methods("POST")
+		r.HandleFunc("/api/user/account/approve/{id}", userVerifyHandler.AccountApprove).Methods("POST")
+		r.HandleFunc("/api/user/account/reject/{id}", userVerifyHandler.AccountReject).Methods("POST")
+		r.HandleFunc("/api/user/password/reset/init", userApiHandler.PasswordResetInit)
+		r.HandleFunc("/api/user/password/reset/apply", userVerifyHandler.PasswordResetApply)
+		r.HandleFunc("/api/user/password/reset/{id}", userVerifyHandler.PasswordResetCheck).Methods("POST")
+	} else {
+		r.HandleFunc("/api/user/login/onprem", userApiHandler.OnPremNoLogin)
+	}
+
+	r.HandleFunc("/api/user/mode", userApiHandler.Mode)
+
+	r.HandleFunc("/api/user/tokens", userApiHandler.ApiTokenList).Methods("GET")
+	r.HandleFunc("/api/user/tokens", userApiHandler.ApiTokenCreate).Methods("POST")
+	r.HandleFunc("/api/user/tokens/{id}", userApiHandler.ApiTokenRevoke).Methods("DELETE")
+
+	r.HandleFunc("/api/user/org", userApiHandler.OrgGet).Methods("GET")
+	r.HandleFunc("/api/user/org", userApiHandler.OrgCreate).Methods("POST")
+	r.HandleFunc("/api/user/org/members", userApiHandler.OrgSetMember).Methods("POST")
+	r.HandleFunc("/api/user/org/members/{email}", userApiHandler.OrgRemoveMember).Methods("DELETE")
+	r.HandleFunc("/api/user/org/invites", userApiHandler.OrgListInvites).Methods("GET")
+	r.HandleFunc("/api/user/org/invites/{id}/accept", userApiHandler.OrgAcceptInvite).Methods("POST")
+	r.HandleFunc("/api/user/org/invites/{id}", userApiHandler.OrgDeclineInvite).Methods("DELETE")
 	r.HandleFunc("/api/user/loggedin", userApiHandler.UserLoggedIn)
 	r.HandleFunc("/api/user/logout", userApiHandler.Logout)
 	r.HandleFunc("/api/user/purgetests", userApiHandler.PurgeTests)
 
-	if ctx.Value(fdoshared.CFG_DEV_ENV) == fdoshared.CFG_ENV_DEV {
+	if config.Env == fdoshared.CFG_ENV_DEV {
 		r.PathPrefix("/").HandlerFunc(ProxyDevUI)
 	} else {
 		r.PathPrefix("/").Handler(http.FileServer(http.Dir("./frontend/")))
 	}
 
-	http.Handle("/", AddContext(r, ctx))
+	http.Handle("/", r)
 }
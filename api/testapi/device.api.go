@@ -1,10 +1,8 @@
 package testapi
 
 import (
-	"context"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -29,15 +27,17 @@ type DeviceTestMgmtAPI struct {
 	ListenerDB   *testcomdbs.ListenerTestDB
 	DevBaseDB    *dbs.DeviceBaseDB
 	SessionDB    *dbs.SessionDB
+	ApiTokenDB   *dbs.ApiTokenDB
+	OrgDB        *dbs.OrgDB
 	ConfigDB     *dbs.ConfigDB
 	DOVouchersDB *dodbs.VoucherDB
-	Ctx          context.Context
+	Config       *fdoshared.Config
 }
 
 func (h *DeviceTestMgmtAPI) submitToRvOwnerSign(voucherdbe *fdoshared.VoucherDBEntry) error {
 	to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{
-		SrvURL: h.Ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string),
-	}, *voucherdbe, h.Ctx)
+		SrvURL: h.Config.FdoServiceUrl,
+	}, *voucherdbe, h.Config)
 
 	helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
 	if err != nil {
@@ -56,32 +56,8 @@ func (h *DeviceTestMgmtAPI) submitVoucherToDO(voucherDBEntry *fdoshared.VoucherD
 	return h.DOVouchersDB.Save(*voucherDBEntry)
 }
 
-func (h *DeviceTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
-	sessionCookie, err := r.Cookie("session")
-	if err != nil {
-		return nil, errors.New("failed to read cookie. " + err.Error())
-
-	}
-
-	if sessionCookie == nil {
-		return nil, errors.New("cookie does not exists")
-	}
-
-	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
-	if err != nil {
-		return nil, errors.New("session expired. " + err.Error())
-	}
-
-	if !sessionInst.LoggedIn {
-		return nil, errors.New("unauthorized")
-	}
-
-	userInst, err := h.UserDB.Get(sessionInst.Email)
-	if err != nil {
-		return nil, errors.New("user does not exists. " + err.Error())
-	}
-
-	return userInst, nil
+func (h *DeviceTestMgmtAPI) checkAutzAndGetUser(r *http.Request, scope dbs.ApiTokenScope) (*dbs.TestWorkspace, error) {
+	return commonapi.AuthorizeWorkspace(r, h.UserDB, h.SessionDB, h.ApiTokenDB, h.OrgDB, scope)
 }
 
 func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
@@ -89,9 +65,9 @@ func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -148,11 +124,9 @@ func (h *DeviceTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst.DeviceTestInsts = append(userInst.DeviceTestInsts, dbs.NewDeviceTestInst(createTestCase.Name, deviceListenerInsts.Uuid, ovHeader.OVGuid))
-
-	err = h.UserDB.Save(*userInst)
+	err = workspace.AddDeviceTestInst(dbs.NewDeviceTestInst(createTestCase.Name, deviceListenerInsts.Uuid, ovHeader.OVGuid))
 	if err != nil {
-		log.Println("Failed to save user. " + err.Error())
+		log.Println("Failed to save workspace. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -166,9 +140,9 @@ func (h *DeviceTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Read)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -177,7 +151,7 @@ func (h *DeviceTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		DeviceItems: []Device_Item{},
 	}
 
-	for _, devInsts := range userInst.DeviceTestInsts {
+	for _, devInsts := range workspace.DeviceTestInsts() {
 		reqListener, err := h.ListenerDB.Get(devInsts.ListenerUuid)
 		if err != nil {
 			log.Printf("Failed find entry for %s. %s", hex.EncodeToString(devInsts.Uuid), err.Error())
@@ -218,9 +192,9 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -241,7 +215,7 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if !userInst.DeviceT_ContainID(testInstIdBytes) {
+	if !workspace.DeviceT_ContainID(testInstIdBytes) {
 		commonapi.RespondError(w, "Invalid test id!", http.StatusBadRequest)
 		return
 	}
@@ -264,7 +238,17 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	runnerInst.StartNewTestRun()
+	// Optional seed, to replay previous test run
+	var seed int64 = fdoshared.NewConf_Seed()
+	if seedStr := r.URL.Query().Get("seed"); seedStr != "" {
+		seed, err = strconv.ParseInt(seedStr, 10, 64)
+		if err != nil {
+			commonapi.RespondError(w, "Failed to decode seed!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	runnerInst.StartNewTestRun(seed)
 
 	err = h.ListenerDB.Update(reqListInst)
 	if err != nil {
@@ -281,9 +265,9 @@ func (h *DeviceTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -306,7 +290,7 @@ func (h *DeviceTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !userInst.DeviceT_ContainID(testIstIdBytes) {
+	if !workspace.DeviceT_ContainID(testIstIdBytes) {
 		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
 		return
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
@@ -25,39 +26,18 @@ import (
 const DOSeedIDsBatchSize int = 20
 
 type DOTestMgmtAPI struct {
-	UserDB    *dbs.UserTestDB
-	ReqTDB    *testdbs.RequestTestDB
-	DevBaseDB *dbs.DeviceBaseDB
-	SessionDB *dbs.SessionDB
-	ConfigDB  *dbs.ConfigDB
+	UserDB      *dbs.UserTestDB
+	ReqTDB      *testdbs.RequestTestDB
+	DevBaseDB   *dbs.DeviceBaseDB
+	SessionDB   *dbs.SessionDB
+	ApiTokenDB  *dbs.ApiTokenDB
+	OrgDB       *dbs.OrgDB
+	DevBasePool *dbs.DeviceBasePool
+	Config      *fdoshared.Config
 }
 
-func (h *DOTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
-	sessionCookie, err := r.Cookie("session")
-	if err != nil {
-		return nil, errors.New("Failed to read cookie. " + err.Error())
-
-	}
-
-	if sessionCookie == nil {
-		return nil, errors.New("cookie does not exists")
-	}
-
-	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
-	if err != nil {
-		return nil, errors.New("session expired. " + err.Error())
-	}
-
-	if !sessionInst.LoggedIn {
-		return nil, errors.New("unauthorized!")
-	}
-
-	userInst, err := h.UserDB.Get(sessionInst.Email)
-	if err != nil {
-		return nil, errors.New("user does not exists. " + err.Error())
-	}
-
-	return userInst, nil
+func (h *DOTestMgmtAPI) checkAutzAndGetUser(r *http.Request, scope dbs.ApiTokenScope) (*dbs.TestWorkspace, error) {
+	return commonapi.AuthorizeWorkspace(r, h.UserDB, h.SessionDB, h.ApiTokenDB, h.OrgDB, scope)
 }
 
 func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
@@ -65,9 +45,9 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -103,8 +83,16 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	doUrl := parsedUrl.Scheme + "://" + parsedUrl.Host
 
-	// Getting pre-gen config
-	mainConfig, err := h.ConfigDB.Get()
+	selection := createTestCase.GetSelection()
+	err = selection.Validate(testcom.GetRequestTestIDs(fdoshared.To2))
+	if err != nil {
+		log.Println("Bad test selection. " + err.Error())
+		commonapi.RespondError(w, "Bad test selection! "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Getting seeded device bases. Every device sgType gets its share of the voucher guids
+	seededGuids, err := h.DevBasePool.GetSeededGuids(testexec.To2VoucherGuidsNeeded()/len(fdoshared.DeviceSgTypeList) + 1)
 	if err != nil {
 		log.Println("Failed to generate VDIs. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
@@ -113,16 +101,17 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	// New request test instance
 	newDOTTestTo2 := reqtestsdeps.NewRequestTestInst(doUrl, 2)
+	rnd := fdoshared.NewConf_Rand(newDOTTestTo2.Seed)
 
 	// Generate test vouchers
-	voucherTestBatch := mainConfig.SeededGuids.GetTestBatch(10000)
+	voucherTestBatch := seededGuids.GetTestBatch(rnd, 10000)
 
 	var allTestIds fdoshared.FdoGuidList
 	for _, v := range voucherTestBatch {
 		allTestIds = append(allTestIds, v...)
 	}
 
-	voucherTestMap, err := testexec.GenerateTo2Vouchers(allTestIds, h.DevBaseDB)
+	voucherTestMap, err := testexec.GenerateTo2Vouchers(allTestIds, h.DevBaseDB, h.Config, newDOTTestTo2.Seed, selection)
 	if err != nil {
 		log.Println("Generate vouchers. " + err.Error())
 		commonapi.RespondError(w, "Failed to generate vouchers. Internal server error", http.StatusInternalServerError)
@@ -130,7 +119,7 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newDOTTestTo2.TestVouchers = voucherTestMap
-	newDOTTestTo2.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(DOSeedIDsBatchSize)
+	newDOTTestTo2.FdoSeedIDs = seededGuids.GetTestBatch(rnd, DOSeedIDsBatchSize)
 
 	// Saving stuff
 	err = h.ReqTDB.Save(newDOTTestTo2)
@@ -140,11 +129,10 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Saving user
-	userInst.DOTestInsts = append(userInst.DOTestInsts, dbs.NewDOTestInst(doUrl, newDOTTestTo2.Uuid))
-	err = h.UserDB.Save(*userInst)
+	// Saving workspace
+	err = workspace.AddDOTestInst(dbs.NewDOTestInst(doUrl, newDOTTestTo2.Uuid))
 	if err != nil {
-		log.Println("Failed to save user. " + err.Error())
+		log.Println("Failed to save workspace. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -158,9 +146,9 @@ func (h *DOTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Read)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -169,7 +157,7 @@ func (h *DOTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		TestEntries: []DOT_Item{},
 	}
 
-	for _, dotInfo := range userInst.DOTestInsts {
+	for _, dotInfo := range workspace.DOTestInsts() {
 		var dotItem DOT_Item = DOT_Item{
 			Id:  hex.EncodeToString(dotInfo.Uuid),
 			Url: dotInfo.Url,
@@ -200,15 +188,16 @@ func (h *DOTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 	commonapi.RespondSuccessStruct(w, dotList)
 }
 
+// Voucher files contain owner private keys, so they need execute scope
 func (h *DOTestMgmtAPI) GetVouchers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -221,7 +210,7 @@ func (h *DOTestMgmtAPI) GetVouchers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !userInst.DOT_ContainID(idBytes) {
+	if !workspace.DOT_ContainID(idBytes) {
 		log.Printf("ID %s does not belong to user", vars["uuid"])
 		commonapi.RespondError(w, "ID not found!", http.StatusNotFound)
 		return
@@ -292,9 +281,9 @@ func (h *DOTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -310,8 +299,8 @@ func (h *DOTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !userInst.DOT_ContainID(dotId) {
-		log.Println("Id does not belong to user")
+	if !workspace.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to workspace")
 		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
 		return
 	}
@@ -326,9 +315,9 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -355,8 +344,8 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !userInst.DOT_ContainID(dotId) {
-		log.Println("Id does not belong to user")
+	if !workspace.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to workspace")
 		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
 		return
 	}
@@ -368,7 +357,57 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB)
+	err = testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB, h.Config, execReq.GetSeed(), execReq.GetSelection())
+	if errors.Is(err, testexec.ErrRunInProgress) {
+		commonapi.RespondError(w, "Test run is already in progress!", http.StatusConflict)
+		return
+	} else if errors.Is(err, testexec.ErrBadSelection) {
+		commonapi.RespondError(w, "Bad test selection! "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println("Failed to execute DOT. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+func (h *DOTestMgmtAPI) CancelTestRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
+	if err != nil {
+		log.Println("Failed to authorize request. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	testinsthex := vars["testinsthex"]
+
+	dotId, err := hex.DecodeString(testinsthex)
+	if err != nil {
+		log.Println("Can not decode hex dotid " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !workspace.DOT_ContainID(dotId) {
+		log.Println("Id does not belong to workspace")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = testexec.CancelRun(h.ReqTDB, dotId)
+	if err != nil {
+		log.Println("Failed to cancel test run. " + err.Error())
+		commonapi.RespondError(w, "Test run is not in progress!", http.StatusBadRequest)
+		return
+	}
 
 	commonapi.RespondSuccess(w)
 }
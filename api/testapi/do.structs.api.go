@@ -3,11 +3,19 @@ package testapi
 import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
 type DOT_CreateTestCase struct {
-	Url string `json:"url"`
+	Url     string   `json:"url"`
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Voucher tests that are not selected are not generated, and fail if executed later
+func (h *DOT_CreateTestCase) GetSelection() testcom.FDOTestSelection {
+	return testcom.NewFDOTestSelection(h.Include, h.Exclude)
 }
 
 type DOT_InstInfo struct {
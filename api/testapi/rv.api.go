@@ -1,7 +1,6 @@
 package testapi
 
 import (
-	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -22,40 +21,18 @@ import (
 const RVSeedIDsBatchSize int = 20
 
 type RVTestMgmtAPI struct {
-	UserDB    *dbs.UserTestDB
-	ReqTDB    *testdbs.RequestTestDB
-	DevBaseDB *dbs.DeviceBaseDB
-	SessionDB *dbs.SessionDB
-	ConfigDB  *dbs.ConfigDB
-	Ctx       context.Context
+	UserDB      *dbs.UserTestDB
+	ReqTDB      *testdbs.RequestTestDB
+	DevBaseDB   *dbs.DeviceBaseDB
+	SessionDB   *dbs.SessionDB
+	ApiTokenDB  *dbs.ApiTokenDB
+	OrgDB       *dbs.OrgDB
+	DevBasePool *dbs.DeviceBasePool
+	Config      *fdoshared.Config
 }
 
-func (h *RVTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
-	sessionCookie, err := r.Cookie("session")
-	if err != nil {
-		return nil, errors.New("Failed to read cookie. " + err.Error())
-
-	}
-
-	if sessionCookie == nil {
-		return nil, errors.New("Cookie does not exists")
-	}
-
-	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
-	if err != nil {
-		return nil, errors.New("Session expired. " + err.Error())
-	}
-
-	if !sessionInst.LoggedIn {
-		return nil, errors.New("Unauthorized!")
-	}
-
-	userInst, err := h.UserDB.Get(sessionInst.Email)
-	if err != nil {
-		return nil, errors.New("User does not exists. " + err.Error())
-	}
-
-	return userInst, nil
+func (h *RVTestMgmtAPI) checkAutzAndGetUser(r *http.Request, scope dbs.ApiTokenScope) (*dbs.TestWorkspace, error) {
+	return commonapi.AuthorizeWorkspace(r, h.UserDB, h.SessionDB, h.ApiTokenDB, h.OrgDB, scope)
 }
 
 func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
@@ -63,9 +40,9 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -100,7 +77,7 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	rvUrl := parsedUrl.Scheme + "://" + parsedUrl.Host
 
-	mainConfig, err := h.ConfigDB.Get()
+	seededGuids, err := h.DevBasePool.GetSeededGuids(RVSeedIDsBatchSize)
 	if err != nil {
 		log.Println("Failed to generate VDIs. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
@@ -108,7 +85,7 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newRVTestTo0 := reqtestsdeps.NewRequestTestInst(rvUrl, 0)
-	newRVTestTo0.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(RVSeedIDsBatchSize)
+	newRVTestTo0.FdoSeedIDs = seededGuids.GetTestBatch(fdoshared.NewConf_Rand(newRVTestTo0.Seed), RVSeedIDsBatchSize)
 	err = h.ReqTDB.Save(newRVTestTo0)
 	if err != nil {
 		log.Println("Failed to save rvte. " + err.Error())
@@ -117,7 +94,7 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newRVTestTo1 := reqtestsdeps.NewRequestTestInst(rvUrl, 1)
-	newRVTestTo1.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(RVSeedIDsBatchSize)
+	newRVTestTo1.FdoSeedIDs = seededGuids.GetTestBatch(fdoshared.NewConf_Rand(newRVTestTo1.Seed), RVSeedIDsBatchSize)
 	err = h.ReqTDB.Save(newRVTestTo1)
 	if err != nil {
 		log.Println("Failed to save rvte. " + err.Error())
@@ -125,11 +102,9 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst.RVTestInsts = append(userInst.RVTestInsts, dbs.NewRVTestInst(rvUrl, newRVTestTo0.Uuid, newRVTestTo1.Uuid))
-
-	err = h.UserDB.Save(*userInst)
+	err = workspace.AddRVTestInst(dbs.NewRVTestInst(rvUrl, newRVTestTo0.Uuid, newRVTestTo1.Uuid))
 	if err != nil {
-		log.Println("Failed to save user. " + err.Error())
+		log.Println("Failed to save workspace. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -143,9 +118,9 @@ func (h *RVTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Read)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -154,7 +129,7 @@ func (h *RVTestMgmtAPI) List(w http.ResponseWriter, r *http.Request) {
 		RVTItems: []RVT_Item{},
 	}
 
-	for _, rvtInfo := range userInst.RVTestInsts {
+	for _, rvtInfo := range workspace.RVTestInsts() {
 		var rvtItem RVT_Item = RVT_Item{
 			Id:  hex.EncodeToString(rvtInfo.Uuid),
 			Url: rvtInfo.Url,
@@ -198,9 +173,9 @@ func (h *RVTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -216,8 +191,8 @@ func (h *RVTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !userInst.RVT_ContainID(rvtId) {
-		log.Println("Id does not belong to user")
+	if !workspace.RVT_ContainID(rvtId) {
+		log.Println("Id does not belong to workspace")
 		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
 		return
 	}
@@ -232,9 +207,9 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst, err := h.checkAutzAndGetUser(r)
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
 	if err != nil {
-		log.Println("Failed to read cookie. " + err.Error())
+		log.Println("Failed to authorize request. " + err.Error())
 		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -261,8 +236,8 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !userInst.RVT_ContainID(rvtId) {
-		log.Println("Id does not belong to user")
+	if !workspace.RVT_ContainID(rvtId) {
+		log.Println("Id does not belong to workspace")
 		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
 		return
 	}
@@ -275,14 +250,65 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if rvte.Protocol == fdoshared.To0 {
-		testexec.ExecuteRVTestsTo0(*rvte, h.ReqTDB, h.DevBaseDB, h.Ctx)
+		err = testexec.ExecuteRVTestsTo0(*rvte, h.ReqTDB, h.DevBaseDB, h.Config, execReq.GetSeed(), execReq.GetSelection())
 	} else if rvte.Protocol == fdoshared.To1 {
-		testexec.ExecuteRVTestsTo1(*rvte, h.ReqTDB, h.DevBaseDB, h.Ctx)
+		err = testexec.ExecuteRVTestsTo1(*rvte, h.ReqTDB, h.DevBaseDB, h.Config, execReq.GetSeed(), execReq.GetSelection())
 	} else {
 		log.Printf("Protocol TO%d is not supported. ", rvte.Protocol)
 		commonapi.RespondError(w, "Unsupported protocol!", http.StatusBadRequest)
 		return
 	}
 
+	if errors.Is(err, testexec.ErrRunInProgress) {
+		commonapi.RespondError(w, "Test run is already in progress!", http.StatusConflict)
+		return
+	} else if errors.Is(err, testexec.ErrBadSelection) {
+		commonapi.RespondError(w, "Bad test selection! "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println("Failed to execute RVT. " + err.Error())
+		commonapi.RespondError(w, "Internal server error!", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+func (h *RVTestMgmtAPI) CancelTestRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspace, err := h.checkAutzAndGetUser(r, dbs.ATS_Execute)
+	if err != nil {
+		log.Println("Failed to authorize request. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	testinsthex := vars["testinsthex"]
+
+	rvtId, err := hex.DecodeString(testinsthex)
+	if err != nil {
+		log.Println("Can not decode hex rvtid " + err.Error())
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	if !workspace.RVT_ContainID(rvtId) {
+		log.Println("Id does not belong to workspace")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = testexec.CancelRun(h.ReqTDB, rvtId)
+	if err != nil {
+		log.Println("Failed to cancel test run. " + err.Error())
+		commonapi.RespondError(w, "Test run is not in progress!", http.StatusBadRequest)
+		return
+	}
+
 	commonapi.RespondSuccess(w)
 }
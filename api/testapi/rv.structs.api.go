@@ -3,6 +3,7 @@ package testapi
 import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
@@ -43,6 +44,23 @@ type RVT_ListRvts struct {
 }
 
 type RVT_RequestInfo struct {
-	Id        string `json:"id"`
-	TestRunId string `json:"testRunId,omitempty"`
+	Id        string   `json:"id"`
+	TestRunId string   `json:"testRunId,omitempty"`
+	Seed      *int64   `json:"seed,omitempty"`
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+}
+
+// Returns requested seed to replay a test run, or a new random seed
+func (h *RVT_RequestInfo) GetSeed() int64 {
+	if h.Seed != nil {
+		return *h.Seed
+	}
+
+	return fdoshared.NewConf_Seed()
+}
+
+// Returns requested subset of the tests to execute. Empty selection executes all tests
+func (h *RVT_RequestInfo) GetSelection() testcom.FDOTestSelection {
+	return testcom.NewFDOTestSelection(h.Include, h.Exclude)
 }
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -37,3 +40,207 @@ func (h *UserAPI) OnPremNoLogin(w http.ResponseWriter, r *http.Request) {
 
 	commonapi.RespondSuccess(w)
 }
+
+func (h *UserAPI) Register(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var registerReq commonapi.User_UserReq
+	err = json.Unmarshal(bodyBytes, &registerReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	registerReq.Email = normalizeEmail(registerReq.Email)
+	if !isEmailValid(registerReq.Email) {
+		commonapi.RespondError(w, "Invalid email!", http.StatusBadRequest)
+		return
+	}
+
+	if len(registerReq.Password) < MIN_PASSWORD_LENGTH {
+		commonapi.RespondError(w, "Password is too short!", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(registerReq.Name) == "" || strings.TrimSpace(registerReq.Company) == "" {
+		commonapi.RespondError(w, "Missing name or company!", http.StatusBadRequest)
+		return
+	}
+
+	// Existing accounts are never overwritten. Repeated unverified registration only resends the verification email
+	existingUser, err := h.UserDB.Get(registerReq.Email)
+	if err == nil && existingUser.EmailVerified {
+		commonapi.RespondError(w, "User already exists!", http.StatusBadRequest)
+		return
+	} else if err == nil {
+		err = h.sendVerifyEmail(existingUser.Email)
+		if err != nil {
+			log.Println(err.Error())
+			commonapi.RespondError(w, "Failed to send verification email.", http.StatusInternalServerError)
+			return
+		}
+
+		commonapi.RespondSuccess(w)
+		return
+	}
+
+	passwordHash, err := generatePasswordHash(registerReq.Password)
+	if err != nil {
+		log.Println("Error hashing password. " + err.Error())
+		commonapi.RespondError(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.UserDB.Save(dbs.UserTestDBEntry{
+		Email:        registerReq.Email,
+		PasswordHash: passwordHash,
+		Name:         strings.TrimSpace(registerReq.Name),
+		Company:      strings.TrimSpace(registerReq.Company),
+		Status:       dbs.AS_Awaiting,
+	})
+	if err != nil {
+		log.Println("Error saving user. " + err.Error())
+		commonapi.RespondError(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.sendVerifyEmail(registerReq.Email)
+	if err != nil {
+		log.Println(err.Error())
+		commonapi.RespondError(w, "Failed to send verification email.", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+func (h *UserAPI) sendVerifyEmail(email string) error {
+	verifyId, err := h.VerifyDB.SaveEntry(dbs.VerifyEntry{Email: email, Type: dbs.VT_Email})
+	if err != nil {
+		return errors.New("Error saving verify entry. " + err.Error())
+	}
+
+	err = h.Mailer.Send(newVerifyEmailMessage(email, h.Config.FdoServiceUrl+LINK_CONFIRM_EMAIL+string(verifyId)))
+	if err != nil {
+		return errors.New("Error sending verification email. " + err.Error())
+	}
+
+	return nil
+}
+
+func (h *UserAPI) Login(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var loginReq commonapi.User_UserReq
+	err = json.Unmarshal(bodyBytes, &loginReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(normalizeEmail(loginReq.Email))
+	if err != nil {
+		commonapi.RespondError(w, "Invalid email or password!", http.StatusUnauthorized)
+		return
+	}
+
+	passwordOk, err := verifyPasswordHash(loginReq.Password, userInst.PasswordHash)
+	if err != nil {
+		log.Println("Error verifying password. " + err.Error())
+		commonapi.RespondError(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	if !passwordOk {
+		commonapi.RespondError(w, "Invalid email or password!", http.StatusUnauthorized)
+		return
+	}
+
+	if !userInst.EmailVerified {
+		commonapi.RespondError(w, "Email is not verified. Please check your mail box.", http.StatusForbidden)
+		return
+	}
+
+	switch userInst.Status {
+	case dbs.AS_Validated:
+	case dbs.AS_Blocked:
+		commonapi.RespondError(w, "Account is blocked.", http.StatusForbidden)
+		return
+	default:
+		commonapi.RespondError(w, "Account is awaiting approval.", http.StatusForbidden)
+		return
+	}
+
+	err = h.setUserSession(w, dbs.SessionEntry{Email: userInst.Email, LoggedIn: true})
+	if err != nil {
+		log.Println("Error creating session. " + err.Error())
+		commonapi.RespondError(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+// Always responds success, so it can not be used to find registered emails
+func (h *UserAPI) PasswordResetInit(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var resetReq commonapi.User_ResetPasswordReq
+	err = json.Unmarshal(bodyBytes, &resetReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(normalizeEmail(resetReq.Email))
+	if err != nil || !userInst.EmailVerified {
+		commonapi.RespondSuccess(w)
+		return
+	}
+
+	verifyId, err := h.VerifyDB.SaveEntry(dbs.VerifyEntry{Email: userInst.Email, Type: dbs.VT_PasswordReset})
+	if err != nil {
+		log.Println("Error saving verify entry. " + err.Error())
+		commonapi.RespondError(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.Mailer.Send(newPasswordResetMessage(userInst.Email, h.Config.FdoServiceUrl+LINK_CONFIRM_PASSWORD_RESET+string(verifyId)))
+	if err != nil {
+		log.Println("Error sending password reset email. " + err.Error())
+		commonapi.RespondError(w, "Failed to send password reset email.", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
+	"github.com/gorilla/mux"
+)
+
+const test_serviceUrl string = "http://tools.example.com"
+
+type test_onlineApi struct {
+	userApi    UserAPI
+	userVerify UserVerify
+	dropDir    string
+}
+
+func test_newOnlineApi(t *testing.T, adminEmail string) *test_onlineApi {
+	db := kv.NewMemoryStore()
+
+	config := fdoshared.DefaultConfig()
+	config.FdoServiceUrl = test_serviceUrl
+	config.Mode = fdoshared.CFG_MODE_ONLINE
+	config.AdminEmail = adminEmail
+
+	dropDir := t.TempDir()
+	sender := mailer.FileDropSender{Dir: dropDir, From: "tools@example.com"}
+
+	return &test_onlineApi{
+		userApi: UserAPI{
+			UserDB:     dbs.NewUserTestDB(db),
+			SessionDB:  dbs.NewSessionDB(db),
+			VerifyDB:   dbs.NewVerifyDB(db),
+			ApiTokenDB: dbs.NewApiTokenDB(db),
+			OrgDB:      dbs.NewOrgDB(db),
+			Mailer:     sender,
+			Config:     &config,
+		},
+		userVerify: UserVerify{
+			UserDB:    dbs.NewUserTestDB(db),
+			VerifyDB:  dbs.NewVerifyDB(db),
+			SessionDB: dbs.NewSessionDB(db),
+			Mailer:    sender,
+			Config:    &config,
+		},
+		dropDir: dropDir,
+	}
+}
+
+func test_postJson(handler http.HandlerFunc, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+// Follows the link from the last email sent to the recipient
+func (h *test_onlineApi) followLink(t *testing.T, to string, linkPrefix string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	files, _ := filepath.Glob(filepath.Join(h.dropDir, "*.eml"))
+	sort.Strings(files)
+
+	for i := len(files) - 1; i >= 0; i-- {
+		emlBytes, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(emlBytes), "To: "+to+"\r\n") {
+			continue
+		}
+
+		link := regexp.MustCompile(regexp.QuoteMeta(test_serviceUrl+linkPrefix) + `([a-f0-9\-]+)`).FindStringSubmatch(string(emlBytes))
+		if link == nil {
+			t.Fatalf("Last email to %s has no %s link", to, linkPrefix)
+		}
+
+		// Links are confirmed by the frontend with POST
+		req := mux.SetURLVars(httptest.NewRequest("POST", "/", bytes.NewBufferString("{}")), map[string]string{"id": link[1]})
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+
+	t.Fatalf("No email to %s", to)
+	return nil
+}
+
+func test_expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, expectedStatus int) {
+	t.Helper()
+	if recorder.Code != expectedStatus {
+		t.Fatalf("Expected status %d, got %d: %s", expectedStatus, recorder.Code, recorder.Body.String())
+	}
+}
+
+func test_confirmRedirect(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	var confirmResp commonapi.User_ConfirmResp
+	err := json.Unmarshal(recorder.Body.Bytes(), &confirmResp)
+	if err != nil {
+		t.Fatalf("Failed to decode confirm response: %v", err)
+	}
+
+	return confirmResp.Redirect
+}
+
+func test_sessionCookie(t *testing.T, recorder *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "session" && cookie.Value != "" {
+			return cookie
+		}
+	}
+
+	t.Fatal("Expected session cookie")
+	return nil
+}
+
+func TestOnlineMode_RegisterVerifyLogin(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+	registerReq := `{"email": "Vendor@Example.com", "password": "secret-password", "name": "Vendor", "company": "Example"}`
+	loginReq := `{"email": "vendor@example.com", "password": "secret-password"}`
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": "vendor@example.com", "password": "short", "name": "Vendor", "company": "Example"}`), http.StatusBadRequest)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, registerReq), http.StatusOK)
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, loginReq), http.StatusForbidden)
+
+	// Repeated registration resends the verification email, but keeps the registered password
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": " VENDOR@example.com", "password": "other-password", "name": "Other", "company": "Other"}`), http.StatusOK)
+
+	if redirect := test_confirmRedirect(t, onlineApi.followLink(t, "vendor@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck)); redirect != "/" {
+		t.Fatalf("Expected redirect home, got %s", redirect)
+	}
+
+	// Links are single use
+	test_expectStatus(t, onlineApi.followLink(t, "vendor@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck), http.StatusBadRequest)
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, registerReq), http.StatusBadRequest)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "vendor@example.com", "password": "other-password"}`), http.StatusUnauthorized)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "vendor@example.com", "password": "wrong-password"}`), http.StatusUnauthorized)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "other@example.com", "password": "secret-password"}`), http.StatusUnauthorized)
+
+	userInst, err := onlineApi.userApi.UserDB.Get("vendor@example.com")
+	if err != nil || userInst.Name != "Vendor" || userInst.Company != "Example" {
+		t.Fatalf("Expected registered user to be kept, got %+v", userInst)
+	}
+
+	recorder := test_postJson(onlineApi.userApi.Login, `{"email": " Vendor@Example.com ", "password": "secret-password"}`)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	recorder = test_postJson(onlineApi.userApi.Login, loginReq)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(test_sessionCookie(t, recorder))
+	isLoggedIn, _, userInst := onlineApi.userApi.isLoggedIn(req)
+	if !isLoggedIn || userInst == nil || userInst.Email != "vendor@example.com" {
+		t.Fatal("Expected vendor@example.com to be logged in")
+	}
+}
+
+func TestOnlineMode_AdminApproval(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "admin@example.com")
+	loginReq := `{"email": "first@example.com", "password": "secret-password"}`
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": "first@example.com", "password": "secret-password", "name": "First", "company": "Example"}`), http.StatusOK)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": "second@example.com", "password": "secret-password", "name": "Second", "company": "Example"}`), http.StatusOK)
+
+	if redirect := test_confirmRedirect(t, onlineApi.followLink(t, "first@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck)); redirect != "/#/error/notverified" {
+		t.Fatalf("Expected awaiting verification redirect, got %s", redirect)
+	}
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, loginReq), http.StatusForbidden)
+
+	test_expectStatus(t, onlineApi.followLink(t, "admin@example.com", LINK_CONFIRM_ACCOUNT_APPROVE, onlineApi.userVerify.AccountApprove), http.StatusOK)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, loginReq), http.StatusOK)
+
+	onlineApi.followLink(t, "second@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck)
+	test_expectStatus(t, onlineApi.followLink(t, "admin@example.com", LINK_CONFIRM_ACCOUNT_REJECT, onlineApi.userVerify.AccountReject), http.StatusOK)
+
+	recorder := test_postJson(onlineApi.userApi.Login, `{"email": "second@example.com", "password": "secret-password"}`)
+	test_expectStatus(t, recorder, http.StatusForbidden)
+	if !strings.Contains(recorder.Body.String(), "blocked") {
+		t.Fatalf("Expected blocked account, got %s", recorder.Body.String())
+	}
+}
+
+func TestOnlineMode_PasswordReset(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": "vendor@example.com", "password": "old-password", "name": "Vendor", "company": "Example"}`), http.StatusOK)
+	onlineApi.followLink(t, "vendor@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck)
+
+	loginRecorder := test_postJson(onlineApi.userApi.Login, `{"email": "vendor@example.com", "password": "old-password"}`)
+	test_expectStatus(t, loginRecorder, http.StatusOK)
+	loginCookie := test_sessionCookie(t, loginRecorder)
+
+	// Unknown emails are not revealed
+	test_expectStatus(t, test_postJson(onlineApi.userApi.PasswordResetInit, `{"email": "unknown@example.com"}`), http.StatusOK)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.PasswordResetInit, `{"email": " Vendor@Example.com"}`), http.StatusOK)
+
+	recorder := onlineApi.followLink(t, "vendor@example.com", LINK_CONFIRM_PASSWORD_RESET, onlineApi.userVerify.PasswordResetCheck)
+	if redirect := test_confirmRedirect(t, recorder); redirect != "/#/resetpassword/apply" {
+		t.Fatalf("Expected reset password redirect, got %s", redirect)
+	}
+	resetCookie := test_sessionCookie(t, recorder)
+
+	test_expectStatus(t, test_postJson(onlineApi.userVerify.PasswordResetApply, `{"password": "new-password", "confirm_password": "other-password"}`, resetCookie), http.StatusBadRequest)
+	test_expectStatus(t, test_postJson(onlineApi.userVerify.PasswordResetApply, `{"password": "new-password", "confirm_password": "new-password"}`), http.StatusUnauthorized)
+	test_expectStatus(t, test_postJson(onlineApi.userVerify.PasswordResetApply, `{"password": "new-password", "confirm_password": "new-password"}`, resetCookie), http.StatusOK)
+
+	// Reset session is closed after use
+	test_expectStatus(t, test_postJson(onlineApi.userVerify.PasswordResetApply, `{"password": "other-password", "confirm_password": "other-password"}`, resetCookie), http.StatusUnauthorized)
+
+	// Other sessions are logged out
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(loginCookie)
+	if isLoggedIn, _, _ := onlineApi.userApi.isLoggedIn(req); isLoggedIn {
+		t.Fatal("Expected password reset to log out other sessions")
+	}
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "vendor@example.com", "password": "old-password"}`), http.StatusUnauthorized)
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "vendor@example.com", "password": "new-password"}`), http.StatusOK)
+}
+
+func TestOnlineMode_LinksNeedPost(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Register, `{"email": "vendor@example.com", "password": "secret-password", "name": "Vendor", "company": "Example"}`), http.StatusOK)
+
+	files, _ := filepath.Glob(filepath.Join(onlineApi.dropDir, "*.eml"))
+	emlBytes, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := regexp.MustCompile(regexp.QuoteMeta(test_serviceUrl+LINK_CONFIRM_EMAIL) + `([a-f0-9\-]+)`).FindStringSubmatch(string(emlBytes))
+	if link == nil {
+		t.Fatal("Expected verification link")
+	}
+
+	// Prefetching the link does not use it up
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": link[1]})
+	recorder := httptest.NewRecorder()
+	onlineApi.userVerify.EmailCheck(recorder, req)
+	test_expectStatus(t, recorder, http.StatusMethodNotAllowed)
+
+	if redirect := test_confirmRedirect(t, onlineApi.followLink(t, "vendor@example.com", LINK_CONFIRM_EMAIL, onlineApi.userVerify.EmailCheck)); redirect != "/" {
+		t.Fatalf("Expected redirect home, got %s", redirect)
+	}
+}
+
+func TestOnlineMode_PasswordResetExpired(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+
+	sessionId, err := onlineApi.userApi.SessionDB.NewSessionEntry(dbs.SessionEntry{
+		PasswordResetEmail:     "vendor@example.com",
+		PasswordResetTimestamp: time.Now().Add(-MAX_PASSWORD_RESET - time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := test_postJson(onlineApi.userVerify.PasswordResetApply, `{"password": "new-password", "confirm_password": "new-password"}`, &http.Cookie{Name: "session", Value: string(sessionId)})
+	test_expectStatus(t, recorder, http.StatusUnauthorized)
+	if !strings.Contains(recorder.Body.String(), "expired") {
+		t.Fatalf("Expected expired reset, got %s", recorder.Body.String())
+	}
+}
+
+func TestOnPremUser_PasswordLogin(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+
+	err := onlineApi.userApi.UserDB.Save(dbs.UserTestDBEntry{Email: ONPREM_CONFIG, EmailVerified: true, Status: dbs.AS_Validated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test_expectStatus(t, test_postJson(onlineApi.userApi.Login, `{"email": "tester@fido.local", "password": ""}`), http.StatusUnauthorized)
+}
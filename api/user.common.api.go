@@ -1,22 +1,36 @@
 package api
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
 	"golang.org/x/crypto/scrypt"
 )
 
 const ONPREM_CONFIG string = "tester@fido.local"
 
+const MIN_PASSWORD_LENGTH int = 8
+
 type UserAPI struct {
-	UserDB    *dbs.UserTestDB
-	SessionDB *dbs.SessionDB
+	UserDB     *dbs.UserTestDB
+	SessionDB  *dbs.SessionDB
+	VerifyDB   *dbs.VerifyDB
+	ApiTokenDB *dbs.ApiTokenDB
+	OrgDB      *dbs.OrgDB
+	Mailer     mailer.Sender
+	Config     *fdoshared.Config
+}
+
+// Emails are stored lower case, so every lookup must normalize them the same way
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 func isEmailValid(e string) bool {
@@ -24,7 +38,7 @@ func isEmailValid(e string) bool {
 	return emailRegex.MatchString(e)
 }
 
-func (h *UserAPI) generatePasswordHash(password string) ([]byte, error) {
+func generatePasswordHash(password string) ([]byte, error) {
 	salt := fdoshared.NewRandomBuffer(8)
 
 	dk, err := scrypt.Key([]byte(password), salt, 1<<15, 8, 1, 32)
@@ -35,7 +49,12 @@ func (h *UserAPI) generatePasswordHash(password string) ([]byte, error) {
 	return append(salt, dk...), nil
 }
 
-func (h *UserAPI) verifyPasswordHash(password string, passwordHash []byte) (bool, error) {
+func verifyPasswordHash(password string, passwordHash []byte) (bool, error) {
+	// Onprem users have no password
+	if len(passwordHash) <= 8 {
+		return false, nil
+	}
+
 	salt := passwordHash[0:8]
 
 	dk, err := scrypt.Key([]byte(password), salt, 1<<15, 8, 1, 32)
@@ -43,7 +62,7 @@ func (h *UserAPI) verifyPasswordHash(password string, passwordHash []byte) (bool
 		return false, errors.New("Error hashing password")
 	}
 
-	return bytes.Equal(append(salt, dk...), passwordHash), nil
+	return subtle.ConstantTimeCompare(dk, passwordHash[8:]) == 1, nil
 }
 
 func (h *UserAPI) setUserSession(w http.ResponseWriter, sessionInst dbs.SessionEntry) error {
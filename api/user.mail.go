@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
+)
+
+const MAIL_SIGNATURE string = "\n\n--\nFIDO Device Onboard Conformance Tools"
+
+func newVerifyEmailMessage(email string, verifyLink string) mailer.Message {
+	return mailer.Message{
+		To:      email,
+		Subject: "Verify your email for FDO Conformance Tools",
+		Body:    fmt.Sprintf("Please verify your email by opening the link below:\n\n%s\n\nIf you did not register, you can ignore this email.%s", verifyLink, MAIL_SIGNATURE),
+	}
+}
+
+func newPasswordResetMessage(email string, resetLink string) mailer.Message {
+	return mailer.Message{
+		To:      email,
+		Subject: "Reset your password for FDO Conformance Tools",
+		Body:    fmt.Sprintf("You can set a new password by opening the link below. The link expires in %s and can only be used once, and the new password must be set within %s.\n\n%s\n\nIf you did not request a password reset, you can ignore this email.%s", dbs.MAX_PASSWORD_RESET_VERIFY_TIME, MAX_PASSWORD_RESET, resetLink, MAIL_SIGNATURE),
+	}
+}
+
+func newAccountValidationMessage(adminEmail string, userInst dbs.UserTestDBEntry, approveLink string, rejectLink string) mailer.Message {
+	return mailer.Message{
+		To:      adminEmail,
+		Subject: "New account awaiting approval: " + userInst.Email,
+		Body:    fmt.Sprintf("New account is awaiting approval.\n\nEmail: %s\nName: %s\nCompany: %s\n\nApprove:\n%s\n\nReject:\n%s%s", userInst.Email, userInst.Name, userInst.Company, approveLink, rejectLink, MAIL_SIGNATURE),
+	}
+}
+
+func newRegistrationResultMessage(email string, approved bool, loginLink string) mailer.Message {
+	if approved {
+		return mailer.Message{
+			To:      email,
+			Subject: "Your FDO Conformance Tools account is approved",
+			Body:    fmt.Sprintf("Your account has been approved. You can now login at:\n\n%s%s", loginLink, MAIL_SIGNATURE),
+		}
+	}
+
+	return mailer.Message{
+		To:      email,
+		Subject: "Your FDO Conformance Tools registration was rejected",
+		Body:    "Your registration has been rejected. If you think this is a mistake, please contact the tools administrator." + MAIL_SIGNATURE,
+	}
+}
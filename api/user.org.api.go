@@ -0,0 +1,347 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/gorilla/mux"
+)
+
+type User_OrgCreateReq struct {
+	Name string `json:"name"`
+}
+
+type User_OrgMemberReq struct {
+	Email string      `json:"email"`
+	Role  dbs.OrgRole `json:"role"`
+}
+
+type User_OrgMember struct {
+	Email string      `json:"email"`
+	Role  dbs.OrgRole `json:"role"`
+}
+
+type User_OrgInfo struct {
+	Id      string           `json:"id"`
+	Name    string           `json:"name"`
+	Role    dbs.OrgRole      `json:"role"`
+	Members []User_OrgMember `json:"members"`
+	Invites []User_OrgMember `json:"invites"`
+}
+
+type User_OrgInvite struct {
+	Id   string      `json:"id"`
+	Name string      `json:"name"`
+	Role dbs.OrgRole `json:"role"`
+}
+
+func newOrgInfo(orgEntry dbs.OrgEntry, email string) User_OrgInfo {
+	orgInfo := User_OrgInfo{
+		Id:      orgEntry.Id,
+		Name:    orgEntry.Name,
+		Members: []User_OrgMember{},
+		Invites: []User_OrgMember{},
+	}
+
+	for _, member := range orgEntry.Members {
+		orgInfo.Members = append(orgInfo.Members, User_OrgMember{Email: member.Email, Role: member.Role})
+	}
+
+	for _, invite := range orgEntry.Invites {
+		orgInfo.Invites = append(orgInfo.Invites, User_OrgMember{Email: invite.Email, Role: invite.Role})
+	}
+
+	if member := orgEntry.GetMember(email); member != nil {
+		orgInfo.Role = member.Role
+	}
+
+	return orgInfo
+}
+
+// Organisation must always have an owner
+var errLastOwner = errors.New("Organisation must have at least one owner")
+
+func (h *UserAPI) getLoggedInUserAndOrg(w http.ResponseWriter, r *http.Request) (*dbs.UserTestDBEntry, *dbs.OrgEntry, bool) {
+	isLoggedIn, _, userInst := h.isLoggedIn(r)
+	if !isLoggedIn || userInst == nil {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	orgEntry, err := h.OrgDB.GetByMember(userInst.Email)
+	if err != nil {
+		log.Println("Failed to read organisation. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return userInst, orgEntry, true
+}
+
+func (h *UserAPI) OrgGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, orgEntry, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if orgEntry == nil {
+		commonapi.RespondError(w, "Not a member of an organisation", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, newOrgInfo(*orgEntry, userInst.Email))
+}
+
+// Creator becomes the owner, and their test instances move to the organisation
+func (h *UserAPI) OrgCreate(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, orgEntry, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if orgEntry != nil {
+		commonapi.RespondError(w, "Already a member of an organisation", http.StatusConflict)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var createReq User_OrgCreateReq
+	err = json.Unmarshal(bodyBytes, &createReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	createReq.Name = strings.TrimSpace(createReq.Name)
+	if createReq.Name == "" {
+		commonapi.RespondError(w, "Missing organisation name!", http.StatusBadRequest)
+		return
+	}
+
+	orgEntry, err = h.OrgDB.Create(createReq.Name, userInst.Email, h.UserDB)
+	if err != nil {
+		log.Println("Failed to create organisation. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, newOrgInfo(*orgEntry, userInst.Email))
+}
+
+// Invites user, or changes role of existing member or invite. Owner only.
+// Invited user becomes a member after accepting the invite
+
+func (h *UserAPI) OrgSetMember(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, orgEntry, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if orgEntry == nil || orgEntry.GetMember(userInst.Email).Role != dbs.OR_Owner {
+		commonapi.RespondError(w, "Only organisation owner can manage members", http.StatusForbidden)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var memberReq User_OrgMemberReq
+	err = json.Unmarshal(bodyBytes, &memberReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if !dbs.IsValidOrgRole(memberReq.Role) {
+		commonapi.RespondError(w, "Role must be owner, tester or viewer!", http.StatusBadRequest)
+		return
+	}
+
+	memberUser, err := h.UserDB.Get(normalizeEmail(memberReq.Email))
+	if err != nil {
+		commonapi.RespondError(w, "User does not exist!", http.StatusBadRequest)
+		return
+	}
+
+	orgEntry, err = h.OrgDB.Update(orgEntry.Id, func(orgEntry *dbs.OrgEntry) error {
+		orgEntry.SetMemberOrInvite(memberUser.Email, memberReq.Role)
+		if orgEntry.CountOwners() == 0 {
+			return errLastOwner
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to set organisation member. " + err.Error())
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, newOrgInfo(*orgEntry, userInst.Email))
+}
+
+// Owner can remove any member or invite, and other members can leave. Test instances stay with the organisation
+func (h *UserAPI) OrgRemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, orgEntry, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	memberEmail := normalizeEmail(mux.Vars(r)["email"])
+	if orgEntry == nil || (orgEntry.GetMember(userInst.Email).Role != dbs.OR_Owner && memberEmail != normalizeEmail(userInst.Email)) {
+		commonapi.RespondError(w, "Only organisation owner can manage members", http.StatusForbidden)
+		return
+	}
+
+	orgEntry, err := h.OrgDB.Update(orgEntry.Id, func(orgEntry *dbs.OrgEntry) error {
+		if orgEntry.RemoveInvite(memberEmail) {
+			return nil
+		}
+
+		if orgEntry.GetMember(memberEmail) == nil {
+			return errors.New("User is not a member of the organisation")
+		}
+
+		members := []dbs.OrgMember{}
+		for _, member := range orgEntry.Members {
+			if member.Email != memberEmail {
+				members = append(members, member)
+			}
+		}
+
+		orgEntry.Members = members
+		if orgEntry.CountOwners() == 0 {
+			return errLastOwner
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to remove organisation member. " + err.Error())
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, newOrgInfo(*orgEntry, userInst.Email))
+}
+
+// Lists organisations the user is invited to
+func (h *UserAPI) OrgListInvites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, _, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	orgEntries, err := h.OrgDB.ListInvites(userInst.Email)
+	if err != nil {
+		log.Println("Failed to list organisation invites. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invites := []User_OrgInvite{}
+	for _, orgEntry := range orgEntries {
+		invites = append(invites, User_OrgInvite{
+			Id:   orgEntry.Id,
+			Name: orgEntry.Name,
+			Role: orgEntry.GetInvite(userInst.Email).Role,
+		})
+	}
+
+	commonapi.RespondSuccessStruct(w, invites)
+}
+
+// Makes user a member, and moves their test instances to the organisation
+func (h *UserAPI) OrgAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, orgEntry, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if orgEntry != nil {
+		commonapi.RespondError(w, "Already a member of an organisation", http.StatusConflict)
+		return
+	}
+
+	orgEntry, err := h.OrgDB.AcceptInvite(mux.Vars(r)["id"], userInst.Email, h.UserDB)
+	if err != nil {
+		log.Println("Failed to accept organisation invite. " + err.Error())
+		commonapi.RespondError(w, "Invite not found!", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, newOrgInfo(*orgEntry, userInst.Email))
+}
+
+func (h *UserAPI) OrgDeclineInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, _, ok := h.getLoggedInUserAndOrg(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.OrgDB.Update(mux.Vars(r)["id"], func(orgEntry *dbs.OrgEntry) error {
+		if !orgEntry.RemoveInvite(userInst.Email) {
+			return errors.New("User is not invited to the organisation")
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to decline organisation invite. " + err.Error())
+		commonapi.RespondError(w, "Invite not found!", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/gorilla/mux"
+)
+
+func test_newUserSession(t *testing.T, userApi UserAPI, userInst dbs.UserTestDBEntry) *http.Cookie {
+	userInst.EmailVerified = true
+	userInst.Status = dbs.AS_Validated
+
+	err := userApi.UserDB.Save(userInst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionId, err := userApi.SessionDB.NewSessionEntry(dbs.SessionEntry{Email: userInst.Email, LoggedIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Cookie{Name: "session", Value: string(sessionId)}
+}
+
+func test_authorizeWorkspace(userApi UserAPI, cookie *http.Cookie, scope dbs.ApiTokenScope) (*dbs.TestWorkspace, error) {
+	req := httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+	req.AddCookie(cookie)
+	return commonapi.AuthorizeWorkspace(req, userApi.UserDB, userApi.SessionDB, userApi.ApiTokenDB, userApi.OrgDB, scope)
+}
+
+func test_getJson(handler http.HandlerFunc, vars map[string]string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), vars)
+	req.AddCookie(cookie)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+func test_acceptInvite(userApi UserAPI, orgId string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/api/user/org/invites/"+orgId+"/accept", nil), map[string]string{"id": orgId})
+	req.AddCookie(cookie)
+
+	recorder := httptest.NewRecorder()
+	userApi.OrgAcceptInvite(recorder, req)
+	return recorder
+}
+
+func TestOrg_SharedWorkspaceAndRoles(t *testing.T) {
+	userApi := test_newOnlineApi(t, "").userApi
+
+	ownerRvtInst := dbs.NewRVTestInst("http://rv.example.com", []byte("to0-owner"), []byte("to1-owner"))
+	ownerCookie := test_newUserSession(t, userApi, dbs.UserTestDBEntry{Email: "owner@example.com", RVTestInsts: []dbs.RVTestInst{ownerRvtInst}})
+	testerRvtInst := dbs.NewRVTestInst("http://rv3.example.com", []byte("to0-tester-own"), []byte("to1-tester-own"))
+	testerCookie := test_newUserSession(t, userApi, dbs.UserTestDBEntry{Email: "tester@example.com", RVTestInsts: []dbs.RVTestInst{testerRvtInst}})
+	viewerCookie := test_newUserSession(t, userApi, dbs.UserTestDBEntry{Email: "viewer@example.com"})
+	otherCookie := test_newUserSession(t, userApi, dbs.UserTestDBEntry{Email: "other@example.com"})
+
+	recorder := test_postJson(userApi.OrgCreate, `{"name": "Vendor"}`, ownerCookie)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	var orgInfo User_OrgInfo
+	err := json.Unmarshal(recorder.Body.Bytes(), &orgInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orgInfo.Role != dbs.OR_Owner || len(orgInfo.Members) != 1 {
+		t.Fatalf("Unexpected org %v", orgInfo)
+	}
+
+	test_expectStatus(t, test_postJson(userApi.OrgCreate, `{"name": "Vendor 2"}`, ownerCookie), http.StatusConflict)
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": "tester@example.com", "role": "tester"}`, testerCookie), http.StatusForbidden)
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": "tester@example.com", "role": "admin"}`, ownerCookie), http.StatusBadRequest)
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": "tester@example.com", "role": "tester"}`, ownerCookie), http.StatusOK)
+	// Emails are matched case-insensitively
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": " Viewer@Example.COM ", "role": "viewer"}`, ownerCookie), http.StatusOK)
+
+	// Invited users are not members until they accept
+	testerWorkspace, err := test_authorizeWorkspace(userApi, testerCookie, dbs.ATS_Execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if testerWorkspace.Org != nil || testerWorkspace.RVT_ContainID([]byte("to0-owner")) {
+		t.Fatal("Expected invited user not to be a member")
+	}
+
+	var invites []User_OrgInvite
+	recorder = test_getJson(userApi.OrgListInvites, nil, testerCookie)
+	test_expectStatus(t, recorder, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &invites)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invites) != 1 || invites[0].Id != orgInfo.Id || invites[0].Role != dbs.OR_Tester {
+		t.Fatalf("Unexpected invites %v", invites)
+	}
+
+	test_expectStatus(t, test_acceptInvite(userApi, "unknown", testerCookie), http.StatusNotFound)
+	test_expectStatus(t, test_acceptInvite(userApi, orgInfo.Id, testerCookie), http.StatusOK)
+	test_expectStatus(t, test_acceptInvite(userApi, orgInfo.Id, viewerCookie), http.StatusOK)
+
+	// Owner can not leave the organisation without an owner
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": "owner@example.com", "role": "tester"}`, ownerCookie), http.StatusBadRequest)
+
+	// User can be member of one organisation only
+	test_expectStatus(t, test_postJson(userApi.OrgCreate, `{"name": "Other"}`, otherCookie), http.StatusOK)
+	test_expectStatus(t, test_postJson(userApi.OrgSetMember, `{"email": "tester@example.com", "role": "tester"}`, otherCookie), http.StatusOK)
+
+	var otherOrgInfo User_OrgInfo
+	recorder = test_getJson(userApi.OrgGet, nil, otherCookie)
+	err = json.Unmarshal(recorder.Body.Bytes(), &otherOrgInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test_expectStatus(t, test_acceptInvite(userApi, otherOrgInfo.Id, testerCookie), http.StatusConflict)
+
+	testerWorkspace, err = test_authorizeWorkspace(userApi, testerCookie, dbs.ATS_Execute)
+	if err != nil {
+		t.Fatalf("Expected tester to execute. %s", err.Error())
+	}
+
+	if !testerWorkspace.RVT_ContainID([]byte("to0-owner")) {
+		t.Fatal("Expected owner test instances to move to the organisation")
+	}
+
+	if !testerWorkspace.RVT_ContainID([]byte("to0-tester-own")) {
+		t.Fatal("Expected tester test instances to move to the organisation")
+	}
+
+	err = testerWorkspace.AddRVTestInst(dbs.NewRVTestInst("http://rv2.example.com", []byte("to0-tester"), []byte("to1-tester")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = test_authorizeWorkspace(userApi, viewerCookie, dbs.ATS_Execute)
+	if err == nil {
+		t.Fatal("Expected viewer to be rejected for execute")
+	}
+
+	viewerWorkspace, err := test_authorizeWorkspace(userApi, viewerCookie, dbs.ATS_Read)
+	if err != nil {
+		t.Fatalf("Expected viewer to read. %s", err.Error())
+	}
+
+	if !viewerWorkspace.RVT_ContainID([]byte("to1-tester")) || len(viewerWorkspace.RVTestInsts()) != 3 {
+		t.Fatal("Expected viewer to see instances of the tester")
+	}
+
+	otherWorkspace, err := test_authorizeWorkspace(userApi, otherCookie, dbs.ATS_Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if otherWorkspace.RVT_ContainID([]byte("to0-tester")) {
+		t.Fatal("Expected other organisation not to see the instances")
+	}
+
+	// Only owner purges organisation tests
+	req := httptest.NewRequest("POST", "/api/user/purgetests", nil)
+	req.AddCookie(testerCookie)
+	recorder = httptest.NewRecorder()
+	userApi.PurgeTests(recorder, req)
+	test_expectStatus(t, recorder, http.StatusForbidden)
+
+	// Members can leave, and get their own workspace back
+	req = mux.SetURLVars(httptest.NewRequest("DELETE", "/api/user/org/members/Viewer@Example.com", nil), map[string]string{"email": "Viewer@Example.com"})
+	req.AddCookie(viewerCookie)
+	recorder = httptest.NewRecorder()
+	userApi.OrgRemoveMember(recorder, req)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	viewerWorkspace, err = test_authorizeWorkspace(userApi, viewerCookie, dbs.ATS_Execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if viewerWorkspace.Org != nil || viewerWorkspace.RVT_ContainID([]byte("to0-tester")) {
+		t.Fatal("Expected viewer to have own workspace after leaving")
+	}
+}
@@ -121,13 +121,22 @@ func (h *UserAPI) PurgeTests(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInst.DeviceTestInsts = []dbs.DeviceTestInst{}
-	userInst.DOTestInsts = []dbs.DOTestInst{}
-	userInst.RVTestInsts = []dbs.RVTestInst{}
+	workspace, err := dbs.NewTestWorkspace(userInst, h.UserDB, h.OrgDB)
+	if err != nil {
+		log.Println("Failed to load workspace. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	err = h.UserDB.Save(*userInst)
+	// Purging organisation tests affects every member
+	if workspace.Role != dbs.OR_Owner {
+		commonapi.RespondError(w, "Only organisation owner can purge tests", http.StatusForbidden)
+		return
+	}
+
+	err = workspace.PurgeTestInsts()
 	if err != nil {
-		log.Println("Failed to save user. " + err.Error())
+		log.Println("Failed to save workspace. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -136,3 +145,19 @@ func (h *UserAPI) PurgeTests(w http.ResponseWriter, r *http.Request) {
 
 	commonapi.RespondSuccess(w)
 }
+
+type User_ModeResp struct {
+	Mode string `json:"mode"`
+}
+
+// Frontend shows onprem login button, or registration and password login in online mode
+func (h *UserAPI) Mode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, User_ModeResp{
+		Mode: h.Config.Mode,
+	})
+}
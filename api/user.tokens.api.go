@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/gorilla/mux"
+)
+
+type User_ApiTokenCreateReq struct {
+	Name  string            `json:"name"`
+	Scope dbs.ApiTokenScope `json:"scope"`
+}
+
+type User_ApiTokenInfo struct {
+	Id       string            `json:"id"`
+	Name     string            `json:"name"`
+	Scope    dbs.ApiTokenScope `json:"scope"`
+	Created  time.Time         `json:"created"`
+	LastUsed *time.Time        `json:"lastUsed,omitempty"`
+}
+
+// Token is only returned on creation
+type User_ApiTokenCreateResp struct {
+	User_ApiTokenInfo
+	Token string `json:"token"`
+}
+
+func newApiTokenInfo(tokenEntry dbs.ApiTokenEntry) User_ApiTokenInfo {
+	tokenInfo := User_ApiTokenInfo{
+		Id:      tokenEntry.Id,
+		Name:    tokenEntry.Name,
+		Scope:   tokenEntry.Scope,
+		Created: tokenEntry.Created,
+	}
+
+	if !tokenEntry.LastUsed.IsZero() {
+		tokenInfo.LastUsed = &tokenEntry.LastUsed
+	}
+
+	return tokenInfo
+}
+
+// Tokens are managed with session only, so a token can not create more tokens
+func (h *UserAPI) ApiTokenCreate(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	isLoggedIn, _, userInst := h.isLoggedIn(r)
+	if !isLoggedIn || userInst == nil {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var createReq User_ApiTokenCreateReq
+	err = json.Unmarshal(bodyBytes, &createReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	createReq.Name = strings.TrimSpace(createReq.Name)
+	if createReq.Name == "" {
+		commonapi.RespondError(w, "Missing token name!", http.StatusBadRequest)
+		return
+	}
+
+	if !dbs.IsValidApiTokenScope(createReq.Scope) {
+		commonapi.RespondError(w, "Scope must be read or execute!", http.StatusBadRequest)
+		return
+	}
+
+	token, tokenEntry, err := h.ApiTokenDB.Create(userInst.Email, createReq.Name, createReq.Scope)
+	if err != nil {
+		log.Println("Failed to create api token. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, User_ApiTokenCreateResp{
+		User_ApiTokenInfo: newApiTokenInfo(*tokenEntry),
+		Token:             token,
+	})
+}
+
+func (h *UserAPI) ApiTokenList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isLoggedIn, _, userInst := h.isLoggedIn(r)
+	if !isLoggedIn || userInst == nil {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenEntries, err := h.ApiTokenDB.List(userInst.Email)
+	if err != nil {
+		log.Println("Failed to list api tokens. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tokenInfos := []User_ApiTokenInfo{}
+	for _, tokenEntry := range tokenEntries {
+		tokenInfos = append(tokenInfos, newApiTokenInfo(tokenEntry))
+	}
+
+	commonapi.RespondSuccessStruct(w, tokenInfos)
+}
+
+func (h *UserAPI) ApiTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isLoggedIn, _, userInst := h.isLoggedIn(r)
+	if !isLoggedIn || userInst == nil {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.ApiTokenDB.Delete(userInst.Email, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println("Failed to revoke api token. " + err.Error())
+		commonapi.RespondError(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
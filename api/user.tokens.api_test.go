@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/gorilla/mux"
+)
+
+func TestApiTokens_CreateListRevoke(t *testing.T) {
+	onlineApi := test_newOnlineApi(t, "")
+	userApi := onlineApi.userApi
+
+	err := userApi.UserDB.Save(dbs.UserTestDBEntry{Email: "vendor@example.com", EmailVerified: true, Status: dbs.AS_Validated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionId, err := userApi.SessionDB.NewSessionEntry(dbs.SessionEntry{Email: "vendor@example.com", LoggedIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionCookie := &http.Cookie{Name: "session", Value: string(sessionId)}
+
+	test_expectStatus(t, test_postJson(userApi.ApiTokenCreate, `{"name": "ci", "scope": "read"}`), http.StatusUnauthorized)
+	test_expectStatus(t, test_postJson(userApi.ApiTokenCreate, `{"name": "ci", "scope": "admin"}`, sessionCookie), http.StatusBadRequest)
+
+	recorder := test_postJson(userApi.ApiTokenCreate, `{"name": "ci", "scope": "read"}`, sessionCookie)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	var createResp User_ApiTokenCreateResp
+	err = json.Unmarshal(recorder.Body.Bytes(), &createResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(createResp.Token, dbs.API_TOKEN_PREFIX) || createResp.Scope != dbs.ATS_Read {
+		t.Fatalf("Unexpected token %s with scope %s", createResp.Token, createResp.Scope)
+	}
+
+	// Tokens can not manage tokens
+	req := httptest.NewRequest("GET", "/api/user/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+createResp.Token)
+	recorder = httptest.NewRecorder()
+	userApi.ApiTokenList(recorder, req)
+	test_expectStatus(t, recorder, http.StatusUnauthorized)
+
+	req = httptest.NewRequest("GET", "/api/user/tokens", nil)
+	req.AddCookie(sessionCookie)
+	recorder = httptest.NewRecorder()
+	userApi.ApiTokenList(recorder, req)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	if strings.Contains(recorder.Body.String(), createResp.Token) || !strings.Contains(recorder.Body.String(), createResp.Id) {
+		t.Fatalf("Expected token list without the token itself, got %s", recorder.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+	req.Header.Set("Authorization", "Bearer "+createResp.Token)
+	_, err = commonapi.AuthorizeRequest(req, userApi.UserDB, userApi.SessionDB, userApi.ApiTokenDB, dbs.ATS_Read)
+	if err != nil {
+		t.Fatalf("Expected token to be accepted. %s", err.Error())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("DELETE", "/api/user/tokens/"+createResp.Id, nil), map[string]string{"id": createResp.Id})
+	req.AddCookie(sessionCookie)
+	recorder = httptest.NewRecorder()
+	userApi.ApiTokenRevoke(recorder, req)
+	test_expectStatus(t, recorder, http.StatusOK)
+
+	req = httptest.NewRequest("GET", "/api/rvt/testruns", nil)
+	req.Header.Set("Authorization", "Bearer "+createResp.Token)
+	_, err = commonapi.AuthorizeRequest(req, userApi.UserDB, userApi.SessionDB, userApi.ApiTokenDB, dbs.ATS_Read)
+	if err == nil {
+		t.Fatal("Expected revoked token to be rejected")
+	}
+}
@@ -1,18 +1,34 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
+	"github.com/gorilla/mux"
 )
 
 const MAX_PASSWORD_RESET time.Duration = time.Hour
 
+// Mail links open a frontend page, that confirms them with POST. Mail scanners prefetching GET links do not use them up
+const LINK_CONFIRM_EMAIL = "/#/confirm/email/"
+const LINK_CONFIRM_PASSWORD_RESET = "/#/confirm/resetpassword/"
+const LINK_CONFIRM_ACCOUNT_APPROVE = "/#/confirm/approve/"
+const LINK_CONFIRM_ACCOUNT_REJECT = "/#/confirm/reject/"
+
 type UserVerify struct {
 	UserDB    *dbs.UserTestDB
 	VerifyDB  *dbs.VerifyDB
 	SessionDB *dbs.SessionDB
+	Mailer    mailer.Sender
+	Config    *fdoshared.Config
 }
 
 func (h *UserVerify) getSession(r *http.Request) (*dbs.SessionEntry, error) {
@@ -50,3 +66,236 @@ func (h *UserVerify) deleteSession(r *http.Request) error {
 
 	return nil
 }
+
+// Reads verify entry from the link, and deletes it so the link can only be used once
+func (h *UserVerify) useVerifyEntry(r *http.Request, expectedType dbs.VerifyType) (*dbs.VerifyEntry, error) {
+	entryId := []byte(mux.Vars(r)["id"])
+
+	verifyEntry, err := h.VerifyDB.GetEntry(entryId)
+	if err != nil {
+		return nil, err
+	}
+
+	if verifyEntry.Type != expectedType {
+		return nil, errors.New("Unexpected verify entry type " + string(verifyEntry.Type))
+	}
+
+	err = h.VerifyDB.DeleteEntry(entryId)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyEntry, nil
+}
+
+func (h *UserVerify) EmailCheck(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	verifyEntry, err := h.useVerifyEntry(r, dbs.VT_Email)
+	if err != nil {
+		log.Println("Failed to verify email. " + err.Error())
+		commonapi.RespondError(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(verifyEntry.Email)
+	if err != nil {
+		log.Println("Failed to verify email. " + err.Error())
+		commonapi.RespondError(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	userInst.EmailVerified = true
+
+	// Without admin email accounts are approved as soon as email is verified
+	adminEmail := h.Config.AdminEmail
+	if adminEmail == "" {
+		userInst.Status = dbs.AS_Validated
+	} else if userInst.Status == dbs.AS_Awaiting {
+		validationId, err := h.VerifyDB.SaveEntry(dbs.VerifyEntry{Email: userInst.Email, Type: dbs.VT_AccountValidation})
+		if err != nil {
+			log.Println("Failed to save account validation entry. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		err = h.Mailer.Send(newAccountValidationMessage(adminEmail, *userInst, h.Config.FdoServiceUrl+LINK_CONFIRM_ACCOUNT_APPROVE+string(validationId), h.Config.FdoServiceUrl+LINK_CONFIRM_ACCOUNT_REJECT+string(validationId)))
+		if err != nil {
+			log.Println("Failed to send account validation email. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = h.UserDB.Save(*userInst)
+	if err != nil {
+		log.Println("Failed to save user. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if userInst.Status != dbs.AS_Validated {
+		commonapi.RespondSuccessStruct(w, commonapi.User_ConfirmResp{Status: commonapi.FdoApiStatus_OK, Redirect: commonapi.REDIRECT_AWAITING_VERIFICATION})
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, commonapi.User_ConfirmResp{Status: commonapi.FdoApiStatus_OK, Redirect: commonapi.REDIRECT_HOME})
+}
+
+func (h *UserVerify) setAccountStatus(w http.ResponseWriter, r *http.Request, approved bool) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	verifyEntry, err := h.useVerifyEntry(r, dbs.VT_AccountValidation)
+	if err != nil {
+		log.Println("Failed to validate account. " + err.Error())
+		commonapi.RespondError(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(verifyEntry.Email)
+	if err != nil {
+		log.Println("Failed to validate account. " + err.Error())
+		commonapi.RespondError(w, "User does not exist", http.StatusBadRequest)
+		return
+	}
+
+	userInst.Status = dbs.AS_Blocked
+	if approved {
+		userInst.Status = dbs.AS_Validated
+	}
+
+	err = h.UserDB.Save(*userInst)
+	if err != nil {
+		log.Println("Failed to save user. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Logs out the blocked user
+	if !approved {
+		err = h.SessionDB.DeleteUserSessions(userInst.Email)
+		if err != nil {
+			log.Println("Failed to delete user sessions. " + err.Error())
+		}
+	}
+
+	err = h.Mailer.Send(newRegistrationResultMessage(userInst.Email, approved, h.Config.FdoServiceUrl+"/#/login"))
+	if err != nil {
+		log.Println("Failed to send registration result email. " + err.Error())
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+func (h *UserVerify) AccountApprove(w http.ResponseWriter, r *http.Request) {
+	h.setAccountStatus(w, r, true)
+}
+
+func (h *UserVerify) AccountReject(w http.ResponseWriter, r *http.Request) {
+	h.setAccountStatus(w, r, false)
+}
+
+// Opens password reset session, that is used by PasswordResetApply
+func (h *UserVerify) PasswordResetCheck(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	verifyEntry, err := h.useVerifyEntry(r, dbs.VT_PasswordReset)
+	if err != nil {
+		log.Println("Failed to check password reset. " + err.Error())
+		commonapi.RespondError(w, "Invalid or expired link", http.StatusBadRequest)
+		return
+	}
+
+	sessionDbId, err := h.SessionDB.NewSessionEntry(dbs.SessionEntry{
+		PasswordResetEmail:     verifyEntry.Email,
+		PasswordResetTimestamp: time.Now(),
+	})
+	if err != nil {
+		log.Println("Error creating session. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, commonapi.GenerateCookie(sessionDbId))
+	commonapi.RespondSuccessStruct(w, commonapi.User_ConfirmResp{Status: commonapi.FdoApiStatus_OK, Redirect: commonapi.REDIRECT_RESET_PASSWORD})
+}
+
+func (h *UserVerify) PasswordResetApply(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	sessionInst, err := h.getSession(r)
+	if err != nil || sessionInst.PasswordResetEmail == "" {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if time.Since(sessionInst.PasswordResetTimestamp) > MAX_PASSWORD_RESET {
+		h.deleteSession(r)
+		commonapi.RespondError(w, "Password reset has expired. Please request a new one.", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var resetReq commonapi.User_ResetPassword
+	err = json.Unmarshal(bodyBytes, &resetReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if resetReq.Password != resetReq.ConfirmPassword {
+		commonapi.RespondError(w, "Passwords do not match!", http.StatusBadRequest)
+		return
+	}
+
+	if len(resetReq.Password) < MIN_PASSWORD_LENGTH {
+		commonapi.RespondError(w, "Password is too short!", http.StatusBadRequest)
+		return
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.PasswordResetEmail)
+	if err != nil {
+		log.Println("User does not exists. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userInst.PasswordHash, err = generatePasswordHash(resetReq.Password)
+	if err != nil {
+		log.Println("Error hashing password. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.UserDB.Save(*userInst)
+	if err != nil {
+		log.Println("Failed to save user. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Closes the reset session, and logs out every other session of the user
+	err = h.SessionDB.DeleteUserSessions(userInst.Email)
+	if err != nil {
+		log.Println("Failed to delete user sessions. " + err.Error())
+	}
+
+	http.SetCookie(w, commonapi.GenerateCookie([]byte{}))
+
+	commonapi.RespondSuccess(w)
+}
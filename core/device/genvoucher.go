@@ -22,16 +22,24 @@ func GenerateOvEntry(
 	mfgPrivateKey interface{},
 	prevEntrySgType fdoshared.DeviceSgType,
 	newEntrySgType fdoshared.DeviceSgType,
+	pkEnc fdoshared.FdoPkEnc,
+	keyStore fdoshared.KeyStore,
 	testId testcom.FDOTestID,
+	rnd *fdoshared.Conf_Rand,
 ) (interface{}, []byte, *fdoshared.CoseSignature, error) {
-	// Generate manufacturer private key.
-	newOVEPrivateKey, newOVEPublicKey, err := fdoshared.GenerateVoucherKeypair(newEntrySgType)
+	// Generate entry private key. Returns the key reference of the store
+	newOVEKeyRef, newOVEPrivateKey, err := keyStore.GenerateKey(newEntrySgType)
+	if err != nil {
+		return nil, []byte{}, nil, err
+	}
+
+	newOVEPublicKey, err := fdoshared.NewFdoPublicKey(newOVEPrivateKey.Public(), newEntrySgType, pkEnc)
 	if err != nil {
 		return nil, []byte{}, nil, err
 	}
 
 	if testId == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_PUBKEY {
-		newOVEPublicKey = fdoshared.Conf_RandomTestFuzzPublicKey(*newOVEPublicKey)
+		newOVEPublicKey = fdoshared.Conf_RandomTestFuzzPublicKey(rnd, *newOVEPublicKey)
 	}
 
 	ovEntryPayload := fdoshared.OVEntryPayload{
@@ -55,21 +63,30 @@ func GenerateOvEntry(
 		return nil, []byte{}, nil, errors.New("Error generating OVEntry. " + err.Error())
 	}
 
-	marshaledPrivateKey, err := fdoshared.MarshalPrivateKey(newOVEPrivateKey, newEntrySgType)
-	if err != nil {
-		return nil, []byte{}, nil, errors.New("Error marshaling private key. " + err.Error())
-	}
+	return newOVEPrivateKey, newOVEKeyRef, ovEntry, nil
+}
 
-	return newOVEPrivateKey, marshaledPrivateKey, ovEntry, nil
+// Conformance vouchers are handed to the implementation under test, so the owner key is always a software key
+func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID, rnd *fdoshared.Conf_Rand) (*fdoshared.DeviceCredAndVoucher, error) {
+	return NewVirtualDeviceAndVoucherWithKeyStore(newDi, voucherSgType, ovRVInfo, fdoTestID, rnd, fdoshared.SoftwareKeyStore{})
 }
 
-func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
+// The final owner key is generated by the ownerKeyStore. Manufacturer and intermediate entry keys are discarded, and so are software keys
+func NewVirtualDeviceAndVoucherWithKeyStore(newDi fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID, rnd *fdoshared.Conf_Rand, ownerKeyStore fdoshared.KeyStore) (*fdoshared.DeviceCredAndVoucher, error) {
 	negotiatedHashHmac := fdoshared.NegotiateHashHmac(newDi.DCSigInfo.SgType, voucherSgType)
 
 	newDi.UpdatedToNewHashHmac(negotiatedHashHmac)
 
+	var fuzzMutation *fdoshared.Conf_CborMutation
+
+	// All owner keys of the voucher use the same encoding
+	pkEnc, ok := testcom.FIDO_TEST_PKENC[fdoTestID]
+	if !ok {
+		pkEnc = fdoshared.Conf_NewRandomFdoPkEnc(rnd)
+	}
+
 	// Generate manufacturer private key.
-	mfgPrivateKey, mfgPublicKey, err := fdoshared.GenerateVoucherKeypair(voucherSgType)
+	mfgPrivateKey, mfgPublicKey, err := fdoshared.GenerateVoucherKeypairWithEncoding(voucherSgType, pkEnc)
 	if err != nil {
 		return nil, errors.New("Error generating new manufacturer private key. " + err.Error())
 	}
@@ -85,7 +102,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 
 	// Tests
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION {
-		voucherHeader.OVHProtVer = fdoshared.ProtVersion(uint16(fdoshared.NewRandomInt(105, 10000)))
+		voucherHeader.OVHProtVer = fdoshared.ProtVersion(uint16(rnd.Int(105, 10000)))
 	}
 
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY {
@@ -97,16 +114,24 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 	}
 
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_PUBKEY {
-		voucherHeader.OVPublicKey = *fdoshared.Conf_RandomTestFuzzPublicKey(voucherHeader.OVPublicKey)
+		voucherHeader.OVPublicKey = *fdoshared.Conf_RandomTestFuzzPublicKey(rnd, voucherHeader.OVPublicKey)
 	}
 
-	if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_CERTCHAIN_HASH {
+	isEpidDevice := fdoshared.IsEpidSgType(newDi.DCSigInfo.SgType)
+	if isEpidDevice {
+		voucherHeader.OVDevCertChainHash = nil
+	}
+
+	if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_CERTCHAIN_HASH && isEpidDevice {
+		// EPID hash must be null
+		voucherHeader.OVDevCertChainHash = &newDi.DCCertificateChainHash
+	} else if fdoTestID == testcom.FIDO_TEST_VOUCHER_HEADER_BAD_CERTCHAIN_HASH {
 		var totalBytes []byte
 		for _, cert := range newDi.DCCertificateChain {
 			totalBytes = append(totalBytes, cert...)
 		}
 
-		voucherHeader.OVDevCertChainHash = fdoshared.Conf_RandomTestHashHmac(*voucherHeader.OVDevCertChainHash, totalBytes, nil)
+		voucherHeader.OVDevCertChainHash = fdoshared.Conf_RandomTestHashHmac(rnd, *voucherHeader.OVDevCertChainHash, totalBytes, nil)
 	}
 
 	ovHeaderBytes, err := fdoshared.CborCust.Marshal(voucherHeader)
@@ -126,8 +151,8 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 	var ovEntryArray []fdoshared.CoseSignature = []fdoshared.CoseSignature{}
 
 	// Test params preparation
-	var ovEntriesCount int = fdoshared.NewRandomInt(3, 7)
-	var badOvEntryIndex = fdoshared.NewRandomInt(0, ovEntriesCount)
+	var ovEntriesCount int = rnd.Int(3, 7)
+	var badOvEntryIndex = rnd.Int(0, ovEntriesCount)
 
 	var prevEntryPrivKey interface{} = mfgPrivateKey
 	var prevEntryHash fdoshared.HashOrHmac
@@ -158,7 +183,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 
 			// Test
 			if i == badOvEntryIndex && fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_PREV_HASH {
-				prevEntryHash = *fdoshared.Conf_RandomTestHashHmac(prevEntryHash, oveHdrInfo, []byte{})
+				prevEntryHash = *fdoshared.Conf_RandomTestHashHmac(rnd, prevEntryHash, oveHdrInfo, []byte{})
 			}
 		} else {
 			prevEntry := ovEntryArray[i-1]
@@ -167,7 +192,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 
 			// Test
 			if i == badOvEntryIndex && fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_PREV_HASH {
-				prevEntryHash = *fdoshared.Conf_RandomTestHashHmac(prevEntryHash, oveHdrInfo, []byte{})
+				prevEntryHash = *fdoshared.Conf_RandomTestHashHmac(rnd, prevEntryHash, oveHdrInfo, []byte{})
 			}
 		}
 
@@ -175,15 +200,20 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 		// Test
 		if i == badOvEntryIndex {
 			if fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_HDRINFO_HASH {
-				oveHdrInfoHash = *fdoshared.Conf_RandomTestHashHmac(oveHdrInfoHash, oveHdrInfo, []byte{})
+				oveHdrInfoHash = *fdoshared.Conf_RandomTestHashHmac(rnd, oveHdrInfoHash, oveHdrInfo, []byte{})
 			}
 
 			if fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_SG_TYPE {
-				chosenSgType = fdoshared.Conf_NewRandomSgTypeExcept(chosenSgType)
+				chosenSgType = fdoshared.Conf_NewRandomSgTypeExcept(rnd, chosenSgType)
 			}
 		}
 
-		newPrivKeyInst, newPrivMashaled, newOvEntry, err := GenerateOvEntry(prevEntryHash, oveHdrInfoHash, prevEntryPrivKey, prevEntrySgType, chosenSgType, fdoTestID)
+		var entryKeyStore fdoshared.KeyStore = fdoshared.SoftwareKeyStore{}
+		if i == ovEntriesCount-1 {
+			entryKeyStore = ownerKeyStore
+		}
+
+		newPrivKeyInst, newPrivMashaled, newOvEntry, err := GenerateOvEntry(prevEntryHash, oveHdrInfoHash, prevEntryPrivKey, prevEntrySgType, chosenSgType, pkEnc, entryKeyStore, fdoTestID, rnd)
 		if err != nil {
 			return nil, err
 		}
@@ -191,7 +221,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 		prevEntrySgType = chosenSgType
 
 		if i == badOvEntryIndex && fdoTestID == testcom.FIDO_TEST_VOUCHER_ENTRY_BAD_SIGNATURE {
-			newOvEntry.Signature = fdoshared.Conf_RandomCborBufferFuzzing(newOvEntry.Signature)
+			newOvEntry.Signature, fuzzMutation = fdoshared.Conf_RandomOpaqueBytesFuzzing(rnd, newOvEntry.Signature)
 		}
 
 		ovEntryArray = append(ovEntryArray, *newOvEntry)
@@ -211,17 +241,24 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 		OVEntryArray:   ovEntryArray,
 	}
 
+	if isEpidDevice {
+		voucherInst.OVDevCertChain = nil
+	}
+
 	// Test
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_BAD_PROT_VERSION {
-		voucherInst.OVProtVer = fdoshared.ProtVersion(uint16(fdoshared.NewRandomInt(105, 10000)))
+		voucherInst.OVProtVer = fdoshared.ProtVersion(uint16(rnd.Int(105, 10000)))
 	}
 
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_BAD_HEADER_BYTES {
-		voucherInst.OVHeaderTag = fdoshared.Conf_RandomCborBufferFuzzing(voucherInst.OVHeaderTag)
+		voucherInst.OVHeaderTag, fuzzMutation, err = fdoshared.Conf_RandomCborBytesFuzzing(rnd, voucherInst.OVHeaderTag)
+		if err != nil {
+			return nil, errors.New("Error fuzzing OVHeader! " + err.Error())
+		}
 	}
 
 	if fdoTestID == testcom.FIDO_TEST_VOUCHER_BAD_HDR_HMAC {
-		voucherInst.OVHeaderHMac = *fdoshared.Conf_RandomTestHashHmac(voucherInst.OVHeaderHMac, ovHeaderBytes, newDi.DCHmacSecret)
+		voucherInst.OVHeaderHMac = *fdoshared.Conf_RandomTestHashHmac(rnd, voucherInst.OVHeaderHMac, ovHeaderBytes, newDi.DCHmacSecret)
 	}
 
 	if voucherInst.OVDevCertChain != nil && fdoTestID == testcom.FIDO_TEST_VOUCHER_BAD_HDR_HMAC {
@@ -246,13 +283,15 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 	newWDC := fdoshared.DeviceCredAndVoucher{
 		VoucherDBEntry:      voucherDBEInst,
 		WawDeviceCredential: newDi,
+		FuzzMutation:        fuzzMutation,
 	}
 
 	return &newWDC, err
 }
 
-func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID) error {
-	newdav, err := NewVirtualDeviceAndVoucher(deviceCred, voucherSgType, ovRVInfo, fdoTestID)
+// Saves device credential and voucher to the PEM files. If credSealer is set, device secrets are sealed with it
+func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType, ovRVInfo fdoshared.RendezvousInfo, fdoTestID testcom.FDOTestID, credSealer fdoshared.DeviceCredSealer) error {
+	newdav, err := NewVirtualDeviceAndVoucherWithKeyStore(deviceCred, voucherSgType, ovRVInfo, fdoTestID, nil, fdoshared.GetKeyStore())
 	if err != nil {
 		return err
 	}
@@ -267,7 +306,7 @@ func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredentia
 	voucherBytesPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.OWNERSHIP_VOUCHER_PEM_TYPE, Bytes: voucherBytes})
 
 	// LastOVEntry private key to PEM
-	ovEntryPrivateKeyPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.PRIVATE_KEY_PEM_TYPE, Bytes: vdandv.VoucherDBEntry.PrivateKeyX509})
+	ovEntryPrivateKeyPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.GetKeyRefPemType(vdandv.VoucherDBEntry.PrivateKeyX509), Bytes: vdandv.VoucherDBEntry.PrivateKeyX509})
 
 	voucherFileBytes := append(voucherBytesPem, ovEntryPrivateKeyPem...)
 
@@ -281,12 +320,11 @@ func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredentia
 	}
 
 	// Di bytes
-	diBytes, err := fdoshared.CborCust.Marshal(vdandv.WawDeviceCredential)
+	diBytesPem, err := MarshalDeviceCredential(vdandv.WawDeviceCredential, credSealer)
 	if err != nil {
-		return errors.New("Error marshaling voucher bytes. " + err.Error())
+		return err
 	}
 
-	diBytesPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.CREDENTIAL_PEM_TYPE, Bytes: diBytes})
 	disWriteLocation := fmt.Sprintf("%s/%s.dis.pem", DIS_LOCATION, filename)
 	err = os.WriteFile(disWriteLocation, diBytesPem, 0644)
 	if err != nil {
@@ -300,6 +338,29 @@ func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredentia
 	return nil
 }
 
+func MarshalDeviceCredential(deviceCred fdoshared.WawDeviceCredential, credSealer fdoshared.DeviceCredSealer) ([]byte, error) {
+	if credSealer == nil {
+		diBytes, err := fdoshared.CborCust.Marshal(deviceCred)
+		if err != nil {
+			return []byte{}, errors.New("Error marshaling device credential bytes. " + err.Error())
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: fdoshared.CREDENTIAL_PEM_TYPE, Bytes: diBytes}), nil
+	}
+
+	sealedCred, err := fdoshared.SealDeviceCredential(deviceCred, credSealer)
+	if err != nil {
+		return []byte{}, errors.New("Error sealing device credential. " + err.Error())
+	}
+
+	diBytes, err := fdoshared.CborCust.Marshal(sealedCred)
+	if err != nil {
+		return []byte{}, errors.New("Error marshaling sealed device credential bytes. " + err.Error())
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: fdoshared.SEALED_CREDENTIAL_PEM_TYPE, Bytes: diBytes}), nil
+}
+
 func MarshalVoucherAndPrivateKey(vdbEntry fdoshared.VoucherDBEntry) ([]byte, error) {
 	// Voucher to PEM
 	voucherBytes, err := fdoshared.CborCust.Marshal(vdbEntry.Voucher)
@@ -309,7 +370,7 @@ func MarshalVoucherAndPrivateKey(vdbEntry fdoshared.VoucherDBEntry) ([]byte, err
 	voucherBytesPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.OWNERSHIP_VOUCHER_PEM_TYPE, Bytes: voucherBytes})
 
 	// LastOVEntry private key to PEM
-	ovEntryPrivateKeyPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.PRIVATE_KEY_PEM_TYPE, Bytes: vdbEntry.PrivateKeyX509})
+	ovEntryPrivateKeyPem := pem.EncodeToMemory(&pem.Block{Type: fdoshared.GetKeyRefPemType(vdbEntry.PrivateKeyX509), Bytes: vdbEntry.PrivateKeyX509})
 	voucherFileBytes := append(voucherBytesPem, ovEntryPrivateKeyPem...)
 
 	return voucherFileBytes, nil
@@ -9,6 +9,8 @@ import (
 
 func (h *To1Requestor) HelloRV30(fdoTestID testcom.FDOTestID) (*fdoshared.HelloRVAck31, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 	var helloRVAck31 fdoshared.HelloRVAck31
 
 	helloRv30 := fdoshared.HelloRV30{
@@ -21,7 +23,7 @@ func (h *To1Requestor) HelloRV30(fdoTestID testcom.FDOTestID) (*fdoshared.HelloR
 	}
 
 	if fdoTestID == testcom.FIDO_DEVT_30_BAD_SIGINFO {
-		helloRv30.EASigInfo = fdoshared.Conf_RandomTestFuzzSigInfo(helloRv30.EASigInfo)
+		helloRv30.EASigInfo = fdoshared.Conf_RandomTestFuzzSigInfo(rnd, helloRv30.EASigInfo)
 	}
 
 	helloRV30Bytes, err := fdoshared.CborCust.Marshal(helloRv30)
@@ -30,12 +32,16 @@ func (h *To1Requestor) HelloRV30(fdoTestID testcom.FDOTestID) (*fdoshared.HelloR
 	}
 
 	if fdoTestID == testcom.FIDO_DEVT_30_BAD_ENCODING {
-		helloRV30Bytes = fdoshared.Conf_RandomCborBufferFuzzing(helloRV30Bytes)
+		helloRV30Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, helloRv30)
+		if err != nil {
+			return nil, nil, errors.New("HelloRV30: Error fuzzing HelloRV30. " + err.Error())
+		}
 	}
 
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.rvEntry, fdoshared.TO1_30_HELLO_RV, helloRV30Bytes, &h.rvEntry.AccessToken)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 	}
 
 	if err != nil {
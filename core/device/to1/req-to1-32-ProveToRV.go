@@ -9,6 +9,8 @@ import (
 
 func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestID testcom.FDOTestID) (*fdoshared.CoseSignature, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 
 	var proveToRV32Payload fdoshared.EATPayloadBase = fdoshared.EATPayloadBase{
 		EatNonce: helloRVAck31.NonceTO1Proof,
@@ -25,23 +27,21 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 	}
 
 	if fdoTestID == testcom.FIDO_DEVT_32_BAD_PROVE_TO_RV_PAYLOAD_ENCODING {
-		proveToRV32PayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(proveToRV32PayloadBytes)
-	}
-
-	privateKeyInst, err := fdoshared.ExtractPrivateKey(h.credential.DCPrivateKeyDer)
-	if err != nil {
-		return nil, nil, errors.New("ProveToRV32: Error extracting private key from voucher. " + err.Error())
+		proveToRV32PayloadBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, proveToRV32Payload)
+		if err != nil {
+			return nil, nil, errors.New("ProveToRV32: Error fuzzing ProveToRV32 payload. " + err.Error())
+		}
 	}
 
 	sgType := helloRVAck31.EBSigInfo.SgType
 
-	proveToRV32, err := fdoshared.GenerateCoseSignature(proveToRV32PayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, privateKeyInst, sgType)
+	proveToRV32, err := h.credStore.Sign(proveToRV32PayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, sgType)
 	if err != nil {
 		return nil, nil, errors.New("ProveToRV32: Error generating ProveToRV32. " + err.Error())
 	}
 
 	if fdoTestID == testcom.FIDO_DEVT_32_BAD_SIGNATURE {
-		proveToRV32.Signature = fdoshared.Conf_RandomCborBufferFuzzing(proveToRV32.Signature)
+		proveToRV32.Signature, fuzzMutation = fdoshared.Conf_RandomOpaqueBytesFuzzing(rnd, proveToRV32.Signature)
 	}
 
 	proveToRV32Bytes, err := fdoshared.CborCust.Marshal(proveToRV32)
@@ -50,7 +50,10 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 	}
 
 	if fdoTestID == testcom.FIDO_DEVT_32_BAD_ENCODING {
-		proveToRV32Bytes = fdoshared.Conf_RandomCborBufferFuzzing(proveToRV32Bytes)
+		proveToRV32Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, proveToRV32)
+		if err != nil {
+			return nil, nil, errors.New("ProveToRV32: Error fuzzing proveToRV32. " + err.Error())
+		}
 	}
 
 	var rvRedirect33 fdoshared.CoseSignature
@@ -58,6 +61,7 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.rvEntry, fdoshared.TO1_32_PROVE_TO_RV, proveToRV32Bytes, &h.authzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return &rvRedirect33, &testState, nil
 	}
 
@@ -8,16 +8,34 @@ import (
 type To1Requestor struct {
 	rvEntry     fdoshared.SRVEntry
 	credential  fdoshared.WawDeviceCredential
+	credStore   fdoshared.DeviceCredStore
 	authzHeader string
+	confSeed    int64
 }
 
 func NewTo1Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential) To1Requestor {
 	return To1Requestor{
 		rvEntry:    srvEntry,
 		credential: credential,
+		credStore:  fdoshared.NewDeviceCredStore(credential),
+		confSeed:   fdoshared.NewConf_Seed(),
 	}
 }
 
+// Sets store for the device secrets, e.g. for the sealed credentials
+func (h *To1Requestor) SetCredStore(credStore fdoshared.DeviceCredStore) {
+	h.credStore = credStore
+}
+
+// Sets test run seed, that drives test fuzzing
+func (h *To1Requestor) SetConfSeed(seed int64) {
+	h.confSeed = seed
+}
+
+func (h *To1Requestor) confRand(fdoTestID testcom.FDOTestID) *fdoshared.Conf_Rand {
+	return fdoshared.Conf_DeriveRand(h.confSeed, string(fdoTestID))
+}
+
 func (h *To1Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
 	switch fdoTestID {
 
@@ -12,11 +12,12 @@ import (
 
 func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO2ProveOVHdrPayload, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
 
 	h.NonceTO2ProveOV60 = fdoshared.NewFdoNonce()
 
 	helloDevice60 := fdoshared.HelloDevice60{
-		MaxDeviceMessageSize: MaxDeviceMessageSize,
+		MaxDeviceMessageSize: h.MaxDeviceMessageSize,
 		Guid:                 h.Credential.DCGuid,
 		NonceTO2ProveOV:      h.NonceTO2ProveOV60,
 		KexSuiteName:         h.KexSuiteName,
@@ -29,13 +30,18 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		return nil, nil, errors.New("HelloDevice60: Error marshaling HelloDevice60. " + err.Error())
 	}
 
-	if fdoTestID == testcom.FIDO_DOT_60_POSITIVE {
-		helloDevice60Byte = fdoshared.Conf_RandomCborBufferFuzzing(helloDevice60Byte)
+	var fuzzMutation *fdoshared.Conf_CborMutation
+	if fdoTestID == testcom.FIDO_DOT_60_BAD_ENCODING {
+		helloDevice60Byte, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, helloDevice60)
+		if err != nil {
+			return nil, nil, errors.New("HelloDevice60: Error fuzzing HelloDevice60. " + err.Error())
+		}
 	}
 
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_60_HELLO_DEVICE, helloDevice60Byte, &h.SrvEntry.AccessToken)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -46,7 +52,7 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 	if httpStatusCode != http.StatusOK {
 		fdoErrInst, err := fdoshared.DecodeErrorResponse(resultBytes)
 		if err == nil {
-			return nil, nil, fmt.Errorf("HelloDevice60: %s", fdoErrInst.EMErrorStr)
+			return nil, nil, fmt.Errorf("HelloDevice60: %w. %s", ErrFdoErrorResponse, fdoErrInst.EMErrorStr)
 		}
 	}
 
@@ -86,7 +92,7 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		return nil, nil, errors.New("HelloDevice60: DO returned wrong NonceTO2ProveOV")
 	}
 
-	err = fdoshared.VerifyHMac(proveOvdrPayload.OVHeader, proveOvdrPayload.HMac, h.Credential.DCHmacSecret)
+	err = h.CredStore.VerifyHmac(proveOvdrPayload.OVHeader, proveOvdrPayload.HMac)
 	if err != nil {
 		return nil, nil, errors.New("HelloDevice60: Unknown Header HMac. " + err.Error())
 	}
@@ -100,6 +106,11 @@ func (h *To2Requestor) HelloDevice60(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		return nil, nil, errors.New("HelloDevice60: Failed to verify hello device Hash")
 	}
 
+	err = fdoshared.ValidateKeyExchange(h.KexSuiteName, proveOvdrPayload.XAKeyExchange, true)
+	if err != nil {
+		return nil, nil, errors.New("HelloDevice60: Bad XAKeyExchange. " + err.Error())
+	}
+
 	h.NonceTO2ProveDv61 = *proveOVHdr61.Unprotected.CUPHNonce
 	h.XAKex = proveOvdrPayload.XAKeyExchange
 	h.OvHmac = proveOvdrPayload.HMac
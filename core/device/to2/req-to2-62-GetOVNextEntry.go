@@ -11,6 +11,8 @@ import (
 
 func (h *To2Requestor) GetOVNextEntry62(entryNum uint8, fdoTestID testcom.FDOTestID) (*fdoshared.OVNextEntry63, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 
 	getOVNextEntry := fdoshared.GetOVNextEntry62{
 		GetOVNextEntry: entryNum,
@@ -19,12 +21,17 @@ func (h *To2Requestor) GetOVNextEntry62(entryNum uint8, fdoTestID testcom.FDOTes
 	getOvNextEntryBytes, _ := fdoshared.CborCust.Marshal(getOVNextEntry)
 
 	if fdoTestID == testcom.FIDO_DOT_62_BAD_ENCODING {
-		getOvNextEntryBytes = fdoshared.Conf_RandomCborBufferFuzzing(getOvNextEntryBytes)
+		var err error
+		getOvNextEntryBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, getOVNextEntry)
+		if err != nil {
+			return nil, nil, errors.New("GetOVNextEntry62: Error fuzzing GetOVNextEntry62. " + err.Error())
+		}
 	}
 
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_62_GET_OVNEXTENTRY, getOvNextEntryBytes, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -12,6 +12,7 @@ import (
 // REQUESTOR
 func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO2SetupDevicePayload, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
 
 	// KEX
 	kex, err := fdoshared.GenerateXABKeyExchange(h.KexSuiteName, &h.ProveOVHdr61PubKey)
@@ -35,6 +36,13 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		XBKeyExchange: h.XBKEXParams.XAKeyExchange,
 	}
 
+	if fdoTestID == testcom.FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP {
+		to2ProveDevicePayload.XBKeyExchange, err = fdoshared.Conf_NewXBKeyExchange_BadOAEP(rnd, h.ProveOVHdr61PubKey)
+		if err != nil {
+			return nil, nil, errors.New("ProveDevice64: Error generating bad OAEP XBKeyExchange... " + err.Error())
+		}
+	}
+
 	// EAT Payload
 	eatPayload := fdoshared.EATPayloadBase{
 		EatNonce: h.NonceTO2ProveDv61,
@@ -46,26 +54,22 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		eatPayload.EatNonce = fdoshared.NewFdoNonce()
 	}
 
+	var fuzzMutation *fdoshared.Conf_CborMutation
 	eatPayloadBytes, _ := fdoshared.CborCust.Marshal(eatPayload)
 	if fdoTestID == testcom.FIDO_DOT_64_BAD_NONCE_PROVEDV61 {
-		eatPayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(eatPayloadBytes)
-	}
-
-	// Private key
-	privateKeyInst, err := fdoshared.ExtractPrivateKey(h.Credential.DCPrivateKeyDer)
-	if err != nil {
-		return nil, nil, errors.New("ProveDevice64: Error extract private key... " + err.Error())
+		// EAT payload is a map, so it is replaced as opaque bytes
+		eatPayloadBytes, fuzzMutation = fdoshared.Conf_RandomOpaqueBytesFuzzing(rnd, eatPayloadBytes)
 	}
 
 	// EAT and exchange
-	proveDevice, err := fdoshared.GenerateCoseSignature(eatPayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{EUPHNonce: &h.NonceTO2SetupDv64}, privateKeyInst, h.Credential.DCSigInfo.SgType)
+	proveDevice, err := h.CredStore.Sign(eatPayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{EUPHNonce: &h.NonceTO2SetupDv64}, h.Credential.DCSigInfo.SgType)
 	if err != nil {
 		return nil, nil, errors.New("ProveDevice64: Error generating device EAT... " + err.Error())
 
 	}
 
 	if fdoTestID == testcom.FIDO_DOT_64_BAD_SIGNATURE {
-		proveDevice.Signature = fdoshared.Conf_RandomCborBufferFuzzing(proveDevice.Signature)
+		proveDevice.Signature, fuzzMutation = fdoshared.Conf_RandomOpaqueBytesFuzzing(rnd, proveDevice.Signature)
 	}
 
 	proveDeviceBytes, _ := fdoshared.CborCust.Marshal(proveDevice)
@@ -73,6 +77,7 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
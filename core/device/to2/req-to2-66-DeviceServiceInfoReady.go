@@ -11,6 +11,7 @@ import (
 
 func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*fdoshared.OwnerServiceInfoReady67, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
 
 	deviceSrvInfoReady := fdoshared.DeviceServiceInfoReady66{
 		ReplacementHMac:       &h.OvHmac,
@@ -23,8 +24,13 @@ func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*f
 
 	deviceSrvInfoReadyBytes, _ := fdoshared.CborCust.Marshal(deviceSrvInfoReady)
 
+	var fuzzMutation *fdoshared.Conf_CborMutation
 	if fdoTestID == testcom.FIDO_DOT_66_BAD_SRVINFO_PAYLOAD {
-		deviceSrvInfoReadyBytes = fdoshared.Conf_RandomCborBufferFuzzing(deviceSrvInfoReadyBytes)
+		var err error
+		deviceSrvInfoReadyBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, deviceSrvInfoReady)
+		if err != nil {
+			return nil, nil, errors.New("DeviceServiceInfoReady66: Error fuzzing DeviceServiceInfoReady66. " + err.Error())
+		}
 	}
 
 	deviceSrvInfoReadyBytesEnc, err := fdoshared.AddEncryptionWrapping(deviceSrvInfoReadyBytes, h.SessionKey, h.CipherSuiteName)
@@ -33,7 +39,7 @@ func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*f
 	}
 
 	if fdoTestID == testcom.FIDO_DOT_66_BAD_ENCRYPTION {
-		deviceSrvInfoReadyBytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(deviceSrvInfoReadyBytesEnc, h.SessionKey, h.CipherSuiteName)
+		deviceSrvInfoReadyBytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, deviceSrvInfoReadyBytesEnc, h.SessionKey, h.CipherSuiteName)
 		if err != nil {
 			return nil, nil, errors.New("DeviceServiceInfoReady66: Error encrypting... " + err.Error())
 		}
@@ -42,6 +48,7 @@ func (h *To2Requestor) DeviceServiceInfoReady66(fdoTestID testcom.FDOTestID) (*f
 	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, deviceSrvInfoReadyBytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -11,11 +11,17 @@ import (
 
 func (h *To2Requestor) DeviceServiceInfo68(deviceServiceInfo68 fdoshared.DeviceServiceInfo68, fdoTestID testcom.FDOTestID) (*fdoshared.OwnerServiceInfo69, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 
 	deviceServiceInfo68Bytes, _ := fdoshared.CborCust.Marshal(deviceServiceInfo68)
 
 	if fdoTestID == testcom.FIDO_DOT_68_BAD_ENCODING {
-		deviceServiceInfo68Bytes = fdoshared.Conf_RandomCborBufferFuzzing(deviceServiceInfo68Bytes)
+		var err error
+		deviceServiceInfo68Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, deviceServiceInfo68)
+		if err != nil {
+			return nil, nil, errors.New("DeviceServiceInfo68: Error fuzzing DeviceServiceInfo68. " + err.Error())
+		}
 	}
 
 	deviceServiceInfo68BytesEnc, err := fdoshared.AddEncryptionWrapping(deviceServiceInfo68Bytes, h.SessionKey, h.CipherSuiteName)
@@ -24,7 +30,7 @@ func (h *To2Requestor) DeviceServiceInfo68(deviceServiceInfo68 fdoshared.DeviceS
 	}
 
 	if fdoTestID == testcom.FIDO_DOT_68_BAD_ENCRYPTION {
-		deviceServiceInfo68BytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(deviceServiceInfo68BytesEnc, h.SessionKey, h.CipherSuiteName)
+		deviceServiceInfo68BytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, deviceServiceInfo68BytesEnc, h.SessionKey, h.CipherSuiteName)
 		if err != nil {
 			return nil, nil, errors.New("DeviceServiceInfo68: Error encrypting... " + err.Error())
 		}
@@ -33,6 +39,7 @@ func (h *To2Requestor) DeviceServiceInfo68(deviceServiceInfo68 fdoshared.DeviceS
 	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_68_DEVICE_SERVICE_INFO, deviceServiceInfo68BytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
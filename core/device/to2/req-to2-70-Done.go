@@ -12,6 +12,8 @@ import (
 
 func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 
 	done70 := fdoshared.Done70{
 		NonceTO2ProveDv: h.NonceTO2ProveDv61,
@@ -24,7 +26,11 @@ func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271,
 	done70Bytes, _ := fdoshared.CborCust.Marshal(done70)
 
 	if fdoTestID == testcom.FIDO_DOT_70_BAD_ENCODING {
-		done70Bytes = fdoshared.Conf_RandomCborBufferFuzzing(done70Bytes)
+		var err error
+		done70Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, done70)
+		if err != nil {
+			return nil, nil, errors.New("Done70: Error fuzzing Done70. " + err.Error())
+		}
 	}
 
 	done70BytesEnc, err := fdoshared.AddEncryptionWrapping(done70Bytes, h.SessionKey, h.CipherSuiteName)
@@ -33,7 +39,7 @@ func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271,
 	}
 
 	if fdoTestID == testcom.FIDO_DOT_70_BAD_ENCRYPTION {
-		done70BytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(done70BytesEnc, h.SessionKey, h.CipherSuiteName)
+		done70BytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, done70BytesEnc, h.SessionKey, h.CipherSuiteName)
 		if err != nil {
 			return nil, nil, errors.New("DeviceServiceInfoReady66: Error encrypting... " + err.Error())
 		}
@@ -42,6 +48,7 @@ func (h *To2Requestor) Done70(fdoTestID testcom.FDOTestID) (*fdoshared.Done271,
 	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_70_DONE, done70BytesEnc, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -1,19 +1,26 @@
 package to2
 
 import (
+	"errors"
+
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
 
-var MaxDeviceMessageSize uint16 = 2048
+// Owner responded with FDO error, e.g. rejected the negotiated suites
+var ErrFdoErrorResponse = errors.New("owner responded with FDO error")
+
 var MaxOwnerServiceInfoSize uint16 = 2048
 
 type To2Requestor struct {
 	SrvEntry        fdoshared.SRVEntry
 	Credential      fdoshared.WawDeviceCredential
+	CredStore       fdoshared.DeviceCredStore
 	KexSuiteName    fdoshared.KexSuiteName
 	CipherSuiteName fdoshared.CipherSuiteName
 
+	MaxDeviceMessageSize uint16
+
 	AuthzHeader string
 	SessionKey  fdoshared.SessionKeyInfo
 	XAKex       []byte
@@ -33,17 +40,26 @@ type To2Requestor struct {
 	CredentialReuse bool
 
 	ReplacementCredential fdoshared.TO2SetupDevicePayload
+
+	ConfSeed int64
 }
 
-func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, kexSuitName fdoshared.KexSuiteName, cipherSuitName fdoshared.CipherSuiteName) To2Requestor {
+func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, kexSuitName fdoshared.KexSuiteName, cipherSuitName fdoshared.CipherSuiteName, config *fdoshared.Config) To2Requestor {
 	return To2Requestor{
-		SrvEntry:        srvEntry,
-		Credential:      credential,
-		KexSuiteName:    kexSuitName,
-		CipherSuiteName: cipherSuitName,
+		SrvEntry:             srvEntry,
+		Credential:           credential,
+		CredStore:            fdoshared.NewDeviceCredStore(credential),
+		KexSuiteName:         kexSuitName,
+		CipherSuiteName:      cipherSuitName,
+		MaxDeviceMessageSize: config.Device.MaxMessageSize,
+		ConfSeed:             fdoshared.NewConf_Seed(),
 	}
 }
 
+func (h *To2Requestor) confRand(fdoTestID testcom.FDOTestID) *fdoshared.Conf_Rand {
+	return fdoshared.Conf_DeriveRand(h.ConfSeed, string(fdoTestID))
+}
+
 func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
 	switch fdoTestID {
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_60, fdoTestID):
@@ -4,18 +4,20 @@ import (
 	"errors"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/google/uuid"
 )
 
 type SessionDB struct {
-	db *badger.DB
+	db  kv.Store
+	ttl time.Duration
 }
 
-func NewSessionDB(db *badger.DB) *SessionDB {
+func NewSessionDB(db kv.Store, ttl time.Duration) *SessionDB {
 	return &SessionDB{
-		db: db,
+		db:  db,
+		ttl: ttl,
 	}
 }
 
@@ -54,6 +56,82 @@ type SessionEntry struct {
 
 	// Conformance testing
 	RequestedOVEntries []uint8
+
+	// Correlation ID of the session, derived in HelloDevice60, and logged for every message
+	Cid uint
+}
+
+func (h SessionEntry) SchemaVersion() uint16 {
+	return 2
+}
+
+// Before correlation ID
+type SessionEntryV1 struct {
+	_        struct{} `cbor:",toarray"`
+	Protocol fdoshared.FdoToProtocol
+	PrevCMD  fdoshared.FdoCmd
+
+	// Session encryption key
+	SessionKey   fdoshared.SessionKeyInfo
+	XAKex        fdoshared.KeXParams
+	KexSuiteName fdoshared.KexSuiteName
+
+	NonceTO2ProveOV60 fdoshared.FdoNonce
+	NonceTO2ProveDv61 fdoshared.FdoNonce
+	NonceTO2SetupDv64 fdoshared.FdoNonce
+
+	EASigInfo       fdoshared.SigInfo
+	PrivateKeyDER   []byte
+	CipherSuiteName fdoshared.CipherSuiteName
+	PublicKeyType   fdoshared.FdoPkType
+	SignatureSgType fdoshared.DeviceSgType
+	Guid            fdoshared.FdoGuid
+	Voucher         fdoshared.OwnershipVoucher
+
+	NumOVEntries uint8
+
+	MaxDeviceServiceInfoSz                  uint16
+	ServiceInfoMsgNo                        uint8
+	OwnerServiceInfoIsMoreServiceInfoIsTrue bool
+
+	DeviceSIMs               []fdoshared.ServiceInfoKV
+	OwnerSIMsSendCounter     uint16
+	OwnerSIMsFinishedSending bool
+	OwnerSIMs                []fdoshared.ServiceInfoKV
+
+	// Conformance testing
+	RequestedOVEntries []uint8
+}
+
+// Sessions from before the correlation ID get a new one
+func MigrateSessionEntryV1(oldEntry SessionEntryV1) (SessionEntry, error) {
+	return SessionEntry{
+		Protocol:                                oldEntry.Protocol,
+		PrevCMD:                                 oldEntry.PrevCMD,
+		SessionKey:                              oldEntry.SessionKey,
+		XAKex:                                   oldEntry.XAKex,
+		KexSuiteName:                            oldEntry.KexSuiteName,
+		NonceTO2ProveOV60:                       oldEntry.NonceTO2ProveOV60,
+		NonceTO2ProveDv61:                       oldEntry.NonceTO2ProveDv61,
+		NonceTO2SetupDv64:                       oldEntry.NonceTO2SetupDv64,
+		EASigInfo:                               oldEntry.EASigInfo,
+		PrivateKeyDER:                           oldEntry.PrivateKeyDER,
+		CipherSuiteName:                         oldEntry.CipherSuiteName,
+		PublicKeyType:                           oldEntry.PublicKeyType,
+		SignatureSgType:                         oldEntry.SignatureSgType,
+		Guid:                                    oldEntry.Guid,
+		Voucher:                                 oldEntry.Voucher,
+		NumOVEntries:                            oldEntry.NumOVEntries,
+		MaxDeviceServiceInfoSz:                  oldEntry.MaxDeviceServiceInfoSz,
+		ServiceInfoMsgNo:                        oldEntry.ServiceInfoMsgNo,
+		OwnerServiceInfoIsMoreServiceInfoIsTrue: oldEntry.OwnerServiceInfoIsMoreServiceInfoIsTrue,
+		DeviceSIMs:                              oldEntry.DeviceSIMs,
+		OwnerSIMsSendCounter:                    oldEntry.OwnerSIMsSendCounter,
+		OwnerSIMsFinishedSending:                oldEntry.OwnerSIMsFinishedSending,
+		OwnerSIMs:                               oldEntry.OwnerSIMs,
+		RequestedOVEntries:                      oldEntry.RequestedOVEntries,
+		Cid:                                     fdoshared.NewCID(),
+	}, nil
 }
 
 // Conformance
@@ -75,24 +153,10 @@ func (h *SessionEntry) Conf_RequestedOVEntriesContain(entryNum uint8) bool {
 }
 
 func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
-	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
-
 	randomEntryId, _ := uuid.NewRandom()
-	sessionEntryId := []byte("session-" + randomEntryId.String())
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(time.Minute * 10) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
-	}
+	sessionEntryId := []byte("dosession-" + randomEntryId.String())
 
-	dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, h.ttl)
 	if err != nil {
 		return []byte{}, errors.New("Failed saving session entry. The error is: " + err.Error())
 	}
@@ -101,22 +165,9 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 }
 
 func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry) error {
-	sessionEntryId := append([]byte("session-"), entryId...)
+	sessionEntryId := append([]byte("dosession-"), entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Set(sessionEntryId, sessionInstBytes)
-	if err != nil {
-		return errors.New("Failed to create saving inst. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, 0)
 	if err != nil {
 		return errors.New("Failed to save session. The error is: " + err.Error())
 	}
@@ -125,30 +176,14 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 }
 
 func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
-	sessionEntryId := append([]byte("session-"), entryId...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	sessionEntryId := append([]byte("dosession-"), entryId...)
 
-	item, err := dbtxn.Get(sessionEntryId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	sessionEntryInst, err := kv.GetCbor[SessionEntry](h.db, sessionEntryId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, nil
 	} else if err != nil {
-		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
-	var sessionEntryInst SessionEntry
-
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
-	if err != nil {
-
-		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading session entry. The error is: " + err.Error())
 	}
 
-	return &sessionEntryInst, nil
+	return sessionEntryInst, nil
 }
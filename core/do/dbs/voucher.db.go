@@ -5,16 +5,16 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 )
 
 type VoucherDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
-func NewVoucherDB(db *badger.DB) *VoucherDB {
+func NewVoucherDB(db kv.Store) *VoucherDB {
 	return &VoucherDB{
 		db:     db,
 		prefix: []byte("voucher-"),
@@ -26,26 +26,12 @@ func (h VoucherDB) getEntryID(guid fdoshared.FdoGuid) []byte {
 }
 
 func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
-	voucherDBBytes, err := fdoshared.CborCust.Marshal(voucherDBEntry)
-	if err != nil {
-		return errors.New("Failed to marshal voucher. " + err.Error())
-	}
-
 	ovHeader, err := voucherDBEntry.Voucher.GetOVHeader()
 	if err != nil {
 		return errors.New("Failed to get voucher header. " + err.Error())
 	}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(h.getEntryID(ovHeader.OVGuid), voucherDBBytes)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating voucherDB entry instance. " + err.Error())
-	}
-
-	dbtxn.Commit()
+	err = kv.SetCbor(h.db, h.getEntryID(ovHeader.OVGuid), voucherDBEntry, 0)
 	if err != nil {
 		return errors.New("Failed saving voucherDB entry. " + err.Error())
 	}
@@ -54,56 +40,35 @@ func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
 }
 
 func (h *VoucherDB) Get(deviceGuid fdoshared.FdoGuid) (*fdoshared.VoucherDBEntry, error) {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(h.getEntryID(deviceGuid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	voucherDBEInst, err := kv.GetCbor[fdoshared.VoucherDBEntry](h.db, h.getEntryID(deviceGuid))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The voucher entry for GUID(%s) does not exist", hex.EncodeToString(deviceGuid[:]))
 	} else if err != nil {
-		return nil, errors.New("Failed locating voucher entry. " + err.Error())
+		return nil, errors.New("Failed reading voucherdb entry. " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading voucherdb entry value. " + err.Error())
-	}
-
-	var voucherDBEInst fdoshared.VoucherDBEntry
-
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &voucherDBEInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding voucherdb entry " + err.Error())
-	}
-
-	return &voucherDBEInst, nil
+	return voucherDBEInst, nil
 }
 
 func (h *VoucherDB) List() ([]fdoshared.FdoGuid, error) {
 	var result []fdoshared.FdoGuid = []fdoshared.FdoGuid{}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	// Create an iterator to iterate over all key-value pairs
-	it := dbtxn.NewIterator(badger.DefaultIteratorOptions)
-	defer it.Close()
-
-	// Iterate over all key-value pairs and add the keys to the list
-	for it.Seek(h.prefix); it.ValidForPrefix(h.prefix); it.Next() {
-		item := it.Item()
-		keyBytes := item.KeyCopy(nil)
-
+	// Iterate over all vouchers and add the guids to the list
+	err := h.db.Iterate(h.prefix, func(keyBytes []byte, value []byte) error {
 		guidBytes := keyBytes[len(h.prefix):]
 
 		if len(guidBytes) != 16 {
-			return nil, errors.New("invalid voucherdb entry key length")
+			return errors.New("invalid voucherdb entry key length")
 		}
 
 		var guid fdoshared.FdoGuid
 		guid.FromBytes(guidBytes[:])
 
 		result = append(result, guid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
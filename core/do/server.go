@@ -1,20 +1,26 @@
 package do
 
 import (
-	"context"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
 )
 
-func SetupServer(db *badger.DB, ctx context.Context) {
-	doto2 := to2.NewDoTo2(db, ctx)
+func SetupServer(db kv.Store, config *fdoshared.Config) {
+	doto2 := to2.NewDoTo2(db, config)
 
-	http.HandleFunc("/fdo/101/msg/60", doto2.HelloDevice60)
-	http.HandleFunc("/fdo/101/msg/62", doto2.GetOVNextEntry62)
-	http.HandleFunc("/fdo/101/msg/64", doto2.ProveDevice64)
-	http.HandleFunc("/fdo/101/msg/66", doto2.DeviceServiceInfoReady66)
-	http.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
-	http.HandleFunc("/fdo/101/msg/70", doto2.Done70)
+	http.HandleFunc("/fdo/101/msg/60", fdoHandler(fdoshared.TO2_60_HELLO_DEVICE, doto2.HelloDevice60))
+	http.HandleFunc("/fdo/101/msg/62", fdoHandler(fdoshared.TO2_62_GET_OVNEXTENTRY, doto2.GetOVNextEntry62))
+	http.HandleFunc("/fdo/101/msg/64", fdoHandler(fdoshared.TO2_64_PROVE_DEVICE, doto2.ProveDevice64))
+	http.HandleFunc("/fdo/101/msg/66", fdoHandler(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, doto2.DeviceServiceInfoReady66))
+	http.HandleFunc("/fdo/101/msg/68", fdoHandler(fdoshared.TO2_68_DEVICE_SERVICE_INFO, doto2.DeviceServiceInfo68))
+	http.HandleFunc("/fdo/101/msg/70", fdoHandler(fdoshared.TO2_70_DONE, doto2.Done70))
+}
+
+// TO2 messages get request log with the correlation ID, and are counted in the metrics
+func fdoHandler(cmd fdoshared.FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	return metrics.InstrumentFdoHandler(metrics.SERVICE_DO, cmd, fdoshared.WithRequestLog(cmd, handler))
 }
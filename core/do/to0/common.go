@@ -1,7 +1,6 @@
 package to0
 
 import (
-	"context"
 	"fmt"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -12,21 +11,30 @@ type To0Requestor struct {
 	srvEntry       fdoshared.SRVEntry
 	voucherDBEntry fdoshared.VoucherDBEntry
 	authzHeader    string
-	ctx            context.Context
+	config         *fdoshared.Config
+	confSeed       int64
 }
 
-func NewTo0Requestor(rvEntry fdoshared.SRVEntry, voucherDBEntry fdoshared.VoucherDBEntry, ctx context.Context) To0Requestor {
+func NewTo0Requestor(rvEntry fdoshared.SRVEntry, voucherDBEntry fdoshared.VoucherDBEntry, config *fdoshared.Config) To0Requestor {
 	return To0Requestor{
 		srvEntry:       rvEntry,
 		voucherDBEntry: voucherDBEntry,
-		ctx:            ctx,
+		config:         config,
+		confSeed:       fdoshared.NewConf_Seed(),
 	}
 }
 
-const ServerWaitSeconds uint32 = 30 * 24 * 60 * 60 // 1 month
+// Sets test run seed, that drives test fuzzing
+func (h *To0Requestor) SetConfSeed(seed int64) {
+	h.confSeed = seed
+}
+
+func (h *To0Requestor) confRand(fdoTestID testcom.FDOTestID) *fdoshared.Conf_Rand {
+	return fdoshared.Conf_DeriveRand(h.confSeed, string(fdoTestID))
+}
 
 func (h *To0Requestor) getRVTO2AddrEntry() (*fdoshared.RVTO2AddrEntry, error) {
-	servUrl := h.ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string)
+	servUrl := h.config.FdoServiceUrl
 	if servUrl == "" {
 		return nil, fmt.Errorf("getRVTO2AddrEntry: FDO service URL not set")
 	}
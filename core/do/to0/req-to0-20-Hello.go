@@ -10,6 +10,8 @@ import (
 
 func (h *To0Requestor) Hello20(fdoTestID testcom.FDOTestID) (*fdoshared.HelloAck21, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestID)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 	var helloAck21 fdoshared.HelloAck21
 
 	hello20Bytes, err := fdoshared.CborCust.Marshal(fdoshared.Hello20{})
@@ -18,12 +20,16 @@ func (h *To0Requestor) Hello20(fdoTestID testcom.FDOTestID) (*fdoshared.HelloAck
 	}
 
 	if fdoTestID == testcom.FIDO_RVT_20_BAD_ENCODING {
-		hello20Bytes = fdoshared.Conf_RandomCborBufferFuzzing(hello20Bytes)
+		hello20Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, fdoshared.Hello20{})
+		if err != nil {
+			return nil, nil, errors.New("Hell20: Error fuzzing Hello20. " + err.Error())
+		}
 	}
 
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.srvEntry, fdoshared.TO0_20_HELLO, hello20Bytes, &h.srvEntry.AccessToken)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -3,7 +3,7 @@ package to0
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
@@ -11,11 +11,13 @@ import (
 
 func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId testcom.FDOTestID) (*fdoshared.AcceptOwner23, *testcom.FDOTestState, error) {
 	var testState testcom.FDOTestState
+	rnd := h.confRand(fdoTestId)
+	var fuzzMutation *fdoshared.Conf_CborMutation
 	var acceptOwner23 fdoshared.AcceptOwner23
 
 	var to0d fdoshared.To0d = fdoshared.To0d{
 		OwnershipVoucher: h.voucherDBEntry.Voucher,
-		WaitSeconds:      ServerWaitSeconds,
+		WaitSeconds:      h.config.Do.To0WaitSeconds,
 		NonceTO0Sign:     nonceTO0Sign,
 	}
 
@@ -29,7 +31,10 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 	}
 
 	if fdoTestId == testcom.FIDO_RVT_22_BAD_TO0D_ENCODING {
-		to0dBytes = fdoshared.Conf_RandomCborBufferFuzzing(to0dBytes)
+		to0dBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, to0d)
+		if err != nil {
+			return nil, nil, errors.New("OwnerSign22: Error fuzzing To0d. " + err.Error())
+		}
 	}
 
 	deviceHashAlg := fdoshared.HmacToHashAlg[h.voucherDBEntry.Voucher.OVHeaderHMac.Type]
@@ -39,7 +44,7 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 	}
 
 	if fdoTestId == testcom.FIDO_RVT_22_BAD_TO0D_HASH {
-		to0dHash = *fdoshared.Conf_RandomTestHashHmac(to0dHash, to0dBytes, []byte{})
+		to0dHash = *fdoshared.Conf_RandomTestHashHmac(rnd, to0dHash, to0dBytes, []byte{})
 	}
 
 	rvTo2AddrEntry, err := h.getRVTO2AddrEntry()
@@ -70,14 +75,14 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 		lastOvEntryPubKeyPkType = lastOvEntryPubKey.PkType
 	}
 
-	privateKeyInst, err := fdoshared.ExtractPrivateKey(h.voucherDBEntry.PrivateKeyX509)
+	privateKeyInst, err := fdoshared.LoadOwnerKey(h.voucherDBEntry.PrivateKeyX509)
 	if err != nil {
 		return nil, nil, errors.New("OwnerSign22: Error extracting private key. " + err.Error())
 	}
 
-	sgType, err := fdoshared.GetDeviceSgType(lastOvEntryPubKeyPkType, deviceHashAlg)
+	sgType, err := fdoshared.GetPrivateKeySgType(lastOvEntryPubKeyPkType, privateKeyInst)
 	if err != nil {
-		return nil, nil, errors.New("OwnerSign22: Error getting device SgType. " + err.Error())
+		return nil, nil, errors.New("OwnerSign22: Error getting owner SgType. " + err.Error())
 	}
 
 	to1d, err := fdoshared.GenerateCoseSignature(to1dPayloadBytes, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, privateKeyInst, sgType)
@@ -86,7 +91,7 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 	}
 
 	if fdoTestId == testcom.FIDO_RVT_22_BAD_SIGNATURE {
-		to1d.Signature = fdoshared.Conf_RandomCborBufferFuzzing(to1d.Signature)
+		to1d.Signature, fuzzMutation = fdoshared.Conf_RandomOpaqueBytesFuzzing(rnd, to1d.Signature)
 	}
 
 	var ownerSign fdoshared.OwnerSign22 = fdoshared.OwnerSign22{
@@ -100,12 +105,16 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 	}
 
 	if fdoTestId == testcom.FIDO_RVT_22_BAD_OWNERSIGN_ENCODING {
-		ownerSign22Bytes = fdoshared.Conf_RandomCborBufferFuzzing(ownerSign22Bytes)
+		ownerSign22Bytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, ownerSign)
+		if err != nil {
+			return nil, nil, errors.New("OwnerSign22: Error fuzzing OwnerSign22. " + err.Error())
+		}
 	}
 
 	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.srvEntry, fdoshared.TO0_22_OWNER_SIGN, ownerSign22Bytes, &h.authzHeader)
 	if fdoTestId != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestId, httpStatusCode)
+		testState.SetFuzzMutation(fuzzMutation)
 		return nil, &testState, nil
 	}
 
@@ -130,15 +139,17 @@ func (h *To0Requestor) OwnerSign22(nonceTO0Sign fdoshared.FdoNonce, fdoTestId te
 	}
 
 	voucherHeader, _ := h.voucherDBEntry.Voucher.GetOVHeader()
-	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
-		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopDO)
+	if fdoTestId == testcom.NULL_TEST && h.config.Interop.Enabled() {
+		iopLogger := slog.Default().With(fdoshared.LOG_FIELD_CMD, fdoshared.TO0_22_OWNER_SIGN.ToString(), fdoshared.LOG_FIELD_GUID, voucherHeader.OVGuid.GetFormattedHex())
+
+		authzHeader, err := fdoshared.IopGetAuthz(h.config.Interop, fdoshared.IopDO)
 		if err != nil {
-			log.Println("OwnerSign22: Error getting authz header: " + err.Error())
+			iopLogger.Error("OwnerSign22: Error getting authz header", "error", err)
 		}
 
-		err = fdoshared.SubmitIopLoggerEvent(h.ctx, voucherHeader.OVGuid, fdoshared.To0, nonceTO0Sign, authzHeader)
+		err = fdoshared.SubmitIopLoggerEvent(h.config.Interop, voucherHeader.OVGuid, fdoshared.To0, nonceTO0Sign, authzHeader)
 		if err != nil {
-			log.Println("OwnerSign22: Error sending iop logg event: " + err.Error())
+			iopLogger.Error("OwnerSign22: Error sending iop logger event", "error", err)
 		}
 	}
 
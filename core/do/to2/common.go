@@ -1,17 +1,15 @@
 package to2
 
 import (
-	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
@@ -22,19 +20,19 @@ type DoTo2 struct {
 	session    *dbs.SessionDB
 	voucher    *dbs.VoucherDB
 	listenerDB *tdbs.ListenerTestDB
-	ctx        context.Context
+	config     *fdoshared.Config
 }
 
-func NewDoTo2(db *badger.DB, ctx context.Context) DoTo2 {
+func NewDoTo2(db kv.Store, config *fdoshared.Config) DoTo2 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
-	sessionDb := dbs.NewSessionDB(db)
+	sessionDb := dbs.NewSessionDB(db, config.Do.SessionTtl)
 	voucherDb := dbs.NewVoucherDB(db)
 
 	return DoTo2{
 		session:    sessionDb,
 		voucher:    voucherDb,
 		listenerDB: newListenerDb,
-		ctx:        ctx,
+		config:     config,
 	}
 }
 
@@ -55,10 +53,8 @@ func ValidateDeviceSIMs(guid fdoshared.FdoGuid, sims []fdoshared.ServiceInfoKV)
 func (h *DoTo2) getEnvInteropSimsMapping() (map[fdoshared.FdoGuid]string, error) {
 	mappings := map[fdoshared.FdoGuid]string{}
 
-	iopEnabled := h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED)
-
-	if iopEnabled != nil && iopEnabled.(bool) {
-		rawTokens := h.ctx.Value(fdoshared.CFG_ENV_INTEROP_DO_TOKEN_MAPPING).(string)
+	if h.config.Interop.Enabled() {
+		rawTokens := h.config.Interop.DoTokenMapping
 
 		var envMappings [][]string
 		err := json.Unmarshal([]byte(rawTokens), &envMappings)
@@ -115,6 +111,12 @@ func (h *DoTo2) GetOwnerSIMs(guid fdoshared.FdoGuid) ([]fdoshared.ServiceInfoKV,
 	return ownerSims, nil
 }
 
+// Records the mutation applied to the response, so the test result carries its fuzzing seed
+func (h *DoTo2) confSaveFuzzMutation(testcomListener *listenertestsdeps.RequestListenerInst, fuzzMutation *fdoshared.Conf_CborMutation) error {
+	testcomListener.To2.SetFuzzMutation(fuzzMutation)
+	return h.listenerDB.Update(testcomListener)
+}
+
 func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, currentCmd fdoshared.FdoCmd) (*dbs.SessionEntry, []byte, string, []byte, *listenertestsdeps.RequestListenerInst, error) {
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO2_64_PROVE_DEVICE) {
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("Error checking header!")
@@ -131,10 +133,14 @@ func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, current
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("%d: Can not find session... %s", currentCmd, err.Error())
 	}
 
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.SetCID(session.Cid)
+	requestLog.With(fdoshared.LOG_FIELD_GUID, session.Guid.GetFormattedHex())
+
 	// Conformance
 	testcomListener, err := h.listenerDB.GetEntryByFdoGuid(session.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(session.Guid[:]), err.Error())
+		requestLog.Logger().Debug("No test case for guid", "error", err)
 	}
 
 	bodyBytes, err := io.ReadAll(r.Body)
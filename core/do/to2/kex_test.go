@@ -0,0 +1,73 @@
+package to2
+
+import (
+	"testing"
+
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Test device chain intermediate is SHA1 signed, and is rejected in ProveDevice64. Reissues device leaf by the test root
+func newTestDoTrustedCredential(t *testing.T) *fdoshared.WawDeviceCredential {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("failed to generate credential: %v", err)
+	}
+
+	privateKey, err := fdoshared.ExtractPrivateKey(credential.DCPrivateKeyDer)
+	if err != nil {
+		t.Fatalf("failed to decode credential key: %v", err)
+	}
+
+	credential.DCCertificateChain, err = fdoshared.NewTestOwnerCertificateChain(fdoshared.CastPublicFromPrivate(privateKey))
+	if err != nil {
+		t.Fatalf("failed to generate certificate chain: %v", err)
+	}
+
+	credential.DCCertificateChainHash, err = fdoshared.ComputeOVDevCertChainHash(credential.DCCertificateChain, credential.DCHashAlg)
+	if err != nil {
+		t.Fatalf("failed to hash certificate chain: %v", err)
+	}
+
+	return credential
+}
+
+func runTestTo2ProveDevice64(t *testing.T, serverUrl string, testCred fdoshared.DeviceCredAndVoucher, kexSuiteName fdoshared.KexSuiteName, fdoTestID testcom.FDOTestID) *testcom.FDOTestState {
+	config := fdoshared.DefaultConfig()
+	to2requestor := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{
+		SrvURL: serverUrl,
+	}, testCred.WawDeviceCredential, kexSuiteName, fdoshared.CIPHER_A128GCM, &config)
+
+	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+	if err != nil {
+		t.Fatalf("HelloDevice60 failed: %v", err)
+	}
+
+	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
+		_, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
+		if err != nil {
+			t.Fatalf("GetOVNextEntry62 failed: %v", err)
+		}
+	}
+
+	_, testState, err := to2requestor.ProveDevice64(fdoTestID)
+	if err != nil {
+		t.Fatalf("ProveDevice64 failed: %v", err)
+	}
+
+	return testState
+}
+
+func TestTo2ProveDevice64_AsymKex(t *testing.T) {
+	doto2, server := newTestDoServer(t)
+	testCred := newTestDoVoucherForCredential(t, doto2, newTestDoTrustedCredential(t), fdoshared.StRSA2048)
+
+	// Positive run first, so the rejection below is not caused by the attestation
+	runTestTo2ProveDevice64(t, server.URL, testCred, fdoshared.KEX_ASYMKEX2048, testcom.NULL_TEST)
+
+	testState := runTestTo2ProveDevice64(t, server.URL, testCred, fdoshared.KEX_ASYMKEX2048, testcom.FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP)
+	if !testState.Passed {
+		t.Errorf("Expected owner to reject bad OAEP xBKeyExchange. %s", testState.Error)
+	}
+}
@@ -1,10 +1,8 @@
 package to2
 
 import (
-	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
@@ -14,7 +12,8 @@ import (
 )
 
 func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
-	log.Println("HelloDevice60: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving HelloDevice60")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_60_HELLO_DEVICE
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
@@ -36,11 +35,14 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestLog.With(fdoshared.LOG_FIELD_GUID, helloDevice.Guid.GetFormattedHex())
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(helloDevice.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(helloDevice.Guid[:]), err.Error())
+		requestLog.Logger().Debug("No test case for guid", "error", err)
 	}
 
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
@@ -55,6 +57,8 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To2.GetConfRand(fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -71,6 +75,30 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	lastOwnerPubKey, err := voucherDBEntry.Voucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error getting last owner public key...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		return
+	}
+
+	// Negotiation
+	err = fdoshared.CheckKexSuiteOwnerKey(helloDevice.KexSuiteName, lastOwnerPubKey.PkType)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Unsupported kex suite. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+		return
+	}
+
+	err = fdoshared.CheckSgTypeEnabled(helloDevice.EASigInfo.SgType)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Unsupported sgType. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+		return
+	}
+
+	if _, ok := fdoshared.CipherSuitesInfoMap[helloDevice.CipherSuiteName]; !ok {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, fmt.Sprintf("Unsupported cipher suite %d", helloDevice.CipherSuiteName), http.StatusBadRequest, testcomListener, fdoshared.To2)
+		return
+	}
+
 	NonceTO2ProveDv := fdoshared.NewFdoNonce()
 
 	// KEX Generation
@@ -109,7 +137,7 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH {
-		proveOVHdrPayload.HelloDeviceHash = *fdoshared.Conf_RandomTestHashHmac(proveOVHdrPayload.HelloDeviceHash, bodyBytes, []byte{})
+		proveOVHdrPayload.HelloDeviceHash = *fdoshared.Conf_RandomTestHashHmac(rnd, proveOVHdrPayload.HelloDeviceHash, bodyBytes, []byte{})
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV {
@@ -117,26 +145,56 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO {
-		proveOVHdrPayload.EBSigInfo.SgType = fdoshared.Conf_NewRandomSgTypeExcept(proveOVHdrPayload.EBSigInfo.SgType)
+		proveOVHdrPayload.EBSigInfo.SgType = fdoshared.Conf_NewRandomSgTypeExcept(rnd, proveOVHdrPayload.EBSigInfo.SgType)
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER {
-		proveOVHdrPayload.OVHeader = fdoshared.Conf_RandomCborBufferFuzzing(proveOVHdrPayload.OVHeader)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		proveOVHdrPayload.OVHeader, fuzzMutation, err = fdoshared.Conf_RandomCborBytesFuzzing(rnd, proveOVHdrPayload.OVHeader)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz OVHeader!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
+	}
+
+	// KEX negative tests. Device must reject xAKeyExchange before requesting OV entries
+	var badXAKeyExchange []byte
+	switch fdoTestId {
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_OFF_CURVE:
+		badXAKeyExchange, err = fdoshared.Conf_NewXAKeyExchange_OffCurve(rnd, helloDevice.KexSuiteName)
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_WEAK_DH:
+		badXAKeyExchange, err = fdoshared.Conf_NewXAKeyExchange_WeakDH(rnd, helloDevice.KexSuiteName)
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_SHORT_RANDOM:
+		badXAKeyExchange, err = fdoshared.Conf_NewXAKeyExchange_ShortRandom(rnd, helloDevice.KexSuiteName)
+	case testcom.FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_KEX_SUITE:
+		badXAKeyExchange, err = fdoshared.Conf_NewXAKeyExchange_OtherSuite(rnd, helloDevice.KexSuiteName)
 	}
 
-	lastOwnerPubKey, err := voucherDBEntry.Voucher.GetFinalOwnerPublicKey()
 	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error getting last owner public key...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error generating test XAKeyExchange. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
 
+	if badXAKeyExchange != nil {
+		proveOVHdrPayload.XAKeyExchange = badXAKeyExchange
+	}
+
 	proveOVHdrUnprotectedHeader := fdoshared.UnprotectedHeader{
 		CUPHNonce:       &NonceTO2ProveDv,
 		CUPHOwnerPubKey: &lastOwnerPubKey,
 	}
 
-	signatureSgType, ok := fdoshared.PkToSgType[lastOwnerPubKey.PkType]
-	if !ok {
+	privateKeyInst, err := fdoshared.LoadOwnerKey(voucherDBEntry.PrivateKeyX509)
+	if err != nil {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error decoding private key...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+		return
+	}
+
+	signatureSgType, err := fdoshared.GetPrivateKeySgType(lastOwnerPubKey.PkType, privateKeyInst)
+	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Unsupported pkType...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
@@ -163,6 +221,8 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		OwnerSIMsFinishedSending: false,
 		OwnerSIMsSendCounter:     0,
 		OwnerSIMs:                []fdoshared.ServiceInfoKV{},
+
+		Cid: requestLog.CID(),
 	}
 
 	sessionId, err := h.session.NewSessionEntry(newSessionInst)
@@ -173,34 +233,46 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 
 	proveOVHdrPayloadBytes, _ := fdoshared.CborCust.Marshal(proveOVHdrPayload)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING {
-		proveOVHdrPayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(proveOVHdrPayloadBytes)
-	}
-
-	privateKeyInst, err := fdoshared.ExtractPrivateKey(voucherDBEntry.PrivateKeyX509)
-	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error decoding private key...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
-		return
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		proveOVHdrPayloadBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, proveOVHdrPayload)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz ProveOVHdr61 payload!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	helloAck, err := fdoshared.GenerateCoseSignature(proveOVHdrPayloadBytes, fdoshared.ProtectedHeader{}, proveOVHdrUnprotectedHeader, privateKeyInst, signatureSgType)
 	if err != nil {
-		log.Println("HelloDevice60: Error generating cose signature..." + err.Error())
+		requestLog.Logger().Error("HelloDevice60: Error generating cose signature", "error", err)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error generating cose signature.", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE {
-		fuzzedCoseSignature := fdoshared.Conf_Fuzz_CoseSignature(*helloAck)
+		fuzzedCoseSignature := fdoshared.Conf_Fuzz_CoseSignature(rnd, *helloAck)
 		helloAck = &fuzzedCoseSignature
 	}
 
 	helloAckBytes, _ := fdoshared.CborCust.Marshal(helloAck)
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING {
-		helloAckBytes = fdoshared.Conf_RandomCborBufferFuzzing(helloAckBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		helloAckBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, helloAck)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz ProveOVHdr61!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	sessionIdToken := "Bearer " + string(sessionId)
+	requestLog.With(fdoshared.LOG_FIELD_SESSION, fdoshared.SessionLogId(sessionIdToken))
+	requestLog.Logger().Debug("HelloDevice60 session started")
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER {
 		sessionIdToken = ""
 	}
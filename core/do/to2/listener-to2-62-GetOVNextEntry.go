@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -11,9 +10,11 @@ import (
 )
 
 func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
-	log.Println("GetOVNextEntry62: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving GetOVNextEntry62")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_62_GET_OVNEXTENTRY
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
 	if !fdoshared.CheckHeaders(w, r, currentCmd) {
@@ -34,6 +35,8 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To2.GetConfRand(fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -79,16 +82,24 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE {
-		ovNextEntry63.OVEntry = fdoshared.Conf_Fuzz_CoseSignature(ovNextEntry63.OVEntry)
+		ovNextEntry63.OVEntry = fdoshared.Conf_Fuzz_CoseSignature(rnd, ovNextEntry63.OVEntry)
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_62_BAD_OVENTRYNUM {
-		ovNextEntry63.OVEntryNum = uint8(fdoshared.NewRandomInt(int(ovNextEntry63.OVEntryNum)+1, 255))
+		ovNextEntry63.OVEntryNum = uint8(rnd.Int(int(ovNextEntry63.OVEntryNum)+1, 255))
 	}
 
 	ovNextEntryBytes, _ := fdoshared.CborCust.Marshal(ovNextEntry63)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_62_BAD_OVNEXTENTRY_PAYLOAD {
-		ovNextEntryBytes = fdoshared.Conf_RandomCborBufferFuzzing(ovNextEntryBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		ovNextEntryBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, ovNextEntry63)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz OVNextEntry63!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
@@ -2,9 +2,9 @@ package to2
 
 import (
 	"bytes"
+	"crypto"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,16 +13,18 @@ import (
 )
 
 func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
-	log.Println("ProveDevice64: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving ProveDevice64")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_64_PROVE_DEVICE
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 
 	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndVerify(w, r, currentCmd)
 	if err != nil {
 		return
 	}
 
-	privateKeyInst, err := fdoshared.ExtractPrivateKey(session.PrivateKeyDER)
+	privateKeyInst, err := fdoshared.LoadOwnerKey(session.PrivateKeyDER)
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, fdoshared.TO2_60_HELLO_DEVICE, "Error decoding private key... "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
@@ -39,6 +41,8 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To2.GetConfRand(fdoTestId)
 		}
 
 		for i := 0; i < int(session.NumOVEntries); i++ {
@@ -67,17 +71,33 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
-	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveDevice64", http.StatusBadRequest, testcomListener, fdoshared.To1)
-		return
-	}
+	if fdoshared.IsEpidSgType(session.EASigInfo.SgType) {
+		err = fdoshared.VerifyCoseSignatureWithEpid(proveDevice64, session.EASigInfo, h.config.EpidVerifier())
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Error validating EPID cose signature..."+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
+	} else {
+		_, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
+		if !ok || session.Voucher.OVDevCertChain == nil {
+			requestLog.Logger().Info("ProveDevice64: Unknown signature type", "sgType", session.EASigInfo.SgType)
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveDevice64", http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
 
-	err = fdoshared.VerifyCoseSignatureWithCertificate(proveDevice64, pkType, *session.Voucher.OVDevCertChain)
-	if err != nil {
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Error validating cose signature with certificate..."+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
-		return
+		err = fdoshared.VerifyCoseSignatureWithCertificate(proveDevice64, session.EASigInfo.SgType, *session.Voucher.OVDevCertChain, h.config.DeviceCertChainPolicy())
+		if err != nil {
+			// Chain findings are recorded in the test run, next to the failed test
+			if testcomListener != nil && testcomListener.To2.PushCertChainFinding(err) {
+				err := h.listenerDB.Update(testcomListener)
+				if err != nil {
+					requestLog.Logger().Error("ProveDevice64: Error saving certificate chain finding", "error", err)
+				}
+			}
+
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.CertChainErrorCode(err, fdoshared.MESSAGE_BODY_ERROR), currentCmd, "Error validating cose signature with certificate..."+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	// EATPayload
@@ -94,8 +114,9 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// KEX
-	sessionKey, err := fdoshared.DeriveSessionKey(session.XAKex, eatPayload.EatFDO.XBKeyExchange, false, privateKeyInst)
+	// KEX. Only RSA owner keys can decrypt ASYMKEX
+	ownerDecrypter, _ := privateKeyInst.(crypto.Decrypter)
+	sessionKey, err := fdoshared.DeriveSessionKey(session.XAKex, eatPayload.EatFDO.XBKeyExchange, false, ownerDecrypter)
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Error generating session shSe..."+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 		return
@@ -122,7 +143,15 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	setupDevicePayloadBytes, _ := fdoshared.CborCust.Marshal(setupDevicePayload)
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_PAYLOAD {
-		setupDevicePayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(setupDevicePayloadBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		setupDevicePayloadBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, setupDevicePayload)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz SetupDevice65 payload!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	// Response signature
@@ -133,13 +162,21 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_COSE_SIGNATURE {
-		tempSig := fdoshared.Conf_Fuzz_CoseSignature(*setupDevice)
+		tempSig := fdoshared.Conf_Fuzz_CoseSignature(rnd, *setupDevice)
 		setupDevice = &tempSig
 	}
 
 	setupDeviceBytes, _ := fdoshared.CborCust.Marshal(setupDevice)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_BYTES {
-		setupDeviceBytes = fdoshared.Conf_RandomCborBufferFuzzing(setupDeviceBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		setupDeviceBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, setupDevice)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz SetupDevice65!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	// Response encrypted
@@ -150,7 +187,7 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_ENC_WRAPPING {
-		setupDeviceBytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(setupDeviceBytesEnc, session.SessionKey, session.CipherSuiteName)
+		setupDeviceBytesEnc, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, setupDeviceBytesEnc, session.SessionKey, session.CipherSuiteName)
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error encrypting..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 			return
@@ -158,7 +195,15 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_64_BAD_SETUPDEVICE_ENCODING {
-		setupDeviceBytesEnc = fdoshared.Conf_RandomCborBufferFuzzing(setupDeviceBytesEnc)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		setupDeviceBytesEnc, fuzzMutation, err = fdoshared.Conf_RandomCborBytesFuzzing(rnd, setupDeviceBytesEnc)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz SetupDevice65 encryption wrapping!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	// Update session
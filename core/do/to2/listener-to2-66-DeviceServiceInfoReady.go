@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,10 +12,12 @@ import (
 const MAX_DEVICE_SERVICE_INFO_SIZE uint16 = 1300
 
 func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfoReady66: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving DeviceServiceInfoReady66")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 
 	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
 	if err != nil {
@@ -32,6 +33,8 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To2.GetConfRand(fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -67,7 +70,15 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 	}
 	ownerServiceInfoReadyPayloadBytes, _ := fdoshared.CborCust.Marshal(ownerServiceInfoReadyPayload)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_66_BAD_ENCODING {
-		ownerServiceInfoReadyPayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(ownerServiceInfoReadyPayloadBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		ownerServiceInfoReadyPayloadBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, ownerServiceInfoReadyPayload)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz OwnerServiceInfoReady67!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	// ----- MAIN BODY ENDS ----- //
@@ -78,7 +89,7 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_66_BAD_ENC_WRAPPING {
-		ownerServiceInfoReadyBytes, err = fdoshared.Conf_Fuzz_AddWrapping(ownerServiceInfoReadyBytes, session.SessionKey, session.CipherSuiteName)
+		ownerServiceInfoReadyBytes, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, ownerServiceInfoReadyBytes, session.SessionKey, session.CipherSuiteName)
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to fuzz encrypt OwnerServiceInfoReady. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 			return
@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,7 +12,8 @@ import (
 const MTU_BYTES = 1500
 
 func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfo68: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving DeviceServiceInfo68")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_68_DEVICE_SERVICE_INFO
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
@@ -36,6 +36,7 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -72,12 +73,12 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 		if session.OwnerSIMsSendCounter == 0 {
 			resultSims, err := ValidateDeviceSIMs(session.Guid, session.DeviceSIMs)
 			if err != nil {
-				log.Println("DeviceServiceInfo68: Error validating device sims: " + err.Error())
+				requestLog.Logger().Info("DeviceServiceInfo68: Error validating device sims", "error", err)
 				fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "DeviceServiceInfo68: Error validating device sims: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			log.Println("DeviceServiceInfo68: Validated device sims: ", *resultSims.SIM_DEVMOD_ARCH, *resultSims.SIM_DEVMOD_DEVICE, resultSims.SIM_DEVMOD_OS)
+			requestLog.Logger().Debug("DeviceServiceInfo68: Validated device sims", "arch", *resultSims.SIM_DEVMOD_ARCH, "device", *resultSims.SIM_DEVMOD_DEVICE, "os", resultSims.SIM_DEVMOD_OS)
 		}
 
 		if int(session.OwnerSIMsSendCounter+1) >= len(session.OwnerSIMs) {
@@ -105,7 +106,7 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	// ----- MAIN BODY ENDS ----- //
 	ownerServiceInfoEncBytes, err := fdoshared.AddEncryptionWrapping(ownerServiceInfoBytes, session.SessionKey, session.CipherSuiteName)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error encrypting..." + err.Error())
+		requestLog.Logger().Error("DeviceServiceInfo68: Error encrypting", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
@@ -113,7 +114,7 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	session.PrevCMD = fdoshared.TO2_69_OWNER_SERVICE_INFO
 	err = h.session.UpdateSessionEntry(sessionId, *session)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error saving session..." + err.Error())
+		requestLog.Logger().Error("DeviceServiceInfo68: Error saving session", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
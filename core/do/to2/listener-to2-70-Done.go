@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,10 +12,12 @@ import (
 )
 
 func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
-	log.Println("Done70: Receiving...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving Done70")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_70_DONE
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 	session, _, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
 	if err != nil {
 		return
@@ -30,6 +31,8 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To2.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To2.GetConfRand(fdoTestId)
 		}
 	}
 
@@ -43,7 +46,7 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to decode Done70. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 
-		log.Println("Done70: Error decoding request..." + err.Error())
+		requestLog.Logger().Info("Done70: Error decoding request", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -63,7 +66,15 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 
 	done271PayloadBytes, _ := fdoshared.CborCust.Marshal(done271Payload)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_70_BAD_DONE71_ENCODING {
-		done271PayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(done271PayloadBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		done271PayloadBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, done271Payload)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz Done271!", http.StatusInternalServerError, testcomListener, fdoshared.To2)
+			return
+		}
 	}
 
 	done271Bytes, err := fdoshared.AddEncryptionWrapping(done271PayloadBytes, session.SessionKey, session.CipherSuiteName)
@@ -73,7 +84,7 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_70_BAD_ENC_WRAPPING {
-		done271Bytes, err = fdoshared.Conf_Fuzz_AddWrapping(done271PayloadBytes, session.SessionKey, session.CipherSuiteName)
+		done271Bytes, err = fdoshared.Conf_Fuzz_AddWrapping(rnd, done271PayloadBytes, session.SessionKey, session.CipherSuiteName)
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Done70: Error encrypting..."+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To1)
 			return
@@ -90,15 +101,15 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
-		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopDO)
+	if fdoTestId == testcom.NULL_TEST && h.config.Interop.Enabled() {
+		authzHeader, err := fdoshared.IopGetAuthz(h.config.Interop, fdoshared.IopDO)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			requestLog.Logger().Error("IOT: Error getting authz header", "error", err)
 		}
 
-		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To2, session.NonceTO2SetupDv64, authzHeader)
+		err = fdoshared.SubmitIopLoggerEvent(h.config.Interop, session.Guid, fdoshared.To2, session.NonceTO2SetupDv64, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			requestLog.Logger().Error("IOT: Error sending iop logg event", "error", err)
 		}
 	}
 
@@ -0,0 +1,104 @@
+package to2
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	deviceto2 "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func newTestDoServer(t *testing.T) (*DoTo2, *httptest.Server) {
+	db := kv.NewMemoryStore()
+	config := fdoshared.DefaultConfig()
+
+	doto2 := NewDoTo2(db, &config)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fdo/101/msg/60", doto2.HelloDevice60)
+	mux.HandleFunc("/fdo/101/msg/62", doto2.GetOVNextEntry62)
+	mux.HandleFunc("/fdo/101/msg/64", doto2.ProveDevice64)
+	mux.HandleFunc("/fdo/101/msg/66", doto2.DeviceServiceInfoReady66)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &doto2, server
+}
+
+func newTestDoVoucher(t *testing.T, doto2 *DoTo2, voucherSgType fdoshared.DeviceSgType) fdoshared.DeviceCredAndVoucher {
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("failed to generate credential: %v", err)
+	}
+
+	return newTestDoVoucherForCredential(t, doto2, credential, voucherSgType)
+}
+
+func newTestDoVoucherForCredential(t *testing.T, doto2 *DoTo2, credential *fdoshared.WawDeviceCredential, voucherSgType fdoshared.DeviceSgType) fdoshared.DeviceCredAndVoucher {
+	rvInfo, _ := fdoshared.UrlsToRendezvousInfo([]string{"http://localhost:8080"})
+
+	testCred, err := fdodeviceimplementation.NewVirtualDeviceAndVoucher(*credential, voucherSgType, rvInfo, testcom.NULL_TEST, nil)
+	if err != nil {
+		t.Fatalf("failed to generate voucher: %v", err)
+	}
+
+	err = doto2.voucher.Save(testCred.VoucherDBEntry)
+	if err != nil {
+		t.Fatalf("failed to save voucher: %v", err)
+	}
+
+	return *testCred
+}
+
+// Suites are negotiated in HelloDevice60, so the rest of TO2 is not needed
+func runTestTo2Negotiation(serverUrl string, testCred fdoshared.DeviceCredAndVoucher, kexSuiteName fdoshared.KexSuiteName, cipherSuiteName fdoshared.CipherSuiteName) error {
+	config := fdoshared.DefaultConfig()
+	to2requestor := deviceto2.NewTo2Requestor(fdoshared.SRVEntry{
+		SrvURL: serverUrl,
+	}, testCred.WawDeviceCredential, kexSuiteName, cipherSuiteName, &config)
+
+	_, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+	return err
+}
+
+func TestTo2Negotiation(t *testing.T) {
+	doto2, server := newTestDoServer(t)
+
+	for _, voucherSgType := range []fdoshared.DeviceSgType{fdoshared.StSECP256R1, fdoshared.StRSA2048} {
+		testCred := newTestDoVoucher(t, doto2, voucherSgType)
+
+		for _, kexSuiteName := range fdoshared.KexSuitNames {
+			for _, cipherSuiteName := range fdoshared.CipherSuiteNames {
+				err := runTestTo2Negotiation(server.URL, testCred, kexSuiteName, cipherSuiteName)
+
+				kexErr := fdoshared.CheckKexSuiteOwnerKey(kexSuiteName, fdoshared.SgTypeToFdoPkType[voucherSgType])
+				if kexErr != nil {
+					if !errors.Is(err, deviceto2.ErrFdoErrorResponse) {
+						t.Errorf("%d/%s/%d: expected HelloDevice60 rejection. Got %v", voucherSgType, kexSuiteName, cipherSuiteName, err)
+					}
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("%d/%s/%d: %v", voucherSgType, kexSuiteName, cipherSuiteName, err)
+				}
+			}
+		}
+	}
+}
+
+func TestTo2Negotiation_UnknownCipher(t *testing.T) {
+	doto2, server := newTestDoServer(t)
+	testCred := newTestDoVoucher(t, doto2, fdoshared.StSECP256R1)
+
+	err := runTestTo2Negotiation(server.URL, testCred, fdoshared.KEX_ECDH256, fdoshared.CipherSuiteName(12345))
+	if !errors.Is(err, deviceto2.ErrFdoErrorResponse) {
+		t.Fatalf("expected HelloDevice60 rejection. Got %v", err)
+	}
+}
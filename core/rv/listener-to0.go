@@ -2,41 +2,36 @@ package rv
 
 import (
 	"bytes"
-	"context"
 	"io"
-	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 )
 
-const ServerWaitSeconds uint32 = 30 * 24 * 60 * 60 // 1 month
-
 type RvTo0 struct {
 	session     *SessionDB
 	ownersignDB *OwnerSignDB
 	listenerDB  *tdbs.ListenerTestDB
-	ctx         context.Context
+	config      *fdoshared.Config
 }
 
-func NewRvTo0(db *badger.DB, ctx context.Context) RvTo0 {
+func NewRvTo0(db kv.Store, config *fdoshared.Config) RvTo0 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
 	return RvTo0{
-		session: &SessionDB{
-			db: db,
-		},
+		session: NewSessionDB(db, config.Rv.SessionTtl),
 		ownersignDB: &OwnerSignDB{
 			db: db,
 		},
 		listenerDB: newListenerDb,
-		ctx:        ctx,
+		config:     config,
 	}
 }
 
 func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving Hello20...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving Hello20")
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_20_HELLO) {
 		return
 	}
@@ -51,7 +46,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &helloMsg)
 	if err != nil {
-		log.Println("Error decoding Hello20. " + err.Error())
+		requestLog.Logger().Info("Error decoding Hello20", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -61,6 +56,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 	newSessionInst := SessionEntry{
 		Protocol:     fdoshared.To0,
 		NonceTO0Sign: nonceTO0Sign,
+		Cid:          requestLog.CID(),
 	}
 
 	sessionId, err := h.session.NewSessionEntry(newSessionInst)
@@ -76,6 +72,8 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 	helloAckBytes, _ := fdoshared.CborCust.Marshal(helloAck)
 
 	sessionIdToken := "Bearer " + string(sessionId)
+	requestLog.With(fdoshared.LOG_FIELD_SESSION, fdoshared.SessionLogId(sessionIdToken))
+	requestLog.Logger().Debug("Hello20 session started")
 	w.Header().Set("Authorization", sessionIdToken)
 	w.Header().Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
 	w.Header().Set("Message-Type", fdoshared.TO0_21_HELLO_ACK.ToString())
@@ -84,7 +82,8 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving OwnerSign22...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving OwnerSign22")
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_22_OWNER_SIGN) {
 		return
 	}
@@ -100,6 +99,8 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestLog.SetCID(session.Cid)
+
 	if session.Protocol != fdoshared.To0 {
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -136,36 +137,38 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	/* ----- Verify OwnerSign ----- */
 
 	if !bytes.Equal(to0d.NonceTO0Sign[:], session.NonceTO0Sign[:]) {
-		log.Println("OwnerSign22: NonceTO0Sign does not match!")
+		requestLog.Logger().Info("OwnerSign22: NonceTO0Sign does not match")
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
 
 	err = to0d.OwnershipVoucher.Validate()
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying voucher. " + err.Error())
+		requestLog.Logger().Info("OwnerSign22: Error verifying voucher", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate voucher!", http.StatusBadRequest)
 		return
 	}
 
 	ovHeader, err := to0d.OwnershipVoucher.GetOVHeader()
 	if err != nil {
-		log.Println("OwnerSign22: Error decoding header. " + err.Error())
+		requestLog.Logger().Info("OwnerSign22: Error decoding header", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
 
+	requestLog.With(fdoshared.LOG_FIELD_GUID, ovHeader.OVGuid.GetFormattedHex())
+
 	// Verify To1D
 	finalPublicKey, err := to0d.OwnershipVoucher.GetFinalOwnerPublicKey()
 	if err != nil {
-		log.Println("OwnerSign22: Error decoding final owner public key. " + err.Error())
+		requestLog.Logger().Info("OwnerSign22: Error decoding final owner public key", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
 
 	err = fdoshared.VerifyCoseSignature(ownerSign.To1d, finalPublicKey)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to1d. " + err.Error())
+		requestLog.Logger().Info("OwnerSign22: Error verifying to1d", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 4!", http.StatusBadRequest)
 		return
 	}
@@ -173,14 +176,14 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	// Verify To0D Hash
 	err = fdoshared.VerifyHash(ownerSign.To0d, to1dPayload.To1dTo0dHash)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to0dHash. " + err.Error())
+		requestLog.Logger().Info("OwnerSign22: Error verifying to0dHash", "error", err)
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 6!", http.StatusBadRequest)
 		return
 	}
 
 	// Agreeing on timeout and saving
-	agreedWaitSeconds := ServerWaitSeconds
-	if to0d.WaitSeconds < ServerWaitSeconds {
+	agreedWaitSeconds := h.config.Rv.MaxWaitSeconds
+	if to0d.WaitSeconds < agreedWaitSeconds {
 		agreedWaitSeconds = to0d.WaitSeconds
 	}
 
@@ -196,15 +199,15 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	acceptOwnerBytes, _ := fdoshared.CborCust.Marshal(acceptOwner)
 
 	// TODO: Add testid check
-	if h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
-		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
+	if h.config.Interop.Enabled() {
+		authzHeader, err := fdoshared.IopGetAuthz(h.config.Interop, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			requestLog.Logger().Error("IOT: Error getting authz header", "error", err)
 		}
 
-		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To0, session.NonceTO1Proof, authzHeader)
+		err = fdoshared.SubmitIopLoggerEvent(h.config.Interop, session.Guid, fdoshared.To0, session.NonceTO1Proof, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			requestLog.Logger().Error("IOT: Error sending iop logg event", "error", err)
 		}
 	}
 
@@ -2,15 +2,13 @@ package rv
 
 import (
 	"bytes"
-	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
@@ -20,25 +18,30 @@ type RvTo1 struct {
 	session     *SessionDB
 	ownersignDB *OwnerSignDB
 	listenerDB  *tdbs.ListenerTestDB
-	ctx         context.Context
+	config      *fdoshared.Config
 }
 
-func NewRvTo1(db *badger.DB, ctx context.Context) RvTo1 {
+func NewRvTo1(db kv.Store, config *fdoshared.Config) RvTo1 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
 	return RvTo1{
-		session: &SessionDB{
-			db: db,
-		},
+		session: NewSessionDB(db, config.Rv.SessionTtl),
 		ownersignDB: &OwnerSignDB{
 			db: db,
 		},
 		listenerDB: newListenerDb,
-		ctx:        ctx,
+		config:     config,
 	}
 }
 
+// Records the mutation applied to the response, so the test result carries its fuzzing seed
+func (h *RvTo1) confSaveFuzzMutation(testcomListener *listenertestsdeps.RequestListenerInst, fuzzMutation *fdoshared.Conf_CborMutation) error {
+	testcomListener.To1.SetFuzzMutation(fuzzMutation)
+	return h.listenerDB.Update(testcomListener)
+}
+
 func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving HelloRV30...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving HelloRV30")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_30_HELLO_RV
 
@@ -60,11 +63,14 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestLog.With(fdoshared.LOG_FIELD_GUID, helloRV30.Guid.GetFormattedHex())
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(helloRV30.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(helloRV30.Guid[:]), err.Error())
+		requestLog.Logger().Debug("No test case for guid", "error", err)
 	}
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
@@ -76,6 +82,8 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To1.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To1.GetConfRand(fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -98,6 +106,7 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		NonceTO1Proof: nonceTO1Proof,
 		Guid:          helloRV30.Guid,
 		EASigInfo:     helloRV30.EASigInfo,
+		Cid:           requestLog.CID(),
 	}
 
 	sessionId, err := h.session.NewSessionEntry(newSessionInst)
@@ -114,7 +123,15 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 	helloRVAckBytes, _ := fdoshared.CborCust.Marshal(helloRVAck31)
 
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_30_BAD_ENCODING {
-		helloRVAckBytes = fdoshared.Conf_RandomCborBufferFuzzing(helloRVAckBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		helloRVAckBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, helloRVAck31)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz HelloRVAck31!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
+			return
+		}
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To1.CheckExpectedCmd(currentCmd) {
@@ -128,6 +145,8 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sessionIdToken := "Bearer " + string(sessionId)
+	requestLog.With(fdoshared.LOG_FIELD_SESSION, fdoshared.SessionLogId(sessionIdToken))
+	requestLog.Logger().Debug("HelloRV30 session started")
 	w.Header().Set("Authorization", sessionIdToken)
 	w.Header().Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
 	w.Header().Set("Message-Type", fdoshared.TO1_31_HELLO_RV_ACK.ToString())
@@ -136,7 +155,8 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving ProveToRV32...")
+	requestLog := fdoshared.GetRequestLog(r)
+	requestLog.Logger().Debug("Receiving ProveToRV32")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_32_PROVE_TO_RV
 
@@ -156,6 +176,8 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestLog.SetCID(session.Cid)
+
 	if session.Protocol != fdoshared.To1 {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Unauthorized", http.StatusUnauthorized, testcomListener, fdoshared.To1)
 		return
@@ -167,11 +189,14 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requestLog.With(fdoshared.LOG_FIELD_GUID, session.Guid.GetFormattedHex())
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
+	var rnd *fdoshared.Conf_Rand
 	testcomListener, err = h.listenerDB.GetEntryByFdoGuid(session.Guid)
 	if err != nil {
-		log.Printf("NO TEST CASE FOR %s. %s ", hex.EncodeToString(session.Guid[:]), err.Error())
+		requestLog.Logger().Debug("No test case for guid", "error", err)
 	}
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
@@ -183,6 +208,8 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 
 		if !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
 			fdoTestId = testcomListener.To1.GetNextTestID()
+			requestLog.With(fdoshared.LOG_FIELD_TEST_ID, fdoTestId)
+			rnd = testcomListener.To1.GetConfRand(fdoTestId)
 		}
 
 		err := h.listenerDB.Update(testcomListener)
@@ -195,7 +222,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var proveToRV32 fdoshared.CoseSignature
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &proveToRV32)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 request: " + err.Error())
+		requestLog.Logger().Info("Failed to decode ProveToRV32 request", "error", err)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -203,7 +230,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var pb fdoshared.EATPayloadBase
 	err = fdoshared.CborCust.Unmarshal(proveToRV32.Payload, &pb)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 payload: " + err.Error())
+		requestLog.Logger().Info("Failed to decode ProveToRV32 payload", "error", err)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body payload!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -216,7 +243,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	// Get ownerSign from ownerSign storage
 	savedOwnerSign, err := h.ownersignDB.Get(session.Guid)
 	if err != nil {
-		log.Println("Couldn't find item in database with guid" + err.Error())
+		requestLog.Logger().Info("Could not find owner sign for guid", "error", err)
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Server Error", http.StatusInternalServerError, testcomListener, fdoshared.To1)
 		return
 	}
@@ -224,33 +251,55 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var to0d fdoshared.To0d
 	err = fdoshared.CborCust.Unmarshal(savedOwnerSign.To0d, &to0d)
 	if err != nil {
-		log.Println("Error decoding To0d" + err.Error())
+		requestLog.Logger().Error("Error decoding To0d", "error", err)
 
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 
-	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
-	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
-		return
+	if fdoshared.IsEpidSgType(session.EASigInfo.SgType) {
+		err = fdoshared.VerifyCoseSignatureWithEpid(proveToRV32, session.EASigInfo, h.config.EpidVerifier())
+	} else {
+		_, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
+		if !ok || to0d.OwnershipVoucher.OVDevCertChain == nil {
+			requestLog.Logger().Info("ProveToRV32: Unknown signature type", "sgType", session.EASigInfo.SgType)
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
+			return
+		}
+
+		err = fdoshared.VerifyCoseSignatureWithCertificate(proveToRV32, session.EASigInfo.SgType, *to0d.OwnershipVoucher.OVDevCertChain, h.config.DeviceCertChainPolicy())
 	}
-	err = fdoshared.VerifyCoseSignatureWithCertificate(proveToRV32, pkType, *to0d.OwnershipVoucher.OVDevCertChain)
 	if err != nil {
-		log.Println("ProveToRV32: Error verifying ProveToRV32 signature. " + err.Error())
-		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
+		requestLog.Logger().Info("ProveToRV32: Error verifying ProveToRV32 signature", "error", err)
+
+		// Chain findings are recorded in the test run, next to the failed test
+		if testcomListener != nil && testcomListener.To1.PushCertChainFinding(err) {
+			err := h.listenerDB.Update(testcomListener)
+			if err != nil {
+				requestLog.Logger().Error("ProveToRV32: Error saving certificate chain finding", "error", err)
+			}
+		}
+
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.CertChainErrorCode(err, fdoshared.INVALID_MESSAGE_ERROR), currentCmd, "Error to verify signature ProveToRV32. "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 
 	var to1d fdoshared.CoseSignature = savedOwnerSign.To1d
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_32_BAD_TO1D {
-		to1d = fdoshared.Conf_Fuzz_CoseSignature(to1d)
+		to1d = fdoshared.Conf_Fuzz_CoseSignature(rnd, to1d)
 	}
 
 	rvRedirectBytes, _ := fdoshared.CborCust.Marshal(to1d)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_32_BAD_ENCODING {
-		rvRedirectBytes = fdoshared.Conf_RandomCborBufferFuzzing(rvRedirectBytes)
+		var fuzzMutation *fdoshared.Conf_CborMutation
+		rvRedirectBytes, fuzzMutation, err = fdoshared.Conf_RandomCborStructFuzzing(rnd, to1d)
+		if err == nil {
+			err = h.confSaveFuzzMutation(testcomListener, fuzzMutation)
+		}
+		if err != nil {
+			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to fuzz RVRedirect33!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
+			return
+		}
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
@@ -263,15 +312,15 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if fdoTestId == testcom.NULL_TEST && h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool) {
-		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
+	if fdoTestId == testcom.NULL_TEST && h.config.Interop.Enabled() {
+		authzHeader, err := fdoshared.IopGetAuthz(h.config.Interop, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			requestLog.Logger().Error("IOT: Error getting authz header", "error", err)
 		}
 
-		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To1, session.NonceTO1Proof, authzHeader)
+		err = fdoshared.SubmitIopLoggerEvent(h.config.Interop, session.Guid, fdoshared.To1, session.NonceTO1Proof, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop logg event: " + err.Error())
+			requestLog.Logger().Error("IOT: Error sending iop logg event", "error", err)
 		}
 	}
 
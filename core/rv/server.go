@@ -1,18 +1,24 @@
 package rv
 
 import (
-	"context"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
 )
 
-func SetupServer(db *badger.DB, ctx context.Context) {
-	to0 := NewRvTo0(db, ctx)
-	to1 := NewRvTo1(db, ctx)
+func SetupServer(db kv.Store, config *fdoshared.Config) {
+	to0 := NewRvTo0(db, config)
+	to1 := NewRvTo1(db, config)
 
-	http.HandleFunc("/fdo/101/msg/20", to0.Handle20Hello)
-	http.HandleFunc("/fdo/101/msg/22", to0.Handle22OwnerSign)
-	http.HandleFunc("/fdo/101/msg/30", to1.Handle30HelloRV)
-	http.HandleFunc("/fdo/101/msg/32", to1.Handle32ProveToRV)
+	http.HandleFunc("/fdo/101/msg/20", fdoHandler(fdoshared.TO0_20_HELLO, to0.Handle20Hello))
+	http.HandleFunc("/fdo/101/msg/22", fdoHandler(fdoshared.TO0_22_OWNER_SIGN, to0.Handle22OwnerSign))
+	http.HandleFunc("/fdo/101/msg/30", fdoHandler(fdoshared.TO1_30_HELLO_RV, to1.Handle30HelloRV))
+	http.HandleFunc("/fdo/101/msg/32", fdoHandler(fdoshared.TO1_32_PROVE_TO_RV, to1.Handle32ProveToRV))
+}
+
+// Every FDO message gets request log with the correlation ID, and is counted in the metrics
+func fdoHandler(cmd fdoshared.FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	return metrics.InstrumentFdoHandler(metrics.SERVICE_RV, cmd, fdoshared.WithRequestLog(cmd, handler))
 }
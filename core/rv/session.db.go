@@ -4,18 +4,20 @@ import (
 	"errors"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/google/uuid"
 )
 
 type SessionDB struct {
-	db *badger.DB
+	db  kv.Store
+	ttl time.Duration
 }
 
-func NewSessionDB(db *badger.DB) SessionDB {
-	return SessionDB{
-		db: db,
+func NewSessionDB(db kv.Store, ttl time.Duration) *SessionDB {
+	return &SessionDB{
+		db:  db,
+		ttl: ttl,
 	}
 }
 
@@ -26,27 +28,42 @@ type SessionEntry struct {
 	NonceTO1Proof fdoshared.FdoNonce
 	EASigInfo     fdoshared.SigInfo
 	Guid          fdoshared.FdoGuid
+
+	// Correlation ID of the session, derived in Hello20 or HelloRV30, and logged for every message
+	Cid uint
 }
 
-func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
-	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
+func (h SessionEntry) SchemaVersion() uint16 {
+	return 2
+}
 
-	randomEntryId, _ := uuid.NewRandom()
-	sessionEntryId := []byte("session-" + randomEntryId.String())
+// Before correlation ID
+type SessionEntryV1 struct {
+	_             struct{} `cbor:",toarray"`
+	Protocol      fdoshared.FdoToProtocol
+	NonceTO0Sign  fdoshared.FdoNonce
+	NonceTO1Proof fdoshared.FdoNonce
+	EASigInfo     fdoshared.SigInfo
+	Guid          fdoshared.FdoGuid
+}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+// Sessions from before the correlation ID get a new one
+func MigrateSessionEntryV1(oldEntry SessionEntryV1) (SessionEntry, error) {
+	return SessionEntry{
+		Protocol:      oldEntry.Protocol,
+		NonceTO0Sign:  oldEntry.NonceTO0Sign,
+		NonceTO1Proof: oldEntry.NonceTO1Proof,
+		EASigInfo:     oldEntry.EASigInfo,
+		Guid:          oldEntry.Guid,
+		Cid:           fdoshared.NewCID(),
+	}, nil
+}
 
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(time.Minute * 10) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
-	}
+func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
+	randomEntryId, _ := uuid.NewRandom()
+	sessionEntryId := []byte("rvsession-" + randomEntryId.String())
 
-	dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, h.ttl)
 	if err != nil {
 		return []byte{}, errors.New("Failed saving session entry. The error is: " + err.Error())
 	}
@@ -55,22 +72,9 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 }
 
 func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry) error {
-	sessionEntryId := append([]byte("session-"), entryId...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Set(sessionEntryId, sessionInstBytes)
-	if err != nil {
-		return errors.New("Failed to create saving inst. The error is: " + err.Error())
-	}
+	sessionEntryId := append([]byte("rvsession-"), entryId...)
 
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, 0)
 	if err != nil {
 		return errors.New("Failed to save session. The error is: " + err.Error())
 	}
@@ -79,28 +83,14 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 }
 
 func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
-	sessionEntryId := append([]byte("session-"), entryId...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	sessionEntryId := append([]byte("rvsession-"), entryId...)
 
-	item, err := dbtxn.Get(sessionEntryId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	sessionEntryInst, err := kv.GetCbor[SessionEntry](h.db, sessionEntryId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, nil
 	} else if err != nil {
-		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
-	var sessionEntryInst SessionEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading session entry. The error is: " + err.Error())
 	}
 
-	return &sessionEntryInst, nil
+	return sessionEntryInst, nil
 }
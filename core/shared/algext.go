@@ -0,0 +1,55 @@
+package fdoshared
+
+import (
+	"fmt"
+)
+
+// Algorithm extensions are algorithms beyond the FDO 1.1 profile: SHA-512 hashes and HMACs, ES512 and EdDSA.
+// They are off by default, and are never picked by the conformance test suites
+var algExtensionsEnabled bool = false
+
+func EnableAlgExtensions() {
+	algExtensionsEnabled = true
+}
+
+func DisableAlgExtensions() {
+	algExtensionsEnabled = false
+}
+
+func AlgExtensionsEnabled() bool {
+	return algExtensionsEnabled
+}
+
+var ExtSgTypeList []DeviceSgType = []DeviceSgType{
+	StSECP521R1,
+	StED25519,
+}
+
+func IsExtSgType(sgType DeviceSgType) bool {
+	return sgType == StSECP521R1 || sgType == StED25519
+}
+
+func IsExtPkType(pkType FdoPkType) bool {
+	return pkType == SECP521R1 || pkType == ED25519
+}
+
+func IsExtHashType(hashType HashType) bool {
+	return hashType == HASH_SHA512 || hashType == HASH_HMAC_SHA512
+}
+
+func checkAlgExtension(algName string) error {
+	if !algExtensionsEnabled {
+		return fmt.Errorf("%s is an algorithm extension. Set %s=true to enable it", algName, CFG_ENV_ALG_EXTENSIONS)
+	}
+
+	return nil
+}
+
+// Returns error if sgType is an algorithm extension, and extensions are not enabled
+func CheckSgTypeEnabled(sgType DeviceSgType) error {
+	if IsExtSgType(sgType) {
+		return checkAlgExtension(fmt.Sprintf("SgType %d", sgType))
+	}
+
+	return nil
+}
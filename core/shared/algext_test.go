@@ -0,0 +1,130 @@
+package fdoshared
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func test_enableAlgExtensions(t *testing.T) {
+	algExtensionsEnabled = true
+	t.Cleanup(func() { algExtensionsEnabled = false })
+}
+
+func TestAlgExtensions_Disabled(t *testing.T) {
+	_, err := GenerateFdoHash([]byte("test"), HASH_SHA512)
+	if err == nil {
+		t.Error("SHA512: expected hash to fail without extensions")
+	}
+
+	_, err = GenerateFdoHmac([]byte("test"), HASH_HMAC_SHA512, NewHmacKey(HASH_HMAC_SHA512))
+	if err == nil {
+		t.Error("HMAC-SHA512: expected hmac to fail without extensions")
+	}
+
+	for _, sgType := range ExtSgTypeList {
+		_, _, err := GenerateVoucherKeypair(sgType)
+		if err == nil {
+			t.Errorf("%d: expected key generation to fail without extensions", sgType)
+		}
+	}
+}
+
+func TestAlgExtensions_HashHmac(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	payload := []byte("test payload")
+
+	fdoHash, err := GenerateFdoHash(payload, HASH_SHA512)
+	if err != nil {
+		t.Fatalf("SHA512: failed to generate hash: %v", err)
+	}
+
+	err = VerifyHash(payload, fdoHash)
+	if err != nil {
+		t.Fatalf("SHA512: failed to verify hash: %v", err)
+	}
+
+	hmacKey := NewHmacKey(HASH_HMAC_SHA512)
+	if len(hmacKey) != 64 {
+		t.Fatalf("HMAC-SHA512: expected 64 byte key. Got %d", len(hmacKey))
+	}
+
+	fdoHmac, err := GenerateFdoHmac(payload, HASH_HMAC_SHA512, hmacKey)
+	if err != nil {
+		t.Fatalf("HMAC-SHA512: failed to generate hmac: %v", err)
+	}
+
+	err = VerifyHMac(payload, fdoHmac, hmacKey)
+	if err != nil {
+		t.Fatalf("HMAC-SHA512: failed to verify hmac: %v", err)
+	}
+}
+
+func TestAlgExtensions_CoseSignature(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	for _, sgType := range ExtSgTypeList {
+		for _, pkEnc := range []FdoPkEnc{X509, COSEKEY} {
+			privKey, pubKey, err := GenerateVoucherKeypairWithEncoding(sgType, pkEnc)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to generate keypair: %v", sgType, pkEnc, err)
+			}
+
+			signatureSgType, err := GetPrivateKeySgType(pubKey.PkType, privKey)
+			if err != nil || signatureSgType != sgType {
+				t.Fatalf("%d/%d: expected private key sgType %d. Got %d %v", sgType, pkEnc, sgType, signatureSgType, err)
+			}
+
+			coseSig, err := GenerateCoseSignature([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, privKey, sgType)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to generate COSE signature: %v", sgType, pkEnc, err)
+			}
+
+			err = VerifyCoseSignature(*coseSig, *pubKey)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to verify COSE signature: %v", sgType, pkEnc, err)
+			}
+
+			coseSig.Payload = []byte("other payload")
+			err = VerifyCoseSignature(*coseSig, *pubKey)
+			if err == nil {
+				t.Fatalf("%d/%d: expected signature over other payload to fail", sgType, pkEnc)
+			}
+		}
+	}
+}
+
+func TestAlgExtensions_DeviceCredential(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	for _, sgType := range ExtSgTypeList {
+		credential, err := NewWawDeviceCredential(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate credential: %v", sgType, err)
+		}
+
+		if credential.DCHmacAlg != SgTypeInfoMap[sgType].HmacType {
+			t.Fatalf("%d: expected hmac alg %d. Got %d", sgType, SgTypeInfoMap[sgType].HmacType, credential.DCHmacAlg)
+		}
+
+		coseSig, err := NewDeviceCredStore(*credential).Sign([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to sign: %v", sgType, err)
+		}
+
+		leafCert, err := x509.ParseCertificate(credential.DCCertificateChain[0])
+		if err != nil {
+			t.Fatalf("%d: failed to parse device certificate: %v", sgType, err)
+		}
+
+		devicePublicKey, err := NewFdoPublicKey(leafCert.PublicKey, sgType, X509)
+		if err != nil {
+			t.Fatalf("%d: failed to encode device public key: %v", sgType, err)
+		}
+
+		err = VerifyCoseSignature(*coseSig, *devicePublicKey)
+		if err != nil {
+			t.Fatalf("%d: failed to verify signature: %v", sgType, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ccm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func test_hexDecode(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Bad hex in test vector: %v", err)
+	}
+
+	return b
+}
+
+func TestCCM_ReferenceVectors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		key        string
+		nonce      string
+		adata      string
+		plaintext  string
+		tagSize    int
+		ciphertext string
+	}{
+		// NIST SP800-38C appendix C
+		{"SP800-38C Example 1", "404142434445464748494a4b4c4d4e4f", "10111213141516", "0001020304050607", "20212223", 4, "7162015b4dac255d"},
+		{"SP800-38C Example 2", "404142434445464748494a4b4c4d4e4f", "1011121314151617", "000102030405060708090a0b0c0d0e0f", "202122232425262728292a2b2c2d2e2f", 6, "d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd"},
+		{"SP800-38C Example 3", "404142434445464748494a4b4c4d4e4f", "101112131415161718191a1b", "000102030405060708090a0b0c0d0e0f10111213", "202122232425262728292a2b2c2d2e2f3031323334353637", 8, "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951"},
+		// RFC3610 section 8
+		{"RFC3610 Packet Vector #1", "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf", "00000003020100a0a1a2a3a4a5", "0001020304050607", "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e", 8, "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			block, err := aes.NewCipher(test_hexDecode(t, tc.key))
+			if err != nil {
+				t.Fatalf("Error creating cipher: %v", err)
+			}
+
+			nonce := test_hexDecode(t, tc.nonce)
+			adata := test_hexDecode(t, tc.adata)
+			plaintext := test_hexDecode(t, tc.plaintext)
+			expected := test_hexDecode(t, tc.ciphertext)
+
+			aesccm, err := NewCCM(block, tc.tagSize, len(nonce))
+			if err != nil {
+				t.Fatalf("Error creating CCM: %v", err)
+			}
+
+			sealed := aesccm.Seal(nil, nonce, plaintext, adata)
+			if !bytes.Equal(sealed, expected) {
+				t.Errorf("Sealed %s does not match %s", hex.EncodeToString(sealed), tc.ciphertext)
+			}
+
+			opened, err := aesccm.Open(nil, nonce, expected, adata)
+			if err != nil {
+				t.Fatalf("Error opening: %v", err)
+			}
+
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("Opened %s does not match %s", hex.EncodeToString(opened), tc.plaintext)
+			}
+
+			expected[0] ^= 0x01
+			if _, err := aesccm.Open(nil, nonce, expected, adata); err == nil {
+				t.Errorf("Expected tampered ciphertext to fail")
+			}
+		})
+	}
+}
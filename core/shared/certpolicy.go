@@ -0,0 +1,370 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Device attestation chain policy, used by DO and RV to check OVDevCertChain
+
+type CertChainFinding string
+
+const (
+	CERT_CHAIN_MALFORMED         CertChainFinding = "CERT_CHAIN_MALFORMED"
+	CERT_CHAIN_UNTRUSTED         CertChainFinding = "CERT_CHAIN_UNTRUSTED"
+	CERT_CHAIN_VALIDITY          CertChainFinding = "CERT_CHAIN_VALIDITY"
+	CERT_CHAIN_KEY_USAGE         CertChainFinding = "CERT_CHAIN_KEY_USAGE"
+	CERT_CHAIN_BASIC_CONSTRAINTS CertChainFinding = "CERT_CHAIN_BASIC_CONSTRAINTS"
+	CERT_CHAIN_REVOKED           CertChainFinding = "CERT_CHAIN_REVOKED"
+	CERT_CHAIN_KEY_POLICY        CertChainFinding = "CERT_CHAIN_KEY_POLICY"
+)
+
+type CertChainError struct {
+	Finding CertChainFinding
+	Message string
+}
+
+func (h *CertChainError) Error() string {
+	return string(h.Finding) + ": " + h.Message
+}
+
+// Undecodable chain is a structural error, the rest fail validation
+func (h *CertChainError) FdoErrorCode() FdoErrorCode {
+	if h.Finding == CERT_CHAIN_MALFORMED {
+		return MESSAGE_BODY_ERROR
+	}
+
+	return INVALID_MESSAGE_ERROR
+}
+
+func newCertChainError(finding CertChainFinding, format string, args ...interface{}) *CertChainError {
+	return &CertChainError{
+		Finding: finding,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns FDO error code for the certificate chain error, or defaultCode for any other error
+func CertChainErrorCode(err error, defaultCode FdoErrorCode) FdoErrorCode {
+	var certChainErr *CertChainError
+	if errors.As(err, &certChainErr) {
+		return certChainErr.FdoErrorCode()
+	}
+
+	return defaultCode
+}
+
+type CertChainPolicy struct {
+	// Trusted manufacturer roots. When nil, the last certificate of the chain is trusted
+	TrustedRoots *x509.CertPool
+	CRLs         []*x509.RevocationList
+	MinRSABits   int
+}
+
+// Policy used when no trusted roots or CRLs are configured
+func DefaultCertChainPolicy() CertChainPolicy {
+	return CertChainPolicy{
+		MinRSABits: 2048,
+	}
+}
+
+// Reads PEM files from a file, or every file in a directory
+func readPemPath(path string) ([][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = []string{}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	var filesBytes [][]byte
+	for _, filePath := range paths {
+		fileBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		filesBytes = append(filesBytes, fileBytes)
+	}
+
+	return filesBytes, nil
+}
+
+// Roots are PEM certificates. CRLs are PEM or DER. Both paths are a file or a directory, and are optional
+func LoadCertChainPolicy(rootsPath string, crlsPath string) (*CertChainPolicy, error) {
+	policy := DefaultCertChainPolicy()
+
+	if rootsPath != "" {
+		filesBytes, err := readPemPath(rootsPath)
+		if err != nil {
+			return nil, errors.New("error reading trusted roots. " + err.Error())
+		}
+
+		policy.TrustedRoots = x509.NewCertPool()
+		for _, fileBytes := range filesBytes {
+			for block, rest := pem.Decode(fileBytes); block != nil; block, rest = pem.Decode(rest) {
+				if block.Type != "CERTIFICATE" {
+					continue
+				}
+
+				rootCert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, errors.New("error decoding trusted root. " + err.Error())
+				}
+
+				policy.TrustedRoots.AddCert(rootCert)
+			}
+		}
+	}
+
+	if crlsPath != "" {
+		filesBytes, err := readPemPath(crlsPath)
+		if err != nil {
+			return nil, errors.New("error reading CRLs. " + err.Error())
+		}
+
+		for _, fileBytes := range filesBytes {
+			var crlsDer [][]byte
+			for block, rest := pem.Decode(fileBytes); block != nil; block, rest = pem.Decode(rest) {
+				if block.Type == "X509 CRL" {
+					crlsDer = append(crlsDer, block.Bytes)
+				}
+			}
+
+			if len(crlsDer) == 0 {
+				crlsDer = [][]byte{fileBytes}
+			}
+
+			for _, crlDer := range crlsDer {
+				crl, err := x509.ParseRevocationList(crlDer)
+				if err != nil {
+					return nil, errors.New("error decoding CRL. " + err.Error())
+				}
+
+				policy.CRLs = append(policy.CRLs, crl)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+func (h CertChainPolicy) checkKeySize(cert *x509.Certificate) error {
+	switch publicKey := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < h.MinRSABits {
+			return newCertChainError(CERT_CHAIN_KEY_POLICY, "\"%s\" RSA key is %d bits. Minimum is %d", cert.Subject.CommonName, publicKey.N.BitLen(), h.MinRSABits)
+		}
+	case *ecdsa.PublicKey:
+		if publicKey.Curve.Params().BitSize < 256 {
+			return newCertChainError(CERT_CHAIN_KEY_POLICY, "\"%s\" curve %s is weaker than P-256", cert.Subject.CommonName, publicKey.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+	default:
+		return newCertChainError(CERT_CHAIN_KEY_POLICY, "\"%s\" key type is not supported", cert.Subject.CommonName)
+	}
+
+	return nil
+}
+
+// Leaf key must be the key sgType signs with
+func checkLeafSgType(leafCert *x509.Certificate, sgType DeviceSgType) error {
+	err := CheckSgTypeEnabled(sgType)
+	if err != nil {
+		return newCertChainError(CERT_CHAIN_KEY_POLICY, "%s", err.Error())
+	}
+
+	var matches bool
+	switch publicKey := leafCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		curveName := publicKey.Curve.Params().Name
+		matches = (sgType == StSECP256R1 && curveName == "P-256") ||
+			(sgType == StSECP384R1 && curveName == "P-384") ||
+			(sgType == StSECP521R1 && curveName == "P-521")
+	case *rsa.PublicKey:
+		bitLen := publicKey.N.BitLen()
+		matches = ((sgType == StRSA2048 || sgType == StRSAPSS2048) && bitLen == 2048) ||
+			((sgType == StRSA3072 || sgType == StRSAPSS3072) && bitLen == 3072)
+	case ed25519.PublicKey:
+		matches = sgType == StED25519
+	}
+
+	if !matches {
+		return newCertChainError(CERT_CHAIN_KEY_POLICY, "leaf \"%s\" key does not match sgType %d", leafCert.Subject.CommonName, sgType)
+	}
+
+	return nil
+}
+
+func checkCertUsage(cert *x509.Certificate, isLeaf bool, isRoot bool) error {
+	if isLeaf {
+		if cert.BasicConstraintsValid && cert.IsCA {
+			return newCertChainError(CERT_CHAIN_BASIC_CONSTRAINTS, "leaf \"%s\" is a CA", cert.Subject.CommonName)
+		}
+
+		if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+			return newCertChainError(CERT_CHAIN_KEY_USAGE, "leaf \"%s\" is missing digitalSignature key usage", cert.Subject.CommonName)
+		}
+
+		return nil
+	}
+
+	// Legacy v1 roots have no basic constraints
+	if (!isRoot || cert.BasicConstraintsValid) && !(cert.BasicConstraintsValid && cert.IsCA) {
+		return newCertChainError(CERT_CHAIN_BASIC_CONSTRAINTS, "issuer \"%s\" is not a CA", cert.Subject.CommonName)
+	}
+
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return newCertChainError(CERT_CHAIN_KEY_USAGE, "issuer \"%s\" is missing keyCertSign key usage", cert.Subject.CommonName)
+	}
+
+	return nil
+}
+
+func (h CertChainPolicy) checkRevocation(cert *x509.Certificate, issuerCert *x509.Certificate) error {
+	for _, crl := range h.CRLs {
+		// CRLs of other issuers, or not signed by the issuer, do not apply
+		if crl.CheckSignatureFrom(issuerCert) != nil {
+			continue
+		}
+
+		for _, revoked := range crl.RevokedCertificateEntries {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return newCertChainError(CERT_CHAIN_REVOKED, "\"%s\" serial %X was revoked at %s", cert.Subject.CommonName, cert.SerialNumber, revoked.RevocationTime.Format(time.RFC3339))
+			}
+		}
+	}
+
+	return nil
+}
+
+func classifyVerifyError(err error) error {
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) {
+		switch invalidErr.Reason {
+		case x509.Expired:
+			return newCertChainError(CERT_CHAIN_VALIDITY, "%s", err.Error())
+		case x509.NotAuthorizedToSign, x509.TooManyIntermediates:
+			return newCertChainError(CERT_CHAIN_BASIC_CONSTRAINTS, "%s", err.Error())
+		case x509.IncompatibleUsage:
+			return newCertChainError(CERT_CHAIN_KEY_USAGE, "%s", err.Error())
+		}
+	}
+
+	return newCertChainError(CERT_CHAIN_UNTRUSTED, "%s", err.Error())
+}
+
+// Verifies device attestation chain, leaf first, for the device sgType. Returns verified chain from leaf to root
+func (h CertChainPolicy) Verify(chain []X509CertificateBytes, sgType DeviceSgType) ([]*x509.Certificate, error) {
+	if len(chain) == 0 || (h.TrustedRoots == nil && len(chain) < 2) {
+		return nil, newCertChainError(CERT_CHAIN_MALFORMED, "chain is too short. Got %d certificates", len(chain))
+	}
+
+	var certs []*x509.Certificate
+	for i, certBytes := range chain {
+		cert, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			return nil, newCertChainError(CERT_CHAIN_MALFORMED, "error decoding certificate %d. %s", i, err.Error())
+		}
+
+		certs = append(certs, cert)
+	}
+
+	now := time.Now()
+	for i, cert := range certs {
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return nil, newCertChainError(CERT_CHAIN_VALIDITY, "\"%s\" is only valid from %s to %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		}
+
+		err := h.checkKeySize(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			err = checkLeafSgType(cert, sgType)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// x509 rejects issuers without CA usage as unknown authority, so usage is checked first
+		isRoot := i != 0 && i == len(certs)-1 && bytes.Equal(cert.RawIssuer, cert.RawSubject)
+		err = checkCertUsage(cert, i == 0, isRoot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rootPool := h.TrustedRoots
+	intermediates := certs[1:]
+	if rootPool == nil {
+		rootPool = x509.NewCertPool()
+		rootPool.AddCert(certs[len(certs)-1])
+		intermediates = certs[1 : len(certs)-1]
+	}
+
+	interPool := x509.NewCertPool()
+	for _, interCert := range intermediates {
+		interPool.AddCert(interCert)
+	}
+
+	verificationChains, err := certs[0].Verify(x509.VerifyOptions{
+		Intermediates: interPool,
+		Roots:         rootPool,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, classifyVerifyError(err)
+	}
+
+	// Trusted root is not necessarily in the submitted chain, so verified chain is checked
+	verifiedChain := verificationChains[0]
+	for i, cert := range verifiedChain {
+		isRoot := i == len(verifiedChain)-1
+
+		err := checkCertUsage(cert, i == 0, isRoot)
+		if err != nil {
+			return nil, err
+		}
+
+		if i != 0 {
+			err = h.checkKeySize(cert)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !isRoot {
+			err = h.checkRevocation(cert, verifiedChain[i+1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return verifiedChain, nil
+}
@@ -0,0 +1,309 @@
+package fdoshared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type test_certChain struct {
+	rootCert  *x509.Certificate
+	interCert *x509.Certificate
+	interKey  interface{}
+	leafKey   *ecdsa.PrivateKey
+	leafCert  *x509.Certificate
+	chain     []X509CertificateBytes
+}
+
+func test_newCertTemplate(commonName string, isCA bool) *x509.Certificate {
+	serialNumber, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+
+	return template
+}
+
+func test_issueCert(t *testing.T, template *x509.Certificate, publicKey interface{}, issuerCert *x509.Certificate, issuerKey interface{}) *x509.Certificate {
+	if issuerCert == nil {
+		issuerCert = template
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, issuerCert, publicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("failed to issue %s: %v", template.Subject.CommonName, err)
+	}
+
+	cert, _ := x509.ParseCertificate(certBytes)
+	return cert
+}
+
+// Root, intermediate and P-256 leaf. modify can change templates, and intermediate key, before they are issued
+func test_newCertChain(t *testing.T, modify func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{})) test_certChain {
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var interKey interface{}
+	interKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	rootTemplate := test_newCertTemplate("Test Root", true)
+	interTemplate := test_newCertTemplate("Test Intermediate", true)
+	leafTemplate := test_newCertTemplate("Test Device", false)
+	if modify != nil {
+		modify(rootTemplate, interTemplate, leafTemplate, &interKey)
+	}
+
+	rootCert := test_issueCert(t, rootTemplate, &rootKey.PublicKey, nil, rootKey)
+	interCert := test_issueCert(t, interTemplate, CastPublicFromPrivate(interKey), rootCert, rootKey)
+	leafCert := test_issueCert(t, leafTemplate, &leafKey.PublicKey, interCert, interKey)
+
+	return test_certChain{
+		rootCert:  rootCert,
+		interCert: interCert,
+		interKey:  interKey,
+		leafKey:   leafKey,
+		leafCert:  leafCert,
+		chain:     []X509CertificateBytes{leafCert.Raw, interCert.Raw, rootCert.Raw},
+	}
+}
+
+func test_expectFinding(t *testing.T, name string, err error, finding CertChainFinding) {
+	var certChainErr *CertChainError
+	if !errors.As(err, &certChainErr) {
+		t.Errorf("%s: expected %s. Got %v", name, finding, err)
+		return
+	}
+
+	if certChainErr.Finding != finding {
+		t.Errorf("%s: expected %s. Got %v", name, finding, err)
+	}
+}
+
+func TestCertChainPolicy_Findings(t *testing.T) {
+	policy := CertChainPolicy{MinRSABits: 2048}
+
+	testCases := map[string]struct {
+		modify  func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{})
+		sgType  DeviceSgType
+		finding CertChainFinding
+	}{
+		"ExpiredLeaf": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				leaf.NotAfter = time.Now().Add(-time.Minute)
+			},
+			finding: CERT_CHAIN_VALIDITY,
+		},
+		"FutureIntermediate": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				inter.NotBefore = time.Now().Add(time.Hour)
+			},
+			finding: CERT_CHAIN_VALIDITY,
+		},
+		"LeafKeyUsage": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				leaf.KeyUsage = x509.KeyUsageKeyEncipherment
+			},
+			finding: CERT_CHAIN_KEY_USAGE,
+		},
+		"IntermediateKeyUsage": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				inter.KeyUsage = x509.KeyUsageDigitalSignature
+			},
+			finding: CERT_CHAIN_KEY_USAGE,
+		},
+		"IntermediateNotCA": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				inter.IsCA = false
+			},
+			finding: CERT_CHAIN_BASIC_CONSTRAINTS,
+		},
+		"LeafIsCA": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				leaf.IsCA = true
+				leaf.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+			},
+			finding: CERT_CHAIN_BASIC_CONSTRAINTS,
+		},
+		"WeakIntermediateKey": {
+			modify: func(root *x509.Certificate, inter *x509.Certificate, leaf *x509.Certificate, interKey *interface{}) {
+				*interKey, _ = rsa.GenerateKey(rand.Reader, 1024)
+			},
+			finding: CERT_CHAIN_KEY_POLICY,
+		},
+		"SgTypeMismatch": {
+			sgType:  StSECP384R1,
+			finding: CERT_CHAIN_KEY_POLICY,
+		},
+		"DisabledExtSgType": {
+			sgType:  StSECP521R1,
+			finding: CERT_CHAIN_KEY_POLICY,
+		},
+	}
+
+	for name, testCase := range testCases {
+		certChain := test_newCertChain(t, testCase.modify)
+
+		sgType := StSECP256R1
+		if testCase.sgType != 0 {
+			sgType = testCase.sgType
+		}
+
+		_, err := policy.Verify(certChain.chain, sgType)
+		test_expectFinding(t, name, err, testCase.finding)
+	}
+
+	_, err := policy.Verify([]X509CertificateBytes{[]byte("not a certificate"), []byte("not a certificate")}, StSECP256R1)
+	test_expectFinding(t, "Malformed", err, CERT_CHAIN_MALFORMED)
+	if CertChainErrorCode(err, INVALID_MESSAGE_ERROR) != MESSAGE_BODY_ERROR {
+		t.Errorf("Malformed: expected MESSAGE_BODY_ERROR")
+	}
+
+	_, err = policy.Verify(test_newCertChain(t, nil).chain, StSECP256R1)
+	if err != nil {
+		t.Errorf("expected valid chain to pass. Got %v", err)
+	}
+}
+
+func TestCertChainPolicy_TrustedRoots(t *testing.T) {
+	certChain := test_newCertChain(t, nil)
+	otherChain := test_newCertChain(t, nil)
+
+	trustedRoots := x509.NewCertPool()
+	trustedRoots.AddCert(certChain.rootCert)
+	policy := CertChainPolicy{TrustedRoots: trustedRoots, MinRSABits: 2048}
+
+	// Root may be left out of the chain
+	verifiedChain, err := policy.Verify(certChain.chain[:2], StSECP256R1)
+	if err != nil {
+		t.Fatalf("expected chain to the trusted root to pass. Got %v", err)
+	}
+
+	if !verifiedChain[len(verifiedChain)-1].Equal(certChain.rootCert) {
+		t.Errorf("expected chain to end with the trusted root")
+	}
+
+	_, err = policy.Verify(otherChain.chain, StSECP256R1)
+	test_expectFinding(t, "UntrustedRoot", err, CERT_CHAIN_UNTRUSTED)
+	if CertChainErrorCode(err, MESSAGE_BODY_ERROR) != INVALID_MESSAGE_ERROR {
+		t.Errorf("UntrustedRoot: expected INVALID_MESSAGE_ERROR")
+	}
+}
+
+func TestCertChainPolicy_Revocation(t *testing.T) {
+	certChain := test_newCertChain(t, nil)
+	otherChain := test_newCertChain(t, nil)
+
+	newCrl := func(issuerCert *x509.Certificate, issuerKey interface{}, serialNumber *big.Int) *x509.RevocationList {
+		crlBytes, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: time.Now(),
+			NextUpdate: time.Now().AddDate(0, 1, 0),
+			RevokedCertificateEntries: []x509.RevocationListEntry{
+				{SerialNumber: serialNumber, RevocationTime: time.Now()},
+			},
+		}, issuerCert, issuerKey.(*ecdsa.PrivateKey))
+		if err != nil {
+			t.Fatalf("failed to create CRL: %v", err)
+		}
+
+		crl, _ := x509.ParseRevocationList(crlBytes)
+		return crl
+	}
+
+	// Same serial, but revoked by another issuer
+	policy := CertChainPolicy{
+		CRLs:       []*x509.RevocationList{newCrl(otherChain.interCert, otherChain.interKey, certChain.leafCert.SerialNumber)},
+		MinRSABits: 2048,
+	}
+
+	_, err := policy.Verify(certChain.chain, StSECP256R1)
+	if err != nil {
+		t.Fatalf("expected CRL of other issuer to be ignored. Got %v", err)
+	}
+
+	policy.CRLs = append(policy.CRLs, newCrl(certChain.interCert, certChain.interKey, certChain.leafCert.SerialNumber))
+
+	_, err = policy.Verify(certChain.chain, StSECP256R1)
+	test_expectFinding(t, "Revoked", err, CERT_CHAIN_REVOKED)
+}
+
+func TestLoadCertChainPolicy(t *testing.T) {
+	certChain := test_newCertChain(t, nil)
+
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().AddDate(0, 1, 0),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: certChain.leafCert.SerialNumber, RevocationTime: time.Now()},
+		},
+	}, certChain.interCert, certChain.interKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+
+	tempDir := t.TempDir()
+	rootsPath := filepath.Join(tempDir, "roots.pem")
+	crlsDir := filepath.Join(tempDir, "crls")
+	os.Mkdir(crlsDir, 0755)
+
+	os.WriteFile(rootsPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certChain.rootCert.Raw}), 0644)
+	os.WriteFile(filepath.Join(crlsDir, "inter.pem"), pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes}), 0644)
+	os.WriteFile(filepath.Join(crlsDir, "inter.der"), crlBytes, 0644)
+
+	policy, err := LoadCertChainPolicy(rootsPath, crlsDir)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	if len(policy.CRLs) != 2 {
+		t.Fatalf("expected PEM and DER CRLs to be loaded. Got %d", len(policy.CRLs))
+	}
+
+	_, err = policy.Verify(certChain.chain[:2], StSECP256R1)
+	test_expectFinding(t, "Revoked", err, CERT_CHAIN_REVOKED)
+
+	_, err = LoadCertChainPolicy(filepath.Join(tempDir, "missing.pem"), "")
+	if err == nil {
+		t.Errorf("expected missing roots file to fail")
+	}
+}
+
+func TestVerifyCoseSignatureWithCertificate_Policy(t *testing.T) {
+	certChain := test_newCertChain(t, nil)
+
+	coseSig, err := GenerateCoseSignature([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, certChain.leafKey, StSECP256R1)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	err = VerifyCoseSignatureWithCertificate(*coseSig, StSECP256R1, certChain.chain, DefaultCertChainPolicy())
+	if err != nil {
+		t.Fatalf("expected signature to verify. Got %v", err)
+	}
+
+	trustedRoots := x509.NewCertPool()
+	trustedRoots.AddCert(test_newCertChain(t, nil).rootCert)
+	err = VerifyCoseSignatureWithCertificate(*coseSig, StSECP256R1, certChain.chain, CertChainPolicy{TrustedRoots: trustedRoots, MinRSABits: 2048})
+	test_expectFinding(t, "UntrustedRoot", err, CERT_CHAIN_UNTRUSTED)
+}
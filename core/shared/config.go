@@ -0,0 +1,476 @@
+package fdoshared
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Server and CLI configuration. Loaded from defaults, then optional YAML file, then environment variables, see LoadConfig
+type Config struct {
+	Port int `yaml:"port"`
+
+	// Port of the Prometheus /metrics listener. Kept apart from the public port. Disabled when 0
+	MetricsPort int `yaml:"metricsPort"`
+
+	// Public URL of the built-in DO, used in TO0 and account emails. Default http://localhost:<port>
+	FdoServiceUrl string `yaml:"fdoServiceUrl"`
+
+	Mode          string `yaml:"mode"`
+	Env           string `yaml:"env"`
+	DBPath        string `yaml:"dbPath"`
+	AdminEmail    string `yaml:"adminEmail"`
+	AlgExtensions bool   `yaml:"algExtensions"`
+
+	// Seeded device bases per device sgType
+	SeedSize int `yaml:"seedSize"`
+
+	Rv               RvConfig               `yaml:"rv"`
+	Do               DoConfig               `yaml:"do"`
+	Device           DeviceConfig           `yaml:"device"`
+	Interop          InteropConfig          `yaml:"interop"`
+	DeviceCertPolicy DeviceCertPolicyConfig `yaml:"deviceCertPolicy"`
+	Pkcs11           Pkcs11Config           `yaml:"pkcs11"`
+	Mailer           MailerConfig           `yaml:"mailer"`
+	Backup           BackupConfig           `yaml:"backup"`
+	Log              LogConfig              `yaml:"log"`
+
+	// RV URL sets for generate_rvinfo. First set is used by iop generate
+	RvInfo []RvInfoSet `yaml:"rvInfo"`
+
+	// Loaded from DeviceCertPolicy roots and CRLs by LoadConfig
+	deviceCertChainPolicy *CertChainPolicy
+}
+
+type RvConfig struct {
+	// Upper limit for the TO0 WaitSeconds the owner asks for
+	MaxWaitSeconds uint32        `yaml:"maxWaitSeconds"`
+	SessionTtl     time.Duration `yaml:"sessionTtl"`
+}
+
+type DoConfig struct {
+	// TO0 WaitSeconds the DO asks RV for
+	To0WaitSeconds uint32        `yaml:"to0WaitSeconds"`
+	SessionTtl     time.Duration `yaml:"sessionTtl"`
+}
+
+// Virtual device
+type DeviceConfig struct {
+	MaxMessageSize uint16 `yaml:"maxMessageSize"`
+	CredPassphrase string `yaml:"credPassphrase"`
+	TpmSimulator   string `yaml:"tpmSimulator"`
+}
+
+// Interop is enabled when dashboard URL is set
+type InteropConfig struct {
+	DashboardUrl   string `yaml:"dashboardUrl"`
+	RvAuthz        string `yaml:"rvAuthz"`
+	DoAuthz        string `yaml:"doAuthz"`
+	DoTokenMapping string `yaml:"doTokenMapping"`
+}
+
+func (h InteropConfig) Enabled() bool {
+	return h.DashboardUrl != ""
+}
+
+type DeviceCertPolicyConfig struct {
+	Roots string `yaml:"roots"`
+	Crls  string `yaml:"crls"`
+
+	// External EPID verification service. Without it only virtual EPID devices of the test group are verified
+	EpidVerifierUrl string `yaml:"epidVerifierUrl"`
+}
+
+type Pkcs11Config struct {
+	Module     string `yaml:"module"`
+	TokenLabel string `yaml:"tokenLabel"`
+	Pin        string `yaml:"pin"`
+}
+
+type MailerConfig struct {
+	Type         string `yaml:"type"`
+	From         string `yaml:"from"`
+	DropDir      string `yaml:"dropDir"`
+	SmtpHost     string `yaml:"smtpHost"`
+	SmtpPort     string `yaml:"smtpPort"`
+	SmtpUsername string `yaml:"smtpUsername"`
+	SmtpPassword string `yaml:"smtpPassword"`
+}
+
+// Backups are disabled when dir is not set
+type BackupConfig struct {
+	Dir      string        `yaml:"dir"`
+	Interval time.Duration `yaml:"interval"`
+	Keep     int           `yaml:"keep"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type RvInfoSet struct {
+	Name string   `yaml:"name"`
+	Urls []string `yaml:"urls"`
+}
+
+const DEFAULT_PORT int = 8080
+
+// 1 month
+const DEFAULT_WAIT_SECONDS uint32 = 30 * 24 * 60 * 60
+
+func DefaultConfig() Config {
+	return Config{
+		Port:     DEFAULT_PORT,
+		Mode:     CFG_MODE_ONPREM,
+		Env:      CFG_ENV_PROD,
+		DBPath:   "./badger.local.db",
+		SeedSize: 10000,
+		Rv: RvConfig{
+			MaxWaitSeconds: DEFAULT_WAIT_SECONDS,
+			SessionTtl:     10 * time.Minute,
+		},
+		Do: DoConfig{
+			To0WaitSeconds: DEFAULT_WAIT_SECONDS,
+			SessionTtl:     10 * time.Minute,
+		},
+		Device: DeviceConfig{
+			MaxMessageSize: 2048,
+			TpmSimulator:   "127.0.0.1:2321",
+		},
+		Mailer: MailerConfig{
+			DropDir:  "./_mail",
+			SmtpPort: "587",
+		},
+		Backup: BackupConfig{
+			Interval: 24 * time.Hour,
+			Keep:     7,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LOG_FORMAT_TEXT,
+		},
+		RvInfo: []RvInfoSet{
+			{
+				Name: "HTTP IP Only",
+				Urls: []string{
+					"http://165.227.240.155:80",   // FIDO
+					"http://20.228.111.63:8080",   // Intel
+					"http://103.147.123.161:7040", // VinCSS
+					"http://44.210.118.60:8040/",  // Dell
+				},
+			},
+			{
+				Name: "HTTP + HTTPS IP Only",
+				Urls: []string{
+					"http://165.227.240.155:80",  // FIDO
+					"https://172.67.150.203:443", // FIDO
+					"https://104.21.0.92:443",    // FIDO
+
+					"http://20.228.111.63:8080", // Intel
+
+					"http://103.147.123.161:7040",  // VinCSS
+					"https://103.147.123.161:7040", // VinCSS
+
+					"http://44.210.118.60:8040/",  // Dell
+					"https://44.210.118.60:8041/", // Dell
+				},
+			},
+			{
+				Name: "HTTP + HTTPS + DNS",
+				Urls: []string{
+					"http://165.227.240.155:80",   // FIDO
+					"https://172.67.150.203:443",  // FIDO
+					"https://104.21.0.92:443",     // FIDO
+					"https://rv.fdo.tools:443",    // FIDO
+					"http://http.rv.fdo.tools:80", // FIDO
+
+					"http://20.228.111.63:8080",                      // Intel
+					"http://bmo-rrp.westus.cloudapp.azure.com:8080/", // Intel
+
+					"http://103.147.123.161:7040",    // VinCSS
+					"https://103.147.123.161:7040",   // VinCSS
+					"http://vincss-fdo-rv.fido2.vn",  // VinCSS
+					"https://vincss-fdo-rv.fido2.vn", // VinCSS
+
+					"http://44.210.118.60:8040/",  // Dell
+					"https://44.210.118.60:8041/", // Dell
+				},
+			},
+		},
+	}
+}
+
+// Loads config file over the defaults, applies environment variables over it, and validates the result.
+// Empty filename only uses defaults and environment
+func LoadConfig(filename string) (*Config, error) {
+	config := DefaultConfig()
+
+	if filename != "" {
+		configBytes, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, errors.New("Failed reading config file. The error is: " + err.Error())
+		}
+
+		// Lists set in the file replace default lists
+		// Unknown keys are rejected, so typos do not silently fall back to defaults
+		decoder := yaml.NewDecoder(bytes.NewReader(configBytes))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("Failed decoding config file %s. The error is: %s", filename, err.Error())
+		}
+	}
+
+	err := config.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.FdoServiceUrl == "" {
+		config.FdoServiceUrl = fmt.Sprintf("http://localhost:%d", config.Port)
+	}
+
+	if config.DeviceCertPolicy.Roots != "" || config.DeviceCertPolicy.Crls != "" {
+		config.deviceCertChainPolicy, err = LoadCertChainPolicy(config.DeviceCertPolicy.Roots, config.DeviceCertPolicy.Crls)
+		if err != nil {
+			return nil, errors.New("Failed loading device certificate chain policy. The error is: " + err.Error())
+		}
+	}
+
+	return &config, nil
+}
+
+// Device certificate chain policy. Default policy when no trusted roots or CRLs are configured
+func (h *Config) DeviceCertChainPolicy() CertChainPolicy {
+	if h.deviceCertChainPolicy == nil {
+		return DefaultCertChainPolicy()
+	}
+
+	return *h.deviceCertChainPolicy
+}
+
+// Remote verifier when EPID verifier URL is set, local verifier of the conformance test group otherwise
+func (h *Config) EpidVerifier() EpidVerifier {
+	if h.DeviceCertPolicy.EpidVerifierUrl != "" {
+		return NewRemoteEpidVerifier(h.DeviceCertPolicy.EpidVerifierUrl)
+	}
+
+	return DefaultEpidVerifier()
+}
+
+// RV info of the test vouchers. Vouchers point to the RV of this tool at FDO service URL
+func (h *Config) VoucherRvInfo() (RendezvousInfo, error) {
+	return UrlsToRendezvousInfo([]string{h.FdoServiceUrl})
+}
+
+// Environment variables override config file values
+func (h *Config) ApplyEnv(lookupEnv func(string) (string, bool)) error {
+	overrides := map[CONFIG_ENTRY]func(string) error{
+		CFG_ENV_PORT:            intSetter(&h.Port),
+		CFG_ENV_METRICS_PORT:    intSetter(&h.MetricsPort),
+		CFG_ENV_FDO_SERVICE_URL: stringSetter(&h.FdoServiceUrl),
+		CFG_ENV_MODE:            stringSetter(&h.Mode),
+		CFG_DEV_ENV:             stringSetter(&h.Env),
+		CFG_ENV_DB_PATH:         stringSetter(&h.DBPath),
+		CFG_ENV_ADMIN_EMAIL:     stringSetter(&h.AdminEmail),
+		CFG_ENV_ALG_EXTENSIONS:  boolSetter(&h.AlgExtensions),
+		CFG_ENV_SEED_SIZE:       intSetter(&h.SeedSize),
+
+		CFG_ENV_DEVICE_CRED_PASSPHRASE: stringSetter(&h.Device.CredPassphrase),
+		CFG_ENV_TPM_SIMULATOR:          stringSetter(&h.Device.TpmSimulator),
+
+		CFG_ENV_INTEROP_DASHBOARD_URL:      stringSetter(&h.Interop.DashboardUrl),
+		CFG_ENV_INTEROP_DASHBOARD_RV_AUTHZ: stringSetter(&h.Interop.RvAuthz),
+		CFG_ENV_INTEROP_DASHBOARD_DO_AUTHZ: stringSetter(&h.Interop.DoAuthz),
+		CFG_ENV_INTEROP_DO_TOKEN_MAPPING:   stringSetter(&h.Interop.DoTokenMapping),
+
+		CFG_ENV_DEVICE_CERT_ROOTS: stringSetter(&h.DeviceCertPolicy.Roots),
+		CFG_ENV_DEVICE_CERT_CRLS:  stringSetter(&h.DeviceCertPolicy.Crls),
+		CFG_ENV_EPID_VERIFIER_URL: stringSetter(&h.DeviceCertPolicy.EpidVerifierUrl),
+
+		CFG_ENV_PKCS11_MODULE:      stringSetter(&h.Pkcs11.Module),
+		CFG_ENV_PKCS11_TOKEN_LABEL: stringSetter(&h.Pkcs11.TokenLabel),
+		CFG_ENV_PKCS11_PIN:         stringSetter(&h.Pkcs11.Pin),
+
+		CFG_ENV_MAILER:        stringSetter(&h.Mailer.Type),
+		CFG_ENV_MAIL_FROM:     stringSetter(&h.Mailer.From),
+		CFG_ENV_MAIL_DROP_DIR: stringSetter(&h.Mailer.DropDir),
+		CFG_ENV_SMTP_HOST:     stringSetter(&h.Mailer.SmtpHost),
+		CFG_ENV_SMTP_PORT:     stringSetter(&h.Mailer.SmtpPort),
+		CFG_ENV_SMTP_USERNAME: stringSetter(&h.Mailer.SmtpUsername),
+		CFG_ENV_SMTP_PASSWORD: stringSetter(&h.Mailer.SmtpPassword),
+
+		CFG_ENV_BACKUP_DIR:      stringSetter(&h.Backup.Dir),
+		CFG_ENV_BACKUP_INTERVAL: durationSetter(&h.Backup.Interval),
+		CFG_ENV_BACKUP_KEEP:     intSetter(&h.Backup.Keep),
+
+		CFG_ENV_LOG_LEVEL:  stringSetter(&h.Log.Level),
+		CFG_ENV_LOG_FORMAT: stringSetter(&h.Log.Format),
+	}
+
+	for envEntry, setValue := range overrides {
+		envValue, ok := lookupEnv(string(envEntry))
+		if !ok || envValue == "" {
+			continue
+		}
+
+		err := setValue(envValue)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %s. %s", envEntry, envValue, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (h *Config) Validate() error {
+	if h.Port <= 0 || h.Port > 65535 {
+		return fmt.Errorf("Invalid port %d", h.Port)
+	}
+
+	if h.MetricsPort < 0 || h.MetricsPort > 65535 || h.MetricsPort == h.Port {
+		return fmt.Errorf("Invalid metrics port %d. Must differ from port %d, or be 0 to disable metrics", h.MetricsPort, h.Port)
+	}
+
+	if h.Mode != CFG_MODE_ONPREM && h.Mode != CFG_MODE_ONLINE {
+		return fmt.Errorf("Unknown mode %s. Must be %s or %s", h.Mode, CFG_MODE_ONPREM, CFG_MODE_ONLINE)
+	}
+
+	if h.Env != CFG_ENV_DEV && h.Env != CFG_ENV_PROD {
+		return fmt.Errorf("Unknown env %s. Must be %s or %s", h.Env, CFG_ENV_DEV, CFG_ENV_PROD)
+	}
+
+	if h.DBPath == "" {
+		return errors.New("DB path is not set")
+	}
+
+	if h.SeedSize <= 0 {
+		return fmt.Errorf("Invalid seed size %d. Expected number of device bases per sgType", h.SeedSize)
+	}
+
+	if h.Interop.Enabled() {
+		// Interop dashboard must reach the DO, so localhost default does not work
+		if h.FdoServiceUrl == "" || h.Interop.RvAuthz == "" || h.Interop.DoAuthz == "" || h.Interop.DoTokenMapping == "" {
+			return errors.New("Interop requires FDO service URL, RV and DO authz, and DO token mapping")
+		}
+	}
+
+	if h.FdoServiceUrl != "" {
+		_, err := url.ParseRequestURI(h.FdoServiceUrl)
+		if err != nil {
+			return fmt.Errorf("Invalid FDO service URL %s", h.FdoServiceUrl)
+		}
+	}
+
+	if h.DeviceCertPolicy.EpidVerifierUrl != "" {
+		_, err := url.ParseRequestURI(h.DeviceCertPolicy.EpidVerifierUrl)
+		if err != nil {
+			return fmt.Errorf("Invalid EPID verifier URL %s", h.DeviceCertPolicy.EpidVerifierUrl)
+		}
+	}
+
+	if h.Rv.MaxWaitSeconds == 0 || h.Do.To0WaitSeconds == 0 {
+		return errors.New("TO0 wait seconds must be positive")
+	}
+
+	if h.Rv.SessionTtl <= 0 || h.Do.SessionTtl <= 0 {
+		return errors.New("Session TTL must be positive")
+	}
+
+	if h.Device.MaxMessageSize == 0 {
+		return errors.New("Device max message size must be positive")
+	}
+
+	if h.Backup.Interval <= 0 {
+		return fmt.Errorf("Invalid backup interval %s", h.Backup.Interval)
+	}
+
+	if h.Backup.Keep < 0 {
+		return fmt.Errorf("Invalid backup keep %d. Expected number of backups", h.Backup.Keep)
+	}
+
+	_, err := NewLogger(os.Stderr, h.Log.Level, h.Log.Format)
+	if err != nil {
+		return err
+	}
+
+	for _, rvInfoSet := range h.RvInfo {
+		_, err := UrlsToRendezvousInfo(rvInfoSet.Urls)
+		if err != nil {
+			return fmt.Errorf("Invalid RV info %s. %s", rvInfoSet.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Returns RV info set by name. Empty name returns the first set
+func (h *Config) GetRvInfoSet(name string) (*RvInfoSet, error) {
+	for _, rvInfoSet := range h.RvInfo {
+		if name == "" || rvInfoSet.Name == name {
+			return &rvInfoSet, nil
+		}
+	}
+
+	if name == "" {
+		return nil, errors.New("No RV info sets are configured")
+	}
+
+	return nil, fmt.Errorf("Unknown RV info set %s", name)
+}
+
+func stringSetter(field *string) func(string) error {
+	return func(value string) error {
+		*field = value
+		return nil
+	}
+}
+
+func intSetter(field *int) func(string) error {
+	return func(value string) error {
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("Expected number")
+		}
+
+		*field = intValue
+		return nil
+	}
+}
+
+func boolSetter(field *bool) func(string) error {
+	return func(value string) error {
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("Expected true or false")
+		}
+
+		*field = boolValue
+		return nil
+	}
+}
+
+func durationSetter(field *time.Duration) func(string) error {
+	return func(value string) error {
+		durationValue, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("Expected duration, e.g. 6h")
+		}
+
+		*field = durationValue
+		return nil
+	}
+}
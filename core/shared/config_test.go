@@ -0,0 +1,138 @@
+package fdoshared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func test_writeConfigFile(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(filename, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return filename
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	config, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+
+	if config.Port != DEFAULT_PORT {
+		t.Errorf("Expected port %d. Got %d", DEFAULT_PORT, config.Port)
+	}
+
+	if config.FdoServiceUrl != "http://localhost:8080" {
+		t.Errorf("Expected FDO service URL default from port. Got %s", config.FdoServiceUrl)
+	}
+
+	if config.Rv.MaxWaitSeconds != DEFAULT_WAIT_SECONDS || config.Do.To0WaitSeconds != DEFAULT_WAIT_SECONDS {
+		t.Errorf("Expected default wait seconds %d", DEFAULT_WAIT_SECONDS)
+	}
+
+	if len(config.RvInfo) == 0 {
+		t.Error("Expected default RV info sets")
+	}
+
+	if config.DeviceCertChainPolicy().MinRSABits != DefaultCertChainPolicy().MinRSABits {
+		t.Errorf("Expected default device certificate chain policy. Got %+v", config.DeviceCertChainPolicy())
+	}
+
+	rvInfo, err := config.VoucherRvInfo()
+	if err != nil || len(rvInfo) != 1 {
+		t.Errorf("Expected voucher RV info from FDO service URL. Got %+v %v", rvInfo, err)
+	}
+}
+
+func TestConfig_FileAndEnv(t *testing.T) {
+	filename := test_writeConfigFile(t, `
+port: 9090
+mode: online
+adminEmail: admin@example.com
+rv:
+  maxWaitSeconds: 3600
+  sessionTtl: 5m
+do:
+  to0WaitSeconds: 600
+device:
+  maxMessageSize: 1300
+backup:
+  dir: ./_backups
+  interval: 6h
+rvInfo:
+  - name: Local
+    urls:
+      - http://localhost:9090
+`)
+
+	t.Setenv(string(CFG_ENV_MODE), CFG_MODE_ONPREM)
+	t.Setenv(string(CFG_ENV_BACKUP_INTERVAL), "1h")
+
+	config, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Port != 9090 || config.FdoServiceUrl != "http://localhost:9090" {
+		t.Errorf("Expected port 9090 from file. Got %d %s", config.Port, config.FdoServiceUrl)
+	}
+
+	if config.Mode != CFG_MODE_ONPREM {
+		t.Errorf("Expected environment to override mode. Got %s", config.Mode)
+	}
+
+	if config.Backup.Interval != time.Hour || config.Backup.Dir != "./_backups" || config.Backup.Keep != 7 {
+		t.Errorf("Unexpected backup config %+v", config.Backup)
+	}
+
+	if config.Rv.MaxWaitSeconds != 3600 || config.Rv.SessionTtl != 5*time.Minute || config.Do.To0WaitSeconds != 600 || config.Do.SessionTtl != 10*time.Minute {
+		t.Errorf("Unexpected RV and DO config %+v %+v", config.Rv, config.Do)
+	}
+
+	if config.Device.MaxMessageSize != 1300 {
+		t.Errorf("Expected max message size 1300. Got %d", config.Device.MaxMessageSize)
+	}
+
+	rvInfoSet, err := config.GetRvInfoSet("")
+	if err != nil || rvInfoSet.Name != "Local" || len(config.RvInfo) != 1 {
+		t.Errorf("Expected file RV info to replace defaults. Got %+v", config.RvInfo)
+	}
+
+	_, err = config.GetRvInfoSet("Unknown")
+	if err == nil {
+		t.Error("Expected unknown RV info set to fail")
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	testCases := map[string]string{
+		"unknown key":         "prot: 9090\n",
+		"invalid mode":        "mode: cloud\n",
+		"interop no authz":    "interop:\n  dashboardUrl: https://interop.example.com\n",
+		"invalid duration":    "backup:\n  interval: daily\n",
+		"invalid log level":   "log:\n  level: verbose\n",
+		"invalid rvinfo url":  "rvInfo:\n  - name: Broken\n    urls:\n      - localhost\n",
+		"zero message size":   "device:\n  maxMessageSize: 0\n",
+		"invalid service url": "fdoServiceUrl: tools.example.com\n",
+		"metrics on app port": "port: 9090\nmetricsPort: 9090\n",
+		"missing cert roots":  "deviceCertPolicy:\n  roots: /nonexistent/roots.pem\n",
+	}
+
+	for name, content := range testCases {
+		_, err := LoadConfig(test_writeConfigFile(t, content))
+		if err == nil {
+			t.Errorf("Expected %s config to fail", name)
+		}
+	}
+
+	t.Setenv(string(CFG_ENV_PORT), "eighty")
+	_, err := LoadConfig("")
+	if err == nil {
+		t.Error("Expected invalid PORT environment variable to fail")
+	}
+}
@@ -0,0 +1,490 @@
+package fdoshared
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// STRUCTURE AWARE CBOR FUZZING
+//
+// Conf_CborFuzzer mutates a single element of an encoded `cbor:",toarray"` message. Every mutation is derived
+// from the fuzzer seed, so a failing negative test can be replayed by creating a new fuzzer with the same seed.
+
+type Conf_CborMutationType string
+
+const (
+	Conf_CborMut_WrongType       Conf_CborMutationType = "wrong_type"
+	Conf_CborMut_IntOutOfRange   Conf_CborMutationType = "int_out_of_range"
+	Conf_CborMut_TruncatedBstr   Conf_CborMutationType = "truncated_bstr"
+	Conf_CborMut_ExtraElement    Conf_CborMutationType = "extra_array_element"
+	Conf_CborMut_IndefiniteLen   Conf_CborMutationType = "indefinite_length"
+	Conf_CborMut_NonCanonicalInt Conf_CborMutationType = "non_canonical_int"
+	Conf_CborMut_UnknownTag      Conf_CborMutationType = "unknown_tag"
+
+	// Used by MutateOpaqueBytes for byte strings that are not CBOR, e.g. signatures. Not in Conf_CborMutationType_List
+	Conf_CborMut_RandomBytes Conf_CborMutationType = "random_bytes"
+)
+
+var Conf_CborMutationType_List []Conf_CborMutationType = []Conf_CborMutationType{
+	Conf_CborMut_WrongType,
+	Conf_CborMut_IntOutOfRange,
+	Conf_CborMut_TruncatedBstr,
+	Conf_CborMut_ExtraElement,
+	Conf_CborMut_IndefiniteLen,
+	Conf_CborMut_NonCanonicalInt,
+	Conf_CborMut_UnknownTag,
+}
+
+// Field index used when the mutation is applied to the message array itself
+const Conf_CborMutationMessageLevel int = -1
+
+type Conf_CborMutation struct {
+	Seed      int64                 `json:"seed"`
+	Type      Conf_CborMutationType `json:"type"`
+	Field     int                   `json:"field"`
+	FieldName string                `json:"fieldName,omitempty"`
+}
+
+func (h Conf_CborMutation) String() string {
+	if h.Field == Conf_CborMutationMessageLevel {
+		return fmt.Sprintf("seed %d: %s on message", h.Seed, h.Type)
+	}
+
+	return fmt.Sprintf("seed %d: %s on field %d %s", h.Seed, h.Type, h.Field, h.FieldName)
+}
+
+const (
+	cborMajorUint   byte = 0
+	cborMajorNegInt byte = 1
+	cborMajorBstr   byte = 2
+	cborMajorTstr   byte = 3
+	cborMajorArray  byte = 4
+	cborMajorMap    byte = 5
+	cborMajorTag    byte = 6
+	cborMajorSimple byte = 7
+
+	cborIndefiniteInfo byte = 31
+	cborBreak          byte = 0xff
+)
+
+// Unassigned tag range used for Conf_CborMut_UnknownTag
+const confUnknownTagBase uint64 = 0x0FD00000
+
+type Conf_CborFuzzer struct {
+	Seed int64
+	rnd  *Conf_Rand
+}
+
+func NewConf_CborFuzzer(seed int64) *Conf_CborFuzzer {
+	return &Conf_CborFuzzer{
+		Seed: seed,
+		rnd:  NewConf_Rand(seed),
+	}
+}
+
+// Encodes v using CborCust and applies a single seeded mutation to one of its fields
+func (h *Conf_CborFuzzer) MutateStruct(v interface{}) ([]byte, *Conf_CborMutation, error) {
+	cborBytes, err := CborCust.Marshal(v)
+	if err != nil {
+		return nil, nil, errors.New("error marshaling fuzzing target. " + err.Error())
+	}
+
+	resultBytes, mutation, err := h.MutateBytes(cborBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mutation.FieldName = confToArrayFieldName(v, mutation.Field)
+
+	return resultBytes, mutation, nil
+}
+
+// Applies a single seeded mutation to an encoded CBOR array, optionally wrapped in tags
+func (h *Conf_CborFuzzer) MutateBytes(cborBytes []byte) ([]byte, *Conf_CborMutation, error) {
+	tagPrefix, arrayBytes, err := confSplitTags(cborBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	major, _, _, err := confReadCborHeader(arrayBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if major != cborMajorArray {
+		return nil, nil, fmt.Errorf("error fuzzing cbor. Expected array, got major type %d", major)
+	}
+
+	var elements []cbor.RawMessage
+	err = cbor.Unmarshal(arrayBytes, &elements)
+	if err != nil {
+		return nil, nil, errors.New("error decoding fuzzing target array. " + err.Error())
+	}
+
+	mutation := Conf_CborMutation{
+		Seed:  h.Seed,
+		Field: Conf_CborMutationMessageLevel,
+	}
+
+	var field int = Conf_CborMutationMessageLevel
+	if len(elements) > 0 {
+		field = h.rnd.Int(0, len(elements))
+	}
+
+	var applicable []Conf_CborMutationType
+	if field == Conf_CborMutationMessageLevel {
+		applicable = []Conf_CborMutationType{
+			Conf_CborMut_WrongType,
+			Conf_CborMut_ExtraElement,
+			Conf_CborMut_IndefiniteLen,
+			Conf_CborMut_NonCanonicalInt,
+			Conf_CborMut_UnknownTag,
+		}
+	} else {
+		applicable = confApplicableMutations(elements[field])
+	}
+
+	mutation.Type = applicable[h.rnd.Int(0, len(applicable))]
+
+	var newArray []byte
+	switch mutation.Type {
+	case Conf_CborMut_ExtraElement:
+		elements = append(elements, h.randomElement(nil))
+		newArray = confEncodeArray(elements, false)
+
+	case Conf_CborMut_WrongType:
+		if field == Conf_CborMutationMessageLevel {
+			arrayMajor := cborMajorArray
+			newArray = h.randomElement(&arrayMajor)
+		} else {
+			elementMajor := elements[field][0] >> 5
+			elements[field] = h.randomElement(&elementMajor)
+			mutation.Field = field
+			newArray = confEncodeArray(elements, false)
+		}
+
+	case Conf_CborMut_IndefiniteLen:
+		if field == Conf_CborMutationMessageLevel {
+			newArray = confEncodeArray(elements, true)
+		} else {
+			elements[field], err = confToIndefinite(elements[field])
+			if err != nil {
+				return nil, nil, err
+			}
+			mutation.Field = field
+			newArray = confEncodeArray(elements, false)
+		}
+
+	case Conf_CborMut_NonCanonicalInt:
+		if field == Conf_CborMutationMessageLevel {
+			newArray = confEncodeArray(elements, false)
+			newArray, err = confToNonCanonical(newArray)
+		} else {
+			elements[field], err = confToNonCanonical(elements[field])
+			mutation.Field = field
+			newArray = confEncodeArray(elements, false)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case Conf_CborMut_IntOutOfRange:
+		elements[field] = confIntOutOfRange(elements[field][0] >> 5)
+		mutation.Field = field
+		newArray = confEncodeArray(elements, false)
+
+	case Conf_CborMut_TruncatedBstr:
+		elements[field], err = h.truncateString(elements[field])
+		if err != nil {
+			return nil, nil, err
+		}
+		mutation.Field = field
+		newArray = confEncodeArray(elements, false)
+
+	case Conf_CborMut_UnknownTag:
+		unknownTag := confEncodeHeader(cborMajorTag, confUnknownTagBase+uint64(h.rnd.Int(0, 0xFFFF)))
+		if field == Conf_CborMutationMessageLevel {
+			newArray = append(unknownTag, confEncodeArray(elements, false)...)
+		} else {
+			elements[field] = append(unknownTag, elements[field]...)
+			mutation.Field = field
+			newArray = confEncodeArray(elements, false)
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unknown cbor mutation %s", mutation.Type)
+	}
+
+	return append(tagPrefix, newArray...), &mutation, nil
+}
+
+// Generates random CBOR element, with a major type different from exceptMajor
+func (h *Conf_CborFuzzer) randomElement(exceptMajor *byte) []byte {
+	var candidates []byte
+	for _, major := range []byte{cborMajorUint, cborMajorNegInt, cborMajorBstr, cborMajorTstr, cborMajorArray, cborMajorMap, cborMajorSimple} {
+		if exceptMajor == nil || *exceptMajor != major {
+			candidates = append(candidates, major)
+		}
+	}
+
+	var value interface{}
+	switch candidates[h.rnd.Int(0, len(candidates))] {
+	case cborMajorUint:
+		value = uint64(h.rnd.Int(0, 61904))
+	case cborMajorNegInt:
+		value = -1 - int64(h.rnd.Int(0, 61904))
+	case cborMajorBstr:
+		value = h.rnd.Buffer(h.rnd.Int(1, 65))
+	case cborMajorTstr:
+		value = h.rnd.String(h.rnd.Int(1, 33))
+	case cborMajorArray:
+		value = []interface{}{uint64(h.rnd.Int(0, 255)), h.rnd.Buffer(8)}
+	case cborMajorMap:
+		value = map[string]interface{}{h.rnd.String(4): uint64(h.rnd.Int(0, 255))}
+	default:
+		value = h.rnd.Int(0, 2) == 1
+	}
+
+	valueBytes, _ := CborCust.Marshal(value)
+	return valueBytes
+}
+
+func (h *Conf_CborFuzzer) truncateString(element []byte) ([]byte, error) {
+	major, length, headerLen, err := confReadCborHeader(element)
+	if err != nil {
+		return nil, err
+	}
+
+	newLength := uint64(h.rnd.Int(0, int(length)))
+
+	return append(confEncodeHeader(major, newLength), element[headerLen:headerLen+int(newLength)]...), nil
+}
+
+// Replaces an opaque byte string, e.g. a signature, with a seeded random prefix followed by zeros. Length is kept
+func (h *Conf_CborFuzzer) MutateOpaqueBytes(inputBuff []byte) ([]byte, *Conf_CborMutation) {
+	maxFuzzRange := len(inputBuff) / 3
+	actualFuzzRange := maxFuzzRange / 2
+
+	newRandomBuffLength := h.rnd.Int(maxFuzzRange-actualFuzzRange, maxFuzzRange)
+
+	var newBuffer []byte = make([]byte, len(inputBuff))
+	copy(newBuffer, h.rnd.Buffer(newRandomBuffLength))
+
+	return newBuffer, &Conf_CborMutation{
+		Seed:  h.Seed,
+		Type:  Conf_CborMut_RandomBytes,
+		Field: Conf_CborMutationMessageLevel,
+	}
+}
+
+// Draws fuzzing seed from rnd, and replaces the opaque byte string inputBuff
+func Conf_RandomOpaqueBytesFuzzing(rnd *Conf_Rand, inputBuff []byte) ([]byte, *Conf_CborMutation) {
+	return NewConf_CborFuzzer(rnd.Int63()).MutateOpaqueBytes(inputBuff)
+}
+
+// Draws fuzzing seed from rnd, and applies a single mutation to encoded CBOR array cborBytes
+func Conf_RandomCborBytesFuzzing(rnd *Conf_Rand, cborBytes []byte) ([]byte, *Conf_CborMutation, error) {
+	return NewConf_CborFuzzer(rnd.Int63()).MutateBytes(cborBytes)
+}
+
+// Draws fuzzing seed from rnd, and applies a single mutation to v
+func Conf_RandomCborStructFuzzing(rnd *Conf_Rand, v interface{}) ([]byte, *Conf_CborMutation, error) {
+	return NewConf_CborFuzzer(rnd.Int63()).MutateStruct(v)
+}
+
+func confApplicableMutations(element []byte) []Conf_CborMutationType {
+	applicable := []Conf_CborMutationType{
+		Conf_CborMut_WrongType,
+		Conf_CborMut_ExtraElement,
+		Conf_CborMut_UnknownTag,
+	}
+
+	major, argument, _, err := confReadCborHeader(element)
+	if err != nil {
+		return applicable
+	}
+
+	switch major {
+	case cborMajorUint, cborMajorNegInt:
+		applicable = append(applicable, Conf_CborMut_IntOutOfRange, Conf_CborMut_NonCanonicalInt)
+	case cborMajorBstr, cborMajorTstr:
+		applicable = append(applicable, Conf_CborMut_IndefiniteLen, Conf_CborMut_NonCanonicalInt)
+		if argument > 0 {
+			applicable = append(applicable, Conf_CborMut_TruncatedBstr)
+		}
+	case cborMajorArray, cborMajorMap:
+		applicable = append(applicable, Conf_CborMut_IndefiniteLen, Conf_CborMut_NonCanonicalInt)
+	}
+
+	return applicable
+}
+
+func confToArrayFieldName(v interface{}, field int) string {
+	if field == Conf_CborMutationMessageLevel {
+		return ""
+	}
+
+	vType := reflect.TypeOf(v)
+	for vType != nil && vType.Kind() == reflect.Ptr {
+		vType = vType.Elem()
+	}
+
+	if vType == nil || vType.Kind() != reflect.Struct {
+		return ""
+	}
+
+	var cborFieldIndex int = 0
+	for i := 0; i < vType.NumField(); i++ {
+		structField := vType.Field(i)
+		if structField.Name == "_" || !structField.IsExported() {
+			continue
+		}
+
+		if cborFieldIndex == field {
+			return structField.Name
+		}
+
+		cborFieldIndex++
+	}
+
+	return ""
+}
+
+// Returns CBOR header major type, argument, and header length
+func confReadCborHeader(cborBytes []byte) (byte, uint64, int, error) {
+	if len(cborBytes) == 0 {
+		return 0, 0, 0, errors.New("error reading cbor header. Input is empty")
+	}
+
+	major := cborBytes[0] >> 5
+	info := cborBytes[0] & 0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), 1, nil
+	case info == 24 && len(cborBytes) >= 2:
+		return major, uint64(cborBytes[1]), 2, nil
+	case info == 25 && len(cborBytes) >= 3:
+		return major, uint64(binary.BigEndian.Uint16(cborBytes[1:3])), 3, nil
+	case info == 26 && len(cborBytes) >= 5:
+		return major, uint64(binary.BigEndian.Uint32(cborBytes[1:5])), 5, nil
+	case info == 27 && len(cborBytes) >= 9:
+		return major, binary.BigEndian.Uint64(cborBytes[1:9]), 9, nil
+	case info == cborIndefiniteInfo:
+		return major, 0, 1, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("error reading cbor header. Unsupported additional info %d", info)
+	}
+}
+
+func confEncodeHeader(major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{major<<5 | byte(argument)}
+	case argument <= 0xff:
+		return []byte{major<<5 | 24, byte(argument)}
+	case argument <= 0xffff:
+		header := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(header[1:], uint16(argument))
+		return header
+	case argument <= 0xffffffff:
+		header := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[1:], uint32(argument))
+		return header
+	default:
+		header := []byte{major<<5 | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(header[1:], argument)
+		return header
+	}
+}
+
+// Always uses eight byte argument encoding, which is never canonical for values that fit in less
+func confEncodeHeaderNonCanonical(major byte, argument uint64) []byte {
+	header := []byte{major<<5 | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[1:], argument)
+	return header
+}
+
+func confEncodeArray(elements []cbor.RawMessage, indefinite bool) []byte {
+	var result []byte
+	if indefinite {
+		result = []byte{cborMajorArray<<5 | cborIndefiniteInfo}
+	} else {
+		result = confEncodeHeader(cborMajorArray, uint64(len(elements)))
+	}
+
+	for _, element := range elements {
+		result = append(result, element...)
+	}
+
+	if indefinite {
+		result = append(result, cborBreak)
+	}
+
+	return result
+}
+
+func confSplitTags(cborBytes []byte) ([]byte, []byte, error) {
+	offset := 0
+	for {
+		major, _, headerLen, err := confReadCborHeader(cborBytes[offset:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if major != cborMajorTag {
+			break
+		}
+
+		offset += headerLen
+	}
+
+	return append([]byte{}, cborBytes[:offset]...), cborBytes[offset:], nil
+}
+
+func confToIndefinite(element []byte) ([]byte, error) {
+	major, argument, headerLen, err := confReadCborHeader(element)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborMajorBstr, cborMajorTstr:
+		// Single chunk indefinite length string
+		result := []byte{major<<5 | cborIndefiniteInfo}
+		result = append(result, confEncodeHeader(major, argument)...)
+		result = append(result, element[headerLen:headerLen+int(argument)]...)
+		return append(result, cborBreak), nil
+
+	case cborMajorArray, cborMajorMap:
+		result := []byte{major<<5 | cborIndefiniteInfo}
+		result = append(result, element[headerLen:]...)
+		return append(result, cborBreak), nil
+
+	default:
+		return nil, fmt.Errorf("error converting to indefinite length. Unsupported major type %d", major)
+	}
+}
+
+func confToNonCanonical(element []byte) ([]byte, error) {
+	major, argument, headerLen, err := confReadCborHeader(element)
+	if err != nil {
+		return nil, err
+	}
+
+	if major == cborMajorSimple || element[0]&0x1f == cborIndefiniteInfo {
+		return nil, fmt.Errorf("error converting to non canonical form. Unsupported element %x", element[0])
+	}
+
+	return append(confEncodeHeaderNonCanonical(major, argument), element[headerLen:]...), nil
+}
+
+func confIntOutOfRange(major byte) []byte {
+	// Largest possible CBOR integer of the same sign. Overflows every FDO integer field
+	return confEncodeHeader(major, ^uint64(0))
+}
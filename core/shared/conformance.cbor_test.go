@@ -0,0 +1,76 @@
+package fdoshared
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConf_CborFuzzer_Deterministic(t *testing.T) {
+	helloDevice := HelloDevice60{
+		MaxDeviceMessageSize: 1300,
+		Guid:                 NewFdoGuid(),
+		NonceTO2ProveOV:      NewFdoNonce(),
+		KexSuiteName:         KEX_ECDH256,
+		CipherSuiteName:      CIPHER_A128GCM,
+		EASigInfo:            SigInfo{SgType: StSECP256R1},
+	}
+
+	originalBytes, err := CborCust.Marshal(helloDevice)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for seed := int64(0); seed < 256; seed++ {
+		mutatedA, mutationA, err := NewConf_CborFuzzer(seed).MutateStruct(helloDevice)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+
+		mutatedB, mutationB, err := NewConf_CborFuzzer(seed).MutateStruct(helloDevice)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+
+		if !bytes.Equal(mutatedA, mutatedB) || *mutationA != *mutationB {
+			t.Fatalf("seed %d: expected identical mutations. Got %s and %s", seed, mutationA, mutationB)
+		}
+
+		if bytes.Equal(mutatedA, originalBytes) {
+			t.Fatalf("seed %d: expected mutated bytes to differ from original. Mutation %s", seed, mutationA)
+		}
+	}
+}
+
+func TestConf_CborFuzzer_EmptyMessage(t *testing.T) {
+	for seed := int64(0); seed < 64; seed++ {
+		_, mutation, err := NewConf_CborFuzzer(seed).MutateStruct(Hello20{})
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+
+		if mutation.Field != Conf_CborMutationMessageLevel {
+			t.Fatalf("seed %d: expected message level mutation. Got %s", seed, mutation)
+		}
+	}
+}
+
+func TestConf_CborFuzzer_OpaqueBytes(t *testing.T) {
+	signature := bytes.Repeat([]byte{0xaa}, 64)
+
+	for seed := int64(0); seed < 64; seed++ {
+		mutatedA, mutationA := NewConf_CborFuzzer(seed).MutateOpaqueBytes(signature)
+		mutatedB, mutationB := NewConf_CborFuzzer(seed).MutateOpaqueBytes(signature)
+
+		if !bytes.Equal(mutatedA, mutatedB) || *mutationA != *mutationB {
+			t.Fatalf("seed %d: expected identical mutations. Got %s and %s", seed, mutationA, mutationB)
+		}
+
+		if len(mutatedA) != len(signature) || bytes.Equal(mutatedA, signature) {
+			t.Fatalf("seed %d: expected mutated bytes of the same length to differ from original", seed)
+		}
+
+		if mutationA.Seed != seed || mutationA.Type != Conf_CborMut_RandomBytes {
+			t.Fatalf("seed %d: unexpected mutation %s", seed, mutationA)
+		}
+	}
+}
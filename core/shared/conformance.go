@@ -2,14 +2,12 @@ package fdoshared
 
 import (
 	"fmt"
-
-	lorem "github.com/drhodes/golorem"
 )
 
 // CONFORMANCE TESTING
-func Conf_NewRandomSgTypeExcept(exceptSg DeviceSgType) DeviceSgType {
+func Conf_NewRandomSgTypeExcept(rnd *Conf_Rand, exceptSg DeviceSgType) DeviceSgType {
 	for {
-		randLoc := NewRandomInt(0, len(SgTypeList)-1)
+		randLoc := rnd.Int(0, len(SgTypeList)-1)
 
 		if SgTypeList[randLoc] != exceptSg {
 			return SgTypeList[randLoc]
@@ -17,9 +15,9 @@ func Conf_NewRandomSgTypeExcept(exceptSg DeviceSgType) DeviceSgType {
 	}
 }
 
-func Conf_NewRandomHashHmacAlgExcept(exceptHashAlg HashType) HashType {
+func Conf_NewRandomHashHmacAlgExcept(rnd *Conf_Rand, exceptHashAlg HashType) HashType {
 	for {
-		randLoc := NewRandomInt(0, len(HashHmacAlgs)-1)
+		randLoc := rnd.Int(0, len(HashHmacAlgs)-1)
 
 		if HashHmacAlgs[randLoc] != exceptHashAlg {
 			return HashHmacAlgs[randLoc]
@@ -27,9 +25,9 @@ func Conf_NewRandomHashHmacAlgExcept(exceptHashAlg HashType) HashType {
 	}
 }
 
-func Conf_NewRandomFdoPkTypeExcept(exceptAlg FdoPkType) FdoPkType {
+func Conf_NewRandomFdoPkTypeExcept(rnd *Conf_Rand, exceptAlg FdoPkType) FdoPkType {
 	for {
-		randLoc := NewRandomInt(0, len(FdoPkType_List)-1)
+		randLoc := rnd.Int(0, len(FdoPkType_List)-1)
 
 		if FdoPkType_List[randLoc] != exceptAlg {
 			return FdoPkType_List[randLoc]
@@ -37,9 +35,13 @@ func Conf_NewRandomFdoPkTypeExcept(exceptAlg FdoPkType) FdoPkType {
 	}
 }
 
-func Conf_NewRandomFdoPkEncExcept(exceptAlg FdoPkEnc) FdoPkEnc {
+func Conf_NewRandomFdoPkEnc(rnd *Conf_Rand) FdoPkEnc {
+	return FdoPkEnc_List[rnd.Int(0, len(FdoPkEnc_List))]
+}
+
+func Conf_NewRandomFdoPkEncExcept(rnd *Conf_Rand, exceptAlg FdoPkEnc) FdoPkEnc {
 	for {
-		randLoc := NewRandomInt(0, len(FdoPkEnc_List)-1)
+		randLoc := rnd.Int(0, len(FdoPkEnc_List))
 
 		if FdoPkEnc_List[randLoc] != exceptAlg {
 			return FdoPkEnc_List[randLoc]
@@ -47,17 +49,17 @@ func Conf_NewRandomFdoPkEncExcept(exceptAlg FdoPkEnc) FdoPkEnc {
 	}
 }
 
-func Conf_RandomTestHashHmac(hashHmac HashOrHmac, originalPayload []byte, originalMasterSecret []byte) *HashOrHmac {
+func Conf_RandomTestHashHmac(rnd *Conf_Rand, hashHmac HashOrHmac, originalPayload []byte, originalMasterSecret []byte) *HashOrHmac {
 	newHashHmac := HashOrHmac{
 		Type: hashHmac.Type,
 		Hash: hashHmac.Hash,
 	}
 
-	randomNumber := NewRandomInt(0, 150)
+	randomNumber := rnd.Int(0, 150)
 	if randomNumber < 50 {
-		newHashHmac.Hash = NewRandomBuffer(len(newHashHmac.Hash))
+		newHashHmac.Hash = rnd.Buffer(len(newHashHmac.Hash))
 	} else if randomNumber > 100 {
-		newHashHmac.Type = Conf_NewRandomHashHmacAlgExcept(newHashHmac.Type)
+		newHashHmac.Type = Conf_NewRandomHashHmacAlgExcept(rnd, newHashHmac.Type)
 	} else {
 		switch newHashHmac.Type {
 		case HASH_SHA256, HASH_SHA384:
@@ -97,10 +99,10 @@ var Conf_CborTypes_List []Conf_CborTypes = []Conf_CborTypes{
 	Conf_CType_ByteArray,
 }
 
-func Conf_RandomTypeExcept(exceptType *Conf_CborTypes) interface{} {
+func Conf_RandomTypeExcept(rnd *Conf_Rand, exceptType *Conf_CborTypes) interface{} {
 	var chosenType Conf_CborTypes
 	for {
-		randLoc := NewRandomInt(0, len(Conf_CborTypes_List)-1)
+		randLoc := rnd.Int(0, len(Conf_CborTypes_List)-1)
 
 		if exceptType == nil || Conf_CborTypes_List[randLoc] != *exceptType {
 			chosenType = Conf_CborTypes_List[randLoc]
@@ -110,65 +112,65 @@ func Conf_RandomTypeExcept(exceptType *Conf_CborTypes) interface{} {
 
 	switch chosenType {
 	case Conf_CType_String:
-		return lorem.Word(1, 50)
+		return rnd.String(rnd.Int(1, 50))
 	case Conf_CType_Number:
-		return NewRandomInt(0, 61904)
+		return rnd.Int(0, 61904)
 	case Conf_CType_Map:
 		return map[string]interface{}{
-			lorem.Word(1, 2): Conf_RandomTypeExcept(nil),
+			rnd.String(rnd.Int(1, 2)): Conf_RandomTypeExcept(rnd, nil),
 		}
 	case Conf_CType_Array:
 		return []interface{}{
-			Conf_RandomTypeExcept(nil),
-			Conf_RandomTypeExcept(nil),
+			Conf_RandomTypeExcept(rnd, nil),
+			Conf_RandomTypeExcept(rnd, nil),
 		}
 	case Conf_CType_ByteArray:
-		return NewRandomBuffer(NewRandomInt(NewRandomInt(0, 51), NewRandomInt(51, 215)))
+		return rnd.Buffer(rnd.Int(rnd.Int(0, 51), rnd.Int(51, 215)))
 	default:
-		return NewRandomInt(0, 61904)
+		return rnd.Int(0, 61904)
 	}
 }
 
-func Conf_RandomTestFuzzPublicKey(pubKey FdoPublicKey) *FdoPublicKey {
+func Conf_RandomTestFuzzPublicKey(rnd *Conf_Rand, pubKey FdoPublicKey) *FdoPublicKey {
 	newPubKey := FdoPublicKey{
 		PkType: pubKey.PkType,
 		PkEnc:  pubKey.PkEnc,
 		PkBody: pubKey.PkBody,
 	}
 
-	randomNumber := NewRandomInt(0, 150)
+	randomNumber := rnd.Int(0, 150)
 	if randomNumber < 50 {
-		newPubKey.PkBody = Conf_RandomTypeExcept(nil)
+		newPubKey.PkBody = Conf_RandomTypeExcept(rnd, nil)
 	} else if randomNumber < 100 {
-		newPubKey.PkEnc = Conf_NewRandomFdoPkEncExcept(newPubKey.PkEnc)
+		newPubKey.PkEnc = Conf_NewRandomFdoPkEncExcept(rnd, newPubKey.PkEnc)
 	} else {
-		newPubKey.PkType = Conf_NewRandomFdoPkTypeExcept(newPubKey.PkType)
+		newPubKey.PkType = Conf_NewRandomFdoPkTypeExcept(rnd, newPubKey.PkType)
 	}
 
 	return &newPubKey
 }
 
-func Conf_RandomCborBufferFuzzing(inputBuff []byte) []byte {
+func Conf_RandomCborBufferFuzzing(rnd *Conf_Rand, inputBuff []byte) []byte {
 	maxFuzzRange := len(inputBuff) / 3
 	actualFuzzRange := maxFuzzRange / 2
 
-	newRandomBuffLength := NewRandomInt(maxFuzzRange-actualFuzzRange, maxFuzzRange)
+	newRandomBuffLength := rnd.Int(maxFuzzRange-actualFuzzRange, maxFuzzRange)
 
 	var newBuffer []byte = make([]byte, len(inputBuff))
-	copy(newBuffer, NewRandomBuffer(newRandomBuffLength))
+	copy(newBuffer, rnd.Buffer(newRandomBuffLength))
 
 	return newBuffer
 }
 
-func Conf_RandomTestFuzzSigInfo(sigInfo SigInfo) SigInfo {
+func Conf_RandomTestFuzzSigInfo(rnd *Conf_Rand, sigInfo SigInfo) SigInfo {
 	newSigInfo := SigInfo{
 		SgType: sigInfo.SgType,
 		Info:   sigInfo.Info,
 	}
 
-	randomNumber := NewRandomInt(0, 100)
+	randomNumber := rnd.Int(0, 100)
 	if randomNumber < 50 {
-		newSigInfo.SgType = DeviceSgType(NewRandomInt(12, 6312))
+		newSigInfo.SgType = DeviceSgType(rnd.Int(12, 6312))
 	} else {
 		newSigInfo.Info = []byte{}
 	}
@@ -200,13 +202,13 @@ var Conf_EncFuzzTypes_List_EMB []Conf_EncFuzzTypes = []Conf_EncFuzzTypes{
 	Conf_EncFuzz_Output,
 }
 
-func Conf_Fuzz_AddWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+func Conf_Fuzz_AddWrapping(rnd *Conf_Rand, payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
 	var encryptedBytes []byte
 	var err error
 
 	switch cipherSuite {
 	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CBC, CIPHER_COSE_AES256_CTR:
-		var chosenType Conf_EncFuzzTypes = Conf_EncFuzzTypes_List_ETM[NewRandomInt(0, len(Conf_EncFuzzTypes_List_ETM)-1)]
+		var chosenType Conf_EncFuzzTypes = Conf_EncFuzzTypes_List_ETM[rnd.Int(0, len(Conf_EncFuzzTypes_List_ETM)-1)]
 
 		encryptedBytes, err = encryptETM(payload, sessionKeyInfo, cipherSuite)
 		if err != nil {
@@ -220,21 +222,21 @@ func Conf_Fuzz_AddWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipher
 		CborCust.Unmarshal(outerBlock.Payload, &innerBlock)
 
 		if chosenType == Conf_EncFuzz_Payload {
-			outerBlock.Payload = Conf_RandomCborBufferFuzzing(outerBlock.Payload)
+			outerBlock.Payload = Conf_RandomCborBufferFuzzing(rnd, outerBlock.Payload)
 		}
 
 		if chosenType == Conf_EncFuzz_Tag {
-			outerBlock.Tag = Conf_RandomCborBufferFuzzing(outerBlock.Tag)
+			outerBlock.Tag = Conf_RandomCborBufferFuzzing(rnd, outerBlock.Tag)
 		}
 
 		if chosenType == Conf_EncFuzz_Ciphertext {
-			innerBlock.Ciphertext = Conf_RandomCborBufferFuzzing(innerBlock.Ciphertext)
+			innerBlock.Ciphertext = Conf_RandomCborBufferFuzzing(rnd, innerBlock.Ciphertext)
 			innerBytes, _ := CborCust.Marshal(innerBlock)
 			outerBlock.Payload = innerBytes
 		}
 
 		if chosenType == Conf_EncFuzz_IV {
-			randBuff := NewRandomBuffer(len(*innerBlock.Unprotected.AESIV))
+			randBuff := rnd.Buffer(len(*innerBlock.Unprotected.AESIV))
 			innerBlock.Unprotected.AESIV = &randBuff
 			innerBytes, _ := CborCust.Marshal(innerBlock)
 			outerBlock.Payload = innerBytes
@@ -243,11 +245,11 @@ func Conf_Fuzz_AddWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipher
 		encryptedBytes, err = CborCust.Marshal(outerBlock)
 
 		if chosenType == Conf_EncFuzz_Output {
-			encryptedBytes = Conf_RandomCborBufferFuzzing(encryptedBytes)
+			encryptedBytes = Conf_RandomCborBufferFuzzing(rnd, encryptedBytes)
 		}
 
 	case CIPHER_A128GCM, CIPHER_A256GCM:
-		var chosenType Conf_EncFuzzTypes = Conf_EncFuzzTypes_List_EMB[NewRandomInt(0, len(Conf_EncFuzzTypes_List_EMB)-1)]
+		var chosenType Conf_EncFuzzTypes = Conf_EncFuzzTypes_List_EMB[rnd.Int(0, len(Conf_EncFuzzTypes_List_EMB)-1)]
 
 		encryptedBytes, err = encryptEMB(payload, sessionKeyInfo, cipherSuite)
 		if err != nil {
@@ -258,18 +260,18 @@ func Conf_Fuzz_AddWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipher
 		CborCust.Unmarshal(encryptedBytes, &embBlock)
 
 		if chosenType == Conf_EncFuzz_Ciphertext {
-			embBlock.Ciphertext = Conf_RandomCborBufferFuzzing(embBlock.Ciphertext)
+			embBlock.Ciphertext = Conf_RandomCborBufferFuzzing(rnd, embBlock.Ciphertext)
 		}
 
 		if chosenType == Conf_EncFuzz_IV {
-			randBuff := NewRandomBuffer(len(*embBlock.Unprotected.AESIV))
+			randBuff := rnd.Buffer(len(*embBlock.Unprotected.AESIV))
 			embBlock.Unprotected.AESIV = &randBuff
 		}
 
 		encryptedBytes, err = CborCust.Marshal(embBlock)
 
 		if chosenType == Conf_EncFuzz_Output {
-			encryptedBytes = Conf_RandomCborBufferFuzzing(encryptedBytes)
+			encryptedBytes = Conf_RandomCborBufferFuzzing(rnd, encryptedBytes)
 		}
 
 	default:
@@ -295,18 +297,18 @@ var Conf_CoseSign_Field_List []Conf_CoseSign_Field = []Conf_CoseSign_Field{
 	Conf_CoseSign_Field_Signature,
 }
 
-func Conf_Fuzz_CoseSignature(coseSignature CoseSignature) CoseSignature {
-	var chosenType Conf_CoseSign_Field = Conf_CoseSign_Field_List[NewRandomInt(0, len(Conf_CoseSign_Field_List)-1)]
+func Conf_Fuzz_CoseSignature(rnd *Conf_Rand, coseSignature CoseSignature) CoseSignature {
+	var chosenType Conf_CoseSign_Field = Conf_CoseSign_Field_List[rnd.Int(0, len(Conf_CoseSign_Field_List)-1)]
 
 	switch chosenType {
 	case Conf_CoseSign_Field_Protected:
-		coseSignature.Protected = NewRandomBuffer(NewRandomInt(5, 49))
+		coseSignature.Protected = rnd.Buffer(rnd.Int(5, 49))
 	case Conf_CoseSign_Field_Unprotected:
 		coseSignature.Unprotected = UnprotectedHeader{}
 	case Conf_CoseSign_Field_Payload:
-		coseSignature.Payload = Conf_RandomCborBufferFuzzing(coseSignature.Payload)
+		coseSignature.Payload = Conf_RandomCborBufferFuzzing(rnd, coseSignature.Payload)
 	default:
-		coseSignature.Signature = Conf_RandomCborBufferFuzzing(coseSignature.Signature)
+		coseSignature.Signature = Conf_RandomCborBufferFuzzing(rnd, coseSignature.Signature)
 	}
 
 	return coseSignature
@@ -0,0 +1,115 @@
+package fdoshared
+
+import (
+	"crypto/rsa"
+	"errors"
+	"math/big"
+
+	"github.com/fido-alliance/dhkx"
+)
+
+// Key exchanges for the KEX negative tests. For the device side xAKeyExchange tests, if the test does not apply
+// to the negotiated suite, the key exchange of another suite is returned instead, which the device must reject just as well
+
+// Returns xAKeyExchange of another suite. Suites have distinct xAKeyExchange lengths
+func Conf_NewXAKeyExchange_OtherSuite(rnd *Conf_Rand, kexSuitName KexSuiteName) ([]byte, error) {
+	for {
+		otherKexSuitName := KexSuitNames[rnd.Int(0, len(KexSuitNames))]
+		if otherKexSuitName == kexSuitName {
+			continue
+		}
+
+		kex, err := GenerateXABKeyExchange(otherKexSuitName, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return kex.XAKeyExchange, nil
+	}
+}
+
+// ECDH public key that is not on the negotiated curve
+func Conf_NewXAKeyExchange_OffCurve(rnd *Conf_Rand, kexSuitName KexSuiteName) ([]byte, error) {
+	if kexSuitName != KEX_ECDH256 && kexSuitName != KEX_ECDH384 {
+		return Conf_NewXAKeyExchange_OtherSuite(rnd, kexSuitName)
+	}
+
+	curve, randomLen := ecdhKexSuiteParams(kexSuitName)
+	coordLen := (curve.Params().BitSize + 7) / 8
+
+	for {
+		x := new(big.Int).SetBytes(rnd.Buffer(coordLen))
+		y := new(big.Int).SetBytes(rnd.Buffer(coordLen))
+		if x.Cmp(curve.Params().P) >= 0 || y.Cmp(curve.Params().P) >= 0 || curve.IsOnCurve(x, y) {
+			continue
+		}
+
+		return encodeEcdhKeyExchange(x.FillBytes(make([]byte, coordLen)), y.FillBytes(make([]byte, coordLen)), rnd.Buffer(randomLen)), nil
+	}
+}
+
+// DH public value of 0, 1 or p-1
+func Conf_NewXAKeyExchange_WeakDH(rnd *Conf_Rand, kexSuitName KexSuiteName) ([]byte, error) {
+	if kexSuitName != KEX_DHKEXid14 && kexSuitName != KEX_DHKEXid15 {
+		return Conf_NewXAKeyExchange_OtherSuite(rnd, kexSuitName)
+	}
+
+	group, err := dhkx.GetGroup(dhkexGroupID(kexSuitName))
+	if err != nil {
+		return nil, errors.New("error getting DH group. " + err.Error())
+	}
+
+	weakValues := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(group.P(), big.NewInt(1)),
+	}
+
+	return weakValues[rnd.Int(0, len(weakValues))].FillBytes(make([]byte, dhkexPublicValueLen(group))), nil
+}
+
+// Valid ECDH public key or ASYMKEX owner random, with the owner random shorter than the suite requires
+func Conf_NewXAKeyExchange_ShortRandom(rnd *Conf_Rand, kexSuitName KexSuiteName) ([]byte, error) {
+	switch kexSuitName {
+	case KEX_ECDH256, KEX_ECDH384:
+		curve, randomLen := ecdhKexSuiteParams(kexSuitName)
+
+		kex, err := GenerateXABKeyExchange(kexSuitName, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		x, y, _, err := parseEcdhKeyExchange(kex.XAKeyExchange, curve, randomLen)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeEcdhKeyExchange(x.Bytes(), y.Bytes(), rnd.Buffer(rnd.Int(0, randomLen))), nil
+	case KEX_ASYMKEX2048, KEX_ASYMKEX3072:
+		return rnd.Buffer(rnd.Int(0, asymKexRandomLen(kexSuitName))), nil
+	default:
+		return Conf_NewXAKeyExchange_OtherSuite(rnd, kexSuitName)
+	}
+}
+
+// Device ASYMKEX xBKeyExchange that is a valid RSA ciphertext for the owner key, but does not decode as OAEP.
+// Plaintext block starts with 0x01, and OAEP requires 0x00
+func Conf_NewXBKeyExchange_BadOAEP(rnd *Conf_Rand, ownerPubKey FdoPublicKey) ([]byte, error) {
+	pubKeyInst, err := ExtractPublicKey(ownerPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaPubKey, ok := pubKeyInst.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("error generating bad OAEP xBKeyExchange. Owner public key is not RSA")
+	}
+
+	block := rnd.Buffer(rsaPubKey.Size())
+	block[0] = 0x01
+
+	m := new(big.Int).SetBytes(block)
+	c := new(big.Int).Exp(m, big.NewInt(int64(rsaPubKey.E)), rsaPubKey.N)
+
+	return c.FillBytes(make([]byte, rsaPubKey.Size())), nil
+}
@@ -0,0 +1,106 @@
+package fdoshared
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	mathrand "math/rand"
+	"sync"
+)
+
+// CONFORMANCE RANDOMNESS
+//
+// Conf_Rand drives test selection and fuzzing. It is seeded per test run, so that a failed run can be replayed.
+// Key material, nonces and IVs used by the protocol itself must keep using crypto/rand.
+// A nil *Conf_Rand is valid and falls back to crypto/rand.
+
+type Conf_Rand struct {
+	seed int64
+	mu   sync.Mutex
+	rnd  *mathrand.Rand
+}
+
+func NewConf_Rand(seed int64) *Conf_Rand {
+	return &Conf_Rand{
+		seed: seed,
+		rnd:  mathrand.New(mathrand.NewSource(seed)),
+	}
+}
+
+// Returns new random positive seed
+func NewConf_Seed() int64 {
+	seedBint, _ := rand.Int(rand.Reader, big.NewInt(int64(^uint64(0)>>1)))
+	return seedBint.Int64()
+}
+
+// Derives independent random source for the label, e.g. test id, from the run seed.
+// Same seed and label always produce the same sequence, regardless of the order tests are executed in.
+func Conf_DeriveRand(runSeed int64, label string) *Conf_Rand {
+	seedBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(seedBytes, uint64(runSeed))
+
+	derivedHash := sha256.Sum256(append(seedBytes, []byte(label)...))
+
+	return NewConf_Rand(int64(binary.BigEndian.Uint64(derivedHash[0:8]) >> 1))
+}
+
+func (h *Conf_Rand) Seed() int64 {
+	if h == nil {
+		return 0
+	}
+
+	return h.seed
+}
+
+// Returns random int in [min, max)
+func (h *Conf_Rand) Int(min int, max int) int {
+	if h == nil {
+		return NewRandomInt(min, max)
+	}
+
+	if min >= max {
+		return min
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return min + h.rnd.Intn(max-min)
+}
+
+func (h *Conf_Rand) Int63() int64 {
+	if h == nil {
+		return NewConf_Seed()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.rnd.Int63()
+}
+
+func (h *Conf_Rand) Buffer(size int) []byte {
+	if h == nil {
+		return NewRandomBuffer(size)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buff := make([]byte, size)
+	h.rnd.Read(buff)
+
+	return buff
+}
+
+func (h *Conf_Rand) String(size int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	result := make([]byte, size)
+	for i := range result {
+		result[i] = alphabet[h.Int(0, len(alphabet))]
+	}
+
+	return string(result)
+}
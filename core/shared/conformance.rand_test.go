@@ -0,0 +1,64 @@
+package fdoshared
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConf_DeriveRand(t *testing.T) {
+	randA := Conf_DeriveRand(1234, "FIDO_DOT_62_BAD_ENCODING")
+	randB := Conf_DeriveRand(1234, "FIDO_DOT_62_BAD_ENCODING")
+	randC := Conf_DeriveRand(1234, "FIDO_DOT_70_BAD_ENCODING")
+
+	buffA := randA.Buffer(32)
+	if !bytes.Equal(buffA, randB.Buffer(32)) {
+		t.Fatalf("expected same seed and label to produce the same sequence")
+	}
+
+	if bytes.Equal(buffA, randC.Buffer(32)) {
+		t.Fatalf("expected different labels to produce different sequences")
+	}
+
+	for i := 0; i < 100; i++ {
+		if randA.Int(5, 10) != randB.Int(5, 10) {
+			t.Fatalf("expected same seed and label to produce the same sequence")
+		}
+	}
+}
+
+func TestConf_Rand_Nil(t *testing.T) {
+	var rnd *Conf_Rand
+
+	if len(rnd.Buffer(16)) != 16 {
+		t.Fatalf("expected nil random source to fall back to crypto/rand")
+	}
+
+	randomInt := rnd.Int(3, 7)
+	if randomInt < 3 || randomInt >= 7 {
+		t.Fatalf("expected int in range [3, 7). Got %d", randomInt)
+	}
+}
+
+func TestConf_NewRandomFdoPkEnc_AllEncodings(t *testing.T) {
+	rnd := NewConf_Rand(1)
+
+	drawn := map[FdoPkEnc]bool{}
+	drawnExcept := map[FdoPkEnc]bool{}
+	for i := 0; i < 200; i++ {
+		drawn[Conf_NewRandomFdoPkEnc(rnd)] = true
+
+		pkEnc := Conf_NewRandomFdoPkEncExcept(rnd, FdoPkEnc_List[0])
+		if pkEnc == FdoPkEnc_List[0] {
+			t.Fatalf("expected %d to be excluded", FdoPkEnc_List[0])
+		}
+		drawnExcept[pkEnc] = true
+	}
+
+	if len(drawn) != len(FdoPkEnc_List) {
+		t.Errorf("expected every encoding to be drawn. Got %v", drawn)
+	}
+
+	if len(drawnExcept) != len(FdoPkEnc_List)-1 {
+		t.Errorf("expected every other encoding to be drawn. Got %v", drawnExcept)
+	}
+}
@@ -0,0 +1,234 @@
+package fdoshared
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Device secrets, the attestation private key and the ownership HMAC secret, are used through the credential store.
+// The TO1 and TO2 requestors never read them from the credential directly
+type DeviceCredStore interface {
+	// Signs payload with the device attestation key
+	Sign(payload []byte, protected ProtectedHeader, unprotected UnprotectedHeader, sgType DeviceSgType) (*CoseSignature, error)
+	// Computes HMAC with the device HMAC secret
+	Hmac(data []byte, hmacAlg HashType) (*HashOrHmac, error)
+	// Verifies HMAC with the device HMAC secret
+	VerifyHmac(data []byte, inputHmac HashOrHmac) error
+}
+
+type DeviceSecrets struct {
+	_ struct{} `cbor:",toarray"`
+
+	DCPrivateKeyDer []byte
+	DCHmacSecret    []byte
+}
+
+func (h DeviceSecrets) Sign(payload []byte, protected ProtectedHeader, unprotected UnprotectedHeader, sgType DeviceSgType) (*CoseSignature, error) {
+	privateKeyInst, err := ExtractPrivateKey(h.DCPrivateKeyDer)
+	if err != nil {
+		return nil, errors.New("error extracting device private key. " + err.Error())
+	}
+
+	return GenerateCoseSignature(payload, protected, unprotected, privateKeyInst, sgType)
+}
+
+func (h DeviceSecrets) Hmac(data []byte, hmacAlg HashType) (*HashOrHmac, error) {
+	hmacInst, err := GenerateFdoHmac(data, hmacAlg, h.DCHmacSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hmacInst, nil
+}
+
+func (h DeviceSecrets) VerifyHmac(data []byte, inputHmac HashOrHmac) error {
+	return VerifyHMac(data, inputHmac, h.DCHmacSecret)
+}
+
+// Plaintext store for the credentials kept in the DB or the unsealed PEM files
+func NewDeviceCredStore(credential WawDeviceCredential) DeviceCredStore {
+	return DeviceSecrets{
+		DCPrivateKeyDer: credential.DCPrivateKeyDer,
+		DCHmacSecret:    credential.DCHmacSecret,
+	}
+}
+
+type DeviceCredSealType string
+
+const (
+	DEVICE_CRED_SEAL_PASSPHRASE DeviceCredSealType = "passphrase"
+	DEVICE_CRED_SEAL_TPM        DeviceCredSealType = "tpm"
+)
+
+// Sealer protects the key that encrypts the device secrets at rest
+type DeviceCredSealer interface {
+	SealType() DeviceCredSealType
+	// Returns new secrets encryption key, and the sealed key blob that is stored with the credential
+	NewSealingKey() ([]byte, []byte, error)
+	// Recovers secrets encryption key from the sealed key blob
+	UnsealKey(sealedKey []byte) ([]byte, error)
+}
+
+// Device credential with the secrets encrypted at rest. The embedded credential has no secrets
+type SealedDeviceCredential struct {
+	_ struct{} `cbor:",toarray"`
+
+	SealType         DeviceCredSealType
+	SealedKey        []byte
+	EncryptedSecrets []byte
+	Credential       WawDeviceCredential
+}
+
+func SealDeviceCredential(credential WawDeviceCredential, sealer DeviceCredSealer) (*SealedDeviceCredential, error) {
+	secretsBytes, err := CborCust.Marshal(DeviceSecrets{
+		DCPrivateKeyDer: credential.DCPrivateKeyDer,
+		DCHmacSecret:    credential.DCHmacSecret,
+	})
+	if err != nil {
+		return nil, errors.New("error marshaling device secrets. " + err.Error())
+	}
+
+	key, sealedKey, err := sealer.NewSealingKey()
+	if err != nil {
+		return nil, errors.New("error generating sealing key. " + err.Error())
+	}
+
+	aesGcm, err := newSealingAead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := NewRandomBuffer(aesGcm.NonceSize())
+	encryptedSecrets := aesGcm.Seal(nonce, nonce, secretsBytes, []byte(sealer.SealType()))
+
+	credential.DCPrivateKeyDer = nil
+	credential.DCHmacSecret = nil
+
+	return &SealedDeviceCredential{
+		SealType:         sealer.SealType(),
+		SealedKey:        sealedKey,
+		EncryptedSecrets: encryptedSecrets,
+		Credential:       credential,
+	}, nil
+}
+
+func (h SealedDeviceCredential) unseal(sealer DeviceCredSealer) (*DeviceSecrets, error) {
+	if sealer.SealType() != h.SealType {
+		return nil, fmt.Errorf("credential is sealed with %s, but the sealer is %s", h.SealType, sealer.SealType())
+	}
+
+	key, err := sealer.UnsealKey(h.SealedKey)
+	if err != nil {
+		return nil, errors.New("error unsealing key. " + err.Error())
+	}
+
+	aesGcm, err := newSealingAead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.EncryptedSecrets) < aesGcm.NonceSize() {
+		return nil, errors.New("encrypted secrets are too short")
+	}
+
+	nonce := h.EncryptedSecrets[:aesGcm.NonceSize()]
+	secretsBytes, err := aesGcm.Open(nil, nonce, h.EncryptedSecrets[aesGcm.NonceSize():], []byte(h.SealType))
+	if err != nil {
+		return nil, errors.New("error decrypting device secrets. " + err.Error())
+	}
+
+	var secrets DeviceSecrets
+	err = CborCust.Unmarshal(secretsBytes, &secrets)
+	if err != nil {
+		return nil, errors.New("error decoding device secrets. " + err.Error())
+	}
+
+	return &secrets, nil
+}
+
+func newSealingAead(key []byte) (cipher.AEAD, error) {
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("error creating sealing cipher. " + err.Error())
+	}
+
+	return cipher.NewGCM(blockCipher)
+}
+
+// Unseals the secrets for every operation, so they are only kept in memory for the duration of it
+type sealedDeviceCredStore struct {
+	sealed SealedDeviceCredential
+	sealer DeviceCredSealer
+}
+
+func NewSealedDeviceCredStore(sealed SealedDeviceCredential, sealer DeviceCredSealer) DeviceCredStore {
+	return sealedDeviceCredStore{
+		sealed: sealed,
+		sealer: sealer,
+	}
+}
+
+func (h sealedDeviceCredStore) Sign(payload []byte, protected ProtectedHeader, unprotected UnprotectedHeader, sgType DeviceSgType) (*CoseSignature, error) {
+	secrets, err := h.sealed.unseal(h.sealer)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.Sign(payload, protected, unprotected, sgType)
+}
+
+func (h sealedDeviceCredStore) Hmac(data []byte, hmacAlg HashType) (*HashOrHmac, error) {
+	secrets, err := h.sealed.unseal(h.sealer)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.Hmac(data, hmacAlg)
+}
+
+func (h sealedDeviceCredStore) VerifyHmac(data []byte, inputHmac HashOrHmac) error {
+	secrets, err := h.sealed.unseal(h.sealer)
+	if err != nil {
+		return err
+	}
+
+	return secrets.VerifyHmac(data, inputHmac)
+}
+
+const passphraseSaltLength int = 16
+
+// Derives sealing key from the passphrase with scrypt. The sealed key blob is the salt
+type PassphraseSealer struct {
+	Passphrase string
+}
+
+func (h PassphraseSealer) SealType() DeviceCredSealType {
+	return DEVICE_CRED_SEAL_PASSPHRASE
+}
+
+func (h PassphraseSealer) NewSealingKey() ([]byte, []byte, error) {
+	salt := NewRandomBuffer(passphraseSaltLength)
+
+	key, err := h.UnsealKey(salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, salt, nil
+}
+
+func (h PassphraseSealer) UnsealKey(sealedKey []byte) ([]byte, error) {
+	if h.Passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+
+	if len(sealedKey) != passphraseSaltLength {
+		return nil, errors.New("bad passphrase salt length")
+	}
+
+	return scrypt.Key([]byte(h.Passphrase), sealedKey, 1<<15, 8, 1, 32)
+}
@@ -0,0 +1,148 @@
+package fdoshared
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/google/go-tpm/tpmutil/mssim"
+)
+
+const tpmSealingKeyLength int = 32
+
+// Storage primary key template. The primary is re-derived from the owner seed on every use, so it is never persisted
+var tpmSrkTemplate = tpm2.Public{
+	Type:       tpm2.AlgECC,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagStorageDefault | tpm2.FlagNoDA,
+	ECCParameters: &tpm2.ECCParams{
+		Symmetric: &tpm2.SymScheme{
+			Alg:     tpm2.AlgAES,
+			KeyBits: 128,
+			Mode:    tpm2.AlgCFB,
+		},
+		CurveID: tpm2.CurveNISTP256,
+	},
+}
+
+type tpmSealedKey struct {
+	_ struct{} `cbor:",toarray"`
+
+	Public  []byte
+	Private []byte
+}
+
+// Seals the secrets encryption key to the TPM storage hierarchy. Talks to the TPM simulator, e.g. swtpm, over the MS simulator TCP interface
+type TPMSealer struct {
+	// Command port address, e.g. 127.0.0.1:2321. The platform port is the next one
+	SimulatorAddress string
+}
+
+func (h TPMSealer) SealType() DeviceCredSealType {
+	return DEVICE_CRED_SEAL_TPM
+}
+
+func (h TPMSealer) open() (io.ReadWriteCloser, error) {
+	host, port, err := net.SplitHostPort(h.SimulatorAddress)
+	if err != nil {
+		return nil, errors.New("bad TPM simulator address. " + err.Error())
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, errors.New("bad TPM simulator port. " + err.Error())
+	}
+
+	tpmConn, err := mssim.Open(mssim.Config{
+		CommandAddress:  h.SimulatorAddress,
+		PlatformAddress: net.JoinHostPort(host, strconv.Itoa(portNum+1)),
+	})
+	if err != nil {
+		return nil, errors.New("error connecting to TPM simulator. " + err.Error())
+	}
+
+	// Simulator is power cycled on connect
+	err = tpm2.Startup(tpmConn, tpm2.StartupClear)
+	if err != nil {
+		tpmConn.Close()
+		return nil, errors.New("error starting TPM. " + err.Error())
+	}
+
+	return tpmConn, nil
+}
+
+func (h TPMSealer) createSrk(tpmConn io.ReadWriter) (tpmutil.Handle, error) {
+	srkHandle, _, err := tpm2.CreatePrimary(tpmConn, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", tpmSrkTemplate)
+	if err != nil {
+		return 0, errors.New("error creating TPM storage key. " + err.Error())
+	}
+
+	return srkHandle, nil
+}
+
+func (h TPMSealer) NewSealingKey() ([]byte, []byte, error) {
+	tpmConn, err := h.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tpmConn.Close()
+
+	srkHandle, err := h.createSrk(tpmConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tpm2.FlushContext(tpmConn, srkHandle)
+
+	key := NewRandomBuffer(tpmSealingKeyLength)
+
+	privateBlob, publicBlob, err := tpm2.Seal(tpmConn, srkHandle, "", "", nil, key)
+	if err != nil {
+		return nil, nil, errors.New("error sealing key. " + err.Error())
+	}
+
+	sealedKey, err := CborCust.Marshal(tpmSealedKey{
+		Public:  publicBlob,
+		Private: privateBlob,
+	})
+	if err != nil {
+		return nil, nil, errors.New("error marshaling sealed key. " + err.Error())
+	}
+
+	return key, sealedKey, nil
+}
+
+func (h TPMSealer) UnsealKey(sealedKey []byte) ([]byte, error) {
+	var sealedKeyInst tpmSealedKey
+	err := CborCust.Unmarshal(sealedKey, &sealedKeyInst)
+	if err != nil {
+		return nil, errors.New("error decoding sealed key. " + err.Error())
+	}
+
+	tpmConn, err := h.open()
+	if err != nil {
+		return nil, err
+	}
+	defer tpmConn.Close()
+
+	srkHandle, err := h.createSrk(tpmConn)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(tpmConn, srkHandle)
+
+	objectHandle, _, err := tpm2.Load(tpmConn, srkHandle, "", sealedKeyInst.Public, sealedKeyInst.Private)
+	if err != nil {
+		return nil, errors.New("error loading sealed key. " + err.Error())
+	}
+	defer tpm2.FlushContext(tpmConn, objectHandle)
+
+	key, err := tpm2.Unseal(tpmConn, objectHandle, "")
+	if err != nil {
+		return nil, errors.New("error unsealing key. " + err.Error())
+	}
+
+	return key, nil
+}
@@ -0,0 +1,114 @@
+package fdoshared
+
+import (
+	"crypto/x509"
+	"os"
+	"testing"
+)
+
+func testSealedDeviceCredStore(t *testing.T, sealer DeviceCredSealer, sgType DeviceSgType) {
+	credential, err := NewWawDeviceCredential(sgType)
+	if err != nil {
+		t.Fatalf("%d: failed to generate credential: %v", sgType, err)
+	}
+
+	sealed, err := SealDeviceCredential(*credential, sealer)
+	if err != nil {
+		t.Fatalf("%d: failed to seal credential: %v", sgType, err)
+	}
+
+	if len(sealed.Credential.DCPrivateKeyDer) != 0 || len(sealed.Credential.DCHmacSecret) != 0 {
+		t.Fatalf("%d: sealed credential contains plaintext secrets", sgType)
+	}
+
+	sealedBytes, err := CborCust.Marshal(sealed)
+	if err != nil {
+		t.Fatalf("%d: failed to marshal sealed credential: %v", sgType, err)
+	}
+
+	var decodedSealed SealedDeviceCredential
+	err = CborCust.Unmarshal(sealedBytes, &decodedSealed)
+	if err != nil {
+		t.Fatalf("%d: failed to unmarshal sealed credential: %v", sgType, err)
+	}
+
+	credStore := NewSealedDeviceCredStore(decodedSealed, sealer)
+
+	coseSig, err := credStore.Sign([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, sgType)
+	if err != nil {
+		t.Fatalf("%d: failed to sign: %v", sgType, err)
+	}
+
+	leafCert, err := x509.ParseCertificate(decodedSealed.Credential.DCCertificateChain[0])
+	if err != nil {
+		t.Fatalf("%d: failed to parse device certificate: %v", sgType, err)
+	}
+
+	devicePublicKey, err := NewFdoPublicKey(leafCert.PublicKey, sgType, X509)
+	if err != nil {
+		t.Fatalf("%d: failed to encode device public key: %v", sgType, err)
+	}
+
+	err = VerifyCoseSignature(*coseSig, *devicePublicKey)
+	if err != nil {
+		t.Fatalf("%d: failed to verify signature: %v", sgType, err)
+	}
+
+	ovHeader := []byte("test ov header")
+	expectedHmac, _ := GenerateFdoHmac(ovHeader, credential.DCHmacAlg, credential.DCHmacSecret)
+
+	hmacInst, err := credStore.Hmac(ovHeader, credential.DCHmacAlg)
+	if err != nil {
+		t.Fatalf("%d: failed to generate hmac: %v", sgType, err)
+	}
+
+	err = VerifyHMac(ovHeader, *hmacInst, credential.DCHmacSecret)
+	if err != nil {
+		t.Fatalf("%d: sealed store hmac does not match: %v", sgType, err)
+	}
+
+	err = credStore.VerifyHmac(ovHeader, expectedHmac)
+	if err != nil {
+		t.Fatalf("%d: failed to verify hmac: %v", sgType, err)
+	}
+}
+
+func TestSealedDeviceCredStore_Passphrase(t *testing.T) {
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1} {
+		testSealedDeviceCredStore(t, PassphraseSealer{Passphrase: "test passphrase"}, sgType)
+	}
+}
+
+func TestSealedDeviceCredStore_WrongPassphrase(t *testing.T) {
+	credential, err := NewWawDeviceCredential(StSECP256R1)
+	if err != nil {
+		t.Fatalf("failed to generate credential: %v", err)
+	}
+
+	sealed, err := SealDeviceCredential(*credential, PassphraseSealer{Passphrase: "test passphrase"})
+	if err != nil {
+		t.Fatalf("failed to seal credential: %v", err)
+	}
+
+	credStore := NewSealedDeviceCredStore(*sealed, PassphraseSealer{Passphrase: "wrong passphrase"})
+	_, err = credStore.Sign([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, StSECP256R1)
+	if err == nil {
+		t.Fatal("expected wrong passphrase to fail")
+	}
+
+	credStore = NewSealedDeviceCredStore(*sealed, TPMSealer{})
+	_, err = credStore.Sign([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, StSECP256R1)
+	if err == nil {
+		t.Fatal("expected seal type mismatch to fail")
+	}
+}
+
+// Run with swtpm: swtpm socket --tpm2 --server type=tcp,port=2321 --ctrl type=tcp,port=2322 --tpmstate dir=/tmp/swtpm --flags not-need-init
+func TestSealedDeviceCredStore_TPM(t *testing.T) {
+	simulatorAddress := os.Getenv(string(CFG_ENV_TPM_SIMULATOR))
+	if simulatorAddress == "" {
+		t.Skip("TPM_SIMULATOR is not set")
+	}
+
+	testSealedDeviceCredStore(t, TPMSealer{SimulatorAddress: simulatorAddress}, StSECP256R1)
+}
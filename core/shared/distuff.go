@@ -33,6 +33,10 @@ type WawDeviceCredential struct {
 	DCSigInfo              SigInfo
 }
 
+func (h WawDeviceCredential) SchemaVersion() uint16 {
+	return 1
+}
+
 func (h *WawDeviceCredential) UpdatedToNewHashHmac(newSgInfo SgTypeInfo) {
 	h.DCHashAlg = newSgInfo.HashType
 	h.DCHmacAlg = newSgInfo.HmacType
@@ -184,14 +188,33 @@ func CastPublicFromPrivate(priv interface{}) interface{} {
 	}
 }
 
-func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
-	if sgType != StSECP256R1 && sgType != StSECP384R1 {
-		return nil, errors.New("for device attestation only SECP256R1 and SECP384R1 are supported")
+func issueTestCertificate(publicKeyInst interface{}, guid FdoGuid, issuerCert *x509.Certificate, issuerKey interface{}) ([]byte, error) {
+	serialNumber := new(big.Int)
+	serialNumber.SetString(guid.GetFormattedHex(), 16)
+	newCertificate := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   fmt.Sprintf("WAW FDO VIRTUAL TEST %X WAW", guid.GetFormatted()),
+			Organization: []string{"FIDO Alliance"},
+			Country:      []string{"US"},
+			Locality:     []string{"San Francisco"},
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0), // 10 years
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: false,
 	}
 
-	newGuid := NewFdoGuid_FIDO()
+	newCertBytes, err := x509.CreateCertificate(rand.Reader, newCertificate, issuerCert, publicKeyInst, issuerKey)
+	if err != nil {
+		return nil, errors.New("error generating new x509 certificate! " + err.Error())
+	}
+
+	return newCertBytes, nil
+}
 
-	// Generate certificate chain
+// Issues device leaf certificate by the test intermediate. Returns leaf, intermediate and root chain
+func NewTestCertificateChain(publicKeyInst interface{}, guid FdoGuid) ([]X509CertificateBytes, error) {
 	rootCert, _ := pem.Decode([]byte(TestRootCert))
 	intermCert, _ := pem.Decode([]byte(TestIntermediateCert))
 	intermKey, _ := pem.Decode([]byte(TestIntermediateKey))
@@ -206,30 +229,60 @@ func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
 		return nil, errors.New("Error decoding intermediate key. " + err.Error())
 	}
 
-	serialNumber := new(big.Int)
-	serialNumber.SetString(newGuid.GetFormattedHex(), 16)
-	newCertificate := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			CommonName:   fmt.Sprintf("WAW FDO VIRTUAL TEST %X WAW", newGuid.GetFormatted()),
-			Organization: []string{"FIDO Alliance"},
-			Country:      []string{"US"},
-			Locality:     []string{"San Francisco"},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0), // 10 years
-		KeyUsage:              x509.KeyUsageDigitalSignature,
-		BasicConstraintsValid: false,
+	newCertBytes, err := issueTestCertificate(publicKeyInst, guid, intermCertInst, intermPrivKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []X509CertificateBytes{
+		newCertBytes, intermCert.Bytes, rootCert.Bytes,
+	}, nil
+}
+
+// Issues owner X5CHAIN leaf certificate by the test root. Test intermediate is SHA1 signed, and so is rejected by the chain verification
+func NewTestOwnerCertificateChain(publicKeyInst interface{}) ([]X509CertificateBytes, error) {
+	rootCert, _ := pem.Decode([]byte(TestRootCert))
+	rootKey, _ := pem.Decode([]byte(TestRootKey))
+
+	rootCertInst, err := x509.ParseCertificate(rootCert.Bytes)
+	if err != nil {
+		return nil, errors.New("Error decoding root certificate. " + err.Error())
+	}
+
+	rootPrivKey, err := x509.ParsePKCS8PrivateKey(rootKey.Bytes)
+	if err != nil {
+		return nil, errors.New("Error decoding root key. " + err.Error())
 	}
 
+	newCertBytes, err := issueTestCertificate(publicKeyInst, NewFdoGuid_FIDO(), rootCertInst, rootPrivKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []X509CertificateBytes{
+		newCertBytes, rootCert.Bytes,
+	}, nil
+}
+
+func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
+	if IsEpidSgType(sgType) {
+		return newEpidWawDeviceCredential(sgType)
+	}
+
+	if sgType != StSECP256R1 && sgType != StSECP384R1 && !IsExtSgType(sgType) {
+		return nil, errors.New("for device attestation only SECP256R1, SECP384R1, EPID and the algorithm extensions are supported")
+	}
+
+	newGuid := NewFdoGuid_FIDO()
+
 	newPrivateKeyInst, _, err := GenerateVoucherKeypair(sgType)
 	if err != nil {
 		return nil, err
 	}
 
-	newCertBytes, err := x509.CreateCertificate(rand.Reader, newCertificate, intermCertInst, CastPublicFromPrivate(newPrivateKeyInst), intermPrivKey)
+	dcCertificateChain, err := NewTestCertificateChain(CastPublicFromPrivate(newPrivateKeyInst), newGuid)
 	if err != nil {
-		return nil, errors.New("error generating new x509 certificate! " + err.Error())
+		return nil, err
 	}
 
 	marshaledPrivateKey, err := MarshalPrivateKey(newPrivateKeyInst, sgType)
@@ -237,10 +290,6 @@ func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
 		return nil, errors.New("error mashaling private key. " + err.Error())
 	}
 
-	dcCertificateChain := []X509CertificateBytes{
-		newCertBytes, intermCert.Bytes, rootCert.Bytes,
-	}
-
 	sgTypeInfo, ok := SgTypeInfoMap[sgType]
 	if !ok {
 		return nil, errors.New("unknown sgType")
@@ -274,9 +323,52 @@ func NewWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
 	}, nil
 }
 
-func RandomSgType() DeviceSgType {
+// EPID device is a member of the conformance test EPID group. It has no certificate chain, and SigInfo.Info is the group id
+func newEpidWawDeviceCredential(sgType DeviceSgType) (*WawDeviceCredential, error) {
+	issuerKey, err := GetTestEpidIssuerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	memberKey, err := issuerKey.NewMemberKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	memberKeyBytes, err := CborCust.Marshal(memberKey)
+	if err != nil {
+		return nil, errors.New("error mashaling EPID member key. " + err.Error())
+	}
+
+	sgTypeInfo := SgTypeInfoMap[sgType]
+
+	dcCertificateChainHash, _ := ComputeOVDevCertChainHash([]X509CertificateBytes{}, HmacToHashAlg[sgTypeInfo.HmacType])
+
+	return &WawDeviceCredential{
+		DCProtVer:    ProtVer101,
+		DCHmacSecret: NewHmacKey(sgTypeInfo.HmacType),
+
+		DCCertificateChain:     []X509CertificateBytes{},
+		DCCertificateChainHash: dcCertificateChainHash,
+
+		DCPrivateKeyDer: memberKeyBytes,
+
+		DCGuid: NewFdoGuid_FIDO(),
+		DCSigInfo: SigInfo{
+			SgType: sgType,
+			Info:   issuerKey.GroupPublicKey.GroupId,
+		},
+
+		DCHmacAlg: sgTypeInfo.HmacType,
+		DCHashAlg: sgTypeInfo.HashType,
+
+		DCDeviceInfo: "I am a virtual FIDO Alliance EPID device!",
+	}, nil
+}
+
+func RandomSgType(rnd *Conf_Rand) DeviceSgType {
 	for {
-		randLoc := NewRandomInt(0, len(SgTypeList)-1)
+		randLoc := rnd.Int(0, len(SgTypeList)-1)
 
 		if SgTypeList[randLoc] != StEPID10 && SgTypeList[randLoc] != StEPID11 {
 			return SgTypeList[randLoc]
@@ -284,9 +376,10 @@ func RandomSgType() DeviceSgType {
 	}
 }
 
-func RandomDeviceSgType() DeviceSgType {
+// EPID is excluded, as local EPID test group signatures can only be verified by this tool
+func RandomDeviceSgType(rnd *Conf_Rand) DeviceSgType {
 	for {
-		randLoc := NewRandomInt(0, len(DeviceSgTypeList)-1)
+		randLoc := rnd.Int(0, len(DeviceSgTypeList)-1)
 
 		if DeviceSgTypeList[randLoc] != StEPID10 && DeviceSgTypeList[randLoc] != StEPID11 {
 			return DeviceSgTypeList[randLoc]
@@ -1,6 +1,7 @@
 package fdoshared
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
@@ -25,8 +26,8 @@ const (
 	CIPHER_AES_CCM_64_128_256 CipherSuiteName = 33        // AES-CCM mode 256-bit key, 128-bit tag, 7-byte nonce
 	CIPHER_COSE_AES128_CBC    CipherSuiteName = -17760703 // CS_AES128_CBC_HMAC-SHA256
 	CIPHER_COSE_AES128_CTR    CipherSuiteName = -17760704 // CS_AES128_CTR_HMAC-SHA256
-	CIPHER_COSE_AES256_CBC    CipherSuiteName = -17760705 // CS_AES256_CTR_HMAC-SHA384
-	CIPHER_COSE_AES256_CTR    CipherSuiteName = -17760706 // CS_AES256_CBC_HMAC-SHA384
+	CIPHER_COSE_AES256_CBC    CipherSuiteName = -17760705 // CS_AES256_CBC_HMAC-SHA384
+	CIPHER_COSE_AES256_CTR    CipherSuiteName = -17760706 // CS_AES256_CTR_HMAC-SHA384
 )
 
 type CipherInfo struct {
@@ -67,7 +68,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA384,
 		HashAlg:    HASH_SHA384,
 		KdfHmacAlg: HASH_HMAC_SHA384,
-		NonceIvLen: 32,
+		NonceIvLen: 16,
 		SekLen:     32,
 		SvkLen:     64,
 	},
@@ -77,7 +78,7 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 		HmacAlg:    HASH_HMAC_SHA384,
 		HashAlg:    HASH_SHA384,
 		KdfHmacAlg: HASH_HMAC_SHA384,
-		NonceIvLen: 32,
+		NonceIvLen: 16,
 		SekLen:     32,
 		SvkLen:     64,
 	},
@@ -141,6 +142,19 @@ var CipherSuitesInfoMap map[CipherSuiteName]CipherInfo = map[CipherSuiteName]Cip
 	},
 }
 
+var CipherSuiteNames []CipherSuiteName = []CipherSuiteName{
+	CIPHER_A128GCM,
+	CIPHER_A256GCM,
+	CIPHER_AES_CCM_16_128_128,
+	CIPHER_AES_CCM_16_128_256,
+	CIPHER_AES_CCM_64_128_128,
+	CIPHER_AES_CCM_64_128_256,
+	CIPHER_COSE_AES128_CBC,
+	CIPHER_COSE_AES128_CTR,
+	CIPHER_COSE_AES256_CBC,
+	CIPHER_COSE_AES256_CTR,
+}
+
 /*
 COSE_Mac0[
     {1:5}, # protected: alg:SHA256
@@ -193,9 +207,24 @@ type COSEEncStructure struct { // It's just a god damn AAD FFS!
 const CONST_KDF_LABEL = "FIDO-KDF"
 const CONST_KDF_CONTEXT = "AutomaticOnboardTunnel"
 
-// Implementation of SP800-108 section 5.1 KDF in Counter Mode
+// Implementation of SP800-108 section 5.1 KDF in Counter Mode, with the FDO fixed input data:
+// Label || 0x00 || Context || ContextRand || L
 // https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-108.pdf
 func Sp800108CounterKDF(sizeBytes int, hmacAlg HashType, key []byte, contextRand []byte) ([]byte, error) {
+	l := sizeBytes * 8
+
+	fixedInput := []byte(CONST_KDF_LABEL)
+	fixedInput = append(fixedInput, 0x00) // Separator
+	fixedInput = append(fixedInput, []byte(CONST_KDF_CONTEXT)...)
+	fixedInput = append(fixedInput, contextRand...)
+	fixedInput = append(fixedInput, byte((l>>8)&0xff), byte(l&0xff))
+
+	return sp800108CounterKdf(sizeBytes, hmacAlg, key, fixedInput)
+}
+
+// SP800-108 KDF in Counter Mode with an 8 bit counter placed before the fixed input data,
+// same layout as the CAVP KDFCTR vectors with CTRLOCATION=BEFORE_FIXED and RLEN=8_BITS
+func sp800108CounterKdf(sizeBytes int, hmacAlg HashType, key []byte, fixedInput []byte) ([]byte, error) {
 	var mac hash.Hash
 	if hmacAlg == HASH_HMAC_SHA256 {
 		mac = hmac.New(sha256.New, key)
@@ -209,17 +238,15 @@ func Sp800108CounterKDF(sizeBytes int, hmacAlg HashType, key []byte, contextRand
 	l := sizeBytes * 8
 
 	n := int(math.Ceil(float64(l) / float64(h)))
+	if n > 0xff {
+		return nil, fmt.Errorf("error! KDF output of %d bytes is too long for an 8 bit counter", sizeBytes)
+	}
 
 	result := []byte{}
 
 	for i := 1; i <= n; i += 1 {
 		mac.Write([]byte{byte(i)})
-		mac.Write([]byte(CONST_KDF_LABEL))
-		mac.Write([]byte{byte(0x00)}) // Separator
-		mac.Write([]byte(CONST_KDF_CONTEXT))
-		mac.Write(contextRand)
-		Lbigend := []byte{byte((l >> 8) & 0xff), byte((l & 0xff))}
-		mac.Write(Lbigend)
+		mac.Write(fixedInput)
 
 		result = append(result, mac.Sum(nil)...)
 		mac.Reset()
@@ -229,6 +256,10 @@ func Sp800108CounterKDF(sizeBytes int, hmacAlg HashType, key []byte, contextRand
 }
 
 func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+	return encryptETMWithIv(plaintext, sessionKeyInfo, cipherSuite, NewRandomBuffer(CipherSuitesInfoMap[cipherSuite].NonceIvLen))
+}
+
+func encryptETMWithIv(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName, nonceIvBytes []byte) ([]byte, error) {
 	var algInfo = CipherSuitesInfoMap[cipherSuite]
 
 	// INNER ENCRYPTION BLOCK
@@ -241,8 +272,6 @@ func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 		return nil, errors.New("Error encoding protected header. " + err.Error())
 	}
 
-	nonceIvBytes := NewRandomBuffer(algInfo.NonceIvLen)
-
 	unprotectedHeaderInner := UnprotectedHeader{
 		AESIV: &nonceIvBytes,
 	}
@@ -255,19 +284,9 @@ func encryptETM(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	svk := svksek[0:algInfo.SvkLen]
 	sek := svksek[algInfo.SvkLen : algInfo.SvkLen+algInfo.SekLen]
 
-	var ciphertext = make([]byte, len(plaintext))
-
-	block, err := aes.NewCipher(sek)
+	ciphertext, err := etmEncrypt(algInfo.CryptoAlg, sek, nonceIvBytes, plaintext)
 	if err != nil {
-		return nil, errors.New("Error creating new cipher. " + err.Error())
-	}
-
-	switch algInfo.CryptoAlg {
-	case CIPHER_COSE_AES128_CTR:
-		stream := cipher.NewCTR(block, nonceIvBytes)
-		stream.XORKeyStream(ciphertext, plaintext)
-	default:
-		return nil, errors.New("%s Error encoding inner ETM. " + err.Error())
+		return nil, err
 	}
 
 	innerBlock := EMB_ETMInnerBlock{
@@ -369,29 +388,94 @@ func decryptETM(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 		return nil, errors.New("Error decoding protected header. " + err.Error())
 	}
 
-	if *innerProtected.Alg != int(algInfo.CryptoAlg) {
+	if innerProtected.Alg == nil || *innerProtected.Alg != int(algInfo.CryptoAlg) {
 		return nil, errors.New("error! Encryption algorithms don't match")
 	}
 
 	nonceIvBytes := inner.Unprotected.AESIV
+	if nonceIvBytes == nil || len(*nonceIvBytes) != algInfo.NonceIvLen {
+		return nil, fmt.Errorf("error! AESIV must be %d bytes long", algInfo.NonceIvLen)
+	}
+
+	return etmDecrypt(algInfo.CryptoAlg, sek, *nonceIvBytes, inner.Ciphertext)
+}
+
+// ETM inner block encryption. CBC plaintext is padded, CTR is not
+func etmEncrypt(cryptoAlg CipherSuiteName, sek []byte, nonceIvBytes []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(sek)
+	if err != nil {
+		return nil, errors.New("Error creating new cipher. " + err.Error())
+	}
+
+	var ciphertext []byte
+	switch cryptoAlg {
+	case CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CTR:
+		ciphertext = make([]byte, len(plaintext))
+		stream := cipher.NewCTR(block, nonceIvBytes)
+		stream.XORKeyStream(ciphertext, plaintext)
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES256_CBC:
+		ciphertext = pkcs7Pad(plaintext, aes.BlockSize)
+		mode := cipher.NewCBCEncrypter(block, nonceIvBytes)
+		mode.CryptBlocks(ciphertext, ciphertext)
+	default:
+		return nil, fmt.Errorf("unsupported ETM encryption algorithm! %d", cryptoAlg)
+	}
+
+	return ciphertext, nil
+}
 
+func etmDecrypt(cryptoAlg CipherSuiteName, sek []byte, nonceIvBytes []byte, ciphertext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(sek)
 	if err != nil {
 		return nil, errors.New("Error creating new cipher. " + err.Error())
 	}
 
-	plaintext := make([]byte, len(inner.Ciphertext))
-	switch *innerProtected.Alg {
-	case int(CIPHER_COSE_AES128_CTR):
-		stream := cipher.NewCTR(block, *nonceIvBytes)
-		stream.XORKeyStream(plaintext, inner.Ciphertext)
+	var plaintext []byte
+	switch cryptoAlg {
+	case CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CTR:
+		plaintext = make([]byte, len(ciphertext))
+		stream := cipher.NewCTR(block, nonceIvBytes)
+		stream.XORKeyStream(plaintext, ciphertext)
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES256_CBC:
+		if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return nil, errors.New("error! CBC ciphertext is not a multiple of the block size")
+		}
+
+		plaintext = make([]byte, len(ciphertext))
+		mode := cipher.NewCBCDecrypter(block, nonceIvBytes)
+		mode.CryptBlocks(plaintext, ciphertext)
+
+		plaintext, err = pkcs7Unpad(plaintext, aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
 	default:
-		return nil, fmt.Errorf("unsupported encryption algorithm! %d", innerProtected.Alg)
+		return nil, fmt.Errorf("unsupported encryption algorithm! %d", cryptoAlg)
 	}
 
 	return plaintext, nil
 }
 
+// CBC plaintext is padded as in RFC5652 section 6.3
+func pkcs7Pad(plaintext []byte, blockSize int) []byte {
+	padLen := blockSize - len(plaintext)%blockSize
+
+	return append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+}
+
+func pkcs7Unpad(padded []byte, blockSize int) ([]byte, error) {
+	padLen := int(padded[len(padded)-1])
+	if padLen == 0 || padLen > blockSize || padLen > len(padded) {
+		return nil, errors.New("error! Bad CBC padding")
+	}
+
+	if !bytes.Equal(padded[len(padded)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+		return nil, errors.New("error! Bad CBC padding")
+	}
+
+	return padded[:len(padded)-padLen], nil
+}
+
 type AEAD_Enc_Structure struct {
 	_           struct{} `cbor:",toarray"`
 	Context     CoseContext
@@ -400,6 +484,10 @@ type AEAD_Enc_Structure struct {
 }
 
 func encryptEMB(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
+	return encryptEMBWithIv(plaintext, sessionKeyInfo, cipherSuite, NewRandomBuffer(CipherSuitesInfoMap[cipherSuite].NonceIvLen))
+}
+
+func encryptEMBWithIv(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName, nonceIvBytes []byte) ([]byte, error) {
 	var algInfo = CipherSuitesInfoMap[cipherSuite]
 
 	// INNER ENCRYPTION BLOCK
@@ -408,7 +496,6 @@ func encryptEMB(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	}
 	protectedHeaderBytes, _ := CborCust.Marshal(protectedHeader)
 
-	nonceIvBytes := NewRandomBuffer(algInfo.NonceIvLen)
 	unprotectedHeader := UnprotectedHeader{
 		AESIV: &nonceIvBytes,
 	}
@@ -426,33 +513,12 @@ func encryptEMB(plaintext []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 		return nil, errors.New("Error generating SEVK! " + err.Error())
 	}
 
-	var ciphertext []byte
-
-	block, err := aes.NewCipher(sevk)
+	aead, err := newEmbAead(algInfo, sevk)
 	if err != nil {
-		return nil, errors.New("Error creating new cipher. " + err.Error())
+		return nil, err
 	}
 
-	switch algInfo.CryptoAlg {
-	case CIPHER_A128GCM, CIPHER_A256GCM:
-		aesgcm, err := cipher.NewGCM(block)
-		if err != nil {
-			return []byte{}, errors.New("Error generating new GCM instance. " + err.Error())
-		}
-
-		ciphertext = aesgcm.Seal(nil, nonceIvBytes, plaintext, aadBytes)
-
-	case CIPHER_AES_CCM_16_128_128, CIPHER_AES_CCM_16_128_256, CIPHER_AES_CCM_64_128_128, CIPHER_AES_CCM_64_128_256:
-		aesccm, err := ccm.NewCCM(block, algInfo.TagSize, algInfo.NonceIvLen)
-		if err != nil {
-			return []byte{}, errors.New("Error generating new CCM instance. " + err.Error())
-		}
-
-		ciphertext = aesccm.Seal(nil, nonceIvBytes, plaintext, aadBytes)
-
-	default:
-		return nil, errors.New("%s Error encoding EMB. " + err.Error())
-	}
+	ciphertext := aead.Seal(nil, nonceIvBytes, plaintext, aadBytes)
 
 	embBlock := EMB_ETMInnerBlock{
 		Protected:   protectedHeaderBytes,
@@ -488,15 +554,18 @@ func decryptEMB(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 		return nil, errors.New("Error decoding protected header. " + err.Error())
 	}
 
-	if *protectedHeader.Alg != int(algInfo.CryptoAlg) {
+	if protectedHeader.Alg == nil || *protectedHeader.Alg != int(algInfo.CryptoAlg) {
 		return nil, errors.New("error! Encryption algorithms don't match")
 	}
 
 	nonceIvBytes := embInst.Unprotected.AESIV
+	if nonceIvBytes == nil || len(*nonceIvBytes) != algInfo.NonceIvLen {
+		return nil, fmt.Errorf("error! AESIV must be %d bytes long", algInfo.NonceIvLen)
+	}
 
-	block, err := aes.NewCipher(sevk)
+	aead, err := newEmbAead(algInfo, sevk)
 	if err != nil {
-		return nil, errors.New("Error creating new cipher. " + err.Error())
+		return nil, err
 	}
 
 	aadStruct := AEAD_Enc_Structure{
@@ -506,38 +575,41 @@ func decryptEMB(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	}
 	aadBytes, _ := CborCust.Marshal(aadStruct)
 
-	var plaintext []byte
+	plaintext, err := aead.Open(nil, *nonceIvBytes, embInst.Ciphertext, aadBytes)
+	if err != nil {
+		return nil, errors.New("Error decrypting EMB. " + err.Error())
+	}
+
+	return plaintext, nil
+}
+
+// Returns GCM or CCM AEAD for the EMB cipher suite
+func newEmbAead(algInfo CipherInfo, sevk []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sevk)
+	if err != nil {
+		return nil, errors.New("Error creating new cipher. " + err.Error())
+	}
+
 	switch algInfo.CryptoAlg {
 	case CIPHER_A128GCM, CIPHER_A256GCM:
 		aesgcm, err := cipher.NewGCM(block)
 		if err != nil {
-			return []byte{}, errors.New("Error generating new GCM instance. " + err.Error())
+			return nil, errors.New("Error generating new GCM instance. " + err.Error())
 		}
 
-		prepPlaintext, err := aesgcm.Open(nil, *nonceIvBytes, embInst.Ciphertext, aadBytes)
-		if err != nil {
-			return []byte{}, errors.New("Error decrypting EMB GCM. " + err.Error())
-		}
-
-		plaintext = prepPlaintext
+		return aesgcm, nil
 
 	case CIPHER_AES_CCM_16_128_128, CIPHER_AES_CCM_16_128_256, CIPHER_AES_CCM_64_128_128, CIPHER_AES_CCM_64_128_256:
 		aesccm, err := ccm.NewCCM(block, algInfo.TagSize, algInfo.NonceIvLen)
 		if err != nil {
-			return []byte{}, errors.New("Error generating new CCM instance. " + err.Error())
+			return nil, errors.New("Error generating new CCM instance. " + err.Error())
 		}
 
-		prepPlaintext, err := aesccm.Open(nil, *nonceIvBytes, embInst.Ciphertext, aadBytes)
-		if err != nil {
-			return []byte{}, errors.New("Error decrypting EMB CCM. " + err.Error())
-		}
+		return aesccm, nil
 
-		plaintext = prepPlaintext
 	default:
-		return nil, errors.New("%s Error encoding EMB. " + err.Error())
+		return nil, fmt.Errorf("unsupported EMB encryption algorithm! %d", algInfo.CryptoAlg)
 	}
-
-	return plaintext, nil
 }
 
 func AddEncryptionWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
@@ -551,6 +623,25 @@ func AddEncryptionWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipher
 	}
 }
 
+// Same as AddEncryptionWrapping, but with the caller's IV or nonce. Only meant for known-answer tests
+func AddEncryptionWrappingWithIv(payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName, nonceIvBytes []byte) ([]byte, error) {
+	algInfo, ok := CipherSuitesInfoMap[cipherSuite]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encryption scheme! %d", cipherSuite)
+	}
+
+	if len(nonceIvBytes) != algInfo.NonceIvLen {
+		return nil, fmt.Errorf("error! AESIV must be %d bytes long", algInfo.NonceIvLen)
+	}
+
+	switch cipherSuite {
+	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CBC, CIPHER_COSE_AES256_CTR:
+		return encryptETMWithIv(payload, sessionKeyInfo, cipherSuite, nonceIvBytes)
+	default:
+		return encryptEMBWithIv(payload, sessionKeyInfo, cipherSuite, nonceIvBytes)
+	}
+}
+
 func RemoveEncryptionWrapping(encryptedPayload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
 	switch cipherSuite {
 	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CBC, CIPHER_COSE_AES256_CTR:
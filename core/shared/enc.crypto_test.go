@@ -33,3 +33,40 @@ func TestEncryptEMB(t *testing.T) {
 		t.Errorf("Decrypted payload does not match original payload %s %s", hex.EncodeToString(payload), hex.EncodeToString(decrypted))
 	}
 }
+
+func TestEncryptionWrapping_CipherSuites(t *testing.T) {
+	sessionKeyInfo := test_generateSessionKeyInfo()
+
+	for _, cipherSuite := range CipherSuiteNames {
+		for _, payloadLen := range []int{0, 16, 37} {
+			payload := NewRandomBuffer(payloadLen)
+
+			encrypted, err := AddEncryptionWrapping(payload, sessionKeyInfo, cipherSuite)
+			if err != nil {
+				t.Fatalf("%d/%d: Error encrypting: %v", cipherSuite, payloadLen, err)
+			}
+
+			decrypted, err := RemoveEncryptionWrapping(encrypted, sessionKeyInfo, cipherSuite)
+			if err != nil {
+				t.Fatalf("%d/%d: Error decrypting: %v", cipherSuite, payloadLen, err)
+			}
+
+			if !bytes.Equal(payload, decrypted) {
+				t.Errorf("%d/%d: Decrypted payload does not match original payload", cipherSuite, payloadLen)
+			}
+		}
+	}
+}
+
+func TestPkcs7Unpad_BadPadding(t *testing.T) {
+	for _, padded := range [][]byte{
+		append(bytes.Repeat([]byte{0x01}, 15), 0x00),
+		append(bytes.Repeat([]byte{0x01}, 15), 0x11),
+		append(bytes.Repeat([]byte{0x01}, 14), 0x03, 0x02),
+	} {
+		_, err := pkcs7Unpad(padded, 16)
+		if err == nil {
+			t.Errorf("Expected padding %s to be rejected", hex.EncodeToString(padded))
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package fdoshared
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Published reference vectors for the primitives behind the FDO cipher suites.
+// Unlike the kat vectors, these are not generated by this tool
+
+func test_hexDecode(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Bad hex in test vector: %v", err)
+	}
+
+	return b
+}
+
+// NIST CAVP KDFCTR_gen.rsp, [PRF=HMAC_SHA256] [CTRLOCATION=BEFORE_FIXED] [RLEN=8_BITS], COUNT=0
+func TestSp800108CounterKdf_CavpVector(t *testing.T) {
+	ki := test_hexDecode(t, "3edc6b5b8f7aadbd713732b482b8f979286e1ea3b8f8f99c30c884cfe3349b83")
+	fixedInput := test_hexDecode(t, "98e9988bb4cc8b34d7922e1c68ad692ba2a1d9ae15149571675f17a77ad49e80c8d2a85e831a26445b1f0ff44d7084a17206b4896c8112daad18605a")
+	ko := test_hexDecode(t, "6c037652990674a07844732d0ad985f9")
+
+	result, err := sp800108CounterKdf(len(ko), HASH_HMAC_SHA256, ki, fixedInput)
+	if err != nil {
+		t.Fatalf("Error deriving key: %v", err)
+	}
+
+	if !bytes.Equal(result, ko) {
+		t.Errorf("KDF output %s does not match CAVP KO %s", hex.EncodeToString(result), hex.EncodeToString(ko))
+	}
+}
+
+func TestSp800108CounterKDF_FdoFixedInput(t *testing.T) {
+	key := []byte("test ShSe")
+	contextRand := []byte("test ContextRand")
+
+	// 0x00 separator, ContextRand, and L=256 bits
+	fixedInput := append([]byte(CONST_KDF_LABEL+"\x00"+CONST_KDF_CONTEXT), contextRand...)
+	fixedInput = append(fixedInput, 0x01, 0x00)
+
+	expected, err := sp800108CounterKdf(32, HASH_HMAC_SHA256, key, fixedInput)
+	if err != nil {
+		t.Fatalf("Error deriving key: %v", err)
+	}
+
+	result, err := Sp800108CounterKDF(32, HASH_HMAC_SHA256, key, contextRand)
+	if err != nil {
+		t.Fatalf("Error deriving key: %v", err)
+	}
+
+	if !bytes.Equal(result, expected) {
+		t.Errorf("FDO KDF output %s does not match %s", hex.EncodeToString(result), hex.EncodeToString(expected))
+	}
+}
+
+// NIST SP800-38A appendix F.2.1, F.2.5, F.5.1 and F.5.5
+func TestEtmEncrypt_Sp80038aVectors(t *testing.T) {
+	plaintext := test_hexDecode(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	key128 := "2b7e151628aed2a6abf7158809cf4f3c"
+	key256 := "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4"
+	cbcIv := "000102030405060708090a0b0c0d0e0f"
+	ctrIv := "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+
+	testCases := []struct {
+		name       string
+		cryptoAlg  CipherSuiteName
+		key        string
+		iv         string
+		ciphertext string
+	}{
+		{"CBC-AES128", CIPHER_COSE_AES128_CBC, key128, cbcIv, "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7"},
+		{"CBC-AES256", CIPHER_COSE_AES256_CBC, key256, cbcIv, "f58c4c04d6e5f1ba779eabfb5f7bfbd69cfc4e967edb808d679f777bc6702c7d39f23369a9d9bacfa530e26304231461b2eb05e2c39be9fcda6c19078c6a9d1b"},
+		{"CTR-AES128", CIPHER_COSE_AES128_CTR, key128, ctrIv, "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee"},
+		{"CTR-AES256", CIPHER_COSE_AES256_CTR, key256, ctrIv, "601ec313775789a5b7a7f504bbf3d228f443e3ca4d62b59aca84e990cacaf5c52b0930daa23de94ce87017ba2d84988ddfc9c58db67aada613c2dd08457941a6"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := test_hexDecode(t, tc.key)
+			iv := test_hexDecode(t, tc.iv)
+			expected := test_hexDecode(t, tc.ciphertext)
+
+			ciphertext, err := etmEncrypt(tc.cryptoAlg, key, iv, plaintext)
+			if err != nil {
+				t.Fatalf("Error encrypting: %v", err)
+			}
+
+			// SP800-38A vectors are not padded. CBC adds one full block of padding after them
+			if !bytes.Equal(ciphertext[:len(expected)], expected) {
+				t.Errorf("Ciphertext %s does not match %s", hex.EncodeToString(ciphertext[:len(expected)]), tc.ciphertext)
+			}
+
+			decrypted, err := etmDecrypt(tc.cryptoAlg, key, iv, ciphertext)
+			if err != nil {
+				t.Fatalf("Error decrypting: %v", err)
+			}
+
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("Decrypted %s does not match plaintext", hex.EncodeToString(decrypted))
+			}
+		})
+	}
+}
+
+// McGrew and Viega, "The Galois/Counter Mode of Operation (GCM)", appendix B test cases 4 and 16
+func TestNewEmbAead_GcmVectors(t *testing.T) {
+	iv := test_hexDecode(t, "cafebabefacedbaddecaf888")
+	plaintext := test_hexDecode(t, "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39")
+	aad := test_hexDecode(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+
+	testCases := []struct {
+		name        string
+		cipherSuite CipherSuiteName
+		key         string
+		ciphertext  string
+		tag         string
+	}{
+		{"Test Case 4", CIPHER_A128GCM, "feffe9928665731c6d6a8f9467308308", "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091", "5bc94fbc3221a5db94fae95ae7121a47"},
+		{"Test Case 16", CIPHER_A256GCM, "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308", "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662", "76fc6ece0f4e1768cddf8853bb2d551b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			aead, err := newEmbAead(CipherSuitesInfoMap[tc.cipherSuite], test_hexDecode(t, tc.key))
+			if err != nil {
+				t.Fatalf("Error creating AEAD: %v", err)
+			}
+
+			expected := test_hexDecode(t, tc.ciphertext+tc.tag)
+
+			sealed := aead.Seal(nil, iv, plaintext, aad)
+			if !bytes.Equal(sealed, expected) {
+				t.Errorf("Sealed %s does not match %s", hex.EncodeToString(sealed), hex.EncodeToString(expected))
+			}
+
+			opened, err := aead.Open(nil, iv, expected, aad)
+			if err != nil {
+				t.Fatalf("Error opening: %v", err)
+			}
+
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("Opened %s does not match plaintext", hex.EncodeToString(opened))
+			}
+		})
+	}
+}
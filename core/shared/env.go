@@ -6,15 +6,60 @@ const (
 	CFG_ENV_FDO_SERVICE_URL CONFIG_ENTRY = "FDO_SERVICE_URL"
 	CFG_ENV_MODE            CONFIG_ENTRY = "MODE"
 
-	CFG_DEV_ENV  CONFIG_ENTRY = "DEV"
-	CFG_ENV_PORT CONFIG_ENTRY = "PORT"
+	CFG_DEV_ENV     CONFIG_ENTRY = "DEV"
+	CFG_ENV_PORT    CONFIG_ENTRY = "PORT"
+	CFG_ENV_DB_PATH CONFIG_ENTRY = "DB_PATH"
+
+	// Prometheus /metrics listener. Disabled when not set
+	CFG_ENV_METRICS_PORT CONFIG_ENTRY = "METRICS_PORT"
+
+	// YAML config file. Environment variables override its values
+	CFG_ENV_CONFIG_FILE CONFIG_ENTRY = "CONFIG_FILE"
 
 	// For conformance testing
-	CFG_ENV_INTEROP_ENABLED            CONFIG_ENTRY = "INTEROP_ENABLED"
 	CFG_ENV_INTEROP_DASHBOARD_URL      CONFIG_ENTRY = "INTEROP_DASHBOARD_URL"
 	CFG_ENV_INTEROP_DASHBOARD_RV_AUTHZ CONFIG_ENTRY = "INTEROP_DASHBOARD_RV_AUTHZ"
 	CFG_ENV_INTEROP_DASHBOARD_DO_AUTHZ CONFIG_ENTRY = "INTEROP_DASHBOARD_DO_AUTHZ"
 	CFG_ENV_INTEROP_DO_TOKEN_MAPPING   CONFIG_ENTRY = "INTEROP_DO_TOKEN_MAPPING"
+
+	// PKCS#11 owner key store. Requires build with -tags pkcs11
+	CFG_ENV_PKCS11_MODULE      CONFIG_ENTRY = "PKCS11_MODULE"
+	CFG_ENV_PKCS11_TOKEN_LABEL CONFIG_ENTRY = "PKCS11_TOKEN_LABEL"
+	CFG_ENV_PKCS11_PIN         CONFIG_ENTRY = "PKCS11_PIN"
+
+	// Sealed device credentials
+	CFG_ENV_DEVICE_CRED_PASSPHRASE CONFIG_ENTRY = "DEVICE_CRED_PASSPHRASE"
+	CFG_ENV_TPM_SIMULATOR          CONFIG_ENTRY = "TPM_SIMULATOR"
+
+	// SHA-512, ES512 and EdDSA. Not part of FDO 1.1
+	CFG_ENV_ALG_EXTENSIONS CONFIG_ENTRY = "ALG_EXTENSIONS"
+
+	// Device attestation chain policy for DO and RV
+	CFG_ENV_DEVICE_CERT_ROOTS CONFIG_ENTRY = "DEVICE_CERT_ROOTS"
+	CFG_ENV_DEVICE_CERT_CRLS  CONFIG_ENTRY = "DEVICE_CERT_CRLS"
+	CFG_ENV_EPID_VERIFIER_URL CONFIG_ENTRY = "EPID_VERIFIER_URL"
+
+	// Account emails in online mode
+	CFG_ENV_MAILER        CONFIG_ENTRY = "MAILER"
+	CFG_ENV_SMTP_HOST     CONFIG_ENTRY = "SMTP_HOST"
+	CFG_ENV_SMTP_PORT     CONFIG_ENTRY = "SMTP_PORT"
+	CFG_ENV_SMTP_USERNAME CONFIG_ENTRY = "SMTP_USERNAME"
+	CFG_ENV_SMTP_PASSWORD CONFIG_ENTRY = "SMTP_PASSWORD"
+	CFG_ENV_MAIL_FROM     CONFIG_ENTRY = "MAIL_FROM"
+	CFG_ENV_MAIL_DROP_DIR CONFIG_ENTRY = "MAIL_DROP_DIR"
+	CFG_ENV_ADMIN_EMAIL   CONFIG_ENTRY = "ADMIN_EMAIL"
+
+	// Seeded device bases per device sgType
+	CFG_ENV_SEED_SIZE CONFIG_ENTRY = "SEED_SIZE"
+
+	// Periodic online DB backups
+	CFG_ENV_BACKUP_DIR      CONFIG_ENTRY = "BACKUP_DIR"
+	CFG_ENV_BACKUP_INTERVAL CONFIG_ENTRY = "BACKUP_INTERVAL"
+	CFG_ENV_BACKUP_KEEP     CONFIG_ENTRY = "BACKUP_KEEP"
+
+	// debug, info, warn or error. text or json
+	CFG_ENV_LOG_LEVEL  CONFIG_ENTRY = "LOG_LEVEL"
+	CFG_ENV_LOG_FORMAT CONFIG_ENTRY = "LOG_FORMAT"
 )
 
 const (
@@ -0,0 +1,511 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bn256"
+)
+
+// EPID-style group signatures for StEPID10/StEPID11 virtual devices and their DO/RV verification.
+// This is NOT Intel EPID 2.0. It is a BBS+ style group signature without revocation lists, over the bn256 pairing of x/crypto, that only this tool implements.
+// Signatures of the virtual EPID devices can only be verified by this tool, and signatures of real EPID devices can not be verified by LocalEpidVerifier.
+// Real EPID devices need RemoteEpidVerifier pointed to a verification service built on the Intel EPID SDK, see https://github.com/Intel-EPID-SDK/epid-sdk
+
+const EPID_GROUPID_LEN int = 16
+
+type EpidGroupPublicKey struct {
+	_       struct{} `cbor:",toarray"`
+	GroupId []byte
+	H1      []byte // G1
+	H2      []byte // G1
+	W       []byte // G2
+}
+
+type EpidIssuerKey struct {
+	_              struct{} `cbor:",toarray"`
+	GroupPublicKey EpidGroupPublicKey
+	Gamma          []byte
+}
+
+type EpidMemberKey struct {
+	_              struct{} `cbor:",toarray"`
+	GroupPublicKey EpidGroupPublicKey
+	A              []byte // G1
+	X              []byte
+	Y              []byte
+	F              []byte
+}
+
+type EpidSignature struct {
+	_  struct{} `cbor:",toarray"`
+	B  []byte   // G1
+	K  []byte   // G1
+	T  []byte   // G1
+	C  []byte
+	Sx []byte
+	Sf []byte
+	Sa []byte
+	Sb []byte
+}
+
+func IsEpidSgType(sgType DeviceSgType) bool {
+	return sgType == StEPID10 || sgType == StEPID11
+}
+
+func randomEpidScalar(r io.Reader) (*big.Int, error) {
+	for {
+		k, err := rand.Int(r, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+func epidModOrder(k *big.Int) *big.Int {
+	return k.Mod(k, bn256.Order)
+}
+
+func epidNeg(k *big.Int) *big.Int {
+	return epidModOrder(new(big.Int).Sub(bn256.Order, k))
+}
+
+func unmarshalEpidG1(m []byte) (*bn256.G1, error) {
+	p, ok := new(bn256.G1).Unmarshal(m)
+	if !ok {
+		return nil, errors.New("bad G1 point")
+	}
+
+	return p, nil
+}
+
+func unmarshalEpidScalar(m []byte) (*big.Int, error) {
+	k := new(big.Int).SetBytes(m)
+	if k.Cmp(bn256.Order) >= 0 {
+		return nil, errors.New("scalar is not reduced")
+	}
+
+	return k, nil
+}
+
+type epidGroup struct {
+	gpk EpidGroupPublicKey
+	h1  *bn256.G1
+	h2  *bn256.G1
+	w   *bn256.G2
+}
+
+func (h EpidGroupPublicKey) decode() (*epidGroup, error) {
+	if len(h.GroupId) != EPID_GROUPID_LEN {
+		return nil, fmt.Errorf("group id must be %d bytes long", EPID_GROUPID_LEN)
+	}
+
+	h1, err := unmarshalEpidG1(h.H1)
+	if err != nil {
+		return nil, errors.New("error decoding group key H1. " + err.Error())
+	}
+
+	h2, err := unmarshalEpidG1(h.H2)
+	if err != nil {
+		return nil, errors.New("error decoding group key H2. " + err.Error())
+	}
+
+	w, ok := new(bn256.G2).Unmarshal(h.W)
+	if !ok {
+		return nil, errors.New("error decoding group key W. Bad G2 point")
+	}
+
+	return &epidGroup{gpk: h, h1: h1, h2: h2, w: w}, nil
+}
+
+func NewEpidIssuerKey(r io.Reader) (*EpidIssuerKey, error) {
+	gamma, err := randomEpidScalar(r)
+	if err != nil {
+		return nil, errors.New("error generating EPID issuer key. " + err.Error())
+	}
+
+	_, h1, err := bn256.RandomG1(r)
+	if err != nil {
+		return nil, errors.New("error generating EPID issuer key. " + err.Error())
+	}
+
+	_, h2, err := bn256.RandomG1(r)
+	if err != nil {
+		return nil, errors.New("error generating EPID issuer key. " + err.Error())
+	}
+
+	groupId := make([]byte, EPID_GROUPID_LEN)
+	_, err = io.ReadFull(r, groupId)
+	if err != nil {
+		return nil, errors.New("error generating EPID group id. " + err.Error())
+	}
+
+	return &EpidIssuerKey{
+		GroupPublicKey: EpidGroupPublicKey{
+			GroupId: groupId,
+			H1:      h1.Marshal(),
+			H2:      h2.Marshal(),
+			W:       new(bn256.G2).ScalarBaseMult(gamma).Marshal(),
+		},
+		Gamma: gamma.Bytes(),
+	}, nil
+}
+
+// Issues new member private key. A = (g1 * h1^f * h2^y)^(1/(x+gamma))
+func (h EpidIssuerKey) NewMemberKey(r io.Reader) (*EpidMemberKey, error) {
+	group, err := h.GroupPublicKey.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	gamma := new(big.Int).SetBytes(h.Gamma)
+
+	var x, y, f *big.Int
+	for {
+		if x, err = randomEpidScalar(r); err != nil {
+			return nil, errors.New("error generating EPID member key. " + err.Error())
+		}
+
+		if epidModOrder(new(big.Int).Add(x, gamma)).Sign() != 0 {
+			break
+		}
+	}
+
+	if y, err = randomEpidScalar(r); err != nil {
+		return nil, errors.New("error generating EPID member key. " + err.Error())
+	}
+
+	if f, err = randomEpidScalar(r); err != nil {
+		return nil, errors.New("error generating EPID member key. " + err.Error())
+	}
+
+	base := new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	base.Add(base, new(bn256.G1).ScalarMult(group.h1, f))
+	base.Add(base, new(bn256.G1).ScalarMult(group.h2, y))
+
+	xGammaInv := new(big.Int).ModInverse(epidModOrder(new(big.Int).Add(x, gamma)), bn256.Order)
+	a := new(bn256.G1).ScalarMult(base, xGammaInv)
+
+	return &EpidMemberKey{
+		GroupPublicKey: h.GroupPublicKey,
+		A:              a.Marshal(),
+		X:              x.Bytes(),
+		Y:              y.Bytes(),
+		F:              f.Bytes(),
+	}, nil
+}
+
+func epidChallenge(sgType DeviceSgType, group *epidGroup, b, k, t *bn256.G1, r1 *bn256.G1, r2 *bn256.GT, payload []byte) *big.Int {
+	hasher := sha256.New()
+	hasher.Write([]byte{byte(sgType)})
+	hasher.Write(group.gpk.GroupId)
+	hasher.Write(group.gpk.H1)
+	hasher.Write(group.gpk.H2)
+	hasher.Write(group.gpk.W)
+	hasher.Write(b.Marshal())
+	hasher.Write(k.Marshal())
+	hasher.Write(t.Marshal())
+	hasher.Write(r1.Marshal())
+	hasher.Write(r2.Marshal())
+	hasher.Write(payload)
+
+	return epidModOrder(new(big.Int).SetBytes(hasher.Sum(nil)))
+}
+
+func EpidSign(sgType DeviceSgType, memberKey EpidMemberKey, payload []byte) ([]byte, error) {
+	if !IsEpidSgType(sgType) {
+		return nil, fmt.Errorf("%d is not an EPID sgType", sgType)
+	}
+
+	group, err := memberKey.GroupPublicKey.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := unmarshalEpidG1(memberKey.A)
+	if err != nil {
+		return nil, errors.New("error decoding member key A. " + err.Error())
+	}
+
+	x := new(big.Int).SetBytes(memberKey.X)
+	y := new(big.Int).SetBytes(memberKey.Y)
+	f := new(big.Int).SetBytes(memberKey.F)
+
+	// Random basename
+	_, b, err := bn256.RandomG1(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := new(bn256.G1).ScalarMult(b, f)
+
+	// T = A * h2^a, b = y + a*x
+	alpha, err := randomEpidScalar(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	t := new(bn256.G1).Add(a, new(bn256.G1).ScalarMult(group.h2, alpha))
+	beta := epidModOrder(new(big.Int).Add(y, new(big.Int).Mul(alpha, x)))
+
+	var rx, rf, ra, rb *big.Int
+	for _, r := range []**big.Int{&rx, &rf, &ra, &rb} {
+		if *r, err = randomEpidScalar(rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	// R1 = B^rf, R2 = e(T,g2)^-rx * e(h1,g2)^rf * e(h2,g2)^rb * e(h2,w)^ra
+	g2 := new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+	r1 := new(bn256.G1).ScalarMult(b, rf)
+	r2 := new(bn256.GT).ScalarMult(bn256.Pair(t, g2), epidNeg(rx))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h1, g2), rf))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h2, g2), rb))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h2, group.w), ra))
+
+	c := epidChallenge(sgType, group, b, k, t, r1, r2, payload)
+
+	response := func(r *big.Int, secret *big.Int) []byte {
+		return epidModOrder(new(big.Int).Add(r, new(big.Int).Mul(c, secret))).Bytes()
+	}
+
+	signature := EpidSignature{
+		B:  b.Marshal(),
+		K:  k.Marshal(),
+		T:  t.Marshal(),
+		C:  c.Bytes(),
+		Sx: response(rx, x),
+		Sf: response(rf, f),
+		Sa: response(ra, alpha),
+		Sb: response(rb, beta),
+	}
+
+	return CborCust.Marshal(signature)
+}
+
+func EpidVerify(sgType DeviceSgType, groupPublicKey EpidGroupPublicKey, payload []byte, signatureBytes []byte) error {
+	if !IsEpidSgType(sgType) {
+		return fmt.Errorf("%d is not an EPID sgType", sgType)
+	}
+
+	group, err := groupPublicKey.decode()
+	if err != nil {
+		return err
+	}
+
+	var signature EpidSignature
+	err = CborCust.Unmarshal(signatureBytes, &signature)
+	if err != nil {
+		return errors.New("error decoding EPID signature. " + err.Error())
+	}
+
+	var b, k, t *bn256.G1
+	for _, p := range []struct {
+		dst   **bn256.G1
+		bytes []byte
+	}{{&b, signature.B}, {&k, signature.K}, {&t, signature.T}} {
+		if *p.dst, err = unmarshalEpidG1(p.bytes); err != nil {
+			return errors.New("error decoding EPID signature. " + err.Error())
+		}
+	}
+
+	var c, sx, sf, sa, sb *big.Int
+	for _, s := range []struct {
+		dst   **big.Int
+		bytes []byte
+	}{{&c, signature.C}, {&sx, signature.Sx}, {&sf, signature.Sf}, {&sa, signature.Sa}, {&sb, signature.Sb}} {
+		if *s.dst, err = unmarshalEpidScalar(s.bytes); err != nil {
+			return errors.New("error decoding EPID signature. " + err.Error())
+		}
+	}
+
+	// R1 = B^sf * K^-c
+	r1 := new(bn256.G1).ScalarMult(b, sf)
+	r1.Add(r1, new(bn256.G1).ScalarMult(k, epidNeg(c)))
+
+	// R2 = e(T,g2)^-sx * e(h1,g2)^sf * e(h2,g2)^sb * e(h2,w)^sa * (e(T,w)/e(g1,g2))^-c
+	g1 := new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	g2 := new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+
+	target := bn256.Pair(t, group.w)
+	target.Add(target, new(bn256.GT).Neg(bn256.Pair(g1, g2)))
+
+	r2 := new(bn256.GT).ScalarMult(bn256.Pair(t, g2), epidNeg(sx))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h1, g2), sf))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h2, g2), sb))
+	r2.Add(r2, new(bn256.GT).ScalarMult(bn256.Pair(group.h2, group.w), sa))
+	r2.Add(r2, new(bn256.GT).ScalarMult(target, epidNeg(c)))
+
+	expectedC := epidChallenge(sgType, group, b, k, t, r1, r2, payload)
+	if expectedC.Cmp(c) != 0 {
+		return errors.New("failed to verify EPID signature")
+	}
+
+	return nil
+}
+
+// Verifies EPID signatures of the group identified by SigInfo.Info
+type EpidVerifier interface {
+	VerifyEpidSignature(sgType DeviceSgType, groupId []byte, payload []byte, signature []byte) error
+}
+
+// Verifies signatures locally, using known group public keys
+type LocalEpidVerifier struct {
+	mu     sync.RWMutex
+	groups map[string]EpidGroupPublicKey
+}
+
+func NewLocalEpidVerifier(groupPublicKeys ...EpidGroupPublicKey) *LocalEpidVerifier {
+	verifier := &LocalEpidVerifier{
+		groups: map[string]EpidGroupPublicKey{},
+	}
+
+	for _, groupPublicKey := range groupPublicKeys {
+		verifier.AddGroup(groupPublicKey)
+	}
+
+	return verifier
+}
+
+func (h *LocalEpidVerifier) AddGroup(groupPublicKey EpidGroupPublicKey) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.groups[hex.EncodeToString(groupPublicKey.GroupId)] = groupPublicKey
+}
+
+func (h *LocalEpidVerifier) VerifyEpidSignature(sgType DeviceSgType, groupId []byte, payload []byte, signature []byte) error {
+	h.mu.RLock()
+	groupPublicKey, ok := h.groups[hex.EncodeToString(groupId)]
+	h.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown EPID group %s", hex.EncodeToString(groupId))
+	}
+
+	return EpidVerify(sgType, groupPublicKey, payload, signature)
+}
+
+// Request of RemoteEpidVerifier. Sent CBOR encoded
+type EpidVerifyRequest struct {
+	_         struct{} `cbor:",toarray"`
+	SgType    DeviceSgType
+	GroupId   []byte
+	Payload   []byte
+	Signature []byte
+}
+
+// Delegates verification to an external EPID verification service, e.g. one built on the Intel EPID SDK.
+// Service gets EpidVerifyRequest as application/cbor POST, and responds 200 for valid signature.
+// Any other status is verification failure, with the response body as the reason
+type RemoteEpidVerifier struct {
+	Url        string
+	HttpClient *http.Client
+}
+
+func NewRemoteEpidVerifier(url string) *RemoteEpidVerifier {
+	return &RemoteEpidVerifier{
+		Url: url,
+		HttpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (h *RemoteEpidVerifier) VerifyEpidSignature(sgType DeviceSgType, groupId []byte, payload []byte, signature []byte) error {
+	reqBytes, err := CborCust.Marshal(EpidVerifyRequest{
+		SgType:    sgType,
+		GroupId:   groupId,
+		Payload:   payload,
+		Signature: signature,
+	})
+	if err != nil {
+		return errors.New("error encoding EPID verify request. " + err.Error())
+	}
+
+	resp, err := h.HttpClient.Post(h.Url, "application/cbor", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return fmt.Errorf("error sending EPID verify request to %s. %s", h.Url, err.Error())
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("EPID signature verification failed for group %s. Verifier responded %d: %s", hex.EncodeToString(groupId), resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
+// Local verifier, that only knows the conformance test group of this tool's EPID-style scheme
+func DefaultEpidVerifier() EpidVerifier {
+	testIssuerKey, err := GetTestEpidIssuerKey()
+	if err != nil {
+		return NewLocalEpidVerifier()
+	}
+
+	return NewLocalEpidVerifier(testIssuerKey.GroupPublicKey)
+}
+
+// For EPID devices SigInfo.Info contains the group id
+func VerifyCoseSignatureWithEpid(coseSig CoseSignature, sigInfo SigInfo, verifier EpidVerifier) error {
+	if !IsEpidSgType(sigInfo.SgType) {
+		return fmt.Errorf("%d is not an EPID sgType", sigInfo.SgType)
+	}
+
+	coseSigPayloadBytes, err := NewSig1Payload(coseSig.Protected, coseSig.Payload)
+	if err != nil {
+		return err
+	}
+
+	return verifier.VerifyEpidSignature(sigInfo.SgType, sigInfo.Info, coseSigPayloadBytes, coseSig.Signature)
+}
+
+// Conformance test EPID group. Issuer key is public, so it must never be used outside of testing
+const TestEpidIssuerKey string = "828450e820e8f41ed9a4dacb091cebcfdb483d5840087a3134ec9cdd818f7cf2dc9c39562ba77d916899ad83004c31f5c6f6addad61c79a0fa2bc9ca7e2a20f0" +
+	"e14ce12bd0b5d9466e435a36a182255dd7a8582d7b58408c2161af246f93577fd53077dcb4ac2afaa94e9e8c9ccf6982509042cd0b45ef0af8f45ddb248089fa" +
+	"abe02936814a9744193fa31707e65e68e2208457411c3f588088be9b945f7fe9efc8d0bbbc5689da0f9898655854f12d3ac976ee8fc3025b5283c7a8902688d0" +
+	"26104ac0f10427cf7c6405cbb9e042561f8288f604e37a3160804ac822256f7cac6197608526196e727f2af158d634c9d9a62d8ced6c3cab1f1f03d77675c82a" +
+	"9b9597c16cf7f5912ebe06b07e8212cff498c82ecfe4339397582009eb8cc5272f30823fe01e77f6c6988c8a58646667fdb1ef357a56c8509fd3da"
+
+func GetTestEpidIssuerKey() (*EpidIssuerKey, error) {
+	issuerKeyBytes, err := hex.DecodeString(TestEpidIssuerKey)
+	if err != nil {
+		return nil, errors.New("error decoding test EPID issuer key. " + err.Error())
+	}
+
+	var issuerKey EpidIssuerKey
+	err = CborCust.Unmarshal(issuerKeyBytes, &issuerKey)
+	if err != nil {
+		return nil, errors.New("error decoding test EPID issuer key. " + err.Error())
+	}
+
+	return &issuerKey, nil
+}
+
+// EPID member keys are stored CBOR encoded in place of DER private key
+func DecodeEpidMemberKey(memberKeyBytes []byte) (*EpidMemberKey, error) {
+	var memberKey EpidMemberKey
+	err := CborCust.Unmarshal(memberKeyBytes, &memberKey)
+	if err != nil {
+		return nil, errors.New("error decoding EPID member key. " + err.Error())
+	}
+
+	_, err = memberKey.GroupPublicKey.decode()
+	if err != nil {
+		return nil, errors.New("error decoding EPID member key. " + err.Error())
+	}
+
+	return &memberKey, nil
+}
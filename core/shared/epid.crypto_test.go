@@ -0,0 +1,148 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type epidTestVectors struct {
+	MemberKey string `json:"memberKey"`
+	Vectors   []struct {
+		Comment   string       `json:"comment"`
+		SgType    DeviceSgType `json:"sgType"`
+		Payload   string       `json:"payload"`
+		Signature string       `json:"signature"`
+		Valid     bool         `json:"valid"`
+	} `json:"vectors"`
+}
+
+// Regression vectors generated by this implementation. There are no external reference vectors,
+// as the scheme is not Intel EPID 2.0
+func TestEpidVerify_Vectors(t *testing.T) {
+	vectorsBytes, err := os.ReadFile("testdata/epid.vectors.json")
+	if err != nil {
+		t.Fatalf("failed to read test vectors: %v", err)
+	}
+
+	var testVectors epidTestVectors
+	err = json.Unmarshal(vectorsBytes, &testVectors)
+	if err != nil {
+		t.Fatalf("failed to decode test vectors: %v", err)
+	}
+
+	issuerKey, err := GetTestEpidIssuerKey()
+	if err != nil {
+		t.Fatalf("failed to decode test issuer key: %v", err)
+	}
+
+	for _, vector := range testVectors.Vectors {
+		payload, _ := hex.DecodeString(vector.Payload)
+		signature, _ := hex.DecodeString(vector.Signature)
+
+		err := DefaultEpidVerifier().VerifyEpidSignature(vector.SgType, issuerKey.GroupPublicKey.GroupId, payload, signature)
+		if vector.Valid && err != nil {
+			t.Errorf("%s: expected valid signature. %v", vector.Comment, err)
+		} else if !vector.Valid && err == nil {
+			t.Errorf("%s: expected invalid signature", vector.Comment)
+		}
+	}
+}
+
+func TestEpidSign_CoseSignature(t *testing.T) {
+	issuerKey, err := GetTestEpidIssuerKey()
+	if err != nil {
+		t.Fatalf("failed to decode test issuer key: %v", err)
+	}
+
+	for _, sgType := range []DeviceSgType{StEPID10, StEPID11} {
+		devCred, err := NewWawDeviceCredential(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate device credential: %v", sgType, err)
+		}
+
+		privateKeyInst, err := ExtractPrivateKey(devCred.DCPrivateKeyDer)
+		if err != nil {
+			t.Fatalf("%d: failed to extract member key: %v", sgType, err)
+		}
+
+		coseSig, err := GenerateCoseSignature([]byte("test payload"), ProtectedHeader{}, UnprotectedHeader{}, privateKeyInst, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate COSE signature: %v", sgType, err)
+		}
+
+		err = VerifyCoseSignatureWithEpid(*coseSig, devCred.DCSigInfo, DefaultEpidVerifier())
+		if err != nil {
+			t.Fatalf("%d: failed to verify COSE signature: %v", sgType, err)
+		}
+
+		coseSig.Payload = []byte("other payload")
+		err = VerifyCoseSignatureWithEpid(*coseSig, devCred.DCSigInfo, DefaultEpidVerifier())
+		if err == nil {
+			t.Fatalf("%d: signature over modified payload passed verification", sgType)
+		}
+	}
+
+	// Member of another group
+	otherIssuerKey, err := NewEpidIssuerKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate issuer key: %v", err)
+	}
+
+	otherMemberKey, err := otherIssuerKey.NewMemberKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate member key: %v", err)
+	}
+
+	signature, err := EpidSign(StEPID10, *otherMemberKey, []byte("test payload"))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	err = EpidVerify(StEPID10, issuerKey.GroupPublicKey, []byte("test payload"), signature)
+	if err == nil {
+		t.Fatalf("signature of another group passed verification")
+	}
+
+	err = DefaultEpidVerifier().VerifyEpidSignature(StEPID10, otherIssuerKey.GroupPublicKey.GroupId, []byte("test payload"), signature)
+	if err == nil {
+		t.Fatalf("signature of unknown group passed verification")
+	}
+}
+
+func TestRemoteEpidVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBytes, _ := io.ReadAll(r.Body)
+
+		var verifyReq EpidVerifyRequest
+		err := CborCust.Unmarshal(reqBytes, &verifyReq)
+		if err != nil || r.Header.Get("Content-Type") != "application/cbor" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if verifyReq.SgType != StEPID11 || !bytes.Equal(verifyReq.Signature, []byte("good signature")) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+	}))
+	defer server.Close()
+
+	verifier := NewRemoteEpidVerifier(server.URL)
+
+	err := verifier.VerifyEpidSignature(StEPID11, []byte("group"), []byte("test payload"), []byte("good signature"))
+	if err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+
+	err = verifier.VerifyEpidSignature(StEPID11, []byte("group"), []byte("test payload"), []byte("bad signature"))
+	if err == nil {
+		t.Error("expected invalid signature to fail")
+	}
+}
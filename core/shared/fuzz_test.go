@@ -0,0 +1,493 @@
+package fdoshared_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fdodevice "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Native go fuzz targets for everything that parses input coming from the vendor implementations.
+// Seed corpora are built from the same code paths the virtual device and the servers use for real exchanges,
+// and are checked in to testdata/fuzz, so fuzz workers do not generate credentials and vouchers on start.
+//
+// Running:
+//   go test ./core/shared -run=^$ -fuzz=FuzzOwnershipVoucherValidate -fuzztime=60s
+//
+// Regenerating seed corpora, after message or voucher encoding changes:
+//   go test ./core/shared -run=TestFuzzUpdateCorpus -update-fuzz-corpus
+
+var fuzzUpdateCorpus = flag.Bool("update-fuzz-corpus", false, "Regenerate seed corpora in testdata/fuzz")
+
+type fuzzExchange struct {
+	vouchers []fdoshared.DeviceCredAndVoucher
+	rvInfo   fdoshared.RendezvousInfo
+	messages map[int][][]byte
+}
+
+var fuzzMessageTypes []int = []int{
+	int(fdoshared.TO0_20_HELLO),
+	int(fdoshared.TO0_21_HELLO_ACK),
+	int(fdoshared.TO0_22_OWNER_SIGN),
+	int(fdoshared.TO0_23_ACCEPT_OWNER),
+	int(fdoshared.TO1_30_HELLO_RV),
+	int(fdoshared.TO1_31_HELLO_RV_ACK),
+	int(fdoshared.TO1_32_PROVE_TO_RV),
+	int(fdoshared.TO1_33_RV_REDIRECT),
+	int(fdoshared.TO2_60_HELLO_DEVICE),
+	int(fdoshared.TO2_61_PROVE_OVHDR),
+	int(fdoshared.TO2_62_GET_OVNEXTENTRY),
+	int(fdoshared.TO2_63_OV_NEXTENTRY),
+	int(fdoshared.TO2_64_PROVE_DEVICE),
+	int(fdoshared.TO2_65_SETUP_DEVICE),
+	int(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY),
+	int(fdoshared.TO2_67_OWNER_SERVICE_INFO_READY),
+	int(fdoshared.TO2_68_DEVICE_SERVICE_INFO),
+	int(fdoshared.TO2_69_OWNER_SERVICE_INFO),
+	int(fdoshared.TO2_70_DONE),
+	int(fdoshared.TO2_71_DONE2),
+	int(fdoshared.TO_ERROR_255),
+}
+
+// Returns pointer to the empty struct the server decodes the message of the given type into
+func fuzzNewMessageStruct(msgType int) interface{} {
+	switch fdoshared.FdoCmd(msgType) {
+	case fdoshared.TO0_20_HELLO:
+		return &fdoshared.Hello20{}
+	case fdoshared.TO0_21_HELLO_ACK:
+		return &fdoshared.HelloAck21{}
+	case fdoshared.TO0_22_OWNER_SIGN:
+		return &fdoshared.OwnerSign22{}
+	case fdoshared.TO0_23_ACCEPT_OWNER:
+		return &fdoshared.AcceptOwner23{}
+	case fdoshared.TO1_30_HELLO_RV:
+		return &fdoshared.HelloRV30{}
+	case fdoshared.TO1_31_HELLO_RV_ACK:
+		return &fdoshared.HelloRVAck31{}
+	case fdoshared.TO1_32_PROVE_TO_RV:
+		return &fdoshared.CoseSignature{}
+	case fdoshared.TO1_33_RV_REDIRECT:
+		return &fdoshared.RVRedirect33{}
+	case fdoshared.TO2_60_HELLO_DEVICE:
+		return &fdoshared.HelloDevice60{}
+	case fdoshared.TO2_61_PROVE_OVHDR:
+		return &fdoshared.CoseSignature{}
+	case fdoshared.TO2_62_GET_OVNEXTENTRY:
+		return &fdoshared.GetOVNextEntry62{}
+	case fdoshared.TO2_63_OV_NEXTENTRY:
+		return &fdoshared.OVNextEntry63{}
+	case fdoshared.TO2_64_PROVE_DEVICE:
+		return &fdoshared.ProveDevice64{}
+	case fdoshared.TO2_65_SETUP_DEVICE:
+		return &fdoshared.SetupDevice65{}
+	case fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY:
+		return &fdoshared.DeviceServiceInfoReady66{}
+	case fdoshared.TO2_67_OWNER_SERVICE_INFO_READY:
+		return &fdoshared.OwnerServiceInfoReady67{}
+	case fdoshared.TO2_68_DEVICE_SERVICE_INFO:
+		return &fdoshared.DeviceServiceInfo68{}
+	case fdoshared.TO2_69_OWNER_SERVICE_INFO:
+		return &fdoshared.OwnerServiceInfo69{}
+	case fdoshared.TO2_70_DONE:
+		return &fdoshared.Done70{}
+	case fdoshared.TO2_71_DONE2:
+		return &fdoshared.Done271{}
+	case fdoshared.TO_ERROR_255:
+		return &fdoshared.FdoError{}
+	default:
+		return nil
+	}
+}
+
+func fuzz_marshal(t testing.TB, payload interface{}) []byte {
+	payloadBytes, err := fdoshared.CborCust.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Error marshaling seed. %s", err.Error())
+	}
+
+	return payloadBytes
+}
+
+// Generates vouchers and protocol messages the same way the virtual device and the servers do
+func fuzz_newExchange(t testing.TB) fuzzExchange {
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
+		"http://127.0.0.1:8080",
+		"https://[::1]:8443",
+		"https://rv.example.com:443",
+	})
+	if err != nil {
+		t.Fatalf("Error generating rvinfo. %s", err.Error())
+	}
+
+	exchange := fuzzExchange{
+		rvInfo:   rvInfo,
+		messages: map[int][][]byte{},
+	}
+
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		credBase, err := fdoshared.NewWawDeviceCredential(sgType)
+		if err != nil {
+			t.Fatalf("Error generating device credential. %s", err.Error())
+		}
+
+		// EPID can not be used for the owner key
+		voucherSgType := sgType
+		if fdoshared.IsEpidSgType(sgType) {
+			voucherSgType = fdoshared.StSECP256R1
+		}
+
+		credAndVoucher, err := fdodevice.NewVirtualDeviceAndVoucher(*credBase, voucherSgType, rvInfo, testcom.NULL_TEST, fdoshared.NewConf_Rand(int64(sgType)))
+		if err != nil {
+			t.Fatalf("Error generating voucher. %s", err.Error())
+		}
+
+		exchange.vouchers = append(exchange.vouchers, *credAndVoucher)
+	}
+
+	voucher := exchange.vouchers[0].VoucherDBEntry.Voucher
+	deviceCred := exchange.vouchers[0].WawDeviceCredential
+	nonce := fdoshared.NewFdoNonce()
+	sigInfo := fdoshared.SigInfo{SgType: deviceCred.DCSigInfo.SgType, Info: []byte{}}
+	maxSz := uint16(1300)
+
+	to0d := fdoshared.To0d{
+		OwnershipVoucher: voucher,
+		WaitSeconds:      3600,
+		NonceTO0Sign:     nonce,
+	}
+
+	addMessage := func(msgType fdoshared.FdoCmd, payload interface{}) {
+		exchange.messages[int(msgType)] = append(exchange.messages[int(msgType)], fuzz_marshal(t, payload))
+	}
+
+	addMessage(fdoshared.TO0_20_HELLO, fdoshared.Hello20{})
+	addMessage(fdoshared.TO0_21_HELLO_ACK, fdoshared.HelloAck21{NonceTO0Sign: nonce})
+	addMessage(fdoshared.TO0_22_OWNER_SIGN, fdoshared.OwnerSign22{
+		To0d: fuzz_marshal(t, to0d),
+		To1d: voucher.OVEntryArray[0],
+	})
+	addMessage(fdoshared.TO0_23_ACCEPT_OWNER, fdoshared.AcceptOwner23{WaitSeconds: 3600})
+	addMessage(fdoshared.TO1_30_HELLO_RV, fdoshared.HelloRV30{Guid: deviceCred.DCGuid, EASigInfo: sigInfo})
+	addMessage(fdoshared.TO1_31_HELLO_RV_ACK, fdoshared.HelloRVAck31{NonceTO1Proof: nonce, EBSigInfo: sigInfo})
+	addMessage(fdoshared.TO1_32_PROVE_TO_RV, voucher.OVEntryArray[0])
+	addMessage(fdoshared.TO1_33_RV_REDIRECT, fdoshared.RVRedirect33{RVRedirect: voucher.OVEntryArray[0]})
+	addMessage(fdoshared.TO2_60_HELLO_DEVICE, fdoshared.HelloDevice60{
+		MaxDeviceMessageSize: maxSz,
+		Guid:                 deviceCred.DCGuid,
+		NonceTO2ProveOV:      nonce,
+		KexSuiteName:         fdoshared.KEX_ECDH256,
+		CipherSuiteName:      fdoshared.CIPHER_A128GCM,
+		EASigInfo:            sigInfo,
+	})
+	addMessage(fdoshared.TO2_61_PROVE_OVHDR, voucher.OVEntryArray[0])
+	addMessage(fdoshared.TO2_62_GET_OVNEXTENTRY, fdoshared.GetOVNextEntry62{GetOVNextEntry: 1})
+	for i, ovEntry := range voucher.OVEntryArray {
+		addMessage(fdoshared.TO2_63_OV_NEXTENTRY, fdoshared.OVNextEntry63{OVEntryNum: uint8(i), OVEntry: ovEntry})
+	}
+	addMessage(fdoshared.TO2_64_PROVE_DEVICE, voucher.OVEntryArray[0])
+	addMessage(fdoshared.TO2_65_SETUP_DEVICE, voucher.OVEntryArray[0])
+	addMessage(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, fdoshared.DeviceServiceInfoReady66{
+		ReplacementHMac:       &voucher.OVHeaderHMac,
+		MaxOwnerServiceInfoSz: &maxSz,
+	})
+	addMessage(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, fdoshared.DeviceServiceInfoReady66{})
+	addMessage(fdoshared.TO2_67_OWNER_SERVICE_INFO_READY, fdoshared.OwnerServiceInfoReady67{MaxDeviceServiceInfoSz: &maxSz})
+	addMessage(fdoshared.TO2_68_DEVICE_SERVICE_INFO, fdoshared.DeviceServiceInfo68{
+		IsMoreServiceInfo: false,
+		ServiceInfo: []fdoshared.ServiceInfoKV{
+			{ServiceInfoKey: "devmod:active", ServiceInfoVal: fuzz_marshal(t, true)},
+		},
+	})
+	addMessage(fdoshared.TO2_69_OWNER_SERVICE_INFO, fdoshared.OwnerServiceInfo69{IsDone: true, ServiceInfo: []fdoshared.ServiceInfoKV{}})
+	addMessage(fdoshared.TO2_70_DONE, fdoshared.Done70{NonceTO2ProveDv: nonce})
+	addMessage(fdoshared.TO2_71_DONE2, fdoshared.Done271{NonceTO2SetupDv: nonce})
+	addMessage(fdoshared.TO_ERROR_255, fdoshared.FdoError{
+		EMErrorCode: fdoshared.MESSAGE_BODY_ERROR,
+		EMPrevMsgID: fdoshared.TO2_60_HELLO_DEVICE,
+		EMErrorStr:  "Error decoding message",
+		EMErrorTs:   0,
+		EMErrorCID:  0,
+	})
+
+	return exchange
+}
+
+// Writes the entry in the go fuzz corpus file format. Seed files are named seed-<hash>, to not mix with the found failures
+func fuzz_writeCorpusEntry(t *testing.T, corpusDir string, values ...interface{}) {
+	entry := "go test fuzz v1\n"
+	for _, value := range values {
+		switch typedValue := value.(type) {
+		case int:
+			entry += fmt.Sprintf("int(%d)\n", typedValue)
+		case []byte:
+			entry += fmt.Sprintf("[]byte(%q)\n", typedValue)
+		default:
+			t.Fatalf("Unsupported corpus value type %T", value)
+		}
+	}
+
+	entryHash := sha256.Sum256([]byte(entry))
+	err := os.WriteFile(filepath.Join(corpusDir, "seed-"+hex.EncodeToString(entryHash[:8])), []byte(entry), 0644)
+	if err != nil {
+		t.Fatalf("Error writing corpus entry. %s", err.Error())
+	}
+}
+
+func TestFuzzUpdateCorpus(t *testing.T) {
+	if !*fuzzUpdateCorpus {
+		t.Skip("Run with -update-fuzz-corpus to regenerate seed corpora")
+	}
+
+	exchange := fuzz_newExchange(t)
+	corpora := map[string][][]interface{}{}
+	addEntry := func(fuzzTarget string, values ...interface{}) {
+		corpora[fuzzTarget] = append(corpora[fuzzTarget], values)
+	}
+
+	for msgType, seeds := range exchange.messages {
+		for _, seed := range seeds {
+			addEntry("FuzzCborUnmarshalMessages", msgType, seed)
+		}
+	}
+
+	// Only TO2 messages after ProveDevice64 are encrypted
+	for cipherSuite := range fdoshared.CipherSuitesInfoMap {
+		for msgType := fdoshared.TO2_65_SETUP_DEVICE; msgType <= fdoshared.TO2_71_DONE2; msgType++ {
+			encrypted, err := fdoshared.AddEncryptionWrapping(exchange.messages[int(msgType)][0], fuzzSessionKeyInfo, cipherSuite)
+			if err != nil { // Not every cipher suite is implemented yet
+				continue
+			}
+
+			addEntry("FuzzRemoveEncryptionWrapping", int(cipherSuite), encrypted)
+		}
+	}
+
+	addEntry("FuzzNewMappedRVInfo", fuzz_marshal(t, exchange.rvInfo))
+	for _, credAndVoucher := range exchange.vouchers {
+		voucher := credAndVoucher.VoucherDBEntry.Voucher
+		ovHeader, err := voucher.GetOVHeader()
+		if err != nil {
+			t.Fatalf("Error decoding seed voucher header. %s", err.Error())
+		}
+
+		addEntry("FuzzNewMappedRVInfo", fuzz_marshal(t, ovHeader.OVRvInfo))
+		addEntry("FuzzOwnershipVoucherValidate", fuzz_marshal(t, voucher))
+
+		signerPublicKey := ovHeader.OVPublicKey
+		for _, ovEntry := range voucher.OVEntryArray {
+			addEntry("FuzzVerifyCoseSignature", fuzz_marshal(t, ovEntry), fuzz_marshal(t, signerPublicKey))
+
+			signerPublicKey, err = ovEntry.GetOVEntryPubKey()
+			if err != nil {
+				t.Fatalf("Error decoding seed OVEntry. %s", err.Error())
+			}
+		}
+	}
+
+	for fuzzTarget, entries := range corpora {
+		corpusDir := filepath.Join("testdata", "fuzz", fuzzTarget)
+		oldSeeds, _ := filepath.Glob(filepath.Join(corpusDir, "seed-*"))
+		for _, oldSeed := range oldSeeds {
+			os.Remove(oldSeed)
+		}
+
+		err := os.MkdirAll(corpusDir, 0755)
+		if err != nil {
+			t.Fatalf("Error creating corpus dir. %s", err.Error())
+		}
+
+		for _, values := range entries {
+			fuzz_writeCorpusEntry(t, corpusDir, values...)
+		}
+	}
+}
+
+// Seeds are in testdata/fuzz/FuzzCborUnmarshalMessages
+func FuzzCborUnmarshalMessages(f *testing.F) {
+	f.Fuzz(func(t *testing.T, msgType int, data []byte) {
+		msgStruct := fuzzNewMessageStruct(msgType)
+		if msgStruct == nil {
+			msgStruct = fuzzNewMessageStruct(fuzzMessageTypes[uint(msgType)%uint(len(fuzzMessageTypes))])
+		}
+
+		err := fdoshared.CborCust.Unmarshal(data, msgStruct)
+		if err != nil {
+			return
+		}
+
+		_, err = fdoshared.CborCust.Marshal(msgStruct)
+		if err != nil {
+			t.Fatalf("Successfully decoded message %d fails to re-encode. %s", msgType, err.Error())
+		}
+	})
+}
+
+// Seeds are in testdata/fuzz/FuzzNewMappedRVInfo
+func FuzzNewMappedRVInfo(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rvInfo fdoshared.RendezvousInfo
+		err := fdoshared.CborCust.Unmarshal(data, &rvInfo)
+		if err != nil {
+			return
+		}
+
+		mappedRvInfo, err := fdoshared.NewMappedRVInfo(rvInfo)
+		if err != nil {
+			return
+		}
+
+		for _, directive := range mappedRvInfo {
+			directive.Validate()
+			directive.GetOwnerUrls()
+		}
+	})
+}
+
+func FuzzFdoIPAddressFromBytes(f *testing.F) {
+	f.Add([]byte{127, 0, 0, 1})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ipAddress, err := fdoshared.FdoIPAddressFromBytes(data)
+		if err != nil {
+			return
+		}
+
+		if len(ipAddress) != fdoshared.IP4Len && len(ipAddress) != fdoshared.IP6Len {
+			t.Fatalf("Accepted IP address of %d bytes", len(ipAddress))
+		}
+
+		_ = ipAddress.String()
+	})
+}
+
+var fuzzSessionKeyInfo fdoshared.SessionKeyInfo = fdoshared.SessionKeyInfo{
+	ShSe:        []byte("fuzz ShSe"),
+	ContextRand: []byte("fuzz ContextRand"),
+}
+
+// Seeds are in testdata/fuzz/FuzzRemoveEncryptionWrapping
+func FuzzRemoveEncryptionWrapping(f *testing.F) {
+	f.Fuzz(func(t *testing.T, cipherSuite int, data []byte) {
+		fdoshared.RemoveEncryptionWrapping(data, fuzzSessionKeyInfo, fdoshared.CipherSuiteName(cipherSuite))
+	})
+}
+
+type fuzzKexOwner struct {
+	kexParams       fdoshared.KeXParams
+	ownerPrivateKey crypto.Decrypter
+	ownerPublicKey  *fdoshared.FdoPublicKey
+}
+
+// RSA keys are fixed, generating them on every fuzz worker start is slow
+func fuzz_loadOwnerKey(keyPath string, sgType fdoshared.DeviceSgType) (interface{}, *fdoshared.FdoPublicKey, error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBlock, _ := pem.Decode(keyBytes)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("No PEM block in %s", keyPath)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := fdoshared.NewFdoPublicKey(fdoshared.CastPublicFromPrivate(privateKey), sgType, fdoshared.X509)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privateKey, publicKey, nil
+}
+
+func FuzzDeriveSessionKey(f *testing.F) {
+	owners := map[fdoshared.KexSuiteName]fuzzKexOwner{}
+	for _, kexSuite := range fdoshared.KexSuitNames {
+		var ownerPrivateKey interface{}
+		var ownerPublicKey *fdoshared.FdoPublicKey
+		var err error
+
+		if kexSuite == fdoshared.KEX_ASYMKEX2048 {
+			ownerPrivateKey, ownerPublicKey, err = fuzz_loadOwnerKey("testdata/fuzz-owner-rsa2048.pem", fdoshared.StRSA2048)
+		} else if kexSuite == fdoshared.KEX_ASYMKEX3072 {
+			ownerPrivateKey, ownerPublicKey, err = fuzz_loadOwnerKey("testdata/fuzz-owner-rsa3072.pem", fdoshared.StRSA3072)
+		}
+		if err != nil {
+			f.Fatalf("Error generating owner key. %s", err.Error())
+		}
+
+		kexParams, err := fdoshared.GenerateXABKeyExchange(kexSuite, nil)
+		if err != nil {
+			f.Fatalf("Error generating xAKeyExchange. %s", err.Error())
+		}
+
+		ownerDecrypter, _ := ownerPrivateKey.(crypto.Decrypter)
+		owners[kexSuite] = fuzzKexOwner{
+			kexParams:       *kexParams,
+			ownerPrivateKey: ownerDecrypter,
+			ownerPublicKey:  ownerPublicKey,
+		}
+
+		deviceKex, err := fdoshared.GenerateXABKeyExchange(kexSuite, ownerPublicKey)
+		if err != nil {
+			f.Fatalf("Error generating xBKeyExchange. %s", err.Error())
+		}
+
+		f.Add(uint8(len(owners)-1), false, deviceKex.XAKeyExchange)
+		f.Add(uint8(len(owners)-1), true, kexParams.XAKeyExchange)
+	}
+
+	f.Fuzz(func(t *testing.T, kexIndex uint8, isDevice bool, xBKeyExchange []byte) {
+		kexSuite := fdoshared.KexSuitNames[int(kexIndex)%len(fdoshared.KexSuitNames)]
+		owner := owners[kexSuite]
+
+		fdoshared.DeriveSessionKey(owner.kexParams, xBKeyExchange, isDevice, owner.ownerPrivateKey)
+	})
+}
+
+// Seeds are in testdata/fuzz/FuzzVerifyCoseSignature
+func FuzzVerifyCoseSignature(f *testing.F) {
+	f.Fuzz(func(t *testing.T, coseSigBytes []byte, publicKeyBytes []byte) {
+		var coseSig fdoshared.CoseSignature
+		err := fdoshared.CborCust.Unmarshal(coseSigBytes, &coseSig)
+		if err != nil {
+			return
+		}
+
+		var publicKey fdoshared.FdoPublicKey
+		err = fdoshared.CborCust.Unmarshal(publicKeyBytes, &publicKey)
+		if err != nil {
+			return
+		}
+
+		fdoshared.VerifyCoseSignature(coseSig, publicKey)
+	})
+}
+
+// Seeds are in testdata/fuzz/FuzzOwnershipVoucherValidate
+func FuzzOwnershipVoucherValidate(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var voucher fdoshared.OwnershipVoucher
+		err := fdoshared.CborCust.Unmarshal(data, &voucher)
+		if err != nil {
+			return
+		}
+
+		voucher.Validate()
+	})
+}
@@ -16,6 +16,10 @@ const (
 	HASH_SHA384      HashType = -43
 	HASH_HMAC_SHA256 HashType = 5
 	HASH_HMAC_SHA384 HashType = 6
+
+	// Algorithm extensions
+	HASH_SHA512      HashType = -44
+	HASH_HMAC_SHA512 HashType = 7
 )
 
 var HashHmacAlgs []HashType = []HashType{
@@ -34,6 +38,7 @@ type HashOrHmac struct {
 var HmacToHashAlg map[HashType]HashType = map[HashType]HashType{
 	HASH_HMAC_SHA256: HASH_SHA256,
 	HASH_HMAC_SHA384: HASH_SHA384,
+	HASH_HMAC_SHA512: HASH_SHA512,
 }
 
 func GenerateFdoHash(data []byte, hashType HashType) (HashOrHmac, error) {
@@ -48,6 +53,18 @@ func GenerateFdoHash(data []byte, hashType HashType) (HashOrHmac, error) {
 	case HASH_SHA384:
 		hashDigest := sha512.Sum384(data)
 
+		return HashOrHmac{
+			Type: hashType,
+			Hash: hashDigest[:],
+		}, nil
+	case HASH_SHA512:
+		err := checkAlgExtension("SHA512")
+		if err != nil {
+			return HashOrHmac{}, err
+		}
+
+		hashDigest := sha512.Sum512(data)
+
 		return HashOrHmac{
 			Type: hashType,
 			Hash: hashDigest[:],
@@ -71,6 +88,19 @@ func GenerateFdoHmac(data []byte, hashType HashType, key []byte) (HashOrHmac, er
 		macInst := hmac.New(sha512.New384, key)
 		macInst.Write(data)
 
+		return HashOrHmac{
+			Type: hashType,
+			Hash: macInst.Sum(nil),
+		}, nil
+	case HASH_HMAC_SHA512:
+		err := checkAlgExtension("HMAC-SHA512")
+		if err != nil {
+			return HashOrHmac{}, err
+		}
+
+		macInst := hmac.New(sha512.New, key)
+		macInst.Write(data)
+
 		return HashOrHmac{
 			Type: hashType,
 			Hash: macInst.Sum(nil),
@@ -99,6 +129,21 @@ func VerifyHash(data []byte, fdoHashB HashOrHmac) error {
 		}
 
 		fdoHashA, _ := GenerateFdoHash(data, fdoHashB.Type)
+		if bytes.Equal(fdoHashB.Hash, fdoHashA.Hash) {
+			return nil
+		} else {
+			return errors.New("failed to verify hash. Hashes don't match")
+		}
+	case HASH_SHA512:
+		if len(fdoHashB.Hash) != sha512.New().Size() {
+			return errors.New("failed to verify hash. The input hash does not match expected hash size")
+		}
+
+		fdoHashA, err := GenerateFdoHash(data, fdoHashB.Type)
+		if err != nil {
+			return errors.New("failed to verify hash. " + err.Error())
+		}
+
 		if bytes.Equal(fdoHashB.Hash, fdoHashA.Hash) {
 			return nil
 		} else {
@@ -131,6 +176,17 @@ func VerifyHMac(data []byte, inputHmac HashOrHmac, key []byte) error {
 		} else {
 			return errors.New("failed to verify HMAC. HMACs do not match")
 		}
+	case HASH_HMAC_SHA512:
+		computedMac, err := GenerateFdoHmac(data, inputHmac.Type, key)
+		if err != nil {
+			return errors.New("failed to verify HMAC. " + err.Error())
+		}
+
+		if bytes.Equal(inputHmac.Hash, computedMac.Hash) {
+			return nil
+		} else {
+			return errors.New("failed to verify HMAC. HMACs do not match")
+		}
 	default:
 		return fmt.Errorf("error verifying hmac. %d is unknown hmac algorithm", inputHmac.Type)
 	}
@@ -142,6 +198,8 @@ func NewHmacKey(hashType HashType) []byte {
 		return NewRandomBuffer(sha256.New().Size())
 	case HASH_HMAC_SHA384:
 		return NewRandomBuffer(sha512.New384().Size())
+	case HASH_HMAC_SHA512:
+		return NewRandomBuffer(sha512.New().Size())
 	default:
 		return []byte{}
 	}
@@ -159,8 +217,15 @@ func NegotiateHashHmac(deviceSg DeviceSgType, ownerSgType DeviceSgType) SgTypeIn
 		HmacType: HASH_HMAC_SHA384,
 	}
 
+	sha512SgInfo := SgTypeInfo{
+		HashType: HASH_SHA512,
+		HmacType: HASH_HMAC_SHA512,
+	}
+
 	if deviceSg == ownerSgType {
 		return SgTypeInfoMap[deviceSg]
+	} else if deviceSg == StSECP521R1 || ownerSgType == StSECP521R1 {
+		return sha512SgInfo
 	} else if deviceSg == StEPID10 || deviceSg == StEPID11 {
 		if ownerSgType == StSECP384R1 {
 			return sha384SgInfo
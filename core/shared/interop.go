@@ -1,7 +1,6 @@
 package fdoshared
 
 import (
-	"context"
 	"fmt"
 )
 
@@ -28,8 +27,8 @@ type IopLoggerPayload struct {
 	Nonce      FdoNonce
 }
 
-func SubmitIopLoggerEvent(ctx context.Context, guid FdoGuid, toProtocol FdoToProtocol, nonce FdoNonce, authzHeader string) error {
-	if !ctx.Value(CFG_ENV_INTEROP_ENABLED).(bool) {
+func SubmitIopLoggerEvent(iopConfig InteropConfig, guid FdoGuid, toProtocol FdoToProtocol, nonce FdoNonce, authzHeader string) error {
+	if !iopConfig.Enabled() {
 		return nil
 	}
 
@@ -40,7 +39,7 @@ func SubmitIopLoggerEvent(ctx context.Context, guid FdoGuid, toProtocol FdoToPro
 	}
 	payloadBytes, _ := CborCust.Marshal(payload)
 
-	srvUrl := ctx.Value(CFG_ENV_INTEROP_DASHBOARD_URL).(string) + IOPLOGGER_LOGGER_PATH
+	srvUrl := iopConfig.DashboardUrl + IOPLOGGER_LOGGER_PATH
 
 	bodyBytes, _, httpStatusCode, err := SendCborPost(
 		SRVEntry{SrvURL: srvUrl, OverrideURL: true},
@@ -62,12 +61,12 @@ func SubmitIopLoggerEvent(ctx context.Context, guid FdoGuid, toProtocol FdoToPro
 	return nil
 }
 
-func IopGetAuthz(ctx context.Context, comp IopComp) (string, error) {
+func IopGetAuthz(iopConfig InteropConfig, comp IopComp) (string, error) {
 	switch comp {
 	case IopDO:
-		return ctx.Value(CFG_ENV_INTEROP_DASHBOARD_RV_AUTHZ).(string), nil
+		return iopConfig.RvAuthz, nil
 	case IopRV:
-		return ctx.Value(CFG_ENV_INTEROP_DASHBOARD_DO_AUTHZ).(string), nil
+		return iopConfig.DoAuthz, nil
 	}
 
 	return "", fmt.Errorf("invalid component %s", comp)
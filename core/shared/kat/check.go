@@ -0,0 +1,245 @@
+package kat
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type ResultStatus string
+
+const (
+	RESULT_PASSED  ResultStatus = "passed"
+	RESULT_FAILED  ResultStatus = "failed"
+	RESULT_SKIPPED ResultStatus = "skipped"
+)
+
+type Result struct {
+	Id      string
+	Status  ResultStatus
+	Message string
+}
+
+func passed(id string) Result {
+	return Result{Id: id, Status: RESULT_PASSED}
+}
+
+func failed(id string, message string) Result {
+	return Result{Id: id, Status: RESULT_FAILED, Message: message}
+}
+
+// Extension sgTypes are skipped unless ALG_EXTENSIONS is enabled
+func extSkipped(vector CoseSign1Vector) bool {
+	return fdoshared.IsExtSgType(vector.SgType) && !fdoshared.AlgExtensionsEnabled()
+}
+
+func computeSessionKey(vector SessionKeyVector) (*fdoshared.SessionKeyInfo, error) {
+	kexParams, err := fdoshared.NewXABKeyExchangeFromSecret(vector.KexSuite, vector.Secret, vector.Random)
+	if err != nil {
+		return nil, err
+	}
+
+	var ownerDecrypter crypto.Decrypter
+	if len(vector.OwnerPrivateKey) != 0 {
+		ownerPrivateKey, err := fdoshared.ExtractPrivateKey(vector.OwnerPrivateKey)
+		if err != nil {
+			return nil, errors.New("error decoding owner private key. " + err.Error())
+		}
+
+		ownerDecrypter, _ = ownerPrivateKey.(crypto.Decrypter)
+	}
+
+	return fdoshared.DeriveSessionKey(*kexParams, vector.XBKeyExchange, vector.IsDevice, ownerDecrypter)
+}
+
+func computeCoseSign1(vector CoseSign1Vector) ([]byte, error) {
+	privateKey, err := fdoshared.ExtractPrivateKey(vector.PrivateKey)
+	if err != nil {
+		return nil, errors.New("error decoding private key. " + err.Error())
+	}
+
+	coseSig, err := fdoshared.GenerateCoseSignature(vector.Payload, fdoshared.ProtectedHeader{}, fdoshared.UnprotectedHeader{}, privateKey, vector.SgType)
+	if err != nil {
+		return nil, err
+	}
+
+	return fdoshared.CborCust.Marshal(*coseSig)
+}
+
+// Runs this implementation against the vectors. Disabled extension vectors are left out
+func ComputeOutputs(vectors Vectors) (*Outputs, error) {
+	outputs := Outputs{
+		Implementation: "iot-fdo-conformance-tools",
+	}
+
+	for _, vector := range vectors.Kdf {
+		output, err := fdoshared.Sp800108CounterKDF(vector.Size, vector.HmacAlg, vector.Key, vector.ContextRand)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+		}
+
+		outputs.Outputs = append(outputs.Outputs, Output{Id: vector.Id, Output: output})
+	}
+
+	for _, vector := range vectors.Encryption {
+		sessionKeyInfo := fdoshared.SessionKeyInfo{
+			ShSe:        vector.ShSe,
+			ContextRand: vector.ContextRand,
+		}
+
+		output, err := fdoshared.AddEncryptionWrappingWithIv(vector.Plaintext, sessionKeyInfo, vector.CipherSuite, vector.Iv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+		}
+
+		outputs.Outputs = append(outputs.Outputs, Output{Id: vector.Id, Output: output})
+	}
+
+	for _, vector := range vectors.SessionKey {
+		sessionKeyInfo, err := computeSessionKey(vector)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+		}
+
+		outputs.Outputs = append(outputs.Outputs, Output{Id: vector.Id, Output: sessionKeyInfo.ShSe, ContextRand: sessionKeyInfo.ContextRand})
+	}
+
+	for _, vector := range vectors.CoseSign1 {
+		if extSkipped(vector) {
+			continue
+		}
+
+		output, err := computeCoseSign1(vector)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+		}
+
+		outputs.Outputs = append(outputs.Outputs, Output{Id: vector.Id, Output: output})
+	}
+
+	return &outputs, nil
+}
+
+func checkEncryption(vector EncryptionVector, output Output) Result {
+	if bytes.Equal(output.Output, vector.Output) {
+		return passed(vector.Id)
+	}
+
+	// Helps to tell a CBOR encoding difference from a crypto one
+	plaintext, err := fdoshared.RemoveEncryptionWrapping(output.Output, fdoshared.SessionKeyInfo{
+		ShSe:        vector.ShSe,
+		ContextRand: vector.ContextRand,
+	}, vector.CipherSuite)
+	if err != nil {
+		return failed(vector.Id, "output does not match, and can not be decrypted. "+err.Error())
+	}
+
+	if !bytes.Equal(plaintext, vector.Plaintext) {
+		return failed(vector.Id, "output does not match, and decrypts to a different plaintext")
+	}
+
+	return failed(vector.Id, "output decrypts, but does not match expected encoding")
+}
+
+func checkCoseSign1(vector CoseSign1Vector, output Output) Result {
+	var coseSig fdoshared.CoseSignature
+	err := fdoshared.CborCust.Unmarshal(output.Output, &coseSig)
+	if err != nil {
+		return failed(vector.Id, "error decoding COSE_Sign1. "+err.Error())
+	}
+
+	var protected fdoshared.ProtectedHeader
+	err = fdoshared.CborCust.Unmarshal(coseSig.Protected, &protected)
+	if err != nil {
+		return failed(vector.Id, "error decoding protected header. "+err.Error())
+	}
+
+	if protected.Alg == nil || *protected.Alg != int(vector.SgType) {
+		return failed(vector.Id, fmt.Sprintf("protected header alg must be %d", vector.SgType))
+	}
+
+	if !bytes.Equal(coseSig.Payload, vector.Payload) {
+		return failed(vector.Id, "payload does not match")
+	}
+
+	publicKeyInst, err := x509.ParsePKIXPublicKey(vector.PublicKey)
+	if err != nil {
+		return failed(vector.Id, "error decoding vector public key. "+err.Error())
+	}
+
+	publicKey, err := fdoshared.NewFdoPublicKey(publicKeyInst, vector.SgType, fdoshared.X509)
+	if err != nil {
+		return failed(vector.Id, "error encoding vector public key. "+err.Error())
+	}
+
+	err = fdoshared.VerifyCoseSignature(coseSig, *publicKey)
+	if err != nil {
+		return failed(vector.Id, "error verifying signature. "+err.Error())
+	}
+
+	if vector.Deterministic && !bytes.Equal(output.Output, vector.Output) {
+		return failed(vector.Id, "signature verifies, but does not match deterministic output")
+	}
+
+	return passed(vector.Id)
+}
+
+// Checks outputs of the implementation under test against the vectors. Vectors without output fail
+func CheckOutputs(vectors Vectors, outputs Outputs) []Result {
+	outputsMap := map[string]Output{}
+	for _, output := range outputs.Outputs {
+		outputsMap[output.Id] = output
+	}
+
+	var results []Result
+
+	for _, vector := range vectors.Kdf {
+		output, ok := outputsMap[vector.Id]
+		if !ok {
+			results = append(results, failed(vector.Id, "missing output"))
+		} else if !bytes.Equal(output.Output, vector.Output) {
+			results = append(results, failed(vector.Id, "output does not match"))
+		} else {
+			results = append(results, passed(vector.Id))
+		}
+	}
+
+	for _, vector := range vectors.Encryption {
+		output, ok := outputsMap[vector.Id]
+		if !ok {
+			results = append(results, failed(vector.Id, "missing output"))
+		} else {
+			results = append(results, checkEncryption(vector, output))
+		}
+	}
+
+	for _, vector := range vectors.SessionKey {
+		output, ok := outputsMap[vector.Id]
+		if !ok {
+			results = append(results, failed(vector.Id, "missing output"))
+		} else if !bytes.Equal(output.Output, vector.ShSe) {
+			results = append(results, failed(vector.Id, "ShSe does not match"))
+		} else if !bytes.Equal(output.ContextRand, vector.ContextRand) {
+			results = append(results, failed(vector.Id, "ContextRand does not match"))
+		} else {
+			results = append(results, passed(vector.Id))
+		}
+	}
+
+	for _, vector := range vectors.CoseSign1 {
+		output, ok := outputsMap[vector.Id]
+		if extSkipped(vector) {
+			results = append(results, Result{Id: vector.Id, Status: RESULT_SKIPPED, Message: "algorithm extensions are disabled"})
+		} else if !ok {
+			results = append(results, failed(vector.Id, "missing output"))
+		} else {
+			results = append(results, checkCoseSign1(vector, output))
+		}
+	}
+
+	return results
+}
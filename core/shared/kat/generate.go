@@ -0,0 +1,253 @@
+package kat
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+var kdfHmacAlgs []fdoshared.HashType = []fdoshared.HashType{
+	fdoshared.HASH_HMAC_SHA256,
+	fdoshared.HASH_HMAC_SHA384,
+}
+
+// 16 and 32 are single block, 80 is multi block for both HMACs
+var kdfSizes []int = []int{16, 32, 80}
+
+// Block aligned and unaligned plaintexts, to cover CBC padding
+var encPlaintextLens []int = []int{16, 37}
+
+func generateKdfVectors() ([]KdfVector, error) {
+	var vectors []KdfVector
+	for _, hmacAlg := range kdfHmacAlgs {
+		for _, size := range kdfSizes {
+			vector := KdfVector{
+				Id:          fmt.Sprintf("kdf-%d-%d", hmacAlg, size),
+				HmacAlg:     hmacAlg,
+				Size:        size,
+				Key:         fdoshared.NewRandomBuffer(32),
+				ContextRand: fdoshared.NewRandomBuffer(16),
+			}
+
+			output, err := fdoshared.Sp800108CounterKDF(vector.Size, vector.HmacAlg, vector.Key, vector.ContextRand)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+			}
+
+			vector.Output = output
+			vectors = append(vectors, vector)
+		}
+	}
+
+	return vectors, nil
+}
+
+func generateEncryptionVectors() ([]EncryptionVector, error) {
+	var vectors []EncryptionVector
+	for _, cipherSuite := range fdoshared.CipherSuiteNames {
+		for _, plaintextLen := range encPlaintextLens {
+			vector := EncryptionVector{
+				Id:          fmt.Sprintf("enc-%d-%d", cipherSuite, plaintextLen),
+				CipherSuite: cipherSuite,
+				ShSe:        fdoshared.NewRandomBuffer(32),
+				ContextRand: fdoshared.NewRandomBuffer(16),
+				Iv:          fdoshared.NewRandomBuffer(fdoshared.CipherSuitesInfoMap[cipherSuite].NonceIvLen),
+				Plaintext:   fdoshared.NewRandomBuffer(plaintextLen),
+			}
+
+			output, err := fdoshared.AddEncryptionWrappingWithIv(vector.Plaintext, fdoshared.SessionKeyInfo{
+				ShSe:        vector.ShSe,
+				ContextRand: vector.ContextRand,
+			}, vector.CipherSuite, vector.Iv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", vector.Id, err.Error())
+			}
+
+			vector.Output = output
+			vectors = append(vectors, vector)
+		}
+	}
+
+	return vectors, nil
+}
+
+// Secret and random of one side of the key exchange
+func newKexSecret(kexSuitName fdoshared.KexSuiteName) ([]byte, []byte, error) {
+	switch kexSuitName {
+	case fdoshared.KEX_DHKEXid14, fdoshared.KEX_DHKEXid15:
+		return fdoshared.NewRandomBuffer(32), nil, nil
+	case fdoshared.KEX_ECDH256, fdoshared.KEX_ECDH384:
+		curve, randomLen := elliptic.P256(), fdoshared.KEX_ECDH256_RANDOM_LEN
+		if kexSuitName == fdoshared.KEX_ECDH384 {
+			curve, randomLen = elliptic.P384(), fdoshared.KEX_ECDH384_RANDOM_LEN
+		}
+
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return key.D.FillBytes(make([]byte, (curve.Params().BitSize+7)/8)), fdoshared.NewRandomBuffer(randomLen), nil
+	case fdoshared.KEX_ASYMKEX2048:
+		return nil, fdoshared.NewRandomBuffer(fdoshared.KEX_ASYMKEX2048_RANDOM_LEN), nil
+	case fdoshared.KEX_ASYMKEX3072:
+		return nil, fdoshared.NewRandomBuffer(fdoshared.KEX_ASYMKEX3072_RANDOM_LEN), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown KeyExchange algorithm: %s", kexSuitName)
+	}
+}
+
+// Generates device and owner vectors from the same exchange, so both derive the same session key
+func generateSessionKeyVectors(kexSuitName fdoshared.KexSuiteName) ([]SessionKeyVector, error) {
+	deviceVector := SessionKeyVector{
+		Id:       fmt.Sprintf("kex-%s-device", kexSuitName),
+		KexSuite: kexSuitName,
+		IsDevice: true,
+	}
+	ownerVector := SessionKeyVector{
+		Id:       fmt.Sprintf("kex-%s-owner", kexSuitName),
+		KexSuite: kexSuitName,
+	}
+
+	var err error
+	deviceVector.Secret, deviceVector.Random, err = newKexSecret(kexSuitName)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerVector.Secret, ownerVector.Random, err = newKexSecret(kexSuitName)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceKex, err := fdoshared.NewXABKeyExchangeFromSecret(kexSuitName, deviceVector.Secret, deviceVector.Random)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerKex, err := fdoshared.NewXABKeyExchangeFromSecret(kexSuitName, ownerVector.Secret, ownerVector.Random)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceVector.XBKeyExchange = ownerKex.XAKeyExchange
+	ownerVector.XBKeyExchange = deviceKex.XAKeyExchange
+
+	var ownerDecrypter crypto.Decrypter
+	if kexSuitName == fdoshared.KEX_ASYMKEX2048 || kexSuitName == fdoshared.KEX_ASYMKEX3072 {
+		ownerSgType := fdoshared.StRSA2048
+		if kexSuitName == fdoshared.KEX_ASYMKEX3072 {
+			ownerSgType = fdoshared.StRSA3072
+		}
+
+		ownerPrivateKey, ownerPublicKey, err := fdoshared.GenerateVoucherKeypair(ownerSgType)
+		if err != nil {
+			return nil, err
+		}
+
+		ownerVector.OwnerPrivateKey, err = x509.MarshalPKCS8PrivateKey(ownerPrivateKey)
+		if err != nil {
+			return nil, errors.New("error encoding owner private key. " + err.Error())
+		}
+
+		ownerVector.XBKeyExchange, err = fdoshared.WrapOAEP(deviceVector.Random, *ownerPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		ownerDecrypter, _ = ownerPrivateKey.(crypto.Decrypter)
+	}
+
+	deviceSessionKey, err := fdoshared.DeriveSessionKey(*deviceKex, deviceVector.XBKeyExchange, true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", deviceVector.Id, err.Error())
+	}
+
+	ownerSessionKey, err := fdoshared.DeriveSessionKey(*ownerKex, ownerVector.XBKeyExchange, false, ownerDecrypter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ownerVector.Id, err.Error())
+	}
+
+	deviceVector.ShSe, deviceVector.ContextRand = deviceSessionKey.ShSe, deviceSessionKey.ContextRand
+	ownerVector.ShSe, ownerVector.ContextRand = ownerSessionKey.ShSe, ownerSessionKey.ContextRand
+
+	return []SessionKeyVector{deviceVector, ownerVector}, nil
+}
+
+func generateCoseSign1Vector(sgType fdoshared.DeviceSgType) (*CoseSign1Vector, error) {
+	vector := CoseSign1Vector{
+		Id:      fmt.Sprintf("sign1-%d", sgType),
+		SgType:  sgType,
+		Payload: fdoshared.NewRandomBuffer(40),
+		// ECDSA and PSS are randomized
+		Deterministic: sgType == fdoshared.StRSA2048 || sgType == fdoshared.StRSA3072 || sgType == fdoshared.StED25519,
+	}
+
+	privateKey, _, err := fdoshared.GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, err
+	}
+
+	vector.PrivateKey, err = x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.New("error encoding private key. " + err.Error())
+	}
+
+	vector.PublicKey, err = x509.MarshalPKIXPublicKey(privateKey.(crypto.Signer).Public())
+	if err != nil {
+		return nil, errors.New("error encoding public key. " + err.Error())
+	}
+
+	vector.Output, err = computeCoseSign1(vector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vector, nil
+}
+
+// Generates new vectors with random inputs. Vectors cover extension sgTypes, so algorithm extensions must be enabled
+func GenerateVectors() (*Vectors, error) {
+	if !fdoshared.AlgExtensionsEnabled() {
+		return nil, fmt.Errorf("vectors cover algorithm extensions. Set %s=true to generate them", fdoshared.CFG_ENV_ALG_EXTENSIONS)
+	}
+
+	var vectors Vectors
+	var err error
+
+	vectors.Kdf, err = generateKdfVectors()
+	if err != nil {
+		return nil, err
+	}
+
+	vectors.Encryption, err = generateEncryptionVectors()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kexSuitName := range fdoshared.KexSuitNames {
+		sessionKeyVectors, err := generateSessionKeyVectors(kexSuitName)
+		if err != nil {
+			return nil, err
+		}
+
+		vectors.SessionKey = append(vectors.SessionKey, sessionKeyVectors...)
+	}
+
+	for _, sgType := range append(append([]fdoshared.DeviceSgType{}, fdoshared.SgTypeList...), fdoshared.ExtSgTypeList...) {
+		vector, err := generateCoseSign1Vector(sgType)
+		if err != nil {
+			return nil, fmt.Errorf("sign1-%d: %s", sgType, err.Error())
+		}
+
+		vectors.CoseSign1 = append(vectors.CoseSign1, *vector)
+	}
+
+	return &vectors, nil
+}
@@ -0,0 +1,146 @@
+package kat
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Known-answer test vectors for the FDO crypto: KDF, EMB/ETM encryption, session key derivation and COSE_Sign1.
+// An external implementation reads the vectors, computes its own outputs, and the outputs are checked with CheckOutputs
+
+//go:embed vectors.json
+var embeddedVectors []byte
+
+// Byte string that is hex encoded in JSON
+type HexBytes []byte
+
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	var hexString string
+	err := json.Unmarshal(data, &hexString)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := hex.DecodeString(hexString)
+	if err != nil {
+		return errors.New("error decoding hex. " + err.Error())
+	}
+
+	*h = decoded
+	return nil
+}
+
+// Sp800108CounterKDF(Size, HmacAlg, Key, ContextRand) = Output
+type KdfVector struct {
+	Id          string             `json:"id"`
+	HmacAlg     fdoshared.HashType `json:"hmacAlg"`
+	Size        int                `json:"size"`
+	Key         HexBytes           `json:"key"`
+	ContextRand HexBytes           `json:"contextRand"`
+	Output      HexBytes           `json:"output"`
+}
+
+// AddEncryptionWrapping with a fixed IV or nonce. Output is EMB or ETM CBOR
+type EncryptionVector struct {
+	Id          string                    `json:"id"`
+	CipherSuite fdoshared.CipherSuiteName `json:"cipherSuite"`
+	ShSe        HexBytes                  `json:"shSe"`
+	ContextRand HexBytes                  `json:"contextRand"`
+	Iv          HexBytes                  `json:"iv"`
+	Plaintext   HexBytes                  `json:"plaintext"`
+	Output      HexBytes                  `json:"output"`
+}
+
+// DeriveSessionKey on one side. Secret is DH exponent or ECDH scalar, Random is ECDH or ASYMKEX random.
+// OwnerPrivateKey is PKCS#8 RSA key, and is only set for the owner side of ASYMKEX
+type SessionKeyVector struct {
+	Id              string                 `json:"id"`
+	KexSuite        fdoshared.KexSuiteName `json:"kexSuite"`
+	IsDevice        bool                   `json:"isDevice"`
+	Secret          HexBytes               `json:"secret"`
+	Random          HexBytes               `json:"random"`
+	XBKeyExchange   HexBytes               `json:"xBKeyExchange"`
+	OwnerPrivateKey HexBytes               `json:"ownerPrivateKey"`
+	ShSe            HexBytes               `json:"shSe"`
+	ContextRand     HexBytes               `json:"contextRand"`
+}
+
+// COSE_Sign1 over Payload, with only alg in the protected header. PrivateKey is PKCS#8, PublicKey is PKIX.
+// Only deterministic signatures are compared byte by byte, the rest are verified with PublicKey
+type CoseSign1Vector struct {
+	Id            string                 `json:"id"`
+	SgType        fdoshared.DeviceSgType `json:"sgType"`
+	PrivateKey    HexBytes               `json:"privateKey"`
+	PublicKey     HexBytes               `json:"publicKey"`
+	Payload       HexBytes               `json:"payload"`
+	Deterministic bool                   `json:"deterministic"`
+	Output        HexBytes               `json:"output"`
+}
+
+type Vectors struct {
+	Kdf        []KdfVector        `json:"kdf"`
+	Encryption []EncryptionVector `json:"encryption"`
+	SessionKey []SessionKeyVector `json:"sessionKey"`
+	CoseSign1  []CoseSign1Vector  `json:"coseSign1"`
+}
+
+// Output of the implementation under test for the vector with the same Id. ContextRand is only used by session key vectors
+type Output struct {
+	Id          string   `json:"id"`
+	Output      HexBytes `json:"output"`
+	ContextRand HexBytes `json:"contextRand,omitempty"`
+}
+
+type Outputs struct {
+	Implementation string   `json:"implementation"`
+	Outputs        []Output `json:"outputs"`
+}
+
+func EmbeddedVectorsJson() []byte {
+	return embeddedVectors
+}
+
+// Loads vectors from the file, or the embedded vectors if the path is empty
+func LoadVectors(path string) (*Vectors, error) {
+	vectorsBytes := embeddedVectors
+	if path != "" {
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.New("error reading vectors file. " + err.Error())
+		}
+
+		vectorsBytes = fileBytes
+	}
+
+	var vectors Vectors
+	err := json.Unmarshal(vectorsBytes, &vectors)
+	if err != nil {
+		return nil, errors.New("error decoding vectors. " + err.Error())
+	}
+
+	return &vectors, nil
+}
+
+func LoadOutputs(path string) (*Outputs, error) {
+	outputsBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("error reading outputs file. " + err.Error())
+	}
+
+	var outputs Outputs
+	err = json.Unmarshal(outputsBytes, &outputs)
+	if err != nil {
+		return nil, errors.New("error decoding outputs. " + err.Error())
+	}
+
+	return &outputs, nil
+}
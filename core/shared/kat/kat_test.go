@@ -0,0 +1,92 @@
+package kat
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func test_checkPassed(t *testing.T, vectors Vectors, outputs Outputs) {
+	for _, result := range CheckOutputs(vectors, outputs) {
+		if result.Status != RESULT_PASSED {
+			t.Errorf("%s: expected vector to pass. Got %s %s", result.Id, result.Status, result.Message)
+		}
+	}
+}
+
+// Enables algorithm extensions for the test, and restores the previous state after it
+func test_enableAlgExtensions(t *testing.T) {
+	wasEnabled := fdoshared.AlgExtensionsEnabled()
+	fdoshared.EnableAlgExtensions()
+
+	t.Cleanup(func() {
+		if !wasEnabled {
+			fdoshared.DisableAlgExtensions()
+		}
+	})
+}
+
+func TestKat_Vectors(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	vectors, err := LoadVectors("")
+	if err != nil {
+		t.Fatalf("failed to load vectors: %v", err)
+	}
+
+	if len(vectors.Kdf) == 0 || len(vectors.Encryption) != 2*len(fdoshared.CipherSuiteNames) || len(vectors.SessionKey) != 2*len(fdoshared.KexSuitNames) || len(vectors.CoseSign1) == 0 {
+		t.Fatalf("vectors do not cover every suite")
+	}
+
+	outputs, err := ComputeOutputs(*vectors)
+	if err != nil {
+		t.Fatalf("failed to compute outputs: %v", err)
+	}
+
+	test_checkPassed(t, *vectors, *outputs)
+}
+
+func TestKat_CheckOutputs_Mismatch(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	vectors, err := LoadVectors("")
+	if err != nil {
+		t.Fatalf("failed to load vectors: %v", err)
+	}
+
+	outputs, err := ComputeOutputs(*vectors)
+	if err != nil {
+		t.Fatalf("failed to compute outputs: %v", err)
+	}
+
+	for i := range outputs.Outputs {
+		tampered := append(HexBytes{}, outputs.Outputs[i].Output...)
+		tampered[len(tampered)-1] ^= 0xff
+		outputs.Outputs[i].Output = tampered
+	}
+
+	// Drop the last output, to check the missing one fails as well
+	outputs.Outputs = outputs.Outputs[:len(outputs.Outputs)-1]
+
+	for _, result := range CheckOutputs(*vectors, *outputs) {
+		if result.Status != RESULT_FAILED {
+			t.Errorf("%s: expected tampered output to fail. Got %s", result.Id, result.Status)
+		}
+	}
+}
+
+func TestKat_GenerateVectors(t *testing.T) {
+	test_enableAlgExtensions(t)
+
+	vectors, err := GenerateVectors()
+	if err != nil {
+		t.Fatalf("failed to generate vectors: %v", err)
+	}
+
+	outputs, err := ComputeOutputs(*vectors)
+	if err != nil {
+		t.Fatalf("failed to compute outputs: %v", err)
+	}
+
+	test_checkPassed(t, *vectors, *outputs)
+}
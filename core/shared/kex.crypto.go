@@ -1,12 +1,12 @@
 package fdoshared
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -99,6 +99,22 @@ func (h *DHKexPrivateKey) GetDHKEXPrivateKeyInst() *dhkx.DHKey {
 	return &dhKex
 }
 
+// ASYMKEX encrypts device random to the owner key, so it can only be negotiated with RSA owner keys
+func CheckKexSuiteOwnerKey(kexSuitName KexSuiteName, ownerPkType FdoPkType) error {
+	switch kexSuitName {
+	case KEX_ECDH256, KEX_ECDH384, KEX_DHKEXid14, KEX_DHKEXid15:
+		return nil
+	case KEX_ASYMKEX2048, KEX_ASYMKEX3072:
+		if ownerPkType != RSA2048RESTR && ownerPkType != RSAPKCS && ownerPkType != RSAPSS {
+			return fmt.Errorf("%s requires RSA owner key. Got pkType %d", kexSuitName, ownerPkType)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported kex suite %s", kexSuitName)
+	}
+}
+
 func GenerateXABKeyExchange(kexSuitName KexSuiteName, ownerPubKey *FdoPublicKey) (*KeXParams, error) {
 	switch kexSuitName {
 	case KEX_DHKEXid14:
@@ -138,72 +154,10 @@ func GenerateXABKeyExchange(kexSuitName KexSuiteName, ownerPubKey *FdoPublicKey)
 		return &resultKex, nil
 
 	case KEX_ECDH256:
-		ownerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-		if err != nil {
-			return nil, errors.New("error while generating ECDH key: " + err.Error())
-		}
-
-		ownerRandom := NewRandomBuffer(int(KEX_ECDH256_RANDOM_LEN))
-
-		ownerRandomLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(ownerRandomLenBytes, uint16(len(ownerRandom)))
-		ownerBlock := append(ownerRandomLenBytes, ownerRandom...)
-
-		xBytes := ownerKey.X.Bytes()
-		xLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(xLenBytes, uint16(len(xBytes)))
-		xBlock := append(xLenBytes, xBytes...)
-
-		yBytes := ownerKey.Y.Bytes()
-		yLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(yLenBytes, uint16(len(yBytes)))
-		yBlock := append(yLenBytes, yBytes...)
-
-		publicKeyBlock := append(xBlock, yBlock...)
-
-		xAKeyExchange := append(publicKeyBlock, ownerBlock...)
-
-		resultKex := KeXParams{
-			Private:       ownerKey.D.Bytes(),
-			XAKeyExchange: xAKeyExchange,
-			KexSuit:       kexSuitName,
-		}
-
-		return &resultKex, nil
+		return newEcdhKeyExchange(kexSuitName, elliptic.P256(), KEX_ECDH256_RANDOM_LEN)
 
 	case KEX_ECDH384:
-		ownerKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-		if err != nil {
-			return nil, errors.New("error generating ECDH key: " + err.Error())
-		}
-
-		ownerRandom := NewRandomBuffer(KEX_ECDH384_RANDOM_LEN)
-
-		ownerRandomLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(ownerRandomLenBytes, uint16(len(ownerRandom)))
-		ownerBlock := append(ownerRandomLenBytes, ownerRandom...)
-
-		xBytes := ownerKey.X.Bytes()
-		xLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(xLenBytes, uint16(len(xBytes)))
-		xBlock := append(xLenBytes, xBytes...)
-
-		yBytes := ownerKey.Y.Bytes()
-		yLenBytes := make([]byte, 2)
-		binary.BigEndian.PutUint16(yLenBytes, uint16(len(yBytes)))
-		yBlock := append(yLenBytes, yBytes...)
-
-		publicKeyBlock := append(xBlock, yBlock...)
-
-		xAKeyExchange := append(publicKeyBlock, ownerBlock...)
-
-		resultKex := KeXParams{
-			Private:       ownerKey.D.Bytes(),
-			XAKeyExchange: xAKeyExchange,
-			KexSuit:       kexSuitName,
-		}
-
-		return &resultKex, nil
+		return newEcdhKeyExchange(kexSuitName, elliptic.P384(), KEX_ECDH384_RANDOM_LEN)
 
 	case KEX_ASYMKEX2048, KEX_ASYMKEX3072:
 		var kexLen int
@@ -236,7 +190,71 @@ func GenerateXABKeyExchange(kexSuitName KexSuiteName, ownerPubKey *FdoPublicKey)
 	}
 }
 
-func DeriveSessionKey(kexA KeXParams, xBKeyExchange []byte, isDevice bool, ownerPrivateKey interface{}) (*SessionKeyInfo, error) {
+// Rebuilds key exchange from a known secret: DH exponent or ECDH scalar. Random is ECDH or ASYMKEX random, and is not used by DH.
+// Only meant for known-answer tests
+func NewXABKeyExchangeFromSecret(kexSuitName KexSuiteName, secret []byte, random []byte) (*KeXParams, error) {
+	switch kexSuitName {
+	case KEX_DHKEXid14, KEX_DHKEXid15:
+		group, _ := dhkx.GetGroup(dhkexGroupID(kexSuitName))
+
+		x := new(big.Int).SetBytes(secret)
+		if x.Sign() == 0 {
+			return nil, fmt.Errorf("%s secret exponent is zero", kexSuitName)
+		}
+
+		y := new(big.Int).Exp(group.G(), x, group.P())
+		privKeyStruct := NewDHKexPrivateKey(x.Bytes(), y.Bytes(), dhkexGroupID(kexSuitName))
+
+		return &KeXParams{
+			Private:       privKeyStruct.MarshalCbor(),
+			XAKeyExchange: y.FillBytes(make([]byte, dhkexPublicValueLen(group))),
+			KexSuit:       kexSuitName,
+		}, nil
+
+	case KEX_ECDH256, KEX_ECDH384:
+		curve, randomLen := ecdhKexSuiteParams(kexSuitName)
+		coordLen := (curve.Params().BitSize + 7) / 8
+
+		d := new(big.Int).SetBytes(secret)
+		if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+			return nil, fmt.Errorf("%s secret scalar is out of range", kexSuitName)
+		}
+
+		if len(random) != randomLen {
+			return nil, fmt.Errorf("unexpected %s random length. Expected %d bytes. Got %d", kexSuitName, randomLen, len(random))
+		}
+
+		x, y := curve.ScalarBaseMult(d.Bytes())
+
+		return &KeXParams{
+			Private:       d.Bytes(),
+			XAKeyExchange: encodeEcdhKeyExchange(x.FillBytes(make([]byte, coordLen)), y.FillBytes(make([]byte, coordLen)), random),
+			KexSuit:       kexSuitName,
+		}, nil
+
+	case KEX_ASYMKEX2048, KEX_ASYMKEX3072:
+		if len(random) != asymKexRandomLen(kexSuitName) {
+			return nil, fmt.Errorf("unexpected %s random length. Expected %d bytes. Got %d", kexSuitName, asymKexRandomLen(kexSuitName), len(random))
+		}
+
+		return &KeXParams{
+			Private:       random,
+			XAKeyExchange: random,
+			KexSuit:       kexSuitName,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown KeyExchange algorithm: %s", kexSuitName)
+	}
+}
+
+// ownerPrivateKey is only used by the owner for ASYMKEX, and is nil for the device
+func DeriveSessionKey(kexA KeXParams, xBKeyExchange []byte, isDevice bool, ownerPrivateKey crypto.Decrypter) (*SessionKeyInfo, error) {
+	err := ValidateKeyExchange(kexA.KexSuit, xBKeyExchange, isDevice)
+	if err != nil {
+		return nil, err
+	}
+
 	switch kexA.KexSuit {
 	case KEX_DHKEXid14, KEX_DHKEXid15:
 		privKeyStruct := DHKexPrivateKey{}
@@ -258,81 +276,20 @@ func DeriveSessionKey(kexA KeXParams, xBKeyExchange []byte, isDevice bool, owner
 			ContextRand: []byte{},
 		}, nil
 
-	case KEX_ECDH256:
-		expectedLen := 2 + 32 + 2 + 32 + 2 + KEX_ECDH256_RANDOM_LEN
-		if len(xBKeyExchange) != expectedLen {
-			return nil, fmt.Errorf("unexpected xBKeyExchange for ECDH256 length. Expected %d bytes long", expectedLen)
-		}
-
-		deviceX := xBKeyExchange[2:34]
-		deviceY := xBKeyExchange[36:68]
-		xbRandom := xBKeyExchange[70:86]
+	case KEX_ECDH256, KEX_ECDH384:
+		curve, randomLen := ecdhKexSuiteParams(kexA.KexSuit)
 
-		devicePubKey := ecdsa.PublicKey{
-			Curve: elliptic.P256(),
-			X:     new(big.Int).SetBytes(deviceX),
-			Y:     new(big.Int).SetBytes(deviceY),
-		}
-
-		ownerX := kexA.XAKeyExchange[2:34]
-		ownerY := kexA.XAKeyExchange[36:68]
-		xaRandom := kexA.XAKeyExchange[70:86]
-
-		ownerPrivateKey := &ecdsa.PrivateKey{
-			PublicKey: ecdsa.PublicKey{
-				Curve: elliptic.P256(),
-				X:     new(big.Int).SetBytes(ownerX),
-				Y:     new(big.Int).SetBytes(ownerY),
-			},
-			D: new(big.Int).SetBytes(kexA.Private),
-		}
-
-		Shx, _ := ownerPrivateKey.PublicKey.Curve.ScalarMult(devicePubKey.X, devicePubKey.Y, ownerPrivateKey.D.Bytes())
-
-		var randomSuffix []byte
-		if isDevice {
-			randomSuffix = append(xaRandom, xbRandom...)
-		} else {
-			randomSuffix = append(xbRandom, xaRandom...)
-		}
-
-		shSe := append(Shx.Bytes(), randomSuffix...)
-
-		return &SessionKeyInfo{
-			ShSe:        shSe,
-			ContextRand: []byte{},
-		}, nil
-
-	case KEX_ECDH384:
-		expectedLen := 2 + 48 + 2 + 48 + 2 + KEX_ECDH384_RANDOM_LEN
-		if len(xBKeyExchange) != expectedLen {
-			return nil, fmt.Errorf("unexpected xBKeyExchange for ECDH384 length. Expected %d bytes long", expectedLen)
-		}
-
-		deviceX := xBKeyExchange[2:50]
-		deviceY := xBKeyExchange[52:100]
-		xbRandom := xBKeyExchange[102:150]
-
-		devicePubKey := ecdsa.PublicKey{
-			Curve: elliptic.P384(),
-			X:     new(big.Int).SetBytes(deviceX),
-			Y:     new(big.Int).SetBytes(deviceY),
+		peerX, peerY, xbRandom, err := parseEcdhKeyExchange(xBKeyExchange, curve, randomLen)
+		if err != nil {
+			return nil, errors.New("error decoding xBKeyExchange. " + err.Error())
 		}
 
-		ownerX := kexA.XAKeyExchange[2:50]
-		ownerY := kexA.XAKeyExchange[52:100]
-		xaRandom := kexA.XAKeyExchange[102:150]
-
-		ownerPrivateKey := &ecdsa.PrivateKey{
-			PublicKey: ecdsa.PublicKey{
-				Curve: elliptic.P384(),
-				X:     new(big.Int).SetBytes(ownerX),
-				Y:     new(big.Int).SetBytes(ownerY),
-			},
-			D: new(big.Int).SetBytes(kexA.Private),
+		_, _, xaRandom, err := parseEcdhKeyExchange(kexA.XAKeyExchange, curve, randomLen)
+		if err != nil {
+			return nil, errors.New("error decoding own xAKeyExchange. " + err.Error())
 		}
 
-		Shx, _ := ownerPrivateKey.PublicKey.Curve.ScalarMult(devicePubKey.X, devicePubKey.Y, ownerPrivateKey.D.Bytes())
+		Shx, _ := curve.ScalarMult(peerX, peerY, kexA.Private)
 
 		var randomSuffix []byte
 		if isDevice {
@@ -360,6 +317,10 @@ func DeriveSessionKey(kexA KeXParams, xBKeyExchange []byte, isDevice bool, owner
 				return nil, fmt.Errorf("error unwrapping OAEP for ASYM KEX. %s", err.Error())
 			}
 
+			if len(deviceRandom) != asymKexRandomLen(kexA.KexSuit) {
+				return nil, fmt.Errorf("unexpected device random length for %s. Expected %d bytes. Got %d", kexA.KexSuit, asymKexRandomLen(kexA.KexSuit), len(deviceRandom))
+			}
+
 			return &SessionKeyInfo{
 				ShSe:        deviceRandom,
 				ContextRand: kexA.Private,
@@ -370,19 +331,157 @@ func DeriveSessionKey(kexA KeXParams, xBKeyExchange []byte, isDevice bool, owner
 	}
 }
 
-func WrapOAEP(message []byte, ownerPubKey FdoPublicKey) ([]byte, error) {
-	publicKeyCasted, ok := ownerPubKey.PkBody.([]byte)
-	if !ok {
-		return nil, errors.New("failed to cast pubkey PkBody to []byte")
+// Checks key exchange received from the peer. fromOwner is true when the device checks owner's xAKeyExchange.
+// Owner's ASYMKEX check is done after the device random is unwrapped
+func ValidateKeyExchange(kexSuitName KexSuiteName, xKeyExchange []byte, fromOwner bool) error {
+	switch kexSuitName {
+	case KEX_DHKEXid14, KEX_DHKEXid15:
+		group, _ := dhkx.GetGroup(dhkexGroupID(kexSuitName))
+
+		if len(xKeyExchange) != dhkexPublicValueLen(group) {
+			return fmt.Errorf("unexpected %s public value length. Expected %d bytes. Got %d", kexSuitName, dhkexPublicValueLen(group), len(xKeyExchange))
+		}
+
+		// 0, 1 and p-1 force the shared secret into a trivial subgroup
+		publicValue := new(big.Int).SetBytes(xKeyExchange)
+		maxValue := new(big.Int).Sub(group.P(), big.NewInt(2))
+		if publicValue.Cmp(big.NewInt(2)) < 0 || publicValue.Cmp(maxValue) > 0 {
+			return fmt.Errorf("%s public value is out of [2, p-2] range", kexSuitName)
+		}
+
+		return nil
+
+	case KEX_ECDH256, KEX_ECDH384:
+		curve, randomLen := ecdhKexSuiteParams(kexSuitName)
+
+		x, y, _, err := parseEcdhKeyExchange(xKeyExchange, curve, randomLen)
+		if err != nil {
+			return err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return fmt.Errorf("%s public key is not on the curve", kexSuitName)
+		}
+
+		return nil
+
+	case KEX_ASYMKEX2048, KEX_ASYMKEX3072:
+		if fromOwner && len(xKeyExchange) != asymKexRandomLen(kexSuitName) {
+			return fmt.Errorf("unexpected %s owner random length. Expected %d bytes. Got %d", kexSuitName, asymKexRandomLen(kexSuitName), len(xKeyExchange))
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unknown KeyExchange algorithm: %s", kexSuitName)
+	}
+}
+
+func dhkexGroupID(kexSuitName KexSuiteName) dhkx.GroupID {
+	if kexSuitName == KEX_DHKEXid14 {
+		return dhkx.DHKX_ID14
+	}
+
+	return dhkx.DHKX_ID15
+}
+
+// Public value is encoded with the modulus length, so the suites can be told apart by length
+func dhkexPublicValueLen(group *dhkx.DHGroup) int {
+	return (group.P().BitLen() + 7) / 8
+}
+
+func asymKexRandomLen(kexSuitName KexSuiteName) int {
+	if kexSuitName == KEX_ASYMKEX2048 {
+		return KEX_ASYMKEX2048_RANDOM_LEN
+	}
+
+	return KEX_ASYMKEX3072_RANDOM_LEN
+}
+
+func ecdhKexSuiteParams(kexSuitName KexSuiteName) (elliptic.Curve, int) {
+	if kexSuitName == KEX_ECDH256 {
+		return elliptic.P256(), KEX_ECDH256_RANDOM_LEN
+	}
+
+	return elliptic.P384(), KEX_ECDH384_RANDOM_LEN
+}
+
+func newEcdhKeyExchange(kexSuitName KexSuiteName, curve elliptic.Curve, randomLen int) (*KeXParams, error) {
+	ownerKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, errors.New("error generating ECDH key: " + err.Error())
+	}
+
+	coordLen := (curve.Params().BitSize + 7) / 8
+
+	return &KeXParams{
+		Private:       ownerKey.D.Bytes(),
+		XAKeyExchange: encodeEcdhKeyExchange(ownerKey.X.FillBytes(make([]byte, coordLen)), ownerKey.Y.FillBytes(make([]byte, coordLen)), NewRandomBuffer(randomLen)),
+		KexSuit:       kexSuitName,
+	}, nil
+}
+
+// Blen[2]||Bx||Blen[2]||By||Blen[2]||random
+func encodeEcdhKeyExchange(x []byte, y []byte, random []byte) []byte {
+	var result []byte
+	for _, block := range [][]byte{x, y, random} {
+		blockLen := make([]byte, 2)
+		binary.BigEndian.PutUint16(blockLen, uint16(len(block)))
+
+		result = append(result, blockLen...)
+		result = append(result, block...)
+	}
+
+	return result
+}
+
+func parseEcdhKeyExchange(xKeyExchange []byte, curve elliptic.Curve, randomLen int) (*big.Int, *big.Int, []byte, error) {
+	coordLen := (curve.Params().BitSize + 7) / 8
+
+	var blocks [][]byte
+	rest := xKeyExchange
+	for i := 0; i < 3; i++ {
+		if len(rest) < 2 {
+			return nil, nil, nil, errors.New("ECDH key exchange is truncated")
+		}
+
+		blockLen := int(binary.BigEndian.Uint16(rest[0:2]))
+		if len(rest) < 2+blockLen {
+			return nil, nil, nil, errors.New("ECDH key exchange is truncated")
+		}
+
+		blocks = append(blocks, rest[2:2+blockLen])
+		rest = rest[2+blockLen:]
 	}
 
-	pubKeyInst, err := x509.ParsePKIXPublicKey(publicKeyCasted)
+	if len(rest) != 0 {
+		return nil, nil, nil, errors.New("ECDH key exchange has trailing bytes")
+	}
+
+	if len(blocks[0]) > coordLen || len(blocks[1]) > coordLen {
+		return nil, nil, nil, fmt.Errorf("ECDH public key coordinates are longer than %d bytes", coordLen)
+	}
+
+	if len(blocks[2]) != randomLen {
+		return nil, nil, nil, fmt.Errorf("unexpected ECDH random length. Expected %d bytes. Got %d", randomLen, len(blocks[2]))
+	}
+
+	return new(big.Int).SetBytes(blocks[0]), new(big.Int).SetBytes(blocks[1]), blocks[2], nil
+}
+
+func WrapOAEP(message []byte, ownerPubKey FdoPublicKey) ([]byte, error) {
+	pubKeyInst, err := ExtractPublicKey(ownerPubKey)
 	if err != nil {
-		return nil, errors.New("error parsing PKIX X509 Public Key. " + err.Error())
+		return nil, err
+	}
+
+	rsaPubKey, ok := pubKeyInst.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("error wrapping OAEP. Owner public key is not RSA")
 	}
 
 	hash := sha256.New()
-	ciphertext, err := rsa.EncryptOAEP(hash, rand.Reader, pubKeyInst.(*rsa.PublicKey), message, []byte{})
+	ciphertext, err := rsa.EncryptOAEP(hash, rand.Reader, rsaPubKey, message, []byte{})
 	if err != nil {
 		return nil, errors.New("error wrapping OAEP. " + err.Error())
 	}
@@ -390,9 +489,15 @@ func WrapOAEP(message []byte, ownerPubKey FdoPublicKey) ([]byte, error) {
 	return ciphertext, nil
 }
 
-func UnwrapOAEP(ciphertext []byte, privateKeyInterface interface{}) ([]byte, error) {
-	hash := sha256.New()
-	message, err := rsa.DecryptOAEP(hash, rand.Reader, privateKeyInterface.(*rsa.PrivateKey), ciphertext, []byte{})
+func UnwrapOAEP(ciphertext []byte, decrypter crypto.Decrypter) ([]byte, error) {
+	if decrypter == nil {
+		return nil, errors.New("error unwrapping OAEP. Owner private key is not an RSA decryption key")
+	}
+
+	message, err := decrypter.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{
+		Hash:  crypto.SHA256,
+		Label: []byte{},
+	})
 	if err != nil {
 		return nil, errors.New("error unwrapping OAEP. " + err.Error())
 	}
@@ -0,0 +1,103 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func test_generateKexOwnerKey(t *testing.T, kexSuitName KexSuiteName) (crypto.Decrypter, *FdoPublicKey) {
+	sgType := StRSA2048
+	if kexSuitName == KEX_ASYMKEX3072 {
+		sgType = StRSA3072
+	}
+
+	privateKey, publicKey, err := GenerateVoucherKeypair(sgType)
+	if err != nil {
+		t.Fatalf("Error generating owner key: %v", err)
+	}
+
+	decrypter, _ := privateKey.(crypto.Decrypter)
+	return decrypter, publicKey
+}
+
+func TestDeriveSessionKey(t *testing.T) {
+	for _, kexSuitName := range KexSuitNames {
+		ownerDecrypter, ownerPublicKey := test_generateKexOwnerKey(t, kexSuitName)
+
+		ownerKex, err := GenerateXABKeyExchange(kexSuitName, nil)
+		if err != nil {
+			t.Fatalf("%s: Error generating xAKeyExchange: %v", kexSuitName, err)
+		}
+
+		deviceKex, err := GenerateXABKeyExchange(kexSuitName, ownerPublicKey)
+		if err != nil {
+			t.Fatalf("%s: Error generating xBKeyExchange: %v", kexSuitName, err)
+		}
+
+		deviceSessionKey, err := DeriveSessionKey(*deviceKex, ownerKex.XAKeyExchange, true, nil)
+		if err != nil {
+			t.Fatalf("%s: Error deriving device session key: %v", kexSuitName, err)
+		}
+
+		ownerSessionKey, err := DeriveSessionKey(*ownerKex, deviceKex.XAKeyExchange, false, ownerDecrypter)
+		if err != nil {
+			t.Fatalf("%s: Error deriving owner session key: %v", kexSuitName, err)
+		}
+
+		if !bytes.Equal(deviceSessionKey.ShSe, ownerSessionKey.ShSe) || !bytes.Equal(deviceSessionKey.ContextRand, ownerSessionKey.ContextRand) {
+			t.Errorf("%s: Device and owner session keys do not match", kexSuitName)
+		}
+	}
+}
+
+func TestValidateKeyExchange_ConfNegative(t *testing.T) {
+	rnd := NewConf_Rand(1)
+
+	for _, kexSuitName := range KexSuitNames {
+		negativeTests := map[string]func() ([]byte, error){
+			"OtherSuite":  func() ([]byte, error) { return Conf_NewXAKeyExchange_OtherSuite(rnd, kexSuitName) },
+			"OffCurve":    func() ([]byte, error) { return Conf_NewXAKeyExchange_OffCurve(rnd, kexSuitName) },
+			"WeakDH":      func() ([]byte, error) { return Conf_NewXAKeyExchange_WeakDH(rnd, kexSuitName) },
+			"ShortRandom": func() ([]byte, error) { return Conf_NewXAKeyExchange_ShortRandom(rnd, kexSuitName) },
+		}
+
+		for testName, newXAKeyExchange := range negativeTests {
+			// Weak DH picks one of three values, and other suite one of five
+			for i := 0; i < 10; i++ {
+				xAKeyExchange, err := newXAKeyExchange()
+				if err != nil {
+					t.Fatalf("%s/%s: Error generating xAKeyExchange: %v", kexSuitName, testName, err)
+				}
+
+				err = ValidateKeyExchange(kexSuitName, xAKeyExchange, true)
+				if err == nil {
+					t.Errorf("%s/%s: Expected xAKeyExchange to be rejected", kexSuitName, testName)
+				}
+			}
+		}
+	}
+}
+
+func TestDeriveSessionKey_BadOAEP(t *testing.T) {
+	rnd := NewConf_Rand(1)
+
+	for _, kexSuitName := range []KexSuiteName{KEX_ASYMKEX2048, KEX_ASYMKEX3072} {
+		ownerDecrypter, ownerPublicKey := test_generateKexOwnerKey(t, kexSuitName)
+
+		ownerKex, err := GenerateXABKeyExchange(kexSuitName, nil)
+		if err != nil {
+			t.Fatalf("%s: Error generating xAKeyExchange: %v", kexSuitName, err)
+		}
+
+		xBKeyExchange, err := Conf_NewXBKeyExchange_BadOAEP(rnd, *ownerPublicKey)
+		if err != nil {
+			t.Fatalf("%s: Error generating xBKeyExchange: %v", kexSuitName, err)
+		}
+
+		_, err = DeriveSessionKey(*ownerKex, xBKeyExchange, false, ownerDecrypter)
+		if err == nil {
+			t.Errorf("%s: Expected bad OAEP xBKeyExchange to be rejected", kexSuitName)
+		}
+	}
+}
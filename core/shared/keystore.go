@@ -0,0 +1,93 @@
+package fdoshared
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+)
+
+const PKCS11_KEY_REF_PREFIX string = "pkcs11:"
+
+// Owner private keys are resolved through the key store. Vouchers and sessions store the key reference,
+// which for software keys is the PKCS#8 DER, and for the token keys is the PKCS#11 URI
+type KeyStore interface {
+	// Generates new owner key for the sgType. Returns the key reference
+	GenerateKey(sgType DeviceSgType) ([]byte, crypto.Signer, error)
+	// Resolves key reference to the signer. RSA signers also implement crypto.Decrypter
+	GetSigner(keyRef []byte) (crypto.Signer, error)
+	// Checks that the reference was issued by the store
+	IsKeyRef(keyRef []byte) bool
+}
+
+// Software key store. Keys are stored as DER with the voucher, in the Badger DB or in the voucher PEM files
+type SoftwareKeyStore struct{}
+
+func (h SoftwareKeyStore) GenerateKey(sgType DeviceSgType) ([]byte, crypto.Signer, error) {
+	privateKeyInst, _, err := GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyDer, err := MarshalPrivateKey(privateKeyInst, sgType)
+	if err != nil {
+		return nil, nil, errors.New("error marshaling private key. " + err.Error())
+	}
+
+	return privateKeyDer, privateKeyInst.(crypto.Signer), nil
+}
+
+func (h SoftwareKeyStore) GetSigner(keyRef []byte) (crypto.Signer, error) {
+	privateKeyInst, err := ExtractPrivateKey(keyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKeyInst.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key is not a signing key")
+	}
+
+	return signer, nil
+}
+
+func (h SoftwareKeyStore) IsKeyRef(keyRef []byte) bool {
+	return !IsPKCS11KeyRef(keyRef)
+}
+
+func IsPKCS11KeyRef(keyRef []byte) bool {
+	return bytes.HasPrefix(keyRef, []byte(PKCS11_KEY_REF_PREFIX))
+}
+
+// PEM type for the key reference in the voucher files
+func GetKeyRefPemType(keyRef []byte) string {
+	if IsPKCS11KeyRef(keyRef) {
+		return PKCS11_KEY_REF_PEM_TYPE
+	}
+
+	return PRIVATE_KEY_PEM_TYPE
+}
+
+var keyStore KeyStore = SoftwareKeyStore{}
+
+// Sets the store for the new owner keys, e.g. PKCS#11 token
+func SetKeyStore(newKeyStore KeyStore) {
+	keyStore = newKeyStore
+}
+
+func GetKeyStore() KeyStore {
+	return keyStore
+}
+
+// Resolves owner key reference. Software keys are always accepted, so the vouchers generated before the store change keep working
+func LoadOwnerKey(keyRef []byte) (crypto.Signer, error) {
+	if keyStore.IsKeyRef(keyRef) {
+		return keyStore.GetSigner(keyRef)
+	}
+
+	if IsPKCS11KeyRef(keyRef) {
+		return nil, fmt.Errorf("owner key %s is in the PKCS#11 token, but PKCS#11 key store is not configured", keyRef)
+	}
+
+	return SoftwareKeyStore{}.GetSigner(keyRef)
+}
@@ -0,0 +1,10 @@
+//go:build !pkcs11
+
+package fdoshared
+
+import "errors"
+
+// PKCS#11 support requires cgo, and so is only included with the pkcs11 build tag
+func NewPKCS11KeyStore(modulePath string, tokenLabel string, pin string) (KeyStore, error) {
+	return nil, errors.New("PKCS#11 key store is not supported by this build. Rebuild with -tags pkcs11")
+}
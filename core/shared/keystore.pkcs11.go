@@ -0,0 +1,503 @@
+//go:build pkcs11
+
+package fdoshared
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+	oidEd25519        = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// PKCS#11 v3.0 Edwards curve constants. Not defined by the pkcs11 package
+const (
+	pkcs11_CKK_EC_EDWARDS              uint = 0x00000040
+	pkcs11_CKM_EC_EDWARDS_KEY_PAIR_GEN uint = 0x00001055
+	pkcs11_CKM_EDDSA                   uint = 0x00001057
+)
+
+// PKCS#1 v1.5 DigestInfo prefixes, RFC 8017 Section 9.2
+var pkcs1DigestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+var pkcs11HashMechanisms = map[crypto.Hash][2]uint{
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// Owner keys in the PKCS#11 token. Keys are generated as non extractable, and referenced by CKA_ID as pkcs11:id=%01%02...
+// Single session is shared by all operations, so they are serialised
+type PKCS11KeyStore struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	mu      sync.Mutex
+}
+
+func NewPKCS11KeyStore(modulePath string, tokenLabel string, pin string) (KeyStore, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("error loading PKCS#11 module %s", modulePath)
+	}
+
+	err := ctx.Initialize()
+	if err != nil {
+		return nil, errors.New("error initializing PKCS#11 module. " + err.Error())
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, errors.New("error listing PKCS#11 slots. " + err.Error())
+	}
+
+	for _, slot := range slots {
+		tokenInfo, err := ctx.GetTokenInfo(slot)
+		if err != nil || strings.TrimSpace(tokenInfo.Label) != tokenLabel {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return nil, errors.New("error opening PKCS#11 session. " + err.Error())
+		}
+
+		err = ctx.Login(session, pkcs11.CKU_USER, pin)
+		if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			ctx.CloseSession(session)
+			return nil, errors.New("error logging in to PKCS#11 token. " + err.Error())
+		}
+
+		return &PKCS11KeyStore{
+			ctx:     ctx,
+			session: session,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("PKCS#11 token %s not found", tokenLabel)
+}
+
+func (h *PKCS11KeyStore) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.ctx.Logout(h.session)
+	h.ctx.CloseSession(h.session)
+	err := h.ctx.Finalize()
+	h.ctx.Destroy()
+
+	return err
+}
+
+func (h *PKCS11KeyStore) IsKeyRef(keyRef []byte) bool {
+	return IsPKCS11KeyRef(keyRef)
+}
+
+func (h *PKCS11KeyStore) GenerateKey(sgType DeviceSgType) ([]byte, crypto.Signer, error) {
+	keyId := NewRandomBuffer(16)
+	keyLabel := fmt.Sprintf("fdo-owner-%x", keyId)
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+	}
+
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+	}
+
+	var mechanism uint
+	switch sgType {
+	case StSECP256R1, StSECP384R1, StSECP521R1:
+		namedCurve := oidNamedCurveP256
+		if sgType == StSECP384R1 {
+			namedCurve = oidNamedCurveP384
+		} else if sgType == StSECP521R1 {
+			namedCurve = oidNamedCurveP521
+		}
+
+		ecParams, _ := asn1.Marshal(namedCurve)
+
+		mechanism = pkcs11.CKM_EC_KEY_PAIR_GEN
+		publicTemplate = append(publicTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams),
+		)
+		privateTemplate = append(privateTemplate, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC))
+	case StED25519:
+		ecParams, _ := asn1.Marshal(oidEd25519)
+
+		mechanism = pkcs11_CKM_EC_EDWARDS_KEY_PAIR_GEN
+		publicTemplate = append(publicTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11_CKK_EC_EDWARDS),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams),
+		)
+		privateTemplate = append(privateTemplate, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11_CKK_EC_EDWARDS))
+	case StRSA2048, StRSA3072, StRSAPSS2048, StRSAPSS3072:
+		rsaKeySize := 2048
+		if sgType == StRSA3072 || sgType == StRSAPSS3072 {
+			rsaKeySize = 3072
+		}
+
+		mechanism = pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN
+		publicTemplate = append(publicTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, rsaKeySize),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{0x01, 0x00, 0x01}),
+		)
+		privateTemplate = append(privateTemplate,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		)
+	default:
+		return nil, nil, fmt.Errorf("%d is an unsupported SgType for the PKCS#11 key store", sgType)
+	}
+
+	h.mu.Lock()
+	_, _, err := h.ctx.GenerateKeyPair(h.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, publicTemplate, privateTemplate)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, nil, errors.New("error generating PKCS#11 keypair. " + err.Error())
+	}
+
+	keyRef := newPKCS11KeyRef(keyId)
+	signer, err := h.GetSigner(keyRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyRef, signer, nil
+}
+
+func (h *PKCS11KeyStore) GetSigner(keyRef []byte) (crypto.Signer, error) {
+	keyId, err := parsePKCS11KeyRef(keyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	privateKeyHandle, err := h.findObject(pkcs11.CKO_PRIVATE_KEY, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyHandle, err := h.findObject(pkcs11.CKO_PUBLIC_KEY, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyInst, err := h.readPublicKey(publicKeyHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkcs11Signer{
+		store:      h,
+		privateKey: privateKeyHandle,
+		publicKey:  publicKeyInst,
+	}, nil
+}
+
+func (h *PKCS11KeyStore) findObject(class uint, keyId []byte) (pkcs11.ObjectHandle, error) {
+	err := h.ctx.FindObjectsInit(h.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+	})
+	if err != nil {
+		return 0, errors.New("error searching PKCS#11 token. " + err.Error())
+	}
+	defer h.ctx.FindObjectsFinal(h.session)
+
+	handles, _, err := h.ctx.FindObjects(h.session, 1)
+	if err != nil {
+		return 0, errors.New("error searching PKCS#11 token. " + err.Error())
+	}
+
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("PKCS#11 key %x not found", keyId)
+	}
+
+	return handles[0], nil
+}
+
+func (h *PKCS11KeyStore) readPublicKey(publicKeyHandle pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := h.ctx.GetAttributeValue(h.session, publicKeyHandle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, errors.New("error reading PKCS#11 key type. " + err.Error())
+	}
+
+	// CK_ULONG is in the host byte order, so compared with the encoded constants
+	keyType := attrs[0].Value
+
+	switch {
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC).Value):
+		attrs, err := h.ctx.GetAttributeValue(h.session, publicKeyHandle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, errors.New("error reading PKCS#11 EC public key. " + err.Error())
+		}
+
+		return decodePKCS11ECPublicKey(attrs[0].Value, attrs[1].Value)
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11_CKK_EC_EDWARDS).Value):
+		attrs, err := h.ctx.GetAttributeValue(h.session, publicKeyHandle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, errors.New("error reading PKCS#11 EdDSA public key. " + err.Error())
+		}
+
+		return decodePKCS11Ed25519PublicKey(attrs[0].Value)
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA).Value):
+		attrs, err := h.ctx.GetAttributeValue(h.session, publicKeyHandle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, errors.New("error reading PKCS#11 RSA public key. " + err.Error())
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 key type %x", keyType)
+	}
+}
+
+func decodePKCS11ECPublicKey(ecParams []byte, ecPoint []byte) (*ecdsa.PublicKey, error) {
+	var namedCurve asn1.ObjectIdentifier
+	_, err := asn1.Unmarshal(ecParams, &namedCurve)
+	if err != nil {
+		return nil, errors.New("error decoding PKCS#11 EC params. " + err.Error())
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case namedCurve.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case namedCurve.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	case namedCurve.Equal(oidNamedCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 EC curve %s", namedCurve)
+	}
+
+	// CKA_EC_POINT is DER OCTET STRING, however some tokens return the raw point
+	var rawPoint []byte
+	if _, err := asn1.Unmarshal(ecPoint, &rawPoint); err != nil {
+		rawPoint = ecPoint
+	}
+
+	coordLen := (curve.Params().BitSize + 7) / 8
+	if len(rawPoint) != 1+2*coordLen || rawPoint[0] != 0x04 {
+		return nil, errors.New("error decoding PKCS#11 EC point. Expected uncompressed point")
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(rawPoint[1 : 1+coordLen]),
+		Y:     new(big.Int).SetBytes(rawPoint[1+coordLen:]),
+	}
+
+	if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, errors.New("error decoding PKCS#11 EC point. Point is not on the curve")
+	}
+
+	return publicKey, nil
+}
+
+// Only Ed25519 is supported. CKA_EC_POINT is DER OCTET STRING, or the raw key on some tokens
+func decodePKCS11Ed25519PublicKey(ecPoint []byte) (ed25519.PublicKey, error) {
+	var rawPoint []byte
+	if _, err := asn1.Unmarshal(ecPoint, &rawPoint); err != nil {
+		rawPoint = ecPoint
+	}
+
+	if len(rawPoint) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("error decoding PKCS#11 EdDSA public key. Expected %d bytes Ed25519 key", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(rawPoint), nil
+}
+
+func newPKCS11KeyRef(keyId []byte) []byte {
+	var encodedId strings.Builder
+	for _, b := range keyId {
+		encodedId.WriteString(fmt.Sprintf("%%%02x", b))
+	}
+
+	return []byte(PKCS11_KEY_REF_PREFIX + "id=" + encodedId.String())
+}
+
+// Parses RFC 7512 URI. Only the id attribute is used
+func parsePKCS11KeyRef(keyRef []byte) ([]byte, error) {
+	if !IsPKCS11KeyRef(keyRef) {
+		return nil, errors.New("not a PKCS#11 key reference")
+	}
+
+	for _, attr := range strings.Split(strings.TrimPrefix(string(keyRef), PKCS11_KEY_REF_PREFIX), ";") {
+		if !strings.HasPrefix(attr, "id=") {
+			continue
+		}
+
+		keyId, err := url.PathUnescape(strings.TrimPrefix(attr, "id="))
+		if err != nil {
+			return nil, errors.New("error decoding PKCS#11 key id. " + err.Error())
+		}
+
+		return []byte(keyId), nil
+	}
+
+	return nil, fmt.Errorf("PKCS#11 key reference %s has no id", keyRef)
+}
+
+type pkcs11Signer struct {
+	store      *PKCS11KeyStore
+	privateKey pkcs11.ObjectHandle
+	publicKey  crypto.PublicKey
+}
+
+func (h *pkcs11Signer) Public() crypto.PublicKey {
+	return h.publicKey
+}
+
+func (h *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch h.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		rawSignature, err := h.store.sign(pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), h.privateKey, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		// crypto.Signer returns ASN.1 ECDSA signature
+		coeffLength := len(rawSignature) / 2
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(rawSignature[:coeffLength]),
+			S: new(big.Int).SetBytes(rawSignature[coeffLength:]),
+		})
+	case *rsa.PublicKey:
+		hashMechanisms, ok := pkcs11HashMechanisms[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported PKCS#11 signature hash %s", opts.HashFunc())
+		}
+
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			saltLength := pssOpts.SaltLength
+			if saltLength == rsa.PSSSaltLengthEqualsHash || saltLength == rsa.PSSSaltLengthAuto {
+				saltLength = opts.HashFunc().Size()
+			}
+
+			pssParams := pkcs11.NewPSSParams(hashMechanisms[0], hashMechanisms[1], uint(saltLength))
+			return h.store.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pssParams), h.privateKey, digest)
+		}
+
+		return h.store.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil), h.privateKey, append(append([]byte{}, pkcs1DigestInfoPrefix[opts.HashFunc()]...), digest...))
+	case ed25519.PublicKey:
+		// Same as ed25519.PrivateKey, pure Ed25519 signs the message itself
+		if opts.HashFunc() != crypto.Hash(0) {
+			return nil, errors.New("PKCS#11 Ed25519 signature must not be prehashed")
+		}
+
+		return h.store.sign(pkcs11.NewMechanism(pkcs11_CKM_EDDSA, nil), h.privateKey, digest)
+	default:
+		return nil, errors.New("unsupported PKCS#11 key type")
+	}
+}
+
+// Only RSA OAEP is supported, as used by ASYMKEX
+func (h *pkcs11Signer) Decrypt(_ io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if _, ok := h.publicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("PKCS#11 decryption requires RSA key")
+	}
+
+	oaepOpts, ok := opts.(*rsa.OAEPOptions)
+	if !ok {
+		return nil, errors.New("PKCS#11 decryption requires OAEP options")
+	}
+
+	hashMechanisms, ok := pkcs11HashMechanisms[oaepOpts.Hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported PKCS#11 OAEP hash %s", oaepOpts.Hash)
+	}
+
+	var sourceData []byte
+	if len(oaepOpts.Label) != 0 {
+		sourceData = oaepOpts.Label
+	}
+
+	oaepParams := pkcs11.NewOAEPParams(hashMechanisms[0], hashMechanisms[1], pkcs11.CKZ_DATA_SPECIFIED, sourceData)
+
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	err := h.store.ctx.DecryptInit(h.store.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_OAEP, oaepParams)}, h.privateKey)
+	if err != nil {
+		return nil, errors.New("error initializing PKCS#11 decryption. " + err.Error())
+	}
+
+	message, err := h.store.ctx.Decrypt(h.store.session, ciphertext)
+	if err != nil {
+		return nil, errors.New("error decrypting with PKCS#11 key. " + err.Error())
+	}
+
+	return message, nil
+}
+
+func (h *PKCS11KeyStore) sign(mechanism *pkcs11.Mechanism, privateKeyHandle pkcs11.ObjectHandle, data []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.ctx.SignInit(h.session, []*pkcs11.Mechanism{mechanism}, privateKeyHandle)
+	if err != nil {
+		return nil, errors.New("error initializing PKCS#11 signature. " + err.Error())
+	}
+
+	signature, err := h.ctx.Sign(h.session, data)
+	if err != nil {
+		return nil, errors.New("error signing with PKCS#11 key. " + err.Error())
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,85 @@
+//go:build pkcs11
+
+package fdoshared
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+)
+
+// Runs against SoftHSM, or any other token:
+//
+//	softhsm2-util --init-token --free --label fdo-test --pin 1234 --so-pin 1234
+//	PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_TOKEN_LABEL=fdo-test PKCS11_PIN=1234 go test -tags pkcs11 ./core/shared -run PKCS11
+func newTestPKCS11KeyStore(t *testing.T) *PKCS11KeyStore {
+	modulePath := os.Getenv(string(CFG_ENV_PKCS11_MODULE))
+	if modulePath == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+
+	testKeyStore, err := NewPKCS11KeyStore(modulePath, os.Getenv(string(CFG_ENV_PKCS11_TOKEN_LABEL)), os.Getenv(string(CFG_ENV_PKCS11_PIN)))
+	if err != nil {
+		t.Fatalf("failed to open PKCS#11 key store: %v", err)
+	}
+
+	pkcs11KeyStore := testKeyStore.(*PKCS11KeyStore)
+	t.Cleanup(func() { pkcs11KeyStore.Close() })
+
+	return pkcs11KeyStore
+}
+
+func TestPKCS11KeyStore_Signer(t *testing.T) {
+	pkcs11KeyStore := newTestPKCS11KeyStore(t)
+	test_enableAlgExtensions(t)
+	payload := []byte("test payload")
+
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1, StSECP521R1, StED25519, StRSA2048, StRSA3072, StRSAPSS2048, StRSAPSS3072} {
+		keyRef, _, err := pkcs11KeyStore.GenerateKey(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate key: %v", sgType, err)
+		}
+
+		signer, err := pkcs11KeyStore.GetSigner(keyRef)
+		if err != nil {
+			t.Fatalf("%d: failed to load key: %v", sgType, err)
+		}
+
+		pubKey, err := NewFdoPublicKey(signer.Public(), sgType, COSEKEY)
+		if err != nil {
+			t.Fatalf("%d: failed to encode public key: %v", sgType, err)
+		}
+
+		coseSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, signer, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate COSE signature: %v", sgType, err)
+		}
+
+		err = VerifyCoseSignature(*coseSig, *pubKey)
+		if err != nil {
+			t.Fatalf("%d: failed to verify COSE signature: %v", sgType, err)
+		}
+	}
+}
+
+func TestPKCS11KeyStore_UnwrapOAEP(t *testing.T) {
+	pkcs11KeyStore := newTestPKCS11KeyStore(t)
+
+	_, signer, err := pkcs11KeyStore.GenerateKey(StRSA2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	message := []byte("device random")
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, signer.Public().(*rsa.PublicKey), message, []byte{})
+	if err != nil {
+		t.Fatalf("failed to wrap: %v", err)
+	}
+
+	unwrapped, err := UnwrapOAEP(ciphertext, signer.(*pkcs11Signer))
+	if err != nil || string(unwrapped) != string(message) {
+		t.Fatalf("failed to unwrap OAEP: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package fdoshared
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+// Signer without access to the key material, as the PKCS#11 signer
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (h opaqueSigner) Public() crypto.PublicKey {
+	return h.signer.Public()
+}
+
+func (h opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return h.signer.Sign(rand, digest, opts)
+}
+
+func (h opaqueSigner) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return h.signer.(crypto.Decrypter).Decrypt(rand, ciphertext, opts)
+}
+
+func TestSoftwareKeyStore_Signer(t *testing.T) {
+	payload := []byte("test payload")
+
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1, StRSA2048, StRSA3072, StRSAPSS2048, StRSAPSS3072} {
+		keyRef, _, err := SoftwareKeyStore{}.GenerateKey(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate key: %v", sgType, err)
+		}
+
+		signer, err := LoadOwnerKey(keyRef)
+		if err != nil {
+			t.Fatalf("%d: failed to load key: %v", sgType, err)
+		}
+
+		pubKey, err := NewFdoPublicKey(signer.Public(), sgType, X509)
+		if err != nil {
+			t.Fatalf("%d: failed to encode public key: %v", sgType, err)
+		}
+
+		privKeySgType, err := GetPrivateKeySgType(pubKey.PkType, opaqueSigner{signer})
+		if err != nil || privKeySgType != sgType {
+			t.Fatalf("%d: expected private key sgType %d, got %d. %v", sgType, sgType, privKeySgType, err)
+		}
+
+		coseSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, opaqueSigner{signer}, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate COSE signature: %v", sgType, err)
+		}
+
+		err = VerifyCoseSignature(*coseSig, *pubKey)
+		if err != nil {
+			t.Fatalf("%d: failed to verify COSE signature: %v", sgType, err)
+		}
+	}
+}
+
+func TestUnwrapOAEP_Decrypter(t *testing.T) {
+	keyRef, _, err := SoftwareKeyStore{}.GenerateKey(StRSA2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	signer, err := LoadOwnerKey(keyRef)
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+
+	message := []byte("device random")
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, signer.Public().(*rsa.PublicKey), message, []byte{})
+	if err != nil {
+		t.Fatalf("failed to wrap: %v", err)
+	}
+
+	unwrapped, err := UnwrapOAEP(ciphertext, opaqueSigner{signer})
+	if err != nil || string(unwrapped) != string(message) {
+		t.Fatalf("failed to unwrap OAEP: %v", err)
+	}
+
+	_, err = UnwrapOAEP(ciphertext, nil)
+	if err == nil {
+		t.Fatalf("expected unwrap without decrypter to fail")
+	}
+}
+
+func TestLoadOwnerKey_PKCS11NotConfigured(t *testing.T) {
+	_, err := LoadOwnerKey([]byte("pkcs11:id=%01%02"))
+	if err == nil {
+		t.Fatalf("expected PKCS#11 key reference to fail without PKCS#11 key store")
+	}
+}
@@ -0,0 +1,167 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type BadgerStore struct {
+	db *badger.DB
+}
+
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{
+		db: db,
+	}
+}
+
+func OpenBadgerStore(location string) (*BadgerStore, error) {
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, errors.New("Error opening Badger DB. " + err.Error())
+	}
+
+	return NewBadgerStore(db), nil
+}
+
+func (h *BadgerStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := h.View(func(txn Reader) error {
+		var err error
+		value, err = txn.Get(key)
+		return err
+	})
+
+	return value, err
+}
+
+func (h *BadgerStore) Iterate(prefix []byte, onEntry func(key []byte, value []byte) error) error {
+	return h.View(func(txn Reader) error {
+		return txn.Iterate(prefix, onEntry)
+	})
+}
+
+func (h *BadgerStore) IterateWithExpiry(prefix []byte, onEntry func(key []byte, value []byte, expiresAt time.Time) error) error {
+	return h.View(func(txn Reader) error {
+		return txn.IterateWithExpiry(prefix, onEntry)
+	})
+}
+
+func (h *BadgerStore) Set(key []byte, value []byte, ttl time.Duration) error {
+	return h.Update(func(txn Txn) error {
+		return txn.Set(key, value, ttl)
+	})
+}
+
+func (h *BadgerStore) Delete(key []byte) error {
+	return h.Update(func(txn Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+func (h *BadgerStore) View(fn func(txn Reader) error) error {
+	return h.db.View(func(dbtxn *badger.Txn) error {
+		return fn(badgerTxn{dbtxn: dbtxn})
+	})
+}
+
+func (h *BadgerStore) Update(fn func(txn Txn) error) error {
+	var err error
+	for attempt := 0; attempt < MAX_UPDATE_ATTEMPTS; attempt++ {
+		err = h.db.Update(func(dbtxn *badger.Txn) error {
+			return fn(badgerTxn{dbtxn: dbtxn})
+		})
+
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (h *BadgerStore) Close() error {
+	return h.db.Close()
+}
+
+type badgerTxn struct {
+	dbtxn *badger.Txn
+}
+
+func (h badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := h.dbtxn.Get(key)
+	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrKeyNotFound
+	} else if err != nil {
+		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
+	}
+
+	return value, nil
+}
+
+func (h badgerTxn) Iterate(prefix []byte, onEntry func(key []byte, value []byte) error) error {
+	return h.IterateWithExpiry(prefix, func(key []byte, value []byte, expiresAt time.Time) error {
+		return onEntry(key, value)
+	})
+}
+
+func (h badgerTxn) IterateWithExpiry(prefix []byte, onEntry func(key []byte, value []byte, expiresAt time.Time) error) error {
+	iterOptions := badger.DefaultIteratorOptions
+	iterOptions.Prefix = prefix
+
+	iterTxn := h.dbtxn.NewIterator(iterOptions)
+	defer iterTxn.Close()
+
+	for iterTxn.Rewind(); iterTxn.Valid(); iterTxn.Next() {
+		item := iterTxn.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return errors.New("Failed reading entry value. The error is: " + err.Error())
+		}
+
+		var expiresAt time.Time
+		if item.ExpiresAt() != 0 {
+			expiresAt = time.Unix(int64(item.ExpiresAt()), 0)
+		}
+
+		err = onEntry(item.KeyCopy(nil), value, expiresAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h badgerTxn) Set(key []byte, value []byte, ttl time.Duration) error {
+	entry := badger.NewEntry(key, value)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+
+	err := h.dbtxn.SetEntry(entry)
+	if err != nil {
+		return errors.New("Failed creating db entry instance. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+func (h badgerTxn) Delete(key []byte) error {
+	err := h.dbtxn.Delete(key)
+	if err != nil {
+		return errors.New("Failed initialise delete entry. The error is: " + err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR records are stored as RECORD_VERSION_TAG([version, record]), so the old records can be migrated when the type changes
+const RECORD_VERSION_TAG uint64 = 0x66646f76
+
+// Records written before versioning, and types without SchemaVersion, are version 1
+const DEFAULT_SCHEMA_VERSION uint16 = 1
+
+// Persisted types return version of their encoding. Bump it when the encoding changes, and add the migration from the previous version
+type Versioned interface {
+	SchemaVersion() uint16
+}
+
+type versionedRecord struct {
+	_       struct{} `cbor:",toarray"`
+	Version uint16
+	Record  cbor.RawMessage
+}
+
+func schemaVersionOf(entry interface{}) uint16 {
+	if versioned, ok := entry.(Versioned); ok {
+		return versioned.SchemaVersion()
+	}
+
+	return DEFAULT_SCHEMA_VERSION
+}
+
+func encodeRecord(version uint16, record []byte) ([]byte, error) {
+	return cbor.Marshal(cbor.Tag{
+		Number:  RECORD_VERSION_TAG,
+		Content: versionedRecord{Version: version, Record: record},
+	})
+}
+
+// Returns record version and the record without the version tag
+func decodeRecord(value []byte) (uint16, []byte, error) {
+	// Major type 6 is tag
+	if len(value) == 0 || value[0]>>5 != 6 {
+		return DEFAULT_SCHEMA_VERSION, value, nil
+	}
+
+	var rawTag cbor.RawTag
+	err := cbor.Unmarshal(value, &rawTag)
+	if err != nil || rawTag.Number != RECORD_VERSION_TAG {
+		return DEFAULT_SCHEMA_VERSION, value, nil
+	}
+
+	var recordInst versionedRecord
+	err = cbor.Unmarshal(rawTag.Content, &recordInst)
+	if err != nil {
+		return 0, nil, errors.New("Failed decoding record version. The error is: " + err.Error())
+	}
+
+	return recordInst.Version, recordInst.Record, nil
+}
+
+// Decodes entry value, and checks that the record version matches T
+func DecodeCbor[T any](value []byte) (*T, error) {
+	version, record, err := decodeRecord(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry T
+	expectedVersion := schemaVersionOf(entry)
+	if version != expectedVersion {
+		return nil, fmt.Errorf("Record version %d does not match %T version %d. Run db migrate", version, entry, expectedVersion)
+	}
+
+	err = fdoshared.CborCust.Unmarshal(record, &entry)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
+	}
+
+	return &entry, nil
+}
+
+func EncodeCbor(entry interface{}) ([]byte, error) {
+	entryBytes, err := fdoshared.CborCust.Marshal(entry)
+	if err != nil {
+		return nil, errors.New("Failed to marshal entry. The error is: " + err.Error())
+	}
+
+	return encodeRecord(schemaVersionOf(entry), entryBytes)
+}
+
+// Returns ErrKeyNotFound as is, so callers can check it with errors.Is
+func GetCbor[T any](txn Reader, key []byte) (*T, error) {
+	valueBytes, err := txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeCbor[T](valueBytes)
+}
+
+func SetCbor(txn Txn, key []byte, entry interface{}, ttl time.Duration) error {
+	entryBytes, err := EncodeCbor(entry)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(key, entryBytes, ttl)
+}
+
+func IterateCbor[T any](txn Reader, prefix []byte, onEntry func(key []byte, entry T) error) error {
+	return txn.Iterate(prefix, func(key []byte, value []byte) error {
+		entry, err := DecodeCbor[T](value)
+		if err != nil {
+			return err
+		}
+
+		return onEntry(key, *entry)
+	})
+}
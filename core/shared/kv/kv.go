@@ -0,0 +1,43 @@
+package kv
+
+import (
+	"errors"
+	"time"
+)
+
+// Key-value store used by all databases. Implemented by Badger, and by memory store for tests and embedded roles
+
+var ErrKeyNotFound = errors.New("Key not found")
+
+// Update gives up after this many conflicting attempts
+const MAX_UPDATE_ATTEMPTS int = 5
+
+type Reader interface {
+	Get(key []byte) ([]byte, error)
+
+	// Calls onEntry in key order for every entry with the prefix. Iteration stops on the first error
+	Iterate(prefix []byte, onEntry func(key []byte, value []byte) error) error
+
+	// Same as Iterate, also passing the entry expiry time. Zero time never expires
+	IterateWithExpiry(prefix []byte, onEntry func(key []byte, value []byte, expiresAt time.Time) error) error
+}
+
+type Txn interface {
+	Reader
+
+	// Entry expires after ttl. Zero ttl never expires
+	Set(key []byte, value []byte, ttl time.Duration) error
+	Delete(key []byte) error
+}
+
+// Every Txn method of the store runs in its own transaction
+type Store interface {
+	Txn
+
+	View(fn func(txn Reader) error) error
+
+	// Runs fn in one transaction. On conflict with concurrent update fn is called again, so it must not have side effects
+	Update(fn func(txn Txn) error) error
+
+	Close() error
+}
@@ -0,0 +1,380 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type testEntry struct {
+	_    struct{} `cbor:",toarray"`
+	Name string
+	Num  int
+}
+
+func newTestStores(t *testing.T) map[string]Store {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return map[string]Store{
+		"badger": NewBadgerStore(db),
+		"memory": NewMemoryStore(),
+	}
+}
+
+func TestStoreGetSetDelete(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Get([]byte("missing"))
+			if !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("expected ErrKeyNotFound, got %v", err)
+			}
+
+			err = store.Set([]byte("a"), []byte("value"), time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := store.Get([]byte("a"))
+			if err != nil || !bytes.Equal(value, []byte("value")) {
+				t.Fatalf("unexpected value %q, %v", value, err)
+			}
+
+			err = store.Delete([]byte("a"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = store.Get([]byte("a"))
+			if !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("expected ErrKeyNotFound after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreIterate(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"p-c", "p-a", "q-a", "p-b"} {
+				err := store.Set([]byte(key), []byte(key), 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			keys := []string{}
+			err := store.Iterate([]byte("p-"), func(key []byte, value []byte) error {
+				if !bytes.Equal(key, value) {
+					t.Errorf("value %q does not match key %q", value, key)
+				}
+
+				keys = append(keys, string(key))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(keys) != 3 || keys[0] != "p-a" || keys[1] != "p-b" || keys[2] != "p-c" {
+				t.Fatalf("unexpected keys %v", keys)
+			}
+
+			err = store.Update(func(txn Txn) error {
+				return txn.Iterate([]byte("p-"), func(key []byte, value []byte) error {
+					return txn.Delete(key)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count := 0
+			store.Iterate([]byte{}, func(key []byte, value []byte) error {
+				count++
+				return nil
+			})
+			if count != 1 {
+				t.Fatalf("expected only q-a to remain, got %d entries", count)
+			}
+		})
+	}
+}
+
+func TestStoreUpdateRollback(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Set([]byte("kept"), []byte("old"), 0)
+
+			failure := errors.New("failure")
+			err := store.Update(func(txn Txn) error {
+				txn.Set([]byte("kept"), []byte("new"), 0)
+				txn.Set([]byte("added"), []byte("new"), 0)
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("expected fn error, got %v", err)
+			}
+
+			value, _ := store.Get([]byte("kept"))
+			if !bytes.Equal(value, []byte("old")) {
+				t.Fatalf("expected rolled back value, got %q", value)
+			}
+
+			_, err = store.Get([]byte("added"))
+			if !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("expected added key to be rolled back, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreCbor(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := SetCbor(store, []byte("e-1"), testEntry{Name: "one", Num: 1}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			SetCbor(store, []byte("e-2"), testEntry{Name: "two", Num: 2}, 0)
+
+			entry, err := GetCbor[testEntry](store, []byte("e-1"))
+			if err != nil || entry.Name != "one" || entry.Num != 1 {
+				t.Fatalf("unexpected entry %+v, %v", entry, err)
+			}
+
+			_, err = GetCbor[testEntry](store, []byte("e-3"))
+			if !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("expected ErrKeyNotFound, got %v", err)
+			}
+
+			sum := 0
+			err = IterateCbor(store, []byte("e-"), func(key []byte, entry testEntry) error {
+				sum += entry.Num
+				return nil
+			})
+			if err != nil || sum != 3 {
+				t.Fatalf("unexpected sum %d, %v", sum, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Set([]byte("short"), []byte("value"), 10*time.Millisecond)
+	store.Set([]byte("forever"), []byte("value"), 0)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := store.Get([]byte("short"))
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected expired entry to be gone, got %v", err)
+	}
+
+	_, err = store.Get([]byte("forever"))
+	if err != nil {
+		t.Fatalf("expected entry without ttl to stay, got %v", err)
+	}
+
+	store.lastPurge = time.Time{}
+	store.Set([]byte("other"), []byte("value"), 0)
+	if _, ok := store.entries["short"]; ok {
+		t.Fatal("expected expired entry to be purged")
+	}
+}
+
+func TestStoreIterateWithExpiry(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Set([]byte("e-ttl"), []byte("value"), time.Hour)
+			store.Set([]byte("e-forever"), []byte("value"), 0)
+
+			expiries := map[string]time.Time{}
+			err := store.IterateWithExpiry([]byte("e-"), func(key []byte, value []byte, expiresAt time.Time) error {
+				expiries[string(key)] = expiresAt
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !expiries["e-forever"].IsZero() {
+				t.Fatalf("expected zero expiry for entry without ttl, got %v", expiries["e-forever"])
+			}
+
+			untilExpiry := time.Until(expiries["e-ttl"])
+			if untilExpiry < 59*time.Minute || untilExpiry > time.Hour+time.Second {
+				t.Fatalf("unexpected expiry %v for one hour ttl", expiries["e-ttl"])
+			}
+		})
+	}
+}
+
+type testEntryV2 struct {
+	_    struct{} `cbor:",toarray"`
+	Name string
+	Num  int
+	Tag  string
+}
+
+func (h testEntryV2) SchemaVersion() uint16 {
+	return 2
+}
+
+func TestCborSchemaVersion(t *testing.T) {
+	store := NewMemoryStore()
+
+	// Records written before versioning are version 1
+	legacyBytes, _ := fdoshared.CborCust.Marshal(testEntry{Name: "legacy", Num: 1})
+	store.Set([]byte("v-legacy"), legacyBytes, 0)
+
+	entry, err := GetCbor[testEntry](store, []byte("v-legacy"))
+	if err != nil || entry.Name != "legacy" {
+		t.Fatalf("failed decoding legacy record %v, %v", entry, err)
+	}
+
+	_, err = GetCbor[testEntryV2](store, []byte("v-legacy"))
+	if err == nil {
+		t.Fatal("expected version 1 record to fail decoding as version 2")
+	}
+
+	SetCbor(store, []byte("v-new"), testEntryV2{Name: "new", Num: 2, Tag: "x"}, 0)
+	_, err = GetCbor[testEntry](store, []byte("v-new"))
+	if err == nil {
+		t.Fatal("expected version 2 record to fail decoding as version 1")
+	}
+}
+
+func TestRunMigrations(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			legacyBytes, _ := fdoshared.CborCust.Marshal(testEntry{Name: "legacy", Num: 1})
+			store.Set([]byte("m-legacy"), legacyBytes, time.Hour)
+			SetCbor(store, []byte("m-v1"), testEntry{Name: "v1", Num: 2}, 0)
+			SetCbor(store, []byte("m-v2"), testEntryV2{Name: "v2", Num: 3, Tag: "kept"}, 0)
+
+			migrations := []Migration{
+				{
+					Prefix:      []byte("m-"),
+					FromVersion: 1,
+					Description: "Add tag",
+					Migrate: MigrateCbor(func(oldEntry testEntry) (testEntryV2, error) {
+						return testEntryV2{Name: oldEntry.Name, Num: oldEntry.Num, Tag: "migrated"}, nil
+					}),
+				},
+			}
+
+			migratedCount, err := RunMigrations(store, migrations)
+			if err != nil || migratedCount != 2 {
+				t.Fatalf("expected 2 migrated records, got %d, %v", migratedCount, err)
+			}
+
+			tags := map[string]string{}
+			err = IterateCbor(store, []byte("m-"), func(key []byte, entry testEntryV2) error {
+				tags[string(key)] = entry.Tag
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tags["m-legacy"] != "migrated" || tags["m-v1"] != "migrated" || tags["m-v2"] != "kept" {
+				t.Fatalf("unexpected tags %v", tags)
+			}
+
+			store.IterateWithExpiry([]byte("m-legacy"), func(key []byte, value []byte, expiresAt time.Time) error {
+				if expiresAt.IsZero() {
+					t.Error("expected migrated record to keep ttl")
+				}
+				return nil
+			})
+
+			migratedCount, err = RunMigrations(store, migrations)
+			if err != nil || migratedCount != 0 {
+				t.Fatalf("expected second run to migrate nothing, got %d, %v", migratedCount, err)
+			}
+		})
+	}
+}
+
+type testOtherEntry struct {
+	_     struct{} `cbor:",toarray"`
+	Email string
+}
+
+func TestRunMigrationsSharedPrefix(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			SetCbor(store, []byte("s-1"), testEntry{Name: "entry", Num: 1}, time.Hour)
+			SetCbor(store, []byte("s-2"), testOtherEntry{Email: "user@example.com"}, 0)
+			store.Set([]byte("s-map-1"), []byte("raw"), 0)
+
+			migrations := []Migration{
+				{
+					Prefix:       []byte("s-"),
+					FromVersion:  1,
+					Description:  "Move entries to own prefix",
+					Match:        MatchCbor[testEntry](),
+					MoveToPrefix: []byte("e-"),
+				},
+				{
+					Prefix:      []byte("e-"),
+					FromVersion: 1,
+					Description: "Add tag",
+					Migrate: MigrateCbor(func(oldEntry testEntry) (testEntryV2, error) {
+						return testEntryV2{Name: oldEntry.Name, Num: oldEntry.Num, Tag: "migrated"}, nil
+					}),
+				},
+				{
+					Prefix:      []byte("s-"),
+					FromVersion: 1,
+					Description: "Match by key",
+					Match: func(key []byte, record []byte) bool {
+						return !bytes.HasPrefix(key, []byte("s-map-"))
+					},
+					Migrate: MigrateCbor(func(oldEntry testOtherEntry) (testOtherEntry, error) {
+						return oldEntry, nil
+					}),
+				},
+			}
+
+			migratedCount, err := RunMigrations(store, migrations)
+			if err != nil || migratedCount != 3 {
+				t.Fatalf("expected 3 migrated records, got %d, %v", migratedCount, err)
+			}
+
+			_, err = store.Get([]byte("s-1"))
+			if !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("expected moved record to be deleted, got %v", err)
+			}
+
+			entry, err := GetCbor[testEntryV2](store, []byte("e-1"))
+			if err != nil || entry.Tag != "migrated" {
+				t.Fatalf("expected moved record to be migrated, got %v, %v", entry, err)
+			}
+
+			store.IterateWithExpiry([]byte("e-1"), func(key []byte, value []byte, expiresAt time.Time) error {
+				if expiresAt.IsZero() {
+					t.Error("expected moved record to keep ttl")
+				}
+				return nil
+			})
+
+			rawValue, err := store.Get([]byte("s-map-1"))
+			if err != nil || string(rawValue) != "raw" {
+				t.Fatalf("expected unmatched record to be left as is, got %q, %v", rawValue, err)
+			}
+		})
+	}
+}
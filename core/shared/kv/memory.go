@@ -0,0 +1,209 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Expired entries are skipped on read, and removed at most once per interval on update
+const MEMORY_PURGE_INTERVAL time.Duration = time.Minute
+
+var errReadOnlyTxn = errors.New("Transaction is read only")
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (h memoryEntry) isExpired(now time.Time) bool {
+	return !h.expiresAt.IsZero() && !now.Before(h.expiresAt)
+}
+
+// Transactions are serialised by a lock, so updates never conflict. Store must not be used from inside fn of View or Update
+type MemoryStore struct {
+	lock      sync.RWMutex
+	entries   map[string]memoryEntry
+	lastPurge time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:   map[string]memoryEntry{},
+		lastPurge: time.Now(),
+	}
+}
+
+func (h *MemoryStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := h.View(func(txn Reader) error {
+		var err error
+		value, err = txn.Get(key)
+		return err
+	})
+
+	return value, err
+}
+
+func (h *MemoryStore) Iterate(prefix []byte, onEntry func(key []byte, value []byte) error) error {
+	return h.View(func(txn Reader) error {
+		return txn.Iterate(prefix, onEntry)
+	})
+}
+
+func (h *MemoryStore) IterateWithExpiry(prefix []byte, onEntry func(key []byte, value []byte, expiresAt time.Time) error) error {
+	return h.View(func(txn Reader) error {
+		return txn.IterateWithExpiry(prefix, onEntry)
+	})
+}
+
+func (h *MemoryStore) Set(key []byte, value []byte, ttl time.Duration) error {
+	return h.Update(func(txn Txn) error {
+		return txn.Set(key, value, ttl)
+	})
+}
+
+func (h *MemoryStore) Delete(key []byte) error {
+	return h.Update(func(txn Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+func (h *MemoryStore) View(fn func(txn Reader) error) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return fn(&memoryTxn{store: h, now: time.Now()})
+}
+
+// Changes are applied in place, and rolled back when fn fails
+func (h *MemoryStore) Update(fn func(txn Txn) error) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	txn := memoryTxn{store: h, now: time.Now(), undo: map[string]*memoryEntry{}}
+	err := fn(&txn)
+	if err != nil {
+		txn.rollback()
+		return err
+	}
+
+	if txn.now.Sub(h.lastPurge) > MEMORY_PURGE_INTERVAL {
+		h.purgeExpired(txn.now)
+	}
+
+	return nil
+}
+
+func (h *MemoryStore) Close() error {
+	return nil
+}
+
+func (h *MemoryStore) purgeExpired(now time.Time) {
+	for key, entry := range h.entries {
+		if entry.isExpired(now) {
+			delete(h.entries, key)
+		}
+	}
+
+	h.lastPurge = now
+}
+
+type memoryTxn struct {
+	store *MemoryStore
+	now   time.Time
+
+	// Previous entries of changed keys. Nil entry means the key did not exist. Nil map means read only
+	undo map[string]*memoryEntry
+}
+
+func (h *memoryTxn) Get(key []byte) ([]byte, error) {
+	entry, ok := h.store.entries[string(key)]
+	if !ok || entry.isExpired(h.now) {
+		return nil, ErrKeyNotFound
+	}
+
+	return append([]byte{}, entry.value...), nil
+}
+
+func (h *memoryTxn) Iterate(prefix []byte, onEntry func(key []byte, value []byte) error) error {
+	return h.IterateWithExpiry(prefix, func(key []byte, value []byte, expiresAt time.Time) error {
+		return onEntry(key, value)
+	})
+}
+
+// Keys are collected before calling onEntry, so onEntry can change them
+func (h *memoryTxn) IterateWithExpiry(prefix []byte, onEntry func(key []byte, value []byte, expiresAt time.Time) error) error {
+	keys := []string{}
+	for key, entry := range h.store.entries {
+		if bytes.HasPrefix([]byte(key), prefix) && !entry.isExpired(h.now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry, ok := h.store.entries[key]
+		if !ok || entry.isExpired(h.now) {
+			continue
+		}
+
+		err := onEntry([]byte(key), append([]byte{}, entry.value...), entry.expiresAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *memoryTxn) saveUndo(key string) {
+	if _, ok := h.undo[key]; ok {
+		return
+	}
+
+	if entry, ok := h.store.entries[key]; ok {
+		h.undo[key] = &entry
+	} else {
+		h.undo[key] = nil
+	}
+}
+
+func (h *memoryTxn) Set(key []byte, value []byte, ttl time.Duration) error {
+	if h.undo == nil {
+		return errReadOnlyTxn
+	}
+
+	h.saveUndo(string(key))
+
+	entry := memoryEntry{value: append([]byte{}, value...)}
+	if ttl > 0 {
+		entry.expiresAt = h.now.Add(ttl)
+	}
+
+	h.store.entries[string(key)] = entry
+	return nil
+}
+
+func (h *memoryTxn) Delete(key []byte) error {
+	if h.undo == nil {
+		return errReadOnlyTxn
+	}
+
+	h.saveUndo(string(key))
+	delete(h.store.entries, string(key))
+	return nil
+}
+
+func (h *memoryTxn) rollback() {
+	for key, entry := range h.undo {
+		if entry == nil {
+			delete(h.store.entries, key)
+		} else {
+			h.store.entries[key] = *entry
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Migrated records are written in batches, so large prefixes do not exceed transaction size
+const MIGRATION_BATCH int = 1000
+
+// Upgrades records under the prefix from FromVersion to FromVersion+1. Records of other versions are left as is,
+// so the migrations can run on every startup. When prefix is shared with other record types, Match selects the records of this type
+type Migration struct {
+	Prefix      []byte
+	FromVersion uint16
+	Description string
+
+	// Optional. Gets the key and the record without the version tag, see MatchCbor
+	Match func(key []byte, record []byte) bool
+
+	// Gets record of FromVersion without the version tag, and returns record of FromVersion+1
+	Migrate func(record []byte) ([]byte, error)
+
+	// When set, records are moved under this prefix as is, instead of Migrate. Used to give record type its own prefix
+	MoveToPrefix []byte
+}
+
+func (h Migration) matches(key []byte, version uint16, record []byte) bool {
+	if version != h.FromVersion {
+		return false
+	}
+
+	return h.Match == nil || h.Match(key, record)
+}
+
+// Returns Match func, that checks that the record decodes as T. Arrays of toarray structs only decode into the struct with the same number of fields
+func MatchCbor[T any]() func(key []byte, record []byte) bool {
+	return func(key []byte, record []byte) bool {
+		var entry T
+		return fdoshared.CborCust.Unmarshal(record, &entry) == nil
+	}
+}
+
+// Returns Migrate func, that decodes the old record as From, and encodes converted record
+func MigrateCbor[From any, To any](convert func(oldEntry From) (To, error)) func(record []byte) ([]byte, error) {
+	return func(record []byte) ([]byte, error) {
+		var oldEntry From
+		err := fdoshared.CborCust.Unmarshal(record, &oldEntry)
+		if err != nil {
+			return nil, errors.New("Failed cbor decoding old record. The error is: " + err.Error())
+		}
+
+		newEntry, err := convert(oldEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		return fdoshared.CborCust.Marshal(newEntry)
+	}
+}
+
+// Runs migrations in order, and returns the number of migrated records
+func RunMigrations(store Store, migrations []Migration) (int, error) {
+	migratedCount := 0
+	for _, migration := range migrations {
+		count, err := runMigration(store, migration)
+		migratedCount += count
+		if err != nil {
+			return migratedCount, fmt.Errorf("Migration \"%s\" failed. %s", migration.Description, err.Error())
+		}
+	}
+
+	return migratedCount, nil
+}
+
+type pendingRecord struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+func runMigration(store Store, migration Migration) (int, error) {
+	pendingRecords := []pendingRecord{}
+	err := store.IterateWithExpiry(migration.Prefix, func(key []byte, value []byte, expiresAt time.Time) error {
+		version, record, err := decodeRecord(value)
+		if err == nil && migration.matches(key, version, record) {
+			pendingRecords = append(pendingRecords, pendingRecord{key: key, expiresAt: expiresAt})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	migratedCount := 0
+	for batchStart := 0; batchStart < len(pendingRecords); batchStart += MIGRATION_BATCH {
+		batchEnd := batchStart + MIGRATION_BATCH
+		if batchEnd > len(pendingRecords) {
+			batchEnd = len(pendingRecords)
+		}
+
+		batchCount := 0
+		err := store.Update(func(txn Txn) error {
+			batchCount = 0
+			for _, pending := range pendingRecords[batchStart:batchEnd] {
+				// Record could change since it was listed
+				value, err := txn.Get(pending.key)
+				if errors.Is(err, ErrKeyNotFound) {
+					continue
+				} else if err != nil {
+					return err
+				}
+
+				version, record, err := decodeRecord(value)
+				if err != nil {
+					return err
+				}
+
+				if !migration.matches(pending.key, version, record) {
+					continue
+				}
+
+				var ttl time.Duration
+				if !pending.expiresAt.IsZero() {
+					ttl = time.Until(pending.expiresAt)
+					if ttl <= 0 {
+						continue
+					}
+				}
+
+				if migration.MoveToPrefix != nil {
+					newKey := append(append([]byte{}, migration.MoveToPrefix...), pending.key[len(migration.Prefix):]...)
+					err = txn.Set(newKey, value, ttl)
+					if err != nil {
+						return err
+					}
+
+					err = txn.Delete(pending.key)
+					if err != nil {
+						return err
+					}
+
+					batchCount++
+					continue
+				}
+
+				newRecord, err := migration.Migrate(record)
+				if err != nil {
+					return fmt.Errorf("Failed migrating record %q. %s", pending.key, err.Error())
+				}
+
+				newValue, err := encodeRecord(migration.FromVersion+1, newRecord)
+				if err != nil {
+					return err
+				}
+
+				err = txn.Set(pending.key, newValue, ttl)
+				if err != nil {
+					return err
+				}
+
+				batchCount++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return migratedCount, err
+		}
+
+		migratedCount += batchCount
+	}
+
+	return migratedCount, nil
+}
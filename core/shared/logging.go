@@ -0,0 +1,149 @@
+package fdoshared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Structured log fields. Every FDO message handler log line has cid and cmd, and guid, session and testId when they are known
+const (
+	LOG_FIELD_CID     string = "cid"
+	LOG_FIELD_CMD     string = "cmd"
+	LOG_FIELD_GUID    string = "guid"
+	LOG_FIELD_SESSION string = "session"
+	LOG_FIELD_TEST_ID string = "testId"
+)
+
+const (
+	LOG_FORMAT_TEXT string = "text"
+	LOG_FORMAT_JSON string = "json"
+)
+
+type requestLogKey struct{}
+
+// Per request logger. CID is returned to the client as EMErrorCID, so error reports can be matched with the server logs
+type RequestLog struct {
+	lock   sync.Mutex
+	cid    uint
+	logger *slog.Logger
+}
+
+func NewRequestLog(logger *slog.Logger) *RequestLog {
+	return &RequestLog{
+		cid:    NewCID(),
+		logger: logger,
+	}
+}
+
+// Hello messages derive the CID for the whole session. Sessionless requests keep the CID of the request
+func NewCID() uint {
+	return uint(rand.Uint64())
+}
+
+// Messages of the session log the CID that is stored in the session
+func (h *RequestLog) SetCID(cid uint) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.cid = cid
+}
+
+func (h *RequestLog) CID() uint {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.cid
+}
+
+// Adds fields to every following log line of the request
+func (h *RequestLog) With(args ...any) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.logger = h.logger.With(args...)
+}
+
+// CID is logged as decimal string, so JSON log tools do not round it to float
+func (h *RequestLog) Logger() *slog.Logger {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.logger.With(LOG_FIELD_CID, strconv.FormatUint(uint64(h.cid), 10))
+}
+
+// Returns request log set by WithRequestLog. Requests without one get a new log with the new CID
+func GetRequestLog(r *http.Request) *RequestLog {
+	requestLog, ok := r.Context().Value(requestLogKey{}).(*RequestLog)
+	if !ok {
+		return NewRequestLog(slog.Default())
+	}
+
+	return requestLog
+}
+
+// Shortcut for GetRequestLog(r).Logger()
+func RequestLogger(r *http.Request) *slog.Logger {
+	return GetRequestLog(r).Logger()
+}
+
+// Attaches request log with the new CID, cmd and session to the handler request
+func WithRequestLog(cmd FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestLog := NewRequestLog(slog.Default().With(LOG_FIELD_CMD, cmd.ToString()))
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader != "" {
+			requestLog.With(LOG_FIELD_SESSION, SessionLogId(authorizationHeader))
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, requestLog)))
+	}
+}
+
+// Session tokens are credentials, so only the short hash of the token is logged
+func SessionLogId(authorizationHeader string) string {
+	token := strings.TrimPrefix(authorizationHeader, "Bearer ")
+	tokenHash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(tokenHash[:8])
+}
+
+func ParseLogLevel(levelStr string) (slog.Level, error) {
+	var level slog.Level
+	if levelStr == "" {
+		return slog.LevelInfo, nil
+	}
+
+	err := level.UnmarshalText([]byte(levelStr))
+	if err != nil {
+		return level, fmt.Errorf("Unknown log level %s. Expected debug, info, warn or error", levelStr)
+	}
+
+	return level, nil
+}
+
+func NewLogger(w io.Writer, levelStr string, format string) (*slog.Logger, error) {
+	level, err := ParseLogLevel(levelStr)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", LOG_FORMAT_TEXT:
+		return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+	case LOG_FORMAT_JSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
+	default:
+		return nil, fmt.Errorf("Unknown log format %s. Expected text or json", format)
+	}
+}
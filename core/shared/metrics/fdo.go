@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	SERVICE_RV string = "rv"
+	SERVICE_DO string = "do"
+)
+
+// Same as DO session TTL. Sessions that did not send a message for this long are not active
+const TO2_SESSION_IDLE_TIME time.Duration = 10 * time.Minute
+
+var FdoRequests = factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "fdo_requests_total",
+	Help: "FDO messages handled by the built-in RV and DO",
+}, []string{"service", "cmd"})
+
+var FdoRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "fdo_request_duration_seconds",
+	Help:    "FDO message handling time",
+	Buckets: prometheus.DefBuckets,
+}, []string{"service", "cmd"})
+
+var FdoErrors = factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "fdo_errors_total",
+	Help: "FDO error messages returned by the built-in RV and DO, by FdoErrorCode",
+}, []string{"service", "cmd", "error_code"})
+
+var TestRunDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "fdo_test_run_duration_seconds",
+	Help:    "RVT and DOT run time",
+	Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+}, []string{"protocol", "status"})
+
+var TestResults = factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "fdo_test_results_total",
+	Help: "RVT and DOT test results",
+}, []string{"protocol", "result"})
+
+var to2Sessions = activeSessions{lastSeen: map[string]time.Time{}}
+
+var To2ActiveSessions = factory.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "fdo_to2_active_sessions",
+	Help: "TO2 sessions of the built-in DO that are not done, failed or idle",
+}, func() float64 {
+	return float64(to2Sessions.count())
+})
+
+func ReportTestResult(protocol fdoshared.FdoToProtocol, passed bool) {
+	result := "failed"
+	if passed {
+		result = "passed"
+	}
+
+	TestResults.WithLabelValues(protocolLabel(protocol), result).Inc()
+}
+
+func ReportTestRun(protocol fdoshared.FdoToProtocol, status string, duration time.Duration) {
+	TestRunDuration.WithLabelValues(protocolLabel(protocol), status).Observe(duration.Seconds())
+}
+
+func protocolLabel(protocol fdoshared.FdoToProtocol) string {
+	return "to" + strconv.Itoa(int(protocol))
+}
+
+type activeSessions struct {
+	lock      sync.Mutex
+	lastSeen  map[string]time.Time
+	lastPrune time.Time
+}
+
+// Idle sessions are pruned at most once per idle time, so the map stays bounded without scrapes
+func (h *activeSessions) touch(sessionId string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastPrune) > TO2_SESSION_IDLE_TIME {
+		h.prune(now)
+	}
+
+	h.lastSeen[sessionId] = now
+}
+
+func (h *activeSessions) end(sessionId string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.lastSeen, sessionId)
+}
+
+func (h *activeSessions) count() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.prune(time.Now())
+
+	return len(h.lastSeen)
+}
+
+// Caller must hold the lock
+func (h *activeSessions) prune(now time.Time) {
+	for sessionId, lastSeen := range h.lastSeen {
+		if now.Sub(lastSeen) > TO2_SESSION_IDLE_TIME {
+			delete(h.lastSeen, sessionId)
+		}
+	}
+
+	h.lastPrune = now
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	isError   bool
+	errorCode fdoshared.FdoErrorCode
+}
+
+func (h *recordingWriter) WriteHeader(statusCode int) {
+	h.isError = h.Header().Get("Message-Type") == fdoshared.TO_ERROR_255.ToString()
+	h.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (h *recordingWriter) Write(body []byte) (int, error) {
+	if h.isError {
+		var fdoError fdoshared.FdoError
+		if fdoshared.CborCust.Unmarshal(body, &fdoError) == nil {
+			h.errorCode = fdoError.EMErrorCode
+		}
+	}
+
+	return h.ResponseWriter.Write(body)
+}
+
+// Records request count, latency and returned FDO error code of the FDO message handler
+func InstrumentFdoHandler(service string, cmd fdoshared.FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		recWriter := &recordingWriter{ResponseWriter: w}
+
+		handler(recWriter, r)
+
+		cmdStr := cmd.ToString()
+		FdoRequests.WithLabelValues(service, cmdStr).Inc()
+		FdoRequestDuration.WithLabelValues(service, cmdStr).Observe(time.Since(startTime).Seconds())
+
+		if recWriter.isError {
+			FdoErrors.WithLabelValues(service, cmdStr, strconv.FormatUint(uint64(recWriter.errorCode), 10)).Inc()
+		}
+
+		if service == SERVICE_DO {
+			trackTo2Session(cmd, r, recWriter)
+		}
+	}
+}
+
+// Session starts with HelloDevice60 response token, and ends with Done70 or error
+func trackTo2Session(cmd fdoshared.FdoCmd, r *http.Request, recWriter *recordingWriter) {
+	sessionId := r.Header.Get("Authorization")
+	if cmd == fdoshared.TO2_60_HELLO_DEVICE {
+		sessionId = recWriter.Header().Get("Authorization")
+	}
+
+	if sessionId == "" {
+		return
+	}
+
+	if recWriter.isError || cmd == fdoshared.TO2_70_DONE {
+		to2Sessions.end(sessionId)
+		return
+	}
+
+	to2Sessions.touch(sessionId)
+}
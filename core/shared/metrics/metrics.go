@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Own registry, so /metrics only exports the tools metrics and the Go runtime ones
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Gauge with labels, that is read from collect on every scrape
+type gaugeFunc struct {
+	desc    *prometheus.Desc
+	collect func() []Sample
+}
+
+func (h *gaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+func (h *gaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	for _, sample := range h.collect() {
+		ch <- prometheus.MustNewConstMetric(h.desc, prometheus.GaugeValue, sample.Value, sample.LabelValues...)
+	}
+}
+
+func NewGaugeFunc(name string, help string, labels []string, collect func() []Sample) {
+	registry.MustRegister(&gaugeFunc{
+		desc:    prometheus.NewDesc(name, help, labels, nil),
+		collect: collect,
+	})
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Serves /metrics on its own port, so metrics are not reachable through the public port
+func ListenAndServe(port int) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
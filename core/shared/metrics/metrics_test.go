@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandler(t *testing.T) {
+	NewGaugeFunc("test_pool_level", "Test gauge", []string{"name"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"a\"b"}, Value: 2}}
+	})
+	TestRunDuration.WithLabelValues("to2", "finished").Observe(3)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	output := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE test_pool_level gauge\n",
+		"test_pool_level{name=\"a\\\"b\"} 2\n",
+		"# TYPE fdo_test_run_duration_seconds histogram\n",
+		"fdo_test_run_duration_seconds_bucket{protocol=\"to2\",status=\"finished\",le=\"1\"} 0\n",
+		"fdo_test_run_duration_seconds_bucket{protocol=\"to2\",status=\"finished\",le=\"5\"} 1\n",
+		"fdo_test_run_duration_seconds_sum{protocol=\"to2\",status=\"finished\"} 3\n",
+		"fdo_to2_active_sessions 0\n",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q. Output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestInstrumentFdoHandler(t *testing.T) {
+	helloHandler := InstrumentFdoHandler(SERVICE_DO, fdoshared.TO2_60_HELLO_DEVICE, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Authorization", "Bearer session1")
+		w.WriteHeader(http.StatusOK)
+	})
+	doneHandler := InstrumentFdoHandler(SERVICE_DO, fdoshared.TO2_70_DONE, func(w http.ResponseWriter, r *http.Request) {
+		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO2_70_DONE, "bad", http.StatusBadRequest)
+	})
+
+	helloHandler(httptest.NewRecorder(), httptest.NewRequest("POST", "/fdo/101/msg/60", nil))
+	if to2Sessions.count() != 1 {
+		t.Fatalf("expected 1 active session, got %d", to2Sessions.count())
+	}
+
+	doneRequest := httptest.NewRequest("POST", "/fdo/101/msg/70", nil)
+	doneRequest.Header.Set("Authorization", "Bearer session1")
+	doneHandler(httptest.NewRecorder(), doneRequest)
+
+	if to2Sessions.count() != 0 {
+		t.Fatalf("expected session to end on error, got %d active", to2Sessions.count())
+	}
+
+	if testutil.ToFloat64(FdoRequests.WithLabelValues(SERVICE_DO, "60")) != 1 || testutil.ToFloat64(FdoRequests.WithLabelValues(SERVICE_DO, "70")) != 1 {
+		t.Fatal("expected one request of each cmd")
+	}
+
+	errorCode := fdoshared.INVALID_MESSAGE_ERROR
+	if testutil.ToFloat64(FdoErrors.WithLabelValues(SERVICE_DO, "70", strconv.FormatUint(uint64(errorCode), 10))) != 1 {
+		t.Fatal("expected error code to be counted")
+	}
+}
+
+func TestActiveSessions_PruneOnTouch(t *testing.T) {
+	sessions := activeSessions{lastSeen: map[string]time.Time{}}
+	sessions.touch("idle")
+	sessions.lastSeen["idle"] = time.Now().Add(-2 * TO2_SESSION_IDLE_TIME)
+	sessions.lastPrune = time.Now().Add(-2 * TO2_SESSION_IDLE_TIME)
+
+	sessions.touch("active")
+
+	if _, ok := sessions.lastSeen["idle"]; ok || len(sessions.lastSeen) != 1 {
+		t.Fatalf("expected idle session to be pruned without a scrape. Got %v", sessions.lastSeen)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -76,14 +77,14 @@ func NewFdoGuid_FIDO() FdoGuid {
 
 type FdoGuidList []FdoGuid
 
-func (h FdoGuidList) GetRandomBatch(size int) FdoGuidList {
+func (h FdoGuidList) GetRandomBatch(rnd *Conf_Rand, size int) FdoGuidList {
 	listLen := len(h)
 
 	if listLen == 0 {
 		return FdoGuidList{}
 	}
 
-	randomLoc := NewRandomInt(0, listLen-1)
+	randomLoc := rnd.Int(0, listLen-1)
 
 	if randomLoc+size > listLen {
 		l1len := listLen - randomLoc
@@ -106,7 +107,7 @@ func (h FdoGuidList) Contains(guid FdoGuid) bool {
 	return false
 }
 
-func (h FdoGuidList) GetRandomSelection(size int) FdoGuidList {
+func (h FdoGuidList) GetRandomSelection(rnd *Conf_Rand, size int) FdoGuidList {
 	randomPick := FdoGuidList{}
 
 	if size >= len(h) {
@@ -114,7 +115,7 @@ func (h FdoGuidList) GetRandomSelection(size int) FdoGuidList {
 	}
 
 	for {
-		randomId := NewRandomInt(0, len(h)-1)
+		randomId := rnd.Int(0, len(h)-1)
 		randomGuid := h[randomId]
 
 		if !randomPick.Contains(randomGuid) {
@@ -131,37 +132,50 @@ func (h FdoGuidList) GetRandomSelection(size int) FdoGuidList {
 
 type FdoSeedIDs map[DeviceSgType]FdoGuidList
 
-func (h *FdoSeedIDs) GetTestBatch(size int) FdoSeedIDs {
+// Returns sgTypes in a stable order, so that seeded selection does not depend on map iteration order
+func (h *FdoSeedIDs) sortedSgTypes() []DeviceSgType {
+	var sgTypes []DeviceSgType = []DeviceSgType{}
+	for k := range *h {
+		sgTypes = append(sgTypes, k)
+	}
+
+	sort.Slice(sgTypes, func(i, j int) bool { return sgTypes[i] < sgTypes[j] })
+
+	return sgTypes
+}
+
+func (h *FdoSeedIDs) GetTestBatch(rnd *Conf_Rand, size int) FdoSeedIDs {
 	var newTestBatch FdoSeedIDs = FdoSeedIDs{}
 
-	for k, v := range *h {
-		newTestBatch[k] = v.GetRandomBatch(size)
+	for _, k := range h.sortedSgTypes() {
+		newTestBatch[k] = (*h)[k].GetRandomBatch(rnd, size)
 	}
 
 	return newTestBatch
 }
 
-func (h *FdoSeedIDs) GetRandomTestGuid() FdoGuid {
+func (h *FdoSeedIDs) GetRandomTestGuid(rnd *Conf_Rand) FdoGuid {
 	var randomGuids []FdoGuid = []FdoGuid{}
 
-	for _, v := range *h {
+	for _, k := range h.sortedSgTypes() {
+		v := (*h)[k]
 		if len(v) == 0 {
 			continue
 		}
 
-		randLoc := NewRandomInt(0, len(v)-1)
+		randLoc := rnd.Int(0, len(v)-1)
 		randomGuids = append(randomGuids, v[randLoc])
 	}
 
-	randLoc := NewRandomInt(0, len(randomGuids)-1)
+	randLoc := rnd.Int(0, len(randomGuids)-1)
 	return randomGuids[randLoc]
 }
 
-func (h *FdoSeedIDs) GetRandomTestGuidForSgType(sgType DeviceSgType) FdoGuid {
+func (h *FdoSeedIDs) GetRandomTestGuidForSgType(rnd *Conf_Rand, sgType DeviceSgType) FdoGuid {
 	sh := *h
 	var randomGuids []FdoGuid = sh[sgType]
 
-	randLoc := NewRandomInt(0, len(randomGuids)-1)
+	randLoc := rnd.Int(0, len(randomGuids)-1)
 	return randomGuids[randLoc]
 }
 
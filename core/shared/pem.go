@@ -2,4 +2,6 @@ package fdoshared
 
 const OWNERSHIP_VOUCHER_PEM_TYPE string = "OWNERSHIP VOUCHER"
 const CREDENTIAL_PEM_TYPE string = "WAW FDO DEVICE CREDENTIAL"
+const SEALED_CREDENTIAL_PEM_TYPE string = "WAW FDO SEALED DEVICE CREDENTIAL"
 const PRIVATE_KEY_PEM_TYPE string = "PRIVATE KEY"
+const PKCS11_KEY_REF_PEM_TYPE string = "PKCS11 KEY REFERENCE"
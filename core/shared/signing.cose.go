@@ -2,9 +2,15 @@ package fdoshared
 
 import (
 	"crypto"
-	"encoding/hex"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 )
 
 type CoseConsts int
@@ -21,7 +27,7 @@ type CoseAlg int
 
 const (
 	CA_PKCS1_SHA1   CoseAlg = -65535
-	CA_PSS_SHA256   CoseAlg = -3
+	CA_PSS_SHA256   CoseAlg = -37
 	CA_PSS_SHA512   CoseAlg = -39
 	CA_PSS_SHA384   CoseAlg = -38
 	CA_PKCS1_SHA256 CoseAlg = -257
@@ -30,6 +36,7 @@ const (
 	CA_P256         CoseAlg = 1
 	CA_P384         CoseAlg = 2
 	CA_P521         CoseAlg = 3
+	CA_Ed25519      CoseAlg = 6
 )
 
 var CoseAlgToHash map[CoseAlg]crypto.Hash = map[CoseAlg]crypto.Hash{
@@ -53,71 +60,157 @@ type CosePublicKey struct {
 	Y      []byte      `cbor:"-3,keyasint,omitempty"`
 }
 
-func CoseKeyToX509(pubKey FdoPublicKey) ([]byte, error) {
-	var algId CoseAlg
-	var rawPublicKey []byte
-
-	switch pubKey.PkEnc {
-	case COSEKEY:
-		cosePubKey, ok := pubKey.PkBody.(CosePublicKey)
-		if !ok {
-			return nil, errors.New("error converting COSE public key. Could not cast pubKey instance to COSE PubKey")
+// Encodes EC2, OKP or RSA public key as COSE_Key. EC2 coordinates are padded to the curve size
+func NewCosePublicKey(publicKeyInst interface{}, sgType DeviceSgType) (*CosePublicKey, error) {
+	switch publicKey := publicKeyInst.(type) {
+	case *ecdsa.PublicKey:
+		var crv CoseAlg
+		switch sgType {
+		case StSECP256R1:
+			crv = CA_P256
+		case StSECP384R1:
+			crv = CA_P384
+		case StSECP521R1:
+			crv = CA_P521
+		default:
+			return nil, fmt.Errorf("%d is an unsupported EC2 SgType", sgType)
+		}
+
+		coordLen := (publicKey.Curve.Params().BitSize + 7) / 8
+
+		return &CosePublicKey{
+			Kty:    CoseEC2,
+			Alg:    CoseAlg(sgType),
+			CrvOrN: crv,
+			XorE:   publicKey.X.FillBytes(make([]byte, coordLen)),
+			Y:      publicKey.Y.FillBytes(make([]byte, coordLen)),
+		}, nil
+	case ed25519.PublicKey:
+		return &CosePublicKey{
+			Kty:    CoseOKP,
+			Alg:    CoseAlg(sgType),
+			CrvOrN: CA_Ed25519,
+			XorE:   []byte(publicKey),
+		}, nil
+	case *rsa.PublicKey:
+		return &CosePublicKey{
+			Kty:    CoseRSA,
+			Alg:    CoseAlg(sgType),
+			CrvOrN: publicKey.N.Bytes(),
+			XorE:   big.NewInt(int64(publicKey.E)).Bytes(),
+		}, nil
+	default:
+		return nil, errors.New("error encoding COSE public key. Unsupported public key type")
+	}
+}
+
+// CrvOrN is CoseAlg when created locally, and uint64 or int64 after CBOR decoding
+func coseCurveId(crvOrN interface{}) (CoseAlg, error) {
+	switch crv := crvOrN.(type) {
+	case CoseAlg:
+		return crv, nil
+	case int:
+		return CoseAlg(crv), nil
+	case int64:
+		return CoseAlg(crv), nil
+	case uint64:
+		return CoseAlg(crv), nil
+	default:
+		return 0, errors.New("error decoding COSE public key. Curve must be an integer")
+	}
+}
+
+// Returns *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey
+func (h CosePublicKey) GetPublicKey() (interface{}, error) {
+	switch h.Kty {
+	case CoseEC2:
+		crv, err := coseCurveId(h.CrvOrN)
+		if err != nil {
+			return nil, err
 		}
 
-		switch cosePubKey.Kty {
-		case CoseEC2:
-			x := cosePubKey.XorE
-			y := cosePubKey.Y
-
-			xy := append(x, y...)
-			rawPublicKey = append([]byte{0x04}, xy...)
-			algId = cosePubKey.CrvOrN.(CoseAlg)
-		case CoseRSA:
-			rawPublicKey = cosePubKey.CrvOrN.([]byte)
-			algId = cosePubKey.Alg
-		case CoseOKP:
-			return nil, errors.New("unsupported COSE key type: OKP")
+		var curve elliptic.Curve
+		switch crv {
+		case CA_P256:
+			curve = elliptic.P256()
+		case CA_P384:
+			curve = elliptic.P384()
+		case CA_P521:
+			curve = elliptic.P521()
 		default:
-			return nil, fmt.Errorf("unsupported COSE key type: %d", cosePubKey.Kty)
+			return nil, fmt.Errorf("unsupported COSE EC2 curve: %d", crv)
+		}
+
+		coordLen := (curve.Params().BitSize + 7) / 8
+		if len(h.XorE) != coordLen || len(h.Y) != coordLen {
+			return nil, errors.New("error decoding COSE public key. Bad EC2 coordinates length")
+		}
+
+		publicKey := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(h.XorE),
+			Y:     new(big.Int).SetBytes(h.Y),
+		}
+
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("error decoding COSE public key. Point is not on the curve")
+		}
+
+		return publicKey, nil
+	case CoseRSA:
+		n, ok := h.CrvOrN.([]byte)
+		if !ok || len(n) == 0 {
+			return nil, errors.New("error decoding COSE public key. RSA modulus must be a byte string")
 		}
 
+		e := new(big.Int).SetBytes(h.XorE)
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > math.MaxInt32 {
+			return nil, errors.New("error decoding COSE public key. Bad RSA exponent")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(e.Int64()),
+		}, nil
+	case CoseOKP:
+		crv, err := coseCurveId(h.CrvOrN)
+		if err != nil {
+			return nil, err
+		}
+
+		if crv != CA_Ed25519 {
+			return nil, fmt.Errorf("unsupported COSE OKP curve: %d", crv)
+		}
+
+		if len(h.XorE) != ed25519.PublicKeySize {
+			return nil, errors.New("error decoding COSE public key. Bad Ed25519 key length")
+		}
+
+		return ed25519.PublicKey(h.XorE), nil
 	default:
+		return nil, fmt.Errorf("unsupported COSE key type: %d", h.Kty)
+	}
+}
+
+func CoseKeyToX509(pubKey FdoPublicKey) ([]byte, error) {
+	if pubKey.PkEnc != COSEKEY {
 		return nil, fmt.Errorf("unsupported public key encoding: %d", pubKey.PkEnc)
 	}
 
-	switch algId {
-	case CA_P256:
-		buff, _ := hex.DecodeString("3059301306072a8648ce3d020106082a8648ce3d030107034200")
-		return append(buff, rawPublicKey...), nil
-	case CA_P384:
-		buff, _ := hex.DecodeString("3076301006072a8648ce3d020106052b81040022036200")
-		return append(buff, rawPublicKey...), nil
-	case CA_P521:
-		buff, _ := hex.DecodeString("30819b301006072a8648ce3d020106052b8104002303818600")
-		return append(buff, rawPublicKey...), nil
-	case CA_PKCS1_SHA256, CA_PKCS1_SHA384, CA_PKCS1_SHA512:
-		if len(rawPublicKey) < 512 { // 2080 key
-			pkcsHeader, _ := hex.DecodeString("30820122300d06092a864886f70d01010105000382010f003082010a0282010100")
-			pkcsEXP, _ := hex.DecodeString("0203010001")
-
-			if len(rawPublicKey) > 256 {
-				rawPublicKey = rawPublicKey[1:]
-			}
-
-			return append(append(pkcsHeader, rawPublicKey...), pkcsEXP...), nil
-		} else if len(rawPublicKey) >= 512 || len(rawPublicKey) == 513 {
-			pkcsHeader, _ := hex.DecodeString("30820222300d06092a864886f70d01010105000382020f003082020a0282020100")
-			pkcsEXP, _ := hex.DecodeString("0203010001")
-
-			if len(rawPublicKey) > 512 {
-				rawPublicKey = rawPublicKey[1:]
-			}
-
-			return append(append(pkcsHeader, rawPublicKey...), pkcsEXP...), nil
-		} else {
-			return nil, fmt.Errorf("unsupported key size: %d", len(rawPublicKey))
-		}
-	default:
-		return nil, fmt.Errorf("unsupported COSE key type: %d", algId)
+	cosePubKey, err := pubKey.GetCosePublicKey()
+	if err != nil {
+		return nil, err
 	}
+
+	publicKeyInst, err := cosePubKey.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyX509, err := x509.MarshalPKIXPublicKey(publicKeyInst)
+	if err != nil {
+		return nil, errors.New("error marshaling COSE public key to X509. " + err.Error())
+	}
+
+	return publicKeyX509, nil
 }
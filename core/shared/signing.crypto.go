@@ -3,11 +3,13 @@ package fdoshared
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"math/big"
@@ -60,14 +62,24 @@ func VerifyCertificateChain(chain []X509CertificateBytes) ([]*x509.Certificate,
 	return finalChain, nil
 }
 
-func VerifyCoseSignatureWithCertificate(coseSig CoseSignature, pkType FdoPkType, certs []X509CertificateBytes) error {
-	newPubKey := FdoPublicKey{
-		PkType: pkType,
-		PkEnc:  X5CHAIN,
-		PkBody: certs,
+// Verifies device attestation signature with the OVDevCertChain leaf. The chain is checked with the device chain policy
+func VerifyCoseSignatureWithCertificate(coseSig CoseSignature, sgType DeviceSgType, certs []X509CertificateBytes, policy CertChainPolicy) error {
+	pkType, ok := SgTypeToFdoPkType[sgType]
+	if !ok {
+		return fmt.Errorf("sgType %d is not supported", sgType)
 	}
 
-	return VerifyCoseSignature(coseSig, newPubKey)
+	verifiedChain, err := policy.Verify(certs, sgType)
+	if err != nil {
+		return err
+	}
+
+	coseSigPayloadBytes, err := NewSig1Payload(coseSig.Protected, coseSig.Payload)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignature(coseSigPayloadBytes, coseSig.Signature, verifiedChain[0].PublicKey, pkType)
 }
 
 func VerifySignature(payload []byte, signature []byte, publicKeyInst interface{}, pkType FdoPkType) error {
@@ -118,88 +130,160 @@ func VerifySignature(payload []byte, signature []byte, publicKeyInst interface{}
 		} else {
 			return nil
 		}
+	case SECP521R1:
+		err := checkAlgExtension("ES512")
+		if err != nil {
+			return err
+		}
+
+		if len(signature) != SECP521R1_SIG_LEN {
+			return errors.New("for ES512, signature must be 132 bytes long")
+		}
+
+		pubKeyCasted, ok := publicKeyInst.(*ecdsa.PublicKey)
+		if !ok || pubKeyCasted.Curve.Params().Name != "P-521" {
+			return errors.New("error verifying SECP521R1 cose signature. Could not cast pubKey instance to P-521 ECDSA PubKey")
+		}
+
+		payloadHash := sha512.Sum512(payload)
+
+		r := new(big.Int)
+		r.SetBytes(signature[0:66])
+
+		s := new(big.Int)
+		s.SetBytes(signature[66:132])
+
+		if !ecdsa.Verify(pubKeyCasted, payloadHash[:], r, s) {
+			return errors.New("failed to verify signature")
+		} else {
+			return nil
+		}
+	case ED25519:
+		err := checkAlgExtension("EdDSA")
+		if err != nil {
+			return err
+		}
+
+		if len(signature) != ED25519_SIG_LEN {
+			return errors.New("for EdDSA, signature must be 64 bytes long")
+		}
+
+		pubKeyCasted, ok := publicKeyInst.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("error verifying ED25519 cose signature. Could not cast pubKey instance to Ed25519 PubKey")
+		}
+
+		if !ed25519.Verify(pubKeyCasted, payload, signature) {
+			return errors.New("failed to verify signature")
+		} else {
+			return nil
+		}
 	case RSAPKCS, RSA2048RESTR:
 		rsaPubKeyCasted, ok := publicKeyInst.(*rsa.PublicKey)
 		if !ok {
 			return errors.New("error verifying RS2RSAPKCS56 cose signature. Could not cast pubKey instance to RSA PubKey")
 		}
 
-		rsaPubKeyLen := len(rsaPubKeyCasted.N.Bytes())
-
-		var hashingAlg crypto.Hash
-		var payloadHash []byte
-		if rsaPubKeyLen*8 == 2048 {
-			hashingAlg = crypto.SHA256
-			sPayloadHash := sha256.Sum256(payload)
-			payloadHash = sPayloadHash[:]
-		} else if rsaPubKeyLen*8 == 3072 {
-			hashingAlg = crypto.SHA384
-			sPayloadHash := sha512.Sum384(payload)
-			payloadHash = sPayloadHash[:]
-		} else {
-			return fmt.Errorf("%d is an unsupported public key length for RSAPKCS", rsaPubKeyLen*8)
+		hashingAlg, payloadHash, err := rsaPayloadHash(payload, rsaPubKeyCasted)
+		if err != nil {
+			return errors.New("error verifying RSAPKCS cose signature. " + err.Error())
 		}
 
 		return rsa.VerifyPKCS1v15(rsaPubKeyCasted, hashingAlg, payloadHash, signature)
 	case RSAPSS:
-		return errors.New("RSAPSS is not currently implemented")
+		rsaPubKeyCasted, ok := publicKeyInst.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("error verifying RSAPSS cose signature. Could not cast pubKey instance to RSA PubKey")
+		}
+
+		hashingAlg, payloadHash, err := rsaPayloadHash(payload, rsaPubKeyCasted)
+		if err != nil {
+			return errors.New("error verifying RSAPSS cose signature. " + err.Error())
+		}
+
+		return rsa.VerifyPSS(rsaPubKeyCasted, hashingAlg, payloadHash, signature, &rsaPSSOptions)
 	default:
 		return fmt.Errorf("PublicKey type %d is not supported", pkType)
 	}
 }
 
-func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
-	coseSigPayloadBytes, err := NewSig1Payload(coseSig.Protected, coseSig.Payload)
-	if err != nil {
-		return err
+// COSE RFC 8230: PSS salt length is the length of the hash
+var rsaPSSOptions = rsa.PSSOptions{
+	SaltLength: rsa.PSSSaltLengthEqualsHash,
+}
+
+// RSA signature hash is defined by the key length: SHA256 for 2048, SHA384 for 3072
+func rsaPayloadHash(payload []byte, rsaPubKey *rsa.PublicKey) (crypto.Hash, []byte, error) {
+	rsaPubKeyLen := len(rsaPubKey.N.Bytes())
+
+	if rsaPubKeyLen*8 == 2048 {
+		payloadHash := sha256.Sum256(payload)
+		return crypto.SHA256, payloadHash[:], nil
+	} else if rsaPubKeyLen*8 == 3072 {
+		payloadHash := sha512.Sum384(payload)
+		return crypto.SHA384, payloadHash[:], nil
+	} else {
+		return 0, nil, fmt.Errorf("%d is an unsupported RSA public key length", rsaPubKeyLen*8)
 	}
+}
 
+// Returns crypto public key for any of the supported encodings. X5CHAIN chain is verified, and the leaf certificate key is returned
+func ExtractPublicKey(publicKey FdoPublicKey) (interface{}, error) {
 	switch publicKey.PkEnc {
 	case Crypto:
-		return errors.New("ePID signatures are not currently supported")
+		return nil, errors.New("ePID public keys are not currently supported")
 	case X509:
-		publicKeyCasted, ok := publicKey.PkBody.([]byte)
-		if !ok {
-			return errors.New("failed to cast pubkey PkBody to []byte")
+		publicKeyX509, err := publicKey.GetX509()
+		if err != nil {
+			return nil, err
 		}
 
-		pubKeyInst, err := x509.ParsePKIXPublicKey(publicKeyCasted)
+		pubKeyInst, err := x509.ParsePKIXPublicKey(publicKeyX509)
 		if err != nil {
-			return errors.New("error parsing PKIX X509 Public Key. " + err.Error())
+			return nil, errors.New("error parsing PKIX X509 Public Key. " + err.Error())
 		}
 
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, pubKeyInst, publicKey.PkType)
+		return pubKeyInst, nil
 	case X5CHAIN:
-		decCertBytes, ok := publicKey.PkBody.([]X509CertificateBytes)
-		if !ok {
-			return errors.New("failed to cast pubkey PkBody to []X509CertificateBytes")
+		decCertBytes, err := publicKey.GetX5Chain()
+		if err != nil {
+			return nil, err
 		}
 
 		successChain, err := VerifyCertificateChain(decCertBytes)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		leafCert := successChain[0]
-
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, leafCert.PublicKey, publicKey.PkType)
-
+		return successChain[0].PublicKey, nil
 	case COSEKEY:
-		publicKeyX509, err := CoseKeyToX509(publicKey)
+		cosePubKey, err := publicKey.GetCosePublicKey()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		pubKeyInst, err := x509.ParsePKIXPublicKey(publicKeyX509)
-		if err != nil {
-			return errors.New("error parsing PKIX X509 Public Key. " + err.Error())
-		}
+		return cosePubKey.GetPublicKey()
+	default:
+		return nil, fmt.Errorf("PublicKey encoding %d is not supported", publicKey.PkEnc)
+	}
+}
+
+func VerifyCoseSignature(coseSig CoseSignature, publicKey FdoPublicKey) error {
+	coseSigPayloadBytes, err := NewSig1Payload(coseSig.Protected, coseSig.Payload)
+	if err != nil {
+		return err
+	}
 
-		return VerifySignature(coseSigPayloadBytes, coseSig.Signature, pubKeyInst, publicKey.PkType)
+	if publicKey.PkEnc == Crypto {
+		return errors.New("ePID signatures are not currently supported")
+	}
 
-	default:
-		return fmt.Errorf("PublicKey encoding %d is not supported", publicKey.PkEnc)
+	pubKeyInst, err := ExtractPublicKey(publicKey)
+	if err != nil {
+		return err
 	}
+
+	return VerifySignature(coseSigPayloadBytes, coseSig.Signature, pubKeyInst, publicKey.PkType)
 }
 
 func ExtractPrivateKey(privateKeyDer []byte) (interface{}, error) {
@@ -208,7 +292,7 @@ func ExtractPrivateKey(privateKeyDer []byte) (interface{}, error) {
 	}
 	if key, err := x509.ParsePKCS8PrivateKey(privateKeyDer); err == nil {
 		switch key := key.(type) {
-		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
 			return key, nil
 		default:
 			return nil, fmt.Errorf("found unknown private key type in PKCS#8 wrapping")
@@ -217,6 +301,9 @@ func ExtractPrivateKey(privateKeyDer []byte) (interface{}, error) {
 	if key, err := x509.ParseECPrivateKey(privateKeyDer); err == nil {
 		return key, nil
 	}
+	if key, err := DecodeEpidMemberKey(privateKeyDer); err == nil {
+		return key, nil
+	}
 	return nil, fmt.Errorf("failed to parse private key")
 }
 
@@ -250,6 +337,36 @@ func i2osp(coeff []byte, expecteCoeffLen int) ([]byte, error) {
 	return coeff, nil
 }
 
+// Signs ECDSA digest, and converts ASN.1 signature to the COSE fixed length R|S
+func signECDSA(signer crypto.Signer, digest []byte, hashingAlg crypto.Hash, coeffLength int) ([]byte, error) {
+	asn1Signature, err := signer.Sign(rand.Reader, digest, hashingAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ecdsaSignature struct {
+		R, S *big.Int
+	}
+
+	_, err = asn1.Unmarshal(asn1Signature, &ecdsaSignature)
+	if err != nil {
+		return nil, errors.New("error decoding ECDSA signature. " + err.Error())
+	}
+
+	Rb, err := i2osp(ecdsaSignature.R.Bytes(), coeffLength)
+	if err != nil {
+		return nil, err
+	}
+
+	Sb, err := i2osp(ecdsaSignature.S.Bytes(), coeffLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(Rb, Sb...), nil
+}
+
+// Owner keys are crypto.Signer, so they can be software keys or keys in the PKCS#11 token. EPID keys are *EpidMemberKey
 func GenerateCoseSignature(payload []byte, protected ProtectedHeader, unprotected UnprotectedHeader, privateKeyInterface interface{}, sgType DeviceSgType) (*CoseSignature, error) {
 	protected.Alg = GetIntRef(int(sgType))
 
@@ -262,93 +379,140 @@ func GenerateCoseSignature(payload []byte, protected ProtectedHeader, unprotecte
 	var signature []byte
 
 	switch sgType {
-	case StSECP256R1:
+	case StSECP256R1, StSECP384R1, StSECP521R1:
+		algName := "ES256"
+		curveName := "P-256"
+		hashingAlg := crypto.SHA256
 		coeffLength := 32
-		payloadHash := sha256.Sum256(coseSigPayloadBytes)
-		privKeyCasted, ok := privateKeyInterface.(*ecdsa.PrivateKey)
-		if !ok {
-			return nil, errors.New("error generating ES256 cose signature. Could not cast privKey instance to ECDSA PrivateKey")
+		if sgType == StSECP384R1 {
+			algName = "ES384"
+			curveName = "P-384"
+			hashingAlg = crypto.SHA384
+			coeffLength = 48
+		} else if sgType == StSECP521R1 {
+			algName = "ES512"
+			curveName = "P-521"
+			hashingAlg = crypto.SHA512
+			coeffLength = 66
+
+			err := checkAlgExtension(algName)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		if privKeyCasted.PublicKey.Curve.Params().Name != "P-256" {
-			return nil, errors.New("error generating ES256 cose signature. Private key curve is not P-256")
+		signer, ok := privateKeyInterface.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("error generating %s cose signature. Private key is not a signer", algName)
 		}
 
-		r, s, err := ecdsa.Sign(rand.Reader, privKeyCasted, payloadHash[:])
-		if err != nil {
-			return nil, errors.New("error generating ES256 cose signature. " + err.Error())
+		pubKeyCasted, ok := signer.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("error generating %s cose signature. Could not cast public key to ECDSA PublicKey", algName)
 		}
 
-		Rb, err := i2osp(r.Bytes(), coeffLength)
-		if err != nil {
-			return nil, errors.New("error generating ES256 cose signature. " + err.Error())
+		if pubKeyCasted.Curve.Params().Name != curveName {
+			return nil, fmt.Errorf("error generating %s cose signature. Private key curve is not %s", algName, curveName)
 		}
 
-		Sb, err := i2osp(s.Bytes(), coeffLength)
+		hasher := hashingAlg.New()
+		hasher.Write(coseSigPayloadBytes)
+
+		tSignature, err := signECDSA(signer, hasher.Sum(nil), hashingAlg, coeffLength)
 		if err != nil {
-			return nil, errors.New("error generating ES256 cose signature. " + err.Error())
+			return nil, fmt.Errorf("error generating %s cose signature. %s", algName, err.Error())
 		}
 
-		signature = append(Rb, Sb...)
-	case StSECP384R1:
-		coeffLength := 48
-		payloadHash := sha512.Sum384(coseSigPayloadBytes)
+		signature = tSignature
+	case StRSA2048, StRSA3072:
+		algName := "RSA2048"
+		hashingAlg := crypto.SHA256
+		if sgType == StRSA3072 {
+			algName = "RSA3072"
+			hashingAlg = crypto.SHA384
+		}
 
-		privKeyCasted, ok := privateKeyInterface.(*ecdsa.PrivateKey)
+		signer, ok := privateKeyInterface.(crypto.Signer)
 		if !ok {
-			return nil, errors.New("error generating ES384 cose signature. Could not cast privKey instance to ECDSA PrivateKey")
+			return nil, fmt.Errorf("error generating %s cose signature. Private key is not a signer", algName)
 		}
 
-		if privKeyCasted.PublicKey.Curve.Params().Name != "P-384" {
-			return nil, errors.New("error generating ES384 cose signature. Private key curve is not P-384")
+		if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("error generating %s cose signature. Could not cast public key to RSA PublicKey", algName)
 		}
 
-		r, s, err := ecdsa.Sign(rand.Reader, privKeyCasted, payloadHash[:])
+		hasher := hashingAlg.New()
+		hasher.Write(coseSigPayloadBytes)
+
+		tSignature, err := signer.Sign(rand.Reader, hasher.Sum(nil), hashingAlg)
 		if err != nil {
-			return nil, errors.New("error generating ES384 cose signature. " + err.Error())
+			return nil, fmt.Errorf("error generating %s cose signature. %s", algName, err.Error())
 		}
 
-		Rb, err := i2osp(r.Bytes(), coeffLength)
+		signature = tSignature
+	case StRSAPSS2048, StRSAPSS3072:
+		signer, ok := privateKeyInterface.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("error generating RSAPSS cose signature. Private key is not a signer")
+		}
+
+		rsaPubKey, ok := signer.Public().(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("error generating RSAPSS cose signature. Could not cast public key to RSA PublicKey")
+		}
+
+		hashingAlg, payloadHash, err := rsaPayloadHash(coseSigPayloadBytes, rsaPubKey)
 		if err != nil {
-			return nil, errors.New("error generating ES256 cose signature. " + err.Error())
+			return nil, errors.New("error generating RSAPSS cose signature. " + err.Error())
+		}
+
+		if hashingAlg != CoseAlgToHash[CoseAlg(sgType)] {
+			return nil, fmt.Errorf("error generating RSAPSS cose signature. Private key length does not match alg %d", sgType)
 		}
 
-		Sb, err := i2osp(s.Bytes(), coeffLength)
+		tSignature, err := signer.Sign(rand.Reader, payloadHash, &rsa.PSSOptions{
+			SaltLength: rsaPSSOptions.SaltLength,
+			Hash:       hashingAlg,
+		})
 		if err != nil {
-			return nil, errors.New("error generating ES256 cose signature. " + err.Error())
+			return nil, errors.New("error generating RSAPSS cose signature. " + err.Error())
 		}
 
-		signature = append(Rb, Sb...)
-	case StRSA3072:
-		payloadHash := sha512.Sum384(coseSigPayloadBytes)
+		signature = tSignature
+	case StED25519:
+		err := checkAlgExtension("EdDSA")
+		if err != nil {
+			return nil, err
+		}
 
-		privKeyCasted, ok := privateKeyInterface.(*rsa.PrivateKey)
+		signer, ok := privateKeyInterface.(crypto.Signer)
 		if !ok {
-			return nil, errors.New("error generating RSA3072 cose signature. Could not cast privKey instance to RSA PrivateKey")
+			return nil, errors.New("error generating EdDSA cose signature. Private key is not a signer")
+		}
+
+		if _, ok := signer.Public().(ed25519.PublicKey); !ok {
+			return nil, errors.New("error generating EdDSA cose signature. Could not cast public key to Ed25519 PublicKey")
 		}
 
-		tSignature, err := rsa.SignPKCS1v15(rand.Reader, privKeyCasted, crypto.SHA384, payloadHash[:])
+		// Ed25519 signs the message itself, not the digest
+		tSignature, err := signer.Sign(rand.Reader, coseSigPayloadBytes, crypto.Hash(0))
 		if err != nil {
-			return nil, errors.New("error generating RSA3072 cose signature. " + err.Error())
+			return nil, errors.New("error generating EdDSA cose signature. " + err.Error())
 		}
 
 		signature = tSignature
-	case StRSA2048:
-		payloadHash := sha256.Sum256(coseSigPayloadBytes)
-
-		privKeyCasted, ok := privateKeyInterface.(*rsa.PrivateKey)
+	case StEPID10, StEPID11:
+		privKeyCasted, ok := privateKeyInterface.(*EpidMemberKey)
 		if !ok {
-			return nil, errors.New("error generating RSA2048 cose signature. Could not cast privKey instance to RSA PrivateKey")
+			return nil, errors.New("error generating EPID cose signature. Could not cast privKey instance to EPID member key")
 		}
 
-		tSignature, err := rsa.SignPKCS1v15(rand.Reader, privKeyCasted, crypto.SHA256, payloadHash[:])
+		tSignature, err := EpidSign(sgType, *privKeyCasted, coseSigPayloadBytes)
 		if err != nil {
-			return nil, errors.New("error generating RSA2048 cose signature. " + err.Error())
+			return nil, errors.New("error generating EPID cose signature. " + err.Error())
 		}
 
 		signature = tSignature
-	case StEPID10, StEPID11:
-		return nil, errors.New("StEPID10/StEPID11 is not currently implemented")
 	default:
 		return nil, fmt.Errorf("alg %d is not supported", sgType)
 	}
@@ -1,6 +1,8 @@
 package fdoshared
 
 import (
+	"bytes"
+	"crypto/x509"
 	"testing"
 )
 
@@ -70,3 +72,137 @@ func TestGenerateCoseSignature(t *testing.T) {
 		t.Fatalf("failed to verify COSE signature: %v", err)
 	}
 }
+
+func TestGenerateCoseSignature_RSAPSS(t *testing.T) {
+	payload := []byte("test payload")
+
+	for _, sgType := range []DeviceSgType{StRSAPSS2048, StRSAPSS3072} {
+		privKey, pubKey, err := GenerateVoucherKeypair(sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate private key: %v", sgType, err)
+		}
+
+		if pubKey.PkType != RSAPSS {
+			t.Fatalf("%d: expected pkType RSAPSS, got %d", sgType, pubKey.PkType)
+		}
+
+		privKeySgType, err := GetPrivateKeySgType(pubKey.PkType, privKey)
+		if err != nil || privKeySgType != sgType {
+			t.Fatalf("%d: expected private key sgType %d, got %d. %v", sgType, sgType, privKeySgType, err)
+		}
+
+		coseSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, privKey, sgType)
+		if err != nil {
+			t.Fatalf("%d: failed to generate COSE signature: %v", sgType, err)
+		}
+
+		err = VerifyCoseSignature(*coseSig, *pubKey)
+		if err != nil {
+			t.Fatalf("%d: failed to verify COSE signature: %v", sgType, err)
+		}
+
+		// PKCS1 v1.5 signature must not pass PSS verification
+		pkcsSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, privKey, PkToSgType[RSAPKCS])
+		if err == nil && VerifyCoseSignature(*pkcsSig, *pubKey) == nil {
+			t.Fatalf("%d: PKCS1 v1.5 signature passed PSS verification", sgType)
+		}
+	}
+
+	// PS384 requires 3072 key
+	privKey, _, err := GenerateVoucherKeypair(StRSAPSS2048)
+	if err != nil {
+		t.Fatalf("failed to generate private key: %v", err)
+	}
+
+	_, err = GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, privKey, StRSAPSS3072)
+	if err == nil {
+		t.Fatalf("expected PS384 signature with 2048 key to fail")
+	}
+}
+
+func TestVerifyCoseSignature_PkEncodings(t *testing.T) {
+	payload := []byte("test payload")
+
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1, StRSA2048, StRSAPSS3072} {
+		for _, pkEnc := range FdoPkEnc_List {
+			privKey, pubKey, err := GenerateVoucherKeypairWithEncoding(sgType, pkEnc)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to generate keypair: %v", sgType, pkEnc, err)
+			}
+
+			coseSig, err := GenerateCoseSignature(payload, ProtectedHeader{}, UnprotectedHeader{}, privKey, sgType)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to generate COSE signature: %v", sgType, pkEnc, err)
+			}
+
+			// Public key as received over the wire
+			pubKeyBytes, err := CborCust.Marshal(pubKey)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to encode public key: %v", sgType, pkEnc, err)
+			}
+
+			var decodedPubKey FdoPublicKey
+			err = CborCust.Unmarshal(pubKeyBytes, &decodedPubKey)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to decode public key: %v", sgType, pkEnc, err)
+			}
+
+			err = VerifyCoseSignature(*coseSig, decodedPubKey)
+			if err != nil {
+				t.Fatalf("%d/%d: failed to verify COSE signature: %v", sgType, pkEnc, err)
+			}
+
+			coseSig.Payload = []byte("other payload")
+			err = VerifyCoseSignature(*coseSig, decodedPubKey)
+			if err == nil {
+				t.Fatalf("%d/%d: signature over modified payload passed verification", sgType, pkEnc)
+			}
+		}
+	}
+}
+
+func TestExtractPublicKey_BadX5Chain(t *testing.T) {
+	_, pubKey, err := GenerateVoucherKeypairWithEncoding(StSECP256R1, X5CHAIN)
+	if err != nil {
+		t.Fatalf("failed to generate keypair: %v", err)
+	}
+
+	chain := pubKey.PkBody.([]X509CertificateBytes)
+	_, otherPubKey, err := GenerateVoucherKeypairWithEncoding(StSECP256R1, X5CHAIN)
+	if err != nil {
+		t.Fatalf("failed to generate keypair: %v", err)
+	}
+
+	// Leaf only
+	pubKey.PkBody = []X509CertificateBytes{chain[0]}
+	_, err = ExtractPublicKey(*pubKey)
+	if err == nil {
+		t.Fatalf("chain without root passed verification")
+	}
+
+	// Leaf with another root
+	pubKey.PkBody = []X509CertificateBytes{chain[0], otherPubKey.PkBody.([]X509CertificateBytes)[0]}
+	_, err = ExtractPublicKey(*pubKey)
+	if err == nil {
+		t.Fatalf("chain with untrusted root passed verification")
+	}
+}
+
+func TestCoseKeyToX509(t *testing.T) {
+	for _, sgType := range []DeviceSgType{StSECP256R1, StSECP384R1, StRSA3072} {
+		privKey, pubKey, err := GenerateVoucherKeypairWithEncoding(sgType, COSEKEY)
+		if err != nil {
+			t.Fatalf("%d: failed to generate keypair: %v", sgType, err)
+		}
+
+		publicKeyX509, err := CoseKeyToX509(*pubKey)
+		if err != nil {
+			t.Fatalf("%d: failed to convert COSE key: %v", sgType, err)
+		}
+
+		expectedX509, _ := x509.MarshalPKIXPublicKey(CastPublicFromPrivate(privKey))
+		if !bytes.Equal(publicKeyX509, expectedX509) {
+			t.Fatalf("%d: converted COSE key does not match", sgType)
+		}
+	}
+}
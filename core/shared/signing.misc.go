@@ -2,6 +2,10 @@ package fdoshared
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 )
@@ -86,6 +90,10 @@ const (
 	RSAPSS       FdoPkType = 6  // RSA key, PSS
 	SECP256R1    FdoPkType = 10 // ECDSA secp256r1 = NIST-P-256 = prime256v1
 	SECP384R1    FdoPkType = 11 // ECDSA secp384r1 = NIST-P-384
+
+	// Algorithm extensions. Not assigned by FDO 1.1
+	SECP521R1 FdoPkType = 12 // ECDSA secp521r1 = NIST-P-521
+	ED25519   FdoPkType = 13 // EdDSA Ed25519
 )
 
 var FdoPkType_List []FdoPkType = []FdoPkType{
@@ -97,21 +105,27 @@ var FdoPkType_List []FdoPkType = []FdoPkType{
 }
 
 var SgTypeToFdoPkType = map[DeviceSgType]FdoPkType{
-	StSECP256R1: SECP256R1,
-	StSECP384R1: SECP384R1,
-	StRSA2048:   RSA2048RESTR,
-	StRSA3072:   RSAPKCS,
+	StSECP256R1:  SECP256R1,
+	StSECP384R1:  SECP384R1,
+	StRSA2048:    RSA2048RESTR,
+	StRSA3072:    RSAPKCS,
+	StRSAPSS2048: RSAPSS,
+	StRSAPSS3072: RSAPSS,
+	StSECP521R1:  SECP521R1,
+	StED25519:    ED25519,
 }
 
 const (
 	SECP256R1_SIG_LEN int = 64
 	SECP384R1_SIG_LEN int = 96
+	SECP521R1_SIG_LEN int = 132
+	ED25519_SIG_LEN   int = 64
 )
 
 type FdoPkEnc uint8
 
 const (
-	Crypto  FdoPkEnc = 0 // TODO: EPID
+	Crypto  FdoPkEnc = 0 // EPID group public key
 	X509    FdoPkEnc = 1
 	X5CHAIN FdoPkEnc = 2
 	COSEKEY FdoPkEnc = 3
@@ -131,6 +145,55 @@ type FdoPublicKey struct {
 	PkBody interface{}
 }
 
+// After CBOR decoding PkBody is a generic interface, so it is re-encoded into the encoding specific structure
+func (h FdoPublicKey) decodePkBody(pkBodyInst interface{}) error {
+	pkBodyBytes, err := CborCust.Marshal(h.PkBody)
+	if err != nil {
+		return errors.New("error encoding public key body. " + err.Error())
+	}
+
+	err = CborCust.Unmarshal(pkBodyBytes, pkBodyInst)
+	if err != nil {
+		return fmt.Errorf("error decoding public key body for encoding %d. %s", h.PkEnc, err.Error())
+	}
+
+	return nil
+}
+
+func (h FdoPublicKey) GetX509() ([]byte, error) {
+	if h.PkEnc != X509 {
+		return nil, fmt.Errorf("expected X509 public key encoding. Got %d", h.PkEnc)
+	}
+
+	var publicKeyX509 []byte
+	err := h.decodePkBody(&publicKeyX509)
+	return publicKeyX509, err
+}
+
+func (h FdoPublicKey) GetX5Chain() ([]X509CertificateBytes, error) {
+	if h.PkEnc != X5CHAIN {
+		return nil, fmt.Errorf("expected X5CHAIN public key encoding. Got %d", h.PkEnc)
+	}
+
+	var chain []X509CertificateBytes
+	err := h.decodePkBody(&chain)
+	return chain, err
+}
+
+func (h FdoPublicKey) GetCosePublicKey() (*CosePublicKey, error) {
+	if h.PkEnc != COSEKEY {
+		return nil, fmt.Errorf("expected COSEKEY public key encoding. Got %d", h.PkEnc)
+	}
+
+	var cosePubKey CosePublicKey
+	err := h.decodePkBody(&cosePubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cosePubKey, nil
+}
+
 func (h FdoPublicKey) Equal(bKey FdoPublicKey) error {
 	aBytes, err := CborCust.Marshal(h)
 	if err != nil {
@@ -158,6 +221,10 @@ const (
 	IANA_ES384 IanaCoseAlg = -35
 	IANA_RS256 IanaCoseAlg = -257
 	IANA_RS384 IanaCoseAlg = -258
+	IANA_PS256 IanaCoseAlg = -37
+	IANA_PS384 IanaCoseAlg = -38
+	IANA_ES512 IanaCoseAlg = -36
+	IANA_EdDSA IanaCoseAlg = -8
 )
 
 type DeviceSgType int
@@ -169,6 +236,13 @@ const (
 	StRSA3072   DeviceSgType = -258
 	StEPID10    DeviceSgType = 90
 	StEPID11    DeviceSgType = 91
+
+	StRSAPSS2048 DeviceSgType = -37 // PS256
+	StRSAPSS3072 DeviceSgType = -38 // PS384
+
+	// Algorithm extensions
+	StSECP521R1 DeviceSgType = -36 // ES512
+	StED25519   DeviceSgType = -8  // EdDSA
 )
 
 var SgTypeList []DeviceSgType = []DeviceSgType{
@@ -176,15 +250,17 @@ var SgTypeList []DeviceSgType = []DeviceSgType{
 	StSECP384R1,
 	StRSA2048,
 	StRSA3072,
+	StRSAPSS2048,
+	StRSAPSS3072,
 	// StEPID10, // TODO
 	// StEPID11,
 }
 
+// Device sgTypes of the seeded virtual devices used in the conformance runs.
+// EPID is excluded, as the virtual EPID devices use this tool's EPID-style scheme, that other implementations can not verify
 var DeviceSgTypeList []DeviceSgType = []DeviceSgType{
 	StSECP256R1,
 	StSECP384R1,
-	// StEPID10, // TODO
-	// StEPID11,
 }
 
 // Maps DEVICE supported SG type, to what OVEntry must use to, since device may not be able to handle some owner algorithms
@@ -195,6 +271,12 @@ var SgType_OwnerToDeviceAttestation = map[DeviceSgType]DeviceSgType{
 	StRSA3072:   StSECP384R1,
 	StEPID10:    StEPID10,
 	StEPID11:    StEPID11,
+
+	StRSAPSS2048: StSECP256R1,
+	StRSAPSS3072: StSECP384R1,
+
+	StSECP521R1: StSECP521R1,
+	StED25519:   StED25519,
 }
 
 // var SgTypeToIana = map[DeviceSgType]IanaCoseAlg{
@@ -226,7 +308,19 @@ func GetDeviceSgType(pkType FdoPkType, hashType HashType) (DeviceSgType, error)
 		return StSECP256R1, nil
 	case SECP384R1:
 		return StSECP384R1, nil
-	case RSA2048RESTR, RSAPKCS, RSAPSS:
+	case SECP521R1:
+		return StSECP521R1, nil
+	case ED25519:
+		return StED25519, nil
+	case RSAPSS:
+		if hashType == HASH_SHA256 {
+			return StRSAPSS2048, nil
+		} else if hashType == HASH_SHA384 {
+			return StRSAPSS3072, nil
+		} else {
+			return 0, fmt.Errorf("for RSAPSS: %d is an unsupported hash type", hashType)
+		}
+	case RSA2048RESTR, RSAPKCS:
 		if hashType == HASH_SHA256 {
 			return StRSA2048, nil
 		} else if hashType == HASH_SHA384 {
@@ -266,6 +360,36 @@ var SgTypeInfoMap = map[DeviceSgType]SgTypeInfo{
 		HashType: HASH_SHA384,
 		HmacType: HASH_HMAC_SHA384,
 	},
+	StRSAPSS2048: {
+		PkType:   RSAPSS,
+		HashType: HASH_SHA256,
+		HmacType: HASH_HMAC_SHA256,
+	},
+	StRSAPSS3072: {
+		PkType:   RSAPSS,
+		HashType: HASH_SHA384,
+		HmacType: HASH_HMAC_SHA384,
+	},
+	StSECP521R1: {
+		PkType:   SECP521R1,
+		HashType: HASH_SHA512,
+		HmacType: HASH_HMAC_SHA512,
+	},
+	// Ed25519 is 128 bit security level, as P-256
+	StED25519: {
+		PkType:   ED25519,
+		HashType: HASH_SHA256,
+		HmacType: HASH_HMAC_SHA256,
+	},
+	// EPID has no owner public key type
+	StEPID10: {
+		HashType: HASH_SHA256,
+		HmacType: HASH_HMAC_SHA256,
+	},
+	StEPID11: {
+		HashType: HASH_SHA256,
+		HmacType: HASH_HMAC_SHA256,
+	},
 }
 
 var PkToSgType = map[FdoPkType]DeviceSgType{
@@ -273,4 +397,49 @@ var PkToSgType = map[FdoPkType]DeviceSgType{
 	SECP384R1:    StSECP384R1,
 	RSA2048RESTR: StRSA2048,
 	RSAPKCS:      StRSA3072,
+	SECP521R1:    StSECP521R1,
+	ED25519:      StED25519,
+}
+
+// Returns SgType for signing with the private key. RSAPSS is used for both 2048 and 3072 keys, so the SgType depends on the key size
+func GetPrivateKeySgType(pkType FdoPkType, privateKeyInst interface{}) (DeviceSgType, error) {
+	signer, ok := privateKeyInst.(crypto.Signer)
+	if !ok {
+		return 0, errors.New("unsupported private key type")
+	}
+
+	switch publicKey := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve.Params().Name {
+		case "P-256":
+			return StSECP256R1, nil
+		case "P-384":
+			return StSECP384R1, nil
+		case "P-521":
+			return StSECP521R1, nil
+		default:
+			return 0, fmt.Errorf("%s is an unsupported curve", publicKey.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return StED25519, nil
+	case *rsa.PublicKey:
+		keySize := publicKey.N.BitLen()
+		if keySize != 2048 && keySize != 3072 {
+			return 0, fmt.Errorf("%d is an unsupported RSA key length", keySize)
+		}
+
+		if pkType == RSAPSS {
+			if keySize == 2048 {
+				return StRSAPSS2048, nil
+			}
+			return StRSAPSS3072, nil
+		}
+
+		if keySize == 2048 {
+			return StRSA2048, nil
+		}
+		return StRSA3072, nil
+	default:
+		return 0, errors.New("unsupported private key type")
+	}
 }
@@ -1,10 +1,29 @@
 package testcom
 
+import fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+
 type FDOTestState struct {
-	_      struct{}  `cbor:",toarray"`
-	Passed bool      `json:"passed"`
-	Error  string    `json:"error"`
-	TestID FDOTestID `json:"testId"`
+	_        struct{}  `cbor:",toarray"`
+	Passed   bool      `json:"passed"`
+	Error    string    `json:"error"`
+	TestID   FDOTestID `json:"testId"`
+	FuzzSeed int64     `json:"fuzzSeed,omitempty"`
+}
+
+// FDOTestState before FuzzSeed. Kept for the DB migrations
+type FDOTestStateV1 struct {
+	_      struct{} `cbor:",toarray"`
+	Passed bool
+	Error  string
+	TestID FDOTestID
+}
+
+func (h FDOTestStateV1) Migrate() FDOTestState {
+	return FDOTestState{
+		Passed: h.Passed,
+		Error:  h.Error,
+		TestID: h.TestID,
+	}
 }
 
 func NewSuccessTestState(testId FDOTestID) FDOTestState {
@@ -21,3 +40,15 @@ func NewFailTestState(testId FDOTestID, errorMsg string) FDOTestState {
 		TestID: testId,
 	}
 }
+
+// Records fuzzing seed, so that failed test can be replayed with the same mutation
+func (h *FDOTestState) SetFuzzMutation(mutation *fdoshared.Conf_CborMutation) {
+	if mutation == nil {
+		return
+	}
+
+	h.FuzzSeed = mutation.Seed
+	if !h.Passed {
+		h.Error = h.Error + " Mutation: " + mutation.String()
+	}
+}
@@ -7,19 +7,19 @@ import (
 	"log"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
 type ListenerTestDB struct {
-	db               *badger.DB
+	db               kv.Store
 	prefix           []byte
 	mapperGuidPrefix []byte
 	ttl              int
 }
 
-func NewListenerTestDB(db *badger.DB) *ListenerTestDB {
+func NewListenerTestDB(db kv.Store) *ListenerTestDB {
 	return &ListenerTestDB{
 		db:               db,
 		prefix:           []byte("lstdb-"),
@@ -37,26 +37,12 @@ func (h *ListenerTestDB) getMappingEntryId(guid fdoshared.FdoGuid) []byte {
 }
 
 func (h *ListenerTestDB) Save(reqListener listenertestsdeps.RequestListenerInst) error {
-	structBytes, err := fdoshared.CborCust.Marshal(reqListener)
-	if err != nil {
-		return errors.New("Failed to marshal listener entry." + err.Error())
-	}
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(h.getEntryId(reqListener.Uuid), structBytes).WithTTL(time.Second * time.Duration(h.ttl))
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating listener db entry instance." + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, h.getEntryId(reqListener.Uuid), reqListener, time.Second*time.Duration(h.ttl))
 	if err != nil {
 		return errors.New("Failed saving listener entry." + err.Error())
 	}
 
-	h.SaveMapping(reqListener.Guid, reqListener.Uuid)
+	err = h.SaveMapping(reqListener.Guid, reqListener.Uuid)
 	if err != nil {
 		return errors.New("Failed saving listener entry guid mapping." + err.Error())
 	}
@@ -65,21 +51,7 @@ func (h *ListenerTestDB) Save(reqListener listenertestsdeps.RequestListenerInst)
 }
 
 func (h *ListenerTestDB) Update(reqListener *listenertestsdeps.RequestListenerInst) error {
-	structBytes, err := fdoshared.CborCust.Marshal(reqListener)
-	if err != nil {
-		return errors.New("Failed to marshal listener entry." + err.Error())
-	}
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(h.getEntryId(reqListener.Uuid), structBytes).WithTTL(time.Second * time.Duration(h.ttl))
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating rvte db entry instance." + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, h.getEntryId(reqListener.Uuid), reqListener, time.Second*time.Duration(h.ttl))
 	if err != nil {
 		return errors.New("Failed saving rvte entry." + err.Error())
 	}
@@ -88,40 +60,18 @@ func (h *ListenerTestDB) Update(reqListener *listenertestsdeps.RequestListenerIn
 }
 
 func (h *ListenerTestDB) Get(entryUuid []byte) (*listenertestsdeps.RequestListenerInst, error) {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(h.getEntryId(entryUuid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	reqListInst, err := kv.GetCbor[listenertestsdeps.RequestListenerInst](h.db, h.getEntryId(entryUuid))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(h.getEntryId(entryUuid)))
 	} else if err != nil {
-		return nil, errors.New("Failed locating rvte entry." + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading rvte entry value." + err.Error())
-	}
-
-	var reqListInst listenertestsdeps.RequestListenerInst
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &reqListInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding rvte entry value." + err.Error())
+		return nil, errors.New("Failed reading rvte entry." + err.Error())
 	}
 
-	return &reqListInst, nil
+	return reqListInst, nil
 }
 
 func (h *ListenerTestDB) DeleteMapping(guid fdoshared.FdoGuid) error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	err := dbtxn.Delete(h.getMappingEntryId(guid))
-	if err != nil {
-		return errors.New("Failed initialise delete mapping entry." + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := h.db.Delete(h.getMappingEntryId(guid))
 	if err != nil {
 		return errors.New("Failed to delete guid mapping." + err.Error())
 	}
@@ -130,15 +80,7 @@ func (h *ListenerTestDB) DeleteMapping(guid fdoshared.FdoGuid) error {
 }
 
 func (h *ListenerTestDB) DeleteEntry(entryUuid []byte) error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	err := dbtxn.Delete(h.getEntryId(entryUuid))
-	if err != nil {
-		return errors.New("Failed initialise delete listener entry." + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := h.db.Delete(h.getEntryId(entryUuid))
 	if err != nil {
 		return errors.New("Failed to delete listener entry." + err.Error())
 	}
@@ -165,31 +107,14 @@ func (h *ListenerTestDB) Delete(entryUuid []byte) error {
 	return nil
 }
 
+// Guid mapping prefix starts with the entry prefix, so both are deleted
 func (h *ListenerTestDB) ResetDB() error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	iterTxn := dbtxn.NewIterator(badger.IteratorOptions{})
-	defer iterTxn.Close()
-	for iterTxn.Rewind(); iterTxn.ValidForPrefix(h.prefix) || iterTxn.ValidForPrefix(h.mapperGuidPrefix); iterTxn.Next() {
-		item := iterTxn.Item()
-		k := item.Key()
-
-		log.Println("Deleting... " + hex.EncodeToString(k))
-
-		err := dbtxn.Delete(k)
-		if err != nil {
-			log.Println("Error creating delete mapping... " + hex.EncodeToString(k))
-		}
-
-		err = dbtxn.Commit()
-		if err != nil {
-			log.Println("Failed to commit delete mapping... " + hex.EncodeToString(k))
-		}
-
-	}
-
-	return nil
+	return h.db.Update(func(txn kv.Txn) error {
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			log.Println("Deleting... " + hex.EncodeToString(key))
+			return txn.Delete(key)
+		})
+	})
 }
 
 /* ---- Test mgmt menthods ----- */
@@ -251,16 +176,7 @@ func (h *ListenerTestDB) RemoveTestRun(toProtocol fdoshared.FdoToProtocol, testI
 }
 
 func (h *ListenerTestDB) SaveMapping(guid fdoshared.FdoGuid, uuid []byte) error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(h.getMappingEntryId(guid), uuid)
-	err := dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating listener db mapping entry instance." + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := h.db.Set(h.getMappingEntryId(guid), uuid, 0)
 	if err != nil {
 		return errors.New("Failed saving listener mapping entry." + err.Error())
 	}
@@ -269,19 +185,11 @@ func (h *ListenerTestDB) SaveMapping(guid fdoshared.FdoGuid, uuid []byte) error
 }
 
 func (h *ListenerTestDB) GetMappingEntry(guid fdoshared.FdoGuid) ([]byte, error) {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(h.getMappingEntryId(guid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := h.db.Get(h.getMappingEntryId(guid))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The mapping entry with id %s does not exist", hex.EncodeToString(h.getMappingEntryId(guid)))
 	} else if err != nil {
-		return nil, errors.New("Failed locating mapping entry." + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading mapping entry value." + err.Error())
+		return nil, errors.New("Failed reading mapping entry." + err.Error())
 	}
 
 	return itemBytes, nil
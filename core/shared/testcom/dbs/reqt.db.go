@@ -7,45 +7,92 @@ import (
 	"log"
 	"time"
 
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
-
-	"github.com/dgraph-io/badger/v4"
 )
 
 type RequestTestDB struct {
-	db     *badger.DB
-	prefix []byte
-	ttl    int
+	db        kv.Store
+	prefix    []byte
+	jobPrefix []byte
+	ttl       int
 }
 
-func NewRequestTestDB(db *badger.DB) *RequestTestDB {
+func NewRequestTestDB(db kv.Store) *RequestTestDB {
 	return &RequestTestDB{
-		db:     db,
-		prefix: []byte("rvte-"),
-		ttl:    60 * 60 * 24 * 183, //6months storage
+		db:        db,
+		prefix:    []byte("rvte-"),
+		jobPrefix: []byte("rvtjob-"),
+		ttl:       60 * 60 * 24 * 183, //6months storage
 	}
 }
 
 func (h *RequestTestDB) Save(rvte reqtestsdeps.RequestTestInst) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(rvte)
+	return h.Update(rvte.Uuid, rvte)
+}
+
+func (h *RequestTestDB) Update(rvtId []byte, rvte reqtestsdeps.RequestTestInst) error {
+	err := kv.SetCbor(h.db, append(h.prefix, rvtId...), rvte, time.Second*time.Duration(h.ttl))
 	if err != nil {
-		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
+		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
 	}
 
-	rvteStorageId := append(h.prefix, rvte.Uuid...)
+	return nil
+}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+func (h *RequestTestDB) Get(rvtId []byte) (*reqtestsdeps.RequestTestInst, error) {
+	rvteInst, err := kv.GetCbor[reqtestsdeps.RequestTestInst](h.db, append(h.prefix, rvtId...))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(rvtId))
+	} else if err != nil {
+		return nil, errors.New("Failed reading rvte entry. The error is: " + err.Error())
+	}
 
-	entry := badger.NewEntry(rvteStorageId, rvteBytes).WithTTL(time.Second * time.Duration(h.ttl)) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
+	return rvteInst, nil
+}
+
+func (h *RequestTestDB) GetMany(rvtids [][]byte) (*[]reqtestsdeps.RequestTestInst, error) {
+	var rvts []reqtestsdeps.RequestTestInst
+
+	for _, rvtid := range rvtids {
+		rvt, err := h.Get(rvtid)
+		if err != nil {
+			return nil, fmt.Errorf("Error obtaining rvt for id %s. %s \n", hex.EncodeToString(rvtid), err.Error())
+		}
+
+		rvts = append(rvts, *rvt)
 	}
 
-	err = dbtxn.Commit()
+	return &rvts, nil
+}
+
+func (h *RequestTestDB) saveRunState(rvte reqtestsdeps.RequestTestInst, job *reqtestsdeps.RequestRunJob, deleteJob bool) error {
+	ttl := time.Second * time.Duration(h.ttl)
+
+	err := h.db.Update(func(txn kv.Txn) error {
+		err := kv.SetCbor(txn, append(h.prefix, rvte.Uuid...), rvte, ttl)
+		if err != nil {
+			return err
+		}
+
+		if job != nil {
+			err = kv.SetCbor(txn, append(h.jobPrefix, job.RequestTestId...), job, ttl)
+			if err != nil {
+				return errors.New("Failed saving run job. " + err.Error())
+			}
+		}
+
+		if deleteJob {
+			err = txn.Delete(append(h.jobPrefix, rvte.Uuid...))
+			if err != nil {
+				return errors.New("Failed deleting run job. " + err.Error())
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
 		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
 	}
@@ -53,118 +100,159 @@ func (h *RequestTestDB) Save(rvte reqtestsdeps.RequestTestInst) error {
 	return nil
 }
 
-func (h *RequestTestDB) Update(rvtId []byte, rvte reqtestsdeps.RequestTestInst) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(rvte)
-	if err != nil {
-		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
+func (h *RequestTestDB) GetRunJob(rvteid []byte) (*reqtestsdeps.RequestRunJob, error) {
+	runJob, err := kv.GetCbor[reqtestsdeps.RequestRunJob](h.db, append(h.jobPrefix, rvteid...))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The run job for %s does not exist", hex.EncodeToString(rvteid))
+	} else if err != nil {
+		return nil, errors.New("Failed reading run job. The error is: " + err.Error())
 	}
 
-	rvteStorageId := append(h.prefix, rvtId...)
+	return runJob, nil
+}
+
+// Returns all runs that were in progress, e.g. when the server was stopped
+func (h *RequestTestDB) GetRunJobs() ([]reqtestsdeps.RequestRunJob, error) {
+	var runJobs []reqtestsdeps.RequestRunJob = []reqtestsdeps.RequestRunJob{}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	err := h.db.Iterate(h.jobPrefix, func(key []byte, value []byte) error {
+		runJob, err := kv.DecodeCbor[reqtestsdeps.RequestRunJob](value)
+		if err != nil {
+			log.Printf("Skipping undecodable run job %s. %s", hex.EncodeToString(key), err.Error())
+			return nil
+		}
 
-	entry := badger.NewEntry(rvteStorageId, rvteBytes).WithTTL(time.Second * time.Duration(h.ttl)) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
+		runJobs = append(runJobs, *runJob)
+		return nil
+	})
 	if err != nil {
-		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
+		return nil, errors.New("Failed reading run jobs. The error is: " + err.Error())
 	}
 
-	err = dbtxn.Commit()
+	return runJobs, nil
+}
+
+func (h *RequestTestDB) StartNewRun(rvteid []byte, seed int64, selection testcom.FDOTestSelection) (*reqtestsdeps.RequestRunJob, error) {
+	log.Printf("----- Starting New Run For %s. Seed %d -----", hex.EncodeToString(rvteid), seed)
+	rvte, err := h.Get(rvteid)
 	if err != nil {
-		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
+		return nil, fmt.Errorf("%s test entry can not be found. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
-	return nil
-}
+	newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol, seed, selection)
 
-func (h *RequestTestDB) Get(rvtId []byte) (*reqtestsdeps.RequestTestInst, error) {
-	rvteStorageId := append(h.prefix, rvtId...)
+	rvte.InProgress = true
+	rvte.CurrentTestRun = newRVTestRun
+	rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	runJob := reqtestsdeps.NewRequestRunJob(rvteid, newRVTestRun)
 
-	item, err := dbtxn.Get(rvteStorageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(rvtId))
-	} else if err != nil {
-		return nil, errors.New("Failed locating rvte entry. The error is: " + err.Error())
+	err = h.saveRunState(*rvte, &runJob, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
+	return &runJob, nil
+}
+
+func (h *RequestTestDB) ResumeRun(rvteid []byte) (*reqtestsdeps.RequestRunJob, error) {
+	runJob, err := h.GetRunJob(rvteid)
 	if err != nil {
-		return nil, errors.New("Failed reading rvte entry value. The error is: " + err.Error())
+		return nil, err
 	}
 
-	var rvteInst reqtestsdeps.RequestTestInst
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &rvteInst)
+	rvte, err := h.Get(rvteid)
 	if err != nil {
-		return nil, errors.New("Failed cbor decoding rvte entry value. The error is: " + err.Error())
+		return nil, fmt.Errorf("%s test entry can not be found. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
-	return &rvteInst, nil
-}
+	if rvte.CurrentTestRun.Uuid != runJob.RunId {
+		return nil, fmt.Errorf("%s current test run %s does not match run job %s", hex.EncodeToString(rvteid), rvte.CurrentTestRun.Uuid, runJob.RunId)
+	}
 
-func (h *RequestTestDB) GetMany(rvtids [][]byte) (*[]reqtestsdeps.RequestTestInst, error) {
-	var rvts []reqtestsdeps.RequestTestInst
+	log.Printf("----- Resuming Run For %s. Seed %d. %d tests completed -----", hex.EncodeToString(rvteid), runJob.Seed, len(runJob.CompletedTests))
 
-	for _, rvtid := range rvtids {
-		rvt, err := h.Get(rvtid)
-		if err != nil {
-			return nil, fmt.Errorf("Error obtaining rvt for id %s. %s \n", hex.EncodeToString(rvtid), err.Error())
-		}
+	runJob.Resumes++
+	runJob.UpdatedAt = time.Now().Unix()
 
-		rvts = append(rvts, *rvt)
+	err = h.saveRunState(*rvte, runJob, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s error saving run job. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
-	return &rvts, nil
+	return runJob, nil
+}
+
+func (h *RequestTestDB) deleteRunJob(rvteid []byte) {
+	err := h.db.Delete(append(h.jobPrefix, rvteid...))
+	if err != nil {
+		log.Printf("%s error deleting run job. %s", hex.EncodeToString(rvteid), err.Error())
+	}
 }
 
-func (h *RequestTestDB) StartNewRun(rvteid []byte) {
-	log.Printf("----- Starting New Run For %s -----", hex.EncodeToString(rvteid))
+// Finishes current run with the final status, and removes its run job.
+// Run job is removed even when the test entry is gone or can not be saved, so it is not resumed again
+func (h *RequestTestDB) FinishRun(rvteid []byte, status reqtestsdeps.RequestTestRunStatus) {
 	rvte, err := h.Get(rvteid)
 	if err != nil {
 		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		h.deleteRunJob(rvteid)
+		return
 	}
 
-	newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol)
-
-	rvte.InProgress = true
-	rvte.CurrentTestRun = newRVTestRun
-	rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
+	rvte.InProgress = false
+	rvte.CurrentTestRun.Status = status
+	if len(rvte.TestsHistory) > 0 && rvte.TestsHistory[0].Uuid == rvte.CurrentTestRun.Uuid {
+		rvte.TestsHistory[0] = rvte.CurrentTestRun
+	}
 
-	err = h.Save(*rvte)
+	err = h.saveRunState(*rvte, nil, true)
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving test entry. %s", hex.EncodeToString(rvteid), err.Error())
+		h.deleteRunJob(rvteid)
 	}
+
+	log.Printf("----- Finishing Run For %s. Status %s -----", hex.EncodeToString(rvteid), status)
 }
 
-func (h *RequestTestDB) FinishRun(rvteid []byte) {
+func (h *RequestTestDB) ReportTest(rvteid []byte, testID testcom.FDOTestID, testResult testcom.FDOTestState) {
 	rvte, err := h.Get(rvteid)
 	if err != nil {
 		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		return
 	}
 
-	rvte.InProgress = false
+	rvte.CurrentTestRun.Tests[testID] = testResult
+	rvte.TestsHistory[0] = rvte.CurrentTestRun
 
-	err = h.Save(*rvte)
+	metrics.ReportTestResult(rvte.Protocol, testResult.Passed)
+
+	runJob, err := h.GetRunJob(rvteid)
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		log.Printf("%s run job can not be found. Test %s will not be checkpointed.", hex.EncodeToString(rvteid), testID)
+		runJob = nil
+	} else {
+		runJob.Checkpoint(testID)
 	}
 
-	log.Printf("----- Finishing Run For %s -----", hex.EncodeToString(rvteid))
+	err = h.saveRunState(*rvte, runJob, false)
+	if err != nil {
+		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+	}
 }
 
-func (h *RequestTestDB) ReportTest(rvteid []byte, testID testcom.FDOTestID, testResult testcom.FDOTestState) {
+// Saves TO2 negotiation matrix of the current run. Must be called before the matrix test is reported, as reporting checkpoints it
+func (h *RequestTestDB) ReportNegotiationMatrix(rvteid []byte, matrix testcom.To2NegotiationMatrix) {
 	rvte, err := h.Get(rvteid)
 	if err != nil {
 		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+		return
 	}
 
-	rvte.CurrentTestRun.Tests[testID] = testResult
+	rvte.CurrentTestRun.NegotiationMatrix = matrix
 	rvte.TestsHistory[0] = rvte.CurrentTestRun
 
-	err = h.Save(*rvte)
+	err = h.saveRunState(*rvte, nil, false)
 	if err != nil {
 		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
 	}
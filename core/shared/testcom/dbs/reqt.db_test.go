@@ -0,0 +1,96 @@
+package dbs
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func test_newRequestTest(t *testing.T) (*RequestTestDB, reqtestsdeps.RequestTestInst) {
+	reqtDB := NewRequestTestDB(kv.NewMemoryStore())
+	reqte := reqtestsdeps.NewRequestTestInst("http://do.example.com", fdoshared.To2)
+
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reqtDB, reqte
+}
+
+func TestRequestTestDB_RunJobCheckpointAndResume(t *testing.T) {
+	reqtDB, reqte := test_newRequestTest(t)
+
+	runJob, err := reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqtDB.ReportTest(reqte.Uuid, testcom.FIDO_DOT_60_POSITIVE, testcom.FDOTestState{Passed: true})
+
+	storedJob, err := reqtDB.GetRunJob(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Expected run job to be persisted. %s", err.Error())
+	}
+
+	if storedJob.RunId != runJob.RunId || storedJob.Seed != 42 || !storedJob.IsTestCompleted(testcom.FIDO_DOT_60_POSITIVE) {
+		t.Fatalf("Expected reported test to be checkpointed. Got %+v", storedJob)
+	}
+
+	resumedJob, err := reqtDB.ResumeRun(reqte.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resumedJob.Resumes != 1 || !resumedJob.IsTestCompleted(testcom.FIDO_DOT_60_POSITIVE) {
+		t.Fatalf("Expected resumed job to keep checkpoints. Got %+v", resumedJob)
+	}
+
+	reqtDB.FinishRun(reqte.Uuid, reqtestsdeps.RunStatus_Completed)
+
+	_, err = reqtDB.GetRunJob(reqte.Uuid)
+	if err == nil {
+		t.Fatal("Expected run job to be deleted")
+	}
+
+	rvte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rvte.InProgress || rvte.CurrentTestRun.Status != reqtestsdeps.RunStatus_Completed || rvte.TestsHistory[0].Status != reqtestsdeps.RunStatus_Completed {
+		t.Fatalf("Expected run to be completed. Got %+v", rvte.CurrentTestRun)
+	}
+
+	if !rvte.TestsHistory[0].Tests[testcom.FIDO_DOT_60_POSITIVE].Passed {
+		t.Fatal("Expected reported test in history")
+	}
+}
+
+func TestRequestTestDB_FinishRunWithoutTestEntry(t *testing.T) {
+	reqtDB, reqte := test_newRequestTest(t)
+
+	_, err := reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = reqtDB.db.Delete(append(reqtDB.prefix, reqte.Uuid...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqtDB.FinishRun(reqte.Uuid, reqtestsdeps.RunStatus_Aborted)
+
+	runJobs, err := reqtDB.GetRunJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runJobs) != 0 {
+		t.Fatalf("Expected run job of deleted test entry to be deleted. Got %+v", runJobs)
+	}
+}
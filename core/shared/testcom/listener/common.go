@@ -1,15 +1,12 @@
 package listener
 
 import (
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 )
 
 func Conf_RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode fdoshared.FdoErrorCode, prevMsgId fdoshared.FdoCmd, messageStr string, httpStatusCode int, testcomListener *RequestListenerInst, fdoProtocol fdoshared.FdoToProtocol) {
-	log.Printf("Err: %d %s %d", prevMsgId, messageStr, errorCode)
-
 	if testcomListener != nil {
 		switch fdoProtocol {
 		case fdoshared.To0:
@@ -20,6 +20,9 @@ type RequestListenerRunnerInst struct {
 	Completed        bool                                     `cbor:"completed,omitempty"`
 	CurrentTestRun   ListenerTestRun                          `cbor:"currentTestRun,omitempty"`
 	TestRunHistory   []ListenerTestRun                        `cbor:"testRunHistory,omitempty"`
+
+	// Mutation applied to the response for LastTestID. Recorded with the test result
+	LastFuzzMutation *fdoshared.Conf_CborMutation `cbor:"lastFuzzMutation,omitempty"`
 }
 
 type RequestListenerInst struct {
@@ -32,6 +35,10 @@ type RequestListenerInst struct {
 	To2         RequestListenerRunnerInst        `cbor:"to2,omitempty"`
 }
 
+func (h RequestListenerInst) SchemaVersion() uint16 {
+	return 3
+}
+
 func (h *RequestListenerInst) GetProtocolInst(toProtocol int) (*RequestListenerRunnerInst, error) {
 	switch fdoshared.FdoToProtocol(toProtocol) {
 	case fdoshared.To0:
@@ -69,7 +76,7 @@ func (h *RequestListenerRunnerInst) CheckCmdTestingIsCompleted(currentCmd fdosha
 	return false
 }
 
-func (h *RequestListenerRunnerInst) StartNewTestRun() {
+func (h *RequestListenerRunnerInst) StartNewTestRun(seed int64) {
 	if len(h.TestRunHistory) != 0 {
 		h.TestRunHistory = append([]ListenerTestRun{h.CurrentTestRun}, h.TestRunHistory...)
 	}
@@ -77,7 +84,7 @@ func (h *RequestListenerRunnerInst) StartNewTestRun() {
 	h.Running = true
 	h.Completed = false
 
-	h.CurrentTestRun = NewListenerTestRun(h.Protocol)
+	h.CurrentTestRun = NewListenerTestRun(h.Protocol, seed)
 	h.CurrentTestIndex = 0
 	h.CompletedCmds = []fdoshared.FdoCmd{}
 
@@ -115,6 +122,11 @@ func (h *RequestListenerRunnerInst) RemoveTestRun(id string) error {
 	return nil
 }
 
+// Returns random source for the test, derived from the current test run seed
+func (h *RequestListenerRunnerInst) GetConfRand(testId testcom.FDOTestID) *fdoshared.Conf_Rand {
+	return fdoshared.Conf_DeriveRand(h.CurrentTestRun.Seed, string(testId))
+}
+
 func (h *RequestListenerRunnerInst) GetNextTestID() testcom.FDOTestID {
 	if !h.Running {
 		return testcom.NULL_TEST
@@ -123,6 +135,7 @@ func (h *RequestListenerRunnerInst) GetNextTestID() testcom.FDOTestID {
 	selectedTestID := h.Tests[h.ExpectedCmd][h.CurrentTestIndex]
 
 	h.LastTestID = selectedTestID
+	h.LastFuzzMutation = nil
 
 	if h.CurrentTestIndex+1 < len(h.Tests[h.ExpectedCmd]) {
 		h.CurrentTestIndex = h.CurrentTestIndex + 1
@@ -152,10 +165,35 @@ func (h *RequestListenerRunnerInst) CompleteTestRun() {
 	h.TestRunHistory = append(h.TestRunHistory, h.CurrentTestRun)
 }
 
+func (h *RequestListenerRunnerInst) SetFuzzMutation(mutation *fdoshared.Conf_CborMutation) {
+	h.LastFuzzMutation = mutation
+}
+
 func (h *RequestListenerRunnerInst) PushFail(errorMsg string) {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewFailTestState(h.GetLastTestID(), errorMsg))
+	testState := testcom.NewFailTestState(h.GetLastTestID(), errorMsg)
+	testState.SetFuzzMutation(h.LastFuzzMutation)
+
+	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testState)
 }
 
 func (h *RequestListenerRunnerInst) PushSuccess() {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewSuccessTestState(h.GetLastTestID()))
+	testState := testcom.NewSuccessTestState(h.GetLastTestID())
+	testState.SetFuzzMutation(h.LastFuzzMutation)
+
+	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testState)
+}
+
+// Records the device certificate chain finding of err in the current test run. Returns false for other errors, or when no test run is running
+func (h *RequestListenerRunnerInst) PushCertChainFinding(err error) bool {
+	if !h.Running {
+		return false
+	}
+
+	testState, ok := testcom.NewCertChainFindingTestState(err)
+	if !ok {
+		return false
+	}
+
+	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testState)
+	return true
 }
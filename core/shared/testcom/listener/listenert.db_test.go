@@ -0,0 +1,90 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestPushCertChainFinding(t *testing.T) {
+	findings := []fdoshared.CertChainFinding{
+		fdoshared.CERT_CHAIN_MALFORMED,
+		fdoshared.CERT_CHAIN_UNTRUSTED,
+		fdoshared.CERT_CHAIN_VALIDITY,
+		fdoshared.CERT_CHAIN_KEY_USAGE,
+		fdoshared.CERT_CHAIN_BASIC_CONSTRAINTS,
+		fdoshared.CERT_CHAIN_REVOKED,
+		fdoshared.CERT_CHAIN_KEY_POLICY,
+	}
+
+	for _, finding := range findings {
+		runner := RequestListenerRunnerInst{Protocol: fdoshared.To2}
+		runner.StartNewTestRun(1)
+
+		err := fmt.Errorf("Error verifying signature. %w", &fdoshared.CertChainError{Finding: finding, Message: "test message"})
+		if !runner.PushCertChainFinding(err) {
+			t.Fatalf("%s: expected finding to be recorded", finding)
+		}
+
+		testRuns := runner.CurrentTestRun.TestRuns
+		if len(testRuns) != 1 || testRuns[0].Passed || testRuns[0].Error != "test message" {
+			t.Fatalf("%s: expected one failed test. Got %+v", finding, testRuns)
+		}
+
+		if testRuns[0].TestID != testcom.FIDO_LISTENER_CERT_CHAIN_FINDINGS[finding] || testRuns[0].TestID == "" {
+			t.Errorf("%s: unexpected test id %s", finding, testRuns[0].TestID)
+		}
+	}
+}
+
+func TestPushCertChainFinding_Ignored(t *testing.T) {
+	runner := RequestListenerRunnerInst{Protocol: fdoshared.To1}
+	chainErr := &fdoshared.CertChainError{Finding: fdoshared.CERT_CHAIN_REVOKED, Message: "test message"}
+
+	if runner.PushCertChainFinding(chainErr) {
+		t.Error("Expected finding not to be recorded without running test run")
+	}
+
+	runner.StartNewTestRun(1)
+	if runner.PushCertChainFinding(errors.New("bad signature")) {
+		t.Error("Expected other errors not to be recorded")
+	}
+
+	if len(runner.CurrentTestRun.TestRuns) != 0 {
+		t.Errorf("Expected no recorded tests. Got %+v", runner.CurrentTestRun.TestRuns)
+	}
+}
+
+func TestPushFail_FuzzMutation(t *testing.T) {
+	runner := RequestListenerRunnerInst{
+		Protocol: fdoshared.To1,
+		Tests: map[fdoshared.FdoCmd][]testcom.FDOTestID{
+			fdoshared.TO1_30_HELLO_RV: {testcom.FIDO_LISTENER_DEVICE_30_BAD_ENCODING, testcom.FIDO_LISTENER_POSITIVE},
+		},
+	}
+	runner.StartNewTestRun(1)
+
+	runner.GetNextTestID()
+	runner.SetFuzzMutation(&fdoshared.Conf_CborMutation{Seed: 42, Type: fdoshared.Conf_CborMut_WrongType, Field: 0})
+	runner.PushFail("accepted fuzzed message")
+
+	runner.GetNextTestID()
+	runner.PushSuccess()
+
+	testRuns := runner.CurrentTestRun.TestRuns
+	if len(testRuns) != 2 {
+		t.Fatalf("Expected two recorded tests. Got %+v", testRuns)
+	}
+
+	if testRuns[0].FuzzSeed != 42 || !strings.Contains(testRuns[0].Error, "seed 42") {
+		t.Errorf("Expected fuzzing seed to be recorded. Got %+v", testRuns[0])
+	}
+
+	if testRuns[1].FuzzSeed != 0 {
+		t.Errorf("Expected mutation to be reset for the next test. Got %+v", testRuns[1])
+	}
+}
@@ -0,0 +1,122 @@
+package listener
+
+import (
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Earlier encodings of RequestListenerInst, kept for the DB migrations. Only the test runs changed, so other fields are kept as raw CBOR
+
+type requestListenerRunnerInstOf[TestRun any] struct {
+	Protocol      cbor.RawMessage `cbor:"protocol,omitempty"`
+	LastTestID    cbor.RawMessage `cbor:"lastTestID,omitempty"`
+	ExpectedCmd   cbor.RawMessage `cbor:"expectedCmd,omitempty"`
+	CompletedCmds cbor.RawMessage `cbor:"completedCmds,omitempty"`
+
+	CurrentTestIndex cbor.RawMessage `cbor:"currentTestIndex,omitempty"`
+	Tests            cbor.RawMessage `cbor:"tests,omitempty"`
+	Running          cbor.RawMessage `cbor:"running,omitempty"`
+	Completed        cbor.RawMessage `cbor:"completed,omitempty"`
+	CurrentTestRun   TestRun         `cbor:"currentTestRun,omitempty"`
+	TestRunHistory   []TestRun       `cbor:"testRunHistory,omitempty"`
+}
+
+type requestListenerInstOf[TestRun any] struct {
+	Uuid        cbor.RawMessage                      `cbor:"uuid,omitempty"`
+	Guid        cbor.RawMessage                      `cbor:"guid,omitempty"`
+	TestVoucher cbor.RawMessage                      `cbor:"testvoucher,omitempty"`
+	Type        cbor.RawMessage                      `cbor:"type,omitempty"`
+	To0         requestListenerRunnerInstOf[TestRun] `cbor:"to0,omitempty"`
+	To1         requestListenerRunnerInstOf[TestRun] `cbor:"to1,omitempty"`
+	To2         requestListenerRunnerInstOf[TestRun] `cbor:"to2,omitempty"`
+}
+
+func migrateRunnerInstRuns[From any, To any](oldInst requestListenerRunnerInstOf[From], migrateRun func(oldRun From) To) requestListenerRunnerInstOf[To] {
+	newInst := requestListenerRunnerInstOf[To]{
+		Protocol:         oldInst.Protocol,
+		LastTestID:       oldInst.LastTestID,
+		ExpectedCmd:      oldInst.ExpectedCmd,
+		CompletedCmds:    oldInst.CompletedCmds,
+		CurrentTestIndex: oldInst.CurrentTestIndex,
+		Tests:            oldInst.Tests,
+		Running:          oldInst.Running,
+		Completed:        oldInst.Completed,
+		CurrentTestRun:   migrateRun(oldInst.CurrentTestRun),
+	}
+
+	for _, oldRun := range oldInst.TestRunHistory {
+		newInst.TestRunHistory = append(newInst.TestRunHistory, migrateRun(oldRun))
+	}
+
+	return newInst
+}
+
+func migrateListenerInstRuns[From any, To any](oldEntry requestListenerInstOf[From], migrateRun func(oldRun From) To) requestListenerInstOf[To] {
+	return requestListenerInstOf[To]{
+		Uuid:        oldEntry.Uuid,
+		Guid:        oldEntry.Guid,
+		TestVoucher: oldEntry.TestVoucher,
+		Type:        oldEntry.Type,
+		To0:         migrateRunnerInstRuns(oldEntry.To0, migrateRun),
+		To1:         migrateRunnerInstRuns(oldEntry.To1, migrateRun),
+		To2:         migrateRunnerInstRuns(oldEntry.To2, migrateRun),
+	}
+}
+
+// Version 1. Baseline
+type ListenerTestRunV1 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	TestRuns  []testcom.FDOTestStateV1
+	Protocol  fdoshared.FdoToProtocol
+	Completed bool
+}
+
+type RequestListenerInstV1 = requestListenerInstOf[ListenerTestRunV1]
+
+// Version 2. Test states have FuzzSeed
+type ListenerTestRunV2 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	TestRuns  []testcom.FDOTestState
+	Protocol  fdoshared.FdoToProtocol
+	Completed bool
+}
+
+type RequestListenerInstV2 = requestListenerInstOf[ListenerTestRunV2]
+
+func MigrateRequestListenerInstV1(oldEntry RequestListenerInstV1) (RequestListenerInstV2, error) {
+	return migrateListenerInstRuns(oldEntry, func(oldRun ListenerTestRunV1) ListenerTestRunV2 {
+		newRun := ListenerTestRunV2{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			TestRuns:  []testcom.FDOTestState{},
+			Protocol:  oldRun.Protocol,
+			Completed: oldRun.Completed,
+		}
+
+		for _, oldState := range oldRun.TestRuns {
+			newRun.TestRuns = append(newRun.TestRuns, oldState.Migrate())
+		}
+
+		return newRun
+	}), nil
+}
+
+// Version 3. Runs have Seed. Older runs have seed 0, as they were not seeded
+type RequestListenerInstV3 = requestListenerInstOf[ListenerTestRun]
+
+func MigrateRequestListenerInstV2(oldEntry RequestListenerInstV2) (RequestListenerInstV3, error) {
+	return migrateListenerInstRuns(oldEntry, func(oldRun ListenerTestRunV2) ListenerTestRun {
+		return ListenerTestRun{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			TestRuns:  oldRun.TestRuns,
+			Protocol:  oldRun.Protocol,
+			Completed: oldRun.Completed,
+		}
+	}), nil
+}
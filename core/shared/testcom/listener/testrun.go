@@ -15,9 +15,10 @@ type ListenerTestRun struct {
 	TestRuns  []testcom.FDOTestState  `json:"tests"`
 	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
 	Completed bool                    `json:"completed"`
+	Seed      int64                   `json:"seed"`
 }
 
-func NewListenerTestRun(protocol fdoshared.FdoToProtocol) ListenerTestRun {
+func NewListenerTestRun(protocol fdoshared.FdoToProtocol, seed int64) ListenerTestRun {
 	newUuid, _ := uuid.NewRandom()
 	uuidStr, _ := newUuid.MarshalText()
 	newRVTestRun := ListenerTestRun{
@@ -25,6 +26,7 @@ func NewListenerTestRun(protocol fdoshared.FdoToProtocol) ListenerTestRun {
 		Timestamp: time.Now().Unix(),
 		TestRuns:  []testcom.FDOTestState{},
 		Protocol:  protocol,
+		Seed:      seed,
 	}
 
 	return newRVTestRun
@@ -1,5 +1,11 @@
 package testcom
 
+import (
+	"errors"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
 const (
 	FIDO_LISTENER_POSITIVE FDOTestID = "FIDO_LISTENER_POSITIVE"
 	// 30
@@ -28,14 +34,18 @@ var FIDO_LISTENER_32_LIST []FDOTestID = []FDOTestID{
 // DO
 const (
 	// 60
-	FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER            FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER"
-	FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV          FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV"
-	FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO                FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO"
-	FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH           FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH"
-	FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE            FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE"
-	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING"
-	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING         FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING"
-	FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER          FDOTestID = "FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER"
+	FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER             FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_OVHDR_OVHEADER"
+	FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV           FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_NONCE_TO2PROVEOV"
+	FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO                 FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_EBSIGNINFO"
+	FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH            FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLODEVICEHASH"
+	FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE             FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_COSE_SIGNATURE"
+	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING  FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING"
+	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING          FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING"
+	FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER           FDOTestID = "FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER"
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_OFF_CURVE    FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_OFF_CURVE"
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_WEAK_DH      FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_WEAK_DH"
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_SHORT_RANDOM FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_SHORT_RANDOM"
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_KEX_SUITE    FDOTestID = "FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_KEX_SUITE"
 
 	// 62
 	FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE FDOTestID = "FIDO_LISTENER_DEVICE_62_BAD_OVENTRY_COSE_SIGNATURE"
@@ -69,6 +79,10 @@ var FIDO_LISTENER_60_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING,
 	FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_ENCODING,
 	FIDO_LISTENER_DEVICE_60_MISSING_AUTHZ_HEADER,
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_OFF_CURVE,
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_WEAK_DH,
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_SHORT_RANDOM,
+	FIDO_LISTENER_DEVICE_60_BAD_XAKEYEXCHANGE_KEX_SUITE,
 }
 
 var FIDO_LISTENER_62_LIST []FDOTestID = []FDOTestID{
@@ -98,3 +112,39 @@ var FIDO_LISTENER_70_LIST []FDOTestID = []FDOTestID{
 	FIDO_LISTENER_DEVICE_70_BAD_DONE71_ENCODING,
 	FIDO_LISTENER_DEVICE_70_BAD_ENC_WRAPPING,
 }
+
+// Device attestation chain findings. Recorded as failed tests in the listener run when DO or RV rejects the device certificate chain
+const (
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_MALFORMED         FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_MALFORMED"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_UNTRUSTED         FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_UNTRUSTED"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_VALIDITY          FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_VALIDITY"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_USAGE         FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_USAGE"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_BASIC_CONSTRAINTS FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_BASIC_CONSTRAINTS"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_REVOKED           FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_REVOKED"
+	FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_POLICY        FDOTestID = "FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_POLICY"
+)
+
+var FIDO_LISTENER_CERT_CHAIN_FINDINGS map[fdoshared.CertChainFinding]FDOTestID = map[fdoshared.CertChainFinding]FDOTestID{
+	fdoshared.CERT_CHAIN_MALFORMED:         FIDO_LISTENER_DEVICE_CERT_CHAIN_MALFORMED,
+	fdoshared.CERT_CHAIN_UNTRUSTED:         FIDO_LISTENER_DEVICE_CERT_CHAIN_UNTRUSTED,
+	fdoshared.CERT_CHAIN_VALIDITY:          FIDO_LISTENER_DEVICE_CERT_CHAIN_VALIDITY,
+	fdoshared.CERT_CHAIN_KEY_USAGE:         FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_USAGE,
+	fdoshared.CERT_CHAIN_BASIC_CONSTRAINTS: FIDO_LISTENER_DEVICE_CERT_CHAIN_BASIC_CONSTRAINTS,
+	fdoshared.CERT_CHAIN_REVOKED:           FIDO_LISTENER_DEVICE_CERT_CHAIN_REVOKED,
+	fdoshared.CERT_CHAIN_KEY_POLICY:        FIDO_LISTENER_DEVICE_CERT_CHAIN_KEY_POLICY,
+}
+
+// Returns failed test state of the finding, when err is a certificate chain error
+func NewCertChainFindingTestState(err error) (FDOTestState, bool) {
+	var certChainErr *fdoshared.CertChainError
+	if !errors.As(err, &certChainErr) {
+		return FDOTestState{}, false
+	}
+
+	testId, ok := FIDO_LISTENER_CERT_CHAIN_FINDINGS[certChainErr.Finding]
+	if !ok {
+		return FDOTestState{}, false
+	}
+
+	return NewFailTestState(testId, certChainErr.Message), true
+}
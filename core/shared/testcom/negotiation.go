@@ -0,0 +1,112 @@
+package testcom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+type To2NegotiationResult string
+
+const (
+	// TO2 completed with the negotiated suites
+	To2Negotiation_Supported To2NegotiationResult = "supported"
+	// Owner rejected optional suites with FDO error in response to HelloDevice60
+	To2Negotiation_Rejected To2NegotiationResult = "rejected"
+	// Owner rejected mandatory suites, accepted the suites, but failed later, or accepted unusable combination
+	To2Negotiation_Broken To2NegotiationResult = "broken"
+)
+
+// Every owner must support these suites, with any owner key. Other combinations are optional
+var To2MandatoryKexSuiteNames []fdoshared.KexSuiteName = []fdoshared.KexSuiteName{
+	fdoshared.KEX_ECDH256,
+	fdoshared.KEX_ECDH384,
+}
+
+var To2MandatoryCipherSuiteNames []fdoshared.CipherSuiteName = []fdoshared.CipherSuiteName{
+	fdoshared.CIPHER_A128GCM,
+	fdoshared.CIPHER_A256GCM,
+}
+
+type To2NegotiationCell struct {
+	_               struct{}                  `cbor:",toarray"`
+	SgType          fdoshared.DeviceSgType    `json:"sgType"`
+	KexSuiteName    fdoshared.KexSuiteName    `json:"kexSuiteName"`
+	CipherSuiteName fdoshared.CipherSuiteName `json:"cipherSuiteName"`
+	Result          To2NegotiationResult      `json:"result"`
+	Error           string                    `json:"error,omitempty"`
+}
+
+func (h To2NegotiationCell) String() string {
+	return fmt.Sprintf("%d/%s/%d", h.SgType, h.KexSuiteName, h.CipherSuiteName)
+}
+
+func (h To2NegotiationCell) IsMandatory() bool {
+	kexMandatory := false
+	for _, kexSuiteName := range To2MandatoryKexSuiteNames {
+		kexMandatory = kexMandatory || kexSuiteName == h.KexSuiteName
+	}
+
+	cipherMandatory := false
+	for _, cipherSuiteName := range To2MandatoryCipherSuiteNames {
+		cipherMandatory = cipherMandatory || cipherSuiteName == h.CipherSuiteName
+	}
+
+	return kexMandatory && cipherMandatory
+}
+
+// Mandatory is not stored, it is derived from the suites
+func (h To2NegotiationCell) MarshalJSON() ([]byte, error) {
+	type cellJson struct {
+		SgType          fdoshared.DeviceSgType    `json:"sgType"`
+		KexSuiteName    fdoshared.KexSuiteName    `json:"kexSuiteName"`
+		CipherSuiteName fdoshared.CipherSuiteName `json:"cipherSuiteName"`
+		Mandatory       bool                      `json:"mandatory"`
+		Result          To2NegotiationResult      `json:"result"`
+		Error           string                    `json:"error,omitempty"`
+	}
+
+	return json.Marshal(cellJson{
+		SgType:          h.SgType,
+		KexSuiteName:    h.KexSuiteName,
+		CipherSuiteName: h.CipherSuiteName,
+		Mandatory:       h.IsMandatory(),
+		Result:          h.Result,
+		Error:           h.Error,
+	})
+}
+
+type To2NegotiationMatrix []To2NegotiationCell
+
+func (h To2NegotiationMatrix) CountResult(result To2NegotiationResult, mandatory bool) int {
+	count := 0
+	for _, cell := range h {
+		if cell.Result == result && cell.IsMandatory() == mandatory {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (h To2NegotiationMatrix) summary(mandatory bool) string {
+	return fmt.Sprintf("%d supported, %d rejected, %d broken", h.CountResult(To2Negotiation_Supported, mandatory), h.CountResult(To2Negotiation_Rejected, mandatory), h.CountResult(To2Negotiation_Broken, mandatory))
+}
+
+// Matrix passes, when no combination is broken. Rejected mandatory combinations are broken
+func (h To2NegotiationMatrix) GetTestState(testId FDOTestID) FDOTestState {
+	var brokenCells []string
+	for _, cell := range h {
+		if cell.Result == To2Negotiation_Broken {
+			brokenCells = append(brokenCells, cell.String())
+		}
+	}
+
+	if len(brokenCells) > 0 {
+		return NewFailTestState(testId, fmt.Sprintf("%d of %d combinations are broken: %s. Mandatory: %s. Optional: %s", len(brokenCells), len(h), strings.Join(brokenCells, ", "), h.summary(true), h.summary(false)))
+	}
+
+	return NewSuccessTestState(testId)
+}
@@ -20,6 +20,9 @@ const (
 	FIDO_RVT_22_BAD_TO0D_HASH                  FDOTestID = "FIDO_RVT_22_BAD_TO0D_HASH"
 	FIDO_RVT_22_BAD_TO0SIGN_NONCE              FDOTestID = "FIDO_RVT_22_BAD_TO0SIGN_NONCE"
 	FIDO_RVT_23_POSITIVE                       FDOTestID = "FIDO_RVT_23_POSITIVE"
+	FIDO_RVT_23_POSITIVE_PKENC_X509            FDOTestID = "FIDO_RVT_23_POSITIVE_PKENC_X509"
+	FIDO_RVT_23_POSITIVE_PKENC_X5CHAIN         FDOTestID = "FIDO_RVT_23_POSITIVE_PKENC_X5CHAIN"
+	FIDO_RVT_23_POSITIVE_PKENC_COSEKEY         FDOTestID = "FIDO_RVT_23_POSITIVE_PKENC_COSEKEY"
 
 	// DEVT 30
 	FIDO_DEVT_30_BAD_ENCODING     FDOTestID = "FIDO_DEVT_30_BAD_ENCODING"
@@ -37,8 +40,11 @@ const (
 	FIDO_DEVT_33_POSITIVE                         FDOTestID = "FIDO_DEVT_33_POSITIVE"
 
 	// DOT60
-	FIDO_DOT_60_BAD_ENCODING FDOTestID = "FIDO_DOT_60_BAD_ENCODING"
-	FIDO_DOT_60_POSITIVE     FDOTestID = "FIDO_DOT_60_POSITIVE"
+	FIDO_DOT_60_BAD_ENCODING           FDOTestID = "FIDO_DOT_60_BAD_ENCODING"
+	FIDO_DOT_60_POSITIVE               FDOTestID = "FIDO_DOT_60_POSITIVE"
+	FIDO_DOT_60_POSITIVE_PKENC_X509    FDOTestID = "FIDO_DOT_60_POSITIVE_PKENC_X509"
+	FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN FDOTestID = "FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN"
+	FIDO_DOT_60_POSITIVE_PKENC_COSEKEY FDOTestID = "FIDO_DOT_60_POSITIVE_PKENC_COSEKEY"
 
 	// DOT62
 	FIDO_DOT_62_BAD_ENCODING        FDOTestID = "FIDO_DOT_62_BAD_ENCODING"
@@ -46,11 +52,12 @@ const (
 	FIDO_DOT_62_POSITIVE            FDOTestID = "FIDO_DOT_62_POSITIVE"
 
 	// DOT64
-	FIDO_DOT_64_BAD_ENCODING        FDOTestID = "FIDO_DOT_64_BAD_ENCODING"
-	FIDO_DOT_64_BAD_EAT_PAYLOAD     FDOTestID = "FIDO_DOT_64_BAD_EAT_PAYLOAD"
-	FIDO_DOT_64_BAD_SIGNATURE       FDOTestID = "FIDO_DOT_64_BAD_SIGNATURE"
-	FIDO_DOT_64_BAD_NONCE_PROVEDV61 FDOTestID = "FIDO_DOT_64_BAD_NONCE_PROVEDV61"
-	FIDO_DOT_64_POSITIVE            FDOTestID = "FIDO_DOT_64_POSITIVE"
+	FIDO_DOT_64_BAD_ENCODING                   FDOTestID = "FIDO_DOT_64_BAD_ENCODING"
+	FIDO_DOT_64_BAD_EAT_PAYLOAD                FDOTestID = "FIDO_DOT_64_BAD_EAT_PAYLOAD"
+	FIDO_DOT_64_BAD_SIGNATURE                  FDOTestID = "FIDO_DOT_64_BAD_SIGNATURE"
+	FIDO_DOT_64_BAD_NONCE_PROVEDV61            FDOTestID = "FIDO_DOT_64_BAD_NONCE_PROVEDV61"
+	FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP FDOTestID = "FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP"
+	FIDO_DOT_64_POSITIVE                       FDOTestID = "FIDO_DOT_64_POSITIVE"
 
 	// DOT66
 	FIDO_DOT_66_BAD_ENCODING        FDOTestID = "FIDO_DOT_66_BAD_ENCODING"
@@ -70,6 +77,9 @@ const (
 	FIDO_DOT_70_BAD_NONCE_PROVE_DV_61 FDOTestID = "FIDO_DOT_70_BAD_NONCE_PROVE_DV_61"
 	FIDO_DOT_70_POSITIVE              FDOTestID = "FIDO_DOT_70_POSITIVE"
 
+	// DOT negotiation
+	FIDO_DOT_NEGOTIATION_MATRIX FDOTestID = "FIDO_DOT_NEGOTIATION_MATRIX"
+
 	// Voucher tests
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION     FDOTestID = "FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION"
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY     FDOTestID = "FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY"
@@ -109,6 +119,9 @@ var FIDO_TEST_LIST_RVT_22 []FDOTestID = []FDOTestID{
 	FIDO_RVT_22_BAD_TO0D_HASH,
 	FIDO_RVT_22_BAD_TO0SIGN_NONCE,
 	FIDO_RVT_23_POSITIVE,
+	FIDO_RVT_23_POSITIVE_PKENC_X509,
+	FIDO_RVT_23_POSITIVE_PKENC_X5CHAIN,
+	FIDO_RVT_23_POSITIVE_PKENC_COSEKEY,
 }
 
 var FIDO_TEST_LIST_DEVT_30 []FDOTestID = []FDOTestID{
@@ -131,6 +144,26 @@ var FIDO_TEST_LIST_DEVT_32 []FDOTestID = []FDOTestID{
 var FIDO_TEST_LIST_DOT_60 []FDOTestID = []FDOTestID{
 	FIDO_DOT_60_BAD_ENCODING,
 	FIDO_DOT_60_POSITIVE,
+	FIDO_DOT_60_POSITIVE_PKENC_X509,
+	FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN,
+	FIDO_DOT_60_POSITIVE_PKENC_COSEKEY,
+}
+
+// DOT 60 tests that use vouchers with the owner keys in the specific encoding
+var FIDO_TEST_LIST_DOT_60_PKENC []FDOTestID = []FDOTestID{
+	FIDO_DOT_60_POSITIVE_PKENC_X509,
+	FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN,
+	FIDO_DOT_60_POSITIVE_PKENC_COSEKEY,
+}
+
+// Owner public key encoding of the voucher for the positive encoding tests. Other tests use random encoding
+var FIDO_TEST_PKENC map[FDOTestID]fdoshared.FdoPkEnc = map[FDOTestID]fdoshared.FdoPkEnc{
+	FIDO_RVT_23_POSITIVE_PKENC_X509:    fdoshared.X509,
+	FIDO_RVT_23_POSITIVE_PKENC_X5CHAIN: fdoshared.X5CHAIN,
+	FIDO_RVT_23_POSITIVE_PKENC_COSEKEY: fdoshared.COSEKEY,
+	FIDO_DOT_60_POSITIVE_PKENC_X509:    fdoshared.X509,
+	FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN: fdoshared.X5CHAIN,
+	FIDO_DOT_60_POSITIVE_PKENC_COSEKEY: fdoshared.COSEKEY,
 }
 
 var FIDO_TEST_LIST_DOT_62 []FDOTestID = []FDOTestID{
@@ -139,11 +172,17 @@ var FIDO_TEST_LIST_DOT_62 []FDOTestID = []FDOTestID{
 	FIDO_DOT_62_POSITIVE,
 }
 
+// Tests that need a voucher with RSA2048 owner key, and run TO2 with ASYMKEX2048
+var FIDO_TEST_LIST_DOT_ASYMKEX []FDOTestID = []FDOTestID{
+	FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP,
+}
+
 var FIDO_TEST_LIST_DOT_64 []FDOTestID = []FDOTestID{
 	FIDO_DOT_64_BAD_ENCODING,
 	FIDO_DOT_64_BAD_EAT_PAYLOAD,
 	FIDO_DOT_64_BAD_SIGNATURE,
 	FIDO_DOT_64_BAD_NONCE_PROVEDV61,
+	FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP,
 	FIDO_DOT_64_POSITIVE,
 }
 
@@ -168,6 +207,25 @@ var FIDO_TEST_LIST_DOT_70 []FDOTestID = []FDOTestID{
 	FIDO_DOT_70_POSITIVE,
 }
 
+// Runs TO2 for every voucher sgType, kex and cipher suite combination
+var FIDO_TEST_LIST_DOT_NEGOTIATION []FDOTestID = []FDOTestID{
+	FIDO_DOT_NEGOTIATION_MATRIX,
+}
+
+// Positive test of every message. They check the flow the negative tests depend on, so the test selection can not skip them
+var FIDO_TEST_LIST_MANDATORY []FDOTestID = []FDOTestID{
+	FIDO_RVT_20_POSITIVE,
+	FIDO_RVT_23_POSITIVE,
+	FIDO_DEVT_30_POSITIVE,
+	FIDO_DEVT_33_POSITIVE,
+	FIDO_DOT_60_POSITIVE,
+	FIDO_DOT_62_POSITIVE,
+	FIDO_DOT_64_POSITIVE,
+	FIDO_DOT_66_POSITIVE,
+	FIDO_DOT_68_POSITIVE,
+	FIDO_DOT_70_POSITIVE,
+}
+
 var FIDO_TEST_LIST_VOUCHER []FDOTestID = []FDOTestID{
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION,
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY,
@@ -211,10 +269,11 @@ var FIDO_TEST_TO_FDO_ERROR_CODE map[FDOTestID]fdoshared.FdoErrorCode = map[FDOTe
 	FIDO_DOT_62_BAD_ENCODING:        fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DOT_62_GETOVNEXT_BAD_INDEX: fdoshared.INVALID_MESSAGE_ERROR,
 
-	FIDO_DOT_64_BAD_ENCODING:        fdoshared.MESSAGE_BODY_ERROR,
-	FIDO_DOT_64_BAD_EAT_PAYLOAD:     fdoshared.MESSAGE_BODY_ERROR,
-	FIDO_DOT_64_BAD_SIGNATURE:       fdoshared.INVALID_MESSAGE_ERROR,
-	FIDO_DOT_64_BAD_NONCE_PROVEDV61: fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_BAD_ENCODING:                   fdoshared.MESSAGE_BODY_ERROR,
+	FIDO_DOT_64_BAD_EAT_PAYLOAD:                fdoshared.MESSAGE_BODY_ERROR,
+	FIDO_DOT_64_BAD_SIGNATURE:                  fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_BAD_NONCE_PROVEDV61:            fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP: fdoshared.MESSAGE_BODY_ERROR,
 
 	FIDO_DOT_66_BAD_ENCODING:        fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DOT_66_BAD_SRVINFO_PAYLOAD: fdoshared.MESSAGE_BODY_ERROR,
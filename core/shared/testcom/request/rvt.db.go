@@ -11,10 +11,10 @@ import (
 
 type TestVouchers map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher
 
-func (h *TestVouchers) GetVoucher(testId testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
+func (h *TestVouchers) GetVoucher(rnd *fdoshared.Conf_Rand, testId testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
 	for k, v := range *h {
 		if k == testId {
-			randVoucherId := fdoshared.NewRandomInt(0, len(v)-1)
+			randVoucherId := rnd.Int(0, len(v)-1)
 
 			return &v[randVoucherId], nil
 		}
@@ -33,6 +33,11 @@ type RequestTestInst struct {
 	CurrentTestRun RequestTestRun
 	TestsHistory   []RequestTestRun
 	TestVouchers   TestVouchers
+	Seed           int64
+}
+
+func (h RequestTestInst) SchemaVersion() uint16 {
+	return 6
 }
 
 func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol) RequestTestInst {
@@ -45,17 +50,34 @@ func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol) RequestTes
 		TestsHistory: make([]RequestTestRun, 0),
 		Protocol:     protocol,
 		TestVouchers: make(TestVouchers),
+		Seed:         fdoshared.NewConf_Seed(),
 	}
 }
 
 type RequestTestResultMap map[testcom.FDOTestID]testcom.FDOTestState
 
+type RequestTestRunStatus string
+
+const (
+	RunStatus_Running   RequestTestRunStatus = "running"
+	RunStatus_Completed RequestTestRunStatus = "completed"
+	RunStatus_Cancelled RequestTestRunStatus = "cancelled"
+	RunStatus_Aborted   RequestTestRunStatus = "aborted"
+	RunStatus_Failed    RequestTestRunStatus = "failed"
+)
+
 type RequestTestRun struct {
-	_         struct{}                `cbor:",toarray"`
-	Uuid      string                  `json:"uuid"`
-	Timestamp int64                   `json:"timestamp"`
-	Tests     RequestTestResultMap    `json:"tests"`
-	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
+	_         struct{}                 `cbor:",toarray"`
+	Uuid      string                   `json:"uuid"`
+	Timestamp int64                    `json:"timestamp"`
+	Tests     RequestTestResultMap     `json:"tests"`
+	Protocol  fdoshared.FdoToProtocol  `json:"protocol"`
+	Seed      int64                    `json:"seed"`
+	Status    RequestTestRunStatus     `json:"status"`
+	Selection testcom.FDOTestSelection `json:"selection"`
+	Skipped   []testcom.FDOTestID      `json:"skipped"`
+
+	NegotiationMatrix testcom.To2NegotiationMatrix `json:"negotiationMatrix,omitempty"`
 }
 
 func (h *RequestTestRun) PassingAllTests() bool {
@@ -77,7 +99,7 @@ func (h *RequestTestRun) GetAllTestIDs() []testcom.FDOTestID {
 	return result
 }
 
-func NewRVTestRun(protocol fdoshared.FdoToProtocol) RequestTestRun {
+func NewRVTestRun(protocol fdoshared.FdoToProtocol, seed int64, selection testcom.FDOTestSelection) RequestTestRun {
 	newUuid, _ := uuid.NewRandom()
 	uuidStr, _ := newUuid.MarshalText()
 	newRVTestRun := RequestTestRun{
@@ -85,7 +107,59 @@ func NewRVTestRun(protocol fdoshared.FdoToProtocol) RequestTestRun {
 		Timestamp: time.Now().Unix(),
 		Tests:     RequestTestResultMap{},
 		Protocol:  protocol,
+		Seed:      seed,
+		Status:    RunStatus_Running,
+		Selection: selection,
+		Skipped:   selection.GetSkipped(testcom.GetRequestTestIDs(protocol)),
 	}
 
 	return newRVTestRun
 }
+
+// Persisted record of the run in progress. It is checkpointed after every test,
+// so that a run interrupted by server restart can be resumed, or marked as aborted.
+type RequestRunJob struct {
+	_              struct{} `cbor:",toarray"`
+	RequestTestId  []byte
+	RunId          string
+	Protocol       fdoshared.FdoToProtocol
+	Seed           int64
+	Selection      testcom.FDOTestSelection
+	CompletedTests []testcom.FDOTestID
+	Resumes        int
+	UpdatedAt      int64
+}
+
+func (h RequestRunJob) SchemaVersion() uint16 {
+	return 2
+}
+
+func NewRequestRunJob(rvteid []byte, testRun RequestTestRun) RequestRunJob {
+	return RequestRunJob{
+		RequestTestId:  rvteid,
+		RunId:          testRun.Uuid,
+		Protocol:       testRun.Protocol,
+		Seed:           testRun.Seed,
+		Selection:      testRun.Selection,
+		CompletedTests: []testcom.FDOTestID{},
+		UpdatedAt:      time.Now().Unix(),
+	}
+}
+
+func (h *RequestRunJob) IsTestCompleted(testId testcom.FDOTestID) bool {
+	for _, completedTestId := range h.CompletedTests {
+		if completedTestId == testId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *RequestRunJob) Checkpoint(testId testcom.FDOTestID) {
+	if !h.IsTestCompleted(testId) {
+		h.CompletedTests = append(h.CompletedTests, testId)
+	}
+
+	h.UpdatedAt = time.Now().Unix()
+}
@@ -0,0 +1,279 @@
+package request
+
+import (
+	"errors"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Earlier encodings of RequestTestInst, kept for the DB migrations. Vouchers and seed ids never changed, so they are kept as raw CBOR
+
+type requestTestInstOf[TestRun any] struct {
+	_              struct{} `cbor:",toarray"`
+	Uuid           []byte
+	URL            string
+	Protocol       fdoshared.FdoToProtocol
+	FdoSeedIDs     cbor.RawMessage
+	InProgress     bool
+	CurrentTestRun TestRun
+	TestsHistory   []TestRun
+	TestVouchers   cbor.RawMessage
+}
+
+func migrateTestInstRuns[From any, To any](oldEntry requestTestInstOf[From], migrateRun func(oldRun From) To) requestTestInstOf[To] {
+	newEntry := requestTestInstOf[To]{
+		Uuid:           oldEntry.Uuid,
+		URL:            oldEntry.URL,
+		Protocol:       oldEntry.Protocol,
+		FdoSeedIDs:     oldEntry.FdoSeedIDs,
+		InProgress:     oldEntry.InProgress,
+		CurrentTestRun: migrateRun(oldEntry.CurrentTestRun),
+		TestsHistory:   []To{},
+		TestVouchers:   oldEntry.TestVouchers,
+	}
+
+	for _, oldRun := range oldEntry.TestsHistory {
+		newEntry.TestsHistory = append(newEntry.TestsHistory, migrateRun(oldRun))
+	}
+
+	return newEntry
+}
+
+// Version 1. Baseline
+type RequestTestRunV1 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     map[testcom.FDOTestID]testcom.FDOTestStateV1
+	Protocol  fdoshared.FdoToProtocol
+}
+
+type RequestTestInstV1 = requestTestInstOf[RequestTestRunV1]
+
+// Version 2. Test states have FuzzSeed
+type RequestTestRunV2 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     RequestTestResultMap
+	Protocol  fdoshared.FdoToProtocol
+}
+
+type RequestTestInstV2 = requestTestInstOf[RequestTestRunV2]
+
+func MigrateRequestTestInstV1(oldEntry RequestTestInstV1) (RequestTestInstV2, error) {
+	return migrateTestInstRuns(oldEntry, func(oldRun RequestTestRunV1) RequestTestRunV2 {
+		newRun := RequestTestRunV2{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			Tests:     RequestTestResultMap{},
+			Protocol:  oldRun.Protocol,
+		}
+
+		for testId, oldState := range oldRun.Tests {
+			newRun.Tests[testId] = oldState.Migrate()
+		}
+
+		return newRun
+	}), nil
+}
+
+// Instance and runs have Seed from version 3. Older instances and runs have seed 0, as they were not seeded
+type requestTestInstWithSeedOf[TestRun any] struct {
+	_              struct{} `cbor:",toarray"`
+	Uuid           []byte
+	URL            string
+	Protocol       fdoshared.FdoToProtocol
+	FdoSeedIDs     cbor.RawMessage
+	InProgress     bool
+	CurrentTestRun TestRun
+	TestsHistory   []TestRun
+	TestVouchers   cbor.RawMessage
+	Seed           int64
+}
+
+func migrateSeededTestInstRuns[From any, To any](oldEntry requestTestInstWithSeedOf[From], migrateRun func(oldRun From) To) requestTestInstWithSeedOf[To] {
+	newEntry := requestTestInstWithSeedOf[To]{
+		Uuid:           oldEntry.Uuid,
+		URL:            oldEntry.URL,
+		Protocol:       oldEntry.Protocol,
+		FdoSeedIDs:     oldEntry.FdoSeedIDs,
+		InProgress:     oldEntry.InProgress,
+		CurrentTestRun: migrateRun(oldEntry.CurrentTestRun),
+		TestsHistory:   []To{},
+		TestVouchers:   oldEntry.TestVouchers,
+		Seed:           oldEntry.Seed,
+	}
+
+	for _, oldRun := range oldEntry.TestsHistory {
+		newEntry.TestsHistory = append(newEntry.TestsHistory, migrateRun(oldRun))
+	}
+
+	return newEntry
+}
+
+// Version 3. Instance and runs have Seed
+type RequestTestRunV3 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     RequestTestResultMap
+	Protocol  fdoshared.FdoToProtocol
+	Seed      int64
+}
+
+type RequestTestInstV3 = requestTestInstWithSeedOf[RequestTestRunV3]
+
+func MigrateRequestTestInstV2(oldEntry RequestTestInstV2) (RequestTestInstV3, error) {
+	newEntry := migrateTestInstRuns(oldEntry, func(oldRun RequestTestRunV2) RequestTestRunV3 {
+		return RequestTestRunV3{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			Tests:     oldRun.Tests,
+			Protocol:  oldRun.Protocol,
+		}
+	})
+
+	return RequestTestInstV3{
+		Uuid:           newEntry.Uuid,
+		URL:            newEntry.URL,
+		Protocol:       newEntry.Protocol,
+		FdoSeedIDs:     newEntry.FdoSeedIDs,
+		InProgress:     newEntry.InProgress,
+		CurrentTestRun: newEntry.CurrentTestRun,
+		TestsHistory:   newEntry.TestsHistory,
+		TestVouchers:   newEntry.TestVouchers,
+	}, nil
+}
+
+// Version 4. Runs have Status
+type RequestTestRunV4 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     RequestTestResultMap
+	Protocol  fdoshared.FdoToProtocol
+	Seed      int64
+	Status    RequestTestRunStatus
+}
+
+type RequestTestInstV4 = requestTestInstWithSeedOf[RequestTestRunV4]
+
+// Runs in progress had no run jobs to resume them, so they are aborted
+func MigrateRequestTestInstV3(oldEntry RequestTestInstV3) (RequestTestInstV4, error) {
+	newEntry := migrateSeededTestInstRuns(oldEntry, func(oldRun RequestTestRunV3) RequestTestRunV4 {
+		status := RunStatus_Completed
+		if oldEntry.InProgress && oldRun.Uuid == oldEntry.CurrentTestRun.Uuid {
+			status = RunStatus_Aborted
+		}
+
+		return RequestTestRunV4{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			Tests:     oldRun.Tests,
+			Protocol:  oldRun.Protocol,
+			Seed:      oldRun.Seed,
+			Status:    status,
+		}
+	})
+
+	newEntry.InProgress = false
+	return newEntry, nil
+}
+
+// Version 5. Runs have Selection and Skipped. Older runs selected all tests
+type RequestTestRunV5 struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     RequestTestResultMap
+	Protocol  fdoshared.FdoToProtocol
+	Seed      int64
+	Status    RequestTestRunStatus
+	Selection testcom.FDOTestSelection
+	Skipped   []testcom.FDOTestID
+}
+
+type RequestTestInstV5 = requestTestInstWithSeedOf[RequestTestRunV5]
+
+func MigrateRequestTestInstV4(oldEntry RequestTestInstV4) (RequestTestInstV5, error) {
+	return migrateSeededTestInstRuns(oldEntry, func(oldRun RequestTestRunV4) RequestTestRunV5 {
+		return RequestTestRunV5{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			Tests:     oldRun.Tests,
+			Protocol:  oldRun.Protocol,
+			Seed:      oldRun.Seed,
+			Status:    oldRun.Status,
+			Selection: testcom.FDOTestSelection{},
+			Skipped:   []testcom.FDOTestID{},
+		}
+	}), nil
+}
+
+// RequestRunJob version 1, before Selection
+type RequestRunJobV1 struct {
+	_              struct{} `cbor:",toarray"`
+	RequestTestId  []byte
+	RunId          string
+	Protocol       fdoshared.FdoToProtocol
+	Seed           int64
+	CompletedTests []testcom.FDOTestID
+	Resumes        int
+	UpdatedAt      int64
+}
+
+func MigrateRequestRunJobV1(oldJob RequestRunJobV1) (RequestRunJob, error) {
+	return RequestRunJob{
+		RequestTestId:  oldJob.RequestTestId,
+		RunId:          oldJob.RunId,
+		Protocol:       oldJob.Protocol,
+		Seed:           oldJob.Seed,
+		Selection:      testcom.FDOTestSelection{},
+		CompletedTests: oldJob.CompletedTests,
+		Resumes:        oldJob.Resumes,
+		UpdatedAt:      oldJob.UpdatedAt,
+	}, nil
+}
+
+// Version 6. Runs have NegotiationMatrix. Current RequestTestInst
+func MigrateRequestTestInstV5(oldEntry RequestTestInstV5) (RequestTestInst, error) {
+	newEntry := migrateSeededTestInstRuns(oldEntry, func(oldRun RequestTestRunV5) RequestTestRun {
+		return RequestTestRun{
+			Uuid:      oldRun.Uuid,
+			Timestamp: oldRun.Timestamp,
+			Tests:     oldRun.Tests,
+			Protocol:  oldRun.Protocol,
+			Seed:      oldRun.Seed,
+			Status:    oldRun.Status,
+			Selection: oldRun.Selection,
+			Skipped:   oldRun.Skipped,
+		}
+	})
+
+	var fdoSeedIDs fdoshared.FdoSeedIDs
+	err := fdoshared.CborCust.Unmarshal(newEntry.FdoSeedIDs, &fdoSeedIDs)
+	if err != nil {
+		return RequestTestInst{}, errors.New("Failed decoding seed ids. The error is: " + err.Error())
+	}
+
+	var testVouchers TestVouchers
+	err = fdoshared.CborCust.Unmarshal(newEntry.TestVouchers, &testVouchers)
+	if err != nil {
+		return RequestTestInst{}, errors.New("Failed decoding test vouchers. The error is: " + err.Error())
+	}
+
+	return RequestTestInst{
+		Uuid:           newEntry.Uuid,
+		URL:            newEntry.URL,
+		Protocol:       newEntry.Protocol,
+		FdoSeedIDs:     fdoSeedIDs,
+		InProgress:     newEntry.InProgress,
+		CurrentTestRun: newEntry.CurrentTestRun,
+		TestsHistory:   newEntry.TestsHistory,
+		TestVouchers:   testVouchers,
+		Seed:           newEntry.Seed,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package testcom
+
+import (
+	"fmt"
+	"path"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Include/exclude filter for the test run. Entries are test ids, or groups as wildcard patterns, e.g. FIDO_DOT_64_*
+// Empty include list selects all tests. Mandatory tests are always selected.
+type FDOTestSelection struct {
+	_       struct{} `cbor:",toarray"`
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func NewFDOTestSelection(include []string, exclude []string) FDOTestSelection {
+	return FDOTestSelection{
+		Include: include,
+		Exclude: exclude,
+	}
+}
+
+func matchesTestPattern(patterns []string, testId FDOTestID) bool {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, string(testId))
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h FDOTestSelection) IsEmpty() bool {
+	return len(h.Include) == 0 && len(h.Exclude) == 0
+}
+
+func IsMandatoryTest(testId FDOTestID) bool {
+	for _, mandatoryTestId := range FIDO_TEST_LIST_MANDATORY {
+		if testId == mandatoryTestId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h FDOTestSelection) Selects(testId FDOTestID) bool {
+	if IsMandatoryTest(testId) {
+		return true
+	}
+
+	if len(h.Include) != 0 && !matchesTestPattern(h.Include, testId) {
+		return false
+	}
+
+	return !matchesTestPattern(h.Exclude, testId)
+}
+
+// Returns tests from the list that are not selected
+func (h FDOTestSelection) GetSkipped(testIds []FDOTestID) []FDOTestID {
+	var skipped []FDOTestID = []FDOTestID{}
+	for _, testId := range testIds {
+		if !h.Selects(testId) {
+			skipped = append(skipped, testId)
+		}
+	}
+
+	return skipped
+}
+
+// Checks that every entry is a valid pattern, and matches at least one of the known tests. Catches typos in test ids,
+// and excludes of mandatory tests.
+func (h FDOTestSelection) Validate(knownTestIds []FDOTestID) error {
+	for _, pattern := range append(append([]string{}, h.Include...), h.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad test id pattern %s. %s", pattern, err.Error())
+		}
+
+		if !matchesAnyTest(pattern, knownTestIds) {
+			return fmt.Errorf("%s does not match any known test id", pattern)
+		}
+	}
+
+	var optionalTestIds []FDOTestID = []FDOTestID{}
+	for _, testId := range knownTestIds {
+		if !IsMandatoryTest(testId) {
+			optionalTestIds = append(optionalTestIds, testId)
+		}
+	}
+
+	for _, pattern := range h.Exclude {
+		if !matchesAnyTest(pattern, optionalTestIds) {
+			return fmt.Errorf("%s only matches mandatory tests, that can not be excluded", pattern)
+		}
+	}
+
+	return nil
+}
+
+func matchesAnyTest(pattern string, testIds []FDOTestID) bool {
+	for _, testId := range testIds {
+		if matchesTestPattern([]string{pattern}, testId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns all tests executed by the requestor run for the protocol, in execution order
+func GetRequestTestIDs(protocol fdoshared.FdoToProtocol) []FDOTestID {
+	var testLists [][]FDOTestID
+
+	switch protocol {
+	case fdoshared.To0:
+		testLists = [][]FDOTestID{FIDO_TEST_LIST_RVT_20, FIDO_TEST_LIST_RVT_22, FIDO_TEST_LIST_VOUCHER}
+	case fdoshared.To1:
+		testLists = [][]FDOTestID{FIDO_TEST_LIST_DEVT_30, FIDO_TEST_LIST_DEVT_32}
+	case fdoshared.To2:
+		testLists = [][]FDOTestID{FIDO_TEST_LIST_DOT_60, FIDO_TEST_LIST_VOUCHER, FIDO_TEST_LIST_DOT_62, FIDO_TEST_LIST_DOT_64, FIDO_TEST_LIST_DOT_66, FIDO_TEST_LIST_DOT_68, FIDO_TEST_LIST_DOT_70, FIDO_TEST_LIST_DOT_NEGOTIATION}
+	}
+
+	var result []FDOTestID = []FDOTestID{}
+	for _, testList := range testLists {
+		result = append(result, testList...)
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package testcom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestFDOTestSelection_Selects(t *testing.T) {
+	testCases := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		testId   FDOTestID
+		expected bool
+	}{
+		{"Empty selects all", nil, nil, FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP, true},
+		{"Exact include", []string{"FIDO_DOT_62_BAD_ENCODING"}, nil, FIDO_DOT_62_BAD_ENCODING, true},
+		{"Exact include other test", []string{"FIDO_DOT_62_BAD_ENCODING"}, nil, FIDO_DOT_70_BAD_ENCODING, false},
+		{"Group include", []string{"FIDO_DOT_64_*"}, nil, FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP, true},
+		{"Group include other group", []string{"FIDO_DOT_64_*"}, nil, FIDO_DOT_62_BAD_ENCODING, false},
+		{"Single character glob", []string{"FIDO_DOT_6?_BAD_ENCODING"}, nil, FIDO_DOT_62_BAD_ENCODING, true},
+		{"Exclude wins over include", []string{"FIDO_DOT_*"}, []string{"FIDO_DOT_62_*"}, FIDO_DOT_62_BAD_ENCODING, false},
+		{"Exclude only", nil, []string{"FIDO_DOT_62_*"}, FIDO_DOT_70_BAD_ENCODING, true},
+		{"Exclude only excluded", nil, []string{"FIDO_DOT_62_*"}, FIDO_DOT_62_BAD_ENCODING, false},
+		{"Invalid pattern never matches", []string{"FIDO_DOT_[62"}, nil, FIDO_DOT_62_BAD_ENCODING, false},
+		{"Invalid exclude never excludes", nil, []string{"FIDO_DOT_[62"}, FIDO_DOT_62_BAD_ENCODING, true},
+		{"Mandatory not included", []string{"FIDO_DOT_64_*"}, nil, FIDO_DOT_60_POSITIVE, true},
+		{"Mandatory excluded by group", nil, []string{"FIDO_DOT_62_*"}, FIDO_DOT_62_POSITIVE, true},
+		{"Mandatory excluded by id", nil, []string{"FIDO_DOT_70_POSITIVE"}, FIDO_DOT_70_POSITIVE, true},
+		{"Optional positive excluded", nil, []string{"FIDO_DOT_60_POSITIVE_PKENC_*"}, FIDO_DOT_60_POSITIVE_PKENC_COSEKEY, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selection := NewFDOTestSelection(tc.include, tc.exclude)
+			if selection.Selects(tc.testId) != tc.expected {
+				t.Errorf("Expected Selects(%s) to be %t", tc.testId, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFDOTestSelection_Validate(t *testing.T) {
+	knownTestIds := GetRequestTestIDs(fdoshared.To2)
+
+	testCases := []struct {
+		name          string
+		include       []string
+		exclude       []string
+		expectedError string
+	}{
+		{"Empty", nil, nil, ""},
+		{"Known test", []string{"FIDO_DOT_62_BAD_ENCODING"}, nil, ""},
+		{"Known group", []string{"FIDO_DOT_64_*"}, []string{"FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP"}, ""},
+		{"Typo in include", []string{"FIDO_DOT_62_BAD_ENCDING"}, nil, "does not match any known test id"},
+		{"Typo in exclude", nil, []string{"FIDO_DOT_99_*"}, "does not match any known test id"},
+		{"Other protocol", []string{"FIDO_RVT_20_*"}, nil, "does not match any known test id"},
+		{"Bad include pattern", []string{"FIDO_DOT_[62"}, nil, "bad test id pattern"},
+		{"Bad exclude pattern", nil, []string{"FIDO_DOT_62_\\"}, "bad test id pattern"},
+		{"Exclude group with mandatory test", nil, []string{"FIDO_DOT_62_*"}, ""},
+		{"Exclude mandatory test", nil, []string{"FIDO_DOT_62_POSITIVE"}, "only matches mandatory tests"},
+		{"Exclude mandatory tests", nil, []string{"FIDO_DOT_*_POSITIVE"}, "only matches mandatory tests"},
+		{"Include mandatory test", []string{"FIDO_DOT_62_POSITIVE"}, nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewFDOTestSelection(tc.include, tc.exclude).Validate(knownTestIds)
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("Expected no error. Got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("Expected error containing \"%s\". Got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestFDOTestSelection_GetSkipped(t *testing.T) {
+	testIds := []FDOTestID{FIDO_DOT_62_BAD_ENCODING, FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP, FIDO_DOT_70_BAD_ENCODING, FIDO_DOT_70_POSITIVE}
+
+	testCases := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []FDOTestID
+	}{
+		{"Empty skips nothing", nil, nil, []FDOTestID{}},
+		{"Include keeps order of the rest", []string{"FIDO_DOT_64_*"}, nil, []FDOTestID{FIDO_DOT_62_BAD_ENCODING, FIDO_DOT_70_BAD_ENCODING}},
+		{"Exclude", nil, []string{"FIDO_DOT_*_BAD_ENCODING"}, []FDOTestID{FIDO_DOT_62_BAD_ENCODING, FIDO_DOT_70_BAD_ENCODING}},
+		{"Exclude all keeps mandatory", nil, []string{"*"}, testIds[:3]},
+		{"Include keeps mandatory", []string{"FIDO_DOT_62_*"}, nil, []FDOTestID{FIDO_DOT_64_BAD_XBKEYEXCHANGE_ASYMKEX_OAEP, FIDO_DOT_70_BAD_ENCODING}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			skipped := NewFDOTestSelection(tc.include, tc.exclude).GetSkipped(testIds)
+			if !reflect.DeepEqual(skipped, tc.expected) {
+				t.Errorf("Expected skipped %v. Got %v", tc.expected, skipped)
+			}
+		})
+	}
+}
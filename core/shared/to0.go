@@ -29,6 +29,11 @@ type OwnerSign22 struct {
 	To1d CoseSignature
 }
 
+// Stored by RV until TO1. Version of the stored record
+func (h OwnerSign22) SchemaVersion() uint16 {
+	return 1
+}
+
 type AcceptOwner23 struct {
 	_           struct{} `cbor:",toarray"`
 	WaitSeconds uint32
@@ -10,7 +10,11 @@ const CONTENT_TYPE_CBOR string = "application/cbor"
 const FDO_101_URL_BASE string = "/fdo/101/msg/"
 
 func RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode FdoErrorCode, prevMsgId FdoCmd, messageStr string, httpStatusCode int) {
+	requestLog := GetRequestLog(r)
+	requestLog.Logger().Warn("Responding with FDO error", "errorCode", errorCode, "prevMsgId", prevMsgId, "message", messageStr, "httpStatus", httpStatusCode)
+
 	fdoErrorInst := NewFdoError(errorCode, prevMsgId, messageStr)
+	fdoErrorInst.EMErrorCID = requestLog.CID()
 
 	fdoErrorBytes, _ := CborCust.Marshal(fdoErrorInst)
 
@@ -3,6 +3,7 @@ package fdoshared
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -51,17 +52,24 @@ func (h OwnershipVoucher) Validate() error {
 		return errors.New(err.Error())
 	}
 
-	if h.OVDevCertChain == nil { // TODO: Future
-		return errors.New("EPID not supported")
-	}
+	// EPID devices have no certificate chain
+	if h.OVDevCertChain == nil {
+		if ovHeader.OVDevCertChainHash != nil {
+			return errors.New("OVDevCertChainHash must be null when OVDevCertChain is null")
+		}
+	} else {
+		if ovHeader.OVDevCertChainHash == nil {
+			return errors.New("OVDevCertChainHash is missing")
+		}
 
-	ovDevCertChainCert, err := ComputeOVDevCertChainHash(*h.OVDevCertChain, ovHeader.OVDevCertChainHash.Type)
-	if err != nil {
-		return errors.New("could not compute OVDevCertChain hash ")
-	}
+		ovDevCertChainCert, err := ComputeOVDevCertChainHash(*h.OVDevCertChain, ovHeader.OVDevCertChainHash.Type)
+		if err != nil {
+			return errors.New("could not compute OVDevCertChain hash ")
+		}
 
-	if !bytes.Equal(ovDevCertChainCert.Hash, ovHeader.OVDevCertChainHash.Hash) {
-		return errors.New("could not verify OVDevCertChain hash")
+		if !bytes.Equal(ovDevCertChainCert.Hash, ovHeader.OVDevCertChainHash.Hash) {
+			return errors.New("could not verify OVDevCertChain hash")
+		}
 	}
 
 	err = h.VerifyOVEntries()
@@ -205,9 +213,16 @@ type VoucherDBEntry struct {
 	PrivateKeyX509 []byte
 }
 
+func (h VoucherDBEntry) SchemaVersion() uint16 {
+	return 1
+}
+
 type DeviceCredAndVoucher struct {
 	VoucherDBEntry      VoucherDBEntry
 	WawDeviceCredential WawDeviceCredential
+
+	// Mutation applied to the voucher by the voucher tests. Recorded with the test result
+	FuzzMutation *Conf_CborMutation `cbor:",omitempty"`
 }
 
 func GeneratePKIXECKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, error) {
@@ -220,6 +235,9 @@ func GeneratePKIXECKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, err
 	} else if sgType == StSECP384R1 {
 		curve = elliptic.P384()
 		pkType = SECP384R1
+	} else if sgType == StSECP521R1 {
+		curve = elliptic.P521()
+		pkType = SECP521R1
 	} else {
 		return nil, nil, fmt.Errorf("%d is an unsupported SgType", sgType)
 	}
@@ -251,6 +269,12 @@ func GeneratePKIXRSAKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, er
 	} else if sgType == StRSA3072 {
 		pkType = RSAPKCS
 		rsaKeySize = 3072
+	} else if sgType == StRSAPSS2048 {
+		pkType = RSAPSS
+		rsaKeySize = 2048
+	} else if sgType == StRSAPSS3072 {
+		pkType = RSAPSS
+		rsaKeySize = 3072
 	} else {
 		return nil, nil, fmt.Errorf("%d is an unsupported RSA SgType", sgType)
 	}
@@ -273,23 +297,107 @@ func GeneratePKIXRSAKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, er
 	}, nil
 }
 
+func GeneratePKIXEd25519Keypair() (interface{}, *FdoPublicKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, errors.New("error generating new Ed25519 private key. " + err.Error())
+	}
+
+	publicKeyPkix, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, errors.New("error marshaling Ed25519 public key. " + err.Error())
+	}
+
+	return privateKey, &FdoPublicKey{
+		PkType: ED25519,
+		PkEnc:  X509,
+		PkBody: publicKeyPkix,
+	}, nil
+}
+
 func GenerateVoucherKeypair(sgType DeviceSgType) (interface{}, *FdoPublicKey, error) {
+	err := CheckSgTypeEnabled(sgType)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	switch sgType {
-	case StSECP256R1, StSECP384R1:
+	case StSECP256R1, StSECP384R1, StSECP521R1:
 		return GeneratePKIXECKeypair(sgType)
-	case StRSA2048, StRSA3072:
+	case StED25519:
+		return GeneratePKIXEd25519Keypair()
+	case StRSA2048, StRSA3072, StRSAPSS2048, StRSAPSS3072:
 		return GeneratePKIXRSAKeypair(sgType)
 	default:
 		return nil, nil, fmt.Errorf("%d is an unsupported SgType for the device", sgType)
 	}
 }
 
+// Encodes public key as FdoPublicKey in the requested encoding. X5CHAIN key is certified by the test root
+func NewFdoPublicKey(publicKeyInst interface{}, sgType DeviceSgType, pkEnc FdoPkEnc) (*FdoPublicKey, error) {
+	pkType, ok := SgTypeToFdoPkType[sgType]
+	if !ok {
+		return nil, fmt.Errorf("%d is an unsupported SgType", sgType)
+	}
+
+	var pkBody interface{}
+	switch pkEnc {
+	case X509:
+		publicKeyPkix, err := x509.MarshalPKIXPublicKey(publicKeyInst)
+		if err != nil {
+			return nil, errors.New("error marshaling public key. " + err.Error())
+		}
+
+		pkBody = publicKeyPkix
+	case X5CHAIN:
+		chain, err := NewTestOwnerCertificateChain(publicKeyInst)
+		if err != nil {
+			return nil, err
+		}
+
+		pkBody = chain
+	case COSEKEY:
+		cosePubKey, err := NewCosePublicKey(publicKeyInst, sgType)
+		if err != nil {
+			return nil, err
+		}
+
+		pkBody = *cosePubKey
+	default:
+		return nil, fmt.Errorf("%d is an unsupported public key encoding", pkEnc)
+	}
+
+	return &FdoPublicKey{
+		PkType: pkType,
+		PkEnc:  pkEnc,
+		PkBody: pkBody,
+	}, nil
+}
+
+// Generates voucher keypair with the public key in the requested encoding
+func GenerateVoucherKeypairWithEncoding(sgType DeviceSgType, pkEnc FdoPkEnc) (interface{}, *FdoPublicKey, error) {
+	privateKeyInst, _, err := GenerateVoucherKeypair(sgType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := NewFdoPublicKey(CastPublicFromPrivate(privateKeyInst), sgType, pkEnc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privateKeyInst, publicKey, nil
+}
+
 func MarshalPrivateKey(privKey interface{}, sgType DeviceSgType) ([]byte, error) {
 	switch sgType {
-	case StSECP256R1, StSECP384R1:
+	case StSECP256R1, StSECP384R1, StSECP521R1:
 		return x509.MarshalPKCS8PrivateKey(privKey.(*ecdsa.PrivateKey))
 
-	case StRSA2048, StRSA3072:
+	case StED25519:
+		return x509.MarshalPKCS8PrivateKey(privKey.(ed25519.PrivateKey))
+
+	case StRSA2048, StRSA3072, StRSAPSS2048, StRSAPSS3072:
 		return x509.MarshalPKCS8PrivateKey(privKey.(*rsa.PrivateKey))
 
 	default:
@@ -0,0 +1,188 @@
+package dbs
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/google/uuid"
+)
+
+// Per-user API tokens for the test management API. Only SHA-256 of the token is stored
+
+const API_TOKEN_PREFIX string = "fdoct_"
+
+type ApiTokenScope string
+
+const (
+	ATS_Read    ApiTokenScope = "read"
+	ATS_Execute ApiTokenScope = "execute"
+)
+
+func IsValidApiTokenScope(scope ApiTokenScope) bool {
+	return scope == ATS_Read || scope == ATS_Execute
+}
+
+type ApiTokenEntry struct {
+	_        struct{} `cbor:",toarray"`
+	Id       string
+	Email    string
+	Name     string
+	Scope    ApiTokenScope
+	Created  time.Time
+	LastUsed time.Time
+}
+
+func (h ApiTokenEntry) SchemaVersion() uint16 {
+	return 1
+}
+
+// Execute tokens can read as well
+func (h *ApiTokenEntry) HasScope(scope ApiTokenScope) bool {
+	return h.Scope == ATS_Execute || h.Scope == scope
+}
+
+type ApiTokenDB struct {
+	db     kv.Store
+	prefix []byte
+}
+
+func NewApiTokenDB(db kv.Store) *ApiTokenDB {
+	return &ApiTokenDB{
+		db:     db,
+		prefix: []byte("apitoken-"),
+	}
+}
+
+func (h *ApiTokenDB) tokenDbId(token string) []byte {
+	tokenHash := sha256.Sum256([]byte(token))
+	return append(append([]byte{}, h.prefix...), []byte(hex.EncodeToString(tokenHash[:]))...)
+}
+
+func (h *ApiTokenDB) save(tokenDbId []byte, tokenEntry ApiTokenEntry) error {
+	err := kv.SetCbor(h.db, tokenDbId, tokenEntry, 0)
+	if err != nil {
+		return errors.New("Failed saving api token entry. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// Returns the plain token. It is not stored, and can not be shown again
+func (h *ApiTokenDB) Create(email string, name string, scope ApiTokenScope) (string, *ApiTokenEntry, error) {
+	if !IsValidApiTokenScope(scope) {
+		return "", nil, fmt.Errorf("Unknown api token scope %s", scope)
+	}
+
+	tokenId, _ := uuid.NewRandom()
+	tokenEntry := ApiTokenEntry{
+		Id:      tokenId.String(),
+		Email:   strings.ToLower(email),
+		Name:    name,
+		Scope:   scope,
+		Created: time.Now().UTC(),
+	}
+
+	token := API_TOKEN_PREFIX + base64.RawURLEncoding.EncodeToString(fdoshared.NewRandomBuffer(32))
+
+	err := h.save(h.tokenDbId(token), tokenEntry)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &tokenEntry, nil
+}
+
+func (h *ApiTokenDB) GetByToken(token string) (*ApiTokenEntry, error) {
+	tokenEntry, err := kv.GetCbor[ApiTokenEntry](h.db, h.tokenDbId(token))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+		return nil, errors.New("The api token does not exist")
+	} else if err != nil {
+		return nil, errors.New("Failed reading api token entry. The error is: " + err.Error())
+	}
+
+	return tokenEntry, nil
+}
+
+// LastUsed is only written when it is older than this, so authorized requests do not write on every call
+const API_TOKEN_LAST_USED_INTERVAL time.Duration = 5 * time.Minute
+
+// Reads and updates token in one transaction, so token deleted in between is not written back.
+// Deleted tokens are skipped without error
+func (h *ApiTokenDB) UpdateLastUsed(token string) error {
+	tokenDbId := h.tokenDbId(token)
+
+	return h.db.Update(func(txn kv.Txn) error {
+		tokenEntry, err := kv.GetCbor[ApiTokenEntry](txn, tokenDbId)
+		if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return errors.New("Failed reading api token entry. The error is: " + err.Error())
+		}
+
+		now := time.Now().UTC()
+		if now.Sub(tokenEntry.LastUsed) < API_TOKEN_LAST_USED_INTERVAL {
+			return nil
+		}
+
+		tokenEntry.LastUsed = now
+		err = kv.SetCbor(txn, tokenDbId, *tokenEntry, 0)
+		if err != nil {
+			return errors.New("Failed saving api token entry. The error is: " + err.Error())
+		}
+
+		return nil
+	})
+}
+
+// Calls onEntry for every token of the user, with the db key of the token
+func (h *ApiTokenDB) forEachUserToken(email string, onEntry func(key []byte, tokenEntry ApiTokenEntry)) error {
+	email = strings.ToLower(email)
+
+	return kv.IterateCbor(h.db, h.prefix, func(key []byte, tokenEntry ApiTokenEntry) error {
+		if tokenEntry.Email == email {
+			onEntry(key, tokenEntry)
+		}
+
+		return nil
+	})
+}
+
+func (h *ApiTokenDB) List(email string) ([]ApiTokenEntry, error) {
+	tokenEntries := []ApiTokenEntry{}
+	err := h.forEachUserToken(email, func(key []byte, tokenEntry ApiTokenEntry) {
+		tokenEntries = append(tokenEntries, tokenEntry)
+	})
+
+	return tokenEntries, err
+}
+
+// Only deletes tokens of the user
+func (h *ApiTokenDB) Delete(email string, tokenId string) error {
+	var tokenDbId []byte
+	err := h.forEachUserToken(email, func(key []byte, tokenEntry ApiTokenEntry) {
+		if tokenEntry.Id == tokenId {
+			tokenDbId = key
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if tokenDbId == nil {
+		return fmt.Errorf("The api token with id %s does not exist", tokenId)
+	}
+
+	err = h.db.Delete(tokenDbId)
+	if err != nil {
+		return errors.New("Failed to delete api token. The error is: " + err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package dbs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+)
+
+// Archive of selected DB prefixes. Used for export/import between hosts, and for backups
+const ARCHIVE_VERSION uint16 = 1
+
+// Imported entries are written in batches, so large archives do not exceed transaction size
+const ARCHIVE_IMPORT_BATCH int = 1000
+
+const BACKUP_FILE_PREFIX string = "fdoct-backup-"
+const BACKUP_FILE_EXT string = ".cbor"
+
+type ArchiveGroup string
+
+const (
+	AG_Users       ArchiveGroup = "users"
+	AG_TestRuns    ArchiveGroup = "testruns"
+	AG_Vouchers    ArchiveGroup = "vouchers"
+	AG_DeviceBases ArchiveGroup = "devicebases"
+	AG_RvRegs      ArchiveGroup = "rvregistrations"
+
+	// Every entry in the DB, including sessions. Used by backups
+	AG_All ArchiveGroup = "all"
+)
+
+// Prefixes must match the ones used by the DBs
+var archiveGroupPrefixes = map[ArchiveGroup][][]byte{
+	AG_Users:       {[]byte("usere-"), []byte("org-"), []byte("orgmember-"), []byte("apitoken-")},
+	AG_TestRuns:    {[]byte("rvte-"), []byte("rvtjob-"), []byte("lstdb-")},
+	AG_Vouchers:    {[]byte("voucher-")},
+	AG_DeviceBases: {[]byte("devbasecreds-"), []byte("devpool-"), []byte("config-")},
+	AG_RvRegs:      {[]byte("to1osstorage-")},
+	AG_All:         {[]byte{}},
+}
+
+var DefaultArchiveGroups = []ArchiveGroup{AG_Users, AG_TestRuns, AG_Vouchers, AG_DeviceBases, AG_RvRegs}
+
+func ParseArchiveGroups(groupsStr []string) ([]ArchiveGroup, error) {
+	if len(groupsStr) == 0 {
+		return DefaultArchiveGroups, nil
+	}
+
+	groups := []ArchiveGroup{}
+	for _, groupStr := range groupsStr {
+		group := ArchiveGroup(strings.ToLower(strings.TrimSpace(groupStr)))
+		if _, ok := archiveGroupPrefixes[group]; !ok {
+			return nil, fmt.Errorf("Unknown archive group %s", groupStr)
+		}
+
+		if containsGroup(groups, group) {
+			continue
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+type ArchiveEntry struct {
+	_     struct{} `cbor:",toarray"`
+	Key   []byte
+	Value []byte
+
+	// Unix seconds. Zero never expires
+	ExpiresAt int64
+}
+
+type Archive struct {
+	_         struct{} `cbor:",toarray"`
+	Version   uint16
+	CreatedAt int64
+	Groups    []ArchiveGroup
+	Entries   []ArchiveEntry
+}
+
+type ArchiveDB struct {
+	db kv.Store
+}
+
+func NewArchiveDB(db kv.Store) *ArchiveDB {
+	return &ArchiveDB{
+		db: db,
+	}
+}
+
+// All groups are read in one transaction, so the archive is consistent while the server is running
+func (h *ArchiveDB) Export(groups []ArchiveGroup) (*Archive, error) {
+	archive := Archive{
+		Version:   ARCHIVE_VERSION,
+		CreatedAt: time.Now().Unix(),
+		Groups:    groups,
+		Entries:   []ArchiveEntry{},
+	}
+
+	prefixes, err := getGroupsPrefixes(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.db.View(func(txn kv.Reader) error {
+		for _, prefix := range prefixes {
+			err := txn.IterateWithExpiry(prefix, func(key []byte, value []byte, expiresAt time.Time) error {
+				entry := ArchiveEntry{
+					Key:   key,
+					Value: value,
+				}
+
+				if !expiresAt.IsZero() {
+					entry.ExpiresAt = expiresAt.Unix()
+				}
+
+				archive.Entries = append(archive.Entries, entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed exporting entries. The error is: " + err.Error())
+	}
+
+	return &archive, nil
+}
+
+// Existing entries with the same keys are overwritten. Entries that expired since the export are skipped.
+// Returns number of imported entries
+func (h *ArchiveDB) Import(archive Archive) (int, error) {
+	if archive.Version != ARCHIVE_VERSION {
+		return 0, fmt.Errorf("Unsupported archive version %d. Expected %d", archive.Version, ARCHIVE_VERSION)
+	}
+
+	prefixes, err := getGroupsPrefixes(archive.Groups)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	importedCount := 0
+	for batchStart := 0; batchStart < len(archive.Entries); batchStart += ARCHIVE_IMPORT_BATCH {
+		batchEnd := batchStart + ARCHIVE_IMPORT_BATCH
+		if batchEnd > len(archive.Entries) {
+			batchEnd = len(archive.Entries)
+		}
+
+		batchCount := 0
+		err := h.db.Update(func(txn kv.Txn) error {
+			batchCount = 0
+			for _, entry := range archive.Entries[batchStart:batchEnd] {
+				if !hasAnyPrefix(entry.Key, prefixes) {
+					return fmt.Errorf("Entry key %q is outside of archive groups", entry.Key)
+				}
+
+				var ttl time.Duration
+				if entry.ExpiresAt != 0 {
+					ttl = time.Unix(entry.ExpiresAt, 0).Sub(now)
+					if ttl <= 0 {
+						continue
+					}
+				}
+
+				err := txn.Set(entry.Key, entry.Value, ttl)
+				if err != nil {
+					return err
+				}
+
+				batchCount++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return importedCount, errors.New("Failed importing entries. The error is: " + err.Error())
+		}
+
+		importedCount += batchCount
+	}
+
+	return importedCount, nil
+}
+
+func (h *ArchiveDB) ExportToFile(groups []ArchiveGroup, filename string) (*Archive, error) {
+	archive, err := h.Export(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveBytes, err := fdoshared.CborCust.Marshal(archive)
+	if err != nil {
+		return nil, errors.New("Failed to marshal archive. The error is: " + err.Error())
+	}
+
+	// Written to temporary file first, so the interrupted export never leaves partial archive
+	tmpFilename := filename + ".tmp"
+	err = os.WriteFile(tmpFilename, archiveBytes, 0600)
+	if err != nil {
+		return nil, errors.New("Failed writing archive file. The error is: " + err.Error())
+	}
+
+	err = os.Rename(tmpFilename, filename)
+	if err != nil {
+		return nil, errors.New("Failed writing archive file. The error is: " + err.Error())
+	}
+
+	return archive, nil
+}
+
+func (h *ArchiveDB) ImportFromFile(filename string) (*Archive, int, error) {
+	archiveBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, 0, errors.New("Failed reading archive file. The error is: " + err.Error())
+	}
+
+	var archive Archive
+	err = fdoshared.CborCust.Unmarshal(archiveBytes, &archive)
+	if err != nil {
+		return nil, 0, errors.New("Failed decoding archive. The error is: " + err.Error())
+	}
+
+	importedCount, err := h.Import(archive)
+	return &archive, importedCount, err
+}
+
+// Writes full backup to the folder, and removes the oldest backups above keepCount. Zero keepCount keeps all backups
+func (h *ArchiveDB) Backup(folder string, keepCount int) (string, error) {
+	err := os.MkdirAll(folder, 0700)
+	if err != nil {
+		return "", errors.New("Failed creating backup folder. The error is: " + err.Error())
+	}
+
+	filename := filepath.Join(folder, BACKUP_FILE_PREFIX+time.Now().UTC().Format("20060102T150405Z")+BACKUP_FILE_EXT)
+	_, err = h.ExportToFile([]ArchiveGroup{AG_All}, filename)
+	if err != nil {
+		return "", err
+	}
+
+	if keepCount > 0 {
+		backups, err := filepath.Glob(filepath.Join(folder, BACKUP_FILE_PREFIX+"*"+BACKUP_FILE_EXT))
+		if err != nil {
+			return filename, errors.New("Failed listing backups. The error is: " + err.Error())
+		}
+
+		// Timestamped names sort in creation order
+		sort.Strings(backups)
+		for len(backups) > keepCount {
+			log.Println("Removing old backup " + backups[0])
+			err = os.Remove(backups[0])
+			if err != nil {
+				return filename, errors.New("Failed removing old backup. The error is: " + err.Error())
+			}
+
+			backups = backups[1:]
+		}
+	}
+
+	return filename, nil
+}
+
+// Takes backup every interval until stop is closed
+func (h *ArchiveDB) RunBackups(folder string, interval time.Duration, keepCount int, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			filename, err := h.Backup(folder, keepCount)
+			if err != nil {
+				log.Println("Backup failed. " + err.Error())
+				continue
+			}
+
+			log.Println("Backup saved to " + filename)
+		}
+	}
+}
+
+func getGroupsPrefixes(groups []ArchiveGroup) ([][]byte, error) {
+	if len(groups) == 0 {
+		return nil, errors.New("Archive has no groups")
+	}
+
+	prefixes := [][]byte{}
+	for _, group := range groups {
+		groupPrefixes, ok := archiveGroupPrefixes[group]
+		if !ok {
+			return nil, fmt.Errorf("Unknown archive group %s", group)
+		}
+
+		prefixes = append(prefixes, groupPrefixes...)
+	}
+
+	return prefixes, nil
+}
+
+func containsGroup(groups []ArchiveGroup, group ArchiveGroup) bool {
+	for _, existingGroup := range groups {
+		if existingGroup == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasAnyPrefix(key []byte, prefixes [][]byte) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+package dbs
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestArchiveExportImport(t *testing.T) {
+	srcStore := kv.NewMemoryStore()
+	srcStore.Set([]byte("usere-alice@example.com"), []byte("alice"), 0)
+	srcStore.Set([]byte("rvte-0001"), []byte("testrun"), time.Hour)
+	srcStore.Set([]byte("voucher-0001"), []byte("voucher"), 0)
+	srcStore.Set([]byte("session-0001"), []byte("session"), time.Hour)
+
+	filename := filepath.Join(t.TempDir(), "archive.cbor")
+	archive, err := NewArchiveDB(srcStore).ExportToFile([]ArchiveGroup{AG_Users, AG_TestRuns}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(archive.Entries) != 2 {
+		t.Fatalf("expected 2 exported entries, got %d", len(archive.Entries))
+	}
+
+	dstStore := kv.NewMemoryStore()
+	_, importedCount, err := NewArchiveDB(dstStore).ImportFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if importedCount != 2 {
+		t.Fatalf("expected 2 imported entries, got %d", importedCount)
+	}
+
+	value, err := dstStore.Get([]byte("usere-alice@example.com"))
+	if err != nil || !bytes.Equal(value, []byte("alice")) {
+		t.Fatalf("unexpected user entry %q, %v", value, err)
+	}
+
+	for _, key := range []string{"voucher-0001", "session-0001"} {
+		if _, err := dstStore.Get([]byte(key)); err == nil {
+			t.Fatalf("expected %s not to be imported", key)
+		}
+	}
+
+	dstStore.IterateWithExpiry([]byte("rvte-"), func(key []byte, value []byte, expiresAt time.Time) error {
+		if expiresAt.IsZero() || time.Until(expiresAt) > time.Hour {
+			t.Errorf("expected test run ttl to be kept, got expiry %v", expiresAt)
+		}
+		return nil
+	})
+}
+
+func TestArchiveImportRejects(t *testing.T) {
+	archiveDB := NewArchiveDB(kv.NewMemoryStore())
+
+	_, err := archiveDB.Import(Archive{Version: ARCHIVE_VERSION + 1, Groups: []ArchiveGroup{AG_Users}})
+	if err == nil {
+		t.Fatal("expected unsupported version to fail")
+	}
+
+	_, err = archiveDB.Import(Archive{
+		Version: ARCHIVE_VERSION,
+		Groups:  []ArchiveGroup{AG_Users},
+		Entries: []ArchiveEntry{{Key: []byte("voucher-0001"), Value: []byte("voucher")}},
+	})
+	if err == nil {
+		t.Fatal("expected entry outside of archive groups to fail")
+	}
+
+	importedCount, err := archiveDB.Import(Archive{
+		Version: ARCHIVE_VERSION,
+		Groups:  []ArchiveGroup{AG_Users},
+		Entries: []ArchiveEntry{{Key: []byte("usere-bob@example.com"), Value: []byte("bob"), ExpiresAt: time.Now().Add(-time.Hour).Unix()}},
+	})
+	if err != nil || importedCount != 0 {
+		t.Fatalf("expected expired entry to be skipped, got %d, %v", importedCount, err)
+	}
+}
+
+func TestArchiveBackupKeep(t *testing.T) {
+	store := kv.NewMemoryStore()
+	store.Set([]byte("session-0001"), []byte("session"), 0)
+
+	folder := t.TempDir()
+	for i := 0; i < 3; i++ {
+		_, err := NewArchiveDB(store).Backup(folder, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Backup names have second resolution
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(folder, BACKUP_FILE_PREFIX+"*"+BACKUP_FILE_EXT))
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups to be kept, got %d", len(backups))
+	}
+}
+
+// Sessions and verify links are short lived, so only AG_All backups keep them
+var test_archiveExcludedPrefixes = [][]byte{[]byte("usersession-"), []byte("rvsession-"), []byte("dosession-"), []byte("verifydb-")}
+
+func TestArchiveGroupsCoverDBs(t *testing.T) {
+	store := kv.NewMemoryStore()
+	guid := fdoshared.FdoGuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	checkErr := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One record through every DB, so a new DB prefix fails here until it gets an archive group or is excluded
+	userDB := NewUserTestDB(store)
+	checkErr(userDB.Save(UserTestDBEntry{Email: "alice@example.com"}))
+	_, err := NewOrgDB(store).Create("Org", "alice@example.com", userDB)
+	checkErr(err)
+	_, _, err = NewApiTokenDB(store).Create("alice@example.com", "token", ATS_Read)
+	checkErr(err)
+	checkErr(NewConfigDB(store).Save(MainConfig{}))
+	checkErr(NewDeviceBaseDB(store).Save(fdoshared.WawDeviceCredential{DCGuid: guid}))
+	checkErr(NewDevicePoolDB(store).Add(fdoshared.StSECP256R1, 0, fdoshared.FdoGuidList{guid}))
+	_, err = NewVerifyDB(store).SaveEntry(VerifyEntry{Email: "alice@example.com", Type: VT_Email})
+	checkErr(err)
+	_, err = NewSessionDB(store).NewSessionEntry(SessionEntry{Email: "alice@example.com"})
+	checkErr(err)
+
+	ovHeaderBytes, _ := fdoshared.CborCust.Marshal(fdoshared.OwnershipVoucherHeader{OVGuid: guid})
+	checkErr(dodbs.NewVoucherDB(store).Save(fdoshared.VoucherDBEntry{Voucher: fdoshared.OwnershipVoucher{OVHeaderTag: ovHeaderBytes}}))
+	_, err = dodbs.NewSessionDB(store, time.Hour).NewSessionEntry(dodbs.SessionEntry{})
+	checkErr(err)
+
+	ownerSignDB := fdorv.NewOwnerSignDB(store)
+	checkErr(ownerSignDB.Save(guid, fdoshared.OwnerSign22{}, 3600))
+	_, err = fdorv.NewSessionDB(store, time.Hour).NewSessionEntry(fdorv.SessionEntry{})
+	checkErr(err)
+
+	checkErr(testdbs.NewListenerTestDB(store).Save(listenertestsdeps.RequestListenerInst{Uuid: []byte("listener"), Guid: guid}))
+	reqtDB := testdbs.NewRequestTestDB(store)
+	checkErr(reqtDB.Save(reqtestsdeps.RequestTestInst{Uuid: []byte("requestor"), Protocol: fdoshared.To2}))
+	_, err = reqtDB.StartNewRun([]byte("requestor"), 1, testcom.FDOTestSelection{})
+	checkErr(err)
+
+	groupPrefixes, err := getGroupsPrefixes(DefaultArchiveGroups)
+	checkErr(err)
+
+	keysCount := 0
+	store.IterateWithExpiry([]byte{}, func(key []byte, value []byte, expiresAt time.Time) error {
+		keysCount++
+		for _, prefix := range append(groupPrefixes, test_archiveExcludedPrefixes...) {
+			if bytes.HasPrefix(key, prefix) {
+				return nil
+			}
+		}
+
+		t.Errorf("Key %q is not in any archive group", key)
+		return nil
+	})
+
+	if keysCount < 15 {
+		t.Errorf("Expected a record of every DB. Got %d keys", keysCount)
+	}
+}
@@ -4,16 +4,16 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 )
 
 type ConfigDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
-func NewConfigDB(db *badger.DB) *ConfigDB {
+func NewConfigDB(db kv.Store) *ConfigDB {
 	return &ConfigDB{
 		db:     db,
 		prefix: []byte("config-"),
@@ -25,24 +25,14 @@ type MainConfig struct {
 	SeededGuids fdoshared.FdoSeedIDs
 }
 
-func (h *ConfigDB) Save(mainCfg MainConfig) error {
-	payloadBytes, err := fdoshared.CborCust.Marshal(mainCfg)
-	if err != nil {
-		return errors.New("Failed to marshal MainConfig. The error is: " + err.Error())
-	}
+func (h MainConfig) SchemaVersion() uint16 {
+	return 1
+}
 
+func (h *ConfigDB) Save(mainCfg MainConfig) error {
 	storageId := append(h.prefix, []byte("main")...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(storageId, payloadBytes)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating MainConfig db entry instance. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, storageId, mainCfg, 0)
 	if err != nil {
 		return errors.New("Failed saving MainConfig entry. The error is: " + err.Error())
 	}
@@ -53,26 +43,12 @@ func (h *ConfigDB) Save(mainCfg MainConfig) error {
 func (h *ConfigDB) Get() (*MainConfig, error) {
 	storageId := append(h.prefix, []byte("main")...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	mainConfig, err := kv.GetCbor[MainConfig](h.db, storageId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The MainConfig entry with does not exist")
 	} else if err != nil {
-		return nil, errors.New("Failed locating MainConfig entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading MainConfig entry value. The error is: " + err.Error())
-	}
-
-	var mainConfig MainConfig
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &mainConfig)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding MainConfig entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading MainConfig entry. The error is: " + err.Error())
 	}
 
-	return &mainConfig, nil
+	return mainConfig, nil
 }
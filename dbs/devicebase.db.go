@@ -4,20 +4,19 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
 
-	"github.com/dgraph-io/badger/v4"
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
 
 type DeviceBaseDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
-func NewDeviceBaseDB(db *badger.DB) *DeviceBaseDB {
+func NewDeviceBaseDB(db kv.Store) *DeviceBaseDB {
 	return &DeviceBaseDB{
 		db:     db,
 		prefix: []byte("devbasecreds-"),
@@ -25,25 +24,11 @@ func NewDeviceBaseDB(db *badger.DB) *DeviceBaseDB {
 }
 
 func (h *DeviceBaseDB) Save(deviceBaseDB fdoshared.WawDeviceCredential) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(deviceBaseDB)
-	if err != nil {
-		return errors.New("Failed to marshal DeviceBase. The error is: " + err.Error())
-	}
-
 	storageId := append(h.prefix, deviceBaseDB.DCGuid[:]...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(storageId, rvteBytes)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, storageId, deviceBaseDB, 0)
 	if err != nil {
-		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
+		return errors.New("Failed saving DeviceBase entry. The error is: " + err.Error())
 	}
 
 	return nil
@@ -52,64 +37,34 @@ func (h *DeviceBaseDB) Save(deviceBaseDB fdoshared.WawDeviceCredential) error {
 func (h *DeviceBaseDB) Get(guid fdoshared.FdoGuid) (*fdoshared.WawDeviceCredential, error) {
 	storageId := append(h.prefix, guid[:]...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	devCred, err := kv.GetCbor[fdoshared.WawDeviceCredential](h.db, storageId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The devCred entry with id %s does not exist", hex.EncodeToString(guid[:]))
 	} else if err != nil {
-		return nil, errors.New("Failed locating devCred entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading devCred entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading devCred entry. The error is: " + err.Error())
 	}
 
-	var devCred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &devCred)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding devCred entry value. The error is: " + err.Error())
-	}
-
-	return &devCred, nil
+	return devCred, nil
 }
 
-func (h *DeviceBaseDB) GetVANDV(guid fdoshared.FdoGuid, testid testcom.FDOTestID) (*fdoshared.DeviceCredAndVoucher, error) {
-	storageId := append(h.prefix, guid[:]...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("The DevBase entry with id %s does not exist", hex.EncodeToString(guid[:]))
-	} else if err != nil {
-		return nil, errors.New("Failed locating DevBase entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading DevBase entry value. The error is: " + err.Error())
-	}
+func (h *DeviceBaseDB) GetVANDV(guid fdoshared.FdoGuid, testid testcom.FDOTestID, rnd *fdoshared.Conf_Rand, config *fdoshared.Config) (*fdoshared.DeviceCredAndVoucher, error) {
+	randomSgType := fdoshared.RandomSgType(rnd)
+	return h.GetVANDVWithSgType(guid, randomSgType, testid, rnd, config)
+}
 
-	var devCred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &devCred)
+// Generates voucher with the set owner sgType. Voucher RV info comes from the config
+func (h *DeviceBaseDB) GetVANDVWithSgType(guid fdoshared.FdoGuid, voucherSgType fdoshared.DeviceSgType, testid testcom.FDOTestID, rnd *fdoshared.Conf_Rand, config *fdoshared.Config) (*fdoshared.DeviceCredAndVoucher, error) {
+	devCred, err := h.Get(guid)
 	if err != nil {
-		return nil, errors.New("Failed cbor decoding DevBase entry value. The error is: " + err.Error())
+		return nil, err
 	}
 
-	// TODO
-	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
-		"https://localhost:8043",
-	})
+	rvInfo, err := config.VoucherRvInfo()
 	if err != nil {
-		log.Panicln(err)
+		return nil, errors.New("Failed generating voucher RV info. The error is: " + err.Error())
 	}
 
-	randomSgType := fdoshared.RandomSgType()
-	return fdodeviceimplementation.NewVirtualDeviceAndVoucher(devCred, randomSgType, rvInfo, testid)
+	return fdodeviceimplementation.NewVirtualDeviceAndVoucher(*devCred, voucherSgType, rvInfo, testid, rnd)
 }
 
 func (h *DeviceBaseDB) GetMany(guids []fdoshared.FdoGuid) (*[]fdoshared.WawDeviceCredential, error) {
@@ -0,0 +1,292 @@
+package dbs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Seeded device bases of every device sgType. Pool starts with a small batch, and is refilled in the background to the target size.
+// Guids are saved to DevicePoolDB in the order they were generated, so the tests keep picking them with GetTestBatch from the same lists.
+// The picked guids are saved in the test instance, so test runs do not depend on the later pool levels
+
+const DEFAULT_POOL_TARGET_SIZE int = 10000
+
+// Per sgType, generated before the server starts
+const POOL_INITIAL_SIZE int = 100
+
+// Device bases are generated and saved in batches, so the level is updated while the pool is refilling
+const POOL_SAVE_BATCH int = 50
+
+// Guids imported from MainConfig are saved in batches, so large pools do not exceed transaction size
+const POOL_IMPORT_BATCH int = 1000
+
+// How long GetSeededGuids waits for the background refill, before generating the missing keys itself
+const POOL_WAIT_TIME time.Duration = 30 * time.Second
+
+type DeviceBasePool struct {
+	configDB    *ConfigDB
+	poolDB      *DevicePoolDB
+	devBaseDB   *DeviceBaseDB
+	targetSize  int
+	initialSize int
+
+	lock        sync.Mutex
+	levelChange *sync.Cond
+	seededGuids fdoshared.FdoSeedIDs
+	refilling   bool
+}
+
+type DeviceBasePoolLevel struct {
+	SgType fdoshared.DeviceSgType `json:"sgType"`
+	Level  int                    `json:"level"`
+}
+
+type DeviceBasePoolStatus struct {
+	TargetSize int                   `json:"targetSize"`
+	Refilling  bool                  `json:"refilling"`
+	Levels     []DeviceBasePoolLevel `json:"levels"`
+}
+
+func NewDeviceBasePool(configDB *ConfigDB, poolDB *DevicePoolDB, devBaseDB *DeviceBaseDB, targetSize int) *DeviceBasePool {
+	pool := DeviceBasePool{
+		configDB:    configDB,
+		poolDB:      poolDB,
+		devBaseDB:   devBaseDB,
+		targetSize:  targetSize,
+		initialSize: POOL_INITIAL_SIZE,
+		seededGuids: fdoshared.FdoSeedIDs{},
+	}
+	pool.levelChange = sync.NewCond(&pool.lock)
+
+	return &pool
+}
+
+// Loads seeded guids, and generates initial batch for the empty sgTypes
+func (h *DeviceBasePool) Init() error {
+	err := h.importMainConfig()
+	if err != nil {
+		return err
+	}
+
+	seededGuids, err := h.poolDB.GetAll()
+	if err != nil {
+		return err
+	}
+
+	h.seededGuids = seededGuids
+	if len(h.seededGuids) == 0 {
+		log.Println("Database is not seeded. Generating initial device bases...")
+	}
+
+	initialSize := h.initialSize
+	if initialSize > h.targetSize {
+		initialSize = h.targetSize
+	}
+
+	return h.fillTo(initialSize)
+}
+
+// Older versions saved the guid list in MainConfig. Moves it to DevicePoolDB in the same order, and clears it from MainConfig
+func (h *DeviceBasePool) importMainConfig() error {
+	mainConfig, err := h.configDB.Get()
+	if err != nil || len(mainConfig.SeededGuids) == 0 {
+		return nil
+	}
+
+	for sgType, guids := range mainConfig.SeededGuids {
+		for position := 0; position < len(guids); position += POOL_IMPORT_BATCH {
+			end := position + POOL_IMPORT_BATCH
+			if end > len(guids) {
+				end = len(guids)
+			}
+
+			err := h.poolDB.Add(sgType, position, guids[position:end])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Println("Moved seeded guids from MainConfig to device pool")
+	return h.configDB.Save(MainConfig{SeededGuids: fdoshared.FdoSeedIDs{}})
+}
+
+// Refills pool to the target size in the background
+func (h *DeviceBasePool) StartRefill() {
+	go func() {
+		err := h.Fill()
+		if err != nil {
+			log.Println("Device base pool refill failed. " + err.Error())
+		}
+	}()
+}
+
+// Fills pool to the target size, and returns when done
+func (h *DeviceBasePool) Fill() error {
+	h.lock.Lock()
+	if h.refilling {
+		h.lock.Unlock()
+		return errors.New("Device base pool is already refilling")
+	}
+	h.refilling = true
+	h.lock.Unlock()
+
+	defer func() {
+		h.lock.Lock()
+		h.refilling = false
+		h.levelChange.Broadcast()
+		h.lock.Unlock()
+	}()
+
+	err := h.fillTo(h.targetSize)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Device base pool filled to %d per sgType", h.targetSize)
+	return nil
+}
+
+// Generates keys for every sgType concurrently
+func (h *DeviceBasePool) fillTo(size int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(fdoshared.DeviceSgTypeList))
+
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		wg.Add(1)
+		go func(sgType fdoshared.DeviceSgType) {
+			defer wg.Done()
+
+			for {
+				missing := size - h.level(sgType)
+				if missing <= 0 {
+					return
+				}
+
+				if missing > POOL_SAVE_BATCH {
+					missing = POOL_SAVE_BATCH
+				}
+
+				err := h.generate(sgType, missing)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(sgType)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (h *DeviceBasePool) level(sgType fdoshared.DeviceSgType) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return len(h.seededGuids[sgType])
+}
+
+func (h *DeviceBasePool) generate(sgType fdoshared.DeviceSgType, count int) error {
+	newGuids := fdoshared.FdoGuidList{}
+	for i := 0; i < count; i++ {
+		newDeviceBase, err := fdoshared.NewWawDeviceCredential(sgType)
+		if err != nil {
+			return fmt.Errorf("Error generating device base for sgType %d. %s", sgType, err.Error())
+		}
+
+		err = h.devBaseDB.Save(*newDeviceBase)
+		if err != nil {
+			return err
+		}
+
+		newGuids = append(newGuids, newDeviceBase.DCGuid)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	err := h.poolDB.Add(sgType, len(h.seededGuids[sgType]), newGuids)
+	if err != nil {
+		return err
+	}
+
+	h.seededGuids[sgType] = append(h.seededGuids[sgType], newGuids...)
+
+	h.levelChange.Broadcast()
+	return nil
+}
+
+// Returns copy of seeded guids with at least minLevel guids per sgType, capped by the target size.
+// Waits for the background refill, and generates the missing keys when it does not catch up in POOL_WAIT_TIME
+func (h *DeviceBasePool) GetSeededGuids(minLevel int) (fdoshared.FdoSeedIDs, error) {
+	if minLevel > h.targetSize {
+		minLevel = h.targetSize
+	}
+
+	deadline := time.Now().Add(POOL_WAIT_TIME)
+	timer := time.AfterFunc(POOL_WAIT_TIME, func() {
+		h.lock.Lock()
+		h.levelChange.Broadcast()
+		h.lock.Unlock()
+	})
+	defer timer.Stop()
+
+	h.lock.Lock()
+	for h.refilling && !h.hasLevel(minLevel) && time.Now().Before(deadline) {
+		h.levelChange.Wait()
+	}
+	h.lock.Unlock()
+
+	err := h.fillTo(minLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	seededGuids := fdoshared.FdoSeedIDs{}
+	for sgType, guids := range h.seededGuids {
+		seededGuids[sgType] = append(fdoshared.FdoGuidList{}, guids...)
+	}
+
+	return seededGuids, nil
+}
+
+func (h *DeviceBasePool) hasLevel(minLevel int) bool {
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		if len(h.seededGuids[sgType]) < minLevel {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *DeviceBasePool) Status() DeviceBasePoolStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	status := DeviceBasePoolStatus{
+		TargetSize: h.targetSize,
+		Refilling:  h.refilling,
+		Levels:     []DeviceBasePoolLevel{},
+	}
+
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		status.Levels = append(status.Levels, DeviceBasePoolLevel{
+			SgType: sgType,
+			Level:  len(h.seededGuids[sgType]),
+		})
+	}
+
+	return status
+}
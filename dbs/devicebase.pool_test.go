@@ -0,0 +1,161 @@
+package dbs
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+)
+
+func TestDeviceBasePool(t *testing.T) {
+	store := kv.NewMemoryStore()
+	pool := NewDeviceBasePool(NewConfigDB(store), NewDevicePoolDB(store), NewDeviceBaseDB(store), 6)
+	pool.initialSize = 2
+
+	err := pool.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range pool.Status().Levels {
+		if level.Level != 2 {
+			t.Fatalf("expected initial level 2 for sgType %d, got %d", level.SgType, level.Level)
+		}
+	}
+
+	// Refill is not running, so missing keys are generated by the caller
+	seededGuids, err := pool.GetSeededGuids(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		if len(seededGuids[sgType]) < 4 {
+			t.Fatalf("expected at least 4 guids for sgType %d, got %d", sgType, len(seededGuids[sgType]))
+		}
+
+		_, err := pool.devBaseDB.Get(seededGuids[sgType][0])
+		if err != nil {
+			t.Fatalf("seeded device base is not saved. %v", err)
+		}
+	}
+
+	err = pool.Fill()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reloaded pool continues from the saved guids
+	reloadedPool := NewDeviceBasePool(NewConfigDB(store), NewDevicePoolDB(store), NewDeviceBaseDB(store), 6)
+	err = reloadedPool.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range reloadedPool.Status().Levels {
+		if level.Level != 6 {
+			t.Fatalf("expected level 6 for sgType %d, got %d", level.SgType, level.Level)
+		}
+	}
+
+	seededGuids, err = reloadedPool.GetSeededGuids(100)
+	if err != nil || len(seededGuids[fdoshared.StSECP256R1]) != 6 {
+		t.Fatalf("expected min level to be capped by target size, got %d, %v", len(seededGuids[fdoshared.StSECP256R1]), err)
+	}
+}
+
+func TestDeviceBasePool_StableOrder(t *testing.T) {
+	store := kv.NewMemoryStore()
+	pool := NewDeviceBasePool(NewConfigDB(store), NewDevicePoolDB(store), NewDeviceBaseDB(store), 3)
+	pool.initialSize = 1
+
+	err := pool.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pool.Fill()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seededGuids, _ := pool.GetSeededGuids(3)
+
+	// Only the pool entries are saved, not the guid list
+	_, err = NewConfigDB(store).Get()
+	if err == nil {
+		t.Fatal("expected MainConfig not to be saved")
+	}
+
+	reloadedPool := NewDeviceBasePool(NewConfigDB(store), NewDevicePoolDB(store), NewDeviceBaseDB(store), 3)
+	err = reloadedPool.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloadedGuids, _ := reloadedPool.GetSeededGuids(3)
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		for i, guid := range seededGuids[sgType] {
+			if !reloadedGuids[sgType][i].Equals(guid) {
+				t.Fatalf("expected reloaded guids of sgType %d in the same order", sgType)
+			}
+		}
+	}
+
+	// Same seed picks the same guids after reload
+	batch := seededGuids.GetTestBatch(fdoshared.NewConf_Rand(1), 2)
+	reloadedBatch := reloadedGuids.GetTestBatch(fdoshared.NewConf_Rand(1), 2)
+	for sgType, guids := range batch {
+		for i, guid := range guids {
+			if !reloadedBatch[sgType][i].Equals(guid) {
+				t.Fatalf("expected same test batch for sgType %d after reload", sgType)
+			}
+		}
+	}
+}
+
+func TestDeviceBasePool_ImportMainConfig(t *testing.T) {
+	store := kv.NewMemoryStore()
+
+	savedGuids := fdoshared.FdoSeedIDs{}
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		for i := 0; i < 3; i++ {
+			newDeviceBase, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			savedGuids[sgType] = append(savedGuids[sgType], newDeviceBase.DCGuid)
+		}
+	}
+
+	err := NewConfigDB(store).Save(MainConfig{SeededGuids: savedGuids})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewDeviceBasePool(NewConfigDB(store), NewDevicePoolDB(store), NewDeviceBaseDB(store), 3)
+	pool.initialSize = 3
+	err = pool.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seededGuids, _ := pool.GetSeededGuids(3)
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		if len(seededGuids[sgType]) != 3 {
+			t.Fatalf("expected 3 imported guids for sgType %d, got %d", sgType, len(seededGuids[sgType]))
+		}
+
+		for i, guid := range savedGuids[sgType] {
+			if !seededGuids[sgType][i].Equals(guid) {
+				t.Fatalf("expected imported guids of sgType %d in the saved order", sgType)
+			}
+		}
+	}
+
+	mainConfig, err := NewConfigDB(store).Get()
+	if err != nil || len(mainConfig.SeededGuids) != 0 {
+		t.Fatalf("expected MainConfig guid list to be cleared. %v", err)
+	}
+}
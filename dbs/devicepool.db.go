@@ -0,0 +1,71 @@
+package dbs
+
+import (
+	"errors"
+	"fmt"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+)
+
+// Guids of the seeded device bases. Every guid is its own entry, so growing the pool only writes the new guids
+type DevicePoolDB struct {
+	db     kv.Store
+	prefix []byte
+}
+
+func NewDevicePoolDB(db kv.Store) *DevicePoolDB {
+	return &DevicePoolDB{
+		db:     db,
+		prefix: []byte("devpool-"),
+	}
+}
+
+type DevicePoolEntry struct {
+	_      struct{} `cbor:",toarray"`
+	SgType fdoshared.DeviceSgType
+	Guid   fdoshared.FdoGuid
+}
+
+func (h DevicePoolEntry) SchemaVersion() uint16 {
+	return 1
+}
+
+// Position is zero padded, so entries of the sgType are iterated in the order they were added
+func (h *DevicePoolDB) entryId(sgType fdoshared.DeviceSgType, position int) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(fmt.Sprintf("%d-%010d", sgType, position))...)
+}
+
+// Saves guids of the sgType, starting from position
+func (h *DevicePoolDB) Add(sgType fdoshared.DeviceSgType, position int, guids fdoshared.FdoGuidList) error {
+	err := h.db.Update(func(txn kv.Txn) error {
+		for i, guid := range guids {
+			err := kv.SetCbor(txn, h.entryId(sgType, position+i), DevicePoolEntry{SgType: sgType, Guid: guid}, 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New("Failed saving DevicePool entries. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// Returns guids of every sgType, in the order they were added
+func (h *DevicePoolDB) GetAll() (fdoshared.FdoSeedIDs, error) {
+	seededGuids := fdoshared.FdoSeedIDs{}
+
+	err := kv.IterateCbor(h.db, h.prefix, func(key []byte, poolEntry DevicePoolEntry) error {
+		seededGuids[poolEntry.SgType] = append(seededGuids[poolEntry.SgType], poolEntry.Guid)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed reading DevicePool entries. The error is: " + err.Error())
+	}
+
+	return seededGuids, nil
+}
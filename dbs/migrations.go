@@ -0,0 +1,145 @@
+package dbs
+
+import (
+	"bytes"
+	"log"
+
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// Migrations of the persisted records, in order. To change encoding of a persisted type, bump its SchemaVersion,
+// keep the previous encoding as <Type>V<version>, and add migration from the previous version for every prefix the type is stored under, e.g.
+//
+//	{
+//		Prefix:      []byte("usere-"),
+//		FromVersion: 1,
+//		Description: "Add user locale",
+//		Migrate: kv.MigrateCbor(func(oldEntry UserTestDBEntryV1) (UserTestDBEntry, error) {
+//			...
+//		}),
+//	},
+var Migrations = []kv.Migration{
+	// User, RV and DO sessions shared session- prefix
+	{
+		Prefix:       []byte("session-"),
+		FromVersion:  1,
+		Description:  "Move user sessions to usersession- prefix",
+		Match:        kv.MatchCbor[SessionEntry](),
+		MoveToPrefix: []byte("usersession-"),
+	},
+	{
+		Prefix:       []byte("session-"),
+		FromVersion:  1,
+		Description:  "Move RV sessions to rvsession- prefix",
+		Match:        kv.MatchCbor[fdorv.SessionEntryV1](),
+		MoveToPrefix: []byte("rvsession-"),
+	},
+	{
+		Prefix:       []byte("session-"),
+		FromVersion:  1,
+		Description:  "Move DO sessions to dosession- prefix",
+		Match:        kv.MatchCbor[dodbs.SessionEntryV1](),
+		MoveToPrefix: []byte("dosession-"),
+	},
+
+	// RV and DO sessions got correlation ID
+	{
+		Prefix:      []byte("rvsession-"),
+		FromVersion: 1,
+		Description: "Add correlation ID to RV sessions",
+		Migrate:     kv.MigrateCbor(fdorv.MigrateSessionEntryV1),
+	},
+	{
+		Prefix:      []byte("dosession-"),
+		FromVersion: 1,
+		Description: "Add correlation ID to DO sessions",
+		Migrate:     kv.MigrateCbor(dodbs.MigrateSessionEntryV1),
+	},
+
+	// Test states got FuzzSeed
+	{
+		Prefix:      []byte("rvte-"),
+		FromVersion: 1,
+		Description: "Add fuzz seed to requestor test states",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestTestInstV1),
+	},
+	{
+		Prefix:      []byte("lstdb-"),
+		FromVersion: 1,
+		Description: "Add fuzz seed to listener test states",
+		Match:       isListenerTestEntry,
+		Migrate:     kv.MigrateCbor(listenertestsdeps.MigrateRequestListenerInstV1),
+	},
+
+	// Test runs got seeds
+	{
+		Prefix:      []byte("rvte-"),
+		FromVersion: 2,
+		Description: "Add seed to requestor test runs",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestTestInstV2),
+	},
+	{
+		Prefix:      []byte("lstdb-"),
+		FromVersion: 2,
+		Description: "Add seed to listener test runs",
+		Match:       isListenerTestEntry,
+		Migrate:     kv.MigrateCbor(listenertestsdeps.MigrateRequestListenerInstV2),
+	},
+
+	// Requestor test runs got status
+	{
+		Prefix:      []byte("rvte-"),
+		FromVersion: 3,
+		Description: "Add status to requestor test runs",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestTestInstV3),
+	},
+
+	// Requestor test runs and run jobs got test selection
+	{
+		Prefix:      []byte("rvte-"),
+		FromVersion: 4,
+		Description: "Add test selection to requestor test runs",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestTestInstV4),
+	},
+	{
+		Prefix:      []byte("rvtjob-"),
+		FromVersion: 1,
+		Description: "Add test selection to run jobs",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestRunJobV1),
+	},
+
+	// Requestor test runs got TO2 negotiation matrix
+	{
+		Prefix:      []byte("rvte-"),
+		FromVersion: 5,
+		Description: "Add negotiation matrix to requestor test runs",
+		Migrate:     kv.MigrateCbor(reqtestsdeps.MigrateRequestTestInstV5),
+	},
+
+	// Organisations got invites
+	{
+		Prefix:      []byte("org-"),
+		FromVersion: 1,
+		Description: "Add invites to organisations",
+		Migrate:     kv.MigrateCbor(MigrateOrgEntryV1),
+	},
+}
+
+// lstdb- prefix also has guid mapping entries
+func isListenerTestEntry(key []byte, record []byte) bool {
+	return !bytes.HasPrefix(key, []byte("lstdb-guid-map-"))
+}
+
+// Runs on startup and after import, so the records of older versions are upgraded
+func RunMigrations(db kv.Store) error {
+	migratedCount, err := kv.RunMigrations(db, Migrations)
+	if migratedCount != 0 {
+		log.Printf("Migrated %d DB records", migratedCount)
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package dbs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// Records in testdata/baseline were encoded by the code before record versioning,
+// so they are untagged version 1 records, as found in the DBs of earlier releases
+func test_loadBaselineRecord(t *testing.T, store kv.Store, name string, key []byte) {
+	recordBytes, err := os.ReadFile("testdata/baseline/" + name + ".v1.cbor")
+	if err != nil {
+		t.Fatalf("Failed to read baseline record: %v", err)
+	}
+
+	store.Set(key, recordBytes, time.Hour)
+}
+
+func TestMigrations_BaselineRecords(t *testing.T) {
+	store := kv.NewMemoryStore()
+	guid := fdoshared.FdoGuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	test_loadBaselineRecord(t, store, "rvte", []byte("rvte-\xaa\xbb"))
+	test_loadBaselineRecord(t, store, "lstdb", []byte("lstdb-\xcc\xdd"))
+	store.Set(append([]byte("lstdb-guid-map-"), guid[:]...), []byte{0xcc, 0xdd}, time.Hour)
+	test_loadBaselineRecord(t, store, "usersession", []byte("session-user"))
+	test_loadBaselineRecord(t, store, "rvsession", []byte("session-rv"))
+	test_loadBaselineRecord(t, store, "dosession", []byte("session-do"))
+
+	kv.SetCbor(store, []byte("rvtjob-\xaa\xbb"), reqtestsdeps.RequestRunJobV1{
+		RequestTestId:  []byte{0xaa, 0xbb},
+		RunId:          "run-2",
+		Protocol:       fdoshared.To2,
+		Seed:           42,
+		CompletedTests: []testcom.FDOTestID{testcom.FIDO_DOT_60_POSITIVE},
+	}, time.Hour)
+
+	err := RunMigrations(store)
+	if err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	reqtDB := testdbs.NewRequestTestDB(store)
+	rvte, err := reqtDB.Get([]byte{0xaa, 0xbb})
+	if err != nil {
+		t.Fatalf("Failed to read migrated rvte: %v", err)
+	}
+
+	if rvte.InProgress || rvte.CurrentTestRun.Status != reqtestsdeps.RunStatus_Aborted {
+		t.Errorf("Expected baseline run in progress to be aborted. Got %v %s", rvte.InProgress, rvte.CurrentTestRun.Status)
+	}
+
+	if len(rvte.TestsHistory) != 2 || rvte.TestsHistory[1].Status != reqtestsdeps.RunStatus_Completed {
+		t.Fatalf("Expected 2 runs in history, the old one completed. Got %+v", rvte.TestsHistory)
+	}
+
+	failedState := rvte.TestsHistory[1].Tests[testcom.FIDO_DOT_62_BAD_ENCODING]
+	if failedState.Passed || failedState.Error != "Expected error" || failedState.TestID != testcom.FIDO_DOT_62_BAD_ENCODING {
+		t.Errorf("Expected failed test state to be kept. Got %+v", failedState)
+	}
+
+	if len(rvte.FdoSeedIDs[fdoshared.StSECP256R1]) != 1 || rvte.FdoSeedIDs[fdoshared.StSECP256R1][0] != guid {
+		t.Errorf("Expected seed ids to be kept. Got %v", rvte.FdoSeedIDs)
+	}
+
+	runJob, err := reqtDB.GetRunJob([]byte{0xaa, 0xbb})
+	if err != nil || runJob.Seed != 42 || !runJob.IsTestCompleted(testcom.FIDO_DOT_60_POSITIVE) || !runJob.Selection.IsEmpty() {
+		t.Errorf("Failed to read migrated run job. Got %+v %v", runJob, err)
+	}
+
+	listenerInst, err := testdbs.NewListenerTestDB(store).GetEntryByFdoGuid(guid)
+	if err != nil {
+		t.Fatalf("Failed to read migrated listener test: %v", err)
+	}
+
+	testRuns := listenerInst.To2.CurrentTestRun.TestRuns
+	if listenerInst.To2.CurrentTestRun.Uuid != "listener-run-1" || len(testRuns) != 2 || testRuns[0].Error != "Bad signature accepted" || !testRuns[1].Passed {
+		t.Errorf("Expected listener test run to be kept. Got %+v", listenerInst.To2.CurrentTestRun)
+	}
+
+	userSession, err := NewSessionDB(store).GetSessionEntry([]byte("user"))
+	if err != nil || userSession == nil || userSession.Email != "user@example.com" || !userSession.LoggedIn {
+		t.Errorf("Failed to read moved user session. Got %+v %v", userSession, err)
+	}
+
+	rvSession, err := fdorv.NewSessionDB(store, time.Hour).GetSessionEntry([]byte("rv"))
+	if err != nil || rvSession == nil || rvSession.Guid != guid || rvSession.Cid == 0 {
+		t.Errorf("Failed to read moved RV session. Got %+v %v", rvSession, err)
+	}
+
+	doSession, err := dodbs.NewSessionDB(store, time.Hour).GetSessionEntry([]byte("do"))
+	if err != nil || doSession == nil || doSession.PrevCMD != fdoshared.TO2_60_HELLO_DEVICE || doSession.Cid == 0 {
+		t.Errorf("Failed to read moved DO session. Got %+v %v", doSession, err)
+	}
+
+	migratedCount, err := kv.RunMigrations(store, Migrations)
+	if err != nil || migratedCount != 0 {
+		t.Errorf("Expected second run to migrate nothing. Got %d %v", migratedCount, err)
+	}
+}
+
+func TestMigrations_OrgWithoutInvites(t *testing.T) {
+	store := kv.NewMemoryStore()
+
+	kv.SetCbor(store, []byte("org-vendor"), OrgEntryV1{
+		Id:          "vendor",
+		Name:        "Vendor",
+		Members:     []OrgMember{{Email: "owner@example.com", Role: OR_Owner}},
+		RVTestInsts: []RVTestInst{NewRVTestInst("http://rv.example.com", []byte("to0"), []byte("to1"))},
+	}, 0)
+
+	err := RunMigrations(store)
+	if err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	orgEntry, err := NewOrgDB(store).Get("vendor")
+	if err != nil {
+		t.Fatalf("Failed to read migrated org: %v", err)
+	}
+
+	if orgEntry.Name != "Vendor" || len(orgEntry.Members) != 1 || len(orgEntry.RVTestInsts) != 1 || len(orgEntry.Invites) != 0 {
+		t.Errorf("Unexpected migrated org %+v", orgEntry)
+	}
+}
@@ -0,0 +1,351 @@
+package dbs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/google/uuid"
+)
+
+// Organisations share test instances between members. A user can be member of one organisation
+
+type OrgRole string
+
+const (
+	OR_Owner  OrgRole = "owner"
+	OR_Tester OrgRole = "tester"
+	OR_Viewer OrgRole = "viewer"
+)
+
+func IsValidOrgRole(role OrgRole) bool {
+	return role == OR_Owner || role == OR_Tester || role == OR_Viewer
+}
+
+type OrgMember struct {
+	_     struct{} `cbor:",toarray"`
+	Email string
+	Role  OrgRole
+}
+
+type OrgEntry struct {
+	_       struct{} `cbor:",toarray"`
+	Id      string
+	Name    string
+	Members []OrgMember
+
+	RVTestInsts     []RVTestInst
+	DOTestInsts     []DOTestInst
+	DeviceTestInsts []DeviceTestInst
+
+	// Users become members only when they accept the invite
+	Invites []OrgMember
+}
+
+func (h OrgEntry) SchemaVersion() uint16 {
+	return 2
+}
+
+// Before invites
+type OrgEntryV1 struct {
+	_       struct{} `cbor:",toarray"`
+	Id      string
+	Name    string
+	Members []OrgMember
+
+	RVTestInsts     []RVTestInst
+	DOTestInsts     []DOTestInst
+	DeviceTestInsts []DeviceTestInst
+}
+
+func MigrateOrgEntryV1(oldEntry OrgEntryV1) (OrgEntry, error) {
+	return OrgEntry{
+		Id:              oldEntry.Id,
+		Name:            oldEntry.Name,
+		Members:         oldEntry.Members,
+		RVTestInsts:     oldEntry.RVTestInsts,
+		DOTestInsts:     oldEntry.DOTestInsts,
+		DeviceTestInsts: oldEntry.DeviceTestInsts,
+		Invites:         []OrgMember{},
+	}, nil
+}
+
+func (h *OrgEntry) GetMember(email string) *OrgMember {
+	email = strings.ToLower(email)
+	for i, member := range h.Members {
+		if member.Email == email {
+			return &h.Members[i]
+		}
+	}
+
+	return nil
+}
+
+func (h *OrgEntry) GetInvite(email string) *OrgMember {
+	email = strings.ToLower(email)
+	for i, invite := range h.Invites {
+		if invite.Email == email {
+			return &h.Invites[i]
+		}
+	}
+
+	return nil
+}
+
+// Sets role of the member, or invites the user with the role
+func (h *OrgEntry) SetMemberOrInvite(email string, role OrgRole) {
+	email = strings.ToLower(email)
+	if member := h.GetMember(email); member != nil {
+		member.Role = role
+	} else if invite := h.GetInvite(email); invite != nil {
+		invite.Role = role
+	} else {
+		h.Invites = append(h.Invites, OrgMember{Email: email, Role: role})
+	}
+}
+
+func (h *OrgEntry) RemoveInvite(email string) bool {
+	email = strings.ToLower(email)
+	invites := []OrgMember{}
+	for _, invite := range h.Invites {
+		if invite.Email != email {
+			invites = append(invites, invite)
+		}
+	}
+
+	removed := len(invites) != len(h.Invites)
+	h.Invites = invites
+	return removed
+}
+
+// Moves the user's own test instances to the organisation
+func (h *OrgEntry) takeTestInsts(userInst *UserTestDBEntry) {
+	h.RVTestInsts = append(h.RVTestInsts, userInst.RVTestInsts...)
+	h.DOTestInsts = append(h.DOTestInsts, userInst.DOTestInsts...)
+	h.DeviceTestInsts = append(h.DeviceTestInsts, userInst.DeviceTestInsts...)
+
+	userInst.RVTestInsts = []RVTestInst{}
+	userInst.DOTestInsts = []DOTestInst{}
+	userInst.DeviceTestInsts = []DeviceTestInst{}
+}
+
+func (h *OrgEntry) CountOwners() int {
+	owners := 0
+	for _, member := range h.Members {
+		if member.Role == OR_Owner {
+			owners++
+		}
+	}
+
+	return owners
+}
+
+type OrgDB struct {
+	db           kv.Store
+	prefix       []byte
+	memberPrefix []byte
+}
+
+func NewOrgDB(db kv.Store) *OrgDB {
+	return &OrgDB{
+		db:           db,
+		prefix:       []byte("org-"),
+		memberPrefix: []byte("orgmember-"),
+	}
+}
+
+func (h *OrgDB) orgDbId(orgId string) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(orgId)...)
+}
+
+func (h *OrgDB) memberDbId(email string) []byte {
+	return append(append([]byte{}, h.memberPrefix...), []byte(strings.ToLower(email))...)
+}
+
+func (h *OrgDB) getTxn(txn kv.Reader, orgId string) (*OrgEntry, error) {
+	orgEntry, err := kv.GetCbor[OrgEntry](txn, h.orgDbId(orgId))
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The org entry with id %s does not exist", orgId)
+	} else if err != nil {
+		return nil, errors.New("Failed reading org entry. The error is: " + err.Error())
+	}
+
+	return orgEntry, nil
+}
+
+// Saves org, and updates member index for added and removed members
+func (h *OrgDB) saveTxn(txn kv.Txn, orgEntry OrgEntry, previousMembers []OrgMember) error {
+	for _, member := range orgEntry.Members {
+		memberOrgId, err := txn.Get(h.memberDbId(member.Email))
+		if err == nil {
+			if string(memberOrgId) != orgEntry.Id {
+				return fmt.Errorf("User %s is already member of another organisation", member.Email)
+			}
+		} else if !errors.Is(err, kv.ErrKeyNotFound) {
+			return errors.New("Failed locating member entry. The error is: " + err.Error())
+		}
+
+		err = txn.Set(h.memberDbId(member.Email), []byte(orgEntry.Id), 0)
+		if err != nil {
+			return errors.New("Failed creating member db entry instance. The error is: " + err.Error())
+		}
+	}
+
+	for _, previousMember := range previousMembers {
+		if orgEntry.GetMember(previousMember.Email) == nil {
+			err := txn.Delete(h.memberDbId(previousMember.Email))
+			if err != nil {
+				return errors.New("Failed deleting member entry. The error is: " + err.Error())
+			}
+		}
+	}
+
+	err := kv.SetCbor(txn, h.orgDbId(orgEntry.Id), orgEntry, 0)
+	if err != nil {
+		return errors.New("Failed saving org entry. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// Creator becomes the owner, and their test instances move to the organisation in the same transaction.
+// userDB must use the same store
+func (h *OrgDB) Create(name string, ownerEmail string, userDB *UserTestDB) (*OrgEntry, error) {
+	orgId, _ := uuid.NewRandom()
+	orgEntry := OrgEntry{
+		Id:      orgId.String(),
+		Name:    name,
+		Members: []OrgMember{{Email: strings.ToLower(ownerEmail), Role: OR_Owner}},
+		Invites: []OrgMember{},
+	}
+
+	err := h.db.Update(func(txn kv.Txn) error {
+		userInst, err := userDB.getTxn(txn, ownerEmail)
+		if err != nil {
+			return err
+		}
+
+		orgEntry.takeTestInsts(userInst)
+
+		err = h.saveTxn(txn, orgEntry, nil)
+		if err != nil {
+			return err
+		}
+
+		return userDB.saveTxn(txn, *userInst)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &orgEntry, nil
+}
+
+// Invited user becomes a member, and their test instances move to the organisation in the same transaction.
+// userDB must use the same store
+func (h *OrgDB) AcceptInvite(orgId string, email string, userDB *UserTestDB) (*OrgEntry, error) {
+	var orgEntry *OrgEntry
+	err := h.db.Update(func(txn kv.Txn) error {
+		currentOrg, err := h.getTxn(txn, orgId)
+		if err != nil {
+			return err
+		}
+
+		invite := currentOrg.GetInvite(email)
+		if invite == nil {
+			return fmt.Errorf("User %s is not invited to organisation %s", email, orgId)
+		}
+
+		userInst, err := userDB.getTxn(txn, email)
+		if err != nil {
+			return err
+		}
+
+		previousMembers := append([]OrgMember{}, currentOrg.Members...)
+		currentOrg.Members = append(currentOrg.Members, *invite)
+		currentOrg.RemoveInvite(email)
+		currentOrg.takeTestInsts(userInst)
+
+		err = h.saveTxn(txn, *currentOrg, previousMembers)
+		if err != nil {
+			return err
+		}
+
+		orgEntry = currentOrg
+		return userDB.saveTxn(txn, *userInst)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orgEntry, nil
+}
+
+// Returns organisations the user is invited to
+func (h *OrgDB) ListInvites(email string) ([]OrgEntry, error) {
+	orgEntries := []OrgEntry{}
+	err := kv.IterateCbor(h.db, h.prefix, func(key []byte, orgEntry OrgEntry) error {
+		if orgEntry.GetInvite(email) != nil {
+			orgEntries = append(orgEntries, orgEntry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed listing org invites. The error is: " + err.Error())
+	}
+
+	return orgEntries, nil
+}
+
+func (h *OrgDB) Get(orgId string) (*OrgEntry, error) {
+	return h.getTxn(h.db, orgId)
+}
+
+// Returns nil org when user is not a member of any organisation
+func (h *OrgDB) GetByMember(email string) (*OrgEntry, error) {
+	var orgEntry *OrgEntry
+	err := h.db.View(func(txn kv.Reader) error {
+		orgId, err := txn.Get(h.memberDbId(email))
+		if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return errors.New("Failed locating member entry. The error is: " + err.Error())
+		}
+
+		orgEntry, err = h.getTxn(txn, string(orgId))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orgEntry, nil
+}
+
+// Reads, modifies and saves org in one transaction. Store retries it on conflict with concurrent update
+func (h *OrgDB) Update(orgId string, modify func(orgEntry *OrgEntry) error) (*OrgEntry, error) {
+	var orgEntry *OrgEntry
+	err := h.db.Update(func(txn kv.Txn) error {
+		currentOrg, err := h.getTxn(txn, orgId)
+		if err != nil {
+			return err
+		}
+
+		previousMembers := append([]OrgMember{}, currentOrg.Members...)
+
+		err = modify(currentOrg)
+		if err != nil {
+			return err
+		}
+
+		orgEntry = currentOrg
+		return h.saveTxn(txn, *currentOrg, previousMembers)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orgEntry, nil
+}
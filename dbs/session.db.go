@@ -4,22 +4,22 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/google/uuid"
 )
 
 type SessionDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
-func NewSessionDB(db *badger.DB) *SessionDB {
+func NewSessionDB(db kv.Store) *SessionDB {
 	return &SessionDB{
 		db:     db,
-		prefix: []byte("session-"),
+		prefix: []byte("usersession-"),
 	}
 }
 
@@ -40,26 +40,16 @@ type SessionEntry struct {
 	PasswordResetTimestamp time.Time
 }
 
-func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
-	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
+func (h SessionEntry) SchemaVersion() uint16 {
+	return 1
+}
 
+func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 	randomEntryId, _ := uuid.NewRandom()
 	randomEntryIdString := randomEntryId.String()
 	sessionEntryId := append(h.prefix, []byte(randomEntryIdString)...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(MAX_SESSION_TIME)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, MAX_SESSION_TIME)
 	if err != nil {
 		return []byte{}, errors.New("Failed saving session entry. The error is: " + err.Error())
 	}
@@ -70,20 +60,7 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry) error {
 	sessionEntryId := append(h.prefix, entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
-	if err != nil {
-		return errors.New("Failed to marshal session. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Set(sessionEntryId, sessionInstBytes)
-	if err != nil {
-		return errors.New("Failed to create saving inst. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, sessionEntryId, sessionInst, 0)
 	if err != nil {
 		return errors.New("Failed to save session. The error is: " + err.Error())
 	}
@@ -94,45 +71,43 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
 	sessionEntryId := append(h.prefix, entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(sessionEntryId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	sessionEntryInst, err := kv.GetCbor[SessionEntry](h.db, sessionEntryId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The session entry with id %s does not exist", hex.EncodeToString(entryId))
 	} else if err != nil {
-		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading session entry. The error is: " + err.Error())
 	}
 
-	var sessionEntryInst SessionEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
-	}
-
-	return &sessionEntryInst, nil
+	return sessionEntryInst, nil
 }
 
 func (h *SessionDB) DeleteSessionEntry(entryId []byte) error {
 	sessionEntryId := append(h.prefix, entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	err := dbtxn.Delete(sessionEntryId)
-	if err != nil {
-		return errors.New("Failed initialise delete entry. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := h.db.Delete(sessionEntryId)
 	if err != nil {
 		return errors.New("Failed to delete session. The error is: " + err.Error())
 	}
 
 	return nil
 }
+
+// Deletes every login and password reset session of the user
+func (h *SessionDB) DeleteUserSessions(email string) error {
+	email = strings.ToLower(email)
+
+	return h.db.Update(func(txn kv.Txn) error {
+		return kv.IterateCbor(txn, h.prefix, func(key []byte, sessionInst SessionEntry) error {
+			if strings.ToLower(sessionInst.Email) != email && strings.ToLower(sessionInst.PasswordResetEmail) != email {
+				return nil
+			}
+
+			err := txn.Delete(key)
+			if err != nil {
+				return errors.New("Failed to delete session. The error is: " + err.Error())
+			}
+
+			return nil
+		})
+	})
+}
@@ -7,12 +7,11 @@ import (
 	"log"
 	"strings"
 
-	"github.com/dgraph-io/badger/v4"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 )
 
 // DB Methods
-func NewUserTestDB(db *badger.DB) *UserTestDB {
+func NewUserTestDB(db kv.Store) *UserTestDB {
 	return &UserTestDB{
 		db:     db,
 		prefix: []byte("usere-"),
@@ -20,25 +19,14 @@ func NewUserTestDB(db *badger.DB) *UserTestDB {
 }
 
 func (h *UserTestDB) Save(usere UserTestDBEntry) error {
-	email := strings.ToLower(usere.Email)
-
-	usereBytes, err := fdoshared.CborCust.Marshal(usere)
-	if err != nil {
-		return errors.New("Failed to marshal User entry. The error is: " + err.Error())
-	}
-
-	userEStorageId := append(h.prefix, []byte(email)...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	return h.saveTxn(h.db, usere)
+}
 
-	entry := badger.NewEntry(userEStorageId, usereBytes)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating User db entry instance. The error is: " + err.Error())
-	}
+func (h *UserTestDB) saveTxn(txn kv.Txn, usere UserTestDBEntry) error {
+	email := strings.ToLower(usere.Email)
+	userEStorageId := append(append([]byte{}, h.prefix...), []byte(email)...)
 
-	err = dbtxn.Commit()
+	err := kv.SetCbor(txn, userEStorageId, usere, 0)
 	if err != nil {
 		return errors.New("Failed saving User entry. The error is: " + err.Error())
 	}
@@ -47,58 +35,28 @@ func (h *UserTestDB) Save(usere UserTestDBEntry) error {
 }
 
 func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
-	email = strings.ToLower(email)
-
-	userEStorageId := append(h.prefix, []byte(email)...)
+	return h.getTxn(h.db, email)
+}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+func (h *UserTestDB) getTxn(txn kv.Reader, email string) (*UserTestDBEntry, error) {
+	email = strings.ToLower(email)
+	userEStorageId := append(append([]byte{}, h.prefix...), []byte(email)...)
 
-	item, err := dbtxn.Get(userEStorageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	usertEntryInst, err := kv.GetCbor[UserTestDBEntry](txn, userEStorageId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The user entry with id %s does not exist", email)
 	} else if err != nil {
-		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
+		return nil, errors.New("Failed reading User entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
-	var usertEntryInst UserTestDBEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &usertEntryInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
-	}
-
-	return &usertEntryInst, nil
+	return usertEntryInst, nil
 }
 
 func (h *UserTestDB) ResetUsers() error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	iterTxn := dbtxn.NewIterator(badger.IteratorOptions{
-		Prefix: h.prefix,
+	return h.db.Update(func(txn kv.Txn) error {
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			log.Println("Deleting... " + hex.EncodeToString(key))
+			return txn.Delete(key)
+		})
 	})
-	defer iterTxn.Close()
-	for iterTxn.Rewind(); iterTxn.Valid(); iterTxn.Next() {
-		item := iterTxn.Item()
-		k := item.Key()
-
-		log.Println("Deleting... " + hex.EncodeToString(k))
-
-		err := dbtxn.Delete(k)
-		if err != nil {
-			log.Println("Error creater delete req... " + hex.EncodeToString(k))
-		}
-
-		err = dbtxn.Commit()
-		if err != nil {
-			log.Println("Failed to commit delete req... " + hex.EncodeToString(k))
-		}
-	}
-
-	return nil
 }
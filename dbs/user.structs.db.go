@@ -3,13 +3,13 @@ package dbs
 import (
 	"bytes"
 
-	"github.com/dgraph-io/badger/v4"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/google/uuid"
 )
 
 type UserTestDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
@@ -91,8 +91,12 @@ type UserTestDBEntry struct {
 	DeviceTestInsts []DeviceTestInst `cbor:"test_device"`
 }
 
-func (h *UserTestDBEntry) RVT_ContainID(rvtid []byte) bool {
-	for _, rvt := range h.RVTestInsts {
+func (h UserTestDBEntry) SchemaVersion() uint16 {
+	return 1
+}
+
+func rvtContainID(rvtInsts []RVTestInst, rvtid []byte) bool {
+	for _, rvt := range rvtInsts {
 		if bytes.Equal(rvt.To0, rvtid) || bytes.Equal(rvt.To1, rvtid) {
 			return true
 		}
@@ -101,8 +105,8 @@ func (h *UserTestDBEntry) RVT_ContainID(rvtid []byte) bool {
 	return false
 }
 
-func (h *UserTestDBEntry) DOT_ContainID(dotid []byte) bool {
-	for _, dotinst := range h.DOTestInsts {
+func dotContainID(dotInsts []DOTestInst, dotid []byte) bool {
+	for _, dotinst := range dotInsts {
 		if bytes.Equal(dotinst.To2, dotid) || bytes.Equal(dotinst.ListenerTo0, dotid) {
 			return true
 		}
@@ -111,8 +115,8 @@ func (h *UserTestDBEntry) DOT_ContainID(dotid []byte) bool {
 	return false
 }
 
-func (h *UserTestDBEntry) DeviceT_ContainID(id []byte) bool {
-	for _, devtinst := range h.DeviceTestInsts {
+func deviceTContainID(deviceTestInsts []DeviceTestInst, id []byte) bool {
+	for _, devtinst := range deviceTestInsts {
 		if bytes.Equal(devtinst.ListenerUuid, id) {
 			return true
 		}
@@ -120,3 +124,15 @@ func (h *UserTestDBEntry) DeviceT_ContainID(id []byte) bool {
 
 	return false
 }
+
+func (h *UserTestDBEntry) RVT_ContainID(rvtid []byte) bool {
+	return rvtContainID(h.RVTestInsts, rvtid)
+}
+
+func (h *UserTestDBEntry) DOT_ContainID(dotid []byte) bool {
+	return dotContainID(h.DOTestInsts, dotid)
+}
+
+func (h *UserTestDBEntry) DeviceT_ContainID(id []byte) bool {
+	return deviceTContainID(h.DeviceTestInsts, id)
+}
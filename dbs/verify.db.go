@@ -6,17 +6,16 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
 	"github.com/google/uuid"
 )
 
 type VerifyDB struct {
-	db     *badger.DB
+	db     kv.Store
 	prefix []byte
 }
 
-func NewVerifyDB(db *badger.DB) *VerifyDB {
+func NewVerifyDB(db kv.Store) *VerifyDB {
 	return &VerifyDB{
 		db:     db,
 		prefix: []byte("verifydb-"),
@@ -25,6 +24,9 @@ func NewVerifyDB(db *badger.DB) *VerifyDB {
 
 const MAX_VERIFY_TIME time.Duration = 7 * 24 * time.Hour
 
+// Password reset links grant account access, so they expire sooner
+const MAX_PASSWORD_RESET_VERIFY_TIME time.Duration = time.Hour
+
 type VerifyType string
 
 const (
@@ -41,26 +43,24 @@ type VerifyEntry struct {
 	Type  VerifyType
 }
 
-func (h *VerifyDB) SaveEntry(verifyEntry VerifyEntry) ([]byte, error) {
-	vtBytes, err := fdoshared.CborCust.Marshal(verifyEntry)
-	if err != nil {
-		return []byte{}, errors.New("Failed to marshal vt. The error is: " + err.Error())
+func (h VerifyEntry) SchemaVersion() uint16 {
+	return 1
+}
+
+func (h VerifyType) Ttl() time.Duration {
+	if h == VT_PasswordReset {
+		return MAX_PASSWORD_RESET_VERIFY_TIME
 	}
 
+	return MAX_VERIFY_TIME
+}
+
+func (h *VerifyDB) SaveEntry(verifyEntry VerifyEntry) ([]byte, error) {
 	randomEntryId, _ := uuid.NewRandom()
 	randomEntryIdString := randomEntryId.String()
 	vtEntryId := append(h.prefix, []byte(randomEntryIdString)...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(vtEntryId, vtBytes).WithTTL(MAX_VERIFY_TIME)
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return []byte{}, errors.New("Failed creating vt db entry instance. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := kv.SetCbor(h.db, vtEntryId, verifyEntry, verifyEntry.Type.Ttl())
 	if err != nil {
 		return []byte{}, errors.New("Failed saving vt entry. The error is: " + err.Error())
 	}
@@ -71,44 +71,22 @@ func (h *VerifyDB) SaveEntry(verifyEntry VerifyEntry) ([]byte, error) {
 func (h *VerifyDB) GetEntry(entryId []byte) (*VerifyEntry, error) {
 	entryDbId := append(h.prefix, entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	item, err := dbtxn.Get(entryDbId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	verifyEntryInst, err := kv.GetCbor[VerifyEntry](h.db, entryDbId)
+	if err != nil && errors.Is(err, kv.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The session entry with id %s does not exist", hex.EncodeToString(entryId))
 	} else if err != nil {
-		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
-	}
-
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
+		return nil, errors.New("Failed reading vt entry. The error is: " + err.Error())
 	}
 
-	var sessionEntryInst VerifyEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
-	if err != nil {
-		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
-	}
-
-	return &sessionEntryInst, nil
+	return verifyEntryInst, nil
 }
 
 func (h *VerifyDB) DeleteEntry(entryId []byte) error {
 	entryDbId := append(h.prefix, entryId...)
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	err := dbtxn.Delete(entryDbId)
-	if err != nil {
-		return errors.New("Failed initialise delete entry. The error is: " + err.Error())
-	}
-
-	err = dbtxn.Commit()
+	err := h.db.Delete(entryDbId)
 	if err != nil {
-		return errors.New("Failed to delete session. The error is: " + err.Error())
+		return errors.New("Failed to delete vt entry. The error is: " + err.Error())
 	}
 
 	return nil
@@ -0,0 +1,40 @@
+package dbs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+)
+
+func TestVerifyDB_EntryTtl(t *testing.T) {
+	store := kv.NewMemoryStore()
+	verifyDb := NewVerifyDB(store)
+
+	testCases := map[VerifyType]time.Duration{
+		VT_Email:             MAX_VERIFY_TIME,
+		VT_AccountValidation: MAX_VERIFY_TIME,
+		VT_PasswordReset:     MAX_PASSWORD_RESET_VERIFY_TIME,
+	}
+
+	for verifyType, expectedTtl := range testCases {
+		entryId, err := verifyDb.SaveEntry(VerifyEntry{Email: "vendor@example.com", Type: verifyType})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expiresAt time.Time
+		err = store.IterateWithExpiry(append([]byte("verifydb-"), entryId...), func(key []byte, value []byte, entryExpiresAt time.Time) error {
+			expiresAt = entryExpiresAt
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		untilExpiry := time.Until(expiresAt)
+		if untilExpiry > expectedTtl || untilExpiry < expectedTtl-time.Minute {
+			t.Errorf("%s: expected entry to expire in %v. Got %v", verifyType, expectedTtl, untilExpiry)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package dbs
+
+import "errors"
+
+// Test instances the user works with. These are the instances of the organisation when the user is a member,
+// otherwise the user's own instances, with owner role
+type TestWorkspace struct {
+	User *UserTestDBEntry
+	Org  *OrgEntry
+	Role OrgRole
+
+	userDB *UserTestDB
+	orgDB  *OrgDB
+}
+
+func NewTestWorkspace(userInst *UserTestDBEntry, userDB *UserTestDB, orgDB *OrgDB) (*TestWorkspace, error) {
+	workspace := TestWorkspace{
+		User:   userInst,
+		Role:   OR_Owner,
+		userDB: userDB,
+		orgDB:  orgDB,
+	}
+
+	orgEntry, err := orgDB.GetByMember(userInst.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if orgEntry != nil {
+		member := orgEntry.GetMember(userInst.Email)
+		if member == nil {
+			return nil, errors.New("User " + userInst.Email + " is not a member of organisation " + orgEntry.Id)
+		}
+
+		workspace.Org = orgEntry
+		workspace.Role = member.Role
+	}
+
+	return &workspace, nil
+}
+
+// Viewers can only read
+func (h *TestWorkspace) CanExecute() bool {
+	return h.Role == OR_Owner || h.Role == OR_Tester
+}
+
+func (h *TestWorkspace) RVTestInsts() []RVTestInst {
+	if h.Org != nil {
+		return h.Org.RVTestInsts
+	}
+
+	return h.User.RVTestInsts
+}
+
+func (h *TestWorkspace) DOTestInsts() []DOTestInst {
+	if h.Org != nil {
+		return h.Org.DOTestInsts
+	}
+
+	return h.User.DOTestInsts
+}
+
+func (h *TestWorkspace) DeviceTestInsts() []DeviceTestInst {
+	if h.Org != nil {
+		return h.Org.DeviceTestInsts
+	}
+
+	return h.User.DeviceTestInsts
+}
+
+func (h *TestWorkspace) RVT_ContainID(rvtid []byte) bool {
+	return rvtContainID(h.RVTestInsts(), rvtid)
+}
+
+func (h *TestWorkspace) DOT_ContainID(dotid []byte) bool {
+	return dotContainID(h.DOTestInsts(), dotid)
+}
+
+func (h *TestWorkspace) DeviceT_ContainID(id []byte) bool {
+	return deviceTContainID(h.DeviceTestInsts(), id)
+}
+
+// Saves user, or updates org in a transaction so concurrent members do not overwrite each other
+func (h *TestWorkspace) modify(modify func(user *UserTestDBEntry, org *OrgEntry)) error {
+	if h.Org == nil {
+		modify(h.User, nil)
+		return h.userDB.Save(*h.User)
+	}
+
+	orgEntry, err := h.orgDB.Update(h.Org.Id, func(orgEntry *OrgEntry) error {
+		modify(nil, orgEntry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.Org = orgEntry
+	return nil
+}
+
+func (h *TestWorkspace) AddRVTestInst(rvtInst RVTestInst) error {
+	return h.modify(func(user *UserTestDBEntry, org *OrgEntry) {
+		if org != nil {
+			org.RVTestInsts = append(org.RVTestInsts, rvtInst)
+		} else {
+			user.RVTestInsts = append(user.RVTestInsts, rvtInst)
+		}
+	})
+}
+
+func (h *TestWorkspace) AddDOTestInst(dotInst DOTestInst) error {
+	return h.modify(func(user *UserTestDBEntry, org *OrgEntry) {
+		if org != nil {
+			org.DOTestInsts = append(org.DOTestInsts, dotInst)
+		} else {
+			user.DOTestInsts = append(user.DOTestInsts, dotInst)
+		}
+	})
+}
+
+func (h *TestWorkspace) AddDeviceTestInst(deviceTestInst DeviceTestInst) error {
+	return h.modify(func(user *UserTestDBEntry, org *OrgEntry) {
+		if org != nil {
+			org.DeviceTestInsts = append(org.DeviceTestInsts, deviceTestInst)
+		} else {
+			user.DeviceTestInsts = append(user.DeviceTestInsts, deviceTestInst)
+		}
+	})
+}
+
+func (h *TestWorkspace) PurgeTestInsts() error {
+	return h.modify(func(user *UserTestDBEntry, org *OrgEntry) {
+		if org != nil {
+			org.RVTestInsts, org.DOTestInsts, org.DeviceTestInsts = []RVTestInst{}, []DOTestInst{}, []DeviceTestInst{}
+		} else {
+			user.RVTestInsts, user.DOTestInsts, user.DeviceTestInsts = []RVTestInst{}, []DOTestInst{}, []DeviceTestInst{}
+		}
+	})
+}
@@ -95,3 +95,26 @@ export const removeTestRun = async (id: string, testRunId: string): Promise<Arra
 
     return resultJson.rvts
 }
+
+export const cancelDoTests = async (id: string): Promise<Array<any>> => {
+    let result = await fetch(`/api/dot/testruns/${id}/cancel`, {
+        method: "POST",
+        headers: {
+            "Content-Type": "application/json",
+        },
+    })
+
+    let resultJson = await result.json()
+
+    if (result.status !== 200) {
+        let statusText = result.statusText
+
+        if (resultJson !== undefined && resultJson.errorMessage !== undefined) {
+            statusText = resultJson.errorMessage
+        }
+
+        return Promise.reject(`Error sending request: ${statusText}`)
+    }
+
+    return resultJson.rvts
+}
@@ -0,0 +1,53 @@
+const requestJson = async (method: string, url: string, body?: any): Promise<any> => {
+    let result = await fetch(url, {
+        method: method,
+        headers: {
+            "Content-Type": "application/json",
+        },
+        body: body !== undefined ? JSON.stringify(body) : undefined,
+    })
+
+    let resultJson = await result.json()
+
+    if (result.status !== 200) {
+        let statusText = result.statusText
+
+        if (resultJson !== undefined && resultJson.errorMessage !== undefined) {
+            statusText = resultJson.errorMessage
+        }
+
+        throw new Error(`Error sending request: ${statusText}`);
+    }
+
+    return resultJson
+}
+
+// Resolves to undefined when user is not a member of an organisation
+export const getOrg = async (): Promise<any> => {
+    return requestJson("GET", "/api/user/org")
+    .catch(() => undefined)
+}
+
+export const createOrg = async (name: string): Promise<any> => {
+    return requestJson("POST", "/api/user/org", {name})
+}
+
+export const setOrgMember = async (email: string, role: string): Promise<any> => {
+    return requestJson("POST", "/api/user/org/members", {email, role})
+}
+
+export const removeOrgMember = async (email: string): Promise<any> => {
+    return requestJson("DELETE", `/api/user/org/members/${encodeURIComponent(email)}`)
+}
+
+export const getOrgInvites = async (): Promise<any> => {
+    return requestJson("GET", "/api/user/org/invites")
+}
+
+export const acceptOrgInvite = async (id: string): Promise<any> => {
+    return requestJson("POST", `/api/user/org/invites/${encodeURIComponent(id)}/accept`)
+}
+
+export const declineOrgInvite = async (id: string): Promise<any> => {
+    return requestJson("DELETE", `/api/user/org/invites/${encodeURIComponent(id)}`)
+}
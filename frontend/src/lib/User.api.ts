@@ -139,3 +139,79 @@ export const isIopOnly = async(): Promise<Boolean> => {
 
     return resultJson.oipOnly
 }
+
+const postJson = async (url: string, body: any): Promise<any> => {
+    let result = await fetch(url, {
+        method: "POST",
+        headers: {
+            "Content-Type": "application/json",
+        },
+        body: JSON.stringify(body),
+    })
+
+    let resultJson = await result.json()
+
+    if (result.status !== 200) {
+        let statusText = result.statusText
+
+        if (resultJson !== undefined && resultJson.errorMessage !== undefined) {
+            statusText = resultJson.errorMessage
+        }
+
+        throw new Error(`Error sending request: ${statusText}`);
+    }
+
+    return resultJson
+}
+
+export const getMode = async(): Promise<string> => {
+    let result = await fetch("/api/user/mode", {
+        method: "GET",
+        headers: {
+            "Content-Type": "application/json",
+        },
+    })
+
+    if (result.status !== 200) {
+        return "onprem"
+    }
+
+    let resultJson = await result.json()
+
+    return resultJson.mode
+}
+
+export const register = async (email: string, password: string, name: string, company: string): Promise<any> => {
+    return postJson("/api/user/register", {email, password, name, company})
+}
+
+export const resetPasswordInit = async (email: string): Promise<any> => {
+    return postJson("/api/user/password/reset/init", {email})
+}
+
+export const resetPasswordApply = async (password: string, confirm_password: string): Promise<any> => {
+    if (password !== confirm_password) {
+        return Promise.reject("Passwords do not match!")
+    }
+
+    return postJson("/api/user/password/reset/apply", {password, confirm_password})
+}
+
+
+const confirmUrls = {
+    "email": "/api/user/email/check/",
+    "resetpassword": "/api/user/password/reset/",
+    "approve": "/api/user/account/approve/",
+    "reject": "/api/user/account/reject/",
+}
+
+// Confirms the link from the mail. Returns the page to open next
+export const confirmLink = async (action: string, id: string): Promise<string> => {
+    if (confirmUrls[action] === undefined) {
+        return Promise.reject("Unknown link!")
+    }
+
+    let resultJson = await postJson(confirmUrls[action] + encodeURIComponent(id), {})
+
+    return resultJson.redirect
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Writes every email as .eml file into Dir. For local testing without SMTP server
+type FileDropSender struct {
+	Dir  string
+	From string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._@-]`)
+
+func (h FileDropSender) Send(message Message) error {
+	messageBytes, err := message.Bytes(h.From)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(h.Dir, 0700)
+	if err != nil {
+		return errors.New("Failed to create mail drop directory. The error is: " + err.Error())
+	}
+
+	fileName := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(message.To, "_"))
+	err = os.WriteFile(filepath.Join(h.Dir, fileName), messageBytes, 0600)
+	if err != nil {
+		return errors.New("Failed to write email. The error is: " + err.Error())
+	}
+
+	return nil
+}
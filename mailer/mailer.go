@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Outgoing account emails: verification, password reset and registration approval
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(message Message) error
+}
+
+const (
+	MAILER_SMTP string = "smtp"
+	MAILER_FILE string = "file"
+)
+
+// Encodes message as plain text RFC 5322 email. Rejects CR and LF in headers to prevent header injection
+func (h Message) Bytes(from string) ([]byte, error) {
+	for _, header := range []string{from, h.To, h.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("email header contains line break")
+		}
+	}
+
+	if h.To == "" {
+		return nil, errors.New("email recipient is empty")
+	}
+
+	var messageBuf bytes.Buffer
+	fmt.Fprintf(&messageBuf, "From: %s\r\n", from)
+	fmt.Fprintf(&messageBuf, "To: %s\r\n", h.To)
+	fmt.Fprintf(&messageBuf, "Subject: %s\r\n", h.Subject)
+	fmt.Fprintf(&messageBuf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	messageBuf.WriteString("MIME-Version: 1.0\r\n")
+	messageBuf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	messageBuf.WriteString("\r\n")
+	messageBuf.WriteString(strings.ReplaceAll(h.Body, "\n", "\r\n"))
+
+	return messageBuf.Bytes(), nil
+}
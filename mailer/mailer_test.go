@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageBytes_HeaderInjection(t *testing.T) {
+	_, err := Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Test"}.Bytes("tools@example.com")
+	if err == nil {
+		t.Error("Expected error for line break in recipient")
+	}
+
+	_, err = Message{To: "user@example.com", Subject: "Test\nBcc: other@example.com"}.Bytes("tools@example.com")
+	if err == nil {
+		t.Error("Expected error for line break in subject")
+	}
+}
+
+func TestFileDropSender(t *testing.T) {
+	dropDir := filepath.Join(t.TempDir(), "mail")
+	sender := FileDropSender{Dir: dropDir, From: "tools@example.com"}
+
+	err := sender.Send(Message{To: "user@example.com", Subject: "Verify your email", Body: "Line one\nLine two"})
+	if err != nil {
+		t.Fatalf("Error sending email. %s", err.Error())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dropDir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %d", len(files))
+	}
+
+	emlBytes, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eml := string(emlBytes)
+	for _, expected := range []string{"From: tools@example.com\r\n", "To: user@example.com\r\n", "Subject: Verify your email\r\n", "\r\n\r\nLine one\r\nLine two"} {
+		if !strings.Contains(eml, expected) {
+			t.Errorf("Expected email to contain %q", expected)
+		}
+	}
+}
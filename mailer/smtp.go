@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+)
+
+// Sends through SMTP server. Uses STARTTLS when server supports it. Auth is only used when Username is set
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (h SMTPSender) Send(message Message) error {
+	messageBytes, err := message.Bytes(h.From)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if h.Username != "" {
+		auth = smtp.PlainAuth("", h.Username, h.Password, h.Host)
+	}
+
+	err = smtp.SendMail(net.JoinHostPort(h.Host, h.Port), auth, h.From, []string{message.To}, messageBytes)
+	if err != nil {
+		return errors.New("Failed to send email. The error is: " + err.Error())
+	}
+
+	return nil
+}
@@ -1,16 +1,15 @@
 package main
 
 import (
-	"context"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api"
@@ -23,105 +22,93 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
 	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kat"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/mailer"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/testexec"
 
 	"github.com/joho/godotenv"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/urfave/cli/v2"
 )
 
-const DEFAULT_PORT = 8080
-const BADGER_LOCATION = "./badger.local.db"
+// Loaded before any command runs, see setupConfig
+var appConfig *fdoshared.Config
 
-func TryReadingWawDIFile(filepath string) (*fdoshared.WawDeviceCredential, error) {
+// Reads device credential file. For the sealed credentials the secrets stay sealed, and are used through the returned store
+func TryReadingWawDIFile(filepath string) (*fdoshared.WawDeviceCredential, fdoshared.DeviceCredStore, error) {
 	fileBytes, err := os.ReadFile(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading file \"%s\". %s ", filepath, err.Error())
+		return nil, nil, fmt.Errorf("error reading file \"%s\". %s ", filepath, err.Error())
 	}
 
 	if len(fileBytes) == 0 {
-		return nil, fmt.Errorf("error reading file \"%s\". The file is empty", filepath)
+		return nil, nil, fmt.Errorf("error reading file \"%s\". The file is empty", filepath)
 	}
 
 	wawdicredBlock, _ := pem.Decode(fileBytes)
 	if wawdicredBlock == nil {
-		return nil, fmt.Errorf("%s: Could not find voucher PEM data", filepath)
+		return nil, nil, fmt.Errorf("%s: Could not find voucher PEM data", filepath)
 	}
 
-	if wawdicredBlock.Type != fdoshared.CREDENTIAL_PEM_TYPE {
-		return nil, fmt.Errorf("%s: Failed to decode PEM voucher. Unexpected type: %s", filepath, wawdicredBlock.Type)
-	}
-
-	var wawdicred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(wawdicredBlock.Bytes, &wawdicred)
-	if err != nil {
-		return nil, fmt.Errorf("%s: Error unmarshaling WawDeviceCredential: %s", filepath, err.Error())
-	}
+	switch wawdicredBlock.Type {
+	case fdoshared.CREDENTIAL_PEM_TYPE:
+		var wawdicred fdoshared.WawDeviceCredential
+		err = fdoshared.CborCust.Unmarshal(wawdicredBlock.Bytes, &wawdicred)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: Error unmarshaling WawDeviceCredential: %s", filepath, err.Error())
+		}
 
-	return &wawdicred, nil
-}
+		return &wawdicred, fdoshared.NewDeviceCredStore(wawdicred), nil
 
-func InitBadgerDB() *badger.DB {
-	options := badger.DefaultOptions(BADGER_LOCATION)
-	options.Logger = nil
+	case fdoshared.SEALED_CREDENTIAL_PEM_TYPE:
+		var sealedcred fdoshared.SealedDeviceCredential
+		err = fdoshared.CborCust.Unmarshal(wawdicredBlock.Bytes, &sealedcred)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: Error unmarshaling SealedDeviceCredential: %s", filepath, err.Error())
+		}
 
-	db, err := badger.Open(options)
-	if err != nil {
-		log.Panicln("Error opening Badger DB. " + err.Error())
-	}
+		credSealer, err := getDeviceCredSealer(sealedcred.SealType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", filepath, err.Error())
+		}
 
-	return db
-}
+		return &sealedcred.Credential, fdoshared.NewSealedDeviceCredStore(sealedcred, credSealer), nil
 
-func TryEnvAndSaveToCtx(ctx context.Context, envvar fdoshared.CONFIG_ENTRY, defaultValue string, required bool) context.Context {
-	resultEnvValue := os.Getenv(strings.ToUpper(string(envvar)))
-	if resultEnvValue == "" && required {
-		log.Panicf("Missing required environment variable %s", envvar)
-	} else if resultEnvValue == "" {
-		resultEnvValue = defaultValue
+	default:
+		return nil, nil, fmt.Errorf("%s: Failed to decode PEM voucher. Unexpected type: %s", filepath, wawdicredBlock.Type)
 	}
-
-	return context.WithValue(ctx, envvar, resultEnvValue)
 }
 
-func loadEnvCtx() context.Context {
-	ctx := context.Background()
-
-	// PORT
-	selectedPort := DEFAULT_PORT
-	envPortString := os.Getenv(strings.ToUpper(string(fdoshared.CFG_ENV_PORT)))
-
-	if envPortString != "" {
-		envPort, err := strconv.Atoi(envPortString)
-		if err != nil {
-			log.Fatalf("Error converting port to integer: %v", err)
+func getDeviceCredSealer(sealType fdoshared.DeviceCredSealType) (fdoshared.DeviceCredSealer, error) {
+	switch sealType {
+	case "":
+		return nil, nil
+	case fdoshared.DEVICE_CRED_SEAL_PASSPHRASE:
+		passphrase := appConfig.Device.CredPassphrase
+		if passphrase == "" {
+			return nil, fmt.Errorf("%s is not set", fdoshared.CFG_ENV_DEVICE_CRED_PASSPHRASE)
 		}
 
-		if envPort != 0 {
-			selectedPort = envPort
-		}
+		return fdoshared.PassphraseSealer{Passphrase: passphrase}, nil
+	case fdoshared.DEVICE_CRED_SEAL_TPM:
+		return fdoshared.TPMSealer{SimulatorAddress: appConfig.Device.TpmSimulator}, nil
+	default:
+		return nil, fmt.Errorf("unknown device credential seal type %s", sealType)
 	}
+}
 
-	defaultUrl := fmt.Sprintf("http://localhost:%d", selectedPort)
-
-	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_PORT, selectedPort)
-
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_DEV_ENV, fdoshared.CFG_ENV_PROD, false)
-
-	// For interop testing
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_URL, "", false)
-	iopEnabled := ctx.Value(fdoshared.CFG_ENV_INTEROP_DASHBOARD_URL).(string) != ""
-
-	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_INTEROP_ENABLED, iopEnabled)
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_FDO_SERVICE_URL, defaultUrl, iopEnabled)
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_RV_AUTHZ, "", iopEnabled)
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_DO_AUTHZ, "", iopEnabled)
-	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DO_TOKEN_MAPPING, "", iopEnabled)
+func InitBadgerDB() kv.Store {
+	db, err := kv.OpenBadgerStore(appConfig.DBPath)
+	if err != nil {
+		log.Panicln(err.Error())
+	}
 
-	return ctx
+	return db
 }
 
 // Enable SHA1 for x509
@@ -135,22 +122,89 @@ func enforceSha1GoDebug() {
 	os.Setenv("GODEBUG", godebug)
 }
 
-func checkAndSeed(db *badger.DB) error {
-	time.Sleep(4 * time.Second)
+// Owner keys are generated in the PKCS#11 token when PKCS11_MODULE is set. Software keys are still accepted
+func setupKeyStore(pkcs11Config fdoshared.Pkcs11Config) error {
+	if pkcs11Config.Module == "" {
+		return nil
+	}
+
+	pkcs11KeyStore, err := fdoshared.NewPKCS11KeyStore(pkcs11Config.Module, pkcs11Config.TokenLabel, pkcs11Config.Pin)
+	if err != nil {
+		return err
+	}
+
+	fdoshared.SetKeyStore(pkcs11KeyStore)
+	log.Println("Using PKCS#11 key store for owner keys")
+
+	return nil
+}
+
+// Mail sender is only needed in online mode. Returns nil sender in onprem mode
+func setupMailSender(config *fdoshared.Config) (mailer.Sender, error) {
+	if config.Mode != fdoshared.CFG_MODE_ONLINE {
+		return nil, nil
+	}
+
+	mailerConfig := config.Mailer
+	if mailerConfig.From == "" {
+		return nil, fmt.Errorf("%s is required in online mode", fdoshared.CFG_ENV_MAIL_FROM)
+	}
+
+	switch mailerConfig.Type {
+	case mailer.MAILER_SMTP:
+		if mailerConfig.SmtpHost == "" {
+			return nil, fmt.Errorf("%s is required for smtp mailer", fdoshared.CFG_ENV_SMTP_HOST)
+		}
+
+		return mailer.SMTPSender{
+			Host:     mailerConfig.SmtpHost,
+			Port:     mailerConfig.SmtpPort,
+			Username: mailerConfig.SmtpUsername,
+			Password: mailerConfig.SmtpPassword,
+			From:     mailerConfig.From,
+		}, nil
+	case mailer.MAILER_FILE:
+		log.Printf("Emails are written to %s", mailerConfig.DropDir)
+		return mailer.FileDropSender{Dir: mailerConfig.DropDir, From: mailerConfig.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %s. Must be %s or %s", mailerConfig.Type, mailer.MAILER_SMTP, mailer.MAILER_FILE)
+	}
+}
+
+// Online backups are only taken when backup dir is set
+func setupBackups(db kv.Store, backupConfig fdoshared.BackupConfig) {
+	if backupConfig.Dir == "" {
+		return
+	}
 
-	devbasedb := dbs.NewDeviceBaseDB(db)
-	configdb := dbs.NewConfigDB(db)
+	log.Printf("Backing up DB to %s every %s", backupConfig.Dir, backupConfig.Interval)
+	go dbs.NewArchiveDB(db).RunBackups(backupConfig.Dir, backupConfig.Interval, backupConfig.Keep, nil)
+}
 
-	_, err := configdb.Get()
+// Loads config file and environment, and applies the process wide settings
+func setupConfig(configFile string) error {
+	config, err := fdoshared.LoadConfig(configFile)
 	if err != nil {
-		log.Println("---------- NOTE ----------")
-		log.Println("\nPlease wait while tools pre-generate testing private keys. This may take up to five minutes...")
-		log.Println("---------- NOTE ----------")
-		return PreSeed(configdb, devbasedb)
-	} else {
-		log.Println("Database already seeded. Skipping...")
+		return err
+	}
+
+	// Standard log package output goes to the same logger at info level
+	logger, err := fdoshared.NewLogger(os.Stderr, config.Log.Level, config.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	if config.AlgExtensions {
+		fdoshared.EnableAlgExtensions()
+		log.Println("Algorithm extensions are enabled")
+	}
+
+	if config.DeviceCertPolicy.EpidVerifierUrl != "" {
+		log.Printf("EPID signatures are verified by %s", config.DeviceCertPolicy.EpidVerifierUrl)
 	}
 
+	appConfig = config
 	return nil
 }
 
@@ -177,6 +231,16 @@ func main() {
 			},
 		},
 		Copyright: "(c) 2022-2024 FIDO Alliance, Inc",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "YAML config file. Environment variables override its values",
+				EnvVars: []string{string(fdoshared.CFG_ENV_CONFIG_FILE)},
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupConfig(c.String("config"))
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "serve",
@@ -194,26 +258,54 @@ func main() {
 					// Enable SHA1 for x509
 					enforceSha1GoDebug()
 
+					err := setupKeyStore(appConfig.Pkcs11)
+					if err != nil {
+						return err
+					}
+
 					db := InitBadgerDB()
 					defer db.Close()
 
-					seedCheck := checkAndSeed(db)
-					if seedCheck != nil {
-						return seedCheck
+					err = dbs.RunMigrations(db)
+					if err != nil {
+						return err
+					}
+
+					devBasePool, err := setupDeviceBasePool(db, appConfig.SeedSize)
+					if err != nil {
+						return err
 					}
 
 					if !checkFrontendExists() && !force {
 						return fmt.Errorf("./frontend folder not found")
 					}
 
-					ctx := loadEnvCtx()
+					mailSender, err := setupMailSender(appConfig)
+					if err != nil {
+						return err
+					}
+
+					setupBackups(db, appConfig.Backup)
 
 					// Setup FDO listeners
-					fdodo.SetupServer(db, ctx)
-					fdorv.SetupServer(db, ctx)
-					api.SetupServer(db, ctx)
+					fdodo.SetupServer(db, appConfig)
+					fdorv.SetupServer(db, appConfig)
+					api.SetupServer(db, appConfig, mailSender, devBasePool)
+
+					if appConfig.MetricsPort != 0 {
+						go func() {
+							log.Printf("Starting metrics server at port %d", appConfig.MetricsPort)
+							err := metrics.ListenAndServe(appConfig.MetricsPort)
+							if err != nil {
+								log.Panicln("Error starting metrics server. " + err.Error())
+							}
+						}()
+					}
+
+					// Resume test runs that were interrupted by the restart
+					testexec.ResumeInterruptedRuns(testcomdbs.NewRequestTestDB(db), dbs.NewDeviceBaseDB(db), appConfig)
 
-					selectedPort := ctx.Value(fdoshared.CFG_ENV_PORT).(int)
+					selectedPort := appConfig.Port
 					log.Printf("Starting server at port %d... \n. http://localhost:%d", selectedPort, selectedPort)
 
 					err = http.ListenAndServe(fmt.Sprintf(":%d", selectedPort), nil)
@@ -227,12 +319,19 @@ func main() {
 			{
 				Name:      "seed",
 				Usage:     "Seed FDO Cred Base",
-				UsageText: "Generates one hundred thousand cred bases to be used in testing",
+				UsageText: "Generates SEED_SIZE(default 10000) cred bases per device sgType. Server does the same in the background",
 				Action: func(c *cli.Context) error {
 					db := InitBadgerDB()
 					defer db.Close()
 
-					return checkAndSeed(db)
+					devBasePool := newDeviceBasePool(db, appConfig.SeedSize)
+
+					err := devBasePool.Init()
+					if err != nil {
+						return err
+					}
+
+					return devBasePool.Fill()
 				},
 			},
 			{
@@ -299,68 +398,19 @@ func main() {
 				},
 			},
 			{
-				Name: "generate_rvinfo",
+				Name:  "generate_rvinfo",
+				Usage: "Print CBOR hex of every RV info set in the config",
 				Action: func(c *cli.Context) error {
-					rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
-						"http://165.227.240.155:80",   // FIDO
-						"http://20.228.111.63:8080",   // Intel
-						"http://103.147.123.161:7040", // VinCSS
-						"http://44.210.118.60:8040/",  // Dell
-					})
-					if err != nil {
-						log.Panicln(err)
-					}
-
-					rvinfoBytes, _ := fdoshared.CborCust.Marshal(rvInfo)
-					log.Println("HTTP IP Only", hex.EncodeToString(rvinfoBytes))
-
-					// HTTPS + HTTP IP Only
-					rvInfo, err = fdoshared.UrlsToRendezvousInfo([]string{
-						"http://165.227.240.155:80",  // FIDO
-						"https://172.67.150.203:443", // FIDO
-						"https://104.21.0.92:443",    // FIDO
-
-						"http://20.228.111.63:8080", // Intel
-
-						"http://103.147.123.161:7040",  // VinCSS
-						"https://103.147.123.161:7040", // VinCSS
-
-						"http://44.210.118.60:8040/",  // Dell
-						"https://44.210.118.60:8041/", // Dell
-					})
-					if err != nil {
-						log.Panicln(err)
-					}
-
-					rvinfoBytes, _ = fdoshared.CborCust.Marshal(rvInfo)
-					log.Println("HTTP + HTTPS IP Only", hex.EncodeToString(rvinfoBytes))
-
-					// HTTPS + HTTP IP Only
-					rvInfo, err = fdoshared.UrlsToRendezvousInfo([]string{
-						"http://165.227.240.155:80",   // FIDO
-						"https://172.67.150.203:443",  // FIDO
-						"https://104.21.0.92:443",     // FIDO
-						"https://rv.fdo.tools:443",    // FIDO
-						"http://http.rv.fdo.tools:80", // FIDO
-
-						"http://20.228.111.63:8080",                      // Intel
-						"http://bmo-rrp.westus.cloudapp.azure.com:8080/", // Intel
-
-						"http://103.147.123.161:7040",    // VinCSS
-						"https://103.147.123.161:7040",   // VinCSS
-						"http://vincss-fdo-rv.fido2.vn",  // VinCSS
-						"https://vincss-fdo-rv.fido2.vn", // VinCSS
-
-						"http://44.210.118.60:8040/",  // Dell
-						"https://44.210.118.60:8041/", // Dell
-					})
-					if err != nil {
-						log.Panicln(err)
+					for _, rvInfoSet := range appConfig.RvInfo {
+						rvInfo, err := fdoshared.UrlsToRendezvousInfo(rvInfoSet.Urls)
+						if err != nil {
+							log.Panicln(err)
+						}
+
+						rvinfoBytes, _ := fdoshared.CborCust.Marshal(rvInfo)
+						log.Println(rvInfoSet.Name, hex.EncodeToString(rvinfoBytes))
 					}
 
-					rvinfoBytes, _ = fdoshared.CborCust.Marshal(rvInfo)
-					log.Println("HTTP + HTTPS + DNS", hex.EncodeToString(rvinfoBytes))
-
 					return nil
 				},
 			},
@@ -372,30 +422,63 @@ func main() {
 					{
 						Name:  "generate",
 						Usage: "Generate virtual device credential and voucher",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "seal",
+								Usage: "Seal device secrets with passphrase or tpm. Passphrase is read from DEVICE_CRED_PASSPHRASE, and TPM simulator address from TPM_SIMULATOR",
+							},
+							&cli.IntFlag{
+								Name:  "device-sg",
+								Usage: "Device attestation SgType. Random SECP256R1 or SECP384R1 by default",
+							},
+							&cli.IntFlag{
+								Name:  "voucher-sg",
+								Usage: "Voucher owner keys SgType. Random by default",
+							},
+							&cli.StringFlag{
+								Name:  "rvinfo",
+								Usage: "Name of the config RV info set. First set by default",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							enforceSha1GoDebug()
-							deviceSgType := fdoshared.RandomDeviceSgType()
+
+							err := setupKeyStore(appConfig.Pkcs11)
+							if err != nil {
+								return err
+							}
+
+							credSealer, err := getDeviceCredSealer(fdoshared.DeviceCredSealType(c.String("seal")))
+							if err != nil {
+								return err
+							}
+
+							deviceSgType := fdoshared.RandomDeviceSgType(nil)
+							if c.IsSet("device-sg") {
+								deviceSgType = fdoshared.DeviceSgType(c.Int("device-sg"))
+							}
+
 							credbase, err := fdoshared.NewWawDeviceCredential(deviceSgType)
 							if err != nil {
 								log.Panicf("Error generating cred base. %s", err.Error())
 							}
 
-							rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
-								"http://165.227.240.155:80",   // FIDO
-								"http://20.228.111.63:8080",   // Intel
-								"http://103.147.123.161:7040", // VinCSS
-								"http://44.210.118.60:8040/",  // Dell
-							})
+							rvInfoSet, err := appConfig.GetRvInfoSet(c.String("rvinfo"))
 							if err != nil {
-								log.Panicln(err)
+								return err
 							}
 
-							// rvinfob, err := fdoshared.CborCust.Marshal(rvInfo)
+							rvInfo, err := fdoshared.UrlsToRendezvousInfo(rvInfoSet.Urls)
+							if err != nil {
+								log.Panicln(err)
+							}
 
-							// print("RVINFO: ", hex.EncodeToString(rvinfob))
+							voucherSgType := fdoshared.RandomSgType(nil)
+							if c.IsSet("voucher-sg") {
+								voucherSgType = fdoshared.DeviceSgType(c.Int("voucher-sg"))
+							}
 
-							voucherSgType := fdoshared.RandomSgType()
-							err = fdodeviceimplementation.GenerateAndSaveDeviceCredAndVoucher(*credbase, voucherSgType, rvInfo, testcom.NULL_TEST)
+							err = fdodeviceimplementation.GenerateAndSaveDeviceCredAndVoucher(*credbase, voucherSgType, rvInfo, testcom.NULL_TEST, credSealer)
 							if err != nil {
 								log.Panicf(err.Error())
 							}
@@ -417,7 +500,7 @@ func main() {
 							url := c.Args().Get(0)
 							filepath := c.Args().Get(1)
 
-							wawcred, err := TryReadingWawDIFile(filepath)
+							wawcred, credStore, err := TryReadingWawDIFile(filepath)
 							if err != nil {
 								return err
 							}
@@ -425,6 +508,7 @@ func main() {
 							to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
 								SrvURL: url,
 							}, *wawcred)
+							to1inst.SetCredStore(credStore)
 
 							helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
 							if err != nil {
@@ -475,12 +559,10 @@ func main() {
 								return nil
 							}
 
-							ctx := loadEnvCtx()
-
 							url := c.Args().Get(0)
 							filepath := c.Args().Get(1)
 
-							wawcred, err := TryReadingWawDIFile(filepath)
+							wawcred, credStore, err := TryReadingWawDIFile(filepath)
 							if err != nil {
 								return err
 							}
@@ -488,7 +570,8 @@ func main() {
 							log.Println("Starting HelloDevice60")
 							to2inst := to2.NewTo2Requestor(fdoshared.SRVEntry{
 								SrvURL: url,
-							}, *wawcred, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
+							}, *wawcred, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, appConfig)
+							to2inst.CredStore = credStore
 
 							to2proveOvhdrPayload, _, err := to2inst.HelloDevice60(testcom.NULL_TEST)
 							if err != nil {
@@ -611,8 +694,7 @@ func main() {
 							log.Println("Success To2")
 
 							// FDO Interop
-							iopEnabled := ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool)
-							if iopEnabled {
+							if appConfig.Interop.Enabled() {
 								authzval, ok := ownerSims.GetSim(fdoshared.IOPLOGGER_SIM)
 								if !ok {
 									log.Println("IOP logger not found in owner sims")
@@ -620,7 +702,7 @@ func main() {
 								}
 
 								log.Println("Submitting IOP logger event")
-								err = fdoshared.SubmitIopLoggerEvent(ctx, to2inst.Credential.DCGuid, fdoshared.To2, to2inst.NonceTO2SetupDv64, string(authzval))
+								err = fdoshared.SubmitIopLoggerEvent(appConfig.Interop, to2inst.Credential.DCGuid, fdoshared.To2, to2inst.NonceTO2SetupDv64, string(authzval))
 								if err != nil {
 									log.Println(err)
 									return nil
@@ -702,8 +784,6 @@ func main() {
 
 							rvUrl := c.Args().Get(0)
 
-							ctx := loadEnvCtx()
-
 							// VoucherDB
 							db := InitBadgerDB()
 							defer db.Close()
@@ -723,7 +803,7 @@ func main() {
 
 								to0 := to0.NewTo0Requestor(fdoshared.SRVEntry{
 									SrvURL: rvUrl,
-								}, *vandk, ctx)
+								}, *vandk, appConfig)
 
 								helloAck21, _, err := to0.Hello20(testcom.NULL_TEST)
 								if err != nil {
@@ -745,6 +825,313 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:        "run",
+				Description: "Execute conformance test runs",
+				Usage:       "run [cmd]",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "rvt",
+						Usage:     "Execute RV test run",
+						UsageText: "[RV test instance id hex]",
+						Flags: []cli.Flag{
+							&cli.Int64Flag{
+								Name:  "seed",
+								Usage: "Seed of the test run to replay",
+							},
+							&cli.StringSliceFlag{
+								Name:  "include",
+								Usage: "Test ids or patterns to execute, e.g. FIDO_DOT_64_*. All tests by default",
+							},
+							&cli.StringSliceFlag{
+								Name:  "exclude",
+								Usage: "Test ids or patterns to skip",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							enforceSha1GoDebug()
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("missing test instance id")
+							}
+
+							rvteId, err := hex.DecodeString(c.Args().Get(0))
+							if err != nil {
+								return fmt.Errorf("error decoding test instance id. %s", err.Error())
+							}
+
+							seed := fdoshared.NewConf_Seed()
+							if c.IsSet("seed") {
+								seed = c.Int64("seed")
+							}
+
+							selection := testcom.NewFDOTestSelection(c.StringSlice("include"), c.StringSlice("exclude"))
+
+							db := InitBadgerDB()
+							defer db.Close()
+
+							reqtDB := testcomdbs.NewRequestTestDB(db)
+							devBaseDB := dbs.NewDeviceBaseDB(db)
+
+							rvte, err := reqtDB.Get(rvteId)
+							if err != nil {
+								return err
+							}
+
+							switch rvte.Protocol {
+							case fdoshared.To0:
+								err = testexec.ExecuteRVTestsTo0(*rvte, reqtDB, devBaseDB, appConfig, seed, selection)
+							case fdoshared.To1:
+								err = testexec.ExecuteRVTestsTo1(*rvte, reqtDB, devBaseDB, appConfig, seed, selection)
+							default:
+								return fmt.Errorf("protocol TO%d is not supported", rvte.Protocol)
+							}
+							if err != nil {
+								return err
+							}
+
+							log.Printf("Finished run with seed %d", seed)
+							return nil
+						},
+					},
+					{
+						Name:      "dot",
+						Usage:     "Execute DO test run",
+						UsageText: "[DO test instance id hex]",
+						Flags: []cli.Flag{
+							&cli.Int64Flag{
+								Name:  "seed",
+								Usage: "Seed of the test run to replay",
+							},
+							&cli.StringSliceFlag{
+								Name:  "include",
+								Usage: "Test ids or patterns to execute, e.g. FIDO_DOT_64_*. All tests by default",
+							},
+							&cli.StringSliceFlag{
+								Name:  "exclude",
+								Usage: "Test ids or patterns to skip",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							enforceSha1GoDebug()
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("missing test instance id")
+							}
+
+							dotId, err := hex.DecodeString(c.Args().Get(0))
+							if err != nil {
+								return fmt.Errorf("error decoding test instance id. %s", err.Error())
+							}
+
+							seed := fdoshared.NewConf_Seed()
+							if c.IsSet("seed") {
+								seed = c.Int64("seed")
+							}
+
+							selection := testcom.NewFDOTestSelection(c.StringSlice("include"), c.StringSlice("exclude"))
+
+							db := InitBadgerDB()
+							defer db.Close()
+
+							reqtDB := testcomdbs.NewRequestTestDB(db)
+
+							dote, err := reqtDB.Get(dotId)
+							if err != nil {
+								return err
+							}
+
+							err = testexec.ExecuteDOTestsTo2(*dote, reqtDB, appConfig, seed, selection)
+							if err != nil {
+								return err
+							}
+
+							log.Printf("Finished run with seed %d", seed)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:        "kat",
+				Description: "Crypto known-answer test vectors: KDF, EMB/ETM encryption, session key derivation and COSE_Sign1",
+				Usage:       "kat [cmd]",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "Write built-in vectors for the external implementation",
+						UsageText: "[Path to vectors file]",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								log.Println("Missing filename. Expected: [Path to vectors file]")
+								return nil
+							}
+
+							return os.WriteFile(c.Args().Get(0), kat.EmbeddedVectorsJson(), 0644)
+						},
+					},
+					{
+						Name:      "check",
+						Usage:     "Check external implementation outputs against the vectors. Without outputs file, checks this implementation",
+						UsageText: "[Path to outputs file]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "vectors",
+								Usage: "Path to vectors file. Built-in vectors by default",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							vectors, err := kat.LoadVectors(c.String("vectors"))
+							if err != nil {
+								return err
+							}
+
+							var outputs *kat.Outputs
+							if c.Args().Len() == 1 {
+								outputs, err = kat.LoadOutputs(c.Args().Get(0))
+							} else {
+								outputs, err = kat.ComputeOutputs(*vectors)
+							}
+							if err != nil {
+								return err
+							}
+
+							failedCount := 0
+							for _, result := range kat.CheckOutputs(*vectors, *outputs) {
+								if result.Status == kat.RESULT_FAILED {
+									failedCount++
+								}
+
+								log.Printf("%s: %s %s", result.Id, result.Status, result.Message)
+							}
+
+							if failedCount != 0 {
+								return fmt.Errorf("%s: %d vectors failed", outputs.Implementation, failedCount)
+							}
+
+							log.Printf("%s: all vectors passed", outputs.Implementation)
+							return nil
+						},
+					},
+					{
+						Name:      "generate",
+						Usage:     "Generate new vectors with random inputs. Requires ALG_EXTENSIONS=true",
+						UsageText: "[Path to vectors file]",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								log.Println("Missing filename. Expected: [Path to vectors file]")
+								return nil
+							}
+
+							vectors, err := kat.GenerateVectors()
+							if err != nil {
+								return err
+							}
+
+							vectorsBytes, err := json.MarshalIndent(vectors, "", "  ")
+							if err != nil {
+								return err
+							}
+
+							return os.WriteFile(c.Args().Get(0), append(vectorsBytes, '\n'), 0644)
+						},
+					},
+				},
+			},
+			{
+				Name:        "db",
+				Description: "Export, import and backup DB. Server must be stopped",
+				Usage:       "db [cmd]",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "Export groups of entries to archive",
+						UsageText: "[Path to archive file]",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "group",
+								Usage: "Group to export: users, testruns, vouchers, devicebases or rvregistrations. All groups by default",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								log.Println("Missing filename. Expected: [Path to archive file]")
+								return nil
+							}
+
+							groups, err := dbs.ParseArchiveGroups(c.StringSlice("group"))
+							if err != nil {
+								return err
+							}
+
+							db := InitBadgerDB()
+							defer db.Close()
+
+							archive, err := dbs.NewArchiveDB(db).ExportToFile(groups, c.Args().Get(0))
+							if err != nil {
+								return err
+							}
+
+							log.Printf("Exported %d entries to %s", len(archive.Entries), c.Args().Get(0))
+							return nil
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Import archive. Existing entries with the same keys are overwritten",
+						UsageText: "[Path to archive file]",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								log.Println("Missing filename. Expected: [Path to archive file]")
+								return nil
+							}
+
+							db := InitBadgerDB()
+							defer db.Close()
+
+							archive, importedCount, err := dbs.NewArchiveDB(db).ImportFromFile(c.Args().Get(0))
+							if err != nil {
+								return err
+							}
+
+							log.Printf("Imported %d of %d entries", importedCount, len(archive.Entries))
+
+							// Archive could be exported by older version
+							return dbs.RunMigrations(db)
+						},
+					},
+					{
+						Name:  "migrate",
+						Usage: "Upgrade records of older versions. Server runs migrations on startup",
+						Action: func(c *cli.Context) error {
+							db := InitBadgerDB()
+							defer db.Close()
+
+							return dbs.RunMigrations(db)
+						},
+					},
+					{
+						Name:      "backup",
+						Usage:     "Export every entry, including sessions. For the running server use BACKUP_DIR",
+						UsageText: "[Path to archive file]",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								log.Println("Missing filename. Expected: [Path to archive file]")
+								return nil
+							}
+
+							db := InitBadgerDB()
+							defer db.Close()
+
+							archive, err := dbs.NewArchiveDB(db).ExportToFile([]dbs.ArchiveGroup{dbs.AG_All}, c.Args().Get(0))
+							if err != nil {
+								return err
+							}
+
+							log.Printf("Backed up %d entries to %s", len(archive.Entries), c.Args().Get(0))
+							return nil
+						},
+					},
+				},
+			},
 			{
 				Name:        "reset",
 				Description: "Reset methods",
@@ -1,103 +1,45 @@
 package main
 
 import (
-	"fmt"
 	"log"
-	"sync"
+	"strconv"
 
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
-const SeedingSize = 10000
-const ThreadsPerAlg = 10
-
-type SeedRunResult struct {
-	DeviceSgType fdoshared.DeviceSgType
-	Guids        []fdoshared.FdoGuid
-	CredBases    []fdoshared.WawDeviceCredential
-	Error        error
-}
-
-func SeedRunInst(threadID int, seedSize int, sgType fdoshared.DeviceSgType, wg *sync.WaitGroup, resultChannel chan SeedRunResult) {
-	var result = SeedRunResult{
-		DeviceSgType: sgType,
-		Guids:        []fdoshared.FdoGuid{},
-		CredBases:    []fdoshared.WawDeviceCredential{},
-	}
-
-	defer wg.Done()
-
-	log.Printf("----- [%d] Starting SgType %d. -----\n", threadID, sgType)
-
-	for i := 0; i < seedSize; i++ {
-		if i != 0 && i%(seedSize/10) == 0 {
-			log.Printf("[%d] %d. %d%% completed\n", threadID, sgType, int(float64(i/(seedSize/10)))*10)
-		}
-
-		newDeviceBase, err := fdoshared.NewWawDeviceCredential(sgType)
-		if err != nil {
-			result.Error = fmt.Errorf("[%d] Error generating device base for sgType %d. %s ", threadID, sgType, err.Error())
-			log.Println(result.Error.Error())
-			resultChannel <- result
-			return
-		}
-
-		result.CredBases = append(result.CredBases, *newDeviceBase)
-		result.Guids = append(result.Guids, newDeviceBase.DCGuid)
-	}
-
-	resultChannel <- result
+// Pool target size per device sgType comes from the config seedSize
+func newDeviceBasePool(db kv.Store, targetSize int) *dbs.DeviceBasePool {
+	return dbs.NewDeviceBasePool(dbs.NewConfigDB(db), dbs.NewDevicePoolDB(db), dbs.NewDeviceBaseDB(db), targetSize)
 }
 
-func PreSeed(configdb *dbs.ConfigDB, devbasedb *dbs.DeviceBaseDB) error {
-	var wg sync.WaitGroup
-
-	totalChannels := len(fdoshared.DeviceSgTypeList) * ThreadsPerAlg
-	chn := make(chan SeedRunResult, totalChannels)
-	var batchSize int = SeedingSize / ThreadsPerAlg
+// Generates initial batch of device bases, and refills the rest in the background
+func setupDeviceBasePool(db kv.Store, targetSize int) (*dbs.DeviceBasePool, error) {
+	devBasePool := newDeviceBasePool(db, targetSize)
 
-	for _, sgType := range fdoshared.DeviceSgTypeList {
-		if sgType == fdoshared.StEPID10 || sgType == fdoshared.StEPID11 {
-			log.Println("EPID is not currently supported!")
-			continue
-		}
-
-		for i := 0; i < ThreadsPerAlg; i++ {
-			wg.Add(1)
-			go SeedRunInst(i, batchSize, sgType, &wg, chn)
-		}
-	}
-
-	wg.Wait()
-
-	var newConfig dbs.MainConfig = dbs.MainConfig{
-		SeededGuids: fdoshared.FdoSeedIDs{},
+	err := devBasePool.Init()
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < totalChannels; i++ {
-		result := <-chn
-
-		if result.Error != nil {
-			return fmt.Errorf("failed to pre-generate creds for %d. %s ", result.DeviceSgType, result.Error)
-		}
-
-		for _, newDeviceBase := range result.CredBases {
-			err := devbasedb.Save(newDeviceBase)
-			if err != nil {
-				return fmt.Errorf("Error saving device base. " + err.Error())
-			}
+	metrics.NewGaugeFunc("fdo_device_pool_level", "Seeded device bases per device sgType", []string{"sg_type"}, func() []metrics.Sample {
+		samples := []metrics.Sample{}
+		for _, level := range devBasePool.Status().Levels {
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{strconv.Itoa(int(level.SgType))},
+				Value:       float64(level.Level),
+			})
 		}
 
-		newConfig.SeededGuids[result.DeviceSgType] = append(newConfig.SeededGuids[result.DeviceSgType], result.Guids...)
-	}
-
-	err := configdb.Save(newConfig)
-	if err != nil {
-		return fmt.Errorf("error saving config. " + err.Error())
-	}
+		return samples
+	})
+	metrics.NewGaugeFunc("fdo_device_pool_target", "Device base pool target size per device sgType", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(devBasePool.Status().TargetSize)}}
+	})
 
-	log.Println("Done!")
+	devBasePool.StartRefill()
+	log.Println("Device base pool refills in the background. See /api/devicepool")
 
-	return nil
+	return devBasePool, nil
 }
@@ -4,24 +4,37 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func executeTo2_60(reqte reqtestsdeps.RequestTestInst, reqtDB *dbs.RequestTestDB) {
+func executeTo2_60(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, fdoTestId := range testcom.FIDO_TEST_LIST_DOT_60 {
-		testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+		if run.skipTest(fdoTestId) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(fdoTestId))
+
+		// Encoding tests have own vouchers
+		voucherTestId := testcom.NULL_TEST
+		if _, ok := testcom.FIDO_TEST_PKENC[fdoTestId]; ok {
+			voucherTestId = fdoTestId
+		}
+
+		testCred, err := reqte.TestVouchers.GetVoucher(rnd, voucherTestId)
 		if err != nil {
 			errTestState := testcom.NewFailTestState(fdoTestId, "Error getting voucher for TO2 60. "+err.Error())
 
-			reqtDB.ReportTest(reqte.Uuid, testcom.NULL_TEST, errTestState)
-			return
+			reqtDB.ReportTest(reqte.Uuid, fdoTestId, errTestState)
+			continue
 		}
 
 		// Generating TO0 handler
 		to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, run.config) // TODO
+		to2requestor.ConfSeed = seed
 
 		switch fdoTestId {
 		case testcom.FIDO_DOT_60_POSITIVE:
@@ -37,6 +50,15 @@ func executeTo2_60(reqte reqtestsdeps.RequestTestInst, reqtDB *dbs.RequestTestDB
 				reqtDB.ReportTest(reqte.Uuid, fdoTestId, errTestState)
 			}
 
+		case testcom.FIDO_DOT_60_POSITIVE_PKENC_X509, testcom.FIDO_DOT_60_POSITIVE_PKENC_X5CHAIN, testcom.FIDO_DOT_60_POSITIVE_PKENC_COSEKEY:
+			// Full HelloDevice, with ProveOVHdr verified against the owner key in the tested encoding
+			_, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+			if err != nil {
+				reqtDB.ReportTest(reqte.Uuid, fdoTestId, testcom.NewFailTestState(fdoTestId, err.Error()))
+			} else {
+				reqtDB.ReportTest(reqte.Uuid, fdoTestId, testcom.NewSuccessTestState(fdoTestId))
+			}
+
 		default:
 			_, rvtTestState, err := to2requestor.HelloDevice60(fdoTestId)
 			if rvtTestState == nil && err != nil {
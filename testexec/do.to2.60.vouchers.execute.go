@@ -4,13 +4,18 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, testId := range testcom.FIDO_TEST_LIST_VOUCHER {
-		testCred, err := reqte.TestVouchers.GetVoucher(testId)
+		if run.skipTest(testId) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+		testCred, err := reqte.TestVouchers.GetVoucher(rnd, testId)
 		if err != nil {
 			errTestState := testcom.FDOTestState{
 				Passed: false,
@@ -24,7 +29,8 @@ func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.
 		// Generating TO0 handler
 		to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, run.config) // TODO
+		to2requestor.ConfSeed = seed
 
 		_, rvtTestState, err := to2requestor.HelloDevice60(testId)
 
@@ -37,6 +43,7 @@ func executeTo2_60_Vouchers(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.
 			rvtTestState = &errTestState
 		}
 
+		rvtTestState.SetFuzzMutation(testCred.FuzzMutation)
 		reqtDB.ReportTest(reqte.Uuid, testId, *rvtTestState)
 	}
 
@@ -7,13 +7,18 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func executeTo2_62(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+func executeTo2_62(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_62 {
-		testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+		if run.skipTest(testId) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+		testCred, err := reqte.TestVouchers.GetVoucher(rnd, testcom.NULL_TEST)
 		if err != nil {
 			errTestState := testcom.FDOTestState{
 				Passed: false,
@@ -27,7 +32,8 @@ func executeTo2_62(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 		// Generating TO0 handler
 		to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+		}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, run.config) // TODO
+		to2requestor.ConfSeed = seed
 
 		proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 		if err != nil {
@@ -91,7 +97,7 @@ func executeTo2_62(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 			reqtDB.ReportTest(reqte.Uuid, testId, errTestState)
 
 		default:
-			randomTestIndex := fdoshared.NewRandomInt(0, int(proveOVHdrPayload61.NumOVEntries))
+			randomTestIndex := rnd.Int(0, int(proveOVHdrPayload61.NumOVEntries))
 			for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
 				selectedTestId := testcom.NULL_TEST
 				selectedNextEntry := i
@@ -101,7 +107,7 @@ func executeTo2_62(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 					}
 
 					if testId == testcom.FIDO_DOT_62_GETOVNEXT_BAD_INDEX {
-						selectedNextEntry = fdoshared.NewRandomInt(int(proveOVHdrPayload61.NumOVEntries), 255)
+						selectedNextEntry = rnd.Int(int(proveOVHdrPayload61.NumOVEntries), 255)
 					}
 				}
 
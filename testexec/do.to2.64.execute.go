@@ -4,12 +4,19 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst, config *fdoshared.Config, seed int64, testId testcom.FDOTestID) (*to2.To2Requestor, error) {
+	rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+
+	// ASYMKEX tests have own vouchers with RSA owner key
+	voucherTestId, kexSuiteName := testcom.NULL_TEST, fdoshared.KEX_ECDH256
+	if testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_ASYMKEX, testId) == testId {
+		voucherTestId, kexSuiteName = testId, fdoshared.KEX_ASYMKEX2048
+	}
+
+	testCred, err := reqte.TestVouchers.GetVoucher(rnd, voucherTestId)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +24,8 @@ func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	// Generating TO0 handler
 	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	}, testCred.WawDeviceCredential, kexSuiteName, fdoshared.CIPHER_A128GCM, config) // TODO
+	to2requestor.ConfSeed = seed
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
@@ -56,9 +64,20 @@ func preExecuteTo2_64(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 
 }
 
-func executeTo2_64(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+func executeTo2_64(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_64 {
-		to2requestor, err := preExecuteTo2_64(reqte)
+		if run.skipTest(testId) {
+			continue
+		}
+
+		to2requestor, err := preExecuteTo2_64(reqte, run.config, seed, testId)
+		if err != nil && testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_ASYMKEX, testId) == testId {
+			// ASYMKEX is optional for the owner. Other tests still run
+			reqtDB.ReportTest(reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 with ASYMKEX2048. Exclude the test if the owner does not support ASYMKEX2048. "+err.Error()))
+			continue
+		}
+
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
 				Passed: false,
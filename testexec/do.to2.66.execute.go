@@ -4,12 +4,12 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst, config *fdoshared.Config, seed int64, testId testcom.FDOTestID) (*to2.To2Requestor, error) {
+	rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+	testCred, err := reqte.TestVouchers.GetVoucher(rnd, testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +17,8 @@ func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	// Generating TO0 handler
 	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, config) // TODO
+	to2requestor.ConfSeed = seed
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
@@ -61,9 +62,14 @@ func preExecuteTo2_66(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 
 }
 
-func executeTo2_66(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+func executeTo2_66(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_66 {
-		to2requestor, err := preExecuteTo2_66(reqte)
+		if run.skipTest(testId) {
+			continue
+		}
+
+		to2requestor, err := preExecuteTo2_66(reqte, run.config, seed, testId)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
 				Passed: false,
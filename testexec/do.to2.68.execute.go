@@ -6,12 +6,12 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
-	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
+func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst, config *fdoshared.Config, seed int64, testId testcom.FDOTestID) (*to2.To2Requestor, error) {
+	rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+	testCred, err := reqte.TestVouchers.GetVoucher(rnd, testcom.NULL_TEST)
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +19,8 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	// Generating TO0 handler
 	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM) // TODO
+	}, testCred.WawDeviceCredential, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, config) // TODO
+	to2requestor.ConfSeed = seed
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
@@ -69,9 +70,15 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 
 }
 
-func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
+func executeTo2_68(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
 	for _, testId := range testcom.FIDO_TEST_LIST_DOT_68 {
-		to2requestor, err := preExecuteTo2_68(reqte)
+		if run.skipTest(testId) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(testId))
+		to2requestor, err := preExecuteTo2_68(reqte, run.config, seed, testId)
 		if err != nil {
 			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
 				Passed: false,
@@ -142,7 +149,7 @@ func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 		default:
 			var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
 
-			randomIndex := fdoshared.NewRandomInt(0, len(deviceSims)-1)
+			randomIndex := rnd.Int(0, len(deviceSims)-1)
 			for i, deviceSim := range deviceSims {
 				selectedTestId := testcom.NULL_TEST
 
@@ -177,7 +184,7 @@ func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTe
 
 				if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC && maxCounter != 255 {
 					getOwnerInfo.ServiceInfo = []fdoshared.ServiceInfoKV{
-						deviceSims[fdoshared.NewRandomInt(0, len(deviceSims)-1)],
+						deviceSims[rnd.Int(0, len(deviceSims)-1)],
 					}
 
 					getOwnerInfo.IsMoreServiceInfo = true
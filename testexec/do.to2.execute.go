@@ -19,20 +19,22 @@ const TEST_NEGATIVE_PER_TEST_VOUCHERS int = 5
 
 type GenVouchersResult struct {
 	TestID                testcom.FDOTestID
+	BatchIndex            int
 	DeviceCredAndVouchers []fdoshared.DeviceCredAndVoucher
 	Error                 error
 }
 
-func GenerateTo2Vouchers_Thread(testId testcom.FDOTestID, guids fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB, wg *sync.WaitGroup, resultChannel chan GenVouchersResult) {
+func GenerateTo2Vouchers_Thread(testId testcom.FDOTestID, batchIndex int, guids fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB, config *fdoshared.Config, rnd *fdoshared.Conf_Rand, wg *sync.WaitGroup, resultChannel chan GenVouchersResult) {
 	log.Printf("Starting %s", testId)
 	defer wg.Done()
 	var genVouchersResult GenVouchersResult = GenVouchersResult{
 		TestID:                testId,
+		BatchIndex:            batchIndex,
 		DeviceCredAndVouchers: []fdoshared.DeviceCredAndVoucher{},
 	}
 
 	for _, guid := range guids {
-		testCred, err := devDB.GetVANDV(guid, testId)
+		testCred, err := devDB.GetVANDV(guid, testId, rnd, config)
 		if err != nil {
 			genVouchersResult.Error = fmt.Errorf("Error generating voucher %s for test %s. %s", guid.GetFormatted(), testId, err.Error())
 			break
@@ -46,37 +48,107 @@ func GenerateTo2Vouchers_Thread(testId testcom.FDOTestID, guids fdoshared.FdoGui
 	resultChannel <- genVouchersResult
 }
 
-func GenerateTo2Vouchers(guidList fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB) (map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher, error) {
+// Generates valid voucher for every owner sgType, in sgTypes order
+func GenerateSgTypeVouchers_Thread(testId testcom.FDOTestID, sgTypes []fdoshared.DeviceSgType, guids fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB, config *fdoshared.Config, rnd *fdoshared.Conf_Rand, wg *sync.WaitGroup, resultChannel chan GenVouchersResult) {
+	log.Printf("Starting %s", testId)
+	defer wg.Done()
+	var genVouchersResult GenVouchersResult = GenVouchersResult{
+		TestID:                testId,
+		DeviceCredAndVouchers: []fdoshared.DeviceCredAndVoucher{},
+	}
+
+	for i, sgType := range sgTypes {
+		testCred, err := devDB.GetVANDVWithSgType(guids[i], sgType, testcom.NULL_TEST, rnd, config)
+		if err != nil {
+			genVouchersResult.Error = fmt.Errorf("Error generating voucher %s for test %s. %s", guids[i].GetFormatted(), testId, err.Error())
+			break
+		}
+
+		genVouchersResult.DeviceCredAndVouchers = append(genVouchersResult.DeviceCredAndVouchers, *testCred)
+	}
+
+	log.Printf("Done %s", testId)
+
+	resultChannel <- genVouchersResult
+}
+
+// Number of seeded guids GenerateTo2Vouchers needs
+func To2VoucherGuidsNeeded() int {
+	testsLen := len(testcom.FIDO_TEST_LIST_VOUCHER) + len(testcom.FIDO_TEST_LIST_DOT_60_PKENC)
+	return testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS + TEST_POSITIVE_BATCHES*TEST_POSITIVE_BATCH_SIZE + len(fdoshared.SgTypeList) + len(testcom.FIDO_TEST_LIST_DOT_ASYMKEX)
+}
+
+// Generates positive vouchers, and vouchers for the selected voucher and encoding tests. Guids are assigned by the position in the full test list, so the selection does not change seeded vouchers
+func GenerateTo2Vouchers(guidList fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB, config *fdoshared.Config, seed int64, selection testcom.FDOTestSelection) (map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher, error) {
 	var vouchers map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher = map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher{}
 
-	totalThreads := len(testcom.FIDO_TEST_LIST_VOUCHER) + TEST_POSITIVE_BATCHES
+	if len(guidList) < To2VoucherGuidsNeeded() {
+		return nil, fmt.Errorf("not enough seeded device bases. Got %d, need %d", len(guidList), To2VoucherGuidsNeeded())
+	}
+
+	voucherTestIds := append(append([]testcom.FDOTestID{}, testcom.FIDO_TEST_LIST_VOUCHER...), testcom.FIDO_TEST_LIST_DOT_60_PKENC...)
+
+	skippedTests := selection.GetSkipped(voucherTestIds)
+	totalThreads := len(voucherTestIds) - len(skippedTests) + TEST_POSITIVE_BATCHES
+
+	negotiationSelected := selection.Selects(testcom.FIDO_DOT_NEGOTIATION_MATRIX)
+	if negotiationSelected {
+		totalThreads++
+	}
+
+	totalThreads += len(testcom.FIDO_TEST_LIST_DOT_ASYMKEX) - len(selection.GetSkipped(testcom.FIDO_TEST_LIST_DOT_ASYMKEX))
 
 	var wg sync.WaitGroup
 
 	chn := make(chan GenVouchersResult, totalThreads)
 
-	testsLen := len(testcom.FIDO_TEST_LIST_VOUCHER)
-	randomGuids := guidList.GetRandomSelection(testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS + TEST_POSITIVE_BATCHES*TEST_POSITIVE_BATCH_SIZE)
+	testsLen := len(voucherTestIds)
+	positiveGuidsLen := TEST_POSITIVE_BATCHES * TEST_POSITIVE_BATCH_SIZE
+	negotiationGuidsStart := testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS + positiveGuidsLen
+	asymKexGuidsStart := negotiationGuidsStart + len(fdoshared.SgTypeList)
+	randomGuids := guidList.GetRandomSelection(fdoshared.NewConf_Rand(seed), To2VoucherGuidsNeeded())
 
 	randomNegativeTestGuids := randomGuids[0 : testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS]
 
-	for i, testId := range testcom.FIDO_TEST_LIST_VOUCHER {
+	for i, testId := range voucherTestIds {
+		if !selection.Selects(testId) {
+			continue
+		}
+
 		indexStart := i * TEST_NEGATIVE_PER_TEST_VOUCHERS
 		indexEnd := (i + 1) * TEST_NEGATIVE_PER_TEST_VOUCHERS
 
 		wg.Add(1)
-		go GenerateTo2Vouchers_Thread(testId, randomNegativeTestGuids[indexStart:indexEnd], devDB, &wg, chn)
+		go GenerateTo2Vouchers_Thread(testId, 0, randomNegativeTestGuids[indexStart:indexEnd], devDB, config, fdoshared.Conf_DeriveRand(seed, string(testId)), &wg, chn)
 	}
 
-	randomPositiveTestGuids := randomGuids[testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS:]
+	randomPositiveTestGuids := randomGuids[testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS : testsLen*TEST_NEGATIVE_PER_TEST_VOUCHERS+positiveGuidsLen]
 	for i := 0; i < TEST_POSITIVE_BATCHES; i++ {
 		indexStart := i * TEST_POSITIVE_BATCH_SIZE
 		indexEnd := (i + 1) * TEST_POSITIVE_BATCH_SIZE
 
 		wg.Add(1)
-		go GenerateTo2Vouchers_Thread(testcom.NULL_TEST, randomPositiveTestGuids[indexStart:indexEnd], devDB, &wg, chn)
+		go GenerateTo2Vouchers_Thread(testcom.NULL_TEST, i, randomPositiveTestGuids[indexStart:indexEnd], devDB, config, fdoshared.Conf_DeriveRand(seed, fmt.Sprintf("%s-%d", testcom.NULL_TEST, i)), &wg, chn)
+	}
+
+	// Negotiation guids are taken after the positive ones, so they do not change the seeded positive vouchers
+	if negotiationSelected {
+		wg.Add(1)
+		go GenerateSgTypeVouchers_Thread(testcom.FIDO_DOT_NEGOTIATION_MATRIX, fdoshared.SgTypeList, randomGuids[negotiationGuidsStart:asymKexGuidsStart], devDB, config, fdoshared.Conf_DeriveRand(seed, string(testcom.FIDO_DOT_NEGOTIATION_MATRIX)), &wg, chn)
+	}
+
+	// ASYMKEX guids are taken last for the same reason
+	for i, testId := range testcom.FIDO_TEST_LIST_DOT_ASYMKEX {
+		if !selection.Selects(testId) {
+			continue
+		}
+
+		wg.Add(1)
+		go GenerateSgTypeVouchers_Thread(testId, []fdoshared.DeviceSgType{fdoshared.StRSA2048}, randomGuids[asymKexGuidsStart+i:asymKexGuidsStart+i+1], devDB, config, fdoshared.Conf_DeriveRand(seed, string(testId)), &wg, chn)
 	}
 
+	// Positive batches are merged in batch order, so that seeded voucher selection is reproducible
+	positiveBatches := make([][]fdoshared.DeviceCredAndVoucher, TEST_POSITIVE_BATCHES)
 	for i := 0; i < totalThreads; i++ {
 		result := <-chn
 
@@ -84,24 +156,42 @@ func GenerateTo2Vouchers(guidList fdoshared.FdoGuidList, devDB *dbs.DeviceBaseDB
 			return nil, result.Error
 		}
 
+		if result.TestID == testcom.NULL_TEST {
+			positiveBatches[result.BatchIndex] = result.DeviceCredAndVouchers
+			continue
+		}
+
 		vouchers[result.TestID] = append(vouchers[result.TestID], result.DeviceCredAndVouchers...)
 	}
 
 	wg.Wait()
 
+	for _, positiveBatch := range positiveBatches {
+		vouchers[testcom.NULL_TEST] = append(vouchers[testcom.NULL_TEST], positiveBatch...)
+	}
+
 	return vouchers, nil
 }
 
-func ExecuteDOTestsTo2(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
-	reqtDB.StartNewRun(reqte.Uuid)
+func ExecuteDOTestsTo2(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, config *fdoshared.Config, seed int64, selection testcom.FDOTestSelection) error {
+	run, err := startRun(config, reqtDB, reqte, seed, selection)
+	if err != nil {
+		return err
+	}
+	defer run.finish(nil)
 
-	executeTo2_60(reqte, reqtDB)
-	executeTo2_60_Vouchers(reqte, reqtDB)
-	executeTo2_62(reqte, reqtDB)
-	executeTo2_64(reqte, reqtDB)
-	executeTo2_66(reqte, reqtDB)
-	executeTo2_68(reqte, reqtDB)
-	executeTo2_70(reqte, reqtDB)
+	executeDOTestsTo2(run, reqte)
+
+	return nil
+}
 
-	reqtDB.FinishRun(reqte.Uuid)
+func executeDOTestsTo2(run *execRun, reqte reqtestsdeps.RequestTestInst) {
+	executeTo2_60(reqte, run)
+	executeTo2_60_Vouchers(reqte, run)
+	executeTo2_62(reqte, run)
+	executeTo2_64(reqte, run)
+	executeTo2_66(reqte, run)
+	executeTo2_68(reqte, run)
+	executeTo2_70(reqte, run)
+	executeTo2_Negotiation(reqte, run)
 }
@@ -0,0 +1,117 @@
+package testexec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// Runs TO2 up to DeviceServiceInfoReady66 with the set suites. Done70 is not sent, so the voucher can be reused for the next combination
+func executeTo2_NegotiationCell(reqte reqtestsdeps.RequestTestInst, config *fdoshared.Config, seed int64, testCred fdoshared.DeviceCredAndVoucher, sgType fdoshared.DeviceSgType, kexSuiteName fdoshared.KexSuiteName, cipherSuiteName fdoshared.CipherSuiteName) testcom.To2NegotiationCell {
+	cell := testcom.To2NegotiationCell{
+		SgType:          sgType,
+		KexSuiteName:    kexSuiteName,
+		CipherSuiteName: cipherSuiteName,
+		Result:          testcom.To2Negotiation_Broken,
+	}
+
+	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCred.WawDeviceCredential, kexSuiteName, cipherSuiteName, config)
+	to2requestor.ConfSeed = seed
+
+	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+	if errors.Is(err, to2.ErrFdoErrorResponse) && cell.IsMandatory() {
+		cell.Error = "Owner rejected mandatory suites. " + err.Error()
+		return cell
+	}
+
+	if errors.Is(err, to2.ErrFdoErrorResponse) {
+		cell.Result = testcom.To2Negotiation_Rejected
+		cell.Error = err.Error()
+		return cell
+	}
+
+	if err != nil {
+		cell.Error = err.Error()
+		return cell
+	}
+
+	err = fdoshared.CheckKexSuiteOwnerKey(kexSuiteName, to2requestor.ProveOVHdr61PubKey.PkType)
+	if err != nil {
+		cell.Error = "Owner accepted unusable combination. " + err.Error()
+		return cell
+	}
+
+	var ovEntries fdoshared.OVEntryArray
+	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
+		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
+		if err != nil {
+			cell.Error = err.Error()
+			return cell
+		}
+
+		if nextEntry.OVEntryNum != uint8(i) {
+			cell.Error = fmt.Sprintf("Server returned wrong entry. Expected %d. Got %d", i, nextEntry.OVEntryNum)
+			return cell
+		}
+
+		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	}
+
+	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
+	if err != nil {
+		cell.Error = err.Error()
+		return cell
+	}
+
+	_, _, err = to2requestor.ProveDevice64(testcom.NULL_TEST)
+	if err != nil {
+		cell.Error = err.Error()
+		return cell
+	}
+
+	_, _, err = to2requestor.DeviceServiceInfoReady66(testcom.NULL_TEST)
+	if err != nil {
+		cell.Error = err.Error()
+		return cell
+	}
+
+	cell.Result = testcom.To2Negotiation_Supported
+	return cell
+}
+
+func executeTo2_Negotiation(reqte reqtestsdeps.RequestTestInst, run *execRun) {
+	reqtDB, seed := run.reqtDB, run.seed
+	testId := testcom.FIDO_DOT_NEGOTIATION_MATRIX
+	if run.skipTest(testId) {
+		return
+	}
+
+	testCreds := reqte.TestVouchers[testId]
+	if len(testCreds) != len(fdoshared.SgTypeList) {
+		reqtDB.ReportTest(reqte.Uuid, testId, testcom.NewFailTestState(testId, "Negotiation vouchers are missing. Please create new test instance"))
+		return
+	}
+
+	var matrix testcom.To2NegotiationMatrix
+	for i, sgType := range fdoshared.SgTypeList {
+		for _, kexSuiteName := range fdoshared.KexSuitNames {
+			for _, cipherSuiteName := range fdoshared.CipherSuiteNames {
+				// Partial matrix is not reported
+				if run.ctx.Err() != nil {
+					return
+				}
+
+				matrix = append(matrix, executeTo2_NegotiationCell(reqte, run.config, seed, testCreds[i], sgType, kexSuiteName, cipherSuiteName))
+			}
+		}
+	}
+
+	reqtDB.ReportNegotiationMatrix(reqte.Uuid, matrix)
+	reqtDB.ReportTest(reqte.Uuid, testId, matrix.GetTestState(testId))
+}
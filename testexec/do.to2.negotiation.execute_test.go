@@ -0,0 +1,62 @@
+package testexec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestExecuteTo2_NegotiationCell_Rejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO2_60_HELLO_DEVICE, "Unsupported suites", http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	credential, err := fdoshared.NewWawDeviceCredential(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rvInfo, _ := fdoshared.UrlsToRendezvousInfo([]string{"http://localhost:8080"})
+	testCred, err := fdodeviceimplementation.NewVirtualDeviceAndVoucher(*credential, fdoshared.StSECP256R1, rvInfo, testcom.NULL_TEST, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqte := reqtestsdeps.NewRequestTestInst(server.URL, fdoshared.To2)
+	config := fdoshared.DefaultConfig()
+
+	testCases := []struct {
+		kexSuiteName    fdoshared.KexSuiteName
+		cipherSuiteName fdoshared.CipherSuiteName
+		expectedResult  testcom.To2NegotiationResult
+	}{
+		{fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM, testcom.To2Negotiation_Broken},
+		{fdoshared.KEX_ECDH384, fdoshared.CIPHER_A256GCM, testcom.To2Negotiation_Broken},
+		{fdoshared.KEX_ECDH256, fdoshared.CIPHER_AES_CCM_16_128_128, testcom.To2Negotiation_Rejected},
+		{fdoshared.KEX_DHKEXid14, fdoshared.CIPHER_A128GCM, testcom.To2Negotiation_Rejected},
+	}
+
+	var matrix testcom.To2NegotiationMatrix
+	for _, tc := range testCases {
+		cell := executeTo2_NegotiationCell(reqte, &config, 42, *testCred, fdoshared.StSECP256R1, tc.kexSuiteName, tc.cipherSuiteName)
+		if cell.Result != tc.expectedResult {
+			t.Errorf("%s: expected %s. Got %s %s", cell, tc.expectedResult, cell.Result, cell.Error)
+		}
+
+		matrix = append(matrix, cell)
+	}
+
+	if matrix.GetTestState(testcom.FIDO_DOT_NEGOTIATION_MATRIX).Passed {
+		t.Error("Expected matrix with rejected mandatory suites to fail")
+	}
+
+	if !matrix[2:].GetTestState(testcom.FIDO_DOT_NEGOTIATION_MATRIX).Passed {
+		t.Error("Expected matrix with rejected optional suites to pass")
+	}
+}
@@ -0,0 +1,188 @@
+package testexec
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/metrics"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// Run that was resumed this many times is most likely crashing the server, so it is marked as aborted instead
+const MAX_RUN_RESUMES int = 3
+
+var ErrRunInProgress = errors.New("test run is already in progress")
+var ErrRunNotInProgress = errors.New("test run is not in progress")
+var ErrBadSelection = errors.New("bad test selection")
+
+type execRun struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	config *fdoshared.Config
+	reqtDB *testdbs.RequestTestDB
+	job    reqtestsdeps.RequestRunJob
+	seed   int64
+
+	startedAt time.Time
+}
+
+var activeRunsMu sync.Mutex
+var activeRuns map[string]*execRun = map[string]*execRun{}
+
+func registerRun(config *fdoshared.Config, reqtDB *testdbs.RequestTestDB, rvteid []byte, getJob func() (*reqtestsdeps.RequestRunJob, error)) (*execRun, error) {
+	activeRunsMu.Lock()
+	defer activeRunsMu.Unlock()
+
+	runKey := hex.EncodeToString(rvteid)
+	if _, ok := activeRuns[runKey]; ok {
+		return nil, ErrRunInProgress
+	}
+
+	runJob, err := getJob()
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	run := &execRun{
+		ctx:    runCtx,
+		cancel: cancel,
+		config: config,
+		reqtDB: reqtDB,
+		job:    *runJob,
+		seed:   runJob.Seed,
+
+		startedAt: time.Now(),
+	}
+
+	activeRuns[runKey] = run
+
+	return run, nil
+}
+
+func startRun(config *fdoshared.Config, reqtDB *testdbs.RequestTestDB, reqte reqtestsdeps.RequestTestInst, seed int64, selection testcom.FDOTestSelection) (*execRun, error) {
+	err := selection.Validate(testcom.GetRequestTestIDs(reqte.Protocol))
+	if err != nil {
+		return nil, fmt.Errorf("%w. %s", ErrBadSelection, err.Error())
+	}
+
+	return registerRun(config, reqtDB, reqte.Uuid, func() (*reqtestsdeps.RequestRunJob, error) {
+		return reqtDB.StartNewRun(reqte.Uuid, seed, selection)
+	})
+}
+
+func resumeRun(config *fdoshared.Config, reqtDB *testdbs.RequestTestDB, rvteid []byte) (*execRun, error) {
+	return registerRun(config, reqtDB, rvteid, func() (*reqtestsdeps.RequestRunJob, error) {
+		return reqtDB.ResumeRun(rvteid)
+	})
+}
+
+// Returns true if test must not be executed, because it is not selected, it was completed before the restart, or the run was cancelled
+func (h *execRun) skipTest(testId testcom.FDOTestID) bool {
+	return h.ctx.Err() != nil || !h.job.Selection.Selects(testId) || h.job.IsTestCompleted(testId)
+}
+
+// Run that could not be executed is finished with failed status
+func (h *execRun) finish(runErr error) {
+	activeRunsMu.Lock()
+	delete(activeRuns, hex.EncodeToString(h.job.RequestTestId))
+	activeRunsMu.Unlock()
+
+	status := reqtestsdeps.RunStatus_Completed
+	if runErr != nil {
+		log.Printf("Test run %s for %s failed. %s", h.job.RunId, hex.EncodeToString(h.job.RequestTestId), runErr.Error())
+		status = reqtestsdeps.RunStatus_Failed
+	} else if h.ctx.Err() != nil {
+		status = reqtestsdeps.RunStatus_Cancelled
+	}
+
+	h.cancel()
+	h.reqtDB.FinishRun(h.job.RequestTestId, status)
+
+	metrics.ReportTestRun(h.job.Protocol, string(status), time.Since(h.startedAt))
+}
+
+// Cancels the run in progress. The test that is currently executing is allowed to complete.
+// Run that is in progress, but is not executed by this server, e.g. left after a crash, is marked as cancelled straight away.
+func CancelRun(reqtDB *testdbs.RequestTestDB, rvteid []byte) error {
+	activeRunsMu.Lock()
+	run, ok := activeRuns[hex.EncodeToString(rvteid)]
+	activeRunsMu.Unlock()
+
+	if ok {
+		run.cancel()
+		return nil
+	}
+
+	_, err := reqtDB.GetRunJob(rvteid)
+	if err != nil {
+		return ErrRunNotInProgress
+	}
+
+	reqtDB.FinishRun(rvteid, reqtestsdeps.RunStatus_Cancelled)
+
+	return nil
+}
+
+// Resumes runs that were interrupted by the server restart. Runs that were already resumed MAX_RUN_RESUMES times are marked as aborted.
+func ResumeInterruptedRuns(reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, config *fdoshared.Config) {
+	runJobs, err := reqtDB.GetRunJobs()
+	if err != nil {
+		log.Println("Error loading interrupted test runs. " + err.Error())
+		return
+	}
+
+	for _, runJob := range runJobs {
+		rvteid := runJob.RequestTestId
+
+		if runJob.Resumes >= MAX_RUN_RESUMES {
+			log.Printf("Test run %s for %s was resumed %d times. Marking it as aborted.", runJob.RunId, hex.EncodeToString(rvteid), runJob.Resumes)
+			reqtDB.FinishRun(rvteid, reqtestsdeps.RunStatus_Aborted)
+			continue
+		}
+
+		rvte, err := reqtDB.Get(rvteid)
+		if err != nil {
+			log.Printf("Can not resume test run %s. Marking it as aborted. %s", runJob.RunId, err.Error())
+			reqtDB.FinishRun(rvteid, reqtestsdeps.RunStatus_Aborted)
+			continue
+		}
+
+		run, err := resumeRun(config, reqtDB, rvteid)
+		if err != nil {
+			log.Printf("Can not resume test run %s. Marking it as aborted. %s", runJob.RunId, err.Error())
+			reqtDB.FinishRun(rvteid, reqtestsdeps.RunStatus_Aborted)
+			continue
+		}
+
+		go executeRun(run, *rvte, devDB)
+	}
+}
+
+// Executes resumed run, and finishes it with failed status when it can not be executed
+func executeRun(run *execRun, reqte reqtestsdeps.RequestTestInst, devDB *dbs.DeviceBaseDB) {
+	var err error
+	defer func() {
+		run.finish(err)
+	}()
+
+	switch reqte.Protocol {
+	case fdoshared.To0:
+		executeRVTestsTo0(run, reqte, devDB)
+	case fdoshared.To1:
+		executeRVTestsTo1(run, reqte, devDB)
+	case fdoshared.To2:
+		executeDOTestsTo2(run, reqte)
+	default:
+		err = fmt.Errorf("protocol TO%d is not supported", reqte.Protocol)
+	}
+}
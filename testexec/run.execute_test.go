@@ -0,0 +1,164 @@
+package testexec
+
+import (
+	"testing"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/kv"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func test_newRequestTest(t *testing.T, protocol fdoshared.FdoToProtocol) (*testdbs.RequestTestDB, reqtestsdeps.RequestTestInst) {
+	reqtDB := testdbs.NewRequestTestDB(kv.NewMemoryStore())
+	reqte := reqtestsdeps.NewRequestTestInst("http://do.example.com", protocol)
+
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reqtDB, reqte
+}
+
+func test_expectRunStatus(t *testing.T, reqtDB *testdbs.RequestTestDB, rvteid []byte, status reqtestsdeps.RequestTestRunStatus) {
+	t.Helper()
+
+	rvte, err := reqtDB.Get(rvteid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rvte.InProgress || rvte.CurrentTestRun.Status != status {
+		t.Fatalf("Expected run to be %s. Got %s, in progress %v", status, rvte.CurrentTestRun.Status, rvte.InProgress)
+	}
+
+	_, err = reqtDB.GetRunJob(rvteid)
+	if err == nil {
+		t.Fatal("Expected run job to be deleted")
+	}
+}
+
+func TestCancelRun_Active(t *testing.T) {
+	reqtDB, reqte := test_newRequestTest(t, fdoshared.To2)
+
+	run, err := startRun(nil, reqtDB, reqte, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = startRun(nil, reqtDB, reqte, 42, testcom.FDOTestSelection{})
+	if err != ErrRunInProgress {
+		t.Fatalf("Expected second run to be rejected. Got %v", err)
+	}
+
+	err = CancelRun(reqtDB, reqte.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !run.skipTest(testcom.FIDO_DOT_60_POSITIVE) {
+		t.Fatal("Expected cancelled run to skip remaining tests")
+	}
+
+	run.finish(nil)
+	test_expectRunStatus(t, reqtDB, reqte.Uuid, reqtestsdeps.RunStatus_Cancelled)
+
+	err = CancelRun(reqtDB, reqte.Uuid)
+	if err != ErrRunNotInProgress {
+		t.Fatalf("Expected finished run not to be cancelled. Got %v", err)
+	}
+}
+
+func TestCancelRun_NotExecuted(t *testing.T) {
+	reqtDB, reqte := test_newRequestTest(t, fdoshared.To2)
+
+	_, err := reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CancelRun(reqtDB, reqte.Uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test_expectRunStatus(t, reqtDB, reqte.Uuid, reqtestsdeps.RunStatus_Cancelled)
+}
+
+func TestResumeInterruptedRuns(t *testing.T) {
+	// Resumed too many times
+	reqtDB, reqte := test_newRequestTest(t, fdoshared.To2)
+	_, err := reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < MAX_RUN_RESUMES; i++ {
+		_, err = reqtDB.ResumeRun(reqte.Uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ResumeInterruptedRuns(reqtDB, nil, nil)
+	test_expectRunStatus(t, reqtDB, reqte.Uuid, reqtestsdeps.RunStatus_Aborted)
+
+	// Resumed run that can not be executed
+	reqtDB, reqte = test_newRequestTest(t, fdoshared.FdoToProtocol(9))
+	_, err = reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ResumeInterruptedRuns(reqtDB, nil, nil)
+
+	for i := 0; i < 100; i++ {
+		runJobs, err := reqtDB.GetRunJobs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(runJobs) == 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	test_expectRunStatus(t, reqtDB, reqte.Uuid, reqtestsdeps.RunStatus_Failed)
+}
+
+func TestResumeInterruptedRuns_MissingEntry(t *testing.T) {
+	store := kv.NewMemoryStore()
+	reqtDB := testdbs.NewRequestTestDB(store)
+	reqte := reqtestsdeps.NewRequestTestInst("http://do.example.com", fdoshared.To2)
+
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reqtDB.StartNewRun(reqte.Uuid, 42, testcom.FDOTestSelection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Delete(append([]byte("rvte-"), reqte.Uuid...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ResumeInterruptedRuns(reqtDB, nil, nil)
+
+	runJobs, err := reqtDB.GetRunJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runJobs) != 0 {
+		t.Fatalf("Expected run job of the missing entry to be deleted. Got %+v", runJobs)
+	}
+}
@@ -1,8 +1,6 @@
 package testexec
 
 import (
-	"context"
-
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
@@ -12,12 +10,29 @@ import (
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
-	reqtDB.StartNewRun(reqte.Uuid)
+func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, config *fdoshared.Config, seed int64, selection testcom.FDOTestSelection) error {
+	run, err := startRun(config, reqtDB, reqte, seed, selection)
+	if err != nil {
+		return err
+	}
+	defer run.finish(nil)
+
+	executeRVTestsTo0(run, reqte, devDB)
+
+	return nil
+}
+
+func executeRVTestsTo0(run *execRun, reqte reqtestsdeps.RequestTestInst, devDB *dbs.DeviceBaseDB) {
+	reqtDB, config, seed := run.reqtDB, run.config, run.seed
 
 	for _, rv20test := range testcom.FIDO_TEST_LIST_RVT_20 {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv20test)
+		if run.skipTest(rv20test) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(rv20test))
+		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid(rnd)
+		testCredV, err := devDB.GetVANDV(randomGuid, rv20test, rnd, config)
 
 		if err != nil {
 			errTestState := testcom.FDOTestState{
@@ -31,7 +46,8 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 
 		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
+		}, testCredV.VoucherDBEntry, config)
+		to0inst.SetConfSeed(seed)
 
 		switch rv20test {
 		case testcom.FIDO_RVT_20_POSITIVE:
@@ -65,8 +81,13 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	}
 
 	for _, rv22test := range testcom.FIDO_TEST_LIST_RVT_22 {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv22test)
+		if run.skipTest(rv22test) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(rv22test))
+		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid(rnd)
+		testCredV, err := devDB.GetVANDV(randomGuid, rv22test, rnd, config)
 
 		if err != nil {
 			errTestState := testcom.FDOTestState{
@@ -80,7 +101,8 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 
 		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
+		}, testCredV.VoucherDBEntry, config)
+		to0inst.SetConfSeed(seed)
 
 		var errTestState testcom.FDOTestState
 		helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
@@ -94,7 +116,7 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 		}
 
 		switch rv22test {
-		case testcom.FIDO_RVT_23_POSITIVE:
+		case testcom.FIDO_RVT_23_POSITIVE, testcom.FIDO_RVT_23_POSITIVE_PKENC_X509, testcom.FIDO_RVT_23_POSITIVE_PKENC_X5CHAIN, testcom.FIDO_RVT_23_POSITIVE_PKENC_COSEKEY:
 			_, _, err = to0inst.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
 			if err != nil {
 				errTestState = testcom.FDOTestState{
@@ -126,8 +148,13 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	}
 
 	for _, rv22VoucherTest := range testcom.FIDO_TEST_LIST_VOUCHER {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv22VoucherTest)
+		if run.skipTest(rv22VoucherTest) {
+			continue
+		}
+
+		rnd := fdoshared.Conf_DeriveRand(seed, string(rv22VoucherTest))
+		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid(rnd)
+		testCredV, err := devDB.GetVANDV(randomGuid, rv22VoucherTest, rnd, config)
 		if err != nil {
 			errTestState := testcom.FDOTestState{
 				Passed: false,
@@ -140,7 +167,8 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 
 		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
 			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
+		}, testCredV.VoucherDBEntry, config)
+		to0inst.SetConfSeed(seed)
 
 		var errTestState testcom.FDOTestState
 		helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
@@ -163,8 +191,7 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			rvtTestState = &errTestState
 		}
 
+		rvtTestState.SetFuzzMutation(testCredV.FuzzMutation)
 		reqtDB.ReportTest(reqte.Uuid, rv22VoucherTest, *rvtTestState)
 	}
-
-	reqtDB.FinishRun(reqte.Uuid)
 }
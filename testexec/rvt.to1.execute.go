@@ -1,8 +1,6 @@
 package testexec
 
 import (
-	"context"
-
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -12,12 +10,25 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
-func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
-	reqtDB.StartNewRun(reqte.Uuid)
+func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, config *fdoshared.Config, seed int64, selection testcom.FDOTestSelection) error {
+	run, err := startRun(config, reqtDB, reqte, seed, selection)
+	if err != nil {
+		return err
+	}
+	defer run.finish(nil)
+
+	executeRVTestsTo1(run, reqte, devDB)
+
+	return nil
+}
+
+func executeRVTestsTo1(run *execRun, reqte reqtestsdeps.RequestTestInst, devDB *dbs.DeviceBaseDB) {
+	reqtDB, config, seed := run.reqtDB, run.config, run.seed
 
 	// Generating voucher
-	randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-	testCredV, err := devDB.GetVANDV(randomGuid, testcom.NULL_TEST)
+	rnd := fdoshared.Conf_DeriveRand(seed, string(testcom.NULL_TO1_SETUP))
+	randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid(rnd)
+	testCredV, err := devDB.GetVANDV(randomGuid, testcom.NULL_TEST, rnd, config)
 
 	if err != nil {
 		errTestState := testcom.FDOTestState{
@@ -32,7 +43,8 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	// Generating TO0 handler
 	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
-	}, testCredV.VoucherDBEntry, ctx)
+	}, testCredV.VoucherDBEntry, config)
+	to0inst.SetConfSeed(seed)
 
 	// Enroling voucher
 	var errTestState testcom.FDOTestState
@@ -59,9 +71,14 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
 	}, testCredV.WawDeviceCredential)
+	to1inst.SetConfSeed(seed)
 
 	// Starting tests
 	for _, rv30test := range testcom.FIDO_TEST_LIST_DEVT_30 {
+		if run.skipTest(rv30test) {
+			continue
+		}
+
 		switch rv30test {
 
 		case testcom.FIDO_DEVT_30_POSITIVE:
@@ -98,6 +115,10 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 	}
 
 	for _, rv32test := range testcom.FIDO_TEST_LIST_DEVT_32 {
+		if run.skipTest(rv32test) {
+			continue
+		}
+
 		helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
 		if err != nil {
 			errTestState = testcom.FDOTestState{
@@ -142,6 +163,4 @@ func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			reqtDB.ReportTest(reqte.Uuid, rv32test, *rvtTestState)
 		}
 	}
-
-	reqtDB.FinishRun(reqte.Uuid)
 }